@@ -0,0 +1,163 @@
+package backend
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+)
+
+// transcode bit rates to choose from, lowest first
+var adaptiveBitRatesKBPS = []int{64, 96, 128, 192, 256, 320}
+
+const (
+	// downloads must be at least this many times faster than
+	// playback for the connection to be considered good
+	adaptiveMinSpeedup = 2.0
+	// typical bit rate of a raw (lossless) file
+	rawFileKBPS = 1000
+	// connections that could download raw files this many times
+	// faster than playback are fast
+	adaptiveFastSpeedup = 6.0
+	// number of transcoded downloads in a row that must be good
+	// before trying the next higher bit rate
+	adaptiveStepUpAfter = 3
+	// max number of issued stream URLs remembered, since most
+	// are streamed by the player rather than downloaded by the cache
+	adaptiveMaxIssued = 32
+)
+
+// AdaptiveTranscoder chooses the transcoding of streams by how fast tracks
+// download over the current network, which is identified by the hostname
+// through which the server is reached (e.g. a LAN Hostname vs. AltHostname).
+// It steps down the bit rate when downloads are not comfortably faster than
+// playback, and back up to streaming raw files when they are.
+type AdaptiveTranscoder struct {
+	lock     sync.Mutex
+	networks map[string]*networkState
+	issued   []issuedStream
+}
+
+type networkState struct {
+	// index into adaptiveBitRatesKBPS, or len(adaptiveBitRatesKBPS) for raw
+	level      int
+	goodStreak int
+}
+
+type issuedStream struct {
+	url      string
+	network  string
+	level    int
+	duration time.Duration
+}
+
+func NewAdaptiveTranscoder() *AdaptiveTranscoder {
+	return &AdaptiveTranscoder{networks: make(map[string]*networkState)}
+}
+
+// StreamSettings returns the transcode settings to request for the next
+// stream over the given network, transcoding to the given codec
+// with at most maxBitRateKBPS (if > 0), or nil for the raw file.
+func (a *AdaptiveTranscoder) StreamSettings(network, codec string, maxBitRateKBPS int) *mediaprovider.TranscodeSettings {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	level := a.network(network).level
+	if level == len(adaptiveBitRatesKBPS) {
+		return nil
+	}
+	br := adaptiveBitRatesKBPS[level]
+	if maxBitRateKBPS > 0 {
+		br = min(br, maxBitRateKBPS)
+	}
+	return &mediaprovider.TranscodeSettings{Codec: codec, BitRateKBPS: br}
+}
+
+// StreamIssued records the URL of a stream requested with the current
+// StreamSettings for the network, so that its download can be reported.
+func (a *AdaptiveTranscoder) StreamIssued(url, network string, trackDuration time.Duration) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if slices.ContainsFunc(a.issued, func(s issuedStream) bool { return s.url == url }) {
+		return
+	}
+	if len(a.issued) == adaptiveMaxIssued {
+		a.issued = a.issued[1:]
+	}
+	a.issued = append(a.issued, issuedStream{
+		url:      url,
+		network:  network,
+		level:    a.network(network).level,
+		duration: trackDuration,
+	})
+}
+
+// DownloadCompleted reports that the stream at url finished downloading
+// in the given time. A download slower than playback means the stream
+// would have stalled. Downloads of streams not issued with StreamIssued
+// are ignored.
+func (a *AdaptiveTranscoder) DownloadCompleted(url string, elapsed time.Duration) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for i, s := range a.issued {
+		if s.url == url {
+			a.issued = append(a.issued[:i], a.issued[i+1:]...)
+			if s.duration > 0 && elapsed > 0 {
+				a.network(s.network).update(s.level, s.duration.Seconds()/elapsed.Seconds())
+			}
+			return
+		}
+	}
+}
+
+// must be called with lock held
+func (a *AdaptiveTranscoder) network(network string) *networkState {
+	n, ok := a.networks[network]
+	if !ok {
+		// start optimistically with raw files
+		n = &networkState{level: len(adaptiveBitRatesKBPS)}
+		a.networks[network] = n
+	}
+	return n
+}
+
+// update adjusts the level for a download made at the given level
+// which downloaded speedup times faster than it plays.
+func (n *networkState) update(level int, speedup float64) {
+	raw := len(adaptiveBitRatesKBPS)
+	if speedup < adaptiveMinSpeedup {
+		n.goodStreak = 0
+		if level == raw {
+			// step down to the bit rate the connection can sustain
+			n.level = levelForBitRate(int(rawFileKBPS * speedup / adaptiveMinSpeedup))
+		} else {
+			n.level = max(min(n.level, level-1), 0)
+		}
+		return
+	}
+	if level != n.level || level == raw {
+		return // nothing to do, or the level changed since the download began
+	}
+	if float64(adaptiveBitRatesKBPS[level])*speedup >= rawFileKBPS*adaptiveFastSpeedup {
+		// fast enough to go straight back to raw files
+		n.level = raw
+		n.goodStreak = 0
+		return
+	}
+	n.goodStreak++
+	if n.goodStreak >= adaptiveStepUpAfter {
+		n.goodStreak = 0
+		n.level++
+	}
+}
+
+// levelForBitRate returns the highest level not above the given bit rate.
+func levelForBitRate(kbps int) int {
+	level := 0
+	for i, br := range adaptiveBitRatesKBPS {
+		if br <= kbps {
+			level = i
+		}
+	}
+	return level
+}
@@ -0,0 +1,64 @@
+package backend
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveTranscoder(t *testing.T) {
+	a := NewAdaptiveTranscoder()
+	const lan, wan = "http://192.168.1.10:4533", "https://music.example.com"
+	dur := 4 * time.Minute
+
+	if ts := a.StreamSettings(wan, "opus", 256); ts != nil {
+		t.Fatalf("expected raw files at first, got %+v", ts)
+	}
+
+	// a raw file downloading slower than it plays
+	// steps down to a bit rate the connection can sustain
+	a.StreamIssued("raw1", wan, dur)
+	a.DownloadCompleted("raw1", dur*2)
+	ts := a.StreamSettings(wan, "opus", 256)
+	if ts == nil || ts.BitRateKBPS != 192 || ts.Codec != "opus" {
+		t.Fatalf("expected opus 192, got %+v", ts)
+	}
+	// the max bit rate setting is a ceiling
+	if ts := a.StreamSettings(wan, "opus", 128); ts.BitRateKBPS != 128 {
+		t.Errorf("expected bit rate capped at 128, got %d", ts.BitRateKBPS)
+	}
+
+	// a stall steps down one level
+	a.StreamIssued("t1", wan, dur)
+	a.DownloadCompleted("t1", dur*2)
+	if ts := a.StreamSettings(wan, "opus", 0); ts.BitRateKBPS != 128 {
+		t.Fatalf("expected 128 after stall, got %d", ts.BitRateKBPS)
+	}
+
+	// consecutive good downloads step up one level
+	for i, url := range []string{"t2", "t3", "t4"} {
+		a.StreamIssued(url, wan, dur)
+		a.DownloadCompleted(url, dur/4)
+		want := 128
+		if i == 2 {
+			want = 192
+		}
+		if ts := a.StreamSettings(wan, "opus", 0); ts.BitRateKBPS != want {
+			t.Fatalf("download %d: expected %d, got %d", i, want, ts.BitRateKBPS)
+		}
+	}
+
+	// a very fast download goes straight back to raw files
+	a.StreamIssued("t5", wan, dur)
+	a.DownloadCompleted("t5", dur/100)
+	if ts := a.StreamSettings(wan, "opus", 0); ts != nil {
+		t.Errorf("expected raw files on fast connection, got %+v", ts)
+	}
+
+	// networks are tracked separately, and unknown downloads are ignored
+	a.StreamIssued("lan1", lan, dur)
+	a.DownloadCompleted("other", dur*2)
+	a.DownloadCompleted("lan1", dur/50)
+	if ts := a.StreamSettings(lan, "opus", 0); ts != nil {
+		t.Errorf("expected raw files on LAN, got %+v", ts)
+	}
+}
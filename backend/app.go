@@ -5,6 +5,7 @@ import (
 	"debug/pe"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/url"
 	"os"
@@ -17,6 +18,7 @@ import (
 	"time"
 
 	"github.com/dweymouth/supersonic/backend/ipc"
+	"github.com/dweymouth/supersonic/backend/logging"
 	"github.com/dweymouth/supersonic/backend/mediaprovider"
 	"github.com/dweymouth/supersonic/backend/player"
 	"github.com/dweymouth/supersonic/backend/player/mpv"
@@ -26,17 +28,20 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/20after4/configdir"
-	"github.com/zalando/go-keyring"
 )
 
 const (
 	configFile               = "config.toml"
 	portableDir              = "supersonic_portable"
 	savedQueueFile           = "saved_queue.json"
+	credentialFile           = "credentials.enc"
 	savedUnshuffledQueueFile = "saved_unshuffled_queue.json"
 	savedShuffledQueueFile   = "saved_shuffled_queue.json"
 	themesDir                = "themes"
 	audioCacheSubdir         = "audio"
+	logFileName              = "supersonic.log"
+	logFileMaxSize           = 5 * 1024 * 1024
+	logFileBackups           = 3
 )
 
 var (
@@ -47,19 +52,25 @@ var (
 )
 
 type App struct {
-	Config          *Config
-	ServerManager   *ServerManager
-	LyricsManager   *LyricsManager
-	ImageManager    *ImageManager
-	AudioCache      *AudioCache
-	AutoEQManager   *AutoEQManager
-	EQPresetManager *EQPresetManager
-	PlaybackManager *PlaybackManager
-	LocalPlayer     *mpv.Player
-	UpdateChecker   UpdateChecker
-	MPRISHandler    *MPRISHandler
-	WinSMTC         *windows.SMTC
-	ipcServer       ipc.IPCServer
+	Config        *Config
+	ServerManager *ServerManager
+	LyricsManager *LyricsManager
+	ImageManager  *ImageManager
+	AudioCache    *AudioCache
+	SearchIndex   *SearchIndex
+	// Non-nil if credentials are saved in an encrypted file,
+	// which must be unlocked before connecting to a server
+	CredentialFile     *CredentialFile
+	AutoEQManager      *AutoEQManager
+	EQPresetManager    *EQPresetManager
+	AudioDeviceManager *AudioDeviceManager
+	PlaybackManager    *PlaybackManager
+	LocalPlayer        *mpv.Player
+	UpdateChecker      UpdateChecker
+	MPRISHandler       *MPRISHandler
+	DLNARenderer       *DLNARenderer
+	WinSMTC            *windows.SMTC
+	ipcServer          ipc.IPCServer
 
 	// UI callbacks to be set in main
 	OnReactivate  func()
@@ -79,7 +90,7 @@ type App struct {
 
 	lastWrittenCfg Config
 
-	logFile *os.File
+	logFile *logging.RotatingFile
 }
 
 func (a *App) VersionTag() string {
@@ -101,13 +112,14 @@ func StartupApp(appName, displayAppName, appVersion, appVersionTag, latestReleas
 	configdir.MakePath(confDir)
 	configdir.MakePath(cacheDir)
 
-	var logFile *os.File
-	if isWindowsGUI() {
-		// Can't log to console in Windows GUI app so log to file instead
-		if f, err := os.Create(filepath.Join(confDir, "supersonic.log")); err == nil {
-			log.SetOutput(f)
-			logFile = f
-		}
+	logFile, err := logging.OpenRotatingFile(filepath.Join(confDir, logFileName), logFileMaxSize, logFileBackups)
+	if err != nil {
+		log.Printf("error opening log file: %v", err)
+	} else if isWindowsGUI() {
+		// Can't log to console in Windows GUI app so log only to file
+		logging.Init(logFile)
+	} else {
+		logging.Init(io.MultiWriter(os.Stderr, logFile))
 	}
 
 	a := &App{
@@ -121,6 +133,7 @@ func StartupApp(appName, displayAppName, appVersion, appVersionTag, latestReleas
 	}
 	a.bgrndCtx, a.cancel = context.WithCancel(context.Background())
 	a.readConfig()
+	logging.SetLevels(a.Config.Logging.Level, a.Config.Logging.Subsystems)
 
 	cli, _ := ipc.Connect()
 	if HaveCommandLineOptions() {
@@ -130,14 +143,14 @@ func StartupApp(appName, displayAppName, appVersion, appVersionTag, latestReleas
 		}
 		return nil, ErrAnotherInstance
 	} else if cli != nil && !a.Config.Application.AllowMultiInstance {
-		log.Println("Another instance is running. Reactivating it...")
+		appLog.Info("another instance is running, reactivating it")
 		cli.Show()
 		return nil, ErrAnotherInstance
 	}
 
-	log.Printf("Starting %s...", appName)
-	log.Printf("Using config dir: %s", confDir)
-	log.Printf("Using cache dir: %s", cacheDir)
+	appLog.Info("starting", "app", appName, "version", appVersion)
+	appLog.Info("using config dir", "path", confDir)
+	appLog.Info("using cache dir", "path", cacheDir)
 
 	if a.Config.Application.EnableAutoUpdateChecker {
 		a.UpdateChecker = NewUpdateChecker(appVersionTag, latestReleaseURL, &a.Config.Application.LastCheckedVersion)
@@ -151,16 +164,30 @@ func StartupApp(appName, displayAppName, appVersion, appVersionTag, latestReleas
 		return nil, err
 	}
 
-	a.ServerManager = NewServerManager(appName, appVersion, a.Config, !portableMode && a.Config.Application.EnablePasswordStorage)
+	var credentials CredentialStore
+	if a.Config.Application.EnablePasswordStorage {
+		if a.Config.Application.UseCredentialFile {
+			a.CredentialFile = NewCredentialFile(filepath.Join(confDir, credentialFile))
+			credentials = a.CredentialFile
+		} else if !portableMode {
+			credentials = keyringStore{service: appName}
+		}
+	}
+	a.ServerManager = NewServerManager(appName, appVersion, a.Config, credentials)
 	a.ImageManager = NewImageManager(a.bgrndCtx, a.ServerManager, cacheDir)
-	if a.Config.Playback.UseWaveformSeekbar {
+	if a.Config.Application.EnableLocalSearchIndex {
+		a.SearchIndex = NewSearchIndex(a.bgrndCtx, a.ServerManager)
+	}
+	// the audio cache also measures download speed for adaptive transcoding
+	if a.Config.Playback.UseWaveformSeekbar || a.Config.Transcoding.Adaptive {
 		ac, err := NewAudioCache(a.bgrndCtx, a.ServerManager, filepath.Join(cacheDir, audioCacheSubdir))
 		if err != nil {
-			log.Printf("failed to create audio cache: %s", err.Error())
+			cacheLog.Error("failed to create audio cache", "err", err)
 		}
 		a.AudioCache = ac
 	}
 	a.PlaybackManager = NewPlaybackManager(a.bgrndCtx, a.ServerManager, a.AudioCache, a.LocalPlayer, &a.Config.Playback, &a.Config.Scrobbling, &a.Config.Transcoding, &a.Config.Application)
+	a.PlaybackManager.SetBitPerfect(a.Config.LocalPlayback.BitPerfect)
 	a.PlaybackManager.CoverArtPathFn = func(coverArtID string) (string, error) {
 		// Ensure the thumbnail is cached on disk, then return its path so
 		// the DLNA player can expose it through the local proxy as
@@ -168,8 +195,27 @@ func StartupApp(appName, displayAppName, appVersion, appVersionTag, latestReleas
 		a.ImageManager.GetCoverThumbnail(coverArtID)
 		return a.ImageManager.GetCoverArtPath(coverArtID)
 	}
+	a.PlaybackManager.InitMPVFn = func(p *mpv.Player) error {
+		c := a.Config.LocalPlayback
+		return p.Init(clamp(c.InMemoryCacheSizeMB, 10, 500), resolveHTTPProxy(c))
+	}
 	a.Config.Application.MaxImageCacheSizeMB = clamp(a.Config.Application.MaxImageCacheSizeMB, 1, 500)
 	a.ImageManager.SetMaxOnDiskCacheSizeBytes(int64(a.Config.Application.MaxImageCacheSizeMB) * 1_048_576)
+	a.ServerManager.OnServerConnected(func(*ServerConfig) {
+		if a.LocalPlayer != nil {
+			a.LocalPlayer.SetTLSOptions(a.ServerManager.StreamTLSOptions())
+			if err := a.LocalPlayer.SetHTTPHeaders(a.ServerManager.StreamHTTPHeaders()); err != nil {
+				playbackLog.Error("error setting stream HTTP headers", "err", err)
+			}
+		}
+	})
+	a.ServerManager.OnHostChanged(func(string) {
+		if a.LocalPlayer != nil {
+			if err := a.LocalPlayer.SetHTTPHeaders(a.ServerManager.StreamHTTPHeaders()); err != nil {
+				playbackLog.Error("error setting stream HTTP headers", "err", err)
+			}
+		}
+	})
 	a.ServerManager.SetPrefetchAlbumCoverCallback(func(coverID string) {
 		_, _ = a.ImageManager.GetCoverThumbnail(coverID)
 	})
@@ -185,6 +231,8 @@ func StartupApp(appName, displayAppName, appVersion, appVersionTag, latestReleas
 	autoEQTimeout := time.Duration(a.Config.Application.RequestTimeoutSeconds) * time.Second
 	a.AutoEQManager = NewAutoEQManager(filepath.Join(cacheDir, "autoeq"), autoEQTimeout)
 
+	a.AudioDeviceManager.Start(a.bgrndCtx)
+
 	// Periodically scan for remote players
 	go a.PlaybackManager.ScanRemotePlayers(a.bgrndCtx, true /*fastScan*/)
 	go func() {
@@ -243,7 +291,7 @@ func StartupApp(appName, displayAppName, appVersion, appVersionTag, latestReleas
 				a.callOnReloadTheme)
 			go a.ipcServer.Serve(listener)
 		} else {
-			log.Printf("error starting IPC server: %s", err.Error())
+			appLog.Error("error starting IPC server", "err", err)
 		}
 	}
 
@@ -259,6 +307,12 @@ func StartupApp(appName, displayAppName, appVersion, appVersionTag, latestReleas
 		// Windows SMTC is initialized from main once we have a window HWND.
 	}
 
+	if a.Config.LocalPlayback.DLNARendererEnabled {
+		if err := a.SetDLNARendererEnabled(true); err != nil {
+			dlnaLog.Error("error starting DLNA renderer", "err", err)
+		}
+	}
+
 	a.startConfigWriter(a.bgrndCtx)
 
 	appInstance = a
@@ -316,11 +370,11 @@ func (a *App) readConfig() {
 	a.isFirstLaunch = !cfgExists
 	cfg, err := ReadConfigFile(cfgPath, a.appVersionTag)
 	if err != nil {
-		log.Printf("Error reading app config file: %v", err)
+		appLog.Error("error reading app config file", "err", err)
 		cfg = DefaultConfig(a.appVersionTag)
 		if cfgExists {
 			backupCfgName := fmt.Sprintf("%s.bak", configFile)
-			log.Printf("Config file may be malformed: copying to %s", backupCfgName)
+			appLog.Warn("config file may be malformed, copying it", "backup", backupCfgName)
 			_ = util.CopyFile(cfgPath, path.Join(a.configDir, backupCfgName))
 		}
 	}
@@ -392,7 +446,7 @@ func (a *App) initMPV() error {
 
 	// Log proxy configuration for debugging (redact credentials)
 	if httpProxy != "" {
-		log.Printf("Setting MPV proxy: %s", redactProxyURL(httpProxy))
+		playbackLog.Info("setting MPV proxy", "proxy", redactProxyURL(httpProxy))
 	}
 
 	if err := p.Init(c.InMemoryCacheSizeMB, httpProxy); err != nil {
@@ -406,27 +460,11 @@ func (a *App) setupMPV() error {
 	a.Config.LocalPlayback.Volume = clamp(a.Config.LocalPlayback.Volume, 0, 100)
 	a.LocalPlayer.SetVolume(a.Config.LocalPlayback.Volume)
 
-	devs, err := a.LocalPlayer.ListAudioDevices()
+	adm, err := NewAudioDeviceManager(a.LocalPlayer, &a.Config.LocalPlayback)
 	if err != nil {
 		return err
 	}
-
-	desiredDevice := a.Config.LocalPlayback.AudioDeviceName
-	var desiredDeviceAvailable bool
-	for _, dev := range devs {
-		if dev.Name == desiredDevice {
-			desiredDeviceAvailable = true
-			break
-		}
-	}
-	if !desiredDeviceAvailable {
-		// The audio device the user has configured is not available.
-		// Use the default (autoselect) device but leave the setting unchanged,
-		// in case the device is later available on a subsequent run of the app
-		// (e.g. a USB audio device that is currently unplugged)
-		desiredDevice = "auto"
-	}
-	a.LocalPlayer.SetAudioDevice(desiredDevice)
+	a.AudioDeviceManager = adm
 
 	rgainOpts := []string{ReplayGainNone, ReplayGainAlbum, ReplayGainTrack, ReplayGainAuto}
 	if !slices.Contains(rgainOpts, a.Config.ReplayGain.Mode) {
@@ -449,37 +487,36 @@ func (a *App) setupMPV() error {
 	})
 	a.LocalPlayer.SetAudioExclusive(a.Config.LocalPlayback.AudioExclusive)
 	a.LocalPlayer.SetPauseFade(a.Config.LocalPlayback.PauseFade)
+	a.LocalPlayer.SetBitPerfect(a.Config.LocalPlayback.BitPerfect)
 
-	// Initialize the appropriate equalizer type based on config
-	var eq mpv.Equalizer
-	if a.Config.LocalPlayback.EqualizerType == "ISO10Band" {
-		eq10 := &mpv.ISO10BandEqualizer{
-			EQPreamp: a.Config.LocalPlayback.EqualizerPreamp,
-			Disabled: !a.Config.LocalPlayback.EqualizerEnabled,
-		}
-		// Copy up to 10 bands
-		numBands := min(len(a.Config.LocalPlayback.GraphicEqualizerBands), 10)
-		for i := 0; i < numBands; i++ {
-			eq10.BandGains[i] = a.Config.LocalPlayback.GraphicEqualizerBands[i]
-		}
-		eq = eq10
-	} else {
-		eq15 := &mpv.ISO15BandEqualizer{
-			EQPreamp: a.Config.LocalPlayback.EqualizerPreamp,
-			Disabled: !a.Config.LocalPlayback.EqualizerEnabled,
-		}
-		// Copy up to 15 bands
-		numBands := min(len(a.Config.LocalPlayback.GraphicEqualizerBands), 15)
-		for i := 0; i < numBands; i++ {
-			eq15.BandGains[i] = a.Config.LocalPlayback.GraphicEqualizerBands[i]
-		}
-		eq = eq15
-	}
-	a.LocalPlayer.SetEqualizer(eq)
+	a.LocalPlayer.SetEqualizer(NewEqualizerFromConfig(&a.Config.LocalPlayback))
 
 	return nil
 }
 
+// SetBitPerfect enables or disables bit-perfect playback: streaming the
+// original files, and sending them unaltered to the local audio device.
+func (a *App) SetBitPerfect(bitPerfect bool) error {
+	a.Config.LocalPlayback.BitPerfect = bitPerfect
+	a.PlaybackManager.SetBitPerfect(bitPerfect)
+	return a.LocalPlayer.SetBitPerfect(bitPerfect)
+}
+
+// SetDLNARendererEnabled starts or stops advertising Supersonic
+// as a DLNA renderer that other apps on the network can cast to.
+func (a *App) SetDLNARendererEnabled(enabled bool) error {
+	if !enabled {
+		if a.DLNARenderer != nil {
+			a.DLNARenderer.Shutdown()
+		}
+		return nil
+	}
+	if a.DLNARenderer == nil {
+		a.DLNARenderer = NewDLNARenderer(a.PlaybackManager)
+	}
+	return a.DLNARenderer.Start(a.Config.LocalPlayback.DLNARendererName)
+}
+
 func (a *App) setupMPRIS(mprisAppName string) {
 	a.MPRISHandler = NewMPRISHandler(mprisAppName, a.PlaybackManager)
 	a.MPRISHandler.ArtURLLookup = func(id string) (string, error) {
@@ -494,7 +531,7 @@ func (a *App) setupMPRIS(mprisAppName string) {
 func (a *App) SetupWindowsSMTC(hwnd uintptr) {
 	smtc, err := windows.InitSMTCForWindow(hwnd)
 	if err != nil {
-		log.Printf("error initializing SMTC: %d", err)
+		appLog.Error("error initializing SMTC", "err", err)
 		return
 	}
 	a.WinSMTC = smtc
@@ -584,19 +621,28 @@ func (a *App) LoginToDefaultServer() error {
 	if serverCfg == nil {
 		return ErrNoServers
 	}
-	pass, err := keyring.Get(a.appName, serverCfg.ID.String())
-	if err != nil {
-		return fmt.Errorf("error reading keyring credentials: %v", err)
+	pass, err := a.ServerManager.GetServerPassword(serverCfg.ID)
+	if err != nil && !a.ServerManager.HasServerToken(serverCfg.ID) {
+		return fmt.Errorf("error reading saved credentials: %v", err)
 	}
 	return a.ServerManager.ConnectToServer(serverCfg, pass)
 }
 
 func (a *App) DeleteServerCacheDir(serverID uuid.UUID) error {
 	path := path.Join(a.cacheDir, serverID.String())
-	log.Printf("Deleting server cache dir: %s", path)
+	cacheLog.Info("deleting server cache dir", "path", path)
 	return os.RemoveAll(path)
 }
 
+// LogFilePath returns the path of the current log file,
+// or "" if it couldn't be opened.
+func (a *App) LogFilePath() string {
+	if a.logFile == nil {
+		return ""
+	}
+	return a.logFile.Path()
+}
+
 // BackgroundContext returns the application's background context
 // which is canceled when the application shuts down.
 func (a *App) BackgroundContext() context.Context {
@@ -604,9 +650,6 @@ func (a *App) BackgroundContext() context.Context {
 }
 
 func (a *App) Shutdown() {
-	if a.logFile != nil {
-		a.logFile.Close()
-	}
 	repeatMode := "None"
 	switch a.PlaybackManager.GetLoopMode() {
 	case LoopOne:
@@ -617,6 +660,7 @@ func (a *App) Shutdown() {
 	a.Config.Playback.RepeatMode = repeatMode
 	a.Config.Playback.Autoplay = a.PlaybackManager.IsAutoplay()
 	a.Config.LocalPlayback.Volume = a.LocalPlayer.GetVolume()
+	a.AudioDeviceManager.SaveProfile()
 	a.SavePlayQueueIfEnabled()
 	a.SaveConfigFile()
 
@@ -626,6 +670,9 @@ func (a *App) Shutdown() {
 	if a.MPRISHandler != nil {
 		a.MPRISHandler.Shutdown()
 	}
+	if a.DLNARenderer != nil {
+		a.DLNARenderer.Shutdown()
+	}
 	if a.WinSMTC != nil {
 		a.WinSMTC.Shutdown()
 	}
@@ -636,6 +683,9 @@ func (a *App) Shutdown() {
 	}
 	a.cancel()
 	a.LocalPlayer.Destroy()
+	if a.logFile != nil {
+		a.logFile.Close()
+	}
 }
 
 func (a *App) SavePlayQueueIfEnabled() {
@@ -648,7 +698,7 @@ func (a *App) SavePlayQueueIfEnabled() {
 			queueServer = qs
 		}
 	}
-	SavePlayQueue(a.ServerManager.ServerID.String(), a.PlaybackManager.GetActivePlayQueue(), a.PlaybackManager, path.Join(a.configDir, savedQueueFile), queueServer)
+	SavePlayQueue(a.ServerManager.ServerID.String(), a.PlaybackManager.GetActivePlayQueueWithUpNext(), a.PlaybackManager, path.Join(a.configDir, savedQueueFile), queueServer)
 	if a.Config.Playback.Shuffle {
 		// if shuffle
 		// save the unshuffled queue to enable unshuffling on restarting supersonic
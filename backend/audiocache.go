@@ -3,11 +3,11 @@ package backend
 import (
 	"context"
 	"errors"
-	"log"
 	"os"
 	"path/filepath"
 	"slices"
 	"sync"
+	"time"
 
 	"github.com/20after4/configdir"
 	"github.com/dweymouth/supersonic/sharedutil"
@@ -23,6 +23,8 @@ type AudioCache struct {
 	baseCacheDir string
 
 	entries map[string]*cacheEntry
+
+	onDownloadCompleted []func(url string, elapsed time.Duration)
 }
 
 type cacheEntry struct {
@@ -123,20 +125,34 @@ func (a *AudioCache) CacheFile(id, dlURL string) {
 	a.cacheFile(id, dlURL)
 }
 
+// OnDownloadCompleted registers a callback invoked with the URL
+// and the time taken when a file finishes downloading.
+func (a *AudioCache) OnDownloadCompleted(cb func(url string, elapsed time.Duration)) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.onDownloadCompleted = append(a.onDownloadCompleted, cb)
+}
+
 func (a *AudioCache) cacheFile(id, dlURL string) {
 	if _, ok := a.entries[id]; !ok {
 		ctx, cancel := context.WithCancel(a.rootCtx)
 		a.entries[id] = &cacheEntry{cancel: cancel}
 		go func() {
-			ok, err := sharedutil.DownloadFileWithContext(ctx, dlURL, a.pathForID(id))
+			start := time.Now()
+			ok, err := sharedutil.DownloadFileWithContext(ctx, a.s.HTTPClientForURL(dlURL), dlURL, a.pathForID(id))
 			if ok {
+				elapsed := time.Since(start)
 				a.mutex.Lock()
 				if e, ok := a.entries[id]; ok {
 					e.done = true
 				}
+				callbacks := slices.Clone(a.onDownloadCompleted)
 				a.mutex.Unlock()
+				for _, cb := range callbacks {
+					cb(dlURL, elapsed)
+				}
 			} else if err != nil && err != context.DeadlineExceeded {
-				log.Printf("error downloading audio file: %v", err)
+				cacheLog.Error("error downloading audio file", "err", err)
 			}
 			cancel() // release ctx resources when done
 		}()
@@ -0,0 +1,276 @@
+package backend
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dweymouth/supersonic/backend/player/mpv"
+)
+
+const audioDevicePollInterval = 3 * time.Second
+
+// AudioDeviceManager selects the audio output device of the local player,
+// following devices being plugged in and removed, and remembers the
+// exclusive mode and equalizer settings used with each device as a profile
+// that is restored when switching back to it.
+type AudioDeviceManager struct {
+	player *mpv.Player
+	cfg    *LocalPlaybackConfig
+
+	lock         sync.Mutex
+	devices      []mpv.AudioDevice
+	activeDevice string
+
+	onDeviceChange []func(dev mpv.AudioDevice)
+}
+
+// NewAudioDeviceManager sets the local player to use the configured audio
+// device, or the default device if it is not available, and applies the
+// profile saved for the device, if any.
+func NewAudioDeviceManager(p *mpv.Player, cfg *LocalPlaybackConfig) (*AudioDeviceManager, error) {
+	devs, err := p.ListAudioDevices()
+	if err != nil {
+		return nil, err
+	}
+	m := &AudioDeviceManager{player: p, cfg: cfg, devices: devs}
+
+	desiredDevice := cfg.AudioDeviceName
+	if !m.isAvailable(desiredDevice) {
+		// The audio device the user has configured is not available.
+		// Use the default (autoselect) device but leave the setting unchanged,
+		// so that it is switched to if the device is plugged in later
+		// (e.g. a USB audio device that is currently unplugged)
+		desiredDevice = "auto"
+	}
+	m.activeDevice = desiredDevice
+	p.SetAudioDevice(desiredDevice)
+	m.applyProfile(desiredDevice)
+	return m, nil
+}
+
+// Start watches for audio devices being plugged in or removed
+// until the context is canceled.
+func (m *AudioDeviceManager) Start(ctx context.Context) {
+	go func() {
+		t := time.NewTicker(audioDevicePollInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				m.checkDevices()
+			}
+		}
+	}()
+}
+
+// OnDeviceChange registers a callback invoked when the output device
+// is switched due to a device being plugged in or removed.
+func (m *AudioDeviceManager) OnDeviceChange(cb func(dev mpv.AudioDevice)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.onDeviceChange = append(m.onDeviceChange, cb)
+}
+
+// ActiveDevice returns the name of the audio device in use.
+func (m *AudioDeviceManager) ActiveDevice() string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.activeDevice
+}
+
+// SetDevice switches to the given audio device as selected by the user,
+// saving the current settings to the profile of the previous device and
+// applying the profile of the new one, if any.
+func (m *AudioDeviceManager) SetDevice(name string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.cfg.AudioDeviceName = name
+	return m.switchDevice(name)
+}
+
+// SaveProfile saves the current settings to the profile of the active device.
+func (m *AudioDeviceManager) SaveProfile() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.saveProfile(m.activeDevice)
+}
+
+func (m *AudioDeviceManager) checkDevices() {
+	devs, err := m.player.ListAudioDevices()
+	if err != nil {
+		playbackLog.Error("error listing audio devices", "err", err)
+		return
+	}
+
+	m.lock.Lock()
+	switchTo := m.deviceToSwitchTo(m.devices, devs)
+	pluggedIn := !containsDevice(m.devices, switchTo)
+	m.devices = devs
+	if switchTo == "" || switchTo == m.activeDevice {
+		m.lock.Unlock()
+		return
+	}
+	if pluggedIn {
+		// a device that was plugged in becomes the configured device,
+		// whereas falling back from a removed device leaves the setting
+		m.cfg.AudioDeviceName = switchTo
+	}
+	if err := m.switchDevice(switchTo); err != nil {
+		m.lock.Unlock()
+		playbackLog.Error("error switching to audio device", "device", switchTo, "err", err)
+		return
+	}
+	dev := m.device(switchTo)
+	callbacks := slices.Clone(m.onDeviceChange)
+	m.lock.Unlock()
+
+	for _, cb := range callbacks {
+		cb(dev)
+	}
+}
+
+// deviceToSwitchTo returns the device to switch to after the device list
+// changed from old to new, or "" to stay on the active device.
+// A device that is plugged in is switched to if it's the configured device,
+// or has a saved profile. If the active device is removed, the configured
+// device is switched to if available, or else the default device.
+// Must be called with lock held.
+func (m *AudioDeviceManager) deviceToSwitchTo(old, new []mpv.AudioDevice) string {
+	var switchTo string
+	for _, dev := range new {
+		if containsDevice(old, dev.Name) {
+			continue
+		}
+		if dev.Name == m.cfg.AudioDeviceName {
+			return dev.Name
+		}
+		if m.profile(dev.Name) != nil {
+			switchTo = dev.Name
+		}
+	}
+	if switchTo != "" {
+		return switchTo
+	}
+	if m.activeDevice != "auto" && !containsDevice(new, m.activeDevice) {
+		if m.cfg.AudioDeviceName != m.activeDevice && containsDevice(new, m.cfg.AudioDeviceName) {
+			return m.cfg.AudioDeviceName
+		}
+		return "auto"
+	}
+	return ""
+}
+
+// must be called with lock held
+func (m *AudioDeviceManager) switchDevice(name string) error {
+	if err := m.player.SetAudioDevice(name); err != nil {
+		return err
+	}
+	if name != m.activeDevice {
+		m.saveProfile(m.activeDevice)
+		m.activeDevice = name
+		m.applyProfile(name)
+	}
+	return nil
+}
+
+// must be called with lock held
+func (m *AudioDeviceManager) saveProfile(deviceName string) {
+	profile := AudioDeviceProfile{
+		DeviceName:            deviceName,
+		AudioExclusive:        m.cfg.AudioExclusive,
+		EqualizerEnabled:      m.cfg.EqualizerEnabled,
+		EqualizerType:         m.cfg.EqualizerType,
+		EqualizerPreamp:       m.cfg.EqualizerPreamp,
+		GraphicEqualizerBands: slices.Clone(m.cfg.GraphicEqualizerBands),
+		ActiveEQPresetName:    m.cfg.ActiveEQPresetName,
+		AutoEQProfilePath:     m.cfg.AutoEQProfilePath,
+		AutoEQProfileName:     m.cfg.AutoEQProfileName,
+	}
+	// replace rather than update the slice in place, since the
+	// last written config is a shallow copy that it is compared with
+	profiles := slices.DeleteFunc(slices.Clone(m.cfg.AudioDeviceProfiles), func(p AudioDeviceProfile) bool {
+		return p.DeviceName == deviceName
+	})
+	m.cfg.AudioDeviceProfiles = append(profiles, profile)
+}
+
+// applyProfile applies the saved profile of the device, if any, to the
+// config and the player. Devices without a profile keep the current settings.
+// Must be called with lock held.
+func (m *AudioDeviceManager) applyProfile(deviceName string) {
+	p := m.profile(deviceName)
+	if p == nil {
+		return
+	}
+	m.cfg.AudioExclusive = p.AudioExclusive
+	m.cfg.EqualizerEnabled = p.EqualizerEnabled
+	m.cfg.EqualizerType = p.EqualizerType
+	m.cfg.EqualizerPreamp = p.EqualizerPreamp
+	m.cfg.GraphicEqualizerBands = slices.Clone(p.GraphicEqualizerBands)
+	m.cfg.ActiveEQPresetName = p.ActiveEQPresetName
+	m.cfg.AutoEQProfilePath = p.AutoEQProfilePath
+	m.cfg.AutoEQProfileName = p.AutoEQProfileName
+
+	m.player.SetAudioExclusive(m.cfg.AudioExclusive)
+	m.player.SetEqualizer(NewEqualizerFromConfig(m.cfg))
+}
+
+// must be called with lock held
+func (m *AudioDeviceManager) profile(deviceName string) *AudioDeviceProfile {
+	for i := range m.cfg.AudioDeviceProfiles {
+		if m.cfg.AudioDeviceProfiles[i].DeviceName == deviceName {
+			return &m.cfg.AudioDeviceProfiles[i]
+		}
+	}
+	return nil
+}
+
+// must be called with lock held
+func (m *AudioDeviceManager) device(name string) mpv.AudioDevice {
+	for _, dev := range m.devices {
+		if dev.Name == name {
+			return dev
+		}
+	}
+	return mpv.AudioDevice{Name: name, Description: name}
+}
+
+// must be called with lock held
+func (m *AudioDeviceManager) isAvailable(name string) bool {
+	return containsDevice(m.devices, name)
+}
+
+func containsDevice(devs []mpv.AudioDevice, name string) bool {
+	return slices.ContainsFunc(devs, func(d mpv.AudioDevice) bool { return d.Name == name })
+}
+
+// NewEqualizerFromConfig creates the equalizer of the type and with
+// the settings from the local playback config.
+func NewEqualizerFromConfig(cfg *LocalPlaybackConfig) mpv.Equalizer {
+	if cfg.EqualizerType == "ISO10Band" {
+		eq10 := &mpv.ISO10BandEqualizer{
+			EQPreamp: cfg.EqualizerPreamp,
+			Disabled: !cfg.EqualizerEnabled,
+		}
+		// Copy up to 10 bands
+		numBands := min(len(cfg.GraphicEqualizerBands), 10)
+		for i := 0; i < numBands; i++ {
+			eq10.BandGains[i] = cfg.GraphicEqualizerBands[i]
+		}
+		return eq10
+	}
+	eq15 := &mpv.ISO15BandEqualizer{
+		EQPreamp: cfg.EqualizerPreamp,
+		Disabled: !cfg.EqualizerEnabled,
+	}
+	// Copy up to 15 bands
+	numBands := min(len(cfg.GraphicEqualizerBands), 15)
+	for i := 0; i < numBands; i++ {
+		eq15.BandGains[i] = cfg.GraphicEqualizerBands[i]
+	}
+	return eq15
+}
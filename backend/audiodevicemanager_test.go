@@ -0,0 +1,74 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/dweymouth/supersonic/backend/player/mpv"
+)
+
+func TestDeviceToSwitchTo(t *testing.T) {
+	devs := func(names ...string) []mpv.AudioDevice {
+		var d []mpv.AudioDevice
+		for _, n := range names {
+			d = append(d, mpv.AudioDevice{Name: n, Description: n})
+		}
+		return d
+	}
+	cfg := &LocalPlaybackConfig{
+		AudioDeviceName:     "dac",
+		AudioDeviceProfiles: []AudioDeviceProfile{{DeviceName: "headphones"}},
+	}
+
+	for _, tt := range []struct {
+		name   string
+		active string
+		old    []mpv.AudioDevice
+		new    []mpv.AudioDevice
+		want   string
+	}{
+		{name: "configured device plugged in", active: "auto",
+			old: devs("auto"), new: devs("auto", "dac"), want: "dac"},
+		{name: "device with profile plugged in", active: "dac",
+			old: devs("auto", "dac"), new: devs("auto", "dac", "headphones"), want: "headphones"},
+		{name: "device without profile plugged in", active: "dac",
+			old: devs("auto", "dac"), new: devs("auto", "dac", "speakers"), want: ""},
+		{name: "active device removed", active: "dac",
+			old: devs("auto", "dac"), new: devs("auto"), want: "auto"},
+		{name: "back to configured device", active: "headphones",
+			old: devs("auto", "dac", "headphones"), new: devs("auto", "dac"), want: "dac"},
+		{name: "other device removed", active: "dac",
+			old: devs("auto", "dac", "speakers"), new: devs("auto", "dac"), want: ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &AudioDeviceManager{cfg: cfg, activeDevice: tt.active}
+			if got := m.deviceToSwitchTo(tt.old, tt.new); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSaveAndApplyProfileConfig(t *testing.T) {
+	cfg := &LocalPlaybackConfig{
+		AudioExclusive:        true,
+		EqualizerType:         "ISO10Band",
+		GraphicEqualizerBands: make([]float64, 10),
+	}
+	m := &AudioDeviceManager{cfg: cfg}
+	m.saveProfile("dac")
+	cfg.GraphicEqualizerBands[0] = 3
+	m.saveProfile("dac")
+
+	if len(cfg.AudioDeviceProfiles) != 1 {
+		t.Fatalf("expected one profile, got %d", len(cfg.AudioDeviceProfiles))
+	}
+	p := cfg.AudioDeviceProfiles[0]
+	if !p.AudioExclusive || p.GraphicEqualizerBands[0] != 3 {
+		t.Errorf("unexpected profile %+v", p)
+	}
+	// the profile must not share the config's bands
+	cfg.GraphicEqualizerBands[0] = 6
+	if p.GraphicEqualizerBands[0] != 3 {
+		t.Error("profile bands changed with config")
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"os"
@@ -78,7 +77,7 @@ type memoryCacheEntry struct {
 // NewAutoEQManager creates a new AutoEQ manager
 func NewAutoEQManager(cachePath string, timeout time.Duration) *AutoEQManager {
 	configdir.MakePath(cachePath)
-	log.Printf("Initializing AutoEQ manager: cache=%s, timeout=%v", cachePath, timeout)
+	eqLog.Debug("initializing AutoEQ manager", "cache", cachePath, "timeout", timeout)
 	return &AutoEQManager{
 		cachePath:   cachePath,
 		timeout:     timeout,
@@ -114,7 +113,7 @@ func (m *AutoEQManager) FetchIndex(ctx context.Context) ([]AutoEQProfileMetadata
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Successfully fetched %d AutoEQ profiles", len(profiles))
+	eqLog.Info("fetched AutoEQ profiles", "count", len(profiles))
 
 	// Cache in memory and disk
 	m.indexCacheMutex.Lock()
@@ -154,17 +153,17 @@ func (m *AutoEQManager) loadIndexFromDisk(cachePath string) ([]AutoEQProfileMeta
 func (m *AutoEQManager) saveIndexToDisk(cachePath string, profiles []AutoEQProfileMetadata) {
 	data, err := json.Marshal(profiles)
 	if err != nil {
-		log.Printf("Failed to marshal AutoEQ index: %v", err)
+		eqLog.Error("failed to marshal AutoEQ index", "err", err)
 		return
 	}
 
 	if err := os.WriteFile(cachePath, data, 0644); err != nil {
-		log.Printf("Failed to write AutoEQ index cache: %v", err)
+		eqLog.Error("failed to write AutoEQ index cache", "err", err)
 	}
 }
 
 func (m *AutoEQManager) fetchIndexFromNetwork(ctx context.Context) ([]AutoEQProfileMetadata, error) {
-	log.Printf("Fetching AutoEQ index from: %s (timeout: %v)", autoEQIndexURL, m.timeout)
+	eqLog.Debug("fetching AutoEQ index", "url", autoEQIndexURL, "timeout", m.timeout)
 
 	ctx, cancel := context.WithTimeout(ctx, m.timeout)
 	defer cancel()
@@ -176,7 +175,7 @@ func (m *AutoEQManager) fetchIndexFromNetwork(ctx context.Context) ([]AutoEQProf
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		log.Printf("HTTP request failed: %v", err)
+		eqLog.Error("AutoEQ index request failed", "err", err)
 		return nil, fmt.Errorf("fetching index: %w", err)
 	}
 	defer resp.Body.Close()
@@ -186,11 +185,11 @@ func (m *AutoEQManager) fetchIndexFromNetwork(ctx context.Context) ([]AutoEQProf
 
 	profiles, err := m.parseIndex(resp.Body)
 	if err != nil {
-		log.Printf("Failed to parse AutoEQ index: %v", err)
+		eqLog.Error("failed to parse AutoEQ index", "err", err)
 		return nil, err
 	}
 
-	log.Printf("Successfully parsed %d profiles from AutoEQ index", len(profiles))
+	eqLog.Debug("parsed AutoEQ index", "profiles", len(profiles))
 	return profiles, nil
 }
 
@@ -249,7 +248,7 @@ func (m *AutoEQManager) parseIndex(r io.Reader) ([]AutoEQProfileMetadata, error)
 		return nil, fmt.Errorf("reading index: %w", err)
 	}
 
-	log.Printf("Parsed %d lines, found %d profile matches", lineCount, matchCount)
+	eqLog.Debug("parsed AutoEQ index lines", "lines", lineCount, "matches", matchCount)
 	return profiles, nil
 }
 
@@ -321,12 +320,12 @@ func (m *AutoEQManager) saveProfileToDisk(path string, profile *AutoEQProfile) {
 
 	data, err := json.Marshal(profile)
 	if err != nil {
-		log.Printf("Failed to marshal AutoEQ profile: %v", err)
+		eqLog.Error("failed to marshal AutoEQ profile", "err", err)
 		return
 	}
 
 	if err := os.WriteFile(cachePath, data, 0644); err != nil {
-		log.Printf("Failed to write AutoEQ profile cache: %v", err)
+		eqLog.Error("failed to write AutoEQ profile cache", "err", err)
 	}
 }
 
@@ -339,7 +338,7 @@ func (m *AutoEQManager) fetchProfileFromNetwork(ctx context.Context, path string
 	// URL-decode the path first (INDEX.md contains HTML-encoded paths like %20 for spaces)
 	decodedPath, err := url.QueryUnescape(path)
 	if err != nil {
-		log.Printf("Failed to decode path %s: %v", path, err)
+		eqLog.Error("failed to decode AutoEQ path", "path", path, "err", err)
 		decodedPath = path // fallback to original
 	}
 
@@ -434,8 +433,8 @@ func (m *AutoEQManager) parseProfile(path string, r io.Reader) (*AutoEQProfile,
 
 		// Verify frequency matches expected (with tolerance for rounding)
 		if freq != expectedFreqs[i] {
-			log.Printf("Warning: AutoEQ filter %d has unexpected frequency %d Hz (expected %d Hz)",
-				i+1, freq, expectedFreqs[i])
+			eqLog.Warn("AutoEQ filter has unexpected frequency",
+				"filter", i+1, "freq", freq, "expected", expectedFreqs[i])
 		}
 
 		gain, err := strconv.ParseFloat(match[2], 64)
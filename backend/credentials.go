@@ -0,0 +1,208 @@
+package backend
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/zalando/go-keyring"
+)
+
+// CredentialStore saves the secrets used to log in to servers.
+type CredentialStore interface {
+	Get(key string) (string, error)
+	Set(key, secret string) error
+	Delete(key string) error
+}
+
+// CredentialKind is the kind of a credential saved for a server.
+type CredentialKind string
+
+const (
+	CredentialPassword     CredentialKind = "Password"
+	CredentialAPIKey       CredentialKind = "API key"
+	CredentialAccessToken  CredentialKind = "Access token"
+	CredentialCertPassword CredentialKind = "Certificate password"
+)
+
+var (
+	ErrCredentialsLocked = errors.New("credential file is locked")
+	ErrWrongPassphrase   = errors.New("wrong passphrase")
+
+	errCredentialNotFound = errors.New("credential not found")
+)
+
+// keyringStore saves credentials in the OS keyring.
+type keyringStore struct {
+	service string
+}
+
+func (k keyringStore) Get(key string) (string, error) {
+	return keyring.Get(k.service, key)
+}
+
+func (k keyringStore) Set(key, secret string) error {
+	return keyring.Set(k.service, key, secret)
+}
+
+func (k keyringStore) Delete(key string) error {
+	return keyring.Delete(k.service, key)
+}
+
+const (
+	credentialFileVersion = 1
+	credentialKeyIters    = 600_000
+)
+
+// CredentialFile saves credentials in a local file encrypted with a key
+// derived from a master passphrase, for systems without an OS keyring.
+// It must be unlocked with the passphrase before use.
+type CredentialFile struct {
+	path string
+
+	mu      sync.Mutex
+	salt    []byte
+	aead    cipher.AEAD // nil while locked
+	secrets map[string]string
+}
+
+type credentialFileData struct {
+	Version int
+	Salt    []byte
+	Nonce   []byte
+	Data    []byte
+}
+
+func NewCredentialFile(path string) *CredentialFile {
+	return &CredentialFile{path: path}
+}
+
+// Exists returns whether the credential file has been created.
+func (c *CredentialFile) Exists() bool {
+	_, err := os.Stat(c.path)
+	return err == nil
+}
+
+func (c *CredentialFile) Locked() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.aead == nil
+}
+
+// Unlock decrypts the credential file with the passphrase,
+// or creates a new file encrypted with it if none exists.
+func (c *CredentialFile) Unlock(passphrase string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		c.salt = make([]byte, 16)
+		rand.Read(c.salt)
+		if c.aead, err = newCredentialAEAD(passphrase, c.salt); err != nil {
+			return err
+		}
+		c.secrets = make(map[string]string)
+		return c.save()
+	} else if err != nil {
+		return err
+	}
+
+	var data credentialFileData
+	if err := json.Unmarshal(b, &data); err != nil {
+		return err
+	}
+	aead, err := newCredentialAEAD(passphrase, data.Salt)
+	if err != nil {
+		return err
+	}
+	plain, err := aead.Open(nil, data.Nonce, data.Data, nil)
+	if err != nil {
+		return ErrWrongPassphrase
+	}
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return err
+	}
+	c.salt, c.aead, c.secrets = data.Salt, aead, secrets
+	return nil
+}
+
+func (c *CredentialFile) Get(key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.aead == nil {
+		return "", ErrCredentialsLocked
+	}
+	secret, ok := c.secrets[key]
+	if !ok {
+		return "", errCredentialNotFound
+	}
+	return secret, nil
+}
+
+func (c *CredentialFile) Set(key, secret string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.aead == nil {
+		return ErrCredentialsLocked
+	}
+	c.secrets[key] = secret
+	return c.save()
+}
+
+func (c *CredentialFile) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.aead == nil {
+		return ErrCredentialsLocked
+	}
+	if _, ok := c.secrets[key]; !ok {
+		return nil
+	}
+	delete(c.secrets, key)
+	return c.save()
+}
+
+// save encrypts and writes the secrets. Must be called with c.mu held.
+func (c *CredentialFile) save() error {
+	plain, err := json.Marshal(c.secrets)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	rand.Read(nonce)
+	b, err := json.Marshal(credentialFileData{
+		Version: credentialFileVersion,
+		Salt:    c.salt,
+		Nonce:   nonce,
+		Data:    c.aead.Seal(nil, nonce, plain, nil),
+	})
+	if err != nil {
+		return err
+	}
+	// write to a temp file first so a failed write can't lose the credentials
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+func newCredentialAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, credentialKeyIters, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
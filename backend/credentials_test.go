@@ -0,0 +1,47 @@
+package backend
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestCredentialFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), credentialFile)
+	f := NewCredentialFile(path)
+	if _, err := f.Get("server"); !errors.Is(err, ErrCredentialsLocked) {
+		t.Fatalf("expected locked error, got %v", err)
+	}
+	if err := f.Unlock("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if !f.Exists() {
+		t.Fatal("credential file not created")
+	}
+	if err := f.Set("server", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set("server.token", "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Delete("server.token"); err != nil {
+		t.Fatal(err)
+	}
+
+	f = NewCredentialFile(path)
+	if err := f.Unlock("wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("expected wrong passphrase error, got %v", err)
+	}
+	if !f.Locked() {
+		t.Fatal("unlocked with wrong passphrase")
+	}
+	if err := f.Unlock("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if secret, err := f.Get("server"); err != nil || secret != "hunter2" {
+		t.Errorf("Get = %q, %v", secret, err)
+	}
+	if _, err := f.Get("server.token"); err == nil {
+		t.Error("deleted credential still saved")
+	}
+}
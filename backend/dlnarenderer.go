@@ -0,0 +1,172 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"sync"
+
+	"github.com/dweymouth/supersonic/backend/dlnarenderer"
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+	"github.com/dweymouth/supersonic/backend/player"
+)
+
+const dlnaRendererItemIDPrefix = "dlna-renderer-"
+
+// DLNARenderer lets other apps on the local network cast to Supersonic,
+// by acting as a UPnP MediaRenderer. Pushed streams are played on the
+// local player as transient items in the play queue.
+type DLNARenderer struct {
+	pm *PlaybackManager
+
+	lock     sync.Mutex
+	renderer *dlnarenderer.Renderer
+	itemID   string // ID of the queue item of the last pushed stream
+	pushed   int
+
+	notify chan struct{}
+}
+
+func NewDLNARenderer(pm *PlaybackManager) *DLNARenderer {
+	d := &DLNARenderer{pm: pm, notify: make(chan struct{}, 1)}
+
+	// Status changes are forwarded to the renderer from a separate goroutine,
+	// since the renderer calls back into the PlaybackManager while notified.
+	go d.notifyLoop()
+	emitStatusChange := func() {
+		select {
+		case d.notify <- struct{}{}:
+		default:
+		}
+	}
+	pm.OnSongChange(func(mediaprovider.MediaItem, *mediaprovider.Track) { emitStatusChange() })
+	pm.OnPlayTimeUpdate(func(float64, float64, bool) { emitStatusChange() })
+	pm.OnVolumeChange(func(int) { emitStatusChange() })
+	pm.OnSeek(emitStatusChange)
+	pm.OnPlaying(emitStatusChange)
+	pm.OnPaused(emitStatusChange)
+	pm.OnStopped(emitStatusChange)
+	return d
+}
+
+// Start advertises the renderer with the given name on the network.
+// If name is empty, a default name including the hostname is used.
+func (d *DLNARenderer) Start(name string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.renderer != nil {
+		return nil
+	}
+	r := dlnarenderer.New(name, d)
+	if err := r.Start(); err != nil {
+		return err
+	}
+	dlnaLog.Info("started DLNA renderer", "name", r.Name())
+	d.renderer = r
+	return nil
+}
+
+// Shutdown stops advertising the renderer.
+func (d *DLNARenderer) Shutdown() {
+	d.lock.Lock()
+	r := d.renderer
+	d.renderer = nil
+	d.lock.Unlock()
+	if r != nil {
+		r.Shutdown()
+	}
+}
+
+func (d *DLNARenderer) notifyLoop() {
+	for range d.notify {
+		d.lock.Lock()
+		r := d.renderer
+		d.lock.Unlock()
+		if r != nil {
+			r.NotifyStatusChange()
+		}
+	}
+}
+
+// Play implements dlnarenderer.Player.
+func (d *DLNARenderer) Play(media dlnarenderer.Media) error {
+	station := &mediaprovider.RadioStation{
+		StationName: media.Album,
+		StreamURL:   media.URI,
+		Title:       media.Title,
+		Artists:     media.Artists,
+		Duration:    media.Duration,
+	}
+	if u, err := url.Parse(media.URI); err == nil {
+		if station.Title == "" {
+			station.Title = path.Base(u.Path)
+		}
+		if station.StationName == "" {
+			station.StationName = u.Hostname()
+		}
+	}
+
+	// pushed streams play locally, so refuse them rather than
+	// interrupting a cast to another device
+	if d.pm.CurrentRemotePlayer() != nil {
+		return errors.New("playing on a remote device")
+	}
+
+	d.lock.Lock()
+	d.pushed++
+	station.ID = fmt.Sprintf("%s%d", dlnaRendererItemIDPrefix, d.pushed)
+	d.itemID = station.ID
+	d.lock.Unlock()
+
+	go d.pm.PlayTransientItem(station)
+	return nil
+}
+
+// Status implements dlnarenderer.Player.
+func (d *DLNARenderer) Status() player.Status {
+	d.lock.Lock()
+	itemID := d.itemID
+	d.lock.Unlock()
+	nowPlaying := d.pm.NowPlaying()
+	if itemID == "" || nowPlaying == nil || nowPlaying.Metadata().ID != itemID {
+		return player.Status{State: player.Stopped}
+	}
+	s := d.pm.PlaybackStatus()
+	return player.Status{State: s.State, TimePos: s.TimePos, Duration: s.Duration}
+}
+
+// Continue implements dlnarenderer.Player.
+func (d *DLNARenderer) Continue() error {
+	d.pm.Continue()
+	return nil
+}
+
+// Pause implements dlnarenderer.Player.
+func (d *DLNARenderer) Pause() error {
+	d.pm.Pause()
+	return nil
+}
+
+// Stop implements dlnarenderer.Player.
+func (d *DLNARenderer) Stop() error {
+	d.pm.Stop()
+	return nil
+}
+
+// SeekSeconds implements dlnarenderer.Player.
+func (d *DLNARenderer) SeekSeconds(secs float64) error {
+	d.pm.SeekSeconds(secs)
+	return nil
+}
+
+// Volume implements dlnarenderer.Player.
+func (d *DLNARenderer) Volume() int {
+	return d.pm.Volume()
+}
+
+// SetVolume implements dlnarenderer.Player.
+func (d *DLNARenderer) SetVolume(vol int) error {
+	d.pm.SetVolume(vol)
+	return nil
+}
@@ -0,0 +1,354 @@
+package dlnarenderer
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dweymouth/supersonic/backend/player"
+)
+
+// the formats accepted from control points; mpv plays all of them
+var sinkProtocolInfo = strings.Join([]string{
+	"http-get:*:audio/mpeg:*",
+	"http-get:*:audio/mp3:*",
+	"http-get:*:audio/flac:*",
+	"http-get:*:audio/x-flac:*",
+	"http-get:*:audio/ogg:*",
+	"http-get:*:audio/opus:*",
+	"http-get:*:audio/mp4:*",
+	"http-get:*:audio/aac:*",
+	"http-get:*:audio/x-m4a:*",
+	"http-get:*:audio/wav:*",
+	"http-get:*:audio/x-wav:*",
+	"http-get:*:audio/L16:*",
+	"http-get:*:audio/x-ms-wma:*",
+	"http-get:*:audio/x-aiff:*",
+	"http-get:*:application/ogg:*",
+	"http-get:*:*:*",
+}, ",")
+
+func (r *Renderer) actionHandlers(s *service) map[string]actionHandler {
+	switch s {
+	case avTransportService:
+		return map[string]actionHandler{
+			"SetAVTransportURI":          r.setAVTransportURI,
+			"SetNextAVTransportURI":      r.setNextAVTransportURI,
+			"GetMediaInfo":               r.getMediaInfo,
+			"GetTransportInfo":           r.getTransportInfo,
+			"GetPositionInfo":            r.getPositionInfo,
+			"GetDeviceCapabilities":      r.getDeviceCapabilities,
+			"GetTransportSettings":       r.getTransportSettings,
+			"GetCurrentTransportActions": r.getCurrentTransportActions,
+			"Play":                       r.playAction,
+			"Pause":                      r.pauseAction,
+			"Stop":                       r.stopAction,
+			"Seek":                       r.seekAction,
+			"Next":                       r.nextAction,
+			"Previous":                   r.previousAction,
+		}
+	case renderingControlService:
+		return map[string]actionHandler{
+			"ListPresets":  r.listPresets,
+			"SelectPreset": r.selectPreset,
+			"GetVolume":    r.getVolume,
+			"SetVolume":    r.setVolume,
+			"GetMute":      r.getMute,
+			"SetMute":      r.setMute,
+		}
+	default:
+		return map[string]actionHandler{
+			"GetProtocolInfo":          r.getProtocolInfo,
+			"GetCurrentConnectionIDs":  r.getCurrentConnectionIDs,
+			"GetCurrentConnectionInfo": r.getCurrentConnectionInfo,
+		}
+	}
+}
+
+// ======================== AVTransport =============================
+
+func (r *Renderer) setAVTransportURI(args map[string]string) ([]string, error) {
+	uri := args["CurrentURI"]
+	if !isHTTPURI(uri) {
+		return nil, errInvalidArgs
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	wasPlaying := r.transportState(r.player.Status()) == "PLAYING"
+	r.cur = parseMedia(uri, args["CurrentURIMetaData"])
+	r.next = nil
+	r.started = false
+	if wasPlaying {
+		// a new URI set while playing starts playing right away
+		return nil, r.play()
+	}
+	return nil, nil
+}
+
+func (r *Renderer) setNextAVTransportURI(args map[string]string) ([]string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if uri := args["NextURI"]; uri != "" {
+		if !isHTTPURI(uri) {
+			return nil, errInvalidArgs
+		}
+		r.next = parseMedia(uri, args["NextURIMetaData"])
+	} else {
+		r.next = nil
+	}
+	return nil, nil
+}
+
+// Only http(s) streams are accepted, since mpv would otherwise also open
+// e.g. local files or other protocols a control point has no business using.
+func isHTTPURI(uri string) bool {
+	u, err := url.Parse(uri)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func (r *Renderer) getMediaInfo(map[string]string) ([]string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	cur, next := r.media()
+	return []string{
+		"NrTracks", numTracks(cur),
+		"MediaDuration", formatClock(r.duration(cur)),
+		"CurrentURI", cur.URI,
+		"CurrentURIMetaData", cur.MetaData,
+		"NextURI", next.URI,
+		"NextURIMetaData", next.MetaData,
+		"PlayMedium", storageMedium(cur),
+		"RecordMedium", "NOT_IMPLEMENTED",
+		"WriteStatus", "NOT_IMPLEMENTED",
+	}, nil
+}
+
+func (r *Renderer) getTransportInfo(map[string]string) ([]string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return []string{
+		"CurrentTransportState", r.transportState(r.player.Status()),
+		"CurrentTransportStatus", "OK",
+		"CurrentSpeed", "1",
+	}, nil
+}
+
+func (r *Renderer) getPositionInfo(map[string]string) ([]string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	cur, _ := r.media()
+	var pos string
+	if status := r.player.Status(); r.started && status.State != player.Stopped {
+		pos = formatClock(secondsToDuration(status.TimePos))
+	} else {
+		pos = formatClock(0)
+	}
+	return []string{
+		"Track", numTracks(cur),
+		"TrackDuration", formatClock(r.duration(cur)),
+		"TrackMetaData", cur.MetaData,
+		"TrackURI", cur.URI,
+		"RelTime", pos,
+		"AbsTime", pos,
+		"RelCount", "2147483647",
+		"AbsCount", "2147483647",
+	}, nil
+}
+
+func (r *Renderer) getDeviceCapabilities(map[string]string) ([]string, error) {
+	return []string{
+		"PlayMedia", "NETWORK",
+		"RecMedia", "NOT_IMPLEMENTED",
+		"RecQualityModes", "NOT_IMPLEMENTED",
+	}, nil
+}
+
+func (r *Renderer) getTransportSettings(map[string]string) ([]string, error) {
+	return []string{
+		"PlayMode", "NORMAL",
+		"RecQualityMode", "NOT_IMPLEMENTED",
+	}, nil
+}
+
+func (r *Renderer) getCurrentTransportActions(map[string]string) ([]string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return []string{"Actions", transportActions(r.transportState(r.player.Status()))}, nil
+}
+
+func (r *Renderer) playAction(map[string]string) ([]string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.cur == nil {
+		return nil, errTransitionNotAvail
+	}
+	if r.started {
+		switch r.player.Status().State {
+		case player.Playing:
+			return nil, nil
+		case player.Paused:
+			r.stopped = false
+			return nil, r.player.Continue()
+		}
+	}
+	return nil, r.play()
+}
+
+func (r *Renderer) pauseAction(map[string]string) ([]string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.started || r.player.Status().State != player.Playing {
+		return nil, errTransitionNotAvail
+	}
+	return nil, r.player.Pause()
+}
+
+func (r *Renderer) stopAction(map[string]string) ([]string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.stopped = true
+	if !r.started {
+		return nil, nil
+	}
+	r.started = false
+	r.transition = time.Time{}
+	if r.player.Status().State == player.Stopped {
+		// something else is playing now; leave it alone
+		return nil, nil
+	}
+	return nil, r.player.Stop()
+}
+
+func (r *Renderer) seekAction(args map[string]string) ([]string, error) {
+	unit := args["Unit"]
+	if unit != "REL_TIME" && unit != "ABS_TIME" {
+		return nil, errSeekModeUnsupported
+	}
+	target, ok := parseClock(args["Target"])
+	if !ok {
+		return nil, errIllegalSeekTarget
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.started {
+		return nil, errTransitionNotAvail
+	}
+	return nil, r.player.SeekSeconds(target.Seconds())
+}
+
+func (r *Renderer) nextAction(map[string]string) ([]string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.next == nil {
+		return nil, errIllegalSeekTarget
+	}
+	r.cur, r.next = r.next, nil
+	return nil, r.play()
+}
+
+func (r *Renderer) previousAction(map[string]string) ([]string, error) {
+	// only the current and next URIs are known
+	return nil, errIllegalSeekTarget
+}
+
+// duration returns the duration of the media from its metadata,
+// or as reported by the player if unknown; must be called with r.lock held
+func (r *Renderer) duration(m Media) time.Duration {
+	if m.Duration > 0 || !r.started {
+		return m.Duration
+	}
+	return secondsToDuration(r.player.Status().Duration)
+}
+
+// ======================== RenderingControl =============================
+
+func (r *Renderer) listPresets(map[string]string) ([]string, error) {
+	return []string{"CurrentPresetNameList", "FactoryDefaults"}, nil
+}
+
+func (r *Renderer) selectPreset(args map[string]string) ([]string, error) {
+	if args["PresetName"] != "FactoryDefaults" {
+		return nil, errInvalidArgs
+	}
+	return nil, nil
+}
+
+func (r *Renderer) getVolume(map[string]string) ([]string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return []string{"CurrentVolume", strconv.Itoa(r.volume())}, nil
+}
+
+func (r *Renderer) setVolume(args map[string]string) ([]string, error) {
+	vol, err := strconv.Atoi(args["DesiredVolume"])
+	if err != nil || vol < 0 || vol > 100 {
+		return nil, errInvalidArgs
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.muted {
+		// takes effect when unmuted
+		r.preMuteVol = vol
+		return nil, nil
+	}
+	return nil, r.player.SetVolume(vol)
+}
+
+func (r *Renderer) getMute(map[string]string) ([]string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return []string{"CurrentMute", boolString(r.muted)}, nil
+}
+
+func (r *Renderer) setMute(args map[string]string) ([]string, error) {
+	var mute bool
+	switch strings.ToLower(args["DesiredMute"]) {
+	case "1", "true", "yes":
+		mute = true
+	case "0", "false", "no":
+	default:
+		return nil, errInvalidArgs
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if mute == r.muted {
+		return nil, nil
+	}
+	if mute {
+		r.preMuteVol = r.player.Volume()
+		r.muted = true
+		return nil, r.player.SetVolume(0)
+	}
+	r.muted = false
+	return nil, r.player.SetVolume(r.preMuteVol)
+}
+
+// ======================== ConnectionManager =============================
+
+func (r *Renderer) getProtocolInfo(map[string]string) ([]string, error) {
+	return []string{"Source", "", "Sink", sinkProtocolInfo}, nil
+}
+
+func (r *Renderer) getCurrentConnectionIDs(map[string]string) ([]string, error) {
+	return []string{"ConnectionIDs", "0"}, nil
+}
+
+func (r *Renderer) getCurrentConnectionInfo(args map[string]string) ([]string, error) {
+	if args["ConnectionID"] != "0" {
+		return nil, &upnpError{706, "Invalid connection reference"}
+	}
+	return []string{
+		"RcsID", "0",
+		"AVTransportID", "0",
+		"ProtocolInfo", "",
+		"PeerConnectionManager", "",
+		"PeerConnectionID", "-1",
+		"Direction", "Input",
+		"Status", "OK",
+	}, nil
+}
+
+func secondsToDuration(secs float64) time.Duration {
+	return time.Duration(secs * float64(time.Second))
+}
@@ -0,0 +1,247 @@
+package dlnarenderer
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// longest subscription granted; control points must renew before it expires
+	maxSubscriptionTimeout = 30 * time.Minute
+	// notifications queued per subscriber before further ones are dropped
+	notifyQueueLen = 16
+)
+
+// eventPublisher keeps the UPnP (GENA) event subscriptions of the
+// renderer's services and sends event notifications to the subscribers.
+type eventPublisher struct {
+	httpClient *http.Client
+
+	lock sync.Mutex
+	subs map[string]*subscriber // by SID
+}
+
+type subscriber struct {
+	sid       string
+	service   string
+	callbacks []string
+	expires   time.Time
+	seq       uint32 // only accessed by sendLoop
+	queue     chan []byte
+}
+
+func newEventPublisher() *eventPublisher {
+	return &eventPublisher{
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		subs:       make(map[string]*subscriber),
+	}
+}
+
+// handle serves SUBSCRIBE and UNSUBSCRIBE requests for the service.
+// initialEvent returns the property set sent right after subscribing,
+// holding the current value of all evented variables.
+func (e *eventPublisher) handle(w http.ResponseWriter, r *http.Request, service string, initialEvent func() []byte) {
+	switch r.Method {
+	case "SUBSCRIBE":
+		if sid := r.Header.Get("SID"); sid != "" {
+			e.renew(w, r, sid)
+			return
+		}
+		callbacks := parseCallbacks(r.Header.Get("CALLBACK"))
+		if r.Header.Get("NT") != "upnp:event" || len(callbacks) == 0 {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		timeout := parseTimeout(r.Header.Get("TIMEOUT"))
+		sub := &subscriber{
+			sid:       "uuid:" + uuid.NewString(),
+			service:   service,
+			callbacks: callbacks,
+			expires:   time.Now().Add(timeout),
+			queue:     make(chan []byte, notifyQueueLen),
+		}
+		e.lock.Lock()
+		e.subs[sub.sid] = sub
+		e.lock.Unlock()
+		go e.sendLoop(sub)
+
+		w.Header().Set("SID", sub.sid)
+		w.Header().Set("TIMEOUT", fmt.Sprintf("Second-%d", int(timeout.Seconds())))
+		w.WriteHeader(http.StatusOK)
+		e.enqueue(sub, initialEvent())
+	case "UNSUBSCRIBE":
+		e.lock.Lock()
+		sub, ok := e.subs[r.Header.Get("SID")]
+		if ok {
+			e.remove(sub)
+		}
+		e.lock.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusPreconditionFailed)
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (e *eventPublisher) renew(w http.ResponseWriter, r *http.Request, sid string) {
+	if r.Header.Get("CALLBACK") != "" || r.Header.Get("NT") != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	timeout := parseTimeout(r.Header.Get("TIMEOUT"))
+	e.lock.Lock()
+	sub, ok := e.subs[sid]
+	if ok {
+		sub.expires = time.Now().Add(timeout)
+	}
+	e.lock.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	w.Header().Set("SID", sid)
+	w.Header().Set("TIMEOUT", fmt.Sprintf("Second-%d", int(timeout.Seconds())))
+	w.WriteHeader(http.StatusOK)
+}
+
+// publish sends the property set to all subscribers of the service.
+func (e *eventPublisher) publish(service string, propertySet []byte) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	now := time.Now()
+	for _, sub := range e.subs {
+		if sub.service != service {
+			continue
+		}
+		if now.After(sub.expires) {
+			e.remove(sub)
+			continue
+		}
+		e.enqueueLocked(sub, propertySet)
+	}
+}
+
+func (e *eventPublisher) enqueue(sub *subscriber, propertySet []byte) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if _, ok := e.subs[sub.sid]; ok {
+		e.enqueueLocked(sub, propertySet)
+	}
+}
+
+func (e *eventPublisher) enqueueLocked(sub *subscriber, propertySet []byte) {
+	select {
+	case sub.queue <- propertySet:
+	default:
+		// subscriber is not keeping up; since every event carries
+		// the full state, later events will bring it up to date
+	}
+}
+
+// must be called with e.lock held
+func (e *eventPublisher) remove(sub *subscriber) {
+	delete(e.subs, sub.sid)
+	close(sub.queue)
+}
+
+// sendLoop sends the queued notifications to the subscriber in order.
+func (e *eventPublisher) sendLoop(sub *subscriber) {
+	for body := range sub.queue {
+		seq := sub.seq
+		// SEQ wraps to 1, since 0 is reserved for the initial event
+		if sub.seq++; sub.seq == 0 {
+			sub.seq = 1
+		}
+		if err := e.notify(sub, seq, body); err != nil {
+			logger.Warn("failed to send event", "callback", sub.callbacks[0], "err", err)
+		}
+	}
+}
+
+func (e *eventPublisher) notify(sub *subscriber, seq uint32, body []byte) error {
+	var err error
+	// callbacks are tried in order until one succeeds
+	for _, callback := range sub.callbacks {
+		var req *http.Request
+		req, err = http.NewRequest("NOTIFY", callback, bytes.NewReader(body))
+		if err != nil {
+			continue
+		}
+		req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+		req.Header.Set("NT", "upnp:event")
+		req.Header.Set("NTS", "upnp:propchange")
+		req.Header.Set("SID", sub.sid)
+		req.Header.Set("SEQ", strconv.FormatUint(uint64(seq), 10))
+		var resp *http.Response
+		if resp, err = e.httpClient.Do(req); err == nil {
+			resp.Body.Close()
+			return nil
+		}
+	}
+	return err
+}
+
+// close drops all subscriptions.
+func (e *eventPublisher) close() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for _, sub := range e.subs {
+		e.remove(sub)
+	}
+}
+
+// propertySet builds an event notification body from
+// state variables given as name, value pairs.
+func propertySet(vars ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?><e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0">`)
+	for i := 0; i+1 < len(vars); i += 2 {
+		fmt.Fprintf(&b, "<e:property><%s>%s</%s></e:property>", vars[i], html.EscapeString(vars[i+1]), vars[i])
+	}
+	b.WriteString("</e:propertyset>")
+	return b.Bytes()
+}
+
+// lastChange builds the LastChange value for instance 0 from state
+// variables given as name, value pairs. Names may include attributes,
+// e.g. `Volume channel="Master"`.
+func lastChange(namespace string, vars ...string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<Event xmlns="%s"><InstanceID val="0">`, namespace)
+	for i := 0; i+1 < len(vars); i += 2 {
+		fmt.Fprintf(&b, `<%s val="%s"/>`, vars[i], html.EscapeString(vars[i+1]))
+	}
+	b.WriteString("</InstanceID></Event>")
+	return b.String()
+}
+
+// parses a CALLBACK header of the form <url1><url2>...
+func parseCallbacks(s string) []string {
+	var urls []string
+	for _, part := range strings.Split(s, ">") {
+		u := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(part), "<"))
+		if strings.HasPrefix(u, "http://") {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// parses a TIMEOUT header of the form Second-N or Second-infinite,
+// limiting the timeout to maxSubscriptionTimeout
+func parseTimeout(s string) time.Duration {
+	secs, err := strconv.Atoi(strings.TrimPrefix(s, "Second-"))
+	if err != nil || secs <= 0 || time.Duration(secs)*time.Second > maxSubscriptionTimeout {
+		return maxSubscriptionTimeout
+	}
+	return time.Duration(secs) * time.Second
+}
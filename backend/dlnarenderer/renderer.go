@@ -0,0 +1,472 @@
+// Package dlnarenderer implements a UPnP/DLNA MediaRenderer device, which
+// lets control points on the local network (phone apps, media servers)
+// push audio streams to a Player and control their playback.
+package dlnarenderer
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dweymouth/supersonic/backend/logging"
+	"github.com/dweymouth/supersonic/backend/player"
+	"github.com/dweymouth/supersonic/backend/util"
+	"github.com/google/uuid"
+)
+
+var logger = logging.For(logging.DLNA)
+
+const (
+	// how long the media given to Play is reported as TRANSITIONING
+	// if the player does not report it playing before then
+	transitionTimeout = 10 * time.Second
+	// a track stopping within this margin of its end is considered finished,
+	// so that playback advances to the next URI set by the control point
+	trackEndMargin = 5 * time.Second
+)
+
+// Player is the playback controlled by the renderer.
+type Player interface {
+	// Play starts playing the media from the beginning.
+	Play(media Media) error
+	Continue() error
+	Pause() error
+	Stop() error
+	SeekSeconds(secs float64) error
+	// Status returns the playback status of the media last given to Play,
+	// which is Stopped if something else has been played since.
+	Status() player.Status
+	// Volume returns the volume in the range [0, 100].
+	Volume() int
+	SetVolume(vol int) error
+}
+
+// Media is a stream pushed by a control point.
+type Media struct {
+	URI string
+	// DIDL-Lite metadata as given by the control point
+	MetaData string
+
+	Title    string
+	Artists  []string
+	Album    string
+	Duration time.Duration
+}
+
+// Renderer is a UPnP MediaRenderer device controlling a Player.
+// It is advertised on the local network between Start and Shutdown.
+type Renderer struct {
+	name   string
+	udn    string
+	player Player
+
+	server *http.Server
+	port   string
+	events *eventPublisher
+	ssdp   *advertiser
+
+	lock        sync.Mutex
+	cur, next   *Media
+	started     bool      // whether cur has been given to the player
+	transition  time.Time // when cur was given to the player, if not yet observed playing
+	stopped     bool      // stop requested by the control point
+	lastState   string
+	lastPos     float64
+	muted       bool
+	preMuteVol  int
+	lastAVTVars string
+	lastRCSVars string
+}
+
+// New creates a renderer with the given friendly name.
+// If name is empty, "Supersonic on <hostname>" is used.
+func New(name string, p Player) *Renderer {
+	hostname, _ := os.Hostname()
+	if name == "" {
+		name = "Supersonic on " + hostname
+	}
+	// keep the device identity stable across restarts,
+	// so control points recognize the renderer
+	udn := "uuid:" + uuid.NewMD5(uuid.NameSpaceDNS, []byte("supersonic-renderer."+hostname+"."+name)).String()
+	return &Renderer{
+		name:   name,
+		udn:    udn,
+		player: p,
+		events: newEventPublisher(),
+	}
+}
+
+// Name returns the friendly name of the renderer.
+func (r *Renderer) Name() string {
+	return r.name
+}
+
+// Start starts serving the renderer and advertising it on the network.
+func (r *Renderer) Start() error {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return err
+	}
+	_, r.port, _ = net.SplitHostPort(listener.Addr().String())
+	r.server = &http.Server{Handler: r.handler()}
+	go r.server.Serve(listener)
+
+	ads, err := newAdvertiser(r.udn, r.location)
+	if err != nil {
+		r.server.Close()
+		return fmt.Errorf("failed to advertise renderer: %w", err)
+	}
+	r.ssdp = ads
+	return nil
+}
+
+// Shutdown stops advertising and serving the renderer.
+func (r *Renderer) Shutdown() {
+	if r.ssdp != nil {
+		r.ssdp.close()
+	}
+	r.events.close()
+	if r.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		r.server.Shutdown(ctx)
+	}
+}
+
+// location returns the device description URL reachable from the given
+// address or network interface.
+func (r *Renderer) location(from net.Addr, ifi *net.Interface) string {
+	ip := localIPFor(from, ifi)
+	return "http://" + net.JoinHostPort(ip, r.port) + "/description.xml"
+}
+
+func (r *Renderer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/description.xml", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+		w.Write(r.description())
+	})
+	for _, s := range services {
+		scpd := s.scpd()
+		mux.HandleFunc("/"+s.name+"/scpd.xml", func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+			w.Write(scpd)
+		})
+		handlers := r.actionHandlers(s)
+		mux.HandleFunc("/"+s.name+"/control", func(w http.ResponseWriter, req *http.Request) {
+			r.handleControl(w, req, s, handlers)
+		})
+		mux.HandleFunc("/"+s.name+"/event", func(w http.ResponseWriter, req *http.Request) {
+			r.events.handle(w, req, s.name, func() []byte {
+				r.lock.Lock()
+				defer r.lock.Unlock()
+				return r.eventVars(s)
+			})
+		})
+	}
+	return mux
+}
+
+func (r *Renderer) handleControl(w http.ResponseWriter, req *http.Request, s *service, handlers map[string]actionHandler) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	action, args, err := parseSOAPRequest(req.Body)
+	if err != nil {
+		writeSOAPFault(w, errInvalidAction)
+		return
+	}
+	handler, ok := handlers[action]
+	if !ok {
+		writeSOAPFault(w, errInvalidAction)
+		return
+	}
+	if id, ok := args["InstanceID"]; ok && id != "0" {
+		writeSOAPFault(w, errInvalidInstanceID)
+		return
+	}
+	out, err := handler(args)
+	if err != nil {
+		uerr, ok := err.(*upnpError)
+		if !ok {
+			logger.Error("renderer action failed", "action", action, "err", err)
+			uerr = errActionFailed
+		}
+		writeSOAPFault(w, uerr)
+		return
+	}
+	writeSOAPResponse(w, s.serviceType(), action, out)
+	r.NotifyStatusChange()
+}
+
+func (r *Renderer) description() []byte {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` +
+		`<root xmlns="urn:schemas-upnp-org:device-1-0" xmlns:dlna="urn:schemas-dlna-org:device-1-0">` +
+		`<specVersion><major>1</major><minor>0</minor></specVersion><device>` +
+		`<deviceType>` + deviceType + `</deviceType>` +
+		`<dlna:X_DLNADOC>DMR-1.50</dlna:X_DLNADOC>`)
+	fmt.Fprintf(&b, "<friendlyName>%s</friendlyName>", html.EscapeString(r.name))
+	b.WriteString(`<manufacturer>Supersonic</manufacturer><manufacturerURL>https://github.com/dweymouth/supersonic</manufacturerURL>` +
+		`<modelName>Supersonic</modelName><modelDescription>Supersonic music player</modelDescription>`)
+	fmt.Fprintf(&b, "<UDN>%s</UDN><serviceList>", r.udn)
+	for _, s := range services {
+		fmt.Fprintf(&b, "<service><serviceType>%s</serviceType><serviceId>%s</serviceId>"+
+			"<SCPDURL>/%s/scpd.xml</SCPDURL><controlURL>/%s/control</controlURL><eventSubURL>/%s/event</eventSubURL></service>",
+			s.serviceType(), s.serviceID(), s.name, s.name, s.name)
+	}
+	b.WriteString("</serviceList></device></root>")
+	return []byte(b.String())
+}
+
+// NotifyStatusChange must be called whenever the playback status or volume
+// of the player changes. It advances to the next URI set by the control point
+// when the current media has finished, and sends events to subscribers.
+func (r *Renderer) NotifyStatusChange() {
+	r.lock.Lock()
+	status := r.player.Status()
+	state := r.transportState(status)
+	if state == "STOPPED" && r.lastState == "PLAYING" && !r.stopped && r.next != nil && r.finished() {
+		r.cur, r.next = r.next, nil
+		if err := r.play(); err != nil {
+			logger.Error("failed to play next URI", "err", err)
+		}
+		state = r.transportState(status)
+	}
+	if status.State != player.Stopped {
+		r.lastPos = status.TimePos
+	}
+	r.lastState = state
+	avtVars, rcsVars := r.eventVars(avTransportService), r.eventVars(renderingControlService)
+	sendAVT, sendRCS := string(avtVars) != r.lastAVTVars, string(rcsVars) != r.lastRCSVars
+	r.lastAVTVars, r.lastRCSVars = string(avtVars), string(rcsVars)
+	r.lock.Unlock()
+
+	if sendAVT {
+		r.events.publish(avTransportService.name, avtVars)
+	}
+	if sendRCS {
+		r.events.publish(renderingControlService.name, rcsVars)
+	}
+}
+
+// whether the current media played until (near) its end;
+// must be called with r.lock held
+func (r *Renderer) finished() bool {
+	dur := r.cur.Duration.Seconds()
+	return dur > 0 && r.lastPos >= dur-trackEndMargin.Seconds()
+}
+
+// play gives the current media to the player; must be called with r.lock held
+func (r *Renderer) play() error {
+	r.stopped = false
+	r.lastPos = 0
+	if err := r.player.Play(*r.cur); err != nil {
+		r.started = false
+		return err
+	}
+	r.started = true
+	r.transition = time.Now()
+	return nil
+}
+
+// transportState returns the UPnP transport state given the player status;
+// must be called with r.lock held
+func (r *Renderer) transportState(status player.Status) string {
+	if r.cur == nil {
+		return "NO_MEDIA_PRESENT"
+	}
+	if !r.started {
+		return "STOPPED"
+	}
+	switch status.State {
+	case player.Playing:
+		r.transition = time.Time{}
+		return "PLAYING"
+	case player.Paused:
+		r.transition = time.Time{}
+		return "PAUSED_PLAYBACK"
+	}
+	if !r.transition.IsZero() && time.Since(r.transition) < transitionTimeout {
+		return "TRANSITIONING"
+	}
+	return "STOPPED"
+}
+
+// eventVars returns the property set of the evented state of the service;
+// must be called with r.lock held
+func (r *Renderer) eventVars(s *service) []byte {
+	switch s {
+	case avTransportService:
+		cur, next := r.media()
+		state := r.lastState
+		if state == "" {
+			state = "NO_MEDIA_PRESENT"
+		}
+		return propertySet("LastChange", lastChange("urn:schemas-upnp-org:metadata-1-0/AVT/",
+			"TransportState", state,
+			"TransportStatus", "OK",
+			"TransportPlaySpeed", "1",
+			"CurrentPlayMode", "NORMAL",
+			"PlaybackStorageMedium", storageMedium(cur),
+			"NumberOfTracks", numTracks(cur),
+			"CurrentTrack", numTracks(cur),
+			"CurrentTrackDuration", formatClock(cur.Duration),
+			"CurrentMediaDuration", formatClock(cur.Duration),
+			"CurrentTrackURI", cur.URI,
+			"AVTransportURI", cur.URI,
+			"CurrentTrackMetaData", cur.MetaData,
+			"AVTransportURIMetaData", cur.MetaData,
+			"NextAVTransportURI", next.URI,
+			"NextAVTransportURIMetaData", next.MetaData,
+			"CurrentTransportActions", transportActions(state),
+		))
+	case renderingControlService:
+		return propertySet("LastChange", lastChange("urn:schemas-upnp-org:metadata-1-0/RCS/",
+			`Volume channel="Master"`, fmt.Sprint(r.volume()),
+			`Mute channel="Master"`, boolString(r.muted),
+		))
+	default:
+		return propertySet(
+			"SourceProtocolInfo", "",
+			"SinkProtocolInfo", sinkProtocolInfo,
+			"CurrentConnectionIDs", "0",
+		)
+	}
+}
+
+// media returns copies of the current and next media,
+// which are empty if not set
+func (r *Renderer) media() (cur, next Media) {
+	if r.cur != nil {
+		cur = *r.cur
+	}
+	if r.next != nil {
+		next = *r.next
+	}
+	return cur, next
+}
+
+func (r *Renderer) volume() int {
+	if r.muted {
+		return r.preMuteVol
+	}
+	return r.player.Volume()
+}
+
+func storageMedium(m Media) string {
+	if m.URI == "" {
+		return "NONE"
+	}
+	return "NETWORK"
+}
+
+func numTracks(m Media) string {
+	if m.URI == "" {
+		return "0"
+	}
+	return "1"
+}
+
+func transportActions(state string) string {
+	switch state {
+	case "PLAYING":
+		return "Pause,Stop,Seek"
+	case "PAUSED_PLAYBACK":
+		return "Play,Stop,Seek"
+	case "STOPPED":
+		return "Play"
+	}
+	return ""
+}
+
+func boolString(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+type didlLite struct {
+	Items []struct {
+		Title   string   `xml:"http://purl.org/dc/elements/1.1/ title"`
+		Creator string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+		Artists []string `xml:"urn:schemas-upnp-org:metadata-1-0/upnp/ artist"`
+		Album   string   `xml:"urn:schemas-upnp-org:metadata-1-0/upnp/ album"`
+		Res     []struct {
+			Duration string `xml:"duration,attr"`
+		} `xml:"res"`
+	} `xml:"item"`
+}
+
+// parseMedia builds the media for a URI and its DIDL-Lite metadata.
+// Missing or invalid metadata is not an error.
+func parseMedia(uri, metaData string) *Media {
+	m := &Media{URI: uri, MetaData: metaData}
+	var didl didlLite
+	if err := xml.Unmarshal([]byte(metaData), &didl); err != nil || len(didl.Items) == 0 {
+		return m
+	}
+	item := didl.Items[0]
+	m.Title = item.Title
+	m.Album = item.Album
+	m.Artists = item.Artists
+	if len(m.Artists) == 0 && item.Creator != "" {
+		m.Artists = []string{item.Creator}
+	}
+	for _, res := range item.Res {
+		if d, ok := parseClock(res.Duration); ok {
+			m.Duration = d
+			break
+		}
+	}
+	return m
+}
+
+// parseClock parses a duration of the form H+:MM:SS[.F+]
+func parseClock(s string) (time.Duration, bool) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 3 {
+		return 0, false
+	}
+	var h, m int
+	var sec float64
+	if _, err := fmt.Sscanf(parts[0]+" "+parts[1]+" "+parts[2], "%d %d %g", &h, &m, &sec); err != nil {
+		return 0, false
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec*float64(time.Second)), true
+}
+
+func formatClock(d time.Duration) string {
+	secs := int(d.Seconds())
+	return fmt.Sprintf("%d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+}
+
+// localIPFor returns the local IP address from which the peer at the given
+// address, or on the given interface, can reach this host.
+func localIPFor(from net.Addr, ifi *net.Interface) string {
+	if udp, ok := from.(*net.UDPAddr); ok {
+		if conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: udp.IP, Port: 1900}); err == nil {
+			defer conn.Close()
+			return conn.LocalAddr().(*net.UDPAddr).IP.String()
+		}
+	}
+	if ifi != nil {
+		addrs, _ := ifi.Addrs()
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+				return ipnet.IP.String()
+			}
+		}
+	}
+	ip, _ := util.GetLocalIP()
+	return ip
+}
@@ -0,0 +1,227 @@
+package dlnarenderer
+
+import (
+	"html"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dweymouth/supersonic/backend/player"
+)
+
+type fakePlayer struct {
+	media  []Media
+	status player.Status
+	volume int
+}
+
+func (f *fakePlayer) Play(m Media) error {
+	f.media = append(f.media, m)
+	f.status = player.Status{State: player.Playing, Duration: m.Duration.Seconds()}
+	return nil
+}
+
+func (f *fakePlayer) Continue() error { f.status.State = player.Playing; return nil }
+func (f *fakePlayer) Pause() error    { f.status.State = player.Paused; return nil }
+func (f *fakePlayer) Stop() error     { f.status.State = player.Stopped; return nil }
+
+func (f *fakePlayer) SeekSeconds(secs float64) error {
+	f.status.TimePos = secs
+	return nil
+}
+
+func (f *fakePlayer) Status() player.Status   { return f.status }
+func (f *fakePlayer) Volume() int             { return f.volume }
+func (f *fakePlayer) SetVolume(vol int) error { f.volume = vol; return nil }
+
+const testMetaData = `<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/">` +
+	`<item id="1" parentID="0"><dc:title>Song</dc:title><upnp:artist>Band</upnp:artist><upnp:album>Album</upnp:album>` +
+	`<res protocolInfo="http-get:*:audio/flac:*" duration="0:03:30.000">http://media/1.flac</res></item></DIDL-Lite>`
+
+func newTestRenderer(t *testing.T) (*Renderer, *fakePlayer, *httptest.Server) {
+	p := &fakePlayer{volume: 50}
+	r := New("Test Renderer", p)
+	srv := httptest.NewServer(r.handler())
+	t.Cleanup(srv.Close)
+	return r, p, srv
+}
+
+func soapCall(t *testing.T, srv *httptest.Server, service, action string, args ...string) (int, string) {
+	t.Helper()
+	var body strings.Builder
+	body.WriteString(`<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>`)
+	body.WriteString(`<u:` + action + ` xmlns:u="urn:schemas-upnp-org:service:` + service + `:1">`)
+	for i := 0; i+1 < len(args); i += 2 {
+		body.WriteString("<" + args[i] + ">" + html.EscapeString(args[i+1]) + "</" + args[i] + ">")
+	}
+	body.WriteString(`</u:` + action + `></s:Body></s:Envelope>`)
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/"+service+"/control", strings.NewReader(body.String()))
+	req.Header.Set("SOAPACTION", `"urn:schemas-upnp-org:service:`+service+`:1#`+action+`"`)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(b)
+}
+
+func TestDescription(t *testing.T) {
+	_, _, srv := newTestRenderer(t)
+	resp, err := srv.Client().Get(srv.URL + "/description.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	desc := string(b)
+	for _, s := range []string{deviceType, "<friendlyName>Test Renderer</friendlyName>", "/AVTransport/control", "/RenderingControl/event"} {
+		if !strings.Contains(desc, s) {
+			t.Errorf("expected description to contain %q", s)
+		}
+	}
+
+	resp, err = srv.Client().Get(srv.URL + "/AVTransport/scpd.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(b), "<name>SetAVTransportURI</name>") {
+		t.Error("expected AVTransport SCPD to list SetAVTransportURI")
+	}
+}
+
+func TestTransportControl(t *testing.T) {
+	r, p, srv := newTestRenderer(t)
+
+	if code, _ := soapCall(t, srv, "AVTransport", "Play", "InstanceID", "0", "Speed", "1"); code != http.StatusInternalServerError {
+		t.Error("expected Play without media to fail")
+	}
+	for _, uri := range []string{"", "file:///etc/passwd", "/home/user/song.flac", "rtsp://media/1.flac"} {
+		if code, _ := soapCall(t, srv, "AVTransport", "SetAVTransportURI",
+			"InstanceID", "0", "CurrentURI", uri, "CurrentURIMetaData", ""); code != http.StatusInternalServerError {
+			t.Errorf("expected non-HTTP URI %q to be rejected", uri)
+		}
+	}
+	if code, body := soapCall(t, srv, "AVTransport", "SetAVTransportURI",
+		"InstanceID", "0", "CurrentURI", "http://media/1.flac", "CurrentURIMetaData", testMetaData); code != http.StatusOK {
+		t.Fatalf("SetAVTransportURI failed: %s", body)
+	}
+	if len(p.media) != 0 {
+		t.Fatal("expected media to not play before Play")
+	}
+	soapCall(t, srv, "AVTransport", "Play", "InstanceID", "0", "Speed", "1")
+	if len(p.media) != 1 {
+		t.Fatal("expected media to be played")
+	}
+	m := p.media[0]
+	if m.URI != "http://media/1.flac" || m.Title != "Song" || m.Album != "Album" ||
+		len(m.Artists) != 1 || m.Artists[0] != "Band" || m.Duration != 210*time.Second {
+		t.Errorf("unexpected media %+v", m)
+	}
+	if _, body := soapCall(t, srv, "AVTransport", "GetTransportInfo", "InstanceID", "0"); !strings.Contains(body, "<CurrentTransportState>PLAYING</CurrentTransportState>") {
+		t.Errorf("expected PLAYING transport state, got %s", body)
+	}
+
+	soapCall(t, srv, "AVTransport", "Seek", "InstanceID", "0", "Unit", "REL_TIME", "Target", "0:01:05")
+	if _, body := soapCall(t, srv, "AVTransport", "GetPositionInfo", "InstanceID", "0"); !strings.Contains(body, "<RelTime>0:01:05</RelTime>") ||
+		!strings.Contains(body, "<TrackDuration>0:03:30</TrackDuration>") {
+		t.Errorf("unexpected position info %s", body)
+	}
+	if code, _ := soapCall(t, srv, "AVTransport", "Seek", "InstanceID", "0", "Unit", "TRACK_NR", "Target", "1"); code != http.StatusInternalServerError {
+		t.Error("expected unsupported seek mode to fail")
+	}
+
+	soapCall(t, srv, "AVTransport", "Pause", "InstanceID", "0")
+	if p.status.State != player.Paused {
+		t.Error("expected player to be paused")
+	}
+	soapCall(t, srv, "AVTransport", "Play", "InstanceID", "0", "Speed", "1")
+	if p.status.State != player.Playing || len(p.media) != 1 {
+		t.Error("expected Play to continue paused media")
+	}
+
+	// advances to the next URI when the current one finishes
+	soapCall(t, srv, "AVTransport", "SetNextAVTransportURI", "InstanceID", "0", "NextURI", "http://media/2.flac", "NextURIMetaData", "")
+	p.status.TimePos = 208
+	r.NotifyStatusChange()
+	p.status = player.Status{State: player.Stopped}
+	r.NotifyStatusChange()
+	if len(p.media) != 2 || p.media[1].URI != "http://media/2.flac" {
+		t.Fatal("expected next URI to play at end of media")
+	}
+
+	soapCall(t, srv, "AVTransport", "Stop", "InstanceID", "0")
+	if _, body := soapCall(t, srv, "AVTransport", "GetTransportInfo", "InstanceID", "0"); !strings.Contains(body, "<CurrentTransportState>STOPPED</CurrentTransportState>") {
+		t.Errorf("expected STOPPED transport state, got %s", body)
+	}
+}
+
+func TestRenderingControl(t *testing.T) {
+	_, p, srv := newTestRenderer(t)
+	soapCall(t, srv, "RenderingControl", "SetVolume", "InstanceID", "0", "Channel", "Master", "DesiredVolume", "30")
+	if p.volume != 30 {
+		t.Errorf("expected volume 30, got %d", p.volume)
+	}
+	soapCall(t, srv, "RenderingControl", "SetMute", "InstanceID", "0", "Channel", "Master", "DesiredMute", "1")
+	if _, body := soapCall(t, srv, "RenderingControl", "GetVolume", "InstanceID", "0", "Channel", "Master"); p.volume != 0 ||
+		!strings.Contains(body, "<CurrentVolume>30</CurrentVolume>") {
+		t.Errorf("expected mute to silence the player and keep the volume, got %s", body)
+	}
+	soapCall(t, srv, "RenderingControl", "SetMute", "InstanceID", "0", "Channel", "Master", "DesiredMute", "0")
+	if p.volume != 30 {
+		t.Errorf("expected volume restored on unmute, got %d", p.volume)
+	}
+	if code, _ := soapCall(t, srv, "RenderingControl", "SetVolume", "InstanceID", "1", "Channel", "Master", "DesiredVolume", "30"); code != http.StatusInternalServerError {
+		t.Error("expected invalid InstanceID to fail")
+	}
+}
+
+func TestEventSubscription(t *testing.T) {
+	_, _, srv := newTestRenderer(t)
+	events := make(chan string, 4)
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if r.Method == "NOTIFY" && r.Header.Get("SID") != "" {
+			// the LastChange event is escaped within the property set
+			events <- r.Header.Get("SEQ") + " " + html.UnescapeString(html.UnescapeString(string(b)))
+		}
+	}))
+	defer callback.Close()
+
+	req, _ := http.NewRequest("SUBSCRIBE", srv.URL+"/AVTransport/event", nil)
+	req.Header.Set("CALLBACK", "<"+callback.URL+"/>")
+	req.Header.Set("NT", "upnp:event")
+	req.Header.Set("TIMEOUT", "Second-300")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("SID") == "" || resp.Header.Get("TIMEOUT") != "Second-300" {
+		t.Fatalf("unexpected SUBSCRIBE response %s %v", resp.Status, resp.Header)
+	}
+
+	select {
+	case ev := <-events:
+		if !strings.HasPrefix(ev, "0 ") || !strings.Contains(ev, `TransportState val="NO_MEDIA_PRESENT"`) {
+			t.Errorf("unexpected initial event %s", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for initial event")
+	}
+
+	soapCall(t, srv, "AVTransport", "SetAVTransportURI", "InstanceID", "0", "CurrentURI", "http://media/1.flac", "CurrentURIMetaData", "")
+	select {
+	case ev := <-events:
+		if !strings.HasPrefix(ev, "1 ") || !strings.Contains(ev, `TransportState val="STOPPED"`) {
+			t.Errorf("unexpected change event %s", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for change event")
+	}
+}
@@ -0,0 +1,191 @@
+package dlnarenderer
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+)
+
+// service describes one of the UPnP services of the renderer,
+// from which its service description (SCPD) is generated.
+type service struct {
+	name      string // e.g. AVTransport
+	actions   []action
+	stateVars []stateVar
+}
+
+func (s *service) serviceType() string {
+	return "urn:schemas-upnp-org:service:" + s.name + ":1"
+}
+
+func (s *service) serviceID() string {
+	return "urn:upnp-org:serviceId:" + s.name
+}
+
+type action struct {
+	name string
+	// arguments as name, direction ("in" or "out"), related state variable
+	args [][3]string
+}
+
+type stateVar struct {
+	name     string
+	dataType string
+	evented  bool
+	allowed  []string
+}
+
+func in(name, stateVar string) [3]string  { return [3]string{name, "in", stateVar} }
+func out(name, stateVar string) [3]string { return [3]string{name, "out", stateVar} }
+
+var instanceID = in("InstanceID", "A_ARG_TYPE_InstanceID")
+
+var avTransportService = &service{
+	name: "AVTransport",
+	actions: []action{
+		{"SetAVTransportURI", [][3]string{instanceID, in("CurrentURI", "AVTransportURI"), in("CurrentURIMetaData", "AVTransportURIMetaData")}},
+		{"SetNextAVTransportURI", [][3]string{instanceID, in("NextURI", "NextAVTransportURI"), in("NextURIMetaData", "NextAVTransportURIMetaData")}},
+		{"GetMediaInfo", [][3]string{instanceID,
+			out("NrTracks", "NumberOfTracks"), out("MediaDuration", "CurrentMediaDuration"),
+			out("CurrentURI", "AVTransportURI"), out("CurrentURIMetaData", "AVTransportURIMetaData"),
+			out("NextURI", "NextAVTransportURI"), out("NextURIMetaData", "NextAVTransportURIMetaData"),
+			out("PlayMedium", "PlaybackStorageMedium"), out("RecordMedium", "RecordStorageMedium"),
+			out("WriteStatus", "RecordMediumWriteStatus")}},
+		{"GetTransportInfo", [][3]string{instanceID,
+			out("CurrentTransportState", "TransportState"), out("CurrentTransportStatus", "TransportStatus"),
+			out("CurrentSpeed", "TransportPlaySpeed")}},
+		{"GetPositionInfo", [][3]string{instanceID,
+			out("Track", "CurrentTrack"), out("TrackDuration", "CurrentTrackDuration"),
+			out("TrackMetaData", "CurrentTrackMetaData"), out("TrackURI", "CurrentTrackURI"),
+			out("RelTime", "RelativeTimePosition"), out("AbsTime", "AbsoluteTimePosition"),
+			out("RelCount", "RelativeCounterPosition"), out("AbsCount", "AbsoluteCounterPosition")}},
+		{"GetDeviceCapabilities", [][3]string{instanceID,
+			out("PlayMedia", "PossiblePlaybackStorageMedia"), out("RecMedia", "PossibleRecordStorageMedia"),
+			out("RecQualityModes", "PossibleRecordQualityModes")}},
+		{"GetTransportSettings", [][3]string{instanceID,
+			out("PlayMode", "CurrentPlayMode"), out("RecQualityMode", "CurrentRecordQualityMode")}},
+		{"GetCurrentTransportActions", [][3]string{instanceID, out("Actions", "CurrentTransportActions")}},
+		{"Stop", [][3]string{instanceID}},
+		{"Play", [][3]string{instanceID, in("Speed", "TransportPlaySpeed")}},
+		{"Pause", [][3]string{instanceID}},
+		{"Seek", [][3]string{instanceID, in("Unit", "A_ARG_TYPE_SeekMode"), in("Target", "A_ARG_TYPE_SeekTarget")}},
+		{"Next", [][3]string{instanceID}},
+		{"Previous", [][3]string{instanceID}},
+	},
+	stateVars: []stateVar{
+		{"TransportState", "string", false, []string{"STOPPED", "PLAYING", "PAUSED_PLAYBACK", "TRANSITIONING", "NO_MEDIA_PRESENT"}},
+		{"TransportStatus", "string", false, []string{"OK", "ERROR_OCCURRED"}},
+		{"TransportPlaySpeed", "string", false, []string{"1"}},
+		{"PlaybackStorageMedium", "string", false, []string{"NONE", "NETWORK"}},
+		{"RecordStorageMedium", "string", false, []string{"NOT_IMPLEMENTED"}},
+		{"PossiblePlaybackStorageMedia", "string", false, nil},
+		{"PossibleRecordStorageMedia", "string", false, nil},
+		{"PossibleRecordQualityModes", "string", false, nil},
+		{"CurrentPlayMode", "string", false, []string{"NORMAL"}},
+		{"CurrentRecordQualityMode", "string", false, []string{"NOT_IMPLEMENTED"}},
+		{"RecordMediumWriteStatus", "string", false, []string{"NOT_IMPLEMENTED"}},
+		{"NumberOfTracks", "ui4", false, nil},
+		{"CurrentTrack", "ui4", false, nil},
+		{"CurrentTrackDuration", "string", false, nil},
+		{"CurrentMediaDuration", "string", false, nil},
+		{"CurrentTrackMetaData", "string", false, nil},
+		{"CurrentTrackURI", "string", false, nil},
+		{"AVTransportURI", "string", false, nil},
+		{"AVTransportURIMetaData", "string", false, nil},
+		{"NextAVTransportURI", "string", false, nil},
+		{"NextAVTransportURIMetaData", "string", false, nil},
+		{"RelativeTimePosition", "string", false, nil},
+		{"AbsoluteTimePosition", "string", false, nil},
+		{"RelativeCounterPosition", "i4", false, nil},
+		{"AbsoluteCounterPosition", "i4", false, nil},
+		{"CurrentTransportActions", "string", false, nil},
+		{"LastChange", "string", true, nil},
+		{"A_ARG_TYPE_SeekMode", "string", false, []string{"REL_TIME", "ABS_TIME", "TRACK_NR"}},
+		{"A_ARG_TYPE_SeekTarget", "string", false, nil},
+		{"A_ARG_TYPE_InstanceID", "ui4", false, nil},
+	},
+}
+
+var renderingControlService = &service{
+	name: "RenderingControl",
+	actions: []action{
+		{"ListPresets", [][3]string{instanceID, out("CurrentPresetNameList", "PresetNameList")}},
+		{"SelectPreset", [][3]string{instanceID, in("PresetName", "A_ARG_TYPE_PresetName")}},
+		{"GetMute", [][3]string{instanceID, in("Channel", "A_ARG_TYPE_Channel"), out("CurrentMute", "Mute")}},
+		{"SetMute", [][3]string{instanceID, in("Channel", "A_ARG_TYPE_Channel"), in("DesiredMute", "Mute")}},
+		{"GetVolume", [][3]string{instanceID, in("Channel", "A_ARG_TYPE_Channel"), out("CurrentVolume", "Volume")}},
+		{"SetVolume", [][3]string{instanceID, in("Channel", "A_ARG_TYPE_Channel"), in("DesiredVolume", "Volume")}},
+	},
+	stateVars: []stateVar{
+		{"PresetNameList", "string", false, nil},
+		{"Mute", "boolean", false, nil},
+		{"Volume", "ui2", false, nil},
+		{"LastChange", "string", true, nil},
+		{"A_ARG_TYPE_Channel", "string", false, []string{"Master"}},
+		{"A_ARG_TYPE_InstanceID", "ui4", false, nil},
+		{"A_ARG_TYPE_PresetName", "string", false, []string{"FactoryDefaults"}},
+	},
+}
+
+var connectionManagerService = &service{
+	name: "ConnectionManager",
+	actions: []action{
+		{"GetProtocolInfo", [][3]string{out("Source", "SourceProtocolInfo"), out("Sink", "SinkProtocolInfo")}},
+		{"GetCurrentConnectionIDs", [][3]string{out("ConnectionIDs", "CurrentConnectionIDs")}},
+		{"GetCurrentConnectionInfo", [][3]string{in("ConnectionID", "A_ARG_TYPE_ConnectionID"),
+			out("RcsID", "A_ARG_TYPE_RcsID"), out("AVTransportID", "A_ARG_TYPE_AVTransportID"),
+			out("ProtocolInfo", "A_ARG_TYPE_ProtocolInfo"), out("PeerConnectionManager", "A_ARG_TYPE_ConnectionManager"),
+			out("PeerConnectionID", "A_ARG_TYPE_ConnectionID"), out("Direction", "A_ARG_TYPE_Direction"),
+			out("Status", "A_ARG_TYPE_ConnectionStatus")}},
+	},
+	stateVars: []stateVar{
+		{"SourceProtocolInfo", "string", true, nil},
+		{"SinkProtocolInfo", "string", true, nil},
+		{"CurrentConnectionIDs", "string", true, nil},
+		{"A_ARG_TYPE_ConnectionStatus", "string", false, []string{"OK", "ContentFormatMismatch", "InsufficientBandwidth", "UnreliableChannel", "Unknown"}},
+		{"A_ARG_TYPE_ConnectionManager", "string", false, nil},
+		{"A_ARG_TYPE_Direction", "string", false, []string{"Input", "Output"}},
+		{"A_ARG_TYPE_ProtocolInfo", "string", false, nil},
+		{"A_ARG_TYPE_ConnectionID", "i4", false, nil},
+		{"A_ARG_TYPE_AVTransportID", "i4", false, nil},
+		{"A_ARG_TYPE_RcsID", "i4", false, nil},
+	},
+}
+
+var services = []*service{avTransportService, renderingControlService, connectionManagerService}
+
+// scpd returns the service description document.
+func (s *service) scpd() []byte {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` +
+		`<scpd xmlns="urn:schemas-upnp-org:service-1-0"><specVersion><major>1</major><minor>0</minor></specVersion><actionList>`)
+	for _, a := range s.actions {
+		fmt.Fprintf(&b, "<action><name>%s</name><argumentList>", a.name)
+		for _, arg := range a.args {
+			fmt.Fprintf(&b, "<argument><name>%s</name><direction>%s</direction><relatedStateVariable>%s</relatedStateVariable></argument>",
+				arg[0], arg[1], arg[2])
+		}
+		b.WriteString("</argumentList></action>")
+	}
+	b.WriteString("</actionList><serviceStateTable>")
+	for _, v := range s.stateVars {
+		sendEvents := "no"
+		if v.evented {
+			sendEvents = "yes"
+		}
+		fmt.Fprintf(&b, `<stateVariable sendEvents="%s"><name>%s</name><dataType>%s</dataType>`, sendEvents, v.name, v.dataType)
+		if len(v.allowed) > 0 {
+			b.WriteString("<allowedValueList>")
+			for _, val := range v.allowed {
+				fmt.Fprintf(&b, "<allowedValue>%s</allowedValue>", html.EscapeString(val))
+			}
+			b.WriteString("</allowedValueList>")
+		}
+		if v.name == "Volume" {
+			b.WriteString("<allowedValueRange><minimum>0</minimum><maximum>100</maximum><step>1</step></allowedValueRange>")
+		}
+		b.WriteString("</stateVariable>")
+	}
+	b.WriteString("</serviceStateTable></scpd>")
+	return b.Bytes()
+}
@@ -0,0 +1,86 @@
+package dlnarenderer
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+)
+
+// upnpError is an error returned to the control point as a SOAP fault.
+type upnpError struct {
+	code int
+	desc string
+}
+
+func (e *upnpError) Error() string {
+	return fmt.Sprintf("UPnP error %d: %s", e.code, e.desc)
+}
+
+var (
+	errInvalidAction       = &upnpError{401, "Invalid Action"}
+	errInvalidArgs         = &upnpError{402, "Invalid Args"}
+	errActionFailed        = &upnpError{501, "Action Failed"}
+	errTransitionNotAvail  = &upnpError{701, "Transition not available"}
+	errSeekModeUnsupported = &upnpError{710, "Seek mode not supported"}
+	errIllegalSeekTarget   = &upnpError{711, "Illegal seek target"}
+	errInvalidInstanceID   = &upnpError{718, "Invalid InstanceID"}
+)
+
+// actionHandler handles a SOAP action given its input arguments by name,
+// returning the output arguments as name, value pairs in SCPD order.
+type actionHandler func(args map[string]string) ([]string, error)
+
+type soapEnvelope struct {
+	Body struct {
+		Action struct {
+			XMLName xml.Name
+			Args    []struct {
+				XMLName xml.Name
+				Value   string `xml:",chardata"`
+			} `xml:",any"`
+		} `xml:",any"`
+	} `xml:"Body"`
+}
+
+// parseSOAPRequest returns the action name and input arguments of a request.
+func parseSOAPRequest(r io.Reader) (string, map[string]string, error) {
+	var env soapEnvelope
+	if err := xml.NewDecoder(io.LimitReader(r, 1<<20)).Decode(&env); err != nil {
+		return "", nil, err
+	}
+	args := make(map[string]string, len(env.Body.Action.Args))
+	for _, a := range env.Body.Action.Args {
+		args[a.XMLName.Local] = a.Value
+	}
+	return env.Body.Action.XMLName.Local, args, nil
+}
+
+const soapEnvelopeStart = `<?xml version="1.0" encoding="utf-8"?>` +
+	`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`
+
+const soapEnvelopeEnd = `</s:Body></s:Envelope>`
+
+func writeSOAPResponse(w http.ResponseWriter, serviceType, action string, out []string) {
+	var b bytes.Buffer
+	b.WriteString(soapEnvelopeStart)
+	fmt.Fprintf(&b, `<u:%sResponse xmlns:u="%s">`, action, serviceType)
+	for i := 0; i+1 < len(out); i += 2 {
+		fmt.Fprintf(&b, "<%s>%s</%s>", out[i], html.EscapeString(out[i+1]), out[i])
+	}
+	fmt.Fprintf(&b, "</u:%sResponse>", action)
+	b.WriteString(soapEnvelopeEnd)
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.Header().Set("EXT", "")
+	w.Write(b.Bytes())
+}
+
+func writeSOAPFault(w http.ResponseWriter, err *upnpError) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, `%s<s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail>`+
+		`<UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>%d</errorCode><errorDescription>%s</errorDescription></UPnPError>`+
+		`</detail></s:Fault>%s`, soapEnvelopeStart, err.code, html.EscapeString(err.desc), soapEnvelopeEnd)
+}
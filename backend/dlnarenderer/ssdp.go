@@ -0,0 +1,84 @@
+package dlnarenderer
+
+import (
+	"net"
+	"runtime"
+	"time"
+
+	"github.com/koron/go-ssdp"
+)
+
+const (
+	deviceType = "urn:schemas-upnp-org:device:MediaRenderer:1"
+
+	// CACHE-CONTROL max-age of the advertisements
+	ssdpMaxAge = 1800
+	// alive notifications are repeated well within max-age
+	ssdpAliveInterval = 5 * time.Minute
+)
+
+var ssdpServer = runtime.GOOS + "/1.0 UPnP/1.0 Supersonic/1.0"
+
+// advertiser announces the renderer over SSDP and answers
+// M-SEARCH requests for the device and its services.
+type advertiser struct {
+	ads      []*ssdp.Advertiser
+	done     chan struct{}
+	loopDone chan struct{}
+}
+
+func newAdvertiser(udn string, location func(net.Addr, *net.Interface) string) (*advertiser, error) {
+	targets := [][2]string{ // ST (NT), USN
+		{ssdp.RootDevice, udn + "::" + ssdp.RootDevice},
+		{udn, udn},
+		{deviceType, udn + "::" + deviceType},
+	}
+	for _, s := range services {
+		targets = append(targets, [2]string{s.serviceType(), udn + "::" + s.serviceType()})
+	}
+
+	a := &advertiser{done: make(chan struct{}), loopDone: make(chan struct{})}
+	for _, t := range targets {
+		ad, err := ssdp.Advertise(t[0], t[1], ssdp.LocationProviderFunc(location), ssdpServer, ssdpMaxAge)
+		if err != nil {
+			for _, ad := range a.ads {
+				ad.Close()
+			}
+			return nil, err
+		}
+		a.ads = append(a.ads, ad)
+	}
+	go a.aliveLoop()
+	return a, nil
+}
+
+func (a *advertiser) aliveLoop() {
+	defer close(a.loopDone)
+	a.alive()
+	t := time.NewTicker(ssdpAliveInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-t.C:
+			a.alive()
+		}
+	}
+}
+
+func (a *advertiser) alive() {
+	for _, ad := range a.ads {
+		ad.Alive()
+	}
+}
+
+// close announces that the renderer is leaving the network.
+func (a *advertiser) close() {
+	close(a.done)
+	<-a.loopDone
+	for _, ad := range a.ads {
+		ad.Bye()
+		ad.Close()
+	}
+}
@@ -0,0 +1,262 @@
+package backend
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/deluan/sanitize"
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+)
+
+// Max difference in duration between two tracks with
+// the same normalized title and artist for them to be
+// considered duplicates of each other.
+const duplicateDurationTolerance = 3 * time.Second
+
+var losslessFormats = []string{"flac", "alac", "wav", "aiff", "aif", "ape", "wv", "dsf", "dff", "tta"}
+
+// A set of tracks which are likely copies of the same recording.
+type DuplicateTrackGroup struct {
+	Tracks []*mediaprovider.Track
+
+	// Index into Tracks of the copy that should be kept.
+	// Initially set to the best quality copy.
+	Preferred int
+}
+
+// PreferredTrack returns the copy of the track that should be kept.
+func (d *DuplicateTrackGroup) PreferredTrack() *mediaprovider.Track {
+	return d.Tracks[d.Preferred]
+}
+
+// InferiorTracks returns all copies except the preferred one.
+func (d *DuplicateTrackGroup) InferiorTracks() []*mediaprovider.Track {
+	inferior := make([]*mediaprovider.Track, 0, len(d.Tracks)-1)
+	for i, tr := range d.Tracks {
+		if i != d.Preferred {
+			inferior = append(inferior, tr)
+		}
+	}
+	return inferior
+}
+
+// FindDuplicateTracks walks the track iterator and groups tracks that are likely
+// duplicates of each other. Tracks are considered duplicates if they share a
+// MusicBrainz recording ID, or if their normalized title and artists match,
+// their durations are within a few seconds, and they don't have conflicting
+// MusicBrainz IDs. If onProgress is non-nil, it is called periodically with
+// the number of tracks scanned so far.
+func FindDuplicateTracks(ctx context.Context, iter mediaprovider.TrackIterator, onProgress func(int)) []*DuplicateTrackGroup {
+	byMBID := make(map[string][]*mediaprovider.Track)
+	byKey := make(map[string][]*mediaprovider.Track)
+	var mbidOrder, keyOrder []string
+
+	scanned := 0
+	for tr := iter.Next(); tr != nil; tr = iter.Next() {
+		if ctx.Err() != nil {
+			return nil
+		}
+		scanned++
+		if onProgress != nil && scanned%500 == 0 {
+			onProgress(scanned)
+		}
+		if tr.MusicBrainzID != "" {
+			if _, ok := byMBID[tr.MusicBrainzID]; !ok {
+				mbidOrder = append(mbidOrder, tr.MusicBrainzID)
+			}
+			byMBID[tr.MusicBrainzID] = append(byMBID[tr.MusicBrainzID], tr)
+		}
+		if key := duplicateMatchKey(tr); key != "" {
+			if _, ok := byKey[key]; !ok {
+				keyOrder = append(keyOrder, key)
+			}
+			byKey[key] = append(byKey[key], tr)
+		}
+	}
+	if onProgress != nil {
+		onProgress(scanned)
+	}
+
+	var groups []*DuplicateTrackGroup
+	mbidGroups := make(map[string]*DuplicateTrackGroup)
+	for _, mbid := range mbidOrder {
+		if trs := byMBID[mbid]; len(trs) > 1 {
+			g := &DuplicateTrackGroup{Tracks: trs}
+			mbidGroups[mbid] = g
+			groups = append(groups, g)
+		}
+	}
+
+	for _, key := range keyOrder {
+		for _, cluster := range clusterByDuration(byKey[key]) {
+			var mbids []string
+			var noMBID []*mediaprovider.Track
+			for _, tr := range cluster {
+				if tr.MusicBrainzID == "" {
+					noMBID = append(noMBID, tr)
+				} else if !slices.Contains(mbids, tr.MusicBrainzID) {
+					mbids = append(mbids, tr.MusicBrainzID)
+				}
+			}
+			switch {
+			case len(mbids) == 1 && mbidGroups[mbids[0]] != nil:
+				// copies without an MBID join the group of their MBID-tagged twin
+				g := mbidGroups[mbids[0]]
+				g.Tracks = append(g.Tracks, noMBID...)
+			case len(mbids) == 1 && len(cluster) > 1:
+				g := &DuplicateTrackGroup{Tracks: cluster}
+				mbidGroups[mbids[0]] = g
+				groups = append(groups, g)
+			case len(mbids) != 1 && len(noMBID) > 1:
+				// tracks with differing MBIDs are distinct recordings
+				groups = append(groups, &DuplicateTrackGroup{Tracks: noMBID})
+			}
+		}
+	}
+
+	for _, g := range groups {
+		g.Preferred = preferredTrackIndex(g.Tracks)
+	}
+	return groups
+}
+
+// ReplaceDuplicatesInPlaylists replaces every inferior copy of each duplicate group
+// with its preferred copy in the given playlists, using ReplacePlaylistTracks.
+// Inferior copies are removed from playlists that already contain the preferred copy.
+// Returns the number of playlists that were modified.
+func ReplaceDuplicatesInPlaylists(mp mediaprovider.MediaProvider, playlists []*mediaprovider.Playlist, groups []*DuplicateTrackGroup) (int, error) {
+	replacements := make(map[string]string)
+	for _, g := range groups {
+		preferredID := g.PreferredTrack().ID
+		for _, tr := range g.InferiorTracks() {
+			replacements[tr.ID] = preferredID
+		}
+	}
+	if len(replacements) == 0 {
+		return 0, nil
+	}
+
+	updated := 0
+	for _, pl := range playlists {
+		playlist, err := mp.GetPlaylist(pl.ID)
+		if err != nil {
+			return updated, err
+		}
+		present := make(map[string]bool, len(playlist.Tracks))
+		for _, tr := range playlist.Tracks {
+			present[tr.ID] = true
+		}
+		changed := false
+		ids := make([]string, 0, len(playlist.Tracks))
+		for _, tr := range playlist.Tracks {
+			newID, ok := replacements[tr.ID]
+			if !ok {
+				ids = append(ids, tr.ID)
+				continue
+			}
+			changed = true
+			// if the playlist already has the preferred copy,
+			// drop the inferior one rather than adding it twice
+			if !present[newID] {
+				ids = append(ids, newID)
+				present[newID] = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		if err := mp.ReplacePlaylistTracks(pl.ID, ids); err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// TrackQualityScore returns a score that orders copies of the same track
+// by audio quality. Lossless formats always rank above lossy ones.
+func TrackQualityScore(tr *mediaprovider.Track) int {
+	score := tr.BitRate
+	if isLosslessTrack(tr) {
+		score += 1_000_000
+	}
+	score += tr.BitDepth*100 + tr.SampleRate/1000
+	return score
+}
+
+func preferredTrackIndex(tracks []*mediaprovider.Track) int {
+	best := 0
+	for i, tr := range tracks {
+		if TrackQualityScore(tr) > TrackQualityScore(tracks[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+func isLosslessTrack(tr *mediaprovider.Track) bool {
+	ext := strings.ToLower(tr.Extension)
+	if ext == "" {
+		if idx := strings.LastIndex(tr.FilePath, "."); idx >= 0 {
+			ext = strings.ToLower(tr.FilePath[idx+1:])
+		}
+	}
+	if slices.Contains(losslessFormats, ext) {
+		return true
+	}
+	ct := strings.ToLower(tr.ContentType)
+	return strings.Contains(ct, "flac") || strings.Contains(ct, "wav") || strings.Contains(ct, "alac")
+}
+
+// groups tracks (which must have matching keys) into clusters
+// where each track is within the duration tolerance of its neighbor
+func clusterByDuration(tracks []*mediaprovider.Track) [][]*mediaprovider.Track {
+	if len(tracks) < 2 {
+		return nil
+	}
+	sorted := slices.Clone(tracks)
+	slices.SortStableFunc(sorted, func(a, b *mediaprovider.Track) int {
+		return int(a.Duration - b.Duration)
+	})
+	var clusters [][]*mediaprovider.Track
+	cur := []*mediaprovider.Track{sorted[0]}
+	for _, tr := range sorted[1:] {
+		if tr.Duration-cur[len(cur)-1].Duration <= duplicateDurationTolerance {
+			cur = append(cur, tr)
+		} else {
+			clusters = append(clusters, cur)
+			cur = []*mediaprovider.Track{tr}
+		}
+	}
+	return append(clusters, cur)
+}
+
+func duplicateMatchKey(tr *mediaprovider.Track) string {
+	title := normalizeForDuplicateMatch(tr.Title)
+	if title == "" {
+		return ""
+	}
+	artists := make([]string, 0, len(tr.ArtistNames))
+	for _, a := range tr.ArtistNames {
+		if n := normalizeForDuplicateMatch(a); n != "" {
+			artists = append(artists, n)
+		}
+	}
+	slices.Sort(artists)
+	return title + "\x00" + strings.Join(artists, "\x00")
+}
+
+// lowercases, strips accents, and removes all punctuation and whitespace
+func normalizeForDuplicateMatch(s string) string {
+	s = sanitize.Accents(strings.ToLower(s))
+	var sb strings.Builder
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
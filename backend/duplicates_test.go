@@ -0,0 +1,114 @@
+package backend
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+)
+
+type sliceTrackIterator struct {
+	tracks []*mediaprovider.Track
+	pos    int
+}
+
+func (s *sliceTrackIterator) Next() *mediaprovider.Track {
+	if s.pos >= len(s.tracks) {
+		return nil
+	}
+	s.pos++
+	return s.tracks[s.pos-1]
+}
+
+func TestFindDuplicateTracks(t *testing.T) {
+	tracks := []*mediaprovider.Track{
+		{ID: "1", Title: "Café Song", ArtistNames: []string{"Artist"}, Duration: 200 * time.Second, Extension: "mp3", BitRate: 320},
+		{ID: "2", Title: "cafe song!", ArtistNames: []string{"artist"}, Duration: 201 * time.Second, Extension: "flac", BitRate: 900},
+		{ID: "3", Title: "Cafe Song", ArtistNames: []string{"Artist"}, Duration: 320 * time.Second}, // extended version
+		{ID: "4", Title: "Other", ArtistNames: []string{"Artist"}, Duration: 100 * time.Second, MusicBrainzID: "abc"},
+		{ID: "5", Title: "Other (Remaster)", ArtistNames: []string{"Artist"}, Duration: 101 * time.Second, MusicBrainzID: "abc"},
+		{ID: "6", Title: "Other", ArtistNames: []string{"Artist"}, Duration: 100 * time.Second, MusicBrainzID: "def"},
+	}
+
+	groups := FindDuplicateTracks(context.Background(), &sliceTrackIterator{tracks: tracks}, nil)
+	if len(groups) != 2 {
+		t.Fatalf("got %d duplicate groups, want 2", len(groups))
+	}
+
+	// tracks sharing a MusicBrainz ID are grouped even if titles differ
+	if g := groups[0]; len(g.Tracks) != 2 || g.Tracks[0].ID != "4" || g.Tracks[1].ID != "5" {
+		t.Errorf("unexpected MusicBrainz ID group: %v", g.Tracks)
+	}
+
+	g := groups[1]
+	if len(g.Tracks) != 2 {
+		t.Fatalf("got %d tracks in group, want 2", len(g.Tracks))
+	}
+	if id := g.PreferredTrack().ID; id != "2" {
+		t.Errorf("preferred track = %q, want lossless copy %q", id, "2")
+	}
+	if inf := g.InferiorTracks(); len(inf) != 1 || inf[0].ID != "1" {
+		t.Errorf("unexpected inferior tracks: %v", inf)
+	}
+}
+
+type playlistTracksProvider struct {
+	mediaprovider.MediaProvider
+	playlists map[string][]string
+}
+
+func (p *playlistTracksProvider) GetPlaylist(id string) (*mediaprovider.PlaylistWithTracks, error) {
+	pl := &mediaprovider.PlaylistWithTracks{Playlist: mediaprovider.Playlist{ID: id}}
+	for _, trID := range p.playlists[id] {
+		pl.Tracks = append(pl.Tracks, &mediaprovider.Track{ID: trID})
+	}
+	return pl, nil
+}
+
+func (p *playlistTracksProvider) ReplacePlaylistTracks(id string, trackIDs []string) error {
+	p.playlists[id] = trackIDs
+	return nil
+}
+
+func TestReplaceDuplicatesInPlaylists(t *testing.T) {
+	mp := &playlistTracksProvider{playlists: map[string][]string{
+		"a": {"x", "1", "y"},
+		"b": {"2", "x", "1"},
+		"c": {"x", "y"},
+	}}
+	groups := []*DuplicateTrackGroup{{
+		Tracks:    []*mediaprovider.Track{{ID: "1"}, {ID: "2"}},
+		Preferred: 1,
+	}}
+	playlists := []*mediaprovider.Playlist{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+
+	n, err := ReplaceDuplicatesInPlaylists(mp, playlists, groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("updated %d playlists, want 2", n)
+	}
+	if got := mp.playlists["a"]; !slices.Equal(got, []string{"x", "2", "y"}) {
+		t.Errorf("inferior copy not replaced: %v", got)
+	}
+	// the preferred copy was already in the playlist
+	if got := mp.playlists["b"]; !slices.Equal(got, []string{"2", "x"}) {
+		t.Errorf("inferior copy not removed: %v", got)
+	}
+}
+
+func TestTrackQualityScore(t *testing.T) {
+	lossy := &mediaprovider.Track{Extension: "mp3", BitRate: 320}
+	lossless := &mediaprovider.Track{FilePath: "/music/a.FLAC", BitRate: 700}
+	hiRes := &mediaprovider.Track{Extension: "flac", BitRate: 700, BitDepth: 24, SampleRate: 96000}
+
+	if TrackQualityScore(lossy) >= TrackQualityScore(lossless) {
+		t.Error("lossy track scored at or above lossless track")
+	}
+	if TrackQualityScore(lossless) >= TrackQualityScore(hiRes) {
+		t.Error("16-bit track scored at or above hi-res track")
+	}
+}
@@ -0,0 +1,272 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// interval between health checks while connected
+	healthCheckInterval = 30 * time.Second
+	// interval between health checks while reconnecting
+	reconnectCheckInterval = 5 * time.Second
+	healthCheckTimeout     = 5 * time.Second
+)
+
+// ConnectionState is the state of the connection to the server.
+type ConnectionState int
+
+const (
+	// the server is reachable through one of its hostnames
+	ConnectionOK ConnectionState = iota
+	// neither hostname is reachable; requests fail until one recovers
+	ConnectionReconnecting
+)
+
+// ConnectionStatus describes the connection to the server.
+type ConnectionStatus struct {
+	State ConnectionState
+	// the hostname requests are sent to, either Hostname or AltHostname
+	Hostname string
+	// round-trip time of the last health check
+	Latency time.Duration
+}
+
+// hostMonitor checks the health of a server's Hostname and AltHostname,
+// and fails over between them. Requests to either hostname are sent to
+// the active one by the transport returned by wrap, so that the clients
+// created at login keep working after switching hosts.
+type hostMonitor struct {
+	// the Hostname and AltHostname (nil if not set)
+	hosts [2]*url.URL
+	// transport to send health checks with, without failover
+	base http.RoundTripper
+
+	mu sync.Mutex
+	// whether requests are sent to the active host; not until
+	// the login has chosen the host to use
+	ready    bool
+	active   int
+	status   ConnectionStatus
+	onChange func(status ConnectionStatus, hostChanged bool)
+	wake     chan struct{}
+}
+
+func newHostMonitor(hostname, altHostname string, base http.RoundTripper) *hostMonitor {
+	m := &hostMonitor{base: base, wake: make(chan struct{}, 1)}
+	m.hosts[0], _ = url.Parse(hostname)
+	if altHostname != "" {
+		m.hosts[1], _ = url.Parse(altHostname)
+	}
+	return m
+}
+
+// activate starts sending requests to the host chosen at login,
+// 0 for Hostname and 1 for AltHostname.
+func (m *hostMonitor) activate(active int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ready = true
+	m.active = active
+	m.status = ConnectionStatus{Hostname: m.hostname(active)}
+}
+
+func (m *hostMonitor) hostname(i int) string {
+	if m.hosts[i] == nil {
+		return ""
+	}
+	return m.hosts[i].String()
+}
+
+// setOnChange sets the callback invoked when the status changes, which is
+// called from the Run goroutine or that of a request that failed over.
+func (m *hostMonitor) setOnChange(onChange func(status ConnectionStatus, hostChanged bool)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = onChange
+}
+
+// Status returns the current connection status.
+func (m *hostMonitor) Status() ConnectionStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
+
+// Run checks the health of the hostnames until the context is canceled.
+func (m *hostMonitor) Run(ctx context.Context) {
+	interval := healthCheckInterval
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		case <-m.wake:
+		}
+		if m.checkNow(ctx).State == ConnectionReconnecting {
+			interval = reconnectCheckInterval
+		} else {
+			interval = healthCheckInterval
+		}
+	}
+}
+
+// CheckSoon wakes the monitor to check the hostnames without waiting.
+func (m *hostMonitor) CheckSoon() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// checkNow checks the hostnames, preferring Hostname when it is
+// reachable, and switches to the first one that responds.
+func (m *hostMonitor) checkNow(ctx context.Context) ConnectionStatus {
+	for i, host := range m.hosts {
+		if host == nil {
+			continue
+		}
+		if latency, err := m.ping(ctx, host); err == nil {
+			return m.setStatus(ConnectionStatus{State: ConnectionOK, Hostname: m.hostname(i), Latency: latency}, i)
+		}
+	}
+	if ctx.Err() != nil {
+		return m.Status()
+	}
+	m.mu.Lock()
+	active := m.active
+	m.mu.Unlock()
+	return m.setStatus(ConnectionStatus{State: ConnectionReconnecting, Hostname: m.hostname(active)}, active)
+}
+
+// ping returns the round-trip time of a request to the host. Any HTTP
+// response, even an error status, means the server is reachable.
+func (m *hostMonitor) ping(ctx context.Context, host *url.URL) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, host.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	resp, err := m.base.RoundTrip(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return time.Since(start), nil
+}
+
+func (m *hostMonitor) setStatus(status ConnectionStatus, active int) ConnectionStatus {
+	m.mu.Lock()
+	hostChanged := active != m.active
+	changed := hostChanged || status.State != m.status.State
+	m.active = active
+	m.status = status
+	onChange := m.onChange
+	m.mu.Unlock()
+	if changed && onChange != nil {
+		onChange(status, hostChanged)
+	}
+	return status
+}
+
+// rewrite returns the URL on the active host if it is on the other one.
+func (m *hostMonitor) rewrite(u *url.URL) *url.URL {
+	m.mu.Lock()
+	ready, active := m.ready, m.active
+	m.mu.Unlock()
+	other := m.hosts[1-active]
+	if !ready || other == nil || !m.onHost(u, other) {
+		return u
+	}
+	to := m.hosts[active]
+	rewritten := *u
+	rewritten.Scheme, rewritten.Host = to.Scheme, to.Host
+	rewritten.Path = to.Path + strings.TrimPrefix(u.Path, other.Path)
+	rewritten.RawPath = ""
+	return &rewritten
+}
+
+// RewriteURL returns the URL on the active host if it is on the other one.
+func (m *hostMonitor) RewriteURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return m.rewrite(u).String()
+}
+
+// IsServerURL returns whether the URL is on either hostname.
+func (m *hostMonitor) IsServerURL(u *url.URL) bool {
+	for _, host := range m.hosts {
+		if host != nil && u.Host == host.Host {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *hostMonitor) onHost(u, host *url.URL) bool {
+	return u.Scheme == host.Scheme && u.Host == host.Host && strings.HasPrefix(u.Path, host.Path)
+}
+
+// wrap returns a transport that sends requests to the active host,
+// and fails over to the other host when a request can't be sent.
+func (m *hostMonitor) wrap(base http.RoundTripper) http.RoundTripper {
+	return &failoverTransport{base: base, m: m}
+}
+
+type failoverTransport struct {
+	base http.RoundTripper
+	m    *hostMonitor
+}
+
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.roundTripActive(req)
+	if err == nil || req.Context().Err() != nil {
+		return resp, err
+	}
+	// the active host may have gone away (e.g. after leaving the LAN);
+	// retry on the other host if it is reachable
+	m := t.m
+	m.mu.Lock()
+	ready, other := m.ready, 1-m.active
+	m.mu.Unlock()
+	if !ready {
+		return nil, err
+	}
+	if m.hosts[other] == nil || (req.Body != nil && req.GetBody == nil) {
+		m.CheckSoon()
+		return nil, err
+	}
+	latency, pingErr := m.ping(req.Context(), m.hosts[other])
+	if pingErr != nil {
+		m.CheckSoon()
+		return nil, err
+	}
+	m.setStatus(ConnectionStatus{State: ConnectionOK, Hostname: m.hostname(other), Latency: latency}, other)
+	return t.roundTripActive(req)
+}
+
+func (t *failoverTransport) roundTripActive(req *http.Request) (*http.Response, error) {
+	u := t.m.rewrite(req.URL)
+	if u == req.URL {
+		return t.base.RoundTrip(req)
+	}
+	r := req.Clone(req.Context())
+	r.URL = u
+	r.Host = ""
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	return t.base.RoundTrip(r)
+}
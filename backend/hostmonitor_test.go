@@ -0,0 +1,71 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHostMonitor_Failover(t *testing.T) {
+	var lanHits, vpnHits atomic.Int32
+	lan := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { lanHits.Add(1) }))
+	vpn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { vpnHits.Add(1) }))
+	defer vpn.Close()
+
+	base := newHTTPClient(0, "", false).Transport
+	m := newHostMonitor(lan.URL+"/music", vpn.URL, base)
+	m.activate(0)
+	var changes []ConnectionStatus
+	m.onChange = func(s ConnectionStatus, hostChanged bool) {
+		if hostChanged {
+			changes = append(changes, s)
+		}
+	}
+	cli := &http.Client{Transport: m.wrap(base)}
+
+	resp, err := cli.Get(lan.URL + "/music/rest/ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if lanHits.Load() != 1 || vpnHits.Load() != 0 {
+		t.Fatalf("expected request on active host, got lan=%d vpn=%d", lanHits.Load(), vpnHits.Load())
+	}
+
+	// leaving the LAN: requests to it fail over to the other host
+	lan.Close()
+	resp, err = cli.Get(lan.URL + "/music/rest/ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Request.URL.String() != vpn.URL+"/rest/ping" {
+		t.Errorf("request sent to %s", resp.Request.URL)
+	}
+	if len(changes) != 1 || changes[0].Hostname != vpn.URL || changes[0].State != ConnectionOK {
+		t.Errorf("expected switch to %s, got %+v", vpn.URL, changes)
+	}
+	if got := m.RewriteURL(lan.URL + "/music/rest/stream?id=1"); got != vpn.URL+"/rest/stream?id=1" {
+		t.Errorf("RewriteURL: got %s", got)
+	}
+
+	// neither host reachable
+	vpn.Close()
+	if s := m.checkNow(context.Background()); s.State != ConnectionReconnecting {
+		t.Errorf("expected reconnecting state, got %+v", s)
+	}
+}
+
+func TestHostMonitor_NotReady(t *testing.T) {
+	m := newHostMonitor("http://lan:4533", "https://music.example.com", nil)
+	// before login has chosen a host, each client keeps its own
+	if got := m.RewriteURL("https://music.example.com/rest/ping"); got != "https://music.example.com/rest/ping" {
+		t.Errorf("got %s", got)
+	}
+	m.activate(0)
+	if got := m.RewriteURL("https://music.example.com/rest/ping"); got != "http://lan:4533/rest/ping" {
+		t.Errorf("got %s", got)
+	}
+}
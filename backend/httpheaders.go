@@ -0,0 +1,156 @@
+package backend
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dweymouth/supersonic/sharedutil"
+)
+
+// ErrInvalidCookieFile is returned when connecting to a server
+// whose cookie file can't be read.
+var ErrInvalidCookieFile = errors.New("invalid cookie file")
+
+// headerTransport adds the server's custom headers to each request.
+type headerTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// setServerHTTPSettings adds the custom headers and cookie jar to a client
+// created by newHTTPClient. It must be called after setTLSConfig.
+func setServerHTTPSettings(cli *http.Client, headers map[string]string, jar http.CookieJar) {
+	if len(headers) > 0 {
+		cli.Transport = &headerTransport{base: cli.Transport, headers: headers}
+	}
+	cli.Jar = jar
+}
+
+// ParseHeaderLines parses custom HTTP headers written one "Name: value" per line.
+func ParseHeaderLines(text string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for line := range strings.Lines(text) {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || !isHeaderName(name) {
+			return nil, fmt.Errorf("invalid header %q", line)
+		}
+		headers[textproto.CanonicalMIMEHeaderKey(name)] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// FormatHeaderLines formats custom HTTP headers one "Name: value" per line.
+func FormatHeaderLines(headers map[string]string) string {
+	var sb strings.Builder
+	for _, k := range slices.Sorted(maps.Keys(headers)) {
+		fmt.Fprintf(&sb, "%s: %s\n", k, headers[k])
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func isHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if c <= ' ' || c >= 0x7f || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// loadCookieFile adds the cookies of a browser cookie file
+// in the Netscape cookies.txt format to the jar.
+func loadCookieFile(jar http.CookieJar, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return readCookies(jar, f)
+}
+
+func readCookies(jar http.CookieJar, r io.Reader) error {
+	now := time.Now()
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		httpOnly := strings.HasPrefix(line, "#HttpOnly_")
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// domain, include subdomains, path, secure, expiry, name, value
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return fmt.Errorf("invalid cookie on line %d", lineNum)
+		}
+		expiry, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid cookie expiry on line %d", lineNum)
+		}
+		c := &http.Cookie{
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+			Name:     fields[5],
+			Value:    fields[6],
+		}
+		if expiry > 0 {
+			if c.Expires = time.Unix(expiry, 0); c.Expires.Before(now) {
+				continue
+			}
+		}
+		host := strings.TrimPrefix(fields[0], ".")
+		if strings.EqualFold(fields[1], "TRUE") {
+			c.Domain = host
+		}
+		scheme := "http"
+		if c.Secure {
+			scheme = "https"
+		}
+		jar.SetCookies(&url.URL{Scheme: scheme, Host: host, Path: c.Path}, []*http.Cookie{c})
+	}
+	return scanner.Err()
+}
+
+// streamHTTPHeaders returns the headers for mpv to stream the server with,
+// as "Name: value" fields: the custom headers and the server's cookies.
+func streamHTTPHeaders(hostname string, headers map[string]string, jar http.CookieJar) []string {
+	var fields []string
+	for _, k := range slices.Sorted(maps.Keys(headers)) {
+		fields = append(fields, k+": "+headers[k])
+	}
+	if u, err := url.Parse(hostname); err == nil && jar != nil {
+		if cookies := jar.Cookies(u); len(cookies) > 0 {
+			fields = append(fields, "Cookie: "+strings.Join(sharedutil.MapSlice(cookies, func(c *http.Cookie) string {
+				return c.Name + "=" + c.Value
+			}), "; "))
+		}
+	}
+	return fields
+}
@@ -0,0 +1,77 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseHeaderLines(t *testing.T) {
+	headers, err := ParseHeaderLines("remote-user: alice\n\n  X-Token :a,b: c \n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"Remote-User": "alice", "X-Token": "a,b: c"}
+	if !reflect.DeepEqual(headers, want) {
+		t.Errorf("got %v, want %v", headers, want)
+	}
+	if got := FormatHeaderLines(headers); got != "Remote-User: alice\nX-Token: a,b: c" {
+		t.Errorf("FormatHeaderLines: got %q", got)
+	}
+	for _, text := range []string{"no colon", ": value", "Bad Name: value"} {
+		if _, err := ParseHeaderLines(text); err == nil {
+			t.Errorf("ParseHeaderLines(%q): expected error", text)
+		}
+	}
+}
+
+func TestReadCookies(t *testing.T) {
+	file := strings.Join([]string{
+		"# Netscape HTTP Cookie File",
+		".example.com\tTRUE\t/\tTRUE\t0\tsession\tabc",
+		"#HttpOnly_music.example.com\tFALSE\t/\tFALSE\t4102444800\tauth\tdef",
+		"music.example.com\tFALSE\t/\tFALSE\t1\texpired\tghi",
+		"",
+	}, "\n")
+	jar, _ := cookiejar.New(nil)
+	if err := readCookies(jar, strings.NewReader(file)); err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.Parse("https://music.example.com/rest/ping")
+	got := streamHTTPHeaders(u.String(), map[string]string{"Remote-User": "alice"}, jar)
+	want := []string{"Remote-User: alice", "Cookie: session=abc; auth=def"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got headers %q, want %q", got, want)
+	}
+
+	if err := readCookies(jar, strings.NewReader("example.com\tTRUE\t/")); err == nil {
+		t.Error("expected error for malformed cookie line")
+	}
+}
+
+func TestHeaderTransport(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer srv.Close()
+
+	jar, _ := cookiejar.New(nil)
+	u, _ := url.Parse(srv.URL)
+	jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "abc"}})
+	cli := newHTTPClient(0, "", false)
+	setServerHTTPSettings(cli, map[string]string{"Remote-User": "alice"}, jar)
+	resp, err := cli.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got.Get("Remote-User") != "alice" || got.Get("Cookie") != "session=abc" {
+		t.Errorf("headers not sent: %v", got)
+	}
+}
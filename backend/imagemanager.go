@@ -7,8 +7,9 @@ import (
 	"fmt"
 	"image"
 	"image/jpeg"
+	"io"
 	"io/fs"
-	"log"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
@@ -52,7 +53,7 @@ type ImageManager struct {
 // NewImageManager returns a new ImageManager.
 func NewImageManager(ctx context.Context, s *ServerManager, baseCacheDir string) *ImageManager {
 	if err := configdir.MakePath(baseCacheDir); err != nil {
-		log.Println("failed to create album cover cache dir")
+		cacheLog.Error("failed to create album cover cache dir")
 		baseCacheDir = ""
 	}
 	i := &ImageManager{
@@ -217,11 +218,11 @@ func (i *ImageManager) ensureArtistCoverCacheDir() string {
 
 func (i *ImageManager) fetchRemoteArtistImage(url string) (image.Image, error) {
 	i.serverFetchSema <- struct{}{} // acquire
-	res, err := fyne.LoadResourceFromURLString(url)
+	content, err := i.fetchURL(url)
 	<-i.serverFetchSema // release
 
 	if err == nil {
-		im, _, err := image.Decode(bytes.NewReader(res.Content()))
+		im, _, err := image.Decode(bytes.NewReader(content))
 		if err == nil {
 			return im, nil
 		}
@@ -230,6 +231,27 @@ func (i *ImageManager) fetchRemoteArtistImage(url string) (image.Image, error) {
 	return nil, err
 }
 
+// fetchURL fetches images hosted on the server with its TLS settings,
+// and images from other hosts (e.g. artist image providers) as usual.
+func (i *ImageManager) fetchURL(url string) ([]byte, error) {
+	if !i.s.IsServerURL(url) {
+		res, err := fyne.LoadResourceFromURLString(url)
+		if err != nil {
+			return nil, err
+		}
+		return res.Content(), nil
+	}
+	resp, err := i.s.HTTPClientForURL(url).Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status: %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
 func (i *ImageManager) fetchAndCacheCoverFromDiskOrServer(ctx context.Context, coverID string, ttl time.Duration, cb func(image.Image, error)) (image.Image, error) {
 	// on disc cache
 	if i.ensureCoverCacheDir() != "" {
@@ -331,7 +353,7 @@ func (i *ImageManager) writeJpeg(img image.Image, path string) error {
 	if err == nil {
 		defer f.Close()
 		if err := jpeg.Encode(f, img, nil /*options*/); err != nil {
-			log.Printf("failed to cache image: %s", err.Error())
+			cacheLog.Error("failed to cache image", "err", err)
 			return err
 		}
 	}
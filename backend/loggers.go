@@ -0,0 +1,17 @@
+package backend
+
+import "github.com/dweymouth/supersonic/backend/logging"
+
+// loggers of the subsystems in this package
+var (
+	appLog      = logging.For(logging.App)
+	serverLog   = logging.For(logging.Server)
+	playbackLog = logging.For(logging.Playback)
+	lyricsLog   = logging.For(logging.Lyrics)
+	autoplayLog = logging.For(logging.Autoplay)
+	waveformLog = logging.For(logging.Waveform)
+	cacheLog    = logging.For(logging.Cache)
+	searchLog   = logging.For(logging.Search)
+	eqLog       = logging.For(logging.Equalizer)
+	dlnaLog     = logging.For(logging.DLNA)
+)
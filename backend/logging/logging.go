@@ -0,0 +1,129 @@
+// Package logging provides leveled, structured loggers for each subsystem
+// of the app, all writing through a single handler set up at startup.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Subsystems with their own log level.
+const (
+	App           = "app"
+	Server        = "server"
+	MediaProvider = "mediaprovider"
+	Playback      = "playback"
+	Player        = "player"
+	Lyrics        = "lyrics"
+	Autoplay      = "autoplay"
+	Waveform      = "waveform"
+	Cache         = "cache"
+	Search        = "search"
+	Equalizer     = "equalizer"
+	DLNA          = "dlna"
+)
+
+// Subsystems lists the subsystems that can be configured.
+var Subsystems = []string{App, Server, MediaProvider, Playback, Player,
+	Lyrics, Autoplay, Waveform, Cache, Search, Equalizer, DLNA}
+
+var (
+	root atomic.Pointer[slog.Handler]
+
+	levelsLock      sync.RWMutex
+	defaultLevel    = slog.LevelInfo
+	subsystemLevels = map[string]slog.Level{}
+)
+
+func init() {
+	var h slog.Handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})
+	root.Store(&h)
+}
+
+// Init sends all logs to w, including those of the standard log package,
+// which are logged at the info level of the App subsystem.
+func Init(w io.Writer) {
+	var h slog.Handler = slog.NewTextHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})
+	root.Store(&h)
+	slog.SetDefault(For(App))
+}
+
+// SetLevels sets the default log level, and the levels of subsystems that
+// differ from it. Levels are "debug", "info", "warn" or "error"; invalid
+// or empty levels are ignored.
+func SetLevels(level string, subsystems map[string]string) {
+	levelsLock.Lock()
+	defer levelsLock.Unlock()
+	defaultLevel = slog.LevelInfo
+	if l, ok := ParseLevel(level); ok {
+		defaultLevel = l
+	}
+	subsystemLevels = make(map[string]slog.Level, len(subsystems))
+	for s, level := range subsystems {
+		if l, ok := ParseLevel(level); ok {
+			subsystemLevels[s] = l
+		}
+	}
+}
+
+// ParseLevel parses a level name, case-insensitively.
+func ParseLevel(level string) (slog.Level, bool) {
+	var l slog.Level
+	if level == "" || l.UnmarshalText([]byte(strings.TrimSpace(level))) != nil {
+		return 0, false
+	}
+	return l, true
+}
+
+func levelOf(subsystem string) slog.Level {
+	levelsLock.RLock()
+	defer levelsLock.RUnlock()
+	if l, ok := subsystemLevels[subsystem]; ok {
+		return l
+	}
+	return defaultLevel
+}
+
+// For returns the logger of a subsystem. It may be called before Init,
+// e.g. to initialize package variables.
+func For(subsystem string) *slog.Logger {
+	return slog.New(&subsystemHandler{subsystem: subsystem})
+}
+
+// subsystemHandler filters records by the level of its subsystem,
+// and passes them to the root handler tagged with the subsystem.
+type subsystemHandler struct {
+	subsystem string
+	// WithAttrs and WithGroup calls to apply to the root handler
+	with []func(slog.Handler) slog.Handler
+}
+
+func (h *subsystemHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= levelOf(h.subsystem)
+}
+
+func (h *subsystemHandler) Handle(ctx context.Context, r slog.Record) error {
+	handler := (*root.Load()).WithAttrs([]slog.Attr{slog.String("subsystem", h.subsystem)})
+	for _, with := range h.with {
+		handler = with(handler)
+	}
+	return handler.Handle(ctx, r)
+}
+
+func (h *subsystemHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.withFunc(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+}
+
+func (h *subsystemHandler) WithGroup(name string) slog.Handler {
+	return h.withFunc(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+}
+
+func (h *subsystemHandler) withFunc(f func(slog.Handler) slog.Handler) slog.Handler {
+	with := append(h.with[:len(h.with):len(h.with)], f)
+	return &subsystemHandler{subsystem: h.subsystem, with: with}
+}
@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSubsystemLevels(t *testing.T) {
+	var buf bytes.Buffer
+	Init(&buf)
+	defer SetLevels("", nil)
+	SetLevels("warn", map[string]string{Playback: "debug", Lyrics: "bogus"})
+
+	For(Playback).Debug("playback debug")
+	For(Lyrics).Info("lyrics info")
+	For(Lyrics).Warn("lyrics warn", "track", "abc")
+	out := buf.String()
+	if !strings.Contains(out, "playback debug") || !strings.Contains(out, "subsystem=playback") {
+		t.Errorf("missing playback debug log: %q", out)
+	}
+	if strings.Contains(out, "lyrics info") {
+		t.Errorf("lyrics info logged below the default level: %q", out)
+	}
+	if !strings.Contains(out, "lyrics warn") || !strings.Contains(out, "track=abc") {
+		t.Errorf("missing lyrics warn log: %q", out)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	f, err := OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.Close()
+
+	for name, want := range map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	} {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Errorf("%s: got %q, want %q", name, b, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 backups")
+	}
+}
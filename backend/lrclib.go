@@ -7,7 +7,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"net/url"
 	"os"
@@ -61,7 +60,7 @@ func (l *LrcLibFetcher) FetchLrcLibLyrics(name, artist, album string, durationSe
 	// Try to write it into cache
 	err = writeCachedLyrics(cacheFilePath, lyrics)
 	if err != nil {
-		log.Printf("Failed to serialize fetched lyrics: %s", err)
+		lyricsLog.Error("failed to serialize fetched lyrics", "err", err)
 	}
 
 	return lyrics, nil
@@ -121,7 +120,7 @@ func (l *LrcLibFetcher) getLrclibUrl() string {
 			return u
 		}
 
-		log.Printf("Invalid LrcLib URL passed (err: %s). Falling back to default", err)
+		lyricsLog.Warn("invalid LrcLib URL, falling back to default", "err", err)
 	}
 
 	return "https://lrclib.net/api/get"
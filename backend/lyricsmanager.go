@@ -2,7 +2,6 @@ package backend
 
 import (
 	"context"
-	"log"
 	"sync"
 
 	"github.com/dweymouth/supersonic/backend/mediaprovider"
@@ -59,7 +58,7 @@ func (lm *LyricsManager) fetchLyrics(ctx context.Context, song *mediaprovider.Tr
 	var err error
 	if lp, ok := lm.sm.Server.(mediaprovider.LyricsProvider); ok {
 		if lyrics, err = lp.GetLyrics(song); err != nil {
-			log.Printf("Error fetching lyrics: %v", err)
+			lyricsLog.Error("error fetching lyrics", "err", err)
 		}
 	}
 	if lyrics == nil && lm.lrclib != nil {
@@ -69,7 +68,7 @@ func (lm *LyricsManager) fetchLyrics(ctx context.Context, song *mediaprovider.Tr
 		}
 		lyrics, err = lm.lrclib.FetchLrcLibLyrics(song.Title, artist, song.Album, int(song.Duration.Seconds()))
 		if err != nil {
-			log.Println(err.Error())
+			lyricsLog.Error("error fetching lyrics from LrcLib", "err", err)
 		}
 	}
 	select {
@@ -0,0 +1,298 @@
+package dlna
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const contentDirectoryServicePrefix = "urn:schemas-upnp-org:service:ContentDirectory:"
+
+// paths of the device description for common media servers,
+// tried when the server URL is given without one
+var descriptionPaths = []string{
+	"/rootDesc.xml",    // MiniDLNA / ReadyMedia
+	"/description.xml", // Gerbera
+	"/dms/description.xml",
+	"/description/fetch", // Serviio
+}
+
+var errNoContentDirectory = errors.New("dlna: device has no ContentDirectory service")
+
+// contentDirectoryClient makes SOAP requests to a
+// UPnP media server's ContentDirectory service.
+type contentDirectoryClient struct {
+	httpClient   *http.Client
+	friendlyName string
+	serviceType  string
+	controlURL   string
+}
+
+type deviceDescription struct {
+	URLBase string `xml:"URLBase"`
+	Device  device `xml:"device"`
+}
+
+type device struct {
+	DeviceType   string `xml:"deviceType"`
+	FriendlyName string `xml:"friendlyName"`
+	ServiceList  struct {
+		Services []struct {
+			Type       string `xml:"serviceType"`
+			ControlURL string `xml:"controlURL"`
+		} `xml:"service"`
+	} `xml:"serviceList"`
+	DeviceList struct {
+		Devices []device `xml:"device"`
+	} `xml:"deviceList"`
+}
+
+// newContentDirectoryClient fetches the device description from the URL
+// (or from the common description paths on its host if it has no path)
+// and finds the ContentDirectory service of the media server.
+func newContentDirectoryClient(ctx context.Context, httpClient *http.Client, serverURL string) (*contentDirectoryClient, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	var descURLs []string
+	if u.Path == "" || u.Path == "/" {
+		for _, p := range descriptionPaths {
+			descURLs = append(descURLs, u.Scheme+"://"+u.Host+p)
+		}
+	} else {
+		descURLs = []string{serverURL}
+	}
+
+	for _, descURL := range descURLs {
+		c, err := fetchContentDirectory(ctx, httpClient, descURL)
+		if err == nil {
+			return c, nil
+		}
+		if ctx.Err() != nil || len(descURLs) == 1 {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("dlna: no media server description found at %s", serverURL)
+}
+
+func fetchContentDirectory(ctx context.Context, httpClient *http.Client, descURL string) (*contentDirectoryClient, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, descURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("dlna: GET %s: %s", descURL, resp.Status)
+	}
+	var desc deviceDescription
+	if err := xml.NewDecoder(resp.Body).Decode(&desc); err != nil {
+		return nil, fmt.Errorf("dlna: invalid device description: %w", err)
+	}
+
+	base, _ := url.Parse(descURL)
+	if desc.URLBase != "" {
+		if b, err := url.Parse(desc.URLBase); err == nil {
+			base = b
+		}
+	}
+	c := findContentDirectory(desc.Device, base)
+	if c == nil {
+		return nil, errNoContentDirectory
+	}
+	c.httpClient = httpClient
+	return c, nil
+}
+
+// the ContentDirectory may be on the root device or an embedded one
+func findContentDirectory(d device, base *url.URL) *contentDirectoryClient {
+	for _, s := range d.ServiceList.Services {
+		if strings.HasPrefix(s.Type, contentDirectoryServicePrefix) {
+			ctrl, err := url.Parse(s.ControlURL)
+			if err != nil {
+				continue
+			}
+			return &contentDirectoryClient{
+				friendlyName: d.FriendlyName,
+				serviceType:  s.Type,
+				controlURL:   base.ResolveReference(ctrl).String(),
+			}
+		}
+	}
+	for _, embedded := range d.DeviceList.Devices {
+		if c := findContentDirectory(embedded, base); c != nil {
+			return c
+		}
+	}
+	return nil
+}
+
+type soapEnvelope struct {
+	Body struct {
+		Fault *struct {
+			String string `xml:"faultstring"`
+			Detail struct {
+				Error struct {
+					Code        int    `xml:"errorCode"`
+					Description string `xml:"errorDescription"`
+				} `xml:"UPnPError"`
+			} `xml:"detail"`
+		} `xml:"Fault"`
+		Response struct {
+			Result         string `xml:"Result"`
+			NumberReturned int    `xml:"NumberReturned"`
+			TotalMatches   int    `xml:"TotalMatches"`
+			SearchCaps     string `xml:"SearchCaps"`
+		} `xml:",any"`
+	} `xml:"Body"`
+}
+
+// call invokes the SOAP action with the arguments, given as name/value pairs in order.
+func (c *contentDirectoryClient) call(ctx context.Context, action string, args ...string) (*soapEnvelope, error) {
+	var body bytes.Buffer
+	body.WriteString(`<?xml version="1.0" encoding="utf-8"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">` +
+		`<s:Body><u:` + action + ` xmlns:u="` + c.serviceType + `">`)
+	for i := 0; i+1 < len(args); i += 2 {
+		fmt.Fprintf(&body, "<%s>%s</%s>", args[i], html.EscapeString(args[i+1]), args[i])
+	}
+	body.WriteString(`</u:` + action + `></s:Body></s:Envelope>`)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.controlURL, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", `"`+c.serviceType+"#"+action+`"`)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var env soapEnvelope
+	if err := xml.Unmarshal(b, &env); err != nil {
+		return nil, fmt.Errorf("dlna: %s: invalid response: %w", action, err)
+	}
+	if f := env.Body.Fault; f != nil {
+		if e := f.Detail.Error; e.Code != 0 {
+			return nil, fmt.Errorf("dlna: %s failed: %d %s", action, e.Code, e.Description)
+		}
+		return nil, fmt.Errorf("dlna: %s failed: %s", action, f.String)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("dlna: %s failed: %s", action, resp.Status)
+	}
+	return &env, nil
+}
+
+// browseChildren returns one page of the children of the container,
+// and the total number of children.
+func (c *contentDirectoryClient) browseChildren(ctx context.Context, objectID string, start, count int) (*didlLite, int, error) {
+	env, err := c.call(ctx, "Browse",
+		"ObjectID", objectID,
+		"BrowseFlag", "BrowseDirectChildren",
+		"Filter", "*",
+		"StartingIndex", strconv.Itoa(start),
+		"RequestedCount", strconv.Itoa(count),
+		"SortCriteria", "",
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	didl, err := parseDIDL(env.Body.Response.Result)
+	return didl, env.Body.Response.TotalMatches, err
+}
+
+// search returns one page of the objects matching the criteria,
+// and the total number of matches.
+func (c *contentDirectoryClient) search(ctx context.Context, criteria string, start, count int) (*didlLite, int, error) {
+	env, err := c.call(ctx, "Search",
+		"ContainerID", "0",
+		"SearchCriteria", criteria,
+		"Filter", "*",
+		"StartingIndex", strconv.Itoa(start),
+		"RequestedCount", strconv.Itoa(count),
+		"SortCriteria", "",
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	didl, err := parseDIDL(env.Body.Response.Result)
+	return didl, env.Body.Response.TotalMatches, err
+}
+
+func (c *contentDirectoryClient) searchCapabilities(ctx context.Context) ([]string, error) {
+	env, err := c.call(ctx, "GetSearchCapabilities")
+	if err != nil {
+		return nil, err
+	}
+	caps := strings.Split(env.Body.Response.SearchCaps, ",")
+	for i := range caps {
+		caps[i] = strings.TrimSpace(caps[i])
+	}
+	return caps, nil
+}
+
+// DIDL-Lite metadata of ContentDirectory objects.
+// Elements are matched by local name regardless of namespace (dc:, upnp:),
+// and numeric values are left as strings since servers often leave them empty.
+type didlLite struct {
+	Containers []didlObject `xml:"container"`
+	Items      []didlObject `xml:"item"`
+}
+
+type didlObject struct {
+	ID       string `xml:"id,attr"`
+	ParentID string `xml:"parentID,attr"`
+	RefID    string `xml:"refID,attr"`
+	Title    string `xml:"title"`
+	Class    string `xml:"class"`
+	Creator  string `xml:"creator"`
+	Artists  []struct {
+		Name string `xml:",chardata"`
+		Role string `xml:"role,attr"`
+	} `xml:"artist"`
+	Album       string   `xml:"album"`
+	Genres      []string `xml:"genre"`
+	TrackNumber string   `xml:"originalTrackNumber"`
+	Date        string   `xml:"date"`
+	AlbumArtURI string   `xml:"albumArtURI"`
+	Res         []struct {
+		URL             string `xml:",chardata"`
+		ProtocolInfo    string `xml:"protocolInfo,attr"`
+		Size            string `xml:"size,attr"`
+		Duration        string `xml:"duration,attr"` // H+:MM:SS[.F+]
+		Bitrate         string `xml:"bitrate,attr"`  // bytes per second
+		SampleFrequency string `xml:"sampleFrequency,attr"`
+		Channels        string `xml:"nrAudioChannels,attr"`
+		BitsPerSample   string `xml:"bitsPerSample,attr"`
+	} `xml:"res"`
+}
+
+func parseDIDL(result string) (*didlLite, error) {
+	var d didlLite
+	if result == "" {
+		return &d, nil
+	}
+	if err := xml.Unmarshal([]byte(result), &d); err != nil {
+		return nil, fmt.Errorf("dlna: invalid DIDL-Lite: %w", err)
+	}
+	return &d, nil
+}
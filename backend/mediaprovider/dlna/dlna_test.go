@@ -0,0 +1,153 @@
+package dlna
+
+import (
+	"html"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:MediaServer:1</deviceType>
+    <friendlyName>Test Server</friendlyName>
+    <serviceList>
+      <service>
+        <serviceType>urn:schemas-upnp-org:service:ContentDirectory:1</serviceType>
+        <controlURL>/ctl/ContentDir</controlURL>
+      </service>
+    </serviceList>
+  </device>
+</root>`
+
+const didlHeader = `<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/">`
+
+// the root has a music album container, and a folder container
+// holding a reference to one of the album's tracks
+var testContainers = map[string]string{
+	"0": didlHeader + `
+<container id="1" parentID="0"><dc:title>Album</dc:title><upnp:class>object.container.album.musicAlbum</upnp:class><upnp:albumArtURI>http://art/1.jpg</upnp:albumArtURI></container>
+<container id="2" parentID="0"><dc:title>Folder</dc:title><upnp:class>object.container.storageFolder</upnp:class></container>
+</DIDL-Lite>`,
+	"1": didlHeader + `
+<item id="1$1" parentID="1"><dc:title>Second</dc:title><upnp:class>object.item.audioItem.musicTrack</upnp:class>
+  <upnp:artist>Band</upnp:artist><upnp:artist role="AlbumArtist">Band</upnp:artist><upnp:album>Album</upnp:album>
+  <upnp:genre>Rock</upnp:genre><upnp:originalTrackNumber>2</upnp:originalTrackNumber><dc:date>2001-05-01</dc:date>
+  <res protocolInfo="http-get:*:audio/flac:*" duration="0:03:30.500" bitrate="40000" size="123">http://media/2.flac</res></item>
+<item id="1$0" parentID="1"><dc:title>First</dc:title><upnp:class>object.item.audioItem.musicTrack</upnp:class>
+  <upnp:artist>Band</upnp:artist><upnp:artist role="AlbumArtist">Band</upnp:artist><upnp:album>Album</upnp:album>
+  <upnp:genre>Rock</upnp:genre><upnp:originalTrackNumber>1</upnp:originalTrackNumber>
+  <res protocolInfo="http-get:*:audio/flac:*" duration="0:02:00" bitrate="">http://media/1.flac</res></item>
+</DIDL-Lite>`,
+	"2": didlHeader + `
+<item id="2$0" parentID="2" refID="1$0"><dc:title>First</dc:title><upnp:class>object.item.audioItem.musicTrack</upnp:class>
+  <res protocolInfo="http-get:*:audio/flac:*">http://media/1.flac</res></item>
+<item id="2$1" parentID="2"><dc:title>Cover</dc:title><upnp:class>object.item.imageItem.photo</upnp:class>
+  <res protocolInfo="http-get:*:image/jpeg:*">http://media/cover.jpg</res></item>
+</DIDL-Lite>`,
+}
+
+func newFakeMediaServer(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rootDesc.xml":
+			io.WriteString(w, testDescription)
+			return
+		case "/ctl/ContentDir":
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		body := string(b)
+		var resp string
+		switch {
+		case strings.Contains(r.Header.Get("SOAPAction"), "#GetSearchCapabilities"):
+			resp = `<u:GetSearchCapabilitiesResponse><SearchCaps></SearchCaps></u:GetSearchCapabilitiesResponse>`
+		case strings.Contains(r.Header.Get("SOAPAction"), "#Browse"):
+			id := body[strings.Index(body, "<ObjectID>")+len("<ObjectID>") : strings.Index(body, "</ObjectID>")]
+			didl, ok := testContainers[html.UnescapeString(id)]
+			if !ok {
+				w.WriteHeader(http.StatusInternalServerError)
+				io.WriteString(w, `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><s:Fault><faultstring>UPnPError</faultstring>`+
+					`<detail><UPnPError><errorCode>701</errorCode><errorDescription>No such object</errorDescription></UPnPError></detail></s:Fault></s:Body></s:Envelope>`)
+				return
+			}
+			n := strings.Count(didl, "<item") + strings.Count(didl, "<container")
+			resp = `<u:BrowseResponse><Result>` + html.EscapeString(didl) + `</Result>` +
+				`<NumberReturned>` + strconv.Itoa(n) + `</NumberReturned><TotalMatches>` + strconv.Itoa(n) + `</TotalMatches></u:BrowseResponse>`
+		}
+		io.WriteString(w, `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>`+resp+`</s:Body></s:Envelope>`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestLoginAndLibrary(t *testing.T) {
+	srv := newFakeMediaServer(t)
+	s := &DLNAServer{HTTPClient: srv.Client(), URL: srv.URL}
+	if resp := s.Login("", ""); resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if s.cd.friendlyName != "Test Server" || s.cd.controlURL != srv.URL+"/ctl/ContentDir" {
+		t.Errorf("unexpected ContentDirectory client %+v", s.cd)
+	}
+	mp := s.MediaProvider().(*DLNAMediaProvider)
+
+	lib, err := mp.library()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lib.tracks) != 2 {
+		t.Fatalf("expected referenced track and image to be skipped, got %d tracks", len(lib.tracks))
+	}
+	if len(lib.albums) != 1 || len(lib.artists) != 1 || len(lib.genres) != 1 {
+		t.Fatalf("expected 1 album, artist and genre, got %d, %d, %d", len(lib.albums), len(lib.artists), len(lib.genres))
+	}
+
+	album, err := mp.GetAlbum(lib.albums[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if album.Name != "Album" || album.ArtistNames[0] != "Band" || album.YearOrZero() != 2001 || album.CoverArtID == "" {
+		t.Errorf("unexpected album %+v", album.Album)
+	}
+	if len(album.Tracks) != 2 || album.Tracks[0].Title != "First" {
+		t.Fatalf("expected album tracks sorted by track number")
+	}
+	// returned items must not share slices with the library
+	album.ArtistNames[0] = "Changed"
+	album.Tracks[0].ArtistNames[0] = "Changed"
+	if lib.albums[0].ArtistNames[0] != "Band" || lib.tracksByID[album.Tracks[0].ID].ArtistNames[0] == "Changed" {
+		t.Error("expected modifying a returned album to leave the library unchanged")
+	}
+	second := album.Tracks[1]
+	if second.Duration != 3*time.Minute+30500*time.Millisecond || second.BitRate != 320 || second.ContentType != "audio/flac" {
+		t.Errorf("unexpected track metadata %+v", second)
+	}
+	if u, _ := mp.GetStreamURL(second.ID, nil, false); u != "http://media/2.flac" {
+		t.Errorf("expected res URL as stream URL, got %s", u)
+	}
+
+	results, err := mp.SearchAll("first", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Name != "First" {
+		t.Errorf("unexpected search results %v", results)
+	}
+}
+
+func TestLoginNoServer(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	s := &DLNAServer{HTTPClient: srv.Client(), URL: srv.URL}
+	if resp := s.Login("", ""); resp.Error == nil {
+		t.Error("expected error logging in to a server with no device description")
+	}
+}
@@ -0,0 +1,610 @@
+package dlna
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math/rand"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boxes-ltd/imaging"
+	"github.com/deluan/sanitize"
+	"github.com/dweymouth/supersonic/backend/logging"
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+	"github.com/dweymouth/supersonic/backend/mediaprovider/helpers"
+	"github.com/dweymouth/supersonic/sharedutil"
+)
+
+var logger = logging.For(logging.DLNA)
+
+// ContentDirectory is read-only and has no user data
+var errUnsupported = errors.New("not supported by DLNA media servers")
+
+// indexing a large library by browsing can take a while
+const libraryLoadTimeout = 5 * time.Minute
+
+// DLNAServer is a UPnP/DLNA media server (e.g. MiniDLNA, Gerbera)
+// whose ContentDirectory is browsed as a library.
+type DLNAServer struct {
+	HTTPClient *http.Client
+	// URL of the device description, or of the server's host
+	// to try the description paths of common servers
+	URL string
+
+	cd *contentDirectoryClient
+}
+
+// Login checks that the server has a ContentDirectory that can be browsed.
+// DLNA servers have no authentication, so username and password are ignored.
+func (d *DLNAServer) Login(_, _ string) mediaprovider.LoginResponse {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cd, err := newContentDirectoryClient(ctx, d.HTTPClient, d.URL)
+	if err != nil {
+		return mediaprovider.LoginResponse{Error: err}
+	}
+	if _, _, err := cd.browseChildren(ctx, "0", 0, 1); err != nil {
+		return mediaprovider.LoginResponse{Error: err}
+	}
+	d.cd = cd
+	return mediaprovider.LoginResponse{}
+}
+
+func (d *DLNAServer) MediaProvider() mediaprovider.MediaProvider {
+	return &DLNAMediaProvider{cd: d.cd}
+}
+
+var _ mediaprovider.MediaProvider = (*DLNAMediaProvider)(nil)
+
+type DLNAMediaProvider struct {
+	cd              *contentDirectoryClient
+	prefetchCoverCB func(coverArtID string)
+
+	libLock sync.Mutex
+	lib     *library
+}
+
+// library returns the index of the server's library, building it on first use.
+func (d *DLNAMediaProvider) library() (*library, error) {
+	d.libLock.Lock()
+	defer d.libLock.Unlock()
+	if d.lib == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), libraryLoadTimeout)
+		defer cancel()
+		lib, err := loadLibrary(ctx, d.cd)
+		if err != nil {
+			return nil, err
+		}
+		d.lib = lib
+	}
+	return d.lib, nil
+}
+
+func (d *DLNAMediaProvider) SetPrefetchCoverCallback(cb func(coverArtID string)) {
+	d.prefetchCoverCB = cb
+}
+
+func (d *DLNAMediaProvider) prefetchCover(coverArtID string) {
+	if d.prefetchCoverCB != nil && coverArtID != "" {
+		d.prefetchCoverCB(coverArtID)
+	}
+}
+
+func (d *DLNAMediaProvider) GetLibraries() ([]mediaprovider.Library, error) {
+	return nil, nil
+}
+
+func (d *DLNAMediaProvider) SetLibrary(id string) error {
+	return nil
+}
+
+func (d *DLNAMediaProvider) GetTrack(trackID string) (*mediaprovider.Track, error) {
+	lib, err := d.library()
+	if err != nil {
+		return nil, err
+	}
+	tr, ok := lib.tracksByID[trackID]
+	if !ok {
+		return nil, fmt.Errorf("track %s not found", trackID)
+	}
+	return copyOf(tr), nil
+}
+
+func (d *DLNAMediaProvider) GetAlbum(albumID string) (*mediaprovider.AlbumWithTracks, error) {
+	lib, err := d.library()
+	if err != nil {
+		return nil, err
+	}
+	al, ok := lib.albumsByID[albumID]
+	if !ok {
+		return nil, fmt.Errorf("album %s not found", albumID)
+	}
+	return &mediaprovider.AlbumWithTracks{
+		Album:  *copyOf(&al.Album),
+		Tracks: sharedutil.MapSlice(al.Tracks, copyOf),
+	}, nil
+}
+
+func (d *DLNAMediaProvider) GetAlbumInfo(albumID string) (*mediaprovider.AlbumInfo, error) {
+	return &mediaprovider.AlbumInfo{}, nil
+}
+
+func (d *DLNAMediaProvider) GetArtist(artistID string) (*mediaprovider.ArtistWithAlbums, error) {
+	lib, err := d.library()
+	if err != nil {
+		return nil, err
+	}
+	ar, ok := lib.artistsByID[artistID]
+	if !ok {
+		return nil, fmt.Errorf("artist %s not found", artistID)
+	}
+	albums := lib.artistAlbums[artistID]
+	if len(albums) == 0 {
+		// only a track artist - show the albums they appear on
+		for _, tr := range lib.artistTracks[artistID] {
+			al := &lib.albumsByID[tr.AlbumID].Album
+			if !slices.Contains(albums, al) {
+				albums = append(albums, al)
+			}
+		}
+	}
+	return &mediaprovider.ArtistWithAlbums{
+		Artist: *ar,
+		Albums: sharedutil.MapSlice(albums, copyOf),
+	}, nil
+}
+
+func (d *DLNAMediaProvider) GetArtistTracks(artistID string) ([]*mediaprovider.Track, error) {
+	lib, err := d.library()
+	if err != nil {
+		return nil, err
+	}
+	return sharedutil.MapSlice(lib.artistTracks[artistID], copyOf), nil
+}
+
+func (d *DLNAMediaProvider) GetArtistInfo(artistID string) (*mediaprovider.ArtistInfo, error) {
+	return &mediaprovider.ArtistInfo{}, nil
+}
+
+func (d *DLNAMediaProvider) GetPlaylist(playlistID string) (*mediaprovider.PlaylistWithTracks, error) {
+	return nil, errUnsupported
+}
+
+func (d *DLNAMediaProvider) GetCoverArt(coverArtID string, size int) (image.Image, error) {
+	lib, err := d.library()
+	if err != nil {
+		return nil, err
+	}
+	artURL, ok := lib.coverArtURLs[coverArtID]
+	if !ok {
+		return nil, fmt.Errorf("cover art %s not found", coverArtID)
+	}
+	resp, err := d.cd.httpClient.Get(artURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching cover art: %s", resp.Status)
+	}
+	img, _, err := image.Decode(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if b := img.Bounds(); size > 0 && (b.Dx() > size || b.Dy() > size) {
+		img = imaging.Fit(img, size, size, imaging.Lanczos)
+	}
+	return img, nil
+}
+
+func (d *DLNAMediaProvider) AlbumSortOrders() []string {
+	return []string{
+		mediaprovider.AlbumSortRecentlyAdded,
+		mediaprovider.AlbumSortRandom,
+		mediaprovider.AlbumSortTitleAZ,
+		mediaprovider.AlbumSortArtistAZ,
+		mediaprovider.AlbumSortYearAscending,
+		mediaprovider.AlbumSortYearDescending,
+	}
+}
+
+func (d *DLNAMediaProvider) IterateAlbums(sortOrder string, filter mediaprovider.AlbumFilter) mediaprovider.AlbumIterator {
+	var albums []*mediaprovider.Album
+	return helpers.NewAlbumIterator(func(offset, limit int) ([]*mediaprovider.Album, error) {
+		if albums == nil {
+			lib, err := d.library()
+			if err != nil {
+				return nil, err
+			}
+			albums = sortedAlbums(lib, sortOrder)
+		}
+		return sharedutil.MapSlice(page(albums, offset, limit), copyOf), nil
+	}, filter, d.prefetchCover)
+}
+
+func sortedAlbums(lib *library, sortOrder string) []*mediaprovider.Album {
+	albums := slices.Clone(lib.albums)
+	switch sortOrder {
+	case mediaprovider.AlbumSortRecentlyAdded:
+		// servers generally list items in the order they were indexed
+		slices.Reverse(albums)
+	case mediaprovider.AlbumSortRandom:
+		rand.Shuffle(len(albums), func(i, j int) { albums[i], albums[j] = albums[j], albums[i] })
+	case mediaprovider.AlbumSortTitleAZ:
+		slices.SortStableFunc(albums, func(a, b *mediaprovider.Album) int {
+			return compareFold(a.Name, b.Name)
+		})
+	case mediaprovider.AlbumSortArtistAZ:
+		slices.SortStableFunc(albums, func(a, b *mediaprovider.Album) int {
+			return compareFold(strings.Join(a.ArtistNames, ", "), strings.Join(b.ArtistNames, ", "))
+		})
+	case mediaprovider.AlbumSortYearAscending:
+		slices.SortStableFunc(albums, func(a, b *mediaprovider.Album) int {
+			return a.YearOrZero() - b.YearOrZero()
+		})
+	case mediaprovider.AlbumSortYearDescending:
+		slices.SortStableFunc(albums, func(a, b *mediaprovider.Album) int {
+			return b.YearOrZero() - a.YearOrZero()
+		})
+	}
+	return albums
+}
+
+func (d *DLNAMediaProvider) IterateTracks(searchQuery string) mediaprovider.TrackIterator {
+	terms := searchTerms(searchQuery)
+	var tracks []*mediaprovider.Track
+	return helpers.NewTrackIterator(func(offset, limit int) ([]*mediaprovider.Track, error) {
+		if tracks == nil {
+			lib, err := d.library()
+			if err != nil {
+				return nil, err
+			}
+			tracks = sharedutil.FilterSlice(lib.tracks, func(t *mediaprovider.Track) bool {
+				return matchesTerms(t.Title, terms)
+			})
+		}
+		return sharedutil.MapSlice(page(tracks, offset, limit), copyOf), nil
+	}, d.prefetchCover)
+}
+
+func (d *DLNAMediaProvider) SearchAlbums(searchQuery string, filter mediaprovider.AlbumFilter) mediaprovider.AlbumIterator {
+	terms := searchTerms(searchQuery)
+	var albums []*mediaprovider.Album
+	return helpers.NewAlbumIterator(func(offset, limit int) ([]*mediaprovider.Album, error) {
+		if albums == nil {
+			lib, err := d.library()
+			if err != nil {
+				return nil, err
+			}
+			albums = sharedutil.FilterSlice(lib.albums, func(a *mediaprovider.Album) bool {
+				return matchesTerms(a.Name+" "+strings.Join(a.ArtistNames, " "), terms)
+			})
+		}
+		return sharedutil.MapSlice(page(albums, offset, limit), copyOf), nil
+	}, filter, d.prefetchCover)
+}
+
+func (d *DLNAMediaProvider) SearchAll(searchQuery string, maxResults int) ([]*mediaprovider.SearchResult, error) {
+	lib, err := d.library()
+	if err != nil {
+		return nil, err
+	}
+	// the whole library is local, so all of the query is applied client-side
+	q := helpers.ParseSearchQuery(searchQuery)
+	var results []*mediaprovider.SearchResult
+	for _, al := range lib.albums {
+		if q.IncludesType(mediaprovider.ContentTypeAlbum) && q.MatchesTerms(al.Name) {
+			results = append(results, &mediaprovider.SearchResult{
+				Type:       mediaprovider.ContentTypeAlbum,
+				ID:         al.ID,
+				CoverID:    al.CoverArtID,
+				Name:       al.Name,
+				ArtistName: strings.Join(al.ArtistNames, ", "),
+				Size:       al.TrackCount,
+				Item:       copyOf(al),
+			})
+		}
+	}
+	for _, ar := range lib.artists {
+		if q.IncludesType(mediaprovider.ContentTypeArtist) && q.MatchesTerms(ar.Name) {
+			results = append(results, &mediaprovider.SearchResult{
+				Type: mediaprovider.ContentTypeArtist,
+				ID:   ar.ID,
+				Name: ar.Name,
+				Size: ar.AlbumCount,
+				Item: copyOf(ar),
+			})
+		}
+	}
+	for _, tr := range lib.tracks {
+		if q.IncludesType(mediaprovider.ContentTypeTrack) && q.MatchesTerms(tr.Title) {
+			results = append(results, &mediaprovider.SearchResult{
+				Type:       mediaprovider.ContentTypeTrack,
+				ID:         tr.ID,
+				CoverID:    tr.CoverArtID,
+				Name:       tr.Title,
+				ArtistName: strings.Join(tr.ArtistNames, ", "),
+				Size:       int(tr.Duration.Seconds()),
+				Item:       copyOf(tr),
+			})
+		}
+	}
+	for _, g := range lib.genres {
+		if q.IncludesType(mediaprovider.ContentTypeGenre) && q.MatchesTerms(g.Name) {
+			results = append(results, &mediaprovider.SearchResult{
+				Type: mediaprovider.ContentTypeGenre,
+				ID:   g.Name,
+				Name: g.Name,
+				Size: g.AlbumCount,
+			})
+		}
+	}
+
+	results = sharedutil.FilterSlice(results, q.Matches)
+	helpers.RankSearchResults(results, q.Text(), q.Terms)
+	if len(results) > maxResults {
+		results = results[:maxResults]
+	}
+	return results, nil
+}
+
+func (d *DLNAMediaProvider) GetRandomTracks(genre string, count int) ([]*mediaprovider.Track, error) {
+	lib, err := d.library()
+	if err != nil {
+		return nil, err
+	}
+	tracks := lib.tracks
+	if genre != "" {
+		tracks = sharedutil.FilterSlice(tracks, func(t *mediaprovider.Track) bool {
+			return slices.ContainsFunc(t.Genres, func(g string) bool { return strings.EqualFold(g, genre) })
+		})
+	}
+	return randomSample(tracks, count), nil
+}
+
+// GetSimilarTracks returns random tracks sharing a genre with the artist's tracks.
+func (d *DLNAMediaProvider) GetSimilarTracks(artistID string, count int) ([]*mediaprovider.Track, error) {
+	lib, err := d.library()
+	if err != nil {
+		return nil, err
+	}
+	genres := make(map[string]bool)
+	for _, tr := range lib.artistTracks[artistID] {
+		for _, g := range tr.Genres {
+			genres[strings.ToLower(g)] = true
+		}
+	}
+	if len(genres) == 0 {
+		return nil, nil
+	}
+	tracks := sharedutil.FilterSlice(lib.tracks, func(t *mediaprovider.Track) bool {
+		return slices.ContainsFunc(t.Genres, func(g string) bool { return genres[strings.ToLower(g)] })
+	})
+	return randomSample(tracks, count), nil
+}
+
+func (d *DLNAMediaProvider) GetSongRadio(trackID string, count int) ([]*mediaprovider.Track, error) {
+	track, err := d.GetTrack(trackID)
+	if err != nil {
+		return nil, err
+	}
+	return helpers.GetSimilarSongsFallback(d, track, count), nil
+}
+
+func (d *DLNAMediaProvider) ArtistSortOrders() []string {
+	return []string{
+		mediaprovider.ArtistSortNameAZ,
+		mediaprovider.ArtistSortAlbumCount,
+		mediaprovider.ArtistSortRandom,
+	}
+}
+
+func (d *DLNAMediaProvider) IterateArtists(sortOrder string, filter mediaprovider.ArtistFilter) mediaprovider.ArtistIterator {
+	var artists []*mediaprovider.Artist
+	return helpers.NewArtistIterator(func(offset, limit int) ([]*mediaprovider.Artist, error) {
+		if artists == nil {
+			lib, err := d.library()
+			if err != nil {
+				return nil, err
+			}
+			artists = sortedArtists(lib, sortOrder)
+		}
+		return sharedutil.MapSlice(page(artists, offset, limit), copyOf), nil
+	}, filter, d.prefetchCover)
+}
+
+func sortedArtists(lib *library, sortOrder string) []*mediaprovider.Artist {
+	// only album artists are listed, like other servers' artist indexes
+	artists := sharedutil.FilterSlice(lib.artists, func(a *mediaprovider.Artist) bool {
+		return a.AlbumCount > 0
+	})
+	switch sortOrder {
+	case mediaprovider.ArtistSortNameAZ:
+		slices.SortStableFunc(artists, func(a, b *mediaprovider.Artist) int {
+			return compareFold(a.Name, b.Name)
+		})
+	case mediaprovider.ArtistSortAlbumCount:
+		slices.SortStableFunc(artists, func(a, b *mediaprovider.Artist) int {
+			return b.AlbumCount - a.AlbumCount
+		})
+	case mediaprovider.ArtistSortRandom:
+		rand.Shuffle(len(artists), func(i, j int) { artists[i], artists[j] = artists[j], artists[i] })
+	}
+	return artists
+}
+
+func (d *DLNAMediaProvider) SearchArtists(searchQuery string, filter mediaprovider.ArtistFilter) mediaprovider.ArtistIterator {
+	terms := searchTerms(searchQuery)
+	var artists []*mediaprovider.Artist
+	return helpers.NewArtistIterator(func(offset, limit int) ([]*mediaprovider.Artist, error) {
+		if artists == nil {
+			lib, err := d.library()
+			if err != nil {
+				return nil, err
+			}
+			artists = sharedutil.FilterSlice(lib.artists, func(a *mediaprovider.Artist) bool {
+				return matchesTerms(a.Name, terms)
+			})
+		}
+		return sharedutil.MapSlice(page(artists, offset, limit), copyOf), nil
+	}, filter, d.prefetchCover)
+}
+
+func (d *DLNAMediaProvider) GetGenres() ([]*mediaprovider.Genre, error) {
+	lib, err := d.library()
+	if err != nil {
+		return nil, err
+	}
+	return sharedutil.MapSlice(lib.genres, copyOf), nil
+}
+
+func (d *DLNAMediaProvider) GetFavorites() (mediaprovider.Favorites, error) {
+	return mediaprovider.Favorites{}, nil
+}
+
+func (d *DLNAMediaProvider) GetStreamURL(trackID string, transcodeSettings *mediaprovider.TranscodeSettings, forceRaw bool) (string, error) {
+	lib, err := d.library()
+	if err != nil {
+		return "", err
+	}
+	u, ok := lib.streamURLs[trackID]
+	if !ok {
+		return "", fmt.Errorf("track %s not found", trackID)
+	}
+	return u, nil
+}
+
+func (d *DLNAMediaProvider) GetTopTracks(artist mediaprovider.Artist, count int) ([]*mediaprovider.Track, error) {
+	return helpers.GetTopTracksFallback(d, artist.ID, count)
+}
+
+func (d *DLNAMediaProvider) SetFavorite(params mediaprovider.RatingFavoriteParameters, favorite bool) error {
+	return errUnsupported
+}
+
+func (d *DLNAMediaProvider) GetPlaylists() ([]*mediaprovider.Playlist, error) {
+	return nil, nil
+}
+
+func (d *DLNAMediaProvider) CreatePlaylistWithTracks(name string, trackIDs []string) error {
+	return errUnsupported
+}
+
+func (d *DLNAMediaProvider) CanMakePublicPlaylist() bool {
+	return false
+}
+
+func (d *DLNAMediaProvider) CreatePlaylist(name, description string, public bool) error {
+	return errUnsupported
+}
+
+func (d *DLNAMediaProvider) EditPlaylist(id, name, description string, public bool) error {
+	return errUnsupported
+}
+
+func (d *DLNAMediaProvider) AddPlaylistTracks(id string, trackIDsToAdd []string) error {
+	return errUnsupported
+}
+
+func (d *DLNAMediaProvider) RemovePlaylistTracks(id string, trackIdxsToRemove []int) error {
+	return errUnsupported
+}
+
+func (d *DLNAMediaProvider) ReplacePlaylistTracks(id string, trackIDs []string) error {
+	return errUnsupported
+}
+
+func (d *DLNAMediaProvider) DeletePlaylist(id string) error {
+	return errUnsupported
+}
+
+func (d *DLNAMediaProvider) ClientDecidesScrobble() bool { return false }
+
+func (d *DLNAMediaProvider) TrackBeganPlayback(trackID string) error {
+	return nil
+}
+
+func (d *DLNAMediaProvider) TrackEndedPlayback(trackID string, positionSecs int, submission bool) error {
+	return nil
+}
+
+func (d *DLNAMediaProvider) DownloadTrack(trackID string) (io.Reader, error) {
+	u, err := d.GetStreamURL(trackID, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.cd.httpClient.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("download failed: HTTP status %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// RescanLibrary re-indexes the server's library on next access.
+// (ContentDirectory has no way to ask the server itself to rescan.)
+func (d *DLNAMediaProvider) RescanLibrary() error {
+	d.libLock.Lock()
+	d.lib = nil
+	d.libLock.Unlock()
+	return nil
+}
+
+// copyOf returns a copy of a library item, including the slices it holds,
+// so that callers modifying the returned item can't affect the library.
+func copyOf[T any](t *T) *T {
+	c := *t
+	switch c := any(&c).(type) {
+	case *mediaprovider.Track:
+		c.Genres = slices.Clone(c.Genres)
+		c.ArtistIDs = slices.Clone(c.ArtistIDs)
+		c.ArtistNames = slices.Clone(c.ArtistNames)
+		c.AlbumArtistIDs = slices.Clone(c.AlbumArtistIDs)
+		c.AlbumArtistNames = slices.Clone(c.AlbumArtistNames)
+		c.ComposerIDs = slices.Clone(c.ComposerIDs)
+		c.ComposerNames = slices.Clone(c.ComposerNames)
+	case *mediaprovider.Album:
+		c.Genres = slices.Clone(c.Genres)
+		c.ArtistIDs = slices.Clone(c.ArtistIDs)
+		c.ArtistNames = slices.Clone(c.ArtistNames)
+	}
+	return &c
+}
+
+func page[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	return items[offset:min(offset+limit, len(items))]
+}
+
+func randomSample(tracks []*mediaprovider.Track, count int) []*mediaprovider.Track {
+	idxs := rand.Perm(len(tracks))
+	if len(idxs) > count {
+		idxs = idxs[:count]
+	}
+	return sharedutil.MapSlice(idxs, func(i int) *mediaprovider.Track { return copyOf(tracks[i]) })
+}
+
+func searchTerms(query string) []string {
+	return strings.Fields(strings.ToLower(sanitize.Accents(query)))
+}
+
+func matchesTerms(name string, terms []string) bool {
+	return helpers.AllTermsMatch(strings.ToLower(sanitize.Accents(name)), terms)
+}
+
+func compareFold(a, b string) int {
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
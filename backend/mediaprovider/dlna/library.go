@@ -0,0 +1,354 @@
+package dlna
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+)
+
+const (
+	browsePageSize = 200
+	// guards against servers with cyclic or very deep container trees
+	maxBrowseDepth = 16
+
+	audioItemClass  = "object.item.audioItem"
+	musicAlbumClass = "object.container.album.musicAlbum"
+)
+
+// library is an in-memory index of a media server's audio items.
+// ContentDirectory has no notion of albums or artists as entities,
+// so they are derived from the items' metadata.
+type library struct {
+	tracks     []*mediaprovider.Track
+	tracksByID map[string]*mediaprovider.Track
+	streamURLs map[string]string // track ID -> res URL
+
+	albums     []*mediaprovider.Album
+	albumsByID map[string]*mediaprovider.AlbumWithTracks
+
+	artists       []*mediaprovider.Artist
+	artistsByID   map[string]*mediaprovider.Artist
+	artistAlbums  map[string][]*mediaprovider.Album
+	artistTracks  map[string][]*mediaprovider.Track
+	genres        []*mediaprovider.Genre
+	coverArtURLs  map[string]string // cover art ID -> albumArtURI
+	albumOrderIdx map[string]int    // album ID -> order first seen, for "recently added"
+}
+
+// loadLibrary indexes all audio items on the server, using Search
+// if the server supports searching by class, otherwise browsing
+// the container tree from the root.
+func loadLibrary(ctx context.Context, cd *contentDirectoryClient) (*library, error) {
+	b := newLibraryBuilder()
+	caps, _ := cd.searchCapabilities(ctx)
+	if slices.Contains(caps, "upnp:class") || slices.Contains(caps, "*") {
+		err := b.addSearchResults(ctx, cd)
+		if err == nil {
+			return b.build(), nil
+		}
+		logger.Warn("search failed, falling back to browsing", "err", err)
+		b = newLibraryBuilder()
+	}
+	if err := b.browse(ctx, cd, "0", 0, make(map[string]bool)); err != nil {
+		return nil, err
+	}
+	return b.build(), nil
+}
+
+type libraryBuilder struct {
+	items []didlObject
+	seen  map[string]bool // by ref ID or res URL, since items may appear in several containers
+
+	// album art of musicAlbum containers, by container ID
+	containerArt map[string]string
+}
+
+func newLibraryBuilder() *libraryBuilder {
+	return &libraryBuilder{
+		seen:         make(map[string]bool),
+		containerArt: make(map[string]string),
+	}
+}
+
+func (b *libraryBuilder) addSearchResults(ctx context.Context, cd *contentDirectoryClient) error {
+	criteria := `upnp:class derivedfrom "` + audioItemClass + `"`
+	for start := 0; ; {
+		didl, total, err := cd.search(ctx, criteria, start, browsePageSize)
+		if err != nil {
+			return err
+		}
+		b.addItems(didl.Items)
+		start += len(didl.Items)
+		if len(didl.Items) == 0 || start >= total {
+			return nil
+		}
+	}
+}
+
+func (b *libraryBuilder) browse(ctx context.Context, cd *contentDirectoryClient, containerID string, depth int, visited map[string]bool) error {
+	if depth > maxBrowseDepth || visited[containerID] {
+		return nil
+	}
+	visited[containerID] = true
+	var children []didlObject
+	for start := 0; ; {
+		didl, total, err := cd.browseChildren(ctx, containerID, start, browsePageSize)
+		if err != nil {
+			return err
+		}
+		b.addItems(didl.Items)
+		children = append(children, didl.Containers...)
+		n := len(didl.Items) + len(didl.Containers)
+		start += n
+		if n == 0 || start >= total {
+			break
+		}
+	}
+	for _, c := range children {
+		if strings.HasPrefix(c.Class, musicAlbumClass) && c.AlbumArtURI != "" {
+			b.containerArt[c.ID] = c.AlbumArtURI
+		}
+		if err := b.browse(ctx, cd, c.ID, depth+1, visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *libraryBuilder) addItems(items []didlObject) {
+	for _, item := range items {
+		if !strings.HasPrefix(item.Class, audioItemClass) || len(item.Res) == 0 {
+			continue
+		}
+		key := item.ID
+		if item.RefID != "" {
+			key = item.RefID
+		}
+		if b.seen[key] || b.seen[item.Res[0].URL] {
+			continue
+		}
+		b.seen[key] = true
+		b.seen[item.Res[0].URL] = true
+		if item.RefID != "" {
+			item.ID = item.RefID
+		}
+		b.items = append(b.items, item)
+	}
+}
+
+func (b *libraryBuilder) build() *library {
+	l := &library{
+		tracksByID:    make(map[string]*mediaprovider.Track),
+		streamURLs:    make(map[string]string),
+		albumsByID:    make(map[string]*mediaprovider.AlbumWithTracks),
+		artistsByID:   make(map[string]*mediaprovider.Artist),
+		artistAlbums:  make(map[string][]*mediaprovider.Album),
+		artistTracks:  make(map[string][]*mediaprovider.Track),
+		coverArtURLs:  make(map[string]string),
+		albumOrderIdx: make(map[string]int),
+	}
+	genreAlbums := make(map[string]map[string]bool)
+	genreTracks := make(map[string]int)
+	var genreNames []string
+
+	for _, item := range b.items {
+		tr := l.toTrack(&item, b.containerArt[item.ParentID])
+		l.tracks = append(l.tracks, tr)
+		l.tracksByID[tr.ID] = tr
+		l.streamURLs[tr.ID] = item.Res[0].URL
+
+		al, ok := l.albumsByID[tr.AlbumID]
+		if !ok {
+			al = &mediaprovider.AlbumWithTracks{Album: mediaprovider.Album{
+				ID:          tr.AlbumID,
+				Name:        tr.Album,
+				ArtistIDs:   tr.AlbumArtistIDs,
+				ArtistNames: tr.AlbumArtistNames,
+			}}
+			if len(al.ArtistIDs) == 0 {
+				al.ArtistIDs, al.ArtistNames = tr.ArtistIDs, tr.ArtistNames
+			}
+			l.albumsByID[tr.AlbumID] = al
+			l.albumOrderIdx[tr.AlbumID] = len(l.albums)
+			l.albums = append(l.albums, &al.Album)
+			for _, id := range al.ArtistIDs {
+				l.artistAlbums[id] = append(l.artistAlbums[id], &al.Album)
+			}
+		}
+		al.Tracks = append(al.Tracks, tr)
+		al.TrackCount++
+		al.Duration += tr.Duration
+		if al.CoverArtID == "" {
+			al.CoverArtID = tr.CoverArtID
+		}
+		if al.Date.Year == nil && tr.Year > 0 {
+			year := tr.Year
+			al.Date.Year = &year
+		}
+		for _, g := range tr.Genres {
+			if !slices.Contains(al.Genres, g) {
+				al.Genres = append(al.Genres, g)
+			}
+			if genreAlbums[g] == nil {
+				genreAlbums[g] = make(map[string]bool)
+				genreNames = append(genreNames, g)
+			}
+			genreAlbums[g][al.ID] = true
+			genreTracks[g]++
+		}
+		for _, id := range tr.ArtistIDs {
+			l.artistTracks[id] = append(l.artistTracks[id], tr)
+		}
+	}
+
+	for _, al := range l.albumsByID {
+		slices.SortStableFunc(al.Tracks, func(a, b *mediaprovider.Track) int {
+			if a.DiscNumber != b.DiscNumber {
+				return a.DiscNumber - b.DiscNumber
+			}
+			return a.TrackNumber - b.TrackNumber
+		})
+	}
+	for id, albums := range l.artistAlbums {
+		l.artistsByID[id].AlbumCount = len(albums)
+	}
+	for _, g := range genreNames {
+		l.genres = append(l.genres, &mediaprovider.Genre{
+			Name:       g,
+			AlbumCount: len(genreAlbums[g]),
+			TrackCount: genreTracks[g],
+		})
+	}
+	return l
+}
+
+func (l *library) toTrack(item *didlObject, containerArt string) *mediaprovider.Track {
+	res := item.Res[0]
+	tr := &mediaprovider.Track{
+		ID:          item.ID,
+		ParentID:    item.ParentID,
+		Title:       item.Title,
+		Album:       item.Album,
+		Genres:      item.Genres,
+		Duration:    parseDuration(res.Duration),
+		Size:        atoi64(res.Size),
+		BitRate:     atoi(res.Bitrate) * 8 / 1000,
+		SampleRate:  atoi(res.SampleFrequency),
+		BitDepth:    atoi(res.BitsPerSample),
+		Channels:    atoi(res.Channels),
+		ContentType: contentTypeFromProtocolInfo(res.ProtocolInfo),
+		Year:        parseYear(item.Date),
+	}
+	// some servers encode the disc number as DNNN
+	if n := atoi(item.TrackNumber); n >= 1000 {
+		tr.DiscNumber, tr.TrackNumber = n/1000, n%1000
+	} else {
+		tr.TrackNumber = n
+	}
+
+	for _, a := range item.Artists {
+		name := strings.TrimSpace(a.Name)
+		if name == "" {
+			continue
+		}
+		switch a.Role {
+		case "AlbumArtist":
+			tr.AlbumArtistNames = append(tr.AlbumArtistNames, name)
+		case "", "Performer":
+			tr.ArtistNames = append(tr.ArtistNames, name)
+		case "Composer":
+			tr.ComposerNames = append(tr.ComposerNames, name)
+		}
+	}
+	if len(tr.ArtistNames) == 0 && item.Creator != "" {
+		tr.ArtistNames = []string{item.Creator}
+	}
+	tr.ArtistIDs = l.artistIDs(tr.ArtistNames)
+	tr.AlbumArtistIDs = l.artistIDs(tr.AlbumArtistNames)
+
+	if tr.Album == "" {
+		tr.Album = "[Unknown Album]"
+	}
+	// without album artists, tracks in the same container belong to the same album
+	albumKey := item.ParentID
+	if len(tr.AlbumArtistNames) > 0 {
+		albumKey = strings.ToLower(strings.Join(tr.AlbumArtistNames, "\x00"))
+	}
+	tr.AlbumID = "al-" + hashID(strings.ToLower(tr.Album)+"\x00"+albumKey)
+
+	artURL := item.AlbumArtURI
+	if artURL == "" {
+		artURL = containerArt
+	}
+	if artURL != "" {
+		tr.CoverArtID = "art-" + hashID(artURL)
+		l.coverArtURLs[tr.CoverArtID] = artURL
+	}
+	return tr
+}
+
+// artistIDs returns the IDs of the named artists, adding any new ones to the library
+func (l *library) artistIDs(names []string) []string {
+	ids := make([]string, 0, len(names))
+	for _, name := range names {
+		id := "ar-" + hashID(strings.ToLower(name))
+		if _, ok := l.artistsByID[id]; !ok {
+			a := &mediaprovider.Artist{ID: id, Name: name}
+			l.artistsByID[id] = a
+			l.artists = append(l.artists, a)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// hashID derives a stable, filesystem-safe ID from metadata,
+// since cover art IDs are also used as cache file names
+func hashID(s string) string {
+	h := md5.Sum([]byte(s))
+	return hex.EncodeToString(h[:8])
+}
+
+// parses the H+:MM:SS[.F+] duration format of res elements
+func parseDuration(s string) time.Duration {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0
+	}
+	h, _ := strconv.Atoi(parts[0])
+	m, _ := strconv.Atoi(parts[1])
+	sec, _ := strconv.ParseFloat(parts[2], 64)
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec*float64(time.Second))
+}
+
+func parseYear(date string) int {
+	if len(date) < 4 {
+		return 0
+	}
+	return atoi(date[:4])
+}
+
+// extracts the MIME type from protocolInfo, e.g. "http-get:*:audio/mpeg:*"
+func contentTypeFromProtocolInfo(info string) string {
+	parts := strings.Split(info, ":")
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[2]
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(s))
+	return n
+}
+
+func atoi64(s string) int64 {
+	n, _ := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	return n
+}
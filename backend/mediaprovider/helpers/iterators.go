@@ -1,12 +1,13 @@
 package helpers
 
 import (
-	"log"
-
+	"github.com/dweymouth/supersonic/backend/logging"
 	"github.com/dweymouth/supersonic/backend/mediaprovider"
 	"github.com/dweymouth/supersonic/sharedutil"
 )
 
+var logger = logging.For(logging.MediaProvider)
+
 type baseIter[M, F any] struct {
 	filter        mediaprovider.MediaFilter[M, F]
 	prefetchCB    func(*M)
@@ -60,7 +61,7 @@ func (r *baseIter[M, F]) Next() *M {
 	for { // keep fetching until we are done or have matching results
 		items, err := r.fetcher(r.serverPos, 20)
 		if err != nil {
-			log.Printf("error fetching items: %s", err.Error())
+			logger.Error("error fetching items", "err", err)
 			items = nil
 		}
 		if len(items) == 0 {
@@ -126,7 +127,7 @@ func (r *randomAlbumIter) Next() *mediaprovider.Album {
 			// fetch albums from deterministic order
 			albums, err := r.deterministicFetcher(r.offset, 25)
 			if err != nil {
-				log.Printf("error fetching albums: %s", err.Error())
+				logger.Error("error fetching albums", "err", err)
 				albums = nil
 			}
 			if len(albums) == 0 {
@@ -147,7 +148,7 @@ func (r *randomAlbumIter) Next() *mediaprovider.Album {
 		} else {
 			albums, err := r.randomFetcher(r.offset, 25)
 			if err != nil {
-				log.Println(err)
+				logger.Error("error fetching random albums", "err", err)
 				r.done = true
 				r.albumIDSet = nil
 				return nil
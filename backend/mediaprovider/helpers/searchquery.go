@@ -0,0 +1,402 @@
+package helpers
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/deluan/sanitize"
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+)
+
+// Fields that can be used in a search query as field:value
+const (
+	SearchFieldArtist = "artist"
+	SearchFieldAlbum  = "album"
+	SearchFieldGenre  = "genre"
+	SearchFieldYear   = "year"
+	SearchFieldRating = "rating"
+	SearchFieldIs     = "is"
+	SearchFieldType   = "type"
+)
+
+var SearchFields = []string{
+	SearchFieldArtist,
+	SearchFieldAlbum,
+	SearchFieldGenre,
+	SearchFieldYear,
+	SearchFieldRating,
+	SearchFieldIs,
+	SearchFieldType,
+}
+
+// Values accepted by the is: and type: fields
+var (
+	SearchIsValues   = []string{"favorite"}
+	SearchTypeValues = []string{
+		"album", "artist", "track", "playlist", "genre", "radio",
+		"ep", "single", "compilation", "live", "soundtrack", "remix",
+	}
+)
+
+var searchContentTypes = map[string]mediaprovider.ContentType{
+	"album":    mediaprovider.ContentTypeAlbum,
+	"artist":   mediaprovider.ContentTypeArtist,
+	"track":    mediaprovider.ContentTypeTrack,
+	"song":     mediaprovider.ContentTypeTrack,
+	"playlist": mediaprovider.ContentTypePlaylist,
+	"genre":    mediaprovider.ContentTypeGenre,
+	"radio":    mediaprovider.ContentTypeRadioStation,
+}
+
+var searchReleaseTypes = map[string]mediaprovider.ReleaseType{
+	"ep":          mediaprovider.ReleaseTypeEP,
+	"single":      mediaprovider.ReleaseTypeSingle,
+	"compilation": mediaprovider.ReleaseTypeCompilation,
+	"live":        mediaprovider.ReleaseTypeLive,
+	"soundtrack":  mediaprovider.ReleaseTypeSoundtrack,
+	"remix":       mediaprovider.ReleaseTypeRemix,
+}
+
+// SearchQuery is a parsed search query. A query consists of plain terms,
+// "quoted phrases" and field filters, any of which can be negated with a
+// leading '-'. The field filters are
+//
+//	artist:name  album:name  genre:name
+//	year:1994  year:1990..1999  year:>=2000
+//	rating:>=4  rating:1..3
+//	is:favorite
+//	type:album|artist|track|playlist|genre|radio|ep|single|compilation|live|...
+//
+// Values containing spaces can be quoted, as in artist:"pink floyd".
+// Unknown fields and invalid values are treated as plain terms.
+type SearchQuery struct {
+	// Plain terms and phrases to match, lowercase with accents removed.
+	Terms []string
+	// Negated terms and phrases, which must not match.
+	ExcludedTerms []string
+
+	Filters []SearchFilter
+}
+
+// SearchFilter is a field:value filter of a search query.
+type SearchFilter struct {
+	Field  string
+	Negate bool
+
+	// for text and is:/type: fields, lowercase with accents removed
+	Value string
+	// for numeric fields, the inclusive range of accepted values
+	Min, Max int
+}
+
+// ParseSearchQuery parses a search query. See SearchQuery for the syntax.
+func ParseSearchQuery(query string) SearchQuery {
+	var q SearchQuery
+	for _, tok := range tokenizeSearchQuery(query) {
+		text := normalizeSearchText(tok.text)
+		if text == "" {
+			continue
+		}
+		if !tok.quoted {
+			if f, ok := parseSearchFilter(tok.text); ok {
+				f.Negate = tok.negate
+				q.Filters = append(q.Filters, f)
+				continue
+			}
+		}
+		if tok.negate {
+			q.ExcludedTerms = append(q.ExcludedTerms, text)
+		} else {
+			q.Terms = append(q.Terms, text)
+		}
+	}
+	return q
+}
+
+// Text returns the plain terms of the query, joined by spaces.
+func (q SearchQuery) Text() string {
+	return strings.Join(q.Terms, " ")
+}
+
+// ServerText returns the text to send to a server's full-text search, which
+// matches terms against names and artist and album names: the plain terms
+// plus the values of the non-negated artist: and album: filters.
+func (q SearchQuery) ServerText() string {
+	parts := slices.Clone(q.Terms)
+	for _, f := range q.Filters {
+		if !f.Negate && (f.Field == SearchFieldArtist || f.Field == SearchFieldAlbum) {
+			parts = append(parts, f.Value)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// HasFilters returns true if the query has any field filters or negated terms,
+// which must be applied (at least in part) to search results with Matches.
+func (q SearchQuery) HasFilters() bool {
+	return len(q.Filters) > 0 || len(q.ExcludedTerms) > 0
+}
+
+// IncludesType returns false if the query's type: filters exclude all
+// results of the given content type.
+func (q SearchQuery) IncludesType(t mediaprovider.ContentType) bool {
+	for _, f := range q.Filters {
+		if f.Field != SearchFieldType {
+			continue
+		}
+		if ct, ok := searchContentTypes[f.Value]; ok {
+			if (ct == t) == f.Negate {
+				return false
+			}
+		} else if !f.Negate && t != mediaprovider.ContentTypeAlbum {
+			// release types apply only to albums
+			return false
+		}
+	}
+	return true
+}
+
+// FilterValues returns the values of the non-negated filters of the given field.
+func (q SearchQuery) FilterValues(field string) []string {
+	var vals []string
+	for _, f := range q.Filters {
+		if f.Field == field && !f.Negate {
+			vals = append(vals, f.Value)
+		}
+	}
+	return vals
+}
+
+// FilterRange returns the range accepted by the non-negated filters
+// of the given numeric field, and false if there are none.
+func (q SearchQuery) FilterRange(field string) (int, int, bool) {
+	lo, hi, found := math.MinInt, math.MaxInt, false
+	for _, f := range q.Filters {
+		if f.Field == field && !f.Negate {
+			lo, hi, found = max(lo, f.Min), min(hi, f.Max), true
+		}
+	}
+	return lo, hi, found
+}
+
+// MatchesTerms returns true if the name contains all of the plain terms.
+// Used to search items that are filtered client-side, such as playlists.
+func (q SearchQuery) MatchesTerms(name string) bool {
+	return AllTermsMatch(normalizeSearchText(name), q.Terms)
+}
+
+// Matches returns true if the search result passes the query's filters
+// and does not contain any of the excluded terms. The plain terms are not
+// checked, since servers match them against more than the result's names.
+func (q SearchQuery) Matches(r *mediaprovider.SearchResult) bool {
+	if len(q.ExcludedTerms) > 0 {
+		text := normalizeSearchText(r.Name + " " + r.ArtistName + " " + searchResultAlbum(r))
+		for _, t := range q.ExcludedTerms {
+			if strings.Contains(text, t) {
+				return false
+			}
+		}
+	}
+	for _, f := range q.Filters {
+		if f.matches(r) == f.Negate {
+			return false
+		}
+	}
+	return true
+}
+
+func (f SearchFilter) matches(r *mediaprovider.SearchResult) bool {
+	switch f.Field {
+	case SearchFieldArtist:
+		switch item := r.Item.(type) {
+		case *mediaprovider.Track:
+			return anyContains(item.ArtistNames, f.Value) || anyContains(item.AlbumArtistNames, f.Value)
+		case *mediaprovider.Album:
+			return anyContains(item.ArtistNames, f.Value)
+		case *mediaprovider.Artist:
+			return strings.Contains(normalizeSearchText(item.Name), f.Value)
+		}
+	case SearchFieldAlbum:
+		switch item := r.Item.(type) {
+		case *mediaprovider.Track:
+			return strings.Contains(normalizeSearchText(item.Album), f.Value)
+		case *mediaprovider.Album:
+			return strings.Contains(normalizeSearchText(item.Name), f.Value)
+		}
+	case SearchFieldGenre:
+		switch item := r.Item.(type) {
+		case *mediaprovider.Track:
+			return anyContains(item.Genres, f.Value)
+		case *mediaprovider.Album:
+			return anyContains(item.Genres, f.Value)
+		}
+		if r.Type == mediaprovider.ContentTypeGenre {
+			return strings.Contains(normalizeSearchText(r.Name), f.Value)
+		}
+	case SearchFieldYear:
+		year := 0
+		switch item := r.Item.(type) {
+		case *mediaprovider.Track:
+			year = item.Year
+		case *mediaprovider.Album:
+			year = item.YearOrZero()
+		}
+		return year > 0 && year >= f.Min && year <= f.Max
+	case SearchFieldRating:
+		if tr, ok := r.Item.(*mediaprovider.Track); ok {
+			return tr.Rating >= f.Min && tr.Rating <= f.Max
+		}
+	case SearchFieldIs:
+		switch item := r.Item.(type) {
+		case *mediaprovider.Track:
+			return item.Favorite
+		case *mediaprovider.Album:
+			return item.Favorite
+		case *mediaprovider.Artist:
+			return item.Favorite
+		}
+	case SearchFieldType:
+		if ct, ok := searchContentTypes[f.Value]; ok {
+			return r.Type == ct
+		}
+		if al, ok := r.Item.(*mediaprovider.Album); ok {
+			return al.ReleaseTypes&searchReleaseTypes[f.Value] != 0
+		}
+	}
+	return false
+}
+
+func searchResultAlbum(r *mediaprovider.SearchResult) string {
+	if tr, ok := r.Item.(*mediaprovider.Track); ok {
+		return tr.Album
+	}
+	return ""
+}
+
+func anyContains(names []string, value string) bool {
+	return slices.ContainsFunc(names, func(n string) bool {
+		return strings.Contains(normalizeSearchText(n), value)
+	})
+}
+
+func normalizeSearchText(s string) string {
+	return strings.ToLower(sanitize.Accents(strings.TrimSpace(s)))
+}
+
+// parseSearchFilter parses a field:value token,
+// returning false if it is not a valid filter.
+func parseSearchFilter(tok string) (SearchFilter, bool) {
+	field, value, ok := strings.Cut(tok, ":")
+	field = strings.ToLower(field)
+	value = normalizeSearchText(strings.Trim(value, `"`))
+	if !ok || value == "" {
+		return SearchFilter{}, false
+	}
+	f := SearchFilter{Field: field, Value: value}
+	switch field {
+	case SearchFieldArtist, SearchFieldAlbum, SearchFieldGenre:
+		return f, true
+	case SearchFieldYear, SearchFieldRating:
+		f.Min, f.Max, ok = parseSearchRange(value)
+		return f, ok
+	case SearchFieldIs:
+		if value == "fav" || value == "favourite" || value == "starred" {
+			f.Value = "favorite"
+		}
+		return f, f.Value == "favorite"
+	case SearchFieldType:
+		_, isContentType := searchContentTypes[value]
+		_, isReleaseType := searchReleaseTypes[value]
+		return f, isContentType || isReleaseType
+	}
+	return SearchFilter{}, false
+}
+
+// parseSearchRange parses "n", "a..b", "a..", "..b", ">n", ">=n", "<n" or "<=n".
+func parseSearchRange(s string) (int, int, bool) {
+	lo, hi := math.MinInt, math.MaxInt
+	var err error
+	switch {
+	case strings.Contains(s, ".."):
+		a, b, _ := strings.Cut(s, "..")
+		if a != "" {
+			if lo, err = strconv.Atoi(a); err != nil {
+				return 0, 0, false
+			}
+		}
+		if b != "" {
+			if hi, err = strconv.Atoi(b); err != nil {
+				return 0, 0, false
+			}
+		}
+		if a == "" && b == "" {
+			return 0, 0, false
+		}
+	case strings.HasPrefix(s, ">="):
+		lo, err = strconv.Atoi(s[2:])
+	case strings.HasPrefix(s, "<="):
+		hi, err = strconv.Atoi(s[2:])
+	case strings.HasPrefix(s, ">"):
+		lo, err = strconv.Atoi(s[1:])
+		lo++
+	case strings.HasPrefix(s, "<"):
+		hi, err = strconv.Atoi(s[1:])
+		hi--
+	default:
+		lo, err = strconv.Atoi(s)
+		hi = lo
+	}
+	return lo, hi, err == nil && lo <= hi
+}
+
+type searchToken struct {
+	text   string
+	quoted bool // the whole token is a quoted phrase
+	negate bool
+}
+
+// tokenizeSearchQuery splits the query on whitespace outside of quotes.
+func tokenizeSearchQuery(query string) []searchToken {
+	var tokens []searchToken
+	var cur strings.Builder
+	var tok searchToken
+	inQuotes, started := false, false
+	flush := func() {
+		if started {
+			tok.text = cur.String()
+			tokens = append(tokens, tok)
+		}
+		cur.Reset()
+		tok = searchToken{}
+		started = false
+	}
+	for _, r := range query {
+		switch {
+		case r == '"':
+			if !started {
+				tok.quoted = true
+			}
+			inQuotes = !inQuotes
+			started = true
+			if tok.quoted {
+				continue // keep only the phrase's text
+			}
+			cur.WriteRune(r)
+		case !inQuotes && (r == ' ' || r == '\t'):
+			flush()
+		case r == '-' && !started && !tok.negate:
+			tok.negate = true
+		default:
+			if tok.quoted && !inQuotes {
+				// text following a closing quote: not just a phrase
+				tok.quoted = false
+			}
+			cur.WriteRune(r)
+			started = true
+		}
+	}
+	flush()
+	return tokens
+}
@@ -0,0 +1,131 @@
+package helpers
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	q := ParseSearchQuery(`Héllo "dark side" -live artist:"Pink Floyd" year:1970..1979 rating:>=4 is:fav type:ep foo:bar -genre:jazz`)
+
+	if !slices.Equal(q.Terms, []string{"hello", "dark side", "foo:bar"}) {
+		t.Errorf("wrong terms: %v", q.Terms)
+	}
+	if !slices.Equal(q.ExcludedTerms, []string{"live"}) {
+		t.Errorf("wrong excluded terms: %v", q.ExcludedTerms)
+	}
+	want := []SearchFilter{
+		{Field: SearchFieldArtist, Value: "pink floyd"},
+		{Field: SearchFieldYear, Value: "1970..1979", Min: 1970, Max: 1979},
+		{Field: SearchFieldRating, Value: ">=4", Min: 4, Max: math.MaxInt},
+		{Field: SearchFieldIs, Value: "favorite"},
+		{Field: SearchFieldType, Value: "ep"},
+		{Field: SearchFieldGenre, Value: "jazz", Negate: true},
+	}
+	if !slices.Equal(q.Filters, want) {
+		t.Errorf("wrong filters:\n got %v\nwant %v", q.Filters, want)
+	}
+	if text := q.ServerText(); text != "hello dark side foo:bar pink floyd" {
+		t.Errorf("wrong server text: %q", text)
+	}
+}
+
+func TestParseSearchQuery_InvalidFilters(t *testing.T) {
+	q := ParseSearchQuery("year:abc rating:5..1 is:new type:podcast artist:")
+	if len(q.Filters) != 0 {
+		t.Errorf("expected no filters, got %v", q.Filters)
+	}
+	if len(q.Terms) != 5 {
+		t.Errorf("expected invalid filters as terms, got %v", q.Terms)
+	}
+}
+
+func TestSearchQuery_IncludesType(t *testing.T) {
+	all := []mediaprovider.ContentType{
+		mediaprovider.ContentTypeAlbum,
+		mediaprovider.ContentTypeArtist,
+		mediaprovider.ContentTypeTrack,
+		mediaprovider.ContentTypePlaylist,
+	}
+	for _, tt := range []struct {
+		query    string
+		included []mediaprovider.ContentType
+	}{
+		{"foo", all},
+		{"type:track", []mediaprovider.ContentType{mediaprovider.ContentTypeTrack}},
+		{"type:single", []mediaprovider.ContentType{mediaprovider.ContentTypeAlbum}},
+		{"-type:album", []mediaprovider.ContentType{mediaprovider.ContentTypeArtist, mediaprovider.ContentTypeTrack, mediaprovider.ContentTypePlaylist}},
+		{"-type:single", all},
+	} {
+		q := ParseSearchQuery(tt.query)
+		for _, ct := range all {
+			if want := slices.Contains(tt.included, ct); q.IncludesType(ct) != want {
+				t.Errorf("%q: expected IncludesType(%v) = %v", tt.query, ct, want)
+			}
+		}
+	}
+}
+
+func TestSearchQuery_Matches(t *testing.T) {
+	album := &mediaprovider.SearchResult{
+		Type: mediaprovider.ContentTypeAlbum,
+		Name: "Wish You Were Here",
+		Item: &mediaprovider.Album{
+			Name:         "Wish You Were Here",
+			ArtistNames:  []string{"Pink Floyd"},
+			Genres:       []string{"Progressive Rock"},
+			Date:         mediaprovider.ItemDate{Year: intPtr(1975)},
+			ReleaseTypes: mediaprovider.ReleaseTypeAlbum,
+			Favorite:     true,
+		},
+	}
+	track := &mediaprovider.SearchResult{
+		Type:       mediaprovider.ContentTypeTrack,
+		Name:       "Shine On You Crazy Diamond (Live)",
+		ArtistName: "Pink Floyd",
+		Item: &mediaprovider.Track{
+			Title:       "Shine On You Crazy Diamond (Live)",
+			ArtistNames: []string{"Pink Floyd"},
+			Album:       "Wish You Were Here",
+			Year:        1975,
+			Rating:      3,
+		},
+	}
+	playlist := &mediaprovider.SearchResult{
+		Type: mediaprovider.ContentTypePlaylist,
+		Name: "Floyd favorites",
+	}
+
+	for _, tt := range []struct {
+		query   string
+		matches []*mediaprovider.SearchResult
+	}{
+		{"floyd", []*mediaprovider.SearchResult{album, track, playlist}},
+		{"artist:floyd", []*mediaprovider.SearchResult{album, track}},
+		{"-artist:floyd", []*mediaprovider.SearchResult{playlist}},
+		{"album:wish", []*mediaprovider.SearchResult{album, track}},
+		{"genre:rock", []*mediaprovider.SearchResult{album}},
+		{"year:1970..1979", []*mediaprovider.SearchResult{album, track}},
+		{"year:>1975", nil},
+		{"rating:>=3", []*mediaprovider.SearchResult{track}},
+		{"is:favorite", []*mediaprovider.SearchResult{album}},
+		{"type:track", []*mediaprovider.SearchResult{track}},
+		{"type:ep", nil},
+		{"-live", []*mediaprovider.SearchResult{album, playlist}},
+		{`-"crazy diamond"`, []*mediaprovider.SearchResult{album, playlist}},
+	} {
+		q := ParseSearchQuery(tt.query)
+		for _, r := range []*mediaprovider.SearchResult{album, track, playlist} {
+			if want := slices.Contains(tt.matches, r); q.Matches(r) != want {
+				t.Errorf("%q: expected Matches(%q) = %v", tt.query, r.Name, want)
+			}
+		}
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -102,7 +102,8 @@ func (j *JellyfinMediaProvider) IterateTracks(searchQuery string) mediaprovider.
 			if err != nil {
 				return nil, err
 			}
-			return sharedutil.MapSlice(s, toTrack), nil
+			tracks := sharedutil.MapSlice(s, toTrack)
+			return tracks, j.fillRecordingIDs(tracks)
 		}
 	} else {
 		fetcher = func(offs, limit int) ([]*mediaprovider.Track, error) {
@@ -113,7 +114,8 @@ func (j *JellyfinMediaProvider) IterateTracks(searchQuery string) mediaprovider.
 			if err != nil {
 				return nil, err
 			}
-			return sharedutil.MapSlice(sr.Songs, toTrack), nil
+			tracks := sharedutil.MapSlice(sr.Songs, toTrack)
+			return tracks, j.fillRecordingIDs(tracks)
 		}
 	}
 	return helpers.NewTrackIterator(fetcher, j.prefetchCoverCB)
@@ -1,6 +1,7 @@
 package jellyfin
 
 import (
+	"errors"
 	"image"
 	"io"
 	"math"
@@ -21,27 +22,83 @@ const (
 
 type JellyfinServer struct {
 	jellyfin.Client
+
+	// Saved access token to log in with instead of the password, if any
+	Token string
+	// Called with the access token of each new login with the password
+	OnTokenChanged func(token string)
 }
 
+// Login logs in with the saved access token if there is one, or else (or if
+// the token has expired) with the password. If the password is given, it is
+// also used to log in again if the server rejects the token later on.
 func (j *JellyfinServer) Login(user, pass string) mediaprovider.LoginResponse {
 	if _, err := j.Ping(); err != nil {
 		return mediaprovider.LoginResponse{Error: err}
 	}
-	err := j.Client.Login(user, pass)
+	t := j.tokenTransport()
+	err := errors.New("no password or access token")
+	if j.Token != "" {
+		err = j.loginWithToken(t, j.Token)
+	}
+	if err != nil && pass != "" {
+		err = j.loginWithPassword(user, pass)
+	}
+	if err == nil && pass != "" {
+		t.setRelogin(func() error { return j.loginWithPassword(user, pass) })
+	}
 	return mediaprovider.LoginResponse{
 		Error:       err,
 		IsAuthError: err != nil,
 	}
 }
 
+// loginWithToken logs in with the access token if the server accepts it.
+// The client can only log in with a password, so its login request is
+// answered by the transport with the checked token instead.
+func (j *JellyfinServer) loginWithToken(t *tokenTransport, token string) error {
+	l, err := t.checkToken(j.Client.BaseURL(), token)
+	if err != nil {
+		return err
+	}
+	t.setTokenLogin(l)
+	return j.Client.Login(l.UserName, "")
+}
+
+// loginWithPassword logs in with the password,
+// reporting the access token of the new login.
+func (j *JellyfinServer) loginWithPassword(user, pass string) error {
+	if err := j.Client.Login(user, pass); err != nil {
+		return err
+	}
+	if j.OnTokenChanged != nil {
+		j.OnTokenChanged(j.tokenTransport().currentToken())
+	}
+	return nil
+}
+
+func (j *JellyfinServer) tokenTransport() *tokenTransport {
+	if t, ok := j.Client.HTTPClient.Transport.(*tokenTransport); ok {
+		return t
+	}
+	base := j.Client.HTTPClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &tokenTransport{base: base}
+	j.Client.HTTPClient.Transport = t
+	return t
+}
+
 func (j *JellyfinServer) MediaProvider() mediaprovider.MediaProvider {
-	return newJellyfinMediaProvider(&j.Client)
+	return newJellyfinMediaProvider(&j.Client, j.tokenTransport())
 }
 
 var _ mediaprovider.MediaProvider = (*JellyfinMediaProvider)(nil)
 
 type JellyfinMediaProvider struct {
 	client          *jellyfin.Client
+	tokens          *tokenTransport
 	prefetchCoverCB func(coverArtID string)
 
 	currentLibraryID string
@@ -50,9 +107,10 @@ type JellyfinMediaProvider struct {
 	genresCachedAt int64 // unix
 }
 
-func newJellyfinMediaProvider(cli *jellyfin.Client) mediaprovider.MediaProvider {
+func newJellyfinMediaProvider(cli *jellyfin.Client, tokens *tokenTransport) mediaprovider.MediaProvider {
 	return &JellyfinMediaProvider{
 		client:       cli,
+		tokens:       tokens,
 		genresCached: make([]*mediaprovider.Genre, 0),
 	}
 }
@@ -376,7 +434,10 @@ func (j *JellyfinMediaProvider) DownloadTrack(trackID string) (io.Reader, error)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := http.Get(url)
+	// download with the client's TLS settings, but without its request timeout
+	cli := *j.client.HTTPClient
+	cli.Timeout = 0
+	resp, err := cli.Get(url)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,71 @@
+package jellyfin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+)
+
+// fillRecordingIDs fills in the MusicBrainz recording IDs of the tracks,
+// which are fetched separately since the Jellyfin client neither requests
+// nor decodes the provider IDs of songs.
+func (j *JellyfinMediaProvider) fillRecordingIDs(tracks []*mediaprovider.Track) error {
+	if len(tracks) == 0 {
+		return nil
+	}
+	ids := make([]string, len(tracks))
+	for i, tr := range tracks {
+		ids[i] = tr.ID
+	}
+	u, err := url.JoinPath(j.client.BaseURL().String(), "/Items")
+	if err != nil {
+		return err
+	}
+	q := url.Values{"Ids": {strings.Join(ids, ",")}, "Fields": {"ProviderIds"}}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, u+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(tokenHeader, j.tokens.currentToken())
+	resp, err := j.client.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch provider IDs: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch provider IDs: %s", resp.Status)
+	}
+
+	var items struct {
+		Items []struct {
+			Id          string
+			ProviderIds map[string]string
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		return fmt.Errorf("invalid provider IDs response: %w", err)
+	}
+	recordingIDs := make(map[string]string, len(items.Items))
+	for _, it := range items.Items {
+		recordingIDs[it.Id] = musicBrainzRecordingID(it.ProviderIds)
+	}
+	for _, tr := range tracks {
+		tr.MusicBrainzID = recordingIDs[tr.ID]
+	}
+	return nil
+}
+
+// Jellyfin stores the recording ID as MusicBrainzRecording since 10.9,
+// and previously as MusicBrainzTrack (from the MUSICBRAINZ_TRACKID tag,
+// which also holds the recording ID).
+func musicBrainzRecordingID(providerIDs map[string]string) string {
+	if id := providerIDs["MusicBrainzRecording"]; id != "" {
+		return id
+	}
+	return providerIDs["MusicBrainzTrack"]
+}
@@ -0,0 +1,51 @@
+package jellyfin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+)
+
+func TestFillRecordingIDs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/System/Info/Public":
+			w.Write([]byte(`{"Id":"s1"}`))
+		case r.Header.Get(tokenHeader) != "saved":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/Users/Me":
+			w.Write([]byte(`{"Name":"alice","Id":"u1","ServerId":"s1"}`))
+		case r.URL.Path == "/Items" && r.URL.Query().Get("Fields") == "ProviderIds":
+			if ids := r.URL.Query().Get("Ids"); ids != "1,2,3" {
+				t.Errorf("got Ids %q", ids)
+			}
+			json.NewEncoder(w).Encode(map[string]any{"Items": []map[string]any{
+				{"Id": "1", "ProviderIds": map[string]string{"MusicBrainzRecording": "rec1"}},
+				{"Id": "2", "ProviderIds": map[string]string{"MusicBrainzTrack": "rec2"}},
+				{"Id": "3"},
+			}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	var tokens []string
+	j := newTestJellyfinServer(t, srv.URL, "saved", &tokens)
+	if resp := j.Login("alice", ""); resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	mp := j.MediaProvider().(*JellyfinMediaProvider)
+	tracks := []*mediaprovider.Track{{ID: "1"}, {ID: "2"}, {ID: "3"}}
+	if err := mp.fillRecordingIDs(tracks); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"rec1", "rec2", ""} {
+		if got := tracks[i].MusicBrainzID; got != want {
+			t.Errorf("track %s: got recording ID %q, want %q", tracks[i].ID, got, want)
+		}
+	}
+}
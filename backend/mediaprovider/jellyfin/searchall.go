@@ -4,15 +4,22 @@ import (
 	"strings"
 	"sync"
 
-	"github.com/deluan/sanitize"
 	"github.com/dweymouth/go-jellyfin"
 	"github.com/dweymouth/supersonic/backend/mediaprovider"
 	"github.com/dweymouth/supersonic/backend/mediaprovider/helpers"
 	"github.com/dweymouth/supersonic/sharedutil"
 )
 
+// how many more results to request from the server
+// when some of them will be removed by client-side filters
+const searchFilterOverfetch = 4
+
 func (j *JellyfinMediaProvider) SearchAll(searchQuery string, maxResults int) ([]*mediaprovider.SearchResult, error) {
+	q := helpers.ParseSearchQuery(searchQuery)
 	limit := maxResults / 3
+	if q.HasFilters() {
+		limit *= searchFilterOverfetch
+	}
 	var wg sync.WaitGroup
 	var albums []*jellyfin.Album
 	var artists []*jellyfin.Artist
@@ -23,58 +30,87 @@ func (j *JellyfinMediaProvider) SearchAll(searchQuery string, maxResults int) ([
 	var opts jellyfin.QueryOpts
 	opts.Paging.Limit = limit
 	opts.Filter.ParentID = j.currentLibraryID
-	wg.Add(1)
-	go func() {
-		albumResult, _ := j.client.Search(searchQuery, jellyfin.TypeAlbum, opts)
-		albums = albumResult.Albums
-		wg.Done()
-	}()
-	wg.Add(1)
-	go func() {
-		artistResult, _ := j.client.Search(searchQuery, jellyfin.TypeArtist, opts)
-		artists = artistResult.Artists
-		wg.Done()
-	}()
-	wg.Add(1)
-	go func() {
-		songResult, _ := j.client.Search(searchQuery, jellyfin.TypeSong, opts)
-		songs = songResult.Songs
-		wg.Done()
-	}()
-
-	querySanitized := strings.ToLower(sanitize.Accents(searchQuery))
-	queryLowerWords := strings.Fields(querySanitized)
-
-	wg.Add(1)
-	go func() {
-		p, e := j.client.GetPlaylists()
-		if e == nil {
-			playlists = sharedutil.FilterSlice(p, func(p *jellyfin.Playlist) bool {
-				return helpers.AllTermsMatch(strings.ToLower(sanitize.Accents(p.Name)), queryLowerWords)
-			})
-		}
-		wg.Done()
-	}()
-
-	wg.Add(1)
-	go func() {
-		g, e := j.client.GetGenres(jellyfin.Paging{}, "")
-		if e == nil {
-			genres = sharedutil.FilterSlice(g, func(g jellyfin.NameID) bool {
-				return helpers.AllTermsMatch(strings.ToLower(sanitize.Accents(g.Name)), queryLowerWords)
-			})
-		}
-		wg.Done()
-	}()
+	setSearchFilters(&opts.Filter, q)
+	if q.IncludesType(mediaprovider.ContentTypeAlbum) {
+		wg.Add(1)
+		go func() {
+			albumResult, _ := j.client.Search(q.Text(), jellyfin.TypeAlbum, opts)
+			albums = albumResult.Albums
+			wg.Done()
+		}()
+	}
+	if q.IncludesType(mediaprovider.ContentTypeArtist) {
+		wg.Add(1)
+		go func() {
+			artistOpts := opts
+			// Jellyfin artists have no genres or years of their own
+			artistOpts.Filter.Genres = nil
+			artistOpts.Filter.YearRange = [2]int{}
+			artistResult, _ := j.client.Search(q.Text(), jellyfin.TypeArtist, artistOpts)
+			artists = artistResult.Artists
+			wg.Done()
+		}()
+	}
+	if q.IncludesType(mediaprovider.ContentTypeTrack) {
+		wg.Add(1)
+		go func() {
+			songResult, _ := j.client.Search(q.Text(), jellyfin.TypeSong, opts)
+			songs = songResult.Songs
+			wg.Done()
+		}()
+	}
+
+	if q.IncludesType(mediaprovider.ContentTypePlaylist) {
+		wg.Add(1)
+		go func() {
+			p, e := j.client.GetPlaylists()
+			if e == nil {
+				playlists = sharedutil.FilterSlice(p, func(p *jellyfin.Playlist) bool {
+					return q.MatchesTerms(p.Name)
+				})
+			}
+			wg.Done()
+		}()
+	}
+
+	if q.IncludesType(mediaprovider.ContentTypeGenre) {
+		wg.Add(1)
+		go func() {
+			g, e := j.client.GetGenres(jellyfin.Paging{}, "")
+			if e == nil {
+				genres = sharedutil.FilterSlice(g, func(g jellyfin.NameID) bool {
+					return q.MatchesTerms(g.Name)
+				})
+			}
+			wg.Done()
+		}()
+	}
 
 	wg.Wait()
 
 	results := j.mergeResults(albums, artists, songs, playlists, genres)
-	helpers.RankSearchResults(results, searchQuery, queryLowerWords)
+	results = sharedutil.FilterSlice(results, q.Matches)
+	helpers.RankSearchResults(results, q.Text(), q.Terms)
 
 	return results, nil
 }
 
+// setSearchFilters pushes down the query's filters that Jellyfin supports.
+// The results are still post-filtered with SearchQuery.Matches for the rest.
+// Note that Jellyfin matches whole genre names, so genre: is stricter here.
+func setSearchFilters(filter *jellyfin.Filter, q helpers.SearchQuery) {
+	if len(q.FilterValues(helpers.SearchFieldIs)) > 0 {
+		filter.Favorite = true
+	}
+	if genres := q.FilterValues(helpers.SearchFieldGenre); len(genres) == 1 {
+		filter.Genres = genres
+	}
+	// Jellyfin filters by a list of years, so only push down bounded ranges
+	if lo, hi, ok := q.FilterRange(helpers.SearchFieldYear); ok && lo > 0 && hi-lo < 100 {
+		filter.YearRange = [2]int{lo, hi}
+	}
+}
+
 func (j *JellyfinMediaProvider) mergeResults(
 	albums []*jellyfin.Album,
 	artists []*jellyfin.Artist,
@@ -0,0 +1,210 @@
+package jellyfin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const (
+	loginPath   = "/Users/authenticatebyname"
+	currentUser = "/Users/Me"
+	tokenHeader = "X-Emby-Token"
+)
+
+// tokenTransport extends the Jellyfin client, which can only log in with a
+// password, to log in with an access token checked by checkToken, and to log
+// in again with the password (if known) when the server rejects an expired
+// token, retrying the request with the new token.
+type tokenTransport struct {
+	base http.RoundTripper
+
+	mu sync.Mutex
+	// token of the current login
+	token string
+	// checked token login to answer the client's next login request with
+	tokenLogin *tokenLogin
+	// logs in again with the password
+	relogin func() error
+
+	reloginMu sync.Mutex
+}
+
+// A Jellyfin user and an access token of theirs accepted by the server.
+type tokenLogin struct {
+	Token    string
+	UserID   string
+	UserName string
+	ServerID string
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(req.URL.Path, loginPath) {
+		return t.login(req)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil // can't be retried
+	}
+	newToken, ok := t.refresh(req.Header.Get(tokenHeader))
+	if !ok {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	retry.Header.Set(tokenHeader, newToken)
+	if q := retry.URL.Query(); q.Has("api_key") {
+		q.Set("api_key", newToken)
+		retry.URL.RawQuery = q.Encode()
+	}
+	return t.base.RoundTrip(retry)
+}
+
+// checkToken checks whether the server at baseURL accepts the access
+// token by fetching the user it belongs to.
+func (t *tokenTransport) checkToken(baseURL *url.URL, token string) (*tokenLogin, error) {
+	u, err := url.JoinPath(baseURL.String(), currentUser)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(tokenHeader, token)
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, fmt.Errorf("login failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("login failed: %s", resp.Status)
+	}
+	var user struct {
+		Id       string
+		Name     string
+		ServerId string
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, fmt.Errorf("invalid user response: %w", err)
+	}
+	return &tokenLogin{Token: token, UserID: user.Id, UserName: user.Name, ServerID: user.ServerId}, nil
+}
+
+// setTokenLogin sets the checked token login to answer
+// the client's next login request with.
+func (t *tokenTransport) setTokenLogin(l *tokenLogin) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tokenLogin = l
+}
+
+// setRelogin sets how to log in again once the server rejects the token.
+func (t *tokenTransport) setRelogin(relogin func() error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.relogin = relogin
+}
+
+// currentToken returns the token of the current login.
+func (t *tokenTransport) currentToken() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.token
+}
+
+// refresh logs in again after the token the request was sent with was
+// rejected, returning the new token, unless another request already has.
+func (t *tokenTransport) refresh(rejected string) (string, bool) {
+	t.reloginMu.Lock()
+	defer t.reloginMu.Unlock()
+
+	t.mu.Lock()
+	token, relogin := t.token, t.relogin
+	t.mu.Unlock()
+	if token != rejected {
+		return token, token != ""
+	}
+	if relogin == nil || relogin() != nil {
+		return "", false
+	}
+	token = t.currentToken()
+	return token, token != rejected
+}
+
+// login answers the login request with the checked token login if set,
+// and otherwise sends it, remembering the token of a successful login.
+func (t *tokenTransport) login(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	tokenLogin := t.tokenLogin
+	t.tokenLogin = nil
+	t.mu.Unlock()
+	if tokenLogin != nil {
+		return t.answerLogin(req, tokenLogin)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	var login struct{ AccessToken string }
+	if json.Unmarshal(body, &login) == nil && login.AccessToken != "" {
+		t.mu.Lock()
+		t.token = login.AccessToken
+		t.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// answerLogin answers the login request as the server would have for the
+// token login, without sending it, since the token was already checked.
+func (t *tokenTransport) answerLogin(req *http.Request, l *tokenLogin) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	body, err := json.Marshal(map[string]any{
+		"User":        map[string]string{"Id": l.UserID, "Name": l.UserName, "ServerId": l.ServerID},
+		"AccessToken": l.Token,
+		"ServerId":    l.ServerID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	t.token = l.Token
+	t.mu.Unlock()
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}, nil
+}
@@ -0,0 +1,100 @@
+package jellyfin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/dweymouth/go-jellyfin"
+)
+
+// newTestServer returns a Jellyfin server that accepts the password "pass",
+// issuing a new access token for each login, and only the latest token.
+func newTestServer(t *testing.T) *httptest.Server {
+	var mu sync.Mutex
+	var logins int
+	token := "saved"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		user := map[string]string{"Name": "alice", "Id": "u1", "ServerId": "s1"}
+		switch r.URL.Path {
+		case "/System/Info/Public":
+			w.Write([]byte(`{"Id":"s1"}`))
+		case "/Users/authenticatebyname":
+			var body struct{ PW string }
+			json.NewDecoder(r.Body).Decode(&body)
+			if body.PW != "pass" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			logins++
+			token = fmt.Sprintf("token%d", logins)
+			json.NewEncoder(w).Encode(map[string]any{"User": user, "AccessToken": token, "ServerId": "s1"})
+		default:
+			if r.Header.Get(tokenHeader) != token {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(user)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestJellyfinServer(t *testing.T, url, token string, tokens *[]string) *JellyfinServer {
+	cli, err := jellyfin.NewClient(url, "test", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &JellyfinServer{
+		Client:         *cli,
+		Token:          token,
+		OnTokenChanged: func(token string) { *tokens = append(*tokens, token) },
+	}
+}
+
+func TestLogin_Token(t *testing.T) {
+	srv := newTestServer(t)
+
+	// the saved token is accepted
+	var tokens []string
+	j := newTestJellyfinServer(t, srv.URL, "saved", &tokens)
+	if resp := j.Login("alice", ""); resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if j.tokenTransport().currentToken() != "saved" || j.Client.LoggedInUser() != "alice" || len(tokens) != 0 {
+		t.Errorf("got token %q for %q, new tokens %v", j.tokenTransport().currentToken(), j.Client.LoggedInUser(), tokens)
+	}
+
+	// a rejected token falls back to the password
+	j = newTestJellyfinServer(t, srv.URL, "expired", &tokens)
+	if resp := j.Login("alice", "pass"); resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if len(tokens) != 1 || j.tokenTransport().currentToken() != tokens[0] {
+		t.Fatalf("got token %q, new tokens %v", j.tokenTransport().currentToken(), tokens)
+	}
+
+	// once the token expires, requests log in again and are retried
+	other := newTestJellyfinServer(t, srv.URL, "", &tokens)
+	if resp := other.Login("alice", "pass"); resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if _, err := j.MediaProvider().GetLibraries(); err != nil {
+		t.Fatalf("request with expired token not retried: %v", err)
+	}
+	if len(tokens) != 3 || j.tokenTransport().currentToken() != tokens[2] {
+		t.Errorf("got token %q, new tokens %v", j.tokenTransport().currentToken(), tokens)
+	}
+
+	// without a password, rejected tokens fail the login
+	j = newTestJellyfinServer(t, srv.URL, "expired", &tokens)
+	if resp := j.Login("alice", ""); resp.Error == nil || !resp.IsAuthError {
+		t.Errorf("login with rejected token: %+v", resp)
+	}
+}
@@ -189,6 +189,9 @@ type LoginResponse struct {
 type TranscodeSettings struct {
 	Codec       string
 	BitRateKBPS int
+	// Start the transcoded stream this far into the track,
+	// if the provider implements CanTranscodeFromOffset
+	OffsetSeconds int
 }
 
 type Server interface {
@@ -306,6 +309,13 @@ type CanReportPlayback interface {
 	ReportPlayback(trackID string, positionMs int64, state string) error
 }
 
+// CanTranscodeFromOffset is implemented by providers that may support
+// starting transcoded streams at TranscodeSettings.OffsetSeconds, which
+// allows seeking in them, since players can't seek transcoded streams.
+type CanTranscodeFromOffset interface {
+	SupportsTranscodeOffset() bool
+}
+
 type LyricsProvider interface {
 	GetLyrics(track *Track) (*Lyrics, error)
 }
@@ -324,6 +334,9 @@ type JukeboxProvider interface {
 	JukeboxRemove(idx int) error
 	JukeboxGetStatus() (*JukeboxStatus, error)
 
+	// Returns the jukebox status along with the tracks of its queue
+	JukeboxGetQueue() (*JukeboxQueue, error)
+
 	// Performs a Clear followed by an Add to set the queue
 	// to contain a single track
 	JukeboxSet(trackID string) error
@@ -339,6 +352,11 @@ type JukeboxStatus struct {
 	PositionSeconds float64
 }
 
+type JukeboxQueue struct {
+	JukeboxStatus
+	Tracks []*Track
+}
+
 func genresMatch(filterGenres, albumGenres []string) bool {
 	for _, g1 := range filterGenres {
 		for _, g2 := range albumGenres {
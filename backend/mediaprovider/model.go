@@ -162,6 +162,7 @@ type Track struct {
 	Extension        string
 	Channels         int
 	DateAdded        time.Time
+	MusicBrainzID    string
 }
 
 type ReplayGainInfo struct {
@@ -214,6 +215,9 @@ type RadioStation struct {
 	Title      string
 	Artists    []string
 	CoverArtID string
+
+	// Length of the stream, if known. Zero for live streams.
+	Duration time.Duration
 }
 
 type MediaItemType int
@@ -293,6 +297,7 @@ func (r *RadioStation) Metadata() MediaItemMetadata {
 		Name:       name,
 		CoverArtID: r.CoverArtID,
 		Artists:    r.Artists,
+		Duration:   r.Duration,
 	}
 }
 
@@ -306,6 +311,7 @@ func (r *RadioStation) Copy() MediaItem {
 		Title:      r.Title,
 		Artists:    slices.Clone(r.Artists),
 		CoverArtID: r.CoverArtID,
+		Duration:   r.Duration,
 	}
 }
 
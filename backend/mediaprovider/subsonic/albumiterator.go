@@ -1,7 +1,6 @@
 package subsonic
 
 import (
-	"log"
 	"strconv"
 	"strings"
 
@@ -112,7 +111,7 @@ func (s *subsonicMediaProvider) IterateAlbums(sortOrder string, filter mediaprov
 		}
 		return helpers.NewAlbumIterator(makeFetchFn(fetchFn), filter, s.prefetchCoverCB)
 	default:
-		log.Printf("Undefined album sort order: %s", sortOrder)
+		logger.Warn("undefined album sort order", "sortOrder", sortOrder)
 		return nil
 	}
 }
@@ -167,7 +166,7 @@ func (s *searchAlbumIter) Next() *mediaprovider.Album {
 		for _, artist := range results.Artist {
 			artist, err := s.s.GetArtist(artist.ID)
 			if err != nil || artist == nil {
-				log.Printf("error fetching artist: %s", err.Error())
+				logger.Error("error fetching artist", "err", err)
 			} else {
 				s.addNewAlbums(artist.Album)
 			}
@@ -181,7 +180,7 @@ func (s *searchAlbumIter) Next() *mediaprovider.Album {
 			}
 			album, err := s.s.GetAlbum(song.AlbumID)
 			if err != nil || album == nil {
-				log.Printf("error fetching album: %s", err.Error())
+				logger.Error("error fetching album", "err", err)
 			} else {
 				s.addNewAlbums([]*subsonic.AlbumID3{album})
 			}
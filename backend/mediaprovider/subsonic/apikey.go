@@ -0,0 +1,74 @@
+package subsonic
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// params of the username and password authentication
+var passwordAuthParams = []string{"u", "p", "t", "s"}
+
+// apiKeyTransport authenticates the requests of the Subsonic client, which
+// only supports password authentication, with an OpenSubsonic API key.
+// The API key identifies the user, so the username must not be sent.
+type apiKeyTransport struct {
+	base   http.RoundTripper
+	apiKey string
+}
+
+func (a *apiKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	a.authenticate(r.URL)
+	if isFormPost(r) {
+		// with formPost, the client sends the params in the body instead
+		if err := stripFormAuth(r); err != nil {
+			return nil, err
+		}
+	}
+	return a.base.RoundTrip(r)
+}
+
+// authenticate replaces the user and password params of the URL with the API key.
+func (a *apiKeyTransport) authenticate(u *url.URL) {
+	q := u.Query()
+	for _, p := range passwordAuthParams {
+		q.Del(p)
+	}
+	q.Set("apiKey", a.apiKey)
+	u.RawQuery = q.Encode()
+}
+
+func isFormPost(r *http.Request) bool {
+	if r.Method != http.MethodPost || r.Body == nil {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/x-www-form-urlencoded"
+}
+
+// stripFormAuth removes the user and password params from the
+// urlencoded body of the request.
+func stripFormAuth(r *http.Request) error {
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return err
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return err
+	}
+	for _, p := range passwordAuthParams {
+		form.Del(p)
+	}
+	encoded := form.Encode()
+	r.Body = io.NopCloser(strings.NewReader(encoded))
+	r.ContentLength = int64(len(encoded))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(encoded)), nil
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package subsonic
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	subsonicCli "github.com/supersonic-app/go-subsonic/subsonic"
+)
+
+func TestAPIKeyAuth_FormPost(t *testing.T) {
+	var mu sync.Mutex
+	var queries, forms []url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		r.ParseForm()
+		endpoint := strings.TrimSuffix(r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:], ".view")
+		body := `"status":"ok","version":"1.16.1","openSubsonic":true`
+		switch endpoint {
+		case "getOpenSubsonicExtensions":
+			body += fmt.Sprintf(`,"openSubsonicExtensions":[{"name":%q,"versions":[1]},{"name":"apiKeyAuthentication","versions":[1]}]`,
+				subsonicCli.HTTPFormPost)
+		case "updatePlaylist":
+			if r.Method != http.MethodPost {
+				t.Errorf("updatePlaylist sent with %s", r.Method)
+			}
+			mu.Lock()
+			queries = append(queries, query)
+			forms = append(forms, r.PostForm)
+			mu.Unlock()
+		}
+		if query.Get("apiKey") != "key" || r.Form.Has("u") {
+			body = `"status":"failed","version":"1.16.1","error":{"code":43,"message":"multiple conflicting authentication mechanisms"}`
+		}
+		fmt.Fprintf(w, `{"subsonic-response":{%s}}`, body)
+	}))
+	t.Cleanup(srv.Close)
+
+	s := &SubsonicServer{
+		Client: subsonicCli.Client{
+			Client:     srv.Client(),
+			BaseUrl:    srv.URL,
+			ClientName: "test",
+			UseJSON:    true,
+		},
+		APIKeyAuth: true,
+	}
+	if resp := s.Login("", "key"); resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	mp := s.MediaProvider().(*subsonicMediaProvider)
+	if err := mp.AddPlaylistTracks("pl", []string{"1", "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(forms) != 1 {
+		t.Fatalf("got %d updatePlaylist requests, want 1", len(forms))
+	}
+	for _, p := range passwordAuthParams {
+		if queries[0].Has(p) || forms[0].Has(p) {
+			t.Errorf("password auth param %q sent with the API key", p)
+		}
+	}
+	if got := forms[0]["songIdToAdd"]; len(got) != 2 {
+		t.Errorf("songIdToAdd: got %v", got)
+	}
+}
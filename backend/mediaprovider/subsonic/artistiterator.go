@@ -1,7 +1,6 @@
 package subsonic
 
 import (
-	"log"
 	"math/rand"
 	"slices"
 
@@ -69,7 +68,7 @@ func (s *subsonicMediaProvider) IterateArtists(sortOrder string, filter mediapro
 			filter,
 		)
 	default:
-		log.Printf("Undefined artist sort order: %s", sortOrder)
+		logger.Warn("undefined artist sort order", "sortOrder", sortOrder)
 		return nil
 	}
 }
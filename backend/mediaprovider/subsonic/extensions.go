@@ -0,0 +1,47 @@
+package subsonic
+
+import (
+	"github.com/supersonic-app/go-subsonic/subsonic"
+)
+
+// max number of track IDs or indexes sent in one GET request when the
+// server doesn't support formPost, to keep the URL within server limits
+const maxTracksPerGETRequest = 200
+
+// extensionSet is the set of OpenSubsonic extensions advertised by a
+// server, by name, with their supported versions.
+type extensionSet map[string][]int
+
+// fetchExtensions returns the OpenSubsonic extensions of the server,
+// or an empty set if it isn't an OpenSubsonic server.
+func fetchExtensions(client *subsonic.Client, openSubsonic bool) extensionSet {
+	set := make(extensionSet)
+	if !openSubsonic {
+		return set
+	}
+	ext, err := client.GetOpenSubsonicExtensions()
+	if err != nil {
+		logger.Warn("failed to get OpenSubsonic extensions", "err", err)
+		return set
+	}
+	for _, e := range ext {
+		set[e.Name] = e.Versions
+	}
+	logger.Debug("detected OpenSubsonic extensions", "extensions", set)
+	return set
+}
+
+func (e extensionSet) has(name string) bool {
+	_, ok := e[name]
+	return ok
+}
+
+// chunk splits s into slices of at most size elements.
+func chunk[T any](s []T, size int) [][]T {
+	var chunks [][]T
+	for len(s) > size {
+		chunks = append(chunks, s[:size])
+		s = s[size:]
+	}
+	return append(chunks, s)
+}
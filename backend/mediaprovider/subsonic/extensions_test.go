@@ -0,0 +1,113 @@
+package subsonic
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+	subsonicCli "github.com/supersonic-app/go-subsonic/subsonic"
+)
+
+// newTestServer returns a Subsonic server advertising the given
+// OpenSubsonic extensions (none if nil), which records the
+// songIndexToRemove parameters of updatePlaylist requests.
+func newTestServer(t *testing.T, extensions []string, removed *[][]string) *SubsonicServer {
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		endpoint := strings.TrimSuffix(r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:], ".view")
+		body := `"status":"ok","version":"1.16.1"`
+		if extensions != nil {
+			body += `,"openSubsonic":true`
+		}
+		switch endpoint {
+		case "getOpenSubsonicExtensions":
+			if extensions == nil {
+				http.NotFound(w, r)
+				return
+			}
+			ext := make([]string, len(extensions))
+			for i, e := range extensions {
+				ext[i] = fmt.Sprintf(`{"name":%q,"versions":[1]}`, e)
+			}
+			body += `,"openSubsonicExtensions":[` + strings.Join(ext, ",") + `]`
+		case "updatePlaylist":
+			mu.Lock()
+			*removed = append(*removed, r.Form["songIndexToRemove"])
+			mu.Unlock()
+		}
+		fmt.Fprintf(w, `{"subsonic-response":{%s}}`, body)
+	}))
+	t.Cleanup(srv.Close)
+	return &SubsonicServer{Client: subsonicCli.Client{
+		Client:     srv.Client(),
+		BaseUrl:    srv.URL,
+		ClientName: "test",
+		UseJSON:    true,
+	}}
+}
+
+func TestLogin_DetectsExtensions(t *testing.T) {
+	s := newTestServer(t, []string{subsonicCli.TranscodeOffset, subsonicCli.HTTPFormPost}, nil)
+	if resp := s.Login("user", "pass"); resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	mp := s.MediaProvider().(*subsonicMediaProvider)
+	if !mp.SupportsTranscodeOffset() || !mp.extensions.has(subsonicCli.HTTPFormPost) {
+		t.Fatalf("extensions not detected: %v", mp.extensions)
+	}
+	for _, tc := range []struct {
+		transcode *mediaprovider.TranscodeSettings
+		want      string
+	}{
+		{&mediaprovider.TranscodeSettings{Codec: "opus", BitRateKBPS: 128, OffsetSeconds: 42}, "42"},
+		{&mediaprovider.TranscodeSettings{Codec: "opus", BitRateKBPS: 128}, ""},
+	} {
+		streamURL, err := mp.GetStreamURL("1", tc.transcode, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, _ := url.Parse(streamURL)
+		if got := u.Query().Get("timeOffset"); got != tc.want {
+			t.Errorf("timeOffset: got %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestLogin_NoExtensions(t *testing.T) {
+	var removed [][]string
+	s := newTestServer(t, nil, &removed)
+	if resp := s.Login("user", "pass"); resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	mp := s.MediaProvider().(*subsonicMediaProvider)
+	if mp.SupportsTranscodeOffset() {
+		t.Error("transcodeOffset supported without OpenSubsonic")
+	}
+	streamURL, _ := mp.GetStreamURL("1", &mediaprovider.TranscodeSettings{Codec: "opus", OffsetSeconds: 42}, false)
+	if strings.Contains(streamURL, "timeOffset") {
+		t.Errorf("timeOffset sent to a server without transcodeOffset: %s", streamURL)
+	}
+
+	// without formPost, large removals are split into GET requests,
+	// removing the last tracks first
+	idxs := make([]int, maxTracksPerGETRequest+10)
+	for i := range idxs {
+		idxs[i] = i
+	}
+	if err := mp.RemovePlaylistTracks("pl", idxs); err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 || len(removed[0]) != maxTracksPerGETRequest || len(removed[1]) != 10 {
+		t.Fatalf("unexpected requests: %d", len(removed))
+	}
+	if removed[0][0] != fmt.Sprint(len(idxs)-1) || !slices.Contains(removed[1], "0") {
+		t.Errorf("tracks not removed from the end: %v, %v", removed[0][:3], removed[1])
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/dweymouth/supersonic/backend/mediaprovider"
+	"github.com/dweymouth/supersonic/sharedutil"
 )
 
 var _ mediaprovider.JukeboxProvider = (*subsonicMediaProvider)(nil)
@@ -67,3 +68,19 @@ func (s *subsonicMediaProvider) JukeboxGetStatus() (*mediaprovider.JukeboxStatus
 		PositionSeconds: float64(stat.Position),
 	}, nil
 }
+
+func (s *subsonicMediaProvider) JukeboxGetQueue() (*mediaprovider.JukeboxQueue, error) {
+	pl, err := s.client.GetJukeboxPlaylist()
+	if err != nil {
+		return nil, err
+	}
+	return &mediaprovider.JukeboxQueue{
+		JukeboxStatus: mediaprovider.JukeboxStatus{
+			Volume:          int(pl.Gain * 100),
+			CurrentTrack:    pl.CurrentIndex,
+			Playing:         pl.Playing,
+			PositionSeconds: float64(pl.Position),
+		},
+		Tracks: sharedutil.MapSlice(pl.Entry, toTrack),
+	}, nil
+}
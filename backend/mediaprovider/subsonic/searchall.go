@@ -5,13 +5,16 @@ import (
 	"strings"
 	"sync"
 
-	"github.com/deluan/sanitize"
 	"github.com/dweymouth/supersonic/backend/mediaprovider"
 	"github.com/dweymouth/supersonic/backend/mediaprovider/helpers"
 	"github.com/dweymouth/supersonic/sharedutil"
 	"github.com/supersonic-app/go-subsonic/subsonic"
 )
 
+// how many more results to request from the server
+// when some of them will be removed by client-side filters
+const searchFilterOverfetch = 4
+
 func (s *subsonicMediaProvider) SearchAll(searchQuery string, maxResults int) ([]*mediaprovider.SearchResult, error) {
 	var wg sync.WaitGroup
 	var err error // only set by Search3
@@ -20,18 +23,31 @@ func (s *subsonicMediaProvider) SearchAll(searchQuery string, maxResults int) ([
 	var genres []*subsonic.Genre
 	var radios []*mediaprovider.RadioStation
 
+	q := helpers.ParseSearchQuery(searchQuery)
+
 	wg.Add(1)
 	go func() {
-		count := strconv.Itoa(maxResults / 3)
+		n := maxResults / 3
+		if q.HasFilters() {
+			n *= searchFilterOverfetch
+		}
+		countFor := func(t mediaprovider.ContentType) string {
+			if !q.IncludesType(t) {
+				return "0"
+			}
+			return strconv.Itoa(n)
+		}
 		params := map[string]string{
-			"artistCount": count,
-			"albumCount":  count,
-			"songCount":   count,
+			"artistCount": countFor(mediaprovider.ContentTypeArtist),
+			"albumCount":  countFor(mediaprovider.ContentTypeAlbum),
+			"songCount":   countFor(mediaprovider.ContentTypeTrack),
 		}
 		if s.currentLibraryID != "" {
 			params["musicFolderId"] = s.currentLibraryID
 		}
-		res, e := s.client.Search3(searchQuery, params)
+		// Search3 matches names and artist and album names,
+		// so artist: and album: filters can narrow the server search
+		res, e := s.client.Search3(q.ServerText(), params)
 		if e != nil {
 			err = e
 		} else {
@@ -40,41 +56,44 @@ func (s *subsonicMediaProvider) SearchAll(searchQuery string, maxResults int) ([
 		wg.Done()
 	}()
 
-	querySanitized := strings.ToLower(sanitize.Accents(searchQuery))
-	queryLowerWords := strings.Fields(querySanitized)
-
-	wg.Add(1)
-	go func() {
-		p, e := s.client.GetPlaylists(nil)
-		if e == nil {
-			playlists = sharedutil.FilterSlice(p, func(p *subsonic.Playlist) bool {
-				return helpers.AllTermsMatch(strings.ToLower(sanitize.Accents(p.Name)), queryLowerWords)
-			})
-		}
-		wg.Done()
-	}()
+	if q.IncludesType(mediaprovider.ContentTypePlaylist) {
+		wg.Add(1)
+		go func() {
+			p, e := s.client.GetPlaylists(nil)
+			if e == nil {
+				playlists = sharedutil.FilterSlice(p, func(p *subsonic.Playlist) bool {
+					return q.MatchesTerms(p.Name)
+				})
+			}
+			wg.Done()
+		}()
+	}
 
-	wg.Add(1)
-	go func() {
-		g, e := s.client.GetGenres()
-		if e == nil {
-			genres = sharedutil.FilterSlice(g, func(g *subsonic.Genre) bool {
-				return helpers.AllTermsMatch(strings.ToLower(sanitize.Accents(g.Name)), queryLowerWords)
-			})
-		}
-		wg.Done()
-	}()
+	if q.IncludesType(mediaprovider.ContentTypeGenre) {
+		wg.Add(1)
+		go func() {
+			g, e := s.client.GetGenres()
+			if e == nil {
+				genres = sharedutil.FilterSlice(g, func(g *subsonic.Genre) bool {
+					return q.MatchesTerms(g.Name)
+				})
+			}
+			wg.Done()
+		}()
+	}
 
-	wg.Add(1)
-	go func() {
-		r, e := s.GetRadioStations()
-		if e == nil {
-			radios = sharedutil.FilterSlice(r, func(r *mediaprovider.RadioStation) bool {
-				return helpers.AllTermsMatch(strings.ToLower(sanitize.Accents(r.StationName)), queryLowerWords)
-			})
-		}
-		wg.Done()
-	}()
+	if q.IncludesType(mediaprovider.ContentTypeRadioStation) {
+		wg.Add(1)
+		go func() {
+			r, e := s.GetRadioStations()
+			if e == nil {
+				radios = sharedutil.FilterSlice(r, func(r *mediaprovider.RadioStation) bool {
+					return q.MatchesTerms(r.StationName)
+				})
+			}
+			wg.Done()
+		}()
+	}
 
 	wg.Wait()
 	if err != nil {
@@ -82,7 +101,8 @@ func (s *subsonicMediaProvider) SearchAll(searchQuery string, maxResults int) ([
 	}
 
 	results := mergeResults(result, playlists, genres, radios)
-	helpers.RankSearchResults(results, querySanitized, queryLowerWords)
+	results = sharedutil.FilterSlice(results, q.Matches)
+	helpers.RankSearchResults(results, q.Text(), q.Terms)
 	if len(results) > maxResults {
 		results = results[:maxResults]
 	}
@@ -1,7 +1,6 @@
 package subsonic
 
 import (
-	"log"
 	"strconv"
 
 	"github.com/supersonic-app/go-subsonic/subsonic"
@@ -27,7 +26,7 @@ func (s *searchIterBase) fetchResults() *subsonic.SearchResult3 {
 	}
 	results, err := s.s.Search3(s.query, searchOpts)
 	if err != nil {
-		log.Println(err)
+		logger.Error("error searching", "err", err)
 		results = nil
 	}
 	if results == nil || len(results.Album)+len(results.Artist)+len(results.Song) == 0 {
@@ -12,12 +12,15 @@ import (
 	"sync"
 	"time"
 
+	"github.com/dweymouth/supersonic/backend/logging"
 	"github.com/dweymouth/supersonic/backend/mediaprovider"
 	"github.com/dweymouth/supersonic/backend/mediaprovider/helpers"
 	"github.com/dweymouth/supersonic/sharedutil"
 	"github.com/supersonic-app/go-subsonic/subsonic"
 )
 
+var logger = logging.For(logging.MediaProvider)
+
 const (
 	playlistCacheValidDurationSeconds = 60
 	cacheValidDurationSeconds         = 120 // genres and radios aren't expected to change as much
@@ -27,6 +30,7 @@ type subsonicMediaProvider struct {
 	currentLibraryID string
 
 	client          *subsonic.Client
+	extensions      extensionSet
 	prefetchCoverCB func(coverArtID string)
 
 	genresCached   []*mediaprovider.Genre
@@ -37,13 +41,10 @@ type subsonicMediaProvider struct {
 
 	radiosCached   []*mediaprovider.RadioStation
 	radiosCachedAt int64 // unix
-
-	playbackReportOnce      sync.Once
-	playbackReportSupported bool
 }
 
-func SubsonicMediaProvider(subsonicClient *subsonic.Client) mediaprovider.MediaProvider {
-	return &subsonicMediaProvider{client: subsonicClient}
+func newSubsonicMediaProvider(subsonicClient *subsonic.Client, extensions extensionSet) mediaprovider.MediaProvider {
+	return &subsonicMediaProvider{client: subsonicClient, extensions: extensions}
 }
 
 func (s *subsonicMediaProvider) SetPrefetchCoverCallback(cb func(coverArtID string)) {
@@ -67,7 +68,19 @@ func (s *subsonicMediaProvider) SetLibrary(id string) error {
 
 func (s *subsonicMediaProvider) CreatePlaylistWithTracks(name string, trackIDs []string) error {
 	s.playlistsCached = nil
-	return s.client.CreatePlaylistWithTracks(trackIDs, map[string]string{"name": name})
+	if s.extensions.has(subsonic.HTTPFormPost) || len(trackIDs) <= maxTracksPerGETRequest {
+		return s.client.CreatePlaylistWithTracks(trackIDs, map[string]string{"name": name})
+	}
+	pl, err := s.client.CreatePlaylist(map[string]string{"name": name})
+	if err != nil {
+		return err
+	}
+	if pl == nil {
+		// Subsonic <= 1.14.0 doesn't return a playlist, so without
+		// its ID the tracks can only be sent in one request
+		return s.client.CreatePlaylistWithTracks(trackIDs, map[string]string{"name": name})
+	}
+	return s.AddPlaylistTracks(pl.ID, trackIDs)
 }
 
 func (s *subsonicMediaProvider) CreatePlaylist(name, description string, public bool) error {
@@ -111,12 +124,33 @@ func (s *subsonicMediaProvider) EditPlaylist(id, name, description string, publi
 
 func (s *subsonicMediaProvider) AddPlaylistTracks(id string, trackIDsToAdd []string) error {
 	s.playlistsCached = nil
-	return s.client.UpdatePlaylistTracks(id, trackIDsToAdd, nil)
+	if s.extensions.has(subsonic.HTTPFormPost) {
+		return s.client.UpdatePlaylistTracks(id, trackIDsToAdd, nil)
+	}
+	for _, ids := range chunk(trackIDsToAdd, maxTracksPerGETRequest) {
+		if err := s.client.UpdatePlaylistTracks(id, ids, nil); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (s *subsonicMediaProvider) RemovePlaylistTracks(id string, removeIdxs []int) error {
 	s.playlistsCached = nil
-	return s.client.UpdatePlaylistTracks(id, nil, removeIdxs)
+	if s.extensions.has(subsonic.HTTPFormPost) {
+		return s.client.UpdatePlaylistTracks(id, nil, removeIdxs)
+	}
+	// remove the last tracks first, so the indexes of the
+	// tracks still to be removed don't shift
+	idxs := slices.Clone(removeIdxs)
+	slices.Sort(idxs)
+	slices.Reverse(idxs)
+	for _, remove := range chunk(idxs, maxTracksPerGETRequest) {
+		if err := s.client.UpdatePlaylistTracks(id, nil, remove); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (s *subsonicMediaProvider) GetTrack(trackID string) (*mediaprovider.Track, error) {
@@ -287,6 +321,9 @@ func (s *subsonicMediaProvider) GetStreamURL(trackID string, transcode *mediapro
 	if transcode != nil {
 		m["format"] = transcode.Codec
 		m["maxBitRate"] = strconv.Itoa(transcode.BitRateKBPS)
+		if transcode.OffsetSeconds > 0 && s.SupportsTranscodeOffset() {
+			m["timeOffset"] = strconv.Itoa(transcode.OffsetSeconds)
+		}
 	} else if forceRaw {
 		m["format"] = "raw"
 	}
@@ -294,6 +331,10 @@ func (s *subsonicMediaProvider) GetStreamURL(trackID string, transcode *mediapro
 	if err != nil {
 		return "", err
 	}
+	if t, ok := s.client.Client.Transport.(*apiKeyTransport); ok {
+		// the stream URL is requested by the player, not through the client
+		t.authenticate(u)
+	}
 	return u.String(), nil
 }
 
@@ -314,7 +355,21 @@ func (s *subsonicMediaProvider) GetTopTracks(artist mediaprovider.Artist, count
 
 func (s *subsonicMediaProvider) ReplacePlaylistTracks(playlistID string, trackIDs []string) error {
 	s.playlistsCached = nil
-	return s.client.CreatePlaylistWithTracks(trackIDs, map[string]string{"playlistId": playlistID})
+	if s.extensions.has(subsonic.HTTPFormPost) || len(trackIDs) <= maxTracksPerGETRequest {
+		return s.client.CreatePlaylistWithTracks(trackIDs, map[string]string{"playlistId": playlistID})
+	}
+	err := s.client.CreatePlaylistWithTracks(trackIDs[:maxTracksPerGETRequest], map[string]string{"playlistId": playlistID})
+	if err != nil {
+		return err
+	}
+	return s.AddPlaylistTracks(playlistID, trackIDs[maxTracksPerGETRequest:])
+}
+
+// CanTranscodeFromOffset interface
+var _ mediaprovider.CanTranscodeFromOffset = (*subsonicMediaProvider)(nil)
+
+func (s *subsonicMediaProvider) SupportsTranscodeOffset() bool {
+	return s.extensions.has(subsonic.TranscodeOffset)
 }
 
 func (s *subsonicMediaProvider) ClientDecidesScrobble() bool { return true }
@@ -410,12 +465,7 @@ func (s *subsonicMediaProvider) RescanLibrary() error {
 var _ mediaprovider.LyricsProvider = (*subsonicMediaProvider)(nil)
 
 func (s *subsonicMediaProvider) GetLyrics(track *mediaprovider.Track) (*mediaprovider.Lyrics, error) {
-	ext, err := s.client.GetOpenSubsonicExtensions()
-	supportsSynced := err == nil &&
-		slices.ContainsFunc(ext, func(ext *subsonic.OpenSubsonicExtension) bool {
-			return ext.Name == subsonic.SongLyricsExtension
-		})
-	if supportsSynced {
+	if s.extensions.has(subsonic.SongLyricsExtension) {
 		lyrics, err := s.client.GetLyricsBySongId(track.ID)
 		if err != nil || len(lyrics.StructuredLyrics) == 0 {
 			return nil, err
@@ -466,14 +516,7 @@ var _ mediaprovider.CanSavePlayQueue = (*subsonicMediaProvider)(nil)
 var _ mediaprovider.CanReportPlayback = (*subsonicMediaProvider)(nil)
 
 func (s *subsonicMediaProvider) ReportPlayback(trackID string, positionMs int64, state string) error {
-	s.playbackReportOnce.Do(func() {
-		ext, err := s.client.GetOpenSubsonicExtensions()
-		s.playbackReportSupported = err == nil && slices.ContainsFunc(ext,
-			func(e *subsonic.OpenSubsonicExtension) bool {
-				return e.Name == subsonic.PlaybackReport
-			})
-	})
-	if !s.playbackReportSupported {
+	if !s.extensions.has(subsonic.PlaybackReport) {
 		return nil
 	}
 	ignoreScrobble := true
@@ -640,6 +683,7 @@ func toTrack(ch *subsonic.Child) *mediaprovider.Track {
 		SampleRate:       ch.SamplingRate,
 		BitDepth:         ch.BitDepth,
 		Channels:         ch.ChannelCount,
+		MusicBrainzID:    ch.MusicBrainzID,
 	}
 }
 
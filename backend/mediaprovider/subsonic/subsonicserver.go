@@ -1,16 +1,33 @@
 package subsonic
 
 import (
+	"net/http"
+
 	"github.com/dweymouth/supersonic/backend/mediaprovider"
 	subsonicCli "github.com/supersonic-app/go-subsonic/subsonic"
 )
 
 type SubsonicServer struct {
 	subsonicCli.Client
+
+	// Authenticate with an OpenSubsonic API key, given as the password
+	APIKeyAuth bool
+
+	// the OpenSubsonic extensions detected at login
+	extensions extensionSet
 }
 
 func (s *SubsonicServer) Login(username, password string) mediaprovider.LoginResponse {
 	s.User = username
+	if s.APIKeyAuth {
+		base := s.Client.Client.Transport
+		if t, ok := base.(*apiKeyTransport); ok {
+			base = t.base
+		} else if base == nil {
+			base = http.DefaultTransport
+		}
+		s.Client.Client.Transport = &apiKeyTransport{base: base, apiKey: password}
+	}
 
 	pr, err := s.Client.Ping()
 	if err != nil {
@@ -25,6 +42,9 @@ func (s *SubsonicServer) Login(username, password string) mediaprovider.LoginRes
 		s.Client.UseJSON = false
 	}
 	err = s.Client.Authenticate(password)
+	if err == nil {
+		s.extensions = fetchExtensions(&s.Client, pr.OpenSubsonic)
+	}
 	return mediaprovider.LoginResponse{
 		Error:       err,
 		IsAuthError: err == subsonicCli.ErrAuthenticationFailure,
@@ -32,5 +52,5 @@ func (s *SubsonicServer) Login(username, password string) mediaprovider.LoginRes
 }
 
 func (s *SubsonicServer) MediaProvider() mediaprovider.MediaProvider {
-	return SubsonicMediaProvider(&s.Client)
+	return newSubsonicMediaProvider(&s.Client, s.extensions)
 }
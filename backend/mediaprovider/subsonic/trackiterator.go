@@ -1,8 +1,6 @@
 package subsonic
 
 import (
-	"log"
-
 	"github.com/dweymouth/supersonic/backend/mediaprovider"
 	"github.com/supersonic-app/go-subsonic/subsonic"
 )
@@ -51,7 +49,7 @@ func (a *allTracksIterator) Next() *mediaprovider.Track {
 			}
 			alWithTracks, err := a.s.GetAlbum(al.ID)
 			if err != nil || alWithTracks == nil {
-				log.Printf("error fetching album: %s", err.Error())
+				logger.Error("error fetching album", "err", err)
 				continue // try next album
 			}
 			haveNextAlbum = true
@@ -97,7 +95,7 @@ func (s *searchTracksIterator) Next() *mediaprovider.Track {
 			for _, artist := range results.Artist {
 				artist, err := s.s.GetArtist(artist.ID)
 				if err != nil {
-					log.Printf("error fetching artist: %s", err.Error())
+					logger.Error("error fetching artist", "err", err)
 				} else {
 					s.addNewTracksFromAlbums(artist.Album)
 				}
@@ -141,7 +139,7 @@ func (s *searchTracksIterator) addNewTracks(tracks []*subsonic.Child) {
 func (s *searchTracksIterator) addNewTracksFromAlbums(albums []*subsonic.AlbumID3) {
 	for _, al := range albums {
 		if album, err := s.s.GetAlbum(al.ID); err != nil {
-			log.Printf("error fetching album: %s", err.Error())
+			logger.Error("error fetching album", "err", err)
 		} else {
 			s.addNewTracks(album.Song)
 		}
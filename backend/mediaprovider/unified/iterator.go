@@ -0,0 +1,120 @@
+package unified
+
+import (
+	"strings"
+
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+)
+
+// mergeIterator merges the iterators of the member servers. If less is
+// non-nil, the iterators are assumed to be sorted by it and are merged in
+// order; otherwise (for sort orders such as Recently Added, which can't be
+// compared across servers) items are taken from each iterator in turn.
+type mergeIterator[M any] struct {
+	iters []mediaprovider.MediaIterator[M]
+	less  func(a, b *M) bool
+
+	heads   []*M
+	started bool
+	turn    int
+}
+
+func newMergeIterator[M any](iters []mediaprovider.MediaIterator[M], less func(a, b *M) bool) *mergeIterator[M] {
+	return &mergeIterator[M]{iters: iters, less: less, heads: make([]*M, len(iters))}
+}
+
+func (m *mergeIterator[M]) Next() *M {
+	if !m.started {
+		m.started = true
+		for i, it := range m.iters {
+			m.heads[i] = it.Next()
+		}
+	}
+	next := -1
+	if m.less == nil {
+		for k := range m.heads {
+			i := (m.turn + k) % len(m.heads)
+			if m.heads[i] != nil {
+				next = i
+				break
+			}
+		}
+		m.turn = next + 1
+	} else {
+		for i, h := range m.heads {
+			if h != nil && (next < 0 || m.less(h, m.heads[next])) {
+				next = i
+			}
+		}
+	}
+	if next < 0 {
+		return nil
+	}
+	item := m.heads[next]
+	m.heads[next] = m.iters[next].Next()
+	return item
+}
+
+// nsIterator namespaces the IDs of the items of a member's iterator.
+type nsIterator[M any] struct {
+	iter mediaprovider.MediaIterator[M]
+	ns   func(*M) *M
+}
+
+func (n *nsIterator[M]) Next() *M {
+	if item := n.iter.Next(); item != nil {
+		return n.ns(item)
+	}
+	return nil
+}
+
+// albumLess returns the comparison of albums in the given sort order,
+// or nil if albums in that order can't be compared across servers.
+func albumLess(sortOrder string) func(a, b *mediaprovider.Album) bool {
+	switch sortOrder {
+	case mediaprovider.AlbumSortTitleAZ:
+		return func(a, b *mediaprovider.Album) bool {
+			return foldLess(albumSortName(a), albumSortName(b))
+		}
+	case mediaprovider.AlbumSortArtistAZ:
+		return func(a, b *mediaprovider.Album) bool {
+			return foldLess(strings.Join(a.ArtistNames, ", "), strings.Join(b.ArtistNames, ", "))
+		}
+	case mediaprovider.AlbumSortYearAscending:
+		return func(a, b *mediaprovider.Album) bool {
+			return a.YearOrZero() < b.YearOrZero()
+		}
+	case mediaprovider.AlbumSortYearDescending:
+		return func(a, b *mediaprovider.Album) bool {
+			return a.YearOrZero() > b.YearOrZero()
+		}
+	}
+	return nil
+}
+
+// artistLess returns the comparison of artists in the given sort order,
+// or nil if artists in that order can't be compared across servers.
+func artistLess(sortOrder string) func(a, b *mediaprovider.Artist) bool {
+	switch sortOrder {
+	case mediaprovider.ArtistSortNameAZ:
+		return func(a, b *mediaprovider.Artist) bool {
+			return foldLess(a.Name, b.Name)
+		}
+	case mediaprovider.ArtistSortAlbumCount:
+		return func(a, b *mediaprovider.Artist) bool {
+			return a.AlbumCount > b.AlbumCount
+		}
+	}
+	return nil
+}
+
+func albumSortName(a *mediaprovider.Album) string {
+	if a.SortName != "" {
+		return a.SortName
+	}
+	return a.Name
+}
+
+func foldLess(a, b string) bool {
+	return strings.ToLower(a) < strings.ToLower(b)
+}
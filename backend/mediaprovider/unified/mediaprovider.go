@@ -0,0 +1,674 @@
+package unified
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math/rand"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+	"github.com/dweymouth/supersonic/backend/mediaprovider/helpers"
+	"github.com/dweymouth/supersonic/sharedutil"
+)
+
+// MediaProvider presents the libraries of several servers as one.
+// Library-wide calls fan out to the member servers in parallel and merge
+// the results, and the IDs of all items are namespaced by the member key,
+// so that calls on an item are routed to the server it belongs to.
+type MediaProvider struct {
+	members []*member
+
+	// member whose library is selected, if any
+	libraryMember *member
+}
+
+var (
+	_ mediaprovider.MediaProvider     = (*MediaProvider)(nil)
+	_ mediaprovider.SupportsRating    = (*MediaProvider)(nil)
+	_ mediaprovider.SupportsSharing   = (*MediaProvider)(nil)
+	_ mediaprovider.CanReportPlayback = (*MediaProvider)(nil)
+	_ mediaprovider.LyricsProvider    = (*MediaProvider)(nil)
+	_ mediaprovider.RadioProvider     = (*MediaProvider)(nil)
+)
+
+func (p *MediaProvider) SetPrefetchCoverCallback(cb func(coverArtID string)) {
+	for _, m := range p.members {
+		if cb == nil {
+			m.mp.SetPrefetchCoverCallback(nil)
+			continue
+		}
+		m.mp.SetPrefetchCoverCallback(func(coverArtID string) {
+			cb(m.ns(coverArtID))
+		})
+	}
+}
+
+// GetLibraries returns the libraries of all member servers,
+// with the member's name prepended.
+func (p *MediaProvider) GetLibraries() ([]mediaprovider.Library, error) {
+	var libraries []mediaprovider.Library
+	var err error
+	for _, m := range p.members {
+		libs, e := m.mp.GetLibraries()
+		if e != nil {
+			err = e
+			continue
+		}
+		if len(libs) == 0 {
+			// select the member's whole library
+			libs = []mediaprovider.Library{{Name: m.Name}}
+		}
+		for _, l := range libs {
+			name := m.Name
+			if l.Name != m.Name {
+				name = fmt.Sprintf("%s: %s", m.Name, l.Name)
+			}
+			libraries = append(libraries, mediaprovider.Library{ID: m.Key + idSeparator + l.ID, Name: name})
+		}
+	}
+	if len(libraries) == 0 {
+		return nil, err
+	}
+	return libraries, nil
+}
+
+// SetLibrary restricts the library to a library of one member server,
+// or with the empty string, resets it to the libraries of all members.
+func (p *MediaProvider) SetLibrary(id string) error {
+	if id == "" {
+		p.libraryMember = nil
+		return errors.Join(sharedutil.MapSlice(p.members, func(m *member) error {
+			return m.mp.SetLibrary("")
+		})...)
+	}
+	m, libID, err := p.route(id)
+	if err != nil {
+		return err
+	}
+	p.libraryMember = m
+	return m.mp.SetLibrary(libID)
+}
+
+func (p *MediaProvider) GetTrack(trackID string) (*mediaprovider.Track, error) {
+	m, id, err := p.route(trackID)
+	if err != nil {
+		return nil, err
+	}
+	tr, err := m.mp.GetTrack(id)
+	return m.track(tr), err
+}
+
+func (p *MediaProvider) GetAlbum(albumID string) (*mediaprovider.AlbumWithTracks, error) {
+	m, id, err := p.route(albumID)
+	if err != nil {
+		return nil, err
+	}
+	al, err := m.mp.GetAlbum(id)
+	if err != nil {
+		return nil, err
+	}
+	return &mediaprovider.AlbumWithTracks{Album: *m.album(&al.Album), Tracks: m.tracks(al.Tracks)}, nil
+}
+
+func (p *MediaProvider) GetAlbumInfo(albumID string) (*mediaprovider.AlbumInfo, error) {
+	m, id, err := p.route(albumID)
+	if err != nil {
+		return nil, err
+	}
+	return m.mp.GetAlbumInfo(id)
+}
+
+func (p *MediaProvider) GetArtist(artistID string) (*mediaprovider.ArtistWithAlbums, error) {
+	m, id, err := p.route(artistID)
+	if err != nil {
+		return nil, err
+	}
+	ar, err := m.mp.GetArtist(id)
+	if err != nil {
+		return nil, err
+	}
+	return &mediaprovider.ArtistWithAlbums{
+		Artist: *m.artist(&ar.Artist),
+		Albums: sharedutil.MapSlice(ar.Albums, m.album),
+	}, nil
+}
+
+func (p *MediaProvider) GetArtistTracks(artistID string) ([]*mediaprovider.Track, error) {
+	m, id, err := p.route(artistID)
+	if err != nil {
+		return nil, err
+	}
+	trs, err := m.mp.GetArtistTracks(id)
+	return m.tracks(trs), err
+}
+
+func (p *MediaProvider) GetArtistInfo(artistID string) (*mediaprovider.ArtistInfo, error) {
+	m, id, err := p.route(artistID)
+	if err != nil {
+		return nil, err
+	}
+	info, err := m.mp.GetArtistInfo(id)
+	if err != nil {
+		return nil, err
+	}
+	i := *info
+	i.SimilarArtists = sharedutil.MapSlice(info.SimilarArtists, m.artist)
+	return &i, nil
+}
+
+func (p *MediaProvider) GetPlaylist(playlistID string) (*mediaprovider.PlaylistWithTracks, error) {
+	m, id, err := p.route(playlistID)
+	if err != nil {
+		return nil, err
+	}
+	pl, err := m.mp.GetPlaylist(id)
+	if err != nil {
+		return nil, err
+	}
+	return &mediaprovider.PlaylistWithTracks{Playlist: *m.playlist(&pl.Playlist), Tracks: m.tracks(pl.Tracks)}, nil
+}
+
+func (p *MediaProvider) GetCoverArt(coverArtID string, size int) (image.Image, error) {
+	m, id, err := p.route(coverArtID)
+	if err != nil {
+		return nil, err
+	}
+	return m.mp.GetCoverArt(id, size)
+}
+
+// AlbumSortOrders returns the sort orders supported by all member servers.
+func (p *MediaProvider) AlbumSortOrders() []string {
+	return commonSortOrders(p.members, func(mp mediaprovider.MediaProvider) []string { return mp.AlbumSortOrders() })
+}
+
+func (p *MediaProvider) IterateAlbums(sortOrder string, filter mediaprovider.AlbumFilter) mediaprovider.AlbumIterator {
+	return p.mergeAlbums(albumLess(sortOrder), func(m *member) mediaprovider.AlbumIterator {
+		return m.mp.IterateAlbums(sortOrder, cloneFilter(filter))
+	})
+}
+
+func (p *MediaProvider) IterateTracks(searchQuery string) mediaprovider.TrackIterator {
+	var iters []mediaprovider.TrackIterator
+	for _, m := range p.active() {
+		iters = append(iters, &nsIterator[mediaprovider.Track]{iter: m.mp.IterateTracks(searchQuery), ns: m.track})
+	}
+	return newMergeIterator(iters, nil)
+}
+
+func (p *MediaProvider) SearchAlbums(searchQuery string, filter mediaprovider.AlbumFilter) mediaprovider.AlbumIterator {
+	return p.mergeAlbums(nil, func(m *member) mediaprovider.AlbumIterator {
+		return m.mp.SearchAlbums(searchQuery, cloneFilter(filter))
+	})
+}
+
+func (p *MediaProvider) mergeAlbums(less func(a, b *mediaprovider.Album) bool, iter func(*member) mediaprovider.AlbumIterator) mediaprovider.AlbumIterator {
+	var iters []mediaprovider.AlbumIterator
+	for _, m := range p.active() {
+		iters = append(iters, &nsIterator[mediaprovider.Album]{iter: iter(m), ns: m.album})
+	}
+	return newMergeIterator(iters, less)
+}
+
+func (p *MediaProvider) SearchAll(searchQuery string, maxResults int) ([]*mediaprovider.SearchResult, error) {
+	var mu sync.Mutex
+	var results []*mediaprovider.SearchResult
+	genres := make(map[string]*mediaprovider.SearchResult)
+	err := p.fanOutAny(func(m *member) error {
+		res, err := m.mp.SearchAll(searchQuery, maxResults)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, r := range res {
+			sr := m.searchResult(r)
+			if r.Type == mediaprovider.ContentTypeGenre {
+				// merge genres of the same name
+				key := strings.ToLower(r.Name)
+				if g, ok := genres[key]; ok {
+					g.Size += max(r.Size, 0)
+					continue
+				}
+				genres[key] = sr
+			}
+			results = append(results, sr)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	q := helpers.ParseSearchQuery(searchQuery)
+	helpers.RankSearchResults(results, q.Text(), q.Terms)
+	if len(results) > maxResults {
+		results = results[:maxResults]
+	}
+	return results, nil
+}
+
+func (p *MediaProvider) GetRandomTracks(genre string, count int) ([]*mediaprovider.Track, error) {
+	members := p.active()
+	perMember := (count + len(members) - 1) / max(len(members), 1)
+	tracks, err := p.collectTracks(func(m *member) ([]*mediaprovider.Track, error) {
+		return m.mp.GetRandomTracks(genre, perMember)
+	})
+	rand.Shuffle(len(tracks), func(i, j int) { tracks[i], tracks[j] = tracks[j], tracks[i] })
+	if len(tracks) > count {
+		tracks = tracks[:count]
+	}
+	return tracks, err
+}
+
+func (p *MediaProvider) GetSimilarTracks(artistID string, count int) ([]*mediaprovider.Track, error) {
+	m, id, err := p.route(artistID)
+	if err != nil {
+		return nil, err
+	}
+	trs, err := m.mp.GetSimilarTracks(id, count)
+	return m.tracks(trs), err
+}
+
+func (p *MediaProvider) GetSongRadio(trackID string, count int) ([]*mediaprovider.Track, error) {
+	m, id, err := p.route(trackID)
+	if err != nil {
+		return nil, err
+	}
+	trs, err := m.mp.GetSongRadio(id, count)
+	return m.tracks(trs), err
+}
+
+// ArtistSortOrders returns the sort orders supported by all member servers.
+func (p *MediaProvider) ArtistSortOrders() []string {
+	return commonSortOrders(p.members, func(mp mediaprovider.MediaProvider) []string { return mp.ArtistSortOrders() })
+}
+
+func (p *MediaProvider) IterateArtists(sortOrder string, filter mediaprovider.ArtistFilter) mediaprovider.ArtistIterator {
+	return p.mergeArtists(artistLess(sortOrder), func(m *member) mediaprovider.ArtistIterator {
+		return m.mp.IterateArtists(sortOrder, cloneFilter(filter))
+	})
+}
+
+func (p *MediaProvider) SearchArtists(searchQuery string, filter mediaprovider.ArtistFilter) mediaprovider.ArtistIterator {
+	return p.mergeArtists(nil, func(m *member) mediaprovider.ArtistIterator {
+		return m.mp.SearchArtists(searchQuery, cloneFilter(filter))
+	})
+}
+
+func (p *MediaProvider) mergeArtists(less func(a, b *mediaprovider.Artist) bool, iter func(*member) mediaprovider.ArtistIterator) mediaprovider.ArtistIterator {
+	var iters []mediaprovider.ArtistIterator
+	for _, m := range p.active() {
+		iters = append(iters, &nsIterator[mediaprovider.Artist]{iter: iter(m), ns: m.artist})
+	}
+	return newMergeIterator(iters, less)
+}
+
+// GetGenres returns the genres of all member servers,
+// merging genres of the same name.
+func (p *MediaProvider) GetGenres() ([]*mediaprovider.Genre, error) {
+	var mu sync.Mutex
+	var genres []*mediaprovider.Genre
+	byName := make(map[string]*mediaprovider.Genre)
+	err := p.fanOutAny(func(m *member) error {
+		gs, err := m.mp.GetGenres()
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, g := range gs {
+			key := strings.ToLower(g.Name)
+			if merged, ok := byName[key]; ok {
+				merged.AlbumCount += g.AlbumCount
+				merged.TrackCount += g.TrackCount
+				continue
+			}
+			genre := *g
+			byName[key] = &genre
+			genres = append(genres, &genre)
+		}
+		return nil
+	})
+	return genres, err
+}
+
+func (p *MediaProvider) GetFavorites() (mediaprovider.Favorites, error) {
+	var mu sync.Mutex
+	var favs mediaprovider.Favorites
+	err := p.fanOutAny(func(m *member) error {
+		f, err := m.mp.GetFavorites()
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		favs.Albums = append(favs.Albums, sharedutil.MapSlice(f.Albums, m.album)...)
+		favs.Artists = append(favs.Artists, sharedutil.MapSlice(f.Artists, m.artist)...)
+		favs.Tracks = append(favs.Tracks, m.tracks(f.Tracks)...)
+		return nil
+	})
+	return favs, err
+}
+
+func (p *MediaProvider) GetStreamURL(trackID string, transcodeSettings *mediaprovider.TranscodeSettings, forceRaw bool) (string, error) {
+	m, id, err := p.route(trackID)
+	if err != nil {
+		return "", err
+	}
+	return m.mp.GetStreamURL(id, transcodeSettings, forceRaw)
+}
+
+func (p *MediaProvider) GetTopTracks(artist mediaprovider.Artist, count int) ([]*mediaprovider.Track, error) {
+	m, id, err := p.route(artist.ID)
+	if err != nil {
+		return nil, err
+	}
+	artist.ID = id
+	artist.CoverArtID = strings.TrimPrefix(artist.CoverArtID, m.Key+idSeparator)
+	trs, err := m.mp.GetTopTracks(artist, count)
+	return m.tracks(trs), err
+}
+
+func (p *MediaProvider) SetFavorite(params mediaprovider.RatingFavoriteParameters, favorite bool) error {
+	return p.forEachMemberParams(params, func(m *member, params mediaprovider.RatingFavoriteParameters) error {
+		return m.mp.SetFavorite(params, favorite)
+	})
+}
+
+func (p *MediaProvider) SetRating(params mediaprovider.RatingFavoriteParameters, rating int) error {
+	return p.forEachMemberParams(params, func(m *member, params mediaprovider.RatingFavoriteParameters) error {
+		if r, ok := m.mp.(mediaprovider.SupportsRating); ok {
+			return r.SetRating(params, rating)
+		}
+		return errUnsupported
+	})
+}
+
+// forEachMemberParams splits the parameters by the member servers
+// that own the items, and calls f for each.
+func (p *MediaProvider) forEachMemberParams(params mediaprovider.RatingFavoriteParameters, f func(*member, mediaprovider.RatingFavoriteParameters) error) error {
+	albums, err1 := p.groupByMember(params.AlbumIDs)
+	artists, err2 := p.groupByMember(params.ArtistIDs)
+	tracks, err3 := p.groupByMember(params.TrackIDs)
+	errs := []error{err1, err2, err3}
+	for _, m := range p.members {
+		memberParams := mediaprovider.RatingFavoriteParameters{
+			AlbumIDs:  albums[m],
+			ArtistIDs: artists[m],
+			TrackIDs:  tracks[m],
+		}
+		if len(memberParams.AlbumIDs)+len(memberParams.ArtistIDs)+len(memberParams.TrackIDs) > 0 {
+			errs = append(errs, f(m, memberParams))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *MediaProvider) GetPlaylists() ([]*mediaprovider.Playlist, error) {
+	var mu sync.Mutex
+	var playlists []*mediaprovider.Playlist
+	err := p.fanOutAny(func(m *member) error {
+		pls, err := m.mp.GetPlaylists()
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		playlists = append(playlists, sharedutil.MapSlice(pls, m.playlist)...)
+		mu.Unlock()
+		return nil
+	})
+	slices.SortStableFunc(playlists, func(a, b *mediaprovider.Playlist) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	return playlists, err
+}
+
+// CreatePlaylistWithTracks creates a playlist of the given name on each
+// member server that owns some of the tracks, since a playlist can only
+// contain tracks of its own server.
+func (p *MediaProvider) CreatePlaylistWithTracks(name string, trackIDs []string) error {
+	groups, err := p.groupByMember(trackIDs)
+	errs := []error{err}
+	for _, m := range p.members {
+		if ids := groups[m]; len(ids) > 0 {
+			errs = append(errs, m.mp.CreatePlaylistWithTracks(name, ids))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CanMakePublicPlaylist returns whether new playlists,
+// which are created on the first member server, can be public.
+func (p *MediaProvider) CanMakePublicPlaylist() bool {
+	return len(p.members) > 0 && p.members[0].mp.CanMakePublicPlaylist()
+}
+
+// CreatePlaylist creates an empty playlist on the first member server.
+func (p *MediaProvider) CreatePlaylist(name, description string, public bool) error {
+	if len(p.members) == 0 {
+		return errNoMembers
+	}
+	return p.members[0].mp.CreatePlaylist(name, description, public)
+}
+
+func (p *MediaProvider) EditPlaylist(id, name, description string, public bool) error {
+	m, plID, err := p.route(id)
+	if err != nil {
+		return err
+	}
+	return m.mp.EditPlaylist(plID, name, description, public)
+}
+
+// AddPlaylistTracks adds the tracks that belong to the playlist's server,
+// returning an error if there were tracks of other servers.
+func (p *MediaProvider) AddPlaylistTracks(id string, trackIDsToAdd []string) error {
+	m, plID, ids, err := p.playlistTracks(id, trackIDsToAdd)
+	if m == nil {
+		return err
+	}
+	return errors.Join(m.mp.AddPlaylistTracks(plID, ids), err)
+}
+
+func (p *MediaProvider) RemovePlaylistTracks(id string, trackIdxsToRemove []int) error {
+	m, plID, err := p.route(id)
+	if err != nil {
+		return err
+	}
+	return m.mp.RemovePlaylistTracks(plID, trackIdxsToRemove)
+}
+
+// ReplacePlaylistTracks replaces the playlist's tracks with those that
+// belong to its server, returning an error if there were tracks of other servers.
+func (p *MediaProvider) ReplacePlaylistTracks(id string, trackIDs []string) error {
+	m, plID, ids, err := p.playlistTracks(id, trackIDs)
+	if m == nil {
+		return err
+	}
+	return errors.Join(m.mp.ReplacePlaylistTracks(plID, ids), err)
+}
+
+// playlistTracks routes the playlist ID, and filters the track IDs
+// to those on the playlist's server.
+func (p *MediaProvider) playlistTracks(id string, trackIDs []string) (*member, string, []string, error) {
+	m, plID, err := p.route(id)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	groups, _ := p.groupByMember(trackIDs)
+	ids := groups[m]
+	if len(ids) < len(trackIDs) {
+		err = errOtherServers
+	}
+	return m, plID, ids, err
+}
+
+func (p *MediaProvider) DeletePlaylist(id string) error {
+	m, plID, err := p.route(id)
+	if err != nil {
+		return err
+	}
+	return m.mp.DeletePlaylist(plID)
+}
+
+// ClientDecidesScrobble returns true, since the submission flag
+// is simply ignored by the member servers that decide for themselves.
+func (p *MediaProvider) ClientDecidesScrobble() bool {
+	return true
+}
+
+func (p *MediaProvider) TrackBeganPlayback(trackID string) error {
+	m, id, err := p.route(trackID)
+	if err != nil {
+		return err
+	}
+	return m.mp.TrackBeganPlayback(id)
+}
+
+func (p *MediaProvider) TrackEndedPlayback(trackID string, positionSecs int, submission bool) error {
+	m, id, err := p.route(trackID)
+	if err != nil {
+		return err
+	}
+	return m.mp.TrackEndedPlayback(id, positionSecs, submission)
+}
+
+func (p *MediaProvider) ReportPlayback(trackID string, positionMs int64, state string) error {
+	m, id, err := p.route(trackID)
+	if err != nil {
+		return err
+	}
+	if r, ok := m.mp.(mediaprovider.CanReportPlayback); ok {
+		return r.ReportPlayback(id, positionMs, state)
+	}
+	return nil
+}
+
+func (p *MediaProvider) DownloadTrack(trackID string) (io.Reader, error) {
+	m, id, err := p.route(trackID)
+	if err != nil {
+		return nil, err
+	}
+	return m.mp.DownloadTrack(id)
+}
+
+func (p *MediaProvider) RescanLibrary() error {
+	return p.fanOut(func(m *member) error {
+		return m.mp.RescanLibrary()
+	})
+}
+
+func (p *MediaProvider) CreateShareURL(id string) (*url.URL, error) {
+	m, itemID, err := p.route(id)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := m.mp.(mediaprovider.SupportsSharing); ok {
+		return s.CreateShareURL(itemID)
+	}
+	return nil, errUnsupported
+}
+
+// CanShareArtists returns true if all member servers can share artists.
+func (p *MediaProvider) CanShareArtists() bool {
+	for _, m := range p.members {
+		if s, ok := m.mp.(mediaprovider.SupportsSharing); !ok || !s.CanShareArtists() {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *MediaProvider) GetLyrics(track *mediaprovider.Track) (*mediaprovider.Lyrics, error) {
+	m, _, err := p.route(track.ID)
+	if err != nil {
+		return nil, err
+	}
+	if lp, ok := m.mp.(mediaprovider.LyricsProvider); ok {
+		return lp.GetLyrics(m.unNS(track))
+	}
+	return nil, errUnsupported
+}
+
+func (p *MediaProvider) GetRadioStation(id string) (*mediaprovider.RadioStation, error) {
+	m, rID, err := p.route(id)
+	if err != nil {
+		return nil, err
+	}
+	if rp, ok := m.mp.(mediaprovider.RadioProvider); ok {
+		r, err := rp.GetRadioStation(rID)
+		return m.radio(r), err
+	}
+	return nil, errUnsupported
+}
+
+func (p *MediaProvider) GetRadioStations() ([]*mediaprovider.RadioStation, error) {
+	var mu sync.Mutex
+	var radios []*mediaprovider.RadioStation
+	err := p.fanOutAny(func(m *member) error {
+		rp, ok := m.mp.(mediaprovider.RadioProvider)
+		if !ok {
+			return nil
+		}
+		rs, err := rp.GetRadioStations()
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		radios = append(radios, sharedutil.MapSlice(rs, m.radio)...)
+		mu.Unlock()
+		return nil
+	})
+	return radios, err
+}
+
+// IsOwnPlaylist returns whether the playlist is owned by
+// the logged in user of the member server it belongs to.
+func (p *MediaProvider) IsOwnPlaylist(playlist *mediaprovider.Playlist) bool {
+	m, _, err := p.route(playlist.ID)
+	return err == nil && strings.EqualFold(playlist.Owner, m.Username)
+}
+
+func (p *MediaProvider) collectTracks(f func(m *member) ([]*mediaprovider.Track, error)) ([]*mediaprovider.Track, error) {
+	var mu sync.Mutex
+	var tracks []*mediaprovider.Track
+	err := p.fanOutAny(func(m *member) error {
+		trs, err := f(m)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		tracks = append(tracks, m.tracks(trs)...)
+		mu.Unlock()
+		return nil
+	})
+	return tracks, err
+}
+
+// commonSortOrders returns the sort orders supported by all members,
+// in the order of the first member.
+func commonSortOrders(members []*member, sortOrders func(mediaprovider.MediaProvider) []string) []string {
+	if len(members) == 0 {
+		return nil
+	}
+	common := sortOrders(members[0].mp)
+	for _, m := range members[1:] {
+		other := sortOrders(m.mp)
+		common = slices.DeleteFunc(slices.Clone(common), func(s string) bool {
+			return !slices.Contains(other, s)
+		})
+	}
+	return common
+}
+
+// cloneFilter returns a copy of the filter for each member's iterator,
+// since iterators may hold on to their filter.
+func cloneFilter[M, F any](filter mediaprovider.MediaFilter[M, F]) mediaprovider.MediaFilter[M, F] {
+	if filter == nil {
+		return nil
+	}
+	return filter.Clone()
+}
@@ -0,0 +1,287 @@
+package unified
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+)
+
+// separates the member key from the member server's own ID
+const idSeparator = ":"
+
+var (
+	errNoMembers    = errors.New("no member servers are connected")
+	errUnknownID    = errors.New("item does not belong to a member server")
+	errUnsupported  = errors.New("not supported by the server of this item")
+	errOtherServers = errors.New("tracks from other servers can't be added to this playlist")
+)
+
+// Server is a unified library of several member servers,
+// which must already be logged in to.
+type Server struct {
+	Members []Member
+}
+
+// Member is one of the servers of a unified library.
+type Member struct {
+	// Key namespaces the IDs of the member's items. It must be unique
+	// and stable, since IDs may be saved (e.g. in the play queue).
+	Key string
+	// Display name of the member server
+	Name     string
+	Username string
+	Server   mediaprovider.Server
+}
+
+// Login succeeds if there are any member servers. The members are logged
+// in to individually, so username and password are ignored.
+func (s *Server) Login(_, _ string) mediaprovider.LoginResponse {
+	if len(s.Members) == 0 {
+		return mediaprovider.LoginResponse{Error: errNoMembers}
+	}
+	return mediaprovider.LoginResponse{}
+}
+
+func (s *Server) MediaProvider() mediaprovider.MediaProvider {
+	p := &MediaProvider{}
+	for _, m := range s.Members {
+		p.members = append(p.members, &member{Member: m, mp: m.Server.MediaProvider()})
+	}
+	return p
+}
+
+type member struct {
+	Member
+	mp mediaprovider.MediaProvider
+}
+
+// ns namespaces an ID of the member's item.
+func (m *member) ns(id string) string {
+	if id == "" {
+		return ""
+	}
+	return m.Key + idSeparator + id
+}
+
+func (m *member) nsAll(ids []string) []string {
+	if ids == nil {
+		return nil
+	}
+	nsIDs := make([]string, len(ids))
+	for i, id := range ids {
+		nsIDs[i] = m.ns(id)
+	}
+	return nsIDs
+}
+
+// MemberName returns the name of the server that an item belongs to,
+// given the item's ID, or the empty string if the ID is not namespaced.
+func (p *MediaProvider) MemberName(id string) string {
+	if m, _, err := p.route(id); err == nil {
+		return m.Name
+	}
+	return ""
+}
+
+// MemberKey returns the key of the server that an item belongs to,
+// given the item's ID, or the empty string if the ID is not namespaced.
+func (p *MediaProvider) MemberKey(id string) string {
+	if m, _, err := p.route(id); err == nil {
+		return m.Key
+	}
+	return ""
+}
+
+// route returns the member that owns the item with the namespaced ID,
+// and the member's own ID for the item.
+func (p *MediaProvider) route(id string) (*member, string, error) {
+	key, memberID, ok := strings.Cut(id, idSeparator)
+	if ok {
+		for _, m := range p.members {
+			if m.Key == key {
+				return m, memberID, nil
+			}
+		}
+	}
+	return nil, "", errUnknownID
+}
+
+// groupByMember groups the namespaced IDs by the member that owns them,
+// returning the members' own IDs.
+func (p *MediaProvider) groupByMember(ids []string) (map[*member][]string, error) {
+	groups := make(map[*member][]string)
+	var err error
+	for _, id := range ids {
+		m, memberID, e := p.route(id)
+		if e != nil {
+			err = e
+			continue
+		}
+		groups[m] = append(groups[m], memberID)
+	}
+	return groups, err
+}
+
+// active returns the members that the library calls fan out to:
+// all members, or only the owner of the selected library.
+func (p *MediaProvider) active() []*member {
+	if p.libraryMember != nil {
+		return []*member{p.libraryMember}
+	}
+	return p.members
+}
+
+// fanOut calls f for each active member in parallel,
+// returning the errors of the calls that failed.
+func (p *MediaProvider) fanOut(f func(m *member) error) error {
+	members := p.active()
+	errs := make([]error, len(members))
+	var wg sync.WaitGroup
+	for i, m := range members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = f(m)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// fanOutAny is like fanOut, but only returns an error if all calls failed,
+// so that the library stays usable while some member servers are down.
+func (p *MediaProvider) fanOutAny(f func(m *member) error) error {
+	members := p.active()
+	var mu sync.Mutex
+	var errs []error
+	err := p.fanOut(func(m *member) error {
+		e := f(m)
+		if e != nil {
+			mu.Lock()
+			errs = append(errs, e)
+			mu.Unlock()
+		}
+		return e
+	})
+	if len(errs) < len(members) {
+		return nil
+	}
+	return err
+}
+
+func (m *member) album(al *mediaprovider.Album) *mediaprovider.Album {
+	if al == nil {
+		return nil
+	}
+	a := *al
+	a.ID = m.ns(a.ID)
+	a.CoverArtID = m.ns(a.CoverArtID)
+	a.ArtistIDs = m.nsAll(a.ArtistIDs)
+	return &a
+}
+
+func (m *member) artist(ar *mediaprovider.Artist) *mediaprovider.Artist {
+	if ar == nil {
+		return nil
+	}
+	a := *ar
+	a.ID = m.ns(a.ID)
+	a.CoverArtID = m.ns(a.CoverArtID)
+	return &a
+}
+
+func (m *member) track(tr *mediaprovider.Track) *mediaprovider.Track {
+	if tr == nil {
+		return nil
+	}
+	t := *tr
+	t.ID = m.ns(t.ID)
+	t.CoverArtID = m.ns(t.CoverArtID)
+	t.ParentID = m.ns(t.ParentID)
+	t.AlbumID = m.ns(t.AlbumID)
+	t.ArtistIDs = m.nsAll(t.ArtistIDs)
+	t.AlbumArtistIDs = m.nsAll(t.AlbumArtistIDs)
+	t.ComposerIDs = m.nsAll(t.ComposerIDs)
+	return &t
+}
+
+func (m *member) tracks(trs []*mediaprovider.Track) []*mediaprovider.Track {
+	if trs == nil {
+		return nil
+	}
+	nsTracks := make([]*mediaprovider.Track, len(trs))
+	for i, tr := range trs {
+		nsTracks[i] = m.track(tr)
+	}
+	return nsTracks
+}
+
+func (m *member) playlist(pl *mediaprovider.Playlist) *mediaprovider.Playlist {
+	if pl == nil {
+		return nil
+	}
+	p := *pl
+	p.ID = m.ns(p.ID)
+	p.CoverArtID = m.ns(p.CoverArtID)
+	return &p
+}
+
+func (m *member) radio(r *mediaprovider.RadioStation) *mediaprovider.RadioStation {
+	if r == nil {
+		return nil
+	}
+	rs := *r
+	rs.ID = m.ns(rs.ID)
+	rs.CoverArtID = m.ns(rs.CoverArtID)
+	return &rs
+}
+
+func (m *member) searchResult(r *mediaprovider.SearchResult) *mediaprovider.SearchResult {
+	sr := *r
+	if sr.Type != mediaprovider.ContentTypeGenre {
+		// genres are identified by name, and merged across servers
+		sr.ID = m.ns(sr.ID)
+	}
+	sr.CoverID = m.ns(sr.CoverID)
+	switch item := sr.Item.(type) {
+	case *mediaprovider.Album:
+		sr.Item = m.album(item)
+	case *mediaprovider.Artist:
+		sr.Item = m.artist(item)
+	case *mediaprovider.Track:
+		sr.Item = m.track(item)
+	case *mediaprovider.Playlist:
+		sr.Item = m.playlist(item)
+	case *mediaprovider.RadioStation:
+		sr.Item = m.radio(item)
+	}
+	return &sr
+}
+
+// unNS returns a copy of the track with the member's own IDs.
+func (m *member) unNS(tr *mediaprovider.Track) *mediaprovider.Track {
+	t := *tr
+	strip := func(id string) string {
+		return strings.TrimPrefix(id, m.Key+idSeparator)
+	}
+	stripAll := func(ids []string) []string {
+		if ids == nil {
+			return nil
+		}
+		s := make([]string, len(ids))
+		for i, id := range ids {
+			s[i] = strip(id)
+		}
+		return s
+	}
+	t.ID = strip(t.ID)
+	t.CoverArtID = strip(t.CoverArtID)
+	t.ParentID = strip(t.ParentID)
+	t.AlbumID = strip(t.AlbumID)
+	t.ArtistIDs = stripAll(t.ArtistIDs)
+	t.AlbumArtistIDs = stripAll(t.AlbumArtistIDs)
+	t.ComposerIDs = stripAll(t.ComposerIDs)
+	return &t
+}
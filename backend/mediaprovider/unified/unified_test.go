@@ -0,0 +1,96 @@
+package unified
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+)
+
+type sliceIter[M any] struct {
+	items []*M
+}
+
+func (s *sliceIter[M]) Next() *M {
+	if len(s.items) == 0 {
+		return nil
+	}
+	item := s.items[0]
+	s.items = s.items[1:]
+	return item
+}
+
+func albums(names ...string) *sliceIter[mediaprovider.Album] {
+	it := &sliceIter[mediaprovider.Album]{}
+	for _, n := range names {
+		it.items = append(it.items, &mediaprovider.Album{ID: n, Name: n})
+	}
+	return it
+}
+
+func drain(it mediaprovider.AlbumIterator) []string {
+	var ids []string
+	for al := it.Next(); al != nil; al = it.Next() {
+		ids = append(ids, al.ID)
+	}
+	return ids
+}
+
+func TestMergeIterator_Sorted(t *testing.T) {
+	it := newMergeIterator([]mediaprovider.AlbumIterator{
+		albums("Abbey Road", "Kind of Blue", "Revolver"),
+		albums(),
+		albums("blue train", "Led Zeppelin IV"),
+	}, albumLess(mediaprovider.AlbumSortTitleAZ))
+
+	want := []string{"Abbey Road", "blue train", "Kind of Blue", "Led Zeppelin IV", "Revolver"}
+	if got := drain(it); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMergeIterator_RoundRobin(t *testing.T) {
+	it := newMergeIterator([]mediaprovider.AlbumIterator{
+		albums("a1", "a2", "a3"),
+		albums("b1"),
+		albums("c1", "c2"),
+	}, albumLess(mediaprovider.AlbumSortRecentlyAdded))
+
+	want := []string{"a1", "b1", "c1", "a2", "c2", "a3"}
+	if got := drain(it); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRoute(t *testing.T) {
+	a := &member{Member: Member{Key: "aaaa", Name: "A"}}
+	b := &member{Member: Member{Key: "bbbb", Name: "B"}}
+	p := &MediaProvider{members: []*member{a, b}}
+
+	tr := b.track(&mediaprovider.Track{ID: "t:1", AlbumID: "al1", ArtistIDs: []string{"ar1"}})
+	if tr.ID != "bbbb:t:1" || tr.AlbumID != "bbbb:al1" || tr.ArtistIDs[0] != "bbbb:ar1" {
+		t.Errorf("track not namespaced: %+v", tr)
+	}
+
+	m, id, err := p.route(tr.ID)
+	if err != nil || m != b || id != "t:1" {
+		t.Errorf("route(%q) = %v, %q, %v", tr.ID, m, id, err)
+	}
+	if orig := m.unNS(tr); orig.ID != "t:1" || orig.AlbumID != "al1" {
+		t.Errorf("unNS: %+v", orig)
+	}
+	if _, _, err := p.route("cccc:1"); err == nil {
+		t.Error("expected error for unknown member key")
+	}
+	if name := p.MemberName("aaaa:1"); name != "A" {
+		t.Errorf("MemberName = %q", name)
+	}
+	if key := p.MemberKey("bbbb:1"); key != "bbbb" {
+		t.Errorf("MemberKey = %q", key)
+	}
+
+	groups, err := p.groupByMember([]string{"aaaa:1", "bbbb:2", "aaaa:3"})
+	if err != nil || !slices.Equal(groups[a], []string{"1", "3"}) || !slices.Equal(groups[b], []string{"2"}) {
+		t.Errorf("groupByMember = %v, %v", groups, err)
+	}
+}
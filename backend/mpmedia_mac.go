@@ -16,7 +16,6 @@ import (
 )
 
 import (
-	"log"
 	"strings"
 	"unsafe"
 
@@ -43,7 +42,7 @@ func os_remote_command_callback(command C.Command, value C.double) {
 	case C.SEEK:
 		mpMediaEventRecipient.OnCommandSeek(float64(value))
 	default:
-		log.Printf("unknown OS command received: %v", command)
+		appLog.Warn("unknown OS command received", "command", command)
 	}
 }
 
@@ -125,7 +124,7 @@ func (mp *MPMediaHandler) updateMetadata(meta *mediaprovider.MediaItemMetadata)
 		var err error
 		if artURL, err = mp.artURLLookup(meta.CoverArtID); err != nil {
 			if meta.CoverArtID != "" {
-				log.Printf("error fetching art url: %s", err.Error())
+				appLog.Error("error fetching art url", "err", err)
 			}
 		}
 	}
@@ -0,0 +1,318 @@
+package backend
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// max number of requests kept in the network log
+const networkLogSize = 1000
+
+const redacted = "REDACTED"
+
+// query parameters and headers that carry credentials
+var (
+	secretQueryParams = []string{"p", "t", "s", "apiKey", "api_key", "token", "access_token", "password"}
+	secretHeaders     = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie",
+		"X-Emby-Authorization", "X-Emby-Token", "X-Mediabrowser-Token"}
+)
+
+// NetworkLogEntry is a request recorded in the network log.
+// The URL and headers are redacted so the log can be shared.
+type NetworkLogEntry struct {
+	Time   time.Time
+	Method string
+	URL    string
+	// 0 if no response was received
+	Status int
+	// the error if no response was received
+	Error string
+	// time until the response headers were received
+	Latency         time.Duration
+	RequestSize     int64
+	ResponseSize    int64
+	RequestHeaders  http.Header
+	ResponseHeaders http.Header
+}
+
+// NetworkLog records the requests sent to the server, for troubleshooting.
+// Nothing is recorded until it is enabled.
+type NetworkLog struct {
+	mu      sync.Mutex
+	enabled bool
+	entries []*NetworkLogEntry
+}
+
+func NewNetworkLog() *NetworkLog {
+	return &NetworkLog{}
+}
+
+// SetEnabled starts or stops recording requests.
+func (n *NetworkLog) SetEnabled(enabled bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.enabled = enabled
+}
+
+func (n *NetworkLog) Enabled() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.enabled
+}
+
+// Entries returns a copy of the recorded requests, oldest first.
+func (n *NetworkLog) Entries() []NetworkLogEntry {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	entries := make([]NetworkLogEntry, len(n.entries))
+	for i, e := range n.entries {
+		entries[i] = *e
+	}
+	return entries
+}
+
+// Clear removes all recorded requests.
+func (n *NetworkLog) Clear() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.entries = nil
+}
+
+func (n *NetworkLog) add(e *NetworkLogEntry) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.entries = append(n.entries, e)
+	if len(n.entries) > networkLogSize {
+		n.entries = n.entries[len(n.entries)-networkLogSize:]
+	}
+}
+
+func (n *NetworkLog) setResponseSize(e *NetworkLogEntry, size int64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	e.ResponseSize = size
+}
+
+// wrap returns a transport that records the requests sent through base.
+// The values of the server's custom headers, which typically carry
+// reverse-proxy credentials, are redacted along with the standard ones.
+func (n *NetworkLog) wrap(base http.RoundTripper, customHeaders map[string]string) http.RoundTripper {
+	t := &networkLogTransport{base: base, log: n}
+	for k := range customHeaders {
+		t.customHeaders = append(t.customHeaders, http.CanonicalHeaderKey(k))
+	}
+	return t
+}
+
+type networkLogTransport struct {
+	base          http.RoundTripper
+	log           *NetworkLog
+	customHeaders []string
+}
+
+func (t *networkLogTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.log.Enabled() {
+		return t.base.RoundTrip(req)
+	}
+	e := &NetworkLogEntry{
+		Time:           time.Now(),
+		Method:         req.Method,
+		URL:            redactURL(req.URL),
+		RequestSize:    max(req.ContentLength, 0),
+		RequestHeaders: redactHeaders(req.Header, t.customHeaders),
+	}
+	resp, err := t.base.RoundTrip(req)
+	e.Latency = time.Since(e.Time)
+	if err != nil {
+		e.Error = err.Error()
+		t.log.add(e)
+		return nil, err
+	}
+	e.Status = resp.StatusCode
+	e.ResponseHeaders = redactHeaders(resp.Header, t.customHeaders)
+	e.ResponseSize = max(resp.ContentLength, 0)
+	t.log.add(e)
+	// count the payload as it is read, since the length is often unknown
+	resp.Body = &countingBody{ReadCloser: resp.Body, onClose: func(n int64) {
+		if n > 0 {
+			t.log.setResponseSize(e, n)
+		}
+	}}
+	return resp, nil
+}
+
+// countingBody counts the bytes read from a response body.
+type countingBody struct {
+	io.ReadCloser
+	n       int64
+	once    sync.Once
+	onClose func(n int64)
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err == io.EOF {
+		b.once.Do(func() { b.onClose(b.n) })
+	}
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	b.once.Do(func() { b.onClose(b.n) })
+	return b.ReadCloser.Close()
+}
+
+// redactURL returns the URL without its user info, and with the
+// values of query parameters that carry credentials redacted.
+func redactURL(u *url.URL) string {
+	r := *u
+	r.User = nil
+	q := r.Query()
+	changed := false
+	for k := range q {
+		for _, secret := range secretQueryParams {
+			if strings.EqualFold(k, secret) {
+				q.Set(k, redacted)
+				changed = true
+			}
+		}
+	}
+	if changed {
+		r.RawQuery = q.Encode()
+	}
+	return r.String()
+}
+
+func redactHeaders(h http.Header, customHeaders []string) http.Header {
+	r := h.Clone()
+	for _, k := range slices.Concat(secretHeaders, customHeaders) {
+		if _, ok := r[k]; ok {
+			r.Set(k, redacted)
+		}
+	}
+	return r
+}
+
+// WriteHAR writes the recorded requests as an HTTP Archive (HAR 1.2),
+// which can be attached to bug reports.
+func (n *NetworkLog) WriteHAR(w io.Writer, appName, appVersion string) error {
+	type nameValue struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	type harRequest struct {
+		Method      string      `json:"method"`
+		URL         string      `json:"url"`
+		HTTPVersion string      `json:"httpVersion"`
+		Cookies     []nameValue `json:"cookies"`
+		Headers     []nameValue `json:"headers"`
+		QueryString []nameValue `json:"queryString"`
+		HeadersSize int         `json:"headersSize"`
+		BodySize    int64       `json:"bodySize"`
+	}
+	type harContent struct {
+		Size     int64  `json:"size"`
+		MimeType string `json:"mimeType"`
+	}
+	type harResponse struct {
+		Status      int         `json:"status"`
+		StatusText  string      `json:"statusText"`
+		HTTPVersion string      `json:"httpVersion"`
+		Cookies     []nameValue `json:"cookies"`
+		Headers     []nameValue `json:"headers"`
+		Content     harContent  `json:"content"`
+		RedirectURL string      `json:"redirectURL"`
+		HeadersSize int         `json:"headersSize"`
+		BodySize    int64       `json:"bodySize"`
+	}
+	type harTimings struct {
+		Send    float64 `json:"send"`
+		Wait    float64 `json:"wait"`
+		Receive float64 `json:"receive"`
+	}
+	type harEntry struct {
+		StartedDateTime string      `json:"startedDateTime"`
+		Time            float64     `json:"time"`
+		Request         harRequest  `json:"request"`
+		Response        harResponse `json:"response"`
+		Cache           struct{}    `json:"cache"`
+		Timings         harTimings  `json:"timings"`
+		Error           string      `json:"_error,omitempty"`
+	}
+	type harCreator struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	type harLog struct {
+		Version string     `json:"version"`
+		Creator harCreator `json:"creator"`
+		Entries []harEntry `json:"entries"`
+	}
+
+	headers := func(h http.Header) []nameValue {
+		nv := []nameValue{}
+		for k, vals := range h {
+			for _, v := range vals {
+				nv = append(nv, nameValue{Name: k, Value: v})
+			}
+		}
+		return nv
+	}
+	har := harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: appName, Version: appVersion},
+		Entries: []harEntry{},
+	}
+	for _, e := range n.Entries() {
+		query := []nameValue{}
+		if u, err := url.Parse(e.URL); err == nil {
+			for k, vals := range u.Query() {
+				for _, v := range vals {
+					query = append(query, nameValue{Name: k, Value: v})
+				}
+			}
+		}
+		ms := float64(e.Latency.Microseconds()) / 1000
+		har.Entries = append(har.Entries, harEntry{
+			StartedDateTime: e.Time.Format(time.RFC3339Nano),
+			Time:            ms,
+			Request: harRequest{
+				Method:      e.Method,
+				URL:         e.URL,
+				HTTPVersion: "HTTP/1.1",
+				Cookies:     []nameValue{},
+				Headers:     headers(e.RequestHeaders),
+				QueryString: query,
+				HeadersSize: -1,
+				BodySize:    e.RequestSize,
+			},
+			Response: harResponse{
+				Status:      e.Status,
+				StatusText:  http.StatusText(e.Status),
+				HTTPVersion: "HTTP/1.1",
+				Cookies:     []nameValue{},
+				Headers:     headers(e.ResponseHeaders),
+				Content: harContent{
+					Size:     e.ResponseSize,
+					MimeType: e.ResponseHeaders.Get("Content-Type"),
+				},
+				HeadersSize: -1,
+				BodySize:    e.ResponseSize,
+			},
+			Timings: harTimings{Send: 0, Wait: ms, Receive: 0},
+			Error:   e.Error,
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Log harLog `json:"log"`
+	}{Log: har})
+}
@@ -0,0 +1,91 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNetworkLog(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	netLog := NewNetworkLog()
+	cli := newHTTPClient(0, "", false)
+	customHeaders := map[string]string{"cf-access-client-secret": "secret", "X-Client": "app"}
+	cli.Transport = netLog.wrap(cli.Transport, customHeaders)
+	setServerHTTPSettings(cli, customHeaders, nil)
+	get := func() {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/rest/ping.view?u=alice&t=secret&s=salt&apiKey=key", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := cli.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	get()
+	if n := len(netLog.Entries()); n != 0 {
+		t.Fatalf("recorded %d requests while disabled", n)
+	}
+	netLog.SetEnabled(true)
+	get()
+	entries := netLog.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if e.Method != http.MethodGet || e.Status != http.StatusTeapot || e.ResponseSize != 5 {
+		t.Errorf("got method %s, status %d, size %d", e.Method, e.Status, e.ResponseSize)
+	}
+	if strings.Contains(e.URL, "secret") || strings.Contains(e.URL, "salt") || strings.Contains(e.URL, "=key") {
+		t.Errorf("URL not redacted: %s", e.URL)
+	}
+	if !strings.Contains(e.URL, "u=alice") {
+		t.Errorf("URL over-redacted: %s", e.URL)
+	}
+	if e.RequestHeaders.Get("Authorization") != redacted || e.ResponseHeaders.Get("Set-Cookie") != redacted {
+		t.Errorf("headers not redacted: %v %v", e.RequestHeaders, e.ResponseHeaders)
+	}
+	if e.RequestHeaders.Get("CF-Access-Client-Secret") != redacted || e.RequestHeaders.Get("X-Client") != redacted {
+		t.Errorf("custom headers not redacted: %v", e.RequestHeaders)
+	}
+
+	var buf bytes.Buffer
+	if err := netLog.WriteHAR(&buf, "app", "1.0"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "secret") {
+		t.Error("HAR contains a secret")
+	}
+	var har struct {
+		Log struct {
+			Version string
+			Entries []struct {
+				Request  struct{ Method string }
+				Response struct{ Status int }
+			}
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &har); err != nil {
+		t.Fatal(err)
+	}
+	if har.Log.Version != "1.2" || len(har.Log.Entries) != 1 || har.Log.Entries[0].Response.Status != http.StatusTeapot {
+		t.Errorf("unexpected HAR: %s", buf.String())
+	}
+
+	netLog.Clear()
+	if n := len(netLog.Entries()); n != 0 {
+		t.Errorf("got %d entries after Clear", n)
+	}
+}
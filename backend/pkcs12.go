@@ -0,0 +1,31 @@
+package backend
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+var errPKCS12Password = errors.New("PKCS#12: wrong password or corrupt file")
+
+// decodePKCS12 decodes the client certificate, its chain and private key
+// from a PKCS#12 (.p12/.pfx) bundle, verifying its integrity MAC.
+func decodePKCS12(data []byte, password string) (tls.Certificate, error) {
+	key, leaf, chain, err := pkcs12.DecodeChain(data, password)
+	if errors.Is(err, pkcs12.ErrIncorrectPassword) || errors.Is(err, pkcs12.ErrDecryption) {
+		return tls.Certificate{}, errPKCS12Password
+	} else if err != nil {
+		return tls.Certificate{}, fmt.Errorf("PKCS#12: %w", err)
+	}
+	cert := tls.Certificate{
+		Certificate: [][]byte{leaf.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+	for _, c := range chain {
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+	return cert, nil
+}
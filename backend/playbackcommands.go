@@ -32,6 +32,17 @@ const (
 	cmdForceRestartPlayback
 
 	cmdLoadTrackPaused // arg: int (idx), arg2: float64 (startTime)
+
+	cmdRemoveFromUpNext // arg: []string
+	cmdClearUpNext
+
+	cmdPlayTransientItem // arg: mediaprovider.MediaItem
+
+	cmdReissueStreamURLs
+
+	// changes made to the QueuePlayer's queue by other means than our requests
+	cmdPlayerQueueIndexChange // arg: int, arg2: player.QueuePlayer
+	cmdPlayerQueueChange      // arg: []*mediaprovider.Track, arg2: int, arg3: player.QueuePlayer
 )
 
 type playbackCommand struct {
@@ -141,6 +152,25 @@ func (c *playbackCommandQueue) RemoveItemsFromQueue(idxs []int) {
 	c.cmdAvailable.Signal()
 }
 
+func (c *playbackCommandQueue) RemoveItemsFromUpNext(itemIDs []string) {
+	c.mutex.Lock()
+	c.queue = append(c.queue, playbackCommand{
+		Type: cmdRemoveFromUpNext,
+		Arg:  itemIDs,
+	})
+	c.mutex.Unlock()
+	c.cmdAvailable.Signal()
+}
+
+func (c *playbackCommandQueue) ClearUpNext() {
+	c.filterCommandsAndAdd([]playbackCommandType{cmdRemoveFromUpNext, cmdClearUpNext},
+		playbackCommand{Type: cmdClearUpNext})
+}
+
+func (c *playbackCommandQueue) PlayTransientItem(item mediaprovider.MediaItem) {
+	c.addCommand(playbackCommand{Type: cmdPlayTransientItem, Arg: item})
+}
+
 func (c *playbackCommandQueue) LoadRadioStation(radio *mediaprovider.RadioStation, insertMode InsertQueueMode) {
 	c.mutex.Lock()
 	c.queue = append(c.queue, playbackCommand{
@@ -233,7 +263,8 @@ func (c *playbackCommandQueue) seekBackOrFwd(direction int) {
 		case cmdSeekFwdBackN:
 			lastIdx = i
 		case cmdRemoveTracksFromQueue, cmdLoadItems, cmdSetQueueState, cmdPlayTrackAt,
-			cmdLoadRadioStation, cmdUpdatePlayQueue, cmdStopAndClearPlayQueue:
+			cmdLoadRadioStation, cmdUpdatePlayQueue, cmdStopAndClearPlayQueue,
+			cmdRemoveFromUpNext, cmdClearUpNext, cmdPlayerQueueIndexChange, cmdPlayerQueueChange:
 			// any queue-modifying command means we can't coalesce any
 			// more seekFwdBackN commands before here
 			done = true
@@ -4,10 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
 	"math/rand"
 	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dweymouth/supersonic/backend/mediaprovider"
@@ -66,19 +67,42 @@ type playbackEngine struct {
 	audiocache    *AudioCache
 	player        player.BasePlayer
 
-	playTimeStopwatch   util.Stopwatch
-	curTrackDuration    float64
+	playTimeStopwatch util.Stopwatch
+	curTrackDuration  float64
+	// time in the current track at which its stream starts, when
+	// restarted at an offset to seek in a transcoded stream
+	streamOffset float64
+	// true if the next track change is the current track
+	// being restarted at streamOffset
+	offsetRestart       bool
 	latestTrackPosition float64 // cleared by checkScrobble
 	callbacksDisabled   bool
 
 	playQueue         []mediaprovider.MediaItem
 	shuffledPlayQueue []mediaprovider.MediaItem
 
+	// Items added with "play next", which play in insertion order before
+	// the rest of the play queue. Kept separate from the play queue so that
+	// shuffling does not mix them in. The first item is moved into the play
+	// queue right after the now playing item just before it is to be played.
+	// Only modified on the command queue goroutine, but guarded by upNextLock
+	// since it is read from other goroutines.
+	upNextLock sync.Mutex
+	upNext     []mediaprovider.MediaItem
+	// true if the first up next item has already been moved into
+	// the play queue (at nowPlayingIdx+1) but has not yet begun playing
+	upNextPromoted bool
+
 	nowPlayingIdx int
 	isRadio       bool
 	loopMode      LoopMode
 	shuffle       bool
 
+	// ID of the item added with PlayTransientItem, if any.
+	// Written on the command queue goroutine, but read by NowPlaying
+	// from any goroutine, so kept atomically.
+	transientItemID atomic.Value // string
+
 	pauseAfterCurrent bool // flag to pause playback after current track ends
 
 	// flags for handleOnTrackChange / handleOnStopped callbacks - reset to false in the callbacks
@@ -108,6 +132,8 @@ type playbackEngine struct {
 	playbackCfg   *PlaybackConfig
 	scrobbleCfg   *ScrobbleConfig
 	transcodeCfg  *TranscodingConfig
+	bitPerfect    atomic.Bool // stream the original files, ignoring transcodeCfg
+	adaptive      *AdaptiveTranscoder
 	replayGainCfg ReplayGainConfig
 
 	// registered callbacks
@@ -124,6 +150,10 @@ type playbackEngine struct {
 	onQueueChange      []func()
 
 	onRadioMetadataChange []func(radioName, title, artist string)
+
+	// adds a command to the PlaybackManager's command queue, to handle
+	// player events that change the play queue on its goroutine
+	postCommand func(playbackCommand)
 }
 
 func NewPlaybackEngine(
@@ -145,6 +175,7 @@ func NewPlaybackEngine(
 		playbackCfg:   playbackCfg,
 		scrobbleCfg:   scrobbleCfg,
 		transcodeCfg:  transcodeCfg,
+		adaptive:      NewAdaptiveTranscoder(),
 		nowPlayingIdx: -1,
 		wasStopped:    true,
 	}
@@ -158,6 +189,14 @@ func NewPlaybackEngine(
 	pm.shuffle = playbackCfg.Shuffle
 
 	pm.registerPlayerCallbacks(p)
+	if c != nil {
+		c.OnDownloadCompleted(pm.adaptive.DownloadCompleted)
+	}
+	pm.onQueueChange = append(pm.onQueueChange, func() {
+		if err := pm.syncPlayerQueue(); err != nil {
+			playbackLog.Error("failed to update player queue", "err", err)
+		}
+	})
 	s.OnLogout(func() {
 		pm.StopAndClearPlayQueue()
 	})
@@ -189,6 +228,21 @@ func (p *playbackEngine) registerPlayerCallbacks(pl player.BasePlayer) {
 		p.invokeNoArgCallbacks(p.onPlaying)
 		p.reportPlayback("playing")
 	})
+	if vp, ok := pl.(player.VolumeEventPlayer); ok {
+		vp.OnVolumeChange(func(vol int) {
+			for _, cb := range p.onVolumeChange {
+				cb(vol)
+			}
+		})
+	}
+	if qp, ok := pl.(player.QueuePlayer); ok {
+		qp.OnQueueIndexChange(func(idx int) {
+			p.postCommand(playbackCommand{Type: cmdPlayerQueueIndexChange, Arg: idx, Arg2: qp})
+		})
+		qp.OnQueueChange(func(tracks []*mediaprovider.Track, nowPlayingIdx int) {
+			p.postCommand(playbackCommand{Type: cmdPlayerQueueChange, Arg: tracks, Arg2: nowPlayingIdx, Arg3: qp})
+		})
+	}
 }
 
 func (p *playbackEngine) unregisterPlayerCallbacks(pl player.BasePlayer) {
@@ -197,12 +251,21 @@ func (p *playbackEngine) unregisterPlayerCallbacks(pl player.BasePlayer) {
 	pl.OnStopped(nil)
 	pl.OnSeek(nil)
 	pl.OnTrackChange(nil)
+	if vp, ok := pl.(player.VolumeEventPlayer); ok {
+		vp.OnVolumeChange(nil)
+	}
+	if qp, ok := pl.(player.QueuePlayer); ok {
+		qp.OnQueueIndexChange(nil)
+		qp.OnQueueChange(nil)
+	}
 }
 
 func (p *playbackEngine) SetPlayer(pl player.BasePlayer) error {
 	needToUnpause := false
 
 	stat := p.CurrentPlayer().GetStatus()
+	stat.TimePos += p.streamOffset
+	p.streamOffset = 0
 	if p.pendingPlayerChange || p.pendingLoadPaused {
 		stat.State = player.Paused
 	}
@@ -224,7 +287,7 @@ func (p *playbackEngine) SetPlayer(pl player.BasePlayer) error {
 	}
 	p.unregisterPlayerCallbacks(p.player)
 	if err := p.player.Stop(true); err != nil {
-		log.Printf("failed to stop player: %v", err)
+		playbackLog.Error("failed to stop player", "err", err)
 	}
 
 	oldVol := p.player.GetVolume()
@@ -274,6 +337,8 @@ func (p *playbackEngine) clearPlayQueue() {
 	p.nowPlayingIdx = -1
 	p.playQueue = nil
 	p.shuffledPlayQueue = nil
+	p.setUpNext(nil)
+	p.upNextPromoted = false
 }
 
 func (p *playbackEngine) setPlayQueue(items []mediaprovider.MediaItem) {
@@ -300,18 +365,205 @@ func (p *playbackEngine) insertItemsIntoPlayQueueAt(items []mediaprovider.MediaI
 	}
 }
 
+// GetPlayQueueDeepCopy returns the unshuffled play queue,
+// with the up next items inserted where they will play.
 func (p *playbackEngine) GetPlayQueueDeepCopy() []mediaprovider.MediaItem {
-	return deepCopyMediaItemSlice(p.getPlayQueue())
+	nowPlayingIdx := p.nowPlayingIdx
+	if p.shuffle && nowPlayingIdx >= 0 && nowPlayingIdx < len(p.shuffledPlayQueue) {
+		nowPlayingIdx = p.GetTrackIdxByIdFrom(p.playQueue, p.shuffledPlayQueue[nowPlayingIdx].Metadata().ID)
+	}
+	return p.withUpNext(p.getPlayQueue(), nowPlayingIdx)
 }
 
+// GetShuffledPlayQueueDeepCopy returns the shuffled play queue,
+// with the up next items inserted where they will play.
 func (p *playbackEngine) GetShuffledPlayQueueDeepCopy() []mediaprovider.MediaItem {
-	return deepCopyMediaItemSlice(p.getShuffledPlayQueue())
+	return p.withUpNext(p.getShuffledPlayQueue(), p.nowPlayingIdx)
 }
 
 func (p *playbackEngine) GetActivePlayQueueDeepCopy() []mediaprovider.MediaItem {
 	return deepCopyMediaItemSlice(p.getActivePlayQueue())
 }
 
+// GetActivePlayQueueWithUpNextDeepCopy returns the active play queue,
+// with the up next items inserted where they will play.
+func (p *playbackEngine) GetActivePlayQueueWithUpNextDeepCopy() []mediaprovider.MediaItem {
+	return p.withUpNext(p.getActivePlayQueue(), p.nowPlayingIdx)
+}
+
+func (p *playbackEngine) GetUpNextDeepCopy() []mediaprovider.MediaItem {
+	p.upNextLock.Lock()
+	defer p.upNextLock.Unlock()
+	return deepCopyMediaItemSlice(p.upNext)
+}
+
+func (p *playbackEngine) setUpNext(items []mediaprovider.MediaItem) {
+	p.upNextLock.Lock()
+	defer p.upNextLock.Unlock()
+	p.upNext = items
+}
+
+// returns a deep copy of queue with the up next items inserted after the
+// item at nowPlayingIdx (and after the promoted up next item, if any)
+func (p *playbackEngine) withUpNext(queue []mediaprovider.MediaItem, nowPlayingIdx int) []mediaprovider.MediaItem {
+	upNext := p.GetUpNextDeepCopy()
+	if len(upNext) == 0 || nowPlayingIdx < 0 {
+		return deepCopyMediaItemSlice(queue)
+	}
+	insertIdx := nowPlayingIdx + 1
+	if p.upNextPromoted {
+		insertIdx++
+	}
+	insertIdx = min(insertIdx, len(queue))
+	result := make([]mediaprovider.MediaItem, 0, len(queue)+len(upNext))
+	result = append(result, deepCopyMediaItemSlice(queue[:insertIdx])...)
+	result = append(result, upNext...)
+	return append(result, deepCopyMediaItemSlice(queue[insertIdx:])...)
+}
+
+// Removes the first item with each of the given IDs from the up next list.
+// Items are identified by ID rather than index since the list may change
+// (e.g. by promoting its first item) after the caller has read it.
+func (p *playbackEngine) RemoveFromUpNext(itemIDs []string) {
+	newUpNext := slices.Clone(p.upNext)
+	for _, id := range itemIDs {
+		if i := p.GetTrackIdxByIdFrom(newUpNext, id); i >= 0 {
+			newUpNext = slices.Delete(newUpNext, i, i+1)
+		}
+	}
+	if len(newUpNext) == len(p.upNext) {
+		return
+	}
+	firstChanged := len(newUpNext) == 0 || newUpNext[0] != p.upNext[0]
+	p.setUpNext(newUpNext)
+	if firstChanged && !p.upNextPromoted {
+		p.handleNextTrackUpdated()
+	}
+	p.invokeNoArgCallbacks(p.onQueueChange)
+}
+
+func (p *playbackEngine) ClearUpNext() {
+	if len(p.upNext) == 0 {
+		return
+	}
+	p.setUpNext(nil)
+	if !p.upNextPromoted {
+		p.handleNextTrackUpdated()
+	}
+	p.invokeNoArgCallbacks(p.onQueueChange)
+}
+
+// true if the first up next item should play next but has not yet
+// been moved into the play queue
+func (p *playbackEngine) hasPendingUpNext() bool {
+	return !p.upNextPromoted && len(p.upNext) > 0 && p.nowPlayingIdx >= 0 && p.loopMode != LoopOne
+}
+
+// Moves the first up next item, if any, into the play queue right after
+// the now playing item. Returns true if the item at nowPlayingIdx+1
+// is an up next item that should play next.
+func (p *playbackEngine) promoteUpNext() bool {
+	if p.upNextPromoted {
+		return true
+	}
+	if !p.hasPendingUpNext() {
+		return false
+	}
+	item := p.upNext[0]
+	p.setUpNext(p.upNext[1:])
+	if p.shuffle {
+		// keep the unshuffled queue in sync, inserting after the now playing item
+		nowPlayingID := p.getPlayQueueItemAt(p.nowPlayingIdx).Metadata().ID
+		idx := p.GetTrackIdxByIdFrom(p.playQueue, nowPlayingID) + 1
+		if idx == 0 {
+			idx = len(p.playQueue)
+		}
+		p.insertItemsIntoPlayQueueAt([]mediaprovider.MediaItem{item}, idx, PlayQueue)
+		p.insertItemsIntoPlayQueueAt([]mediaprovider.MediaItem{item.Copy()}, p.nowPlayingIdx+1, ShuffledPlayQueue)
+	} else {
+		p.insertItemsIntoPlayQueueAt([]mediaprovider.MediaItem{item}, p.nowPlayingIdx+1, PlayQueue)
+	}
+	p.upNextPromoted = true
+	p.invokeNoArgCallbacks(p.onQueueChange)
+	return true
+}
+
+// Reverses promoteUpNext, moving the promoted up next item, if any,
+// out of the play queue and back to the front of the up next list.
+func (p *playbackEngine) demoteUpNext() {
+	if !p.upNextPromoted {
+		return
+	}
+	p.upNextPromoted = false
+	idx := p.nowPlayingIdx + 1
+	active := p.getActivePlayQueue()
+	if p.nowPlayingIdx < 0 || idx >= len(active) {
+		return
+	}
+	item := active[idx]
+	if p.shuffle {
+		nowPlayingID := active[p.nowPlayingIdx].Metadata().ID
+		if i := p.GetTrackIdxByIdFrom(p.playQueue, nowPlayingID) + 1; i > 0 && i < len(p.playQueue) &&
+			p.playQueue[i].Metadata().ID == item.Metadata().ID {
+			p.playQueue = slices.Delete(p.playQueue, i, i+1)
+		}
+		p.shuffledPlayQueue = slices.Delete(p.shuffledPlayQueue, idx, idx+1)
+	} else {
+		p.playQueue = slices.Delete(p.playQueue, idx, idx+1)
+	}
+	p.setUpNext(append([]mediaprovider.MediaItem{item}, p.upNext...))
+}
+
+// PlayTransientItem plays the item right away, inserting it after the now
+// playing item. Only one transient item is kept in the play queue; a previous
+// one is replaced. Transient items are not meant to be saved with the queue.
+func (p *playbackEngine) PlayTransientItem(item mediaprovider.MediaItem) error {
+	if transientID := p.TransientItemID(); transientID != "" {
+		oldIdx := p.GetTrackIdxByIdFrom(p.getActivePlayQueue(), transientID)
+		if oldIdx >= 0 && oldIdx == p.nowPlayingIdx {
+			// replace the now playing transient item in place
+			p.getActivePlayQueue()[oldIdx] = item
+			if p.shuffle {
+				if idx := p.GetTrackIdxByIdFrom(p.playQueue, transientID); idx >= 0 {
+					p.playQueue[idx] = item.Copy()
+				}
+			}
+			p.transientItemID.Store(item.Metadata().ID)
+			p.invokeNoArgCallbacks(p.onQueueChange)
+			return p.playTrackAt(oldIdx, 0)
+		} else if oldIdx >= 0 {
+			p.RemoveTracksFromQueue([]int{oldIdx})
+		}
+	}
+
+	idx := p.nowPlayingIdx + 1
+	if p.nowPlayingIdx < 0 {
+		idx = p.getPlayQueueLength()
+	}
+	if p.shuffle {
+		unshuffledIdx := len(p.playQueue)
+		if p.nowPlayingIdx >= 0 {
+			nowPlayingID := p.getPlayQueueItemAt(p.nowPlayingIdx).Metadata().ID
+			if i := p.GetTrackIdxByIdFrom(p.playQueue, nowPlayingID); i >= 0 {
+				unshuffledIdx = i + 1
+			}
+		}
+		p.insertItemsIntoPlayQueueAt([]mediaprovider.MediaItem{item}, unshuffledIdx, PlayQueue)
+		p.insertItemsIntoPlayQueueAt([]mediaprovider.MediaItem{item.Copy()}, idx, ShuffledPlayQueue)
+	} else {
+		p.insertItemsIntoPlayQueueAt([]mediaprovider.MediaItem{item}, idx, PlayQueue)
+	}
+	p.transientItemID.Store(item.Metadata().ID)
+	p.invokeNoArgCallbacks(p.onQueueChange)
+	return p.playTrackAt(idx, 0)
+}
+
+// TransientItemID returns the ID of the item last played with PlayTransientItem.
+func (p *playbackEngine) TransientItemID() string {
+	id, _ := p.transientItemID.Load().(string)
+	return id
+}
+
 // ======================== END PLAY QUEUE FUNCS =============================
 
 func (p *playbackEngine) PlayTrackAt(idx int) error {
@@ -411,6 +663,9 @@ func (p *playbackEngine) SetShuffle(shuffle bool) {
 	for _, cb := range p.onShuffleChange {
 		cb(shuffle)
 	}
+	// the promoted up next item is only guaranteed to follow
+	// the now playing item in the current queue
+	p.demoteUpNext()
 	p.shuffle = shuffle
 
 	// guard against changing shuffle with an empty queue
@@ -420,13 +675,9 @@ func (p *playbackEngine) SetShuffle(shuffle bool) {
 
 	newNowPlayingIdx := 0
 	if shuffle {
-		shuffledQueue := deepCopyMediaItemSlice(p.playQueue)
-		rand.Shuffle(len(shuffledQueue), func(i, j int) {
-			shuffledQueue[i], shuffledQueue[j] = shuffledQueue[j], shuffledQueue[i]
-		})
 		if p.nowPlayingIdx >= 0 && len(p.getPlayQueue()) > p.nowPlayingIdx {
 			nowPlayingID := p.getPlayQueue()[p.nowPlayingIdx].Metadata().ID
-			p.setShuffledPlayQueue(sharedutil.ReorderItems(shuffledQueue, []int{p.GetTrackIdxByIdFrom(shuffledQueue, nowPlayingID)}, 0))
+			p.setShuffledPlayQueue(p.newShuffledQueue(nowPlayingID))
 		} else {
 			return
 		}
@@ -444,6 +695,31 @@ func (p *playbackEngine) SetShuffle(shuffle bool) {
 	p.invokeNoArgCallbacks(p.onQueueChange)
 }
 
+// Re-shuffles the play queue after a change to the shuffle mode.
+// The now playing track is kept as the first item of the shuffled queue.
+func (p *playbackEngine) ReshuffleQueue() {
+	if !p.shuffle || p.nowPlayingIdx < 0 || p.nowPlayingIdx >= len(p.getShuffledPlayQueue()) {
+		return
+	}
+	p.demoteUpNext()
+	nowPlayingID := p.getShuffledPlayQueue()[p.nowPlayingIdx].Metadata().ID
+	p.setShuffledPlayQueue(p.newShuffledQueue(nowPlayingID))
+	p.nowPlayingIdx = 0
+	p.handleNextTrackUpdated()
+	p.invokeNoArgCallbacks(p.onQueueChange)
+}
+
+// returns a shuffled copy of the play queue with the given item moved to the front
+func (p *playbackEngine) newShuffledQueue(firstID string) []mediaprovider.MediaItem {
+	shuffledQueue := deepCopyMediaItemSlice(p.playQueue)
+	p.shuffleItems(shuffledQueue)
+	return sharedutil.ReorderItems(shuffledQueue, []int{p.GetTrackIdxByIdFrom(shuffledQueue, firstID)}, 0)
+}
+
+func (p *playbackEngine) shuffleItems(items []mediaprovider.MediaItem) {
+	shuffleItems(items, p.playbackCfg.ShuffleMode, p.playbackCfg.ShuffleArtistSpread, rand.Float64)
+}
+
 func (p *playbackEngine) PlaybackStatus() PlaybackStatus {
 	if p.pendingLoadPaused {
 		return PlaybackStatus{
@@ -455,6 +731,7 @@ func (p *playbackEngine) PlaybackStatus() PlaybackStatus {
 	stat := p.pendingPlayerChangeStatus
 	if !p.pendingPlayerChange {
 		stat = p.CurrentPlayer().GetStatus()
+		stat.TimePos += p.streamOffset
 	}
 	return PlaybackStatus{
 		State:    stat.State,
@@ -482,6 +759,7 @@ func (p *playbackEngine) SeekNext() error {
 	if p.PlaybackStatus().State == player.Stopped {
 		return nil
 	}
+	p.promoteUpNext()
 	return p.PlayTrackAt(p.nowPlayingIdx + 1)
 }
 
@@ -512,10 +790,62 @@ func (p *playbackEngine) SeekFwdBackN(n int) error {
 
 // Seek to given absolute position in the current track by seconds.
 func (p *playbackEngine) SeekSeconds(sec float64) error {
-	if p.isRadio {
-		return nil // can't seek radio stations
+	if p.isRadio && p.curTrackDuration == 0 {
+		return nil // can't seek live radio streams
+	}
+	if url := p.streamURLAtOffset(int(sec)); url != "" {
+		return p.restartAtOffset(url, float64(int(sec)))
+	}
+	return p.player.SeekSeconds(max(sec-p.streamOffset, 0))
+}
+
+// streamURLAtOffset returns the URL to restart the current track at the
+// given time, if it is streamed transcoded from a server that can start
+// transcoded streams at an offset, or "" to seek in the current stream.
+func (p *playbackEngine) streamURLAtOffset(sec int) string {
+	if p.pendingLoadPaused || p.pendingPlayerChange || p.nowPlayingIdx < 0 {
+		return ""
+	}
+	if _, ok := p.player.(player.URLPlayer); !ok {
+		return ""
+	}
+	tr, ok := p.getPlayQueueItemAt(p.nowPlayingIdx).(*mediaprovider.Track)
+	if !ok {
+		return ""
 	}
-	return p.player.SeekSeconds(sec)
+	if t, ok := p.sm.Server.(mediaprovider.CanTranscodeFromOffset); !ok || !t.SupportsTranscodeOffset() {
+		return ""
+	}
+	if p.audiocache != nil && p.audiocache.PathForCachedFile(tr.ID) != "" {
+		return "" // playing the seekable cached file
+	}
+	ts, _ := p.transcodeSettings()
+	if ts == nil {
+		return ""
+	}
+	ts.OffsetSeconds = sec
+	url, err := p.sm.Server.GetStreamURL(tr.ID, ts, false)
+	if err != nil {
+		playbackLog.Warn("failed to get stream URL at offset", "err", err)
+		return ""
+	}
+	return p.sm.StreamURL(tr.ID, url)
+}
+
+// restartAtOffset plays the stream of the current track starting at offset.
+func (p *playbackEngine) restartAtOffset(url string, offset float64) error {
+	wasPaused := p.player.GetStatus().State == player.Paused
+	meta := p.getPlayQueueItemAt(p.nowPlayingIdx).Metadata()
+	p.offsetRestart = true
+	p.streamOffset = offset
+	if err := p.player.(player.URLPlayer).PlayFile(url, meta, 0); err != nil {
+		p.offsetRestart = false
+		return err
+	}
+	if wasPaused {
+		return p.player.Pause()
+	}
+	return nil
 }
 
 func (p *playbackEngine) IsSeeking() bool {
@@ -591,9 +921,7 @@ func (p *playbackEngine) LoadItemsAndPlayAtIdx(items []mediaprovider.MediaItem,
 
 	if p.shuffle || shuffle {
 		p.clearPlayQueue()
-		rand.Shuffle(len(newItems), func(i, j int) {
-			newItems[i], newItems[j] = newItems[j], newItems[i]
-		})
+		p.shuffleItems(newItems)
 		if idx < len(items) {
 			nowPlayingID := items[idx].Metadata().ID
 			p.setPlayQueue(deepCopyMediaItemSlice(items))
@@ -629,7 +957,13 @@ func (p *playbackEngine) doLoaditems(items []mediaprovider.MediaItem, insertQueu
 	}
 
 	if shuffle {
-		rand.Shuffle(len(items), func(i, j int) { items[i], items[j] = items[j], items[i] })
+		p.shuffleItems(items)
+	}
+
+	if insertQueueMode == InsertNext && p.nowPlayingIdx >= 0 {
+		p.setUpNext(append(p.upNext, items...))
+		p.invokeNoArgCallbacks(p.onQueueChange)
+		return nil
 	}
 
 	insertIdx := p.getPlayQueueLength()
@@ -637,7 +971,7 @@ func (p *playbackEngine) doLoaditems(items []mediaprovider.MediaItem, insertQueu
 	if insertQueueMode == Replace {
 		if p.shuffle {
 			shuffledItems := deepCopyMediaItemSlice(items)
-			rand.Shuffle(len(shuffledItems), func(i, j int) { shuffledItems[i], shuffledItems[j] = shuffledItems[j], shuffledItems[i] })
+			p.shuffleItems(shuffledItems)
 
 			p.insertItemsIntoPlayQueueAt(items, insertIdx, PlayQueue)
 			p.insertItemsIntoPlayQueueAt(shuffledItems, insertIdx, ShuffledPlayQueue)
@@ -663,7 +997,12 @@ func (p *playbackEngine) LoadRadioStation(radio *mediaprovider.RadioStation, ins
 		p.clearPlayQueue()
 	}
 	if nextChanged := insertMode == InsertNext || (insertMode == Append && p.nowPlayingIdx == p.getPlayQueueLength()-1); nextChanged {
-		p.handleNextTrackUpdated()
+		defer p.handleNextTrackUpdated()
+	}
+	if insertMode == InsertNext && p.nowPlayingIdx >= 0 {
+		p.setUpNext(append(p.upNext, radio))
+		p.invokeNoArgCallbacks(p.onQueueChange)
+		return
 	}
 	insertIdx := p.getPlayQueueLength()
 	if insertMode == InsertNext {
@@ -675,7 +1014,7 @@ func (p *playbackEngine) LoadRadioStation(radio *mediaprovider.RadioStation, ins
 
 // Stop playback and clear the play queue.
 func (p *playbackEngine) StopAndClearPlayQueue() {
-	changed := p.getPlayQueueLength() > 0
+	changed := p.getPlayQueueLength() > 0 || len(p.upNext) > 0
 	p.clearPlayQueue()
 	if changed {
 		p.invokeNoArgCallbacks(p.onQueueChange)
@@ -824,7 +1163,7 @@ func (p *playbackEngine) GetTrackIdsFromIdx(idx []int) []string {
 func (p *playbackEngine) SetReplayGainOptions(config ReplayGainConfig) {
 	rGainPlayer, ok := p.player.(player.ReplayGainPlayer)
 	if !ok {
-		log.Println("Error: player doesn't support ReplayGain")
+		playbackLog.Error("player doesn't support ReplayGain")
 		return
 	}
 
@@ -849,7 +1188,7 @@ func (p *playbackEngine) SetReplayGainOptions(config ReplayGainConfig) {
 func (p *playbackEngine) SetReplayGainMode(mode player.ReplayGainMode) {
 	rGainPlayer, ok := p.player.(player.ReplayGainPlayer)
 	if !ok {
-		log.Println("Error: player doesn't support ReplayGain")
+		playbackLog.Error("player doesn't support ReplayGain")
 		return
 	}
 	rGainPlayer.SetReplayGainOptions(player.ReplayGainOptions{
@@ -886,6 +1225,16 @@ func (p *playbackEngine) cacheNextTracks() {
 }
 
 func (p *playbackEngine) handleOnTrackChange() {
+	if p.offsetRestart {
+		// the current track was restarted to seek, not changed;
+		// the next track must be handed to the player again
+		p.offsetRestart = false
+		p.handleTimePosUpdate(true)
+		p.handleNextTrackUpdated()
+		return
+	}
+	p.streamOffset = 0
+
 	// scrobble the previous song if needed
 	if !p.alreadyScrobbled {
 		p.checkScrobble()
@@ -910,6 +1259,7 @@ func (p *playbackEngine) handleOnTrackChange() {
 	// reset flags
 	p.wasStopped = false
 	p.alreadyScrobbled = false
+	p.upNextPromoted = false
 
 	p.curTrackDuration = nowPlaying.Metadata().Duration.Seconds()
 	p.sendNowPlayingScrobble() // Must come before invokeOnChangeCallbacks b/c track may immediately be scrobbled
@@ -925,6 +1275,7 @@ func (p *playbackEngine) handleOnTrackChange() {
 }
 
 func (p *playbackEngine) handleOnStopped() {
+	p.streamOffset = 0
 	p.playTimeStopwatch.Stop()
 	if !p.alreadyScrobbled {
 		p.checkScrobble()
@@ -946,7 +1297,9 @@ func (p *playbackEngine) handleNextTrackUpdated() {
 	p.needToSetNextTrack = true
 	for _, cb := range p.onBeforeSongChange {
 		var item mediaprovider.MediaItem
-		if idx := p.nextPlayingIndex(); idx >= 0 {
+		if p.hasPendingUpNext() {
+			item = p.upNext[0]
+		} else if idx := p.nextPlayingIndex(); idx >= 0 {
 			item = p.getPlayQueueItemAt(idx)
 		}
 		cb(item)
@@ -1027,28 +1380,148 @@ func (p *playbackEngine) setTrack(idx int, next bool, startTime float64) error {
 			return trP.SetNextTrack(track)
 		}
 		return trP.PlayTrack(track, startTime)
+	} else if qP, ok := p.player.(player.QueuePlayer); ok {
+		if err := p.syncPlayerQueue(); err != nil {
+			return err
+		}
+		if next {
+			return qP.SetNextTrackAt(idx)
+		}
+		return qP.PlayTrackAt(idx, startTime)
 	}
 	panic("Unsupported player type")
 }
 
+// syncPlayerQueue mirrors the active play queue to the player,
+// if it is a QueuePlayer.
+func (p *playbackEngine) syncPlayerQueue() error {
+	qP, ok := p.player.(player.QueuePlayer)
+	if !ok {
+		return nil
+	}
+	queue := p.getActivePlayQueue()
+	tracks := make([]*mediaprovider.Track, 0, len(queue))
+	for _, item := range queue {
+		tr, ok := item.(*mediaprovider.Track)
+		if !ok {
+			return errors.New("cannot play non-Track media item with QueuePlayer")
+		}
+		tracks = append(tracks, tr)
+	}
+	return qP.SetQueue(tracks, p.nowPlayingIdx)
+}
+
+// handlePlayerQueueIndexChange is called on the command queue goroutine
+// when the QueuePlayer starts playing another track of its queue by some
+// other means than our request (e.g. another client controlling a server
+// jukebox). Changes from a player that is no longer current are ignored.
+func (p *playbackEngine) handlePlayerQueueIndexChange(pl player.QueuePlayer, idx int) {
+	if p.player != player.BasePlayer(pl) || idx < 0 || idx >= p.getPlayQueueLength() {
+		return
+	}
+	p.pendingTrackChangeNum = idx
+	p.handleOnTrackChange()
+}
+
+// handlePlayerQueueChange is called on the command queue goroutine when the
+// QueuePlayer's queue was replaced by some other means than our request.
+// The player's queue is adopted as the play queue.
+func (p *playbackEngine) handlePlayerQueueChange(pl player.QueuePlayer, tracks []*mediaprovider.Track, nowPlayingIdx int) {
+	if p.player != player.BasePlayer(pl) {
+		return
+	}
+	var oldID string
+	if p.nowPlayingIdx >= 0 && p.nowPlayingIdx < p.getPlayQueueLength() {
+		oldID = p.getPlayQueueItemAt(p.nowPlayingIdx).Metadata().ID
+	}
+	var newID string
+	if nowPlayingIdx >= 0 && nowPlayingIdx < len(tracks) {
+		newID = tracks[nowPlayingIdx].ID
+	} else {
+		nowPlayingIdx = -1
+	}
+	trackChanged := oldID != newID
+	if trackChanged && !p.alreadyScrobbled {
+		p.checkScrobble()
+		p.alreadyScrobbled = true
+	}
+
+	items := sharedutil.MapSlice(tracks, func(tr *mediaprovider.Track) mediaprovider.MediaItem { return tr })
+	p.setPlayQueue(items)
+	if p.shuffle {
+		p.setShuffledPlayQueue(slices.Clone(items))
+	}
+	p.setUpNext(nil)
+	p.upNextPromoted = false
+
+	if trackChanged && nowPlayingIdx >= 0 {
+		p.pendingTrackChangeNum = nowPlayingIdx
+		p.handleOnTrackChange()
+	} else {
+		p.nowPlayingIdx = nowPlayingIdx
+		if trackChanged {
+			p.alreadyScrobbled = false
+		}
+		p.handleNextTrackUpdated()
+	}
+	p.invokeNoArgCallbacks(p.onQueueChange)
+}
+
 func (p *playbackEngine) getMediaURLForIdx(idx int) string {
 	var url string
 	item := p.getPlayQueueItemAt(idx)
 	if tr, ok := item.(*mediaprovider.Track); ok {
-		var ts *mediaprovider.TranscodeSettings
-		if p.transcodeCfg.RequestTranscode {
-			ts = &mediaprovider.TranscodeSettings{
-				Codec:       p.transcodeCfg.Codec,
-				BitRateKBPS: p.transcodeCfg.MaxBitRateKBPS,
-			}
+		ts, forceRaw := p.transcodeSettings()
+		adaptive := p.isAdaptiveTranscoding()
+		url, _ = p.sm.Server.GetStreamURL(tr.ID, ts, forceRaw)
+		url = p.sm.StreamURL(tr.ID, url)
+		if adaptive {
+			p.adaptive.StreamIssued(url, p.sm.Hostname(), tr.Duration)
 		}
-		url, _ = p.sm.Server.GetStreamURL(tr.ID, ts, p.transcodeCfg.ForceRawFile)
 	} else {
 		url = item.(*mediaprovider.RadioStation).StreamURL
 	}
 	return url
 }
 
+// transcodeSettings returns the settings to request track streams with,
+// or nil if not transcoding, and whether to request the raw file.
+func (p *playbackEngine) transcodeSettings() (*mediaprovider.TranscodeSettings, bool) {
+	bitPerfect := p.bitPerfect.Load()
+	if p.isAdaptiveTranscoding() {
+		ts := p.adaptive.StreamSettings(p.sm.Hostname(), p.transcodeCfg.Codec, p.transcodeCfg.MaxBitRateKBPS)
+		return ts, ts == nil
+	}
+	if p.transcodeCfg.RequestTranscode && !bitPerfect {
+		return &mediaprovider.TranscodeSettings{
+			Codec:       p.transcodeCfg.Codec,
+			BitRateKBPS: p.transcodeCfg.MaxBitRateKBPS,
+		}, p.transcodeCfg.ForceRawFile
+	}
+	return nil, p.transcodeCfg.ForceRawFile || bitPerfect
+}
+
+func (p *playbackEngine) isAdaptiveTranscoding() bool {
+	return p.transcodeCfg.RequestTranscode && p.transcodeCfg.Adaptive && !p.bitPerfect.Load() && p.audiocache != nil
+}
+
+// ReissueStreamURLs hands the player and audio cache the stream URLs
+// of the upcoming tracks again, after failing over to the server's
+// other hostname, since the ones issued before are on the previous host.
+func (p *playbackEngine) ReissueStreamURLs() error {
+	if p.nowPlayingIdx < 0 {
+		return nil
+	}
+	p.cacheNextTracks()
+	if p.needToSetNextTrack {
+		return nil // next track not handed to the player yet
+	}
+	if _, ok := p.player.(player.URLPlayer); !ok {
+		return nil
+	}
+	return p.setNextTrack(p.nextPlayingIndex())
+}
+
 func (p *playbackEngine) setNextTrack(idx int) error {
 	return p.setTrack(idx, true, 0)
 }
@@ -1185,6 +1658,7 @@ func (p *playbackEngine) handleTimePosUpdate(seeked bool) {
 	isNearEnd := meta.Type != mediaprovider.MediaItemTypeRadioStation && s.TimePos > meta.Duration.Seconds()-10
 	if p.needToSetNextTrack && isNearEnd {
 		p.needToSetNextTrack = false
+		p.promoteUpNext()
 		if nextIdx := p.nextPlayingIndex(); nextIdx >= 0 && nextIdx < len(p.playQueue) {
 			p.setNextTrack(nextIdx)
 		} else {
@@ -1200,8 +1674,9 @@ func (p *playbackEngine) handleTimePosUpdate(seeked bool) {
 	}
 	duration := s.Duration
 	if p.isRadio {
-		// MPV reports buffered duration - we don't want to show this
-		duration = 0
+		// MPV reports buffered duration - we don't want to show this.
+		// Use the known length of the stream instead, if any.
+		duration = p.curTrackDuration
 	}
 	for _, cb := range p.onPlayTimeUpdate {
 		cb(s.TimePos, duration, seeked)
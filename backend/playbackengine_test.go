@@ -0,0 +1,157 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+	"github.com/dweymouth/supersonic/backend/player"
+)
+
+type nopPlayer struct{}
+
+func (nopPlayer) Continue() error           { return nil }
+func (nopPlayer) Pause() error              { return nil }
+func (nopPlayer) Stop(bool) error           { return nil }
+func (nopPlayer) SeekSeconds(float64) error { return nil }
+func (nopPlayer) IsSeeking() bool           { return false }
+func (nopPlayer) SetVolume(int) error       { return nil }
+func (nopPlayer) GetVolume() int            { return 100 }
+func (nopPlayer) GetStatus() player.Status  { return player.Status{State: player.Playing} }
+func (nopPlayer) Destroy()                  {}
+func (nopPlayer) OnPaused(func())           {}
+func (nopPlayer) OnStopped(func())          {}
+func (nopPlayer) OnPlaying(func())          {}
+func (nopPlayer) OnSeek(func())             {}
+func (nopPlayer) OnTrackChange(func())      {}
+
+// returns an engine playing the first of the tracks with the given IDs
+func newTestEngine(t *testing.T, ids string) *playbackEngine {
+	t.Helper()
+	p := &playbackEngine{
+		player:        nopPlayer{},
+		playbackCfg:   &PlaybackConfig{ShuffleMode: ShuffleModeRandom},
+		scrobbleCfg:   &ScrobbleConfig{},
+		nowPlayingIdx: -1,
+	}
+	if err := p.doLoaditems(testItems(ids), Replace, false); err != nil {
+		t.Fatal(err)
+	}
+	p.nowPlayingIdx = 0
+	return p
+}
+
+// returns tracks with single character IDs
+func testItems(ids string) []mediaprovider.MediaItem {
+	items := make([]mediaprovider.MediaItem, len(ids))
+	for i, id := range ids {
+		items[i] = &mediaprovider.Track{ID: string(id)}
+	}
+	return items
+}
+
+func itemIDs(items []mediaprovider.MediaItem) string {
+	var sb strings.Builder
+	for _, it := range items {
+		sb.WriteString(it.Metadata().ID)
+	}
+	return sb.String()
+}
+
+func TestUpNextEnqueueAndPromote(t *testing.T) {
+	p := newTestEngine(t, "abc")
+	p.doLoaditems(testItems("xy"), InsertNext, false)
+
+	if got := itemIDs(p.getActivePlayQueue()); got != "abc" {
+		t.Errorf("expected up next items kept out of the queue, got %q", got)
+	}
+	if got := itemIDs(p.GetUpNextDeepCopy()); got != "xy" {
+		t.Errorf("up next = %q, want %q", got, "xy")
+	}
+	if got := itemIDs(p.GetPlayQueueDeepCopy()); got != "axybc" {
+		t.Errorf("expected up next in the play queue copy, got %q", got)
+	}
+
+	if !p.promoteUpNext() {
+		t.Fatal("expected up next item to be promoted")
+	}
+	if got := itemIDs(p.getActivePlayQueue()); got != "axbc" {
+		t.Errorf("queue after promote = %q, want %q", got, "axbc")
+	}
+	if got := itemIDs(p.GetUpNextDeepCopy()); got != "y" {
+		t.Errorf("up next after promote = %q, want %q", got, "y")
+	}
+	// promoting again before the item plays is a no-op
+	p.promoteUpNext()
+	if got := itemIDs(p.GetActivePlayQueueWithUpNextDeepCopy()); got != "axybc" {
+		t.Errorf("queue with up next = %q, want %q", got, "axybc")
+	}
+}
+
+func TestUpNextRemoveAndClear(t *testing.T) {
+	p := newTestEngine(t, "abc")
+	p.doLoaditems(testItems("xyzx"), InsertNext, false)
+
+	// only the first copy of a repeated item is removed
+	p.RemoveFromUpNext([]string{"x", "z"})
+	if got := itemIDs(p.GetUpNextDeepCopy()); got != "yx" {
+		t.Errorf("up next after remove = %q, want %q", got, "yx")
+	}
+	p.RemoveFromUpNext([]string{"nonexistent"})
+	if got := itemIDs(p.GetUpNextDeepCopy()); got != "yx" {
+		t.Errorf("up next after removing unknown ID = %q, want %q", got, "yx")
+	}
+
+	p.ClearUpNext()
+	if n := len(p.GetUpNextDeepCopy()); n != 0 {
+		t.Errorf("expected empty up next after clear, got %d items", n)
+	}
+
+	p.doLoaditems(testItems("xy"), InsertNext, false)
+	p.promoteUpNext()
+	p.StopAndClearPlayQueue()
+	if n := len(p.GetUpNextDeepCopy()); n != 0 || p.upNextPromoted {
+		t.Errorf("expected up next reset by clearing the queue, got %d items", n)
+	}
+
+	// replacing the queue also drops up next items
+	p = newTestEngine(t, "abc")
+	p.doLoaditems(testItems("xy"), InsertNext, false)
+	p.doLoaditems(testItems("def"), Replace, false)
+	if n := len(p.GetUpNextDeepCopy()); n != 0 {
+		t.Errorf("expected up next cleared on queue replace, got %d items", n)
+	}
+}
+
+func TestUpNextShuffleToggle(t *testing.T) {
+	p := newTestEngine(t, "abcdef")
+	p.doLoaditems(testItems("xy"), InsertNext, false)
+	p.promoteUpNext()
+
+	p.SetShuffle(true)
+	if p.upNextPromoted {
+		t.Error("expected promoted item to be returned to up next on shuffle")
+	}
+	if got := itemIDs(p.GetUpNextDeepCopy()); got != "xy" {
+		t.Errorf("up next after shuffle = %q, want %q", got, "xy")
+	}
+	if got := itemIDs(p.playQueue); got != "abcdef" {
+		t.Errorf("unshuffled queue after shuffle = %q, want %q", got, "abcdef")
+	}
+	if n := len(p.shuffledPlayQueue); n != 6 || p.shuffledPlayQueue[p.nowPlayingIdx].Metadata().ID != "a" {
+		t.Errorf("unexpected shuffled queue %q", itemIDs(p.shuffledPlayQueue))
+	}
+
+	// promote into the shuffled queue, then turn shuffle off
+	p.promoteUpNext()
+	if got := p.shuffledPlayQueue[p.nowPlayingIdx+1].Metadata().ID; got != "x" {
+		t.Errorf("expected promoted item after now playing, got %q", got)
+	}
+	p.SetShuffle(false)
+	if got := itemIDs(p.playQueue); got != "abcdef" {
+		t.Errorf("queue after unshuffle = %q, want %q", got, "abcdef")
+	}
+	if got := itemIDs(p.GetPlayQueueDeepCopy()); got != "axybcdef" {
+		t.Errorf("queue with up next after unshuffle = %q, want %q", got, "axybcdef")
+	}
+}
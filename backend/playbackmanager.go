@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
 	"math/rand"
 	"runtime"
 	"slices"
@@ -14,8 +13,11 @@ import (
 	"github.com/charlievieth/strcase"
 	"github.com/dweymouth/supersonic/backend/mediaprovider"
 	"github.com/dweymouth/supersonic/backend/player"
+	"github.com/dweymouth/supersonic/backend/player/cast"
 	"github.com/dweymouth/supersonic/backend/player/dlna"
+	"github.com/dweymouth/supersonic/backend/player/jukebox"
 	"github.com/dweymouth/supersonic/backend/player/mpv"
+	"github.com/dweymouth/supersonic/backend/player/snapcast"
 	"github.com/dweymouth/supersonic/sharedutil"
 	"github.com/supersonic-app/go-upnpcast/device"
 	"github.com/supersonic-app/go-upnpcast/services"
@@ -37,6 +39,10 @@ type PlaybackManager struct {
 	// (typically wired to ImageManager.GetCoverArtPath).
 	CoverArtPathFn func(coverArtID string) (string, error)
 
+	// InitMPVFn initializes the additional mpv instances used by remote
+	// players that decode audio locally (Snapcast). Set externally.
+	InitMPVFn func(*mpv.Player) error
+
 	localPlayer         player.BasePlayer
 	remotePlayersLock   sync.Mutex
 	remotePlayers       []RemotePlaybackDevice
@@ -44,6 +50,7 @@ type PlaybackManager struct {
 
 	onWaveformImgUpdate []func(*WaveformImage)
 	onPlayerChange      []func()
+	onShuffleModeChange []func(string, bool)
 
 	lastPlayTime         float64
 	lastPlayingID        string
@@ -54,6 +61,11 @@ type PlaybackManager struct {
 	pendingAutoplay    bool
 	wasLoadTrackPaused bool
 
+	// custom radio that is continued when nearing the end of the queue
+	// (regardless of autoplay setting), until the queue is replaced
+	radioLock sync.Mutex
+	radio     *RadioEngine
+
 	// current radio metadata
 	radioStationName string
 	radioIcyTitle    string
@@ -79,6 +91,7 @@ func NewPlaybackManager(
 ) *PlaybackManager {
 	e := NewPlaybackEngine(ctx, s, c, p, playbackCfg, scrobbleCfg, transcodeCfg)
 	q := NewCommandQueue()
+	e.postCommand = q.addCommand
 	pm := &PlaybackManager{
 		engine:      e,
 		cmdQueue:    q,
@@ -91,6 +104,16 @@ func NewPlaybackManager(
 		pm.wfmGen = NewWaveformImageGenerator(c)
 	}
 	pm.addOnTrackChangeHook()
+	s.OnLogout(func() {
+		// the jukebox belongs to the server being logged out of
+		if rp := pm.currentRemotePlayer; rp != nil && rp.Protocol == "Jukebox" {
+			pm.SetRemotePlayer(nil)
+		}
+	})
+	s.OnHostChanged(func(string) {
+		// the pending stream URLs are on the server's previous hostname
+		pm.cmdQueue.addCommand(playbackCommand{Type: cmdReissueStreamURLs})
+	})
 	go pm.runCmdQueue(ctx)
 	return pm
 }
@@ -120,7 +143,7 @@ func (p *PlaybackManager) addOnTrackChangeHook() {
 		p.lastPlayTime = curTime
 
 		// enqueue autoplay tracks if enabled and nearing end of queue
-		if p.cfg.Autoplay && !p.pendingAutoplay && totalTime-curTime < 10.0 &&
+		if (p.cfg.Autoplay || p.currentRadio() != nil) && !p.pendingAutoplay && totalTime-curTime < 10.0 &&
 			p.NowPlayingIndex() == p.engine.getPlayQueueLength()-1 {
 			p.enqueueAutoplayTracks()
 		}
@@ -162,7 +185,7 @@ func (p *PlaybackManager) addOnTrackChangeHook() {
 			go func() {
 				time.Sleep(300 * time.Millisecond)
 				if p.lastPlayTime == 0 {
-					log.Println("Play stall detected!")
+					playbackLog.Warn("play stall detected")
 					p.cmdQueue.addCommand(playbackCommand{Type: cmdForceRestartPlayback})
 				}
 			}()
@@ -251,7 +274,30 @@ func (p *PlaybackManager) ScanRemotePlayers(ctx context.Context, fastScan bool)
 }
 
 func (p *PlaybackManager) scanRemotePlayers(ctx context.Context, waitSec int) {
+	var castDevices []cast.Device
+	castScanDone := make(chan struct{})
+	go func() {
+		defer close(castScanDone)
+		var err error
+		castDevices, err = cast.Discover(ctx, time.Duration(waitSec)*time.Second)
+		if err != nil {
+			playbackLog.Error("failed to scan for Cast devices", "err", err)
+		}
+	}()
+	var snapServers []snapcast.Server
+	snapScanDone := make(chan struct{})
+	go func() {
+		defer close(snapScanDone)
+		var err error
+		snapServers, err = snapcast.Discover(ctx, time.Duration(waitSec)*time.Second,
+			p.cfg.Snapcast.Servers, p.cfg.Snapcast.StreamID)
+		if err != nil {
+			playbackLog.Error("failed to scan for Snapcast servers", "err", err)
+		}
+	}()
 	devices, _ := device.SearchMediaRenderers(ctx, waitSec, services.AVTransport, services.RenderingControl)
+	<-castScanDone
+	<-snapScanDone
 
 	coverArtPathFn := p.CoverArtPathFn
 	var discovered []RemotePlaybackDevice
@@ -266,6 +312,40 @@ func (p *PlaybackManager) scanRemotePlayers(ctx context.Context, waitSec int) {
 		}
 		discovered = append(discovered, rp)
 	}
+	for _, d := range castDevices {
+		discovered = append(discovered, RemotePlaybackDevice{
+			Name:     d.Name,
+			URL:      "cast://" + d.Addr,
+			Protocol: "Cast",
+			new: func() (player.BasePlayer, error) {
+				return cast.NewCastPlayer(d, coverArtPathFn)
+			},
+		})
+	}
+	initMPVFn := p.InitMPVFn
+	for _, s := range snapServers {
+		discovered = append(discovered, RemotePlaybackDevice{
+			Name:     fmt.Sprintf("Snapcast (%s)", s.Name),
+			URL:      "snapcast://" + s.Addr + "/" + s.Stream.ID,
+			Protocol: "Snapcast",
+			new: func() (player.BasePlayer, error) {
+				return snapcast.NewSnapcastPlayer(s, initMPVFn)
+			},
+		})
+	}
+
+	if jp, ok := p.engine.sm.Server.(mediaprovider.JukeboxProvider); ok {
+		if conf := p.engine.sm.CurrentServerConfig(); conf != nil {
+			discovered = append(discovered, RemotePlaybackDevice{
+				Name:     fmt.Sprintf("Jukebox (%s)", conf.Nickname),
+				URL:      "jukebox://" + conf.ID.String(),
+				Protocol: "Jukebox",
+				new: func() (player.BasePlayer, error) {
+					return jukebox.NewJukeboxPlayer(jp)
+				},
+			})
+		}
+	}
 
 	p.remotePlayersLock.Lock()
 	p.remotePlayers = discovered
@@ -321,6 +401,11 @@ func (p *PlaybackManager) OnPlayerChange(cb func()) {
 	p.onPlayerChange = append(p.onPlayerChange, cb)
 }
 
+// Registers a callback that is notified whenever the shuffle mode changes.
+func (p *PlaybackManager) OnShuffleModeChange(cb func(mode string, artistSpread bool)) {
+	p.onShuffleModeChange = append(p.onShuffleModeChange, cb)
+}
+
 func (p *PlaybackManager) OnWaveformImgUpdate(cb func(*WaveformImage)) {
 	p.onWaveformImgUpdate = append(p.onWaveformImgUpdate, cb)
 }
@@ -338,7 +423,7 @@ func (p *PlaybackManager) DisableCallbacks() {
 // Gets the now playing media item, if any.
 func (p *PlaybackManager) NowPlaying() mediaprovider.MediaItem {
 	item := p.engine.NowPlaying()
-	if station, ok := item.(*mediaprovider.RadioStation); ok {
+	if station, ok := item.(*mediaprovider.RadioStation); ok && station.ID != p.engine.TransientItemID() {
 		station = station.Copy().(*mediaprovider.RadioStation)
 		station.StationName = p.radioStationName
 		station.Title = p.radioIcyTitle
@@ -434,12 +519,16 @@ func (p *PlaybackManager) LoadPlaylist(playlistID string, insertQueueMode Insert
 // Load tracks into the play queue.
 // If replacing the current queue (!appendToQueue), playback will be stopped.
 func (p *PlaybackManager) LoadTracks(tracks []*mediaprovider.Track, insertQueueMode InsertQueueMode, shuffle bool) {
+	if insertQueueMode == Replace {
+		p.setRadio(nil)
+	}
 	items := sharedutil.CopyTrackSliceToMediaItemSlice(tracks)
 	p.cmdQueue.LoadItems(items, insertQueueMode, shuffle)
 }
 
 // Replaces the playQueue with tracks and moves the track at idx to position 0
 func (p *PlaybackManager) LoadTracksAndPlayAtIdx(tracks []*mediaprovider.Track, shuffle bool, idx int) {
+	p.setRadio(nil)
 	items := sharedutil.CopyTrackSliceToMediaItemSlice(tracks)
 	p.cmdQueue.LoadItemsAndPlayAtIdx(items, shuffle, idx)
 }
@@ -448,6 +537,9 @@ func (p *PlaybackManager) LoadTracksAndPlayAtIdx(tracks []*mediaprovider.Track,
 // If replacing the current queue (!appendToQueue), playback will be stopped.
 // Loading items into the shuffledPlayQueue may also modify the playQueue
 func (p *PlaybackManager) LoadItems(items []mediaprovider.MediaItem, insertQueueMode InsertQueueMode, shuffle bool) {
+	if insertQueueMode == Replace {
+		p.setRadio(nil)
+	}
 	p.cmdQueue.LoadItems(items, insertQueueMode, shuffle)
 }
 
@@ -503,7 +595,7 @@ func (p *PlaybackManager) PlayTrack(trackID string) error {
 func (p *PlaybackManager) ShuffleArtistAlbums(artistID string) {
 	artist, err := p.engine.sm.Server.GetArtist(artistID)
 	if err != nil {
-		log.Printf("failed to get artist: %v\n", err)
+		playbackLog.Error("failed to get artist", "err", err)
 		return
 	}
 	if len(artist.Albums) == 0 {
@@ -527,7 +619,7 @@ func (p *PlaybackManager) ShuffleArtistAlbums(artistID string) {
 func (p *PlaybackManager) PlayArtistDiscography(artistID string, shuffleTracks bool) {
 	tr, err := p.engine.sm.Server.GetArtistTracks(artistID)
 	if err != nil {
-		log.Printf("failed to get artist tracks: %v\n", err)
+		playbackLog.Error("failed to get artist tracks", "err", err)
 		return
 	}
 	p.LoadTracks(tr, Replace, shuffleTracks)
@@ -618,14 +710,58 @@ func (p *PlaybackManager) PlayRandomAlbums(genreName string) error {
 }
 
 func (p *PlaybackManager) LoadRadioStation(station *mediaprovider.RadioStation, queueMode InsertQueueMode) {
+	if queueMode == Replace {
+		p.setRadio(nil)
+	}
 	p.cmdQueue.LoadRadioStation(station, queueMode)
 }
 
+// PlayTransientItem immediately plays an item pushed from outside the app,
+// such as a stream cast from another device, inserting it after the now
+// playing item. Only one transient item is kept in the queue at a time.
+func (p *PlaybackManager) PlayTransientItem(item mediaprovider.MediaItem) {
+	p.cmdQueue.PlayTransientItem(item)
+}
+
 func (p *PlaybackManager) PlayRadioStation(station *mediaprovider.RadioStation) {
 	p.LoadRadioStation(station, Replace)
 	p.PlayFromBeginning()
 }
 
+// StartRadio replaces the play queue with tracks generated by the radio engine
+// from the given seeds and constraints, and begins playback. The radio will
+// continue generating tracks as the queue nears its end until the queue is replaced.
+func (p *PlaybackManager) StartRadio(params RadioParams) error {
+	mp := p.engine.sm.GetServer()
+	if mp == nil {
+		return errors.New("logged out")
+	}
+	radio := NewRadioEngine(mp, params)
+	tracks := radio.NextBatch(p.appCfg.EnqueueBatchSize, p.shouldIncludeAutoplayTrack)
+	if len(tracks) == 0 {
+		return errors.New("no tracks matched the radio constraints")
+	}
+	p.LoadTracks(tracks, Replace, false)
+	p.setRadio(radio)
+	if p.engine.replayGainCfg.Mode == ReplayGainAuto {
+		p.SetReplayGainMode(player.ReplayGainTrack)
+	}
+	p.PlayFromBeginning()
+	return nil
+}
+
+func (p *PlaybackManager) setRadio(radio *RadioEngine) {
+	p.radioLock.Lock()
+	defer p.radioLock.Unlock()
+	p.radio = radio
+}
+
+func (p *PlaybackManager) currentRadio() *RadioEngine {
+	p.radioLock.Lock()
+	defer p.radioLock.Unlock()
+	return p.radio
+}
+
 func (p *PlaybackManager) fetchAndPlayTracks(fetchFn func() ([]*mediaprovider.Track, error)) error {
 	if songs, err := fetchFn(); err != nil {
 		return err
@@ -643,10 +779,20 @@ func (p *PlaybackManager) GetActivePlayQueue() []mediaprovider.MediaItem {
 	return p.engine.GetActivePlayQueueDeepCopy()
 }
 
+// GetActivePlayQueueWithUpNext returns the active play queue with the
+// items added with "play next" inserted where they will play.
+func (p *PlaybackManager) GetActivePlayQueueWithUpNext() []mediaprovider.MediaItem {
+	return p.engine.GetActivePlayQueueWithUpNextDeepCopy()
+}
+
+// GetPlayQueue returns the unshuffled play queue, with the items
+// added with "play next" inserted where they will play.
 func (p *PlaybackManager) GetPlayQueue() []mediaprovider.MediaItem {
 	return p.engine.GetPlayQueueDeepCopy()
 }
 
+// GetShuffledPlayQueue returns the shuffled play queue, with the items
+// added with "play next" inserted where they will play.
 func (p *PlaybackManager) GetShuffledPlayQueue() []mediaprovider.MediaItem {
 	return p.engine.GetShuffledPlayQueueDeepCopy()
 }
@@ -667,8 +813,25 @@ func (p *PlaybackManager) RemoveTracksFromQueue(idxs []int) {
 	p.cmdQueue.RemoveItemsFromQueue(idxs)
 }
 
+// GetUpNext returns the items added with "play next" that have
+// not yet been moved into the play queue, in the order they will play.
+func (p *PlaybackManager) GetUpNext() []mediaprovider.MediaItem {
+	return p.engine.GetUpNextDeepCopy()
+}
+
+// RemoveFromUpNext removes the items with the given IDs from the up next
+// list. If an item was added more than once, only the first copy is removed.
+func (p *PlaybackManager) RemoveFromUpNext(itemIDs []string) {
+	p.cmdQueue.RemoveItemsFromUpNext(itemIDs)
+}
+
+func (p *PlaybackManager) ClearUpNext() {
+	p.cmdQueue.ClearUpNext()
+}
+
 // Stop playback and clear the play queue.
 func (p *PlaybackManager) StopAndClearPlayQueue() {
+	p.setRadio(nil)
 	p.cmdQueue.StopAndClearPlayQueue()
 }
 
@@ -676,6 +839,13 @@ func (p *PlaybackManager) SetReplayGainOptions(config ReplayGainConfig) {
 	p.engine.SetReplayGainOptions(config)
 }
 
+// SetBitPerfect sets whether the original files are streamed from the server
+// without transcoding, regardless of the transcoding settings.
+// Takes effect from the next track loaded.
+func (p *PlaybackManager) SetBitPerfect(bitPerfect bool) {
+	p.engine.bitPerfect.Store(bitPerfect)
+}
+
 func (p *PlaybackManager) SetReplayGainMode(mode player.ReplayGainMode) {
 	p.engine.SetReplayGainMode(mode)
 }
@@ -725,6 +895,22 @@ func (p *PlaybackManager) SetShuffle(shuffle bool) {
 	p.engine.SetShuffle(shuffle)
 }
 
+// SetShuffleMode sets the shuffle weighting mode (one of ShuffleModes)
+// and whether to avoid playing the same artist twice in a row.
+// If shuffle is currently enabled, the queue is re-shuffled.
+func (p *PlaybackManager) SetShuffleMode(mode string, artistSpread bool) {
+	p.cfg.ShuffleMode = mode
+	p.cfg.ShuffleArtistSpread = artistSpread
+	p.engine.ReshuffleQueue()
+	for _, cb := range p.onShuffleModeChange {
+		cb(mode, artistSpread)
+	}
+}
+
+func (p *PlaybackManager) ShuffleMode() (mode string, artistSpread bool) {
+	return p.cfg.ShuffleMode, p.cfg.ShuffleArtistSpread
+}
+
 func (p *PlaybackManager) Volume() int {
 	return p.engine.CurrentPlayer().GetVolume()
 }
@@ -820,19 +1006,25 @@ func (p *PlaybackManager) enqueueAutoplayTracks() {
 		queue = queue[l-500:]
 	}
 
-	// tracks we will enqueue
-	var tracks []*mediaprovider.Track
+	radio := p.currentRadio()
+	if radio == nil {
+		params := RadioParams{RadioConfig: p.cfg.AutoplayRadio}
+		// seeding from artist and genres only works for tracks
+		if tr, ok := nowPlaying.(*mediaprovider.Track); ok {
+			if len(tr.ArtistIDs) > 0 {
+				params.ArtistIDs = tr.ArtistIDs[:1]
+			}
+			params.Genres = sharedutil.FilterSlice(tr.Genres, func(g string) bool { return g != "" })
+		}
+		radio = NewAutoplayEngine(s, params)
+		radio.SeedHistory(queue)
+	}
 
-	filterAutoplayTracks := func(tracks []*mediaprovider.Track) []*mediaprovider.Track {
-		return sharedutil.FilterSlice(tracks, func(t *mediaprovider.Track) bool {
-			shouldSkip :=
-				(p.cfg.SkipOneStarWhenShuffling && t.Rating == 1) ||
-					(p.cfg.SkipKeywordWhenShuffling != "" && strcase.Contains(t.Title, p.cfg.SkipKeywordWhenShuffling))
-			recentlyPlayed := slices.ContainsFunc(queue, func(i mediaprovider.MediaItem) bool {
-				return i.Metadata().Type == mediaprovider.MediaItemTypeTrack && i.Metadata().ID == t.ID
-			})
-			return !shouldSkip && !recentlyPlayed
+	include := func(t *mediaprovider.Track) bool {
+		recentlyPlayed := slices.ContainsFunc(queue, func(i mediaprovider.MediaItem) bool {
+			return i.Metadata().Type == mediaprovider.MediaItemTypeTrack && i.Metadata().ID == t.ID
 		})
+		return p.shouldIncludeAutoplayTrack(t) && !recentlyPlayed
 	}
 
 	// since this func is invoked in a callback from the playback engine,
@@ -841,57 +1033,23 @@ func (p *PlaybackManager) enqueueAutoplayTracks() {
 	go func() {
 		defer func() { p.pendingAutoplay = false }()
 
-		// first 2 strategies - similar by artist, and similar by genres - only work for tracks
-		if nowPlaying.Metadata().Type == mediaprovider.MediaItemTypeTrack {
-			tr := nowPlaying.(*mediaprovider.Track)
-
-			// similar tracks by artist
-			if len(tr.ArtistIDs) > 0 {
-				similar, err := s.GetSimilarTracks(tr.ArtistIDs[0], p.appCfg.EnqueueBatchSize)
-				if err != nil {
-					log.Printf("autoplay error: failed to get similar tracks: %v", err)
-				}
-				tracks = filterAutoplayTracks(similar)
-			}
-
-			// fallback to random tracks from genre
-			if len(tracks) == 0 {
-				for _, g := range tr.Genres {
-					if g == "" {
-						continue
-					}
-					byGenre, err := s.GetRandomTracks(g, p.appCfg.EnqueueBatchSize)
-					if err != nil {
-						log.Printf("autoplay error: failed to get tracks by genre: %v", err)
-					}
-					tracks = filterAutoplayTracks(byGenre)
-					if len(tracks) > 0 {
-						break
-					}
-				}
-			}
-		}
-
-		// random tracks works regardless of the type of the last playing media
-		if len(tracks) == 0 {
-			// fallback to random tracks
-			random, err := s.GetRandomTracks("", p.appCfg.EnqueueBatchSize)
-			if err != nil {
-				log.Printf("autoplay error: failed to get random tracks: %v", err)
-			}
-			tracks = filterAutoplayTracks(random)
-		}
-
-		if len(tracks) > 0 {
+		if tracks := radio.NextBatch(p.appCfg.EnqueueBatchSize, include); len(tracks) > 0 {
 			p.LoadTracks(tracks, Append, false /*no need to shuffle, already random*/)
 		}
 	}()
 }
 
+func (p *PlaybackManager) shouldIncludeAutoplayTrack(t *mediaprovider.Track) bool {
+	shouldSkip :=
+		(p.cfg.SkipOneStarWhenShuffling && t.Rating == 1) ||
+			(p.cfg.SkipKeywordWhenShuffling != "" && strcase.Contains(t.Title, p.cfg.SkipKeywordWhenShuffling))
+	return !shouldSkip
+}
+
 func (p *PlaybackManager) runCmdQueue(ctx context.Context) {
 	logIfErr := func(action string, err error) {
 		if err != nil {
-			log.Printf("Playback error (%s): %v", action, err)
+			playbackLog.Error("playback error", "action", action, "err", err)
 		}
 	}
 	for {
@@ -923,6 +1081,10 @@ func (p *PlaybackManager) runCmdQueue(ctx context.Context) {
 				logIfErr("UpdatePlayQueue", p.engine.UpdatePlayQueue(c.Arg.([]mediaprovider.MediaItem)))
 			case cmdRemoveTracksFromQueue:
 				p.engine.RemoveTracksFromQueue(c.Arg.([]int))
+			case cmdRemoveFromUpNext:
+				p.engine.RemoveFromUpNext(c.Arg.([]string))
+			case cmdClearUpNext:
+				p.engine.ClearUpNext()
 			case cmdLoadItems:
 				err := p.engine.LoadItems(
 					c.Arg.([]mediaprovider.MediaItem),
@@ -948,11 +1110,20 @@ func (p *PlaybackManager) runCmdQueue(ctx context.Context) {
 					c.Arg.(*mediaprovider.RadioStation),
 					c.Arg2.(InsertQueueMode),
 				)
+			case cmdPlayTransientItem:
+				logIfErr("PlayTransientItem", p.engine.PlayTransientItem(c.Arg.(mediaprovider.MediaItem)))
 			case cmdLoadTrackPaused:
 				logIfErr("LoadTrackPaused", p.engine.loadTrackPaused(c.Arg.(int), c.Arg2.(float64)))
+			case cmdReissueStreamURLs:
+				logIfErr("ReissueStreamURLs", p.engine.ReissueStreamURLs())
+			case cmdPlayerQueueIndexChange:
+				p.engine.handlePlayerQueueIndexChange(c.Arg2.(player.QueuePlayer), c.Arg.(int))
+			case cmdPlayerQueueChange:
+				p.engine.handlePlayerQueueChange(c.Arg3.(player.QueuePlayer),
+					c.Arg.([]*mediaprovider.Track), c.Arg2.(int))
 			case cmdForceRestartPlayback:
 				if mpv, ok := p.engine.CurrentPlayer().(*mpv.Player); ok {
-					log.Println("Force-restarting MPV playback")
+					playbackLog.Warn("force-restarting MPV playback")
 
 					// restart player, but perserve the state
 					isPaused := false
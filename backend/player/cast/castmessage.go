@@ -0,0 +1,134 @@
+package cast
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// castMessage is the CASTV2 protocol's CastMessage protobuf.
+// Only string payloads are used by the namespaces we speak,
+// so it is encoded and decoded by hand rather than with generated code.
+//
+//	message CastMessage {
+//	  required ProtocolVersion protocol_version = 1; // CASTV2_1_0 = 0
+//	  required string source_id = 2;
+//	  required string destination_id = 3;
+//	  required string namespace = 4;
+//	  required PayloadType payload_type = 5; // STRING = 0, BINARY = 1
+//	  optional string payload_utf8 = 6;
+//	  optional bytes payload_binary = 7;
+//	}
+type castMessage struct {
+	SourceID      string
+	DestinationID string
+	Namespace     string
+	PayloadUTF8   string
+}
+
+const (
+	wireVarint = 0
+	wire64Bit  = 1
+	wireBytes  = 2
+	wire32Bit  = 5
+)
+
+// messages larger than this are rejected as a protocol error
+const maxMessageSize = 64 * 1024
+
+func (m *castMessage) marshal() []byte {
+	b := make([]byte, 0, 64+len(m.Namespace)+len(m.PayloadUTF8))
+	b = appendVarintField(b, 1, 0) // CASTV2_1_0
+	b = appendStringField(b, 2, m.SourceID)
+	b = appendStringField(b, 3, m.DestinationID)
+	b = appendStringField(b, 4, m.Namespace)
+	b = appendVarintField(b, 5, 0) // STRING
+	b = appendStringField(b, 6, m.PayloadUTF8)
+	return b
+}
+
+func (m *castMessage) unmarshal(b []byte) error {
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errors.New("cast: malformed message tag")
+		}
+		b = b[n:]
+		field, wireType := tag>>3, tag&7
+		switch wireType {
+		case wireVarint:
+			if _, n = binary.Uvarint(b); n <= 0 {
+				return errors.New("cast: malformed varint")
+			}
+			b = b[n:]
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return errors.New("cast: malformed length-delimited field")
+			}
+			val := string(b[n : n+int(l)])
+			b = b[n+int(l):]
+			switch field {
+			case 2:
+				m.SourceID = val
+			case 3:
+				m.DestinationID = val
+			case 4:
+				m.Namespace = val
+			case 6:
+				m.PayloadUTF8 = val
+			}
+		case wire64Bit:
+			if len(b) < 8 {
+				return errors.New("cast: malformed fixed64")
+			}
+			b = b[8:]
+		case wire32Bit:
+			if len(b) < 4 {
+				return errors.New("cast: malformed fixed32")
+			}
+			b = b[4:]
+		default:
+			return fmt.Errorf("cast: unsupported wire type %d", wireType)
+		}
+	}
+	return nil
+}
+
+// writeMessage writes the message with its 4-byte big-endian length prefix.
+func writeMessage(w io.Writer, m *castMessage) error {
+	payload := m.marshal()
+	buf := make([]byte, 4, 4+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(len(payload)))
+	_, err := w.Write(append(buf, payload...))
+	return err
+}
+
+func readMessage(r io.Reader) (*castMessage, error) {
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		return nil, err
+	}
+	l := binary.BigEndian.Uint32(lenBuf[:])
+	if l > maxMessageSize {
+		return nil, fmt.Errorf("cast: message too large (%d bytes)", l)
+	}
+	buf := make([]byte, l)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	m := &castMessage{}
+	return m, m.unmarshal(buf)
+}
+
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+func appendStringField(b []byte, field int, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
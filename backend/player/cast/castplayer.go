@@ -0,0 +1,631 @@
+package cast
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dweymouth/supersonic/backend/logging"
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+	"github.com/dweymouth/supersonic/backend/player"
+	"github.com/dweymouth/supersonic/backend/util"
+)
+
+var logger = logging.For(logging.Player)
+
+const (
+	stopped = 0
+	playing = 1
+	paused  = 2
+)
+
+// App ID of the Default Media Receiver, which every Cast device supports
+const defaultMediaReceiverAppID = "CC1AD845"
+
+// seconds before the end of the current item
+// that the receiver should begin loading the next
+const preloadTimeSecs = 10
+
+const requestTimeout = 10 * time.Second
+
+// CastPlayer is a URLPlayer which plays on a Chromecast or other Google Cast device
+// through the Default Media Receiver app. The next track is queued ahead
+// on the receiver for gapless playback.
+type CastPlayer struct {
+	player.BasePlayerCallbackImpl
+
+	destroyed atomic.Bool
+
+	ch *channel
+
+	// callbacks for status updates, run in order on the callbacks
+	// goroutine, since they may make requests, whose responses are
+	// received by the channel's read goroutine the updates come from
+	callbacksLock   sync.Mutex
+	callbacks       []func()
+	callbacksSignal chan struct{}
+
+	// coverArtPathFn returns a local filesystem path to the cached cover
+	// art image for the given CoverArtID. When set and the resolver succeeds,
+	// the path is exposed through the local proxy and sent as the media image.
+	coverArtPathFn func(coverArtID string) (string, error)
+
+	// serves local files and streams from servers the device can't reach
+	proxy util.MediaProxy
+
+	lock           sync.Mutex
+	transportID    string
+	sessionID      string
+	mediaSessionID int
+	curItemID      int
+	nextItemID     int
+	maxItemID      int
+	curTrackMeta   mediaprovider.MediaItemMetadata
+	nextTrackMeta  mediaprovider.MediaItemMetadata
+	state          int // stopped, playing, paused
+	volume         int
+
+	seeking atomic.Bool
+	// start playback position in seconds of the last status update or seek
+	lastStartTime float64
+	// how long the track has been playing since lastStartTime
+	stopwatch util.Stopwatch
+}
+
+type receiverStatusMessage struct {
+	Status struct {
+		Applications []struct {
+			AppID       string `json:"appId"`
+			SessionID   string `json:"sessionId"`
+			TransportID string `json:"transportId"`
+		} `json:"applications"`
+		Volume struct {
+			Level *float64 `json:"level"`
+		} `json:"volume"`
+	} `json:"status"`
+}
+
+type mediaStatusMessage struct {
+	Status []mediaStatus `json:"status"`
+}
+
+type mediaStatus struct {
+	MediaSessionID int         `json:"mediaSessionId"`
+	PlayerState    string      `json:"playerState"`
+	IdleReason     string      `json:"idleReason"`
+	CurrentTime    float64     `json:"currentTime"`
+	CurrentItemID  int         `json:"currentItemId"`
+	Items          []queueItem `json:"items"`
+}
+
+type queueItem struct {
+	ItemID int `json:"itemId"`
+}
+
+// NewCastPlayer connects to the device and launches the Default Media Receiver.
+func NewCastPlayer(device Device, coverArtPathFn func(coverArtID string) (string, error)) (*CastPlayer, error) {
+	p := &CastPlayer{
+		coverArtPathFn:  coverArtPathFn,
+		volume:          100,
+		callbacksSignal: make(chan struct{}, 1),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	ch, err := dialChannel(ctx, device.Addr, p.handleMessage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", device.Name, err)
+	}
+	p.ch = ch
+	go p.runCallbacks()
+
+	if err := p.launchReceiverApp(ctx); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("failed to launch media receiver on %s: %w", device.Name, err)
+	}
+	return p, nil
+}
+
+func (p *CastPlayer) launchReceiverApp(ctx context.Context) error {
+	resp, err := p.ch.request(ctx, receiverID, nsReceiver, map[string]any{
+		"type":  "LAUNCH",
+		"appId": defaultMediaReceiverAppID,
+	})
+	// the app may still be starting when the LAUNCH response arrives
+	for err == nil && p.getTransportID() == "" {
+		if t := messageType(resp); t == "LAUNCH_ERROR" || t == "INVALID_REQUEST" {
+			return errors.New(t)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+		resp, err = p.ch.request(ctx, receiverID, nsReceiver, map[string]any{"type": "GET_STATUS"})
+	}
+	if err != nil {
+		return err
+	}
+	return p.ch.connect(p.getTransportID())
+}
+
+func (p *CastPlayer) getTransportID() string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.transportID
+}
+
+func (p *CastPlayer) PlayFile(urlstr string, meta mediaprovider.MediaItemMetadata, startTime float64) error {
+	if p.destroyed.Load() {
+		return nil
+	}
+
+	media := p.buildMedia(urlstr, meta)
+	p.lock.Lock()
+	p.curTrackMeta = meta
+	p.nextTrackMeta = mediaprovider.MediaItemMetadata{}
+	p.curItemID, p.nextItemID = 0, 0
+	p.lastStartTime = startTime
+	p.stopwatch.Reset()
+	p.lock.Unlock()
+
+	resp, err := p.mediaRequest(map[string]any{
+		"type":        "LOAD",
+		"media":       media,
+		"autoplay":    true,
+		"currentTime": startTime,
+	})
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	// status updates about the replaced media may have arrived
+	// in the meantime, so take the current item from the LOAD response
+	var status mediaStatusMessage
+	if json.Unmarshal([]byte(resp.PayloadUTF8), &status) == nil && len(status.Status) > 0 {
+		p.curItemID = status.Status[0].CurrentItemID
+	}
+	p.state = playing
+	p.lock.Unlock()
+	p.stopwatch.Start()
+	p.InvokeOnPlaying()
+	p.InvokeOnTrackChange()
+	if startTime > 0 {
+		p.InvokeOnSeek()
+	}
+	return nil
+}
+
+func (p *CastPlayer) SetNextFile(urlstr string, meta mediaprovider.MediaItemMetadata) error {
+	if p.destroyed.Load() {
+		return nil
+	}
+
+	p.lock.Lock()
+	oldNextID := p.nextItemID
+	p.nextItemID = 0
+	p.nextTrackMeta = meta
+	p.lock.Unlock()
+
+	if oldNextID != 0 {
+		if _, err := p.mediaRequest(map[string]any{
+			"type":    "QUEUE_REMOVE",
+			"itemIds": []int{oldNextID},
+		}); err != nil {
+			return err
+		}
+	}
+	if urlstr == "" {
+		return nil
+	}
+
+	_, err := p.mediaRequest(map[string]any{
+		"type": "QUEUE_INSERT",
+		"items": []map[string]any{{
+			"media":       p.buildMedia(urlstr, meta),
+			"autoplay":    true,
+			"preloadTime": preloadTimeSecs,
+		}},
+	})
+	if err == nil {
+		p.lock.Lock()
+		if p.nextItemID == 0 {
+			// status did not include the queue items. The receiver
+			// assigns item IDs sequentially, so it will be the next one
+			p.maxItemID++
+			p.nextItemID = p.maxItemID
+		}
+		p.lock.Unlock()
+	}
+	return err
+}
+
+func (p *CastPlayer) Continue() error {
+	if p.destroyed.Load() || !p.setStateIf(paused, playing) {
+		return nil
+	}
+	if _, err := p.mediaRequest(map[string]any{"type": "PLAY"}); err != nil {
+		p.setStateIf(playing, paused)
+		return err
+	}
+	p.stopwatch.Start()
+	p.InvokeOnPlaying()
+	return nil
+}
+
+func (p *CastPlayer) Pause() error {
+	if p.destroyed.Load() || !p.setStateIf(playing, paused) {
+		return nil
+	}
+	if _, err := p.mediaRequest(map[string]any{"type": "PAUSE"}); err != nil {
+		p.setStateIf(paused, playing)
+		return err
+	}
+	p.stopwatch.Stop()
+	p.InvokeOnPaused()
+	return nil
+}
+
+func (p *CastPlayer) Stop(force bool) error {
+	if p.destroyed.Load() {
+		return nil
+	}
+	p.lock.Lock()
+	wasStopped := p.state == stopped
+	p.state = stopped
+	p.lock.Unlock()
+	if wasStopped {
+		return nil
+	}
+
+	timeout := requestTimeout
+	if force {
+		timeout = 2 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err := p.mediaRequestCtx(ctx, map[string]any{"type": "STOP"})
+
+	p.stopwatch.Reset()
+	p.lock.Lock()
+	p.lastStartTime = 0
+	p.lock.Unlock()
+	p.InvokeOnStopped()
+	return err
+}
+
+func (p *CastPlayer) SeekSeconds(secs float64) error {
+	if p.destroyed.Load() {
+		return nil
+	}
+	p.seeking.Store(true)
+	_, err := p.mediaRequest(map[string]any{
+		"type":        "SEEK",
+		"currentTime": secs,
+	})
+	p.seeking.Store(false)
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	p.lastStartTime = secs
+	isPlaying := p.state == playing
+	p.lock.Unlock()
+	p.stopwatch.Reset()
+	if isPlaying {
+		p.stopwatch.Start()
+	}
+	p.InvokeOnSeek()
+	return nil
+}
+
+func (p *CastPlayer) IsSeeking() bool {
+	return p.seeking.Load()
+}
+
+func (p *CastPlayer) SetVolume(vol int) error {
+	if p.destroyed.Load() {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	_, err := p.ch.request(ctx, receiverID, nsReceiver, map[string]any{
+		"type":   "SET_VOLUME",
+		"volume": map[string]any{"level": float64(vol) / 100},
+	})
+	if err == nil {
+		p.lock.Lock()
+		p.volume = vol
+		p.lock.Unlock()
+	}
+	return err
+}
+
+func (p *CastPlayer) GetVolume() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.volume
+}
+
+func (p *CastPlayer) GetStatus() player.Status {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	state := player.Stopped
+	if p.state == playing {
+		state = player.Playing
+	} else if p.state == paused {
+		state = player.Paused
+	}
+	return player.Status{
+		State:    state,
+		TimePos:  p.lastStartTime + p.stopwatch.Elapsed().Seconds(),
+		Duration: p.curTrackMeta.Duration.Seconds(),
+	}
+}
+
+func (p *CastPlayer) Destroy() {
+	p.destroyed.Store(true)
+	if transportID := p.getTransportID(); transportID != "" {
+		p.ch.send(transportID, nsConnection, map[string]any{"type": "CLOSE"})
+	}
+	p.ch.Close()
+	p.proxy.Shutdown()
+}
+
+// sends a request to the media receiver app for the current media session
+func (p *CastPlayer) mediaRequest(payload map[string]any) (*castMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	return p.mediaRequestCtx(ctx, payload)
+}
+
+func (p *CastPlayer) mediaRequestCtx(ctx context.Context, payload map[string]any) (*castMessage, error) {
+	p.lock.Lock()
+	transportID := p.transportID
+	if payload["type"] != "LOAD" {
+		payload["mediaSessionId"] = p.mediaSessionID
+	}
+	p.lock.Unlock()
+
+	resp, err := p.ch.request(ctx, transportID, nsMedia, payload)
+	if err != nil {
+		return nil, err
+	}
+	switch t := messageType(resp); t {
+	case "MEDIA_STATUS":
+		return resp, nil
+	default:
+		return nil, fmt.Errorf("cast: %s failed: %s", payload["type"], t)
+	}
+}
+
+// sets the state to newState if it is currently oldState,
+// and returns whether the state was changed
+func (p *CastPlayer) setStateIf(oldState, newState int) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.state != oldState {
+		return false
+	}
+	p.state = newState
+	return true
+}
+
+func (p *CastPlayer) buildMedia(urlstr string, meta mediaprovider.MediaItemMetadata) map[string]any {
+	if needsProxy(urlstr) {
+		p.proxy.EnsureStarted()
+		urlstr = p.proxy.Add(urlstr)
+	}
+	metadata := map[string]any{
+		"metadataType": 3, // MusicTrackMediaMetadata
+		"title":        meta.Name,
+		"artist":       strings.Join(meta.Artists, ", "),
+		"albumName":    meta.Album,
+	}
+	if meta.TrackNumber > 0 {
+		metadata["trackNumber"] = meta.TrackNumber
+	}
+	if meta.CoverArtID != "" && p.coverArtPathFn != nil {
+		if path, err := p.coverArtPathFn(meta.CoverArtID); err == nil {
+			p.proxy.EnsureStarted()
+			metadata["images"] = []map[string]any{{"url": p.proxy.Add(path)}}
+		}
+	}
+	contentType := meta.MIMEType
+	if contentType == "" {
+		contentType = "audio/mpeg"
+	}
+	media := map[string]any{
+		"contentId":   urlstr,
+		"contentUrl":  urlstr,
+		"contentType": contentType,
+		"streamType":  "BUFFERED",
+		"metadata":    metadata,
+	}
+	if meta.Type == mediaprovider.MediaItemTypeRadioStation {
+		media["streamType"] = "LIVE"
+	} else if meta.Duration > 0 {
+		media["duration"] = meta.Duration.Seconds()
+	}
+	return media
+}
+
+// Cast devices fetch media directly from the server unless they can't reach it:
+// local (cached) files, and servers on loopback or mDNS hostnames,
+// which Cast devices do not resolve.
+func needsProxy(urlstr string) bool {
+	u, err := url.Parse(urlstr)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return true
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".local") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// invoked on the channel's read goroutine
+func (p *CastPlayer) handleMessage(m *castMessage) {
+	switch m.Namespace {
+	case nsReceiver:
+		if messageType(m) == "RECEIVER_STATUS" {
+			var status receiverStatusMessage
+			if err := json.Unmarshal([]byte(m.PayloadUTF8), &status); err == nil {
+				p.handleReceiverStatus(&status)
+			}
+		}
+	case nsMedia:
+		switch t := messageType(m); t {
+		case "MEDIA_STATUS":
+			var status mediaStatusMessage
+			if err := json.Unmarshal([]byte(m.PayloadUTF8), &status); err == nil {
+				for _, s := range status.Status {
+					p.handleMediaStatus(&s)
+				}
+			}
+		case "LOAD_FAILED", "LOAD_CANCELLED", "INVALID_REQUEST":
+			logger.Error("cast media receiver error", "payload", m.PayloadUTF8)
+		}
+	}
+}
+
+func (p *CastPlayer) handleReceiverStatus(status *receiverStatusMessage) {
+	p.lock.Lock()
+	if l := status.Status.Volume.Level; l != nil {
+		p.volume = int(*l*100 + 0.5)
+	}
+	appRunning := false
+	for _, app := range status.Status.Applications {
+		if app.AppID == defaultMediaReceiverAppID {
+			appRunning = true
+			p.transportID = app.TransportID
+			p.sessionID = app.SessionID
+		}
+	}
+	// another sender may have stopped our session or launched a different app
+	stoppedExternally := !appRunning && p.sessionID != "" && p.state != stopped
+	if stoppedExternally {
+		p.state = stopped
+	}
+	p.lock.Unlock()
+
+	if stoppedExternally {
+		p.stopwatch.Reset()
+		p.queueCallbacks(p.InvokeOnStopped)
+	}
+}
+
+func (p *CastPlayer) handleMediaStatus(s *mediaStatus) {
+	var invokeCallbacks []func()
+
+	p.lock.Lock()
+	if s.MediaSessionID != 0 {
+		p.mediaSessionID = s.MediaSessionID
+	}
+	p.maxItemID = max(p.maxItemID, s.CurrentItemID)
+	for _, item := range s.Items {
+		p.maxItemID = max(p.maxItemID, item.ItemID)
+	}
+
+	if p.curItemID == 0 {
+		p.curItemID = s.CurrentItemID
+	} else if s.CurrentItemID != 0 && s.CurrentItemID != p.curItemID && p.nextTrackMeta.ID != "" {
+		// receiver advanced to the queued next track
+		p.curItemID = s.CurrentItemID
+		p.curTrackMeta = p.nextTrackMeta
+		p.nextTrackMeta = mediaprovider.MediaItemMetadata{}
+		p.nextItemID = 0
+		invokeCallbacks = append(invokeCallbacks, p.InvokeOnTrackChange)
+	}
+	if p.nextTrackMeta.ID != "" && p.nextItemID == 0 && len(s.Items) > 0 {
+		if last := slices.MaxFunc(s.Items, func(a, b queueItem) int {
+			return a.ItemID - b.ItemID
+		}); last.ItemID > p.curItemID {
+			p.nextItemID = last.ItemID
+		}
+	}
+
+	if p.state != stopped {
+		switch s.PlayerState {
+		case "PLAYING":
+			if p.state == paused {
+				p.state = playing
+				invokeCallbacks = append(invokeCallbacks, p.InvokeOnPlaying)
+			}
+		case "PAUSED":
+			if p.state == playing {
+				p.state = paused
+				invokeCallbacks = append(invokeCallbacks, p.InvokeOnPaused)
+			}
+		case "IDLE":
+			// INTERRUPTED and CANCELLED are from replacing or stopping
+			// the media ourselves; FINISHED means the end of the queue
+			if s.IdleReason == "FINISHED" || s.IdleReason == "ERROR" {
+				p.state = stopped
+				p.curTrackMeta = mediaprovider.MediaItemMetadata{}
+				invokeCallbacks = append(invokeCallbacks, p.InvokeOnStopped)
+			}
+		}
+	}
+
+	// sync playback time with the receiver
+	if s.PlayerState != "" && s.PlayerState != "IDLE" {
+		p.lastStartTime = s.CurrentTime
+		p.stopwatch.Reset()
+		if s.PlayerState == "PLAYING" {
+			p.stopwatch.Start()
+		}
+	}
+	p.lock.Unlock()
+
+	p.queueCallbacks(invokeCallbacks...)
+}
+
+// queueCallbacks runs the callbacks on the callbacks goroutine.
+func (p *CastPlayer) queueCallbacks(cbs ...func()) {
+	if len(cbs) == 0 {
+		return
+	}
+	p.callbacksLock.Lock()
+	p.callbacks = append(p.callbacks, cbs...)
+	p.callbacksLock.Unlock()
+	select {
+	case p.callbacksSignal <- struct{}{}:
+	default: // already signaled
+	}
+}
+
+func (p *CastPlayer) runCallbacks() {
+	for {
+		select {
+		case <-p.ch.closed:
+			return
+		case <-p.callbacksSignal:
+		}
+		p.callbacksLock.Lock()
+		cbs := p.callbacks
+		p.callbacks = nil
+		p.callbacksLock.Unlock()
+		for _, cb := range cbs {
+			cb()
+		}
+	}
+}
+
+func messageType(m *castMessage) string {
+	var hdr messageHeader
+	json.Unmarshal([]byte(m.PayloadUTF8), &hdr)
+	return hdr.Type
+}
@@ -0,0 +1,249 @@
+package cast
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+)
+
+func TestCastMessage_RoundTrip(t *testing.T) {
+	m := &castMessage{
+		SourceID:      senderID,
+		DestinationID: receiverID,
+		Namespace:     nsReceiver,
+		PayloadUTF8:   `{"type":"GET_STATUS","requestId":1}`,
+	}
+	var buf bytes.Buffer
+	if err := writeMessage(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readMessage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *m {
+		t.Errorf("round trip mismatch: got %+v, want %+v", got, m)
+	}
+}
+
+func TestNeedsProxy(t *testing.T) {
+	for url, want := range map[string]bool{
+		"/home/user/.cache/track.mp3":     true,
+		"http://localhost:4533/rest/x":    true,
+		"http://127.0.0.1:4533/rest/x":    true,
+		"http://nas.local:4533/rest/x":    true,
+		"http://192.168.1.10:4533/rest/x": false,
+		"https://music.example.com/x":     false,
+	} {
+		if got := needsProxy(url); got != want {
+			t.Errorf("needsProxy(%q) = %v, want %v", url, got, want)
+		}
+	}
+}
+
+// fakeReceiver is a minimal Cast device that runs the
+// Default Media Receiver and advances to queued items on request.
+type fakeReceiver struct {
+	t        *testing.T
+	listener net.Listener
+
+	lock      sync.Mutex
+	conn      net.Conn
+	loaded    []string // contentIds of LOADed and QUEUE_INSERTed items
+	curItemID int
+}
+
+func newFakeReceiver(t *testing.T) *fakeReceiver {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &fakeReceiver{t: t, listener: l}
+	go r.serve()
+	t.Cleanup(func() { l.Close() })
+	return r
+}
+
+func (r *fakeReceiver) serve() {
+	conn, err := r.listener.Accept()
+	if err != nil {
+		return
+	}
+	r.lock.Lock()
+	r.conn = conn
+	r.lock.Unlock()
+	defer conn.Close()
+	for {
+		m, err := readMessage(conn)
+		if err != nil {
+			return
+		}
+		var req map[string]any
+		json.Unmarshal([]byte(m.PayloadUTF8), &req)
+		reqID := req["requestId"]
+		switch req["type"] {
+		case "LAUNCH", "GET_STATUS":
+			r.reply(m, map[string]any{
+				"type":      "RECEIVER_STATUS",
+				"requestId": reqID,
+				"status": map[string]any{
+					"applications": []map[string]any{{
+						"appId":       defaultMediaReceiverAppID,
+						"sessionId":   "session-1",
+						"transportId": "transport-1",
+					}},
+					"volume": map[string]any{"level": 0.5},
+				},
+			})
+		case "LOAD", "QUEUE_INSERT", "QUEUE_REMOVE", "PLAY", "PAUSE", "SEEK", "STOP":
+			r.lock.Lock()
+			switch req["type"] {
+			case "LOAD":
+				r.loaded = []string{req["media"].(map[string]any)["contentId"].(string)}
+				r.curItemID = 1
+			case "QUEUE_INSERT":
+				item := req["items"].([]any)[0].(map[string]any)
+				r.loaded = append(r.loaded, item["media"].(map[string]any)["contentId"].(string))
+			}
+			status := r.mediaStatusLocked("PLAYING", reqID)
+			r.lock.Unlock()
+			r.reply(m, status)
+		}
+	}
+}
+
+func (r *fakeReceiver) mediaStatusLocked(state string, reqID any) map[string]any {
+	var items []map[string]any
+	for i := range r.loaded {
+		items = append(items, map[string]any{"itemId": i + 1})
+	}
+	return map[string]any{
+		"type":      "MEDIA_STATUS",
+		"requestId": reqID,
+		"status": []map[string]any{{
+			"mediaSessionId": 1,
+			"playerState":    state,
+			"currentItemId":  r.curItemID,
+			"items":          items,
+		}},
+	}
+}
+
+// advance simulates the receiver moving on to the next queued item.
+func (r *fakeReceiver) advance() {
+	r.lock.Lock()
+	r.curItemID++
+	status := r.mediaStatusLocked("PLAYING", 0)
+	r.lock.Unlock()
+	r.reply(&castMessage{SourceID: senderID, DestinationID: "transport-1", Namespace: nsMedia}, status)
+}
+
+func (r *fakeReceiver) reply(req *castMessage, payload map[string]any) {
+	b, _ := json.Marshal(payload)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	writeMessage(r.conn, &castMessage{
+		SourceID:      req.DestinationID,
+		DestinationID: req.SourceID,
+		Namespace:     req.Namespace,
+		PayloadUTF8:   string(b),
+	})
+}
+
+func TestCastPlayer_PlayAndQueueNext(t *testing.T) {
+	r := newFakeReceiver(t)
+	p, err := NewCastPlayer(Device{Name: "Fake", Addr: r.listener.Addr().String()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Destroy()
+
+	if vol := p.GetVolume(); vol != 50 {
+		t.Errorf("expected volume 50 from receiver status, got %d", vol)
+	}
+
+	trackChanged := make(chan struct{}, 2)
+	p.OnTrackChange(func() { trackChanged <- struct{}{} })
+
+	const url1, url2 = "http://192.168.1.10/1.mp3", "http://192.168.1.10/2.mp3"
+	if err := p.PlayFile(url1, mediaprovider.MediaItemMetadata{ID: "1", Name: "One"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	<-trackChanged
+	if err := p.SetNextFile(url2, mediaprovider.MediaItemMetadata{ID: "2", Name: "Two"}); err != nil {
+		t.Fatal(err)
+	}
+	r.lock.Lock()
+	loaded := append([]string(nil), r.loaded...)
+	r.lock.Unlock()
+	if len(loaded) != 2 || loaded[0] != url1 || loaded[1] != url2 {
+		t.Fatalf("unexpected receiver queue: %v", loaded)
+	}
+
+	r.advance()
+	select {
+	case <-trackChanged:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for track change")
+	}
+	p.lock.Lock()
+	curID := p.curTrackMeta.ID
+	p.lock.Unlock()
+	if curID != "2" {
+		t.Errorf("expected current track 2 after advance, got %q", curID)
+	}
+}
+
+func TestCastPlayer_RequestFromStatusCallback(t *testing.T) {
+	r := newFakeReceiver(t)
+	p, err := NewCastPlayer(Device{Name: "Fake", Addr: r.listener.Addr().String()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Destroy()
+
+	if err := p.PlayFile("http://192.168.1.10/1.mp3", mediaprovider.MediaItemMetadata{ID: "1"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetNextFile("http://192.168.1.10/2.mp3", mediaprovider.MediaItemMetadata{ID: "2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// like the playback engine pausing after the current track
+	pauseErr := make(chan error, 1)
+	p.OnTrackChange(func() { pauseErr <- p.Pause() })
+	r.advance()
+	select {
+	case err := <-pauseErr:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("request from a status update callback blocked")
+	}
+}
@@ -0,0 +1,45 @@
+package cast
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/dweymouth/supersonic/backend/util"
+)
+
+// Device is a Cast device found on the local network.
+type Device struct {
+	// Friendly name of the device, e.g. "Living Room speaker"
+	Name  string
+	Model string
+	// Unique device ID
+	ID string
+	// host:port of the device's CASTV2 endpoint
+	Addr string
+}
+
+const castService = "_googlecast._tcp.local."
+
+// Discover searches the local network for Cast devices using mDNS,
+// returning the devices that responded within the wait time.
+func Discover(ctx context.Context, wait time.Duration) ([]Device, error) {
+	services, err := util.BrowseMDNS(ctx, castService, wait)
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]Device, 0, len(services))
+	for _, s := range services {
+		friendlyName := s.TXT["fn"]
+		if friendlyName == "" {
+			friendlyName = strings.TrimSuffix(s.Instance, "."+castService)
+		}
+		devices = append(devices, Device{
+			Name:  friendlyName,
+			Model: s.TXT["md"],
+			ID:    s.TXT["id"],
+			Addr:  s.Addr,
+		})
+	}
+	return devices, nil
+}
@@ -2,20 +2,17 @@ package dlna
 
 import (
 	"context"
-	"crypto/md5"
-	"encoding/base64"
 	"errors"
 	"fmt"
-	"io"
-	"log"
-	"net"
 	"net/http"
-	"os"
+	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/dweymouth/supersonic/backend/logging"
 	"github.com/dweymouth/supersonic/backend/mediaprovider"
 	"github.com/dweymouth/supersonic/backend/player"
 	"github.com/dweymouth/supersonic/backend/util"
@@ -25,16 +22,23 @@ import (
 	"github.com/supersonic-app/go-upnpcast/services/renderingcontrol"
 )
 
+var logger = logging.For(logging.DLNA)
+
 const (
 	stopped = 0
 	playing = 1
 	paused  = 2
 )
 
-type proxyMapEntry struct {
-	key string
-	url string
-}
+const (
+	// how often the renderer's state is polled if it does not support events
+	pollInterval = 5 * time.Second
+	// how long after sending a transport command renderer state changes
+	// are considered a result of the command rather than external control
+	transportCmdGracePeriod = 3 * time.Second
+	// a renderer stopping this close to the end of the track has finished it
+	trackEndMargin = 5 * time.Second
+)
 
 type DLNAPlayer struct {
 	player.BasePlayerCallbackImpl
@@ -67,32 +71,45 @@ type DLNAPlayer struct {
 	// how long the track has been playing since last time sync
 	stopwatch util.Stopwatch
 
-	proxyServer *http.Server
-	proxyActive atomic.Bool
-	localIP     string
-	proxyPort   int
+	proxy util.MediaProxy
 
 	pendingSeek     bool
 	pendingSeekSecs float64
 
-	// keep in order of most recently accessed at the end
-	// that way the item in proxyURLs[0] can be kicked out
-	// when adding a new URL to the proxy, since
-	// only two will need to be active at any given time
-	proxyURLs    [3]proxyMapEntry
-	proxyURLLock sync.Mutex
-
 	// If SetNextAVTransport fails (e.g. because the device
 	// does not support the API/gapless), this flag is set
-	// true, and the next firing of the track change timer
-	// should clear it to false and use SetAVTransport
-	// to begin playing the item in nextTrackMeta.
+	// true, and the next track change (from the timer, or the
+	// renderer stopping at the end of the track) should clear
+	// it to false and use SetAVTransport to begin playing
+	// the item in nextTrackMeta.
 	failedToSetNext    bool
 	unsetNextMediaItem *avtransport.MediaItem
 
 	timerActive atomic.Bool
 	timer       *time.Timer
 	resetChan   chan (time.Duration)
+
+	// If the renderer supports UPnP events, its state changes and
+	// track transitions are reported by the renderer, and the track
+	// change timer is not used. Otherwise the renderer is polled.
+	events       *eventListener
+	eventsActive atomic.Bool
+	stopPolling  chan struct{}
+
+	// guards the playback state (state, lastStartTime, stopwatch,
+	// pendingSeek, lastTransportCmd) which is updated both by the
+	// player API and by renderer events. Renderer requests and
+	// callbacks must not be made while holding it.
+	eventLock sync.Mutex
+
+	// proxy URLs of the current and next media items,
+	// to recognize the track transitions reported by the renderer
+	curURL  string
+	nextURL string
+
+	lastTransportCmd time.Time
+	volume           atomic.Int32 // last known renderer volume, or -1
+	onVolumeChange   func(int)
 }
 
 func NewDLNAPlayer(device *device.MediaRenderer, coverArtPathFn func(coverArtID string) (string, error)) (*DLNAPlayer, error) {
@@ -120,12 +137,18 @@ func NewDLNAPlayer(device *device.MediaRenderer, coverArtPathFn func(coverArtID
 		return nil, fmt.Errorf("failed to connect to %s", device.FriendlyName)
 	}
 
-	return &DLNAPlayer{
+	d := &DLNAPlayer{
 		avTransport:    avt,
 		renderControl:  rc,
 		resetChan:      make(chan time.Duration),
 		coverArtPathFn: coverArtPathFn,
-	}, nil
+	}
+	d.volume.Store(-1)
+	if err := d.subscribeEvents(device.URL); err != nil {
+		logger.Warn("renderer events unavailable, polling instead", "err", err)
+		d.startPolling()
+	}
+	return d, nil
 }
 
 // buildMediaItem assembles the avtransport.MediaItem for a track. It
@@ -155,8 +178,7 @@ func (d *DLNAPlayer) buildMediaItem(playbackURL string, meta mediaprovider.Media
 	}
 	if meta.CoverArtID != "" && d.coverArtPathFn != nil {
 		if path, err := d.coverArtPathFn(meta.CoverArtID); err == nil {
-			artKey := d.addURLToProxy(path)
-			item.AlbumArtURI = d.urlForItem(artKey)
+			item.AlbumArtURI = d.proxy.Add(path)
 		}
 	}
 	return item
@@ -169,7 +191,11 @@ func (d *DLNAPlayer) SetVolume(vol int) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	d.cancelRequest = cancel
 	defer cancel()
-	return d.renderControl.SetVolume(ctx, vol)
+	if err := d.renderControl.SetVolume(ctx, vol); err != nil {
+		return err
+	}
+	d.volume.Store(int32(vol))
+	return nil
 }
 
 func (d *DLNAPlayer) GetVolume() int {
@@ -179,23 +205,33 @@ func (d *DLNAPlayer) GetVolume() int {
 	ctx, cancel := context.WithCancel(context.Background())
 	d.cancelRequest = cancel
 	defer cancel()
-	vol, _ := d.renderControl.GetVolume(ctx)
+	vol, err := d.renderControl.GetVolume(ctx)
+	if err == nil {
+		d.volume.Store(int32(vol))
+	}
 	return vol
 }
 
+// OnVolumeChange sets a callback invoked when the volume
+// is changed on the renderer itself or by another control point.
+func (d *DLNAPlayer) OnVolumeChange(cb func(int)) {
+	d.onVolumeChange = cb
+}
+
 func (d *DLNAPlayer) PlayFile(urlstr string, meta mediaprovider.MediaItemMetadata, startTime float64) error {
 	if d.destroyed {
 		return nil
 	}
 
-	d.ensureSetupProxy()
+	d.proxy.EnsureStarted()
+
+	media := d.buildMediaItem(d.proxy.Add(urlstr), meta)
 
 	d.metaLock.Lock()
 	d.curTrackMeta = meta
+	d.curURL = media.URL
+	d.nextURL = ""
 	d.metaLock.Unlock()
-	key := d.addURLToProxy(urlstr)
-
-	media := d.buildMediaItem(d.urlForItem(key), meta)
 
 	if err := d.playAVTransportMedia(&media); err != nil {
 		return err
@@ -217,12 +253,14 @@ func (d *DLNAPlayer) PlayFile(urlstr string, meta mediaprovider.MediaItemMetadat
 			d.pendingPlayStart = false
 		}()
 	}
+	d.eventLock.Lock()
 	d.state = playing
-	remainingDur := meta.Duration - time.Duration(startTime)*time.Second
-	d.setTrackChangeTimer(remainingDur)
 	d.stopwatch.Reset()
 	d.stopwatch.Start()
 	d.lastStartTime = int(startTime)
+	d.eventLock.Unlock()
+	remainingDur := meta.Duration - time.Duration(startTime)*time.Second
+	d.setTrackChangeTimer(remainingDur)
 	d.InvokeOnPlaying()
 	d.InvokeOnTrackChange()
 	if startTime > 0 {
@@ -237,6 +275,7 @@ func (d *DLNAPlayer) playAVTransportMedia(media *avtransport.MediaItem) error {
 	d.cancelRequest = cancel
 	defer cancel()
 
+	d.markTransportCmd()
 	err := d.avTransport.SetAVTransportMedia(ctx, media)
 	if err != nil {
 		return err
@@ -253,19 +292,19 @@ func (d *DLNAPlayer) SetNextFile(url string, meta mediaprovider.MediaItemMetadat
 	}
 
 	var media *avtransport.MediaItem
-	d.metaLock.Lock()
-	d.nextTrackMeta = meta
-	d.metaLock.Unlock()
 	if url != "" {
-		d.ensureSetupProxy()
+		d.proxy.EnsureStarted()
 
-		key := d.addURLToProxy(url)
-		item := d.buildMediaItem(d.urlForItem(key), meta)
+		item := d.buildMediaItem(d.proxy.Add(url), meta)
 		media = &item
 	} else {
 		// empty media item to signify erasing next track in device queue
 		media = &avtransport.MediaItem{}
 	}
+	d.metaLock.Lock()
+	d.nextTrackMeta = meta
+	d.nextURL = media.URL
+	d.metaLock.Unlock()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	d.cancelRequest = cancel
@@ -281,7 +320,7 @@ func (d *DLNAPlayer) SetNextFile(url string, meta mediaprovider.MediaItemMetadat
 }
 
 func (d *DLNAPlayer) Continue() error {
-	if d.destroyed || d.state == playing {
+	if d.destroyed || d.playerState() == playing {
 		return nil
 	}
 
@@ -289,10 +328,13 @@ func (d *DLNAPlayer) Continue() error {
 	d.cancelRequest = cancel
 	defer cancel()
 
-	if d.pendingSeek {
-		d.pendingSeek = false
-		err := d.avTransport.Seek(ctx, int(d.pendingSeekSecs))
-		if err != nil {
+	d.eventLock.Lock()
+	d.lastTransportCmd = time.Now()
+	pendingSeek, seekSecs := d.pendingSeek, d.pendingSeekSecs
+	d.pendingSeek = false
+	d.eventLock.Unlock()
+	if pendingSeek {
+		if err := d.avTransport.Seek(ctx, int(seekSecs)); err != nil {
 			return err
 		}
 	}
@@ -300,30 +342,36 @@ func (d *DLNAPlayer) Continue() error {
 	if err := d.avTransport.Play(ctx); err != nil {
 		return err
 	}
+	d.eventLock.Lock()
+	d.state = playing
+	d.stopwatch.Start()
+	playPos := d.curPlayPos()
+	d.eventLock.Unlock()
 	d.metaLock.Lock()
-	nextTrackChange := d.curTrackMeta.Duration - d.curPlayPos()
+	nextTrackChange := d.curTrackMeta.Duration - playPos
 	d.metaLock.Unlock()
-	d.state = playing
 	d.setTrackChangeTimer(nextTrackChange)
-	d.stopwatch.Start()
 	d.InvokeOnPlaying()
 	return nil
 }
 
 func (d *DLNAPlayer) Pause() error {
-	if d.destroyed || d.state != playing {
+	if d.destroyed || d.playerState() != playing {
 		return nil
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	d.cancelRequest = cancel
 	defer cancel()
+	d.markTransportCmd()
 	if err := d.avTransport.Pause(ctx); err != nil {
 		return err
 	}
-	d.setTrackChangeTimer(0)
+	d.eventLock.Lock()
 	d.stopwatch.Stop()
 	d.state = paused
+	d.eventLock.Unlock()
+	d.setTrackChangeTimer(0)
 	d.InvokeOnPaused()
 	return nil
 }
@@ -336,7 +384,7 @@ func (d *DLNAPlayer) Stop(force bool) error {
 		d.cancelRequest()
 	}
 
-	switch d.state {
+	switch d.playerState() {
 	case stopped:
 		return nil
 	case playing:
@@ -350,15 +398,18 @@ func (d *DLNAPlayer) Stop(force bool) error {
 		d.cancelRequest = cancel
 		defer cancel()
 
+		d.markTransportCmd()
 		if err := d.avTransport.Pause(ctx); err != nil {
 			return err
 		}
 		fallthrough
 	case paused:
-		d.setTrackChangeTimer(0)
+		d.eventLock.Lock()
 		d.stopwatch.Reset()
 		d.lastStartTime = 0
 		d.state = stopped
+		d.eventLock.Unlock()
+		d.setTrackChangeTimer(0)
 		d.InvokeOnStopped()
 		return nil
 	default:
@@ -371,24 +422,31 @@ func (d *DLNAPlayer) SeekSeconds(secs float64) error {
 		return nil
 	}
 
-	if d.state == paused {
+	state := d.playerState()
+	if state == paused {
+		d.eventLock.Lock()
 		d.pendingSeek = true
 		d.pendingSeekSecs = secs
+		d.eventLock.Unlock()
 	} else {
 		if err := d.sendSeekCmd(secs); err != nil {
 			return err
 		}
 	}
 
+	d.eventLock.Lock()
 	d.lastStartTime = int(secs)
 	d.stopwatch.Reset()
+	if state == playing {
+		d.stopwatch.Start()
+	}
+	d.eventLock.Unlock()
 
-	if d.state == playing {
+	if state == playing {
 		d.metaLock.Lock()
 		nextTrackChange := d.curTrackMeta.Duration - time.Duration(secs)*time.Second
 		d.metaLock.Unlock()
 		d.setTrackChangeTimer(nextTrackChange)
-		d.stopwatch.Start()
 	}
 
 	d.InvokeOnSeek()
@@ -404,6 +462,7 @@ func (d *DLNAPlayer) SeekSeconds(secs float64) error {
 
 func (d *DLNAPlayer) sendSeekCmd(secs float64) error {
 	d.seeking = true
+	d.markTransportCmd()
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	if err := d.avTransport.Seek(ctx, int(secs)); err != nil {
@@ -419,17 +478,21 @@ func (d *DLNAPlayer) IsSeeking() bool {
 }
 
 func (d *DLNAPlayer) GetStatus() player.Status {
+	d.eventLock.Lock()
 	state := player.Stopped
 	if d.state == playing {
 		state = player.Playing
 	} else if d.state == paused {
 		state = player.Paused
 	}
-
 	var timePos float64
 	if !d.pendingPlayStart {
 		timePos = d.curPlayPos().Seconds()
 	}
+	d.eventLock.Unlock()
+
+	d.metaLock.Lock()
+	defer d.metaLock.Unlock()
 	return player.Status{
 		State:    state,
 		TimePos:  timePos,
@@ -437,64 +500,66 @@ func (d *DLNAPlayer) GetStatus() player.Status {
 	}
 }
 
+func (d *DLNAPlayer) playerState() int {
+	d.eventLock.Lock()
+	defer d.eventLock.Unlock()
+	return d.state
+}
+
+// must be called with eventLock held
 func (d *DLNAPlayer) curPlayPos() time.Duration {
 	return time.Duration(d.lastStartTime)*time.Second + d.stopwatch.Elapsed()
 }
 
+func (d *DLNAPlayer) markTransportCmd() {
+	d.eventLock.Lock()
+	d.lastTransportCmd = time.Now()
+	d.eventLock.Unlock()
+}
+
 func (d *DLNAPlayer) Destroy() {
 	d.destroyed = true
 	d.setTrackChangeTimer(0)
 	if d.cancelRequest != nil {
 		d.cancelRequest()
 	}
-
-	if d.proxyServer != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-		defer cancel()
-		go d.proxyServer.Shutdown(ctx)
-		d.proxyServer = nil
+	d.eventLock.Lock()
+	if d.events != nil {
+		go d.events.close()
+		d.events = nil
 	}
+	if d.stopPolling != nil {
+		close(d.stopPolling)
+		d.stopPolling = nil
+	}
+	d.eventLock.Unlock()
+	d.proxy.Shutdown()
 }
 
 func (d *DLNAPlayer) syncPlaybackTime() {
 	start := time.Now()
 	if pos, err := d.avTransport.GetPositionInfo(context.Background()); err == nil {
+		d.eventLock.Lock()
 		d.lastStartTime = int(pos.RelTime.Seconds() + (time.Since(start) / 2).Seconds())
 		d.stopwatch.Reset()
 		if d.state == playing {
 			d.stopwatch.Start()
 		}
-		d.setTrackChangeTimer(d.curTrackMeta.Duration - time.Duration(d.lastStartTime)*time.Second)
+		startTime := time.Duration(d.lastStartTime) * time.Second
+		d.eventLock.Unlock()
+		d.metaLock.Lock()
+		remaining := d.curTrackMeta.Duration - startTime
+		d.metaLock.Unlock()
+		d.setTrackChangeTimer(remaining)
 		d.InvokeOnSeek()
 	}
 }
 
-func (d *DLNAPlayer) ensureSetupProxy() error {
-	if d.proxyActive.Swap(true) {
-		return nil // already active
-	}
-
-	var err error
-	d.localIP, err = util.GetLocalIP()
-	if err != nil {
-		return err
-	}
-
-	listener, err := net.Listen("tcp", ":0")
-	if err != nil {
-		return err
-	}
-	d.proxyPort = listener.Addr().(*net.TCPAddr).Port
-
-	d.proxyServer = &http.Server{
-		Handler: http.HandlerFunc(d.handleRequest),
-	}
-
-	go d.proxyServer.Serve(listener)
-	return nil
-}
-
 func (d *DLNAPlayer) setTrackChangeTimer(dur time.Duration) {
+	if dur != 0 && d.eventsActive.Load() {
+		// track changes are reported by the renderer
+		return
+	}
 	if d.timerActive.Swap(true) {
 		// was active
 		d.resetChan <- dur
@@ -539,36 +604,45 @@ func (d *DLNAPlayer) setTrackChangeTimer(dur time.Duration) {
 	}()
 }
 
+// handleOnTrackChange advances to the next track when the track change timer fires.
 func (d *DLNAPlayer) handleOnTrackChange() {
-	stopping := false
+	d.eventLock.Lock()
+	after := d.advanceTrack()
+	d.eventLock.Unlock()
+	runAll(after)
+}
+
+// advanceTrack updates the player state for a transition to the next
+// track, or stopping if there is none, and returns the renderer requests
+// and callbacks to be run once eventLock is released.
+// Must be called with eventLock held.
+func (d *DLNAPlayer) advanceTrack() []func() {
 	d.metaLock.Lock()
-	if d.nextTrackMeta.ID == "" {
-		stopping = true
-	}
+	stopping := d.nextTrackMeta.ID == ""
 	d.curTrackMeta = d.nextTrackMeta
 	d.nextTrackMeta = mediaprovider.MediaItemMetadata{}
+	d.curURL = d.nextURL
+	d.nextURL = ""
 	nextTrackChange := d.curTrackMeta.Duration
+	var unsetNext *avtransport.MediaItem
+	if !stopping && d.failedToSetNext {
+		d.failedToSetNext = false
+		unsetNext = d.unsetNextMediaItem
+		d.unsetNextMediaItem = nil
+	}
 	d.metaLock.Unlock()
 
+	d.lastStartTime = 0
+	d.stopwatch.Reset()
 	if stopping {
-		d.lastStartTime = 0
-		d.stopwatch.Reset()
-		d.InvokeOnStopped()
-	} else {
-		d.metaLock.Lock()
-		if d.failedToSetNext {
-			d.failedToSetNext = false
-			media := d.unsetNextMediaItem
-			d.unsetNextMediaItem = nil
-			d.metaLock.Unlock()
-			d.playAVTransportMedia(media)
-		} else {
-			d.metaLock.Unlock()
+		d.state = stopped
+		return []func(){d.InvokeOnStopped}
+	}
+	d.stopwatch.Start()
+	return []func(){func() {
+		if unsetNext != nil {
+			d.playAVTransportMedia(unsetNext)
 		}
-
-		d.lastStartTime = 0
-		d.stopwatch.Reset()
-		d.stopwatch.Start()
 		d.setTrackChangeTimer(nextTrackChange)
 		d.InvokeOnTrackChange()
 
@@ -578,109 +652,229 @@ func (d *DLNAPlayer) handleOnTrackChange() {
 				d.syncPlaybackTime()
 			}
 		}()
-	}
+	}}
 }
 
-func (d *DLNAPlayer) urlForItem(key string) string {
-	return fmt.Sprintf("http://%s:%d/%s", d.localIP, d.proxyPort, key)
+func runAll(fns []func()) {
+	for _, fn := range fns {
+		fn()
+	}
 }
 
-func (d *DLNAPlayer) handleRequest(w http.ResponseWriter, r *http.Request) {
-	key := strings.TrimPrefix(r.URL.Path, "/")
-	url, _ := d.lookupProxyURL(key)
+// subscribeEvents subscribes to the AVTransport and RenderingControl events
+// of the renderer with the given device description URL.
+func (d *DLNAPlayer) subscribeEvents(descURL string) error {
+	u, err := url.Parse(descURL)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	urls, err := eventSubURLs(ctx, &http.Client{}, descURL)
+	if err != nil {
+		return err
+	}
+	if urls[avTransportService] == "" {
+		return errors.New("renderer has no AVTransport event URL")
+	}
 
-	if url == "" {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte("404"))
-		return
+	e, err := newEventListener(u.Hostname(), d.handleEvent, d.handleEventsLost)
+	if err != nil {
+		return err
+	}
+	if err := e.subscribe(ctx, avTransportService, urls[avTransportService]); err != nil {
+		e.close()
+		return err
+	}
+	if rcURL := urls[renderingControlService]; rcURL != "" {
+		if err := e.subscribe(ctx, renderingControlService, rcURL); err != nil {
+			logger.Warn("failed to subscribe to volume events", "err", err)
+		}
 	}
+	d.events = e
+	d.eventsActive.Store(true)
+	return nil
+}
 
-	// if the url is a filepath for a local cached file, serve it
-	if info, err := os.Stat(url); err == nil && info.Size() > 0 {
-		http.ServeFile(w, r, url)
+// handleEventsLost falls back to polling and the track change timer
+// if the event subscriptions could not be renewed.
+func (d *DLNAPlayer) handleEventsLost() {
+	d.eventLock.Lock()
+	if d.destroyed || !d.eventsActive.Swap(false) {
+		d.eventLock.Unlock()
 		return
 	}
+	go d.events.close()
+	d.events = nil
+	isPlaying, playPos := d.state == playing, d.curPlayPos()
+	d.eventLock.Unlock()
 
-	// Otherwise, proxy request to the music server
-	proxyReq, err := http.NewRequest(r.Method, url, r.Body)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	d.startPolling()
+	if isPlaying {
+		d.metaLock.Lock()
+		nextTrackChange := d.curTrackMeta.Duration - playPos
+		d.metaLock.Unlock()
+		d.setTrackChangeTimer(max(nextTrackChange, time.Millisecond))
+	}
+}
+
+func (d *DLNAPlayer) startPolling() {
+	d.eventLock.Lock()
+	defer d.eventLock.Unlock()
+	if d.stopPolling != nil {
 		return
 	}
+	stop := make(chan struct{})
+	d.stopPolling = stop
+	go func() {
+		t := time.NewTicker(pollInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+				d.pollState()
+			}
+		}
+	}()
+}
 
-	// Copy headers from the original request to the new request
-	proxyReq.Header = r.Header
+// pollState handles the renderer's transport state and volume as if they
+// were reported by events. (Track transitions are left to the track change timer.)
+func (d *DLNAPlayer) pollState() {
+	ctx, cancel := context.WithTimeout(context.Background(), pollInterval)
+	defer cancel()
+	if info, err := d.avTransport.GetTransportInfo(ctx); err == nil {
+		d.handleEvent(avTransportService, map[string]string{"TransportState": info.State})
+	}
+	if vol, err := d.renderControl.GetVolume(ctx); err == nil {
+		d.handleEvent(renderingControlService, map[string]string{"Volume": strconv.Itoa(vol)})
+	}
+}
 
-	// Create an HTTP client and send the request
-	client := &http.Client{}
-	resp, err := client.Do(proxyReq)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadGateway)
+// handleEvent updates the player for changes of the renderer's state variables.
+func (d *DLNAPlayer) handleEvent(service string, vars map[string]string) {
+	d.eventLock.Lock()
+	if d.destroyed {
+		d.eventLock.Unlock()
 		return
 	}
-	defer resp.Body.Close()
 
-	// Copy headers from the response to the writer
-	for name, values := range resp.Header {
-		for _, value := range values {
-			w.Header().Add(name, value)
+	var after []func()
+	switch service {
+	case avTransportService:
+		// handle a track transition before the state of the new track
+		if uri, ok := vars["CurrentTrackURI"]; ok {
+			after = append(after, d.handleTrackURI(uri)...)
+		}
+		if state, ok := vars["TransportState"]; ok {
+			after = append(after, d.handleTransportState(state)...)
+		}
+		if pos, ok := vars["RelativeTimePosition"]; ok && d.state != stopped {
+			if secs, err := parseClockTime(pos); err == nil {
+				d.lastStartTime = secs
+				d.stopwatch.Reset()
+				if d.state == playing {
+					d.stopwatch.Start()
+				}
+				after = append(after, d.InvokeOnSeek)
+			}
+		}
+	case renderingControlService:
+		if v, ok := vars["Volume"]; ok {
+			if vol, err := strconv.Atoi(v); err == nil {
+				if old := d.volume.Swap(int32(vol)); old >= 0 && old != int32(vol) && d.onVolumeChange != nil {
+					after = append(after, func() { d.onVolumeChange(vol) })
+				}
+			}
 		}
 	}
+	d.eventLock.Unlock()
+	runAll(after)
+}
 
-	// Set the status code
-	w.WriteHeader(resp.StatusCode)
+// must be called with eventLock held; returns the actions to run once it is released
+func (d *DLNAPlayer) handleTrackURI(uri string) []func() {
+	d.metaLock.Lock()
+	curURL, nextURL := d.curURL, d.nextURL
+	d.metaLock.Unlock()
 
-	// Copy the response body to the writer
-	_, err = io.Copy(w, resp.Body)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error copying response body:", err)
+	switch {
+	case uri == "" || uri == curURL:
+		return nil
+	case uri == nextURL:
+		// the renderer advanced to the next track on its own
+		return d.advanceTrack()
+	case d.state != stopped && !d.recentTransportCmd() && !d.proxy.Serves(uri):
+		// another control point is playing something else on the renderer
+		return d.handleExternalStop()
 	}
+	return nil
 }
 
-func (d *DLNAPlayer) addURLToProxy(url string) string {
-	hash := md5.Sum([]byte(url))
-	key := base64.StdEncoding.EncodeToString(hash[:])
-	d.proxyURLLock.Lock()
-	defer d.proxyURLLock.Unlock()
-	d._updateProxyURL(key, url)
-	return key
-}
-
-// lookupProxyURL finds a URL by key and updates its position to most recently used
-func (d *DLNAPlayer) lookupProxyURL(key string) (string, bool) {
-	d.proxyURLLock.Lock()
-	defer d.proxyURLLock.Unlock()
+// must be called with eventLock held; returns the actions to run once it is released
+func (d *DLNAPlayer) handleTransportState(state string) []func() {
+	if d.state == stopped || d.recentTransportCmd() {
+		return nil
+	}
 
-	for i := range len(d.proxyURLs) {
-		if d.proxyURLs[i].key == key {
-			url := d.proxyURLs[i].url
-			// Move accessed entry to the most recent position
-			d._updateProxyURL(key, url)
-			return url, true
+	switch state {
+	case "PLAYING":
+		if d.state == paused {
+			d.state = playing
+			d.stopwatch.Start()
+			return []func(){d.InvokeOnPlaying, func() { go d.syncPlaybackTime() }}
+		}
+	case "PAUSED_PLAYBACK":
+		if d.state == playing {
+			d.state = paused
+			d.stopwatch.Stop()
+			return []func(){func() { d.setTrackChangeTimer(0) }, d.InvokeOnPaused}
+		}
+	case "STOPPED", "NO_MEDIA_PRESENT":
+		playPos := d.curPlayPos()
+		d.metaLock.Lock()
+		nearTrackEnd := d.curTrackMeta.Duration-playPos < trackEndMargin
+		d.metaLock.Unlock()
+		if !nearTrackEnd {
+			return d.handleExternalStop()
+		} else if d.eventsActive.Load() {
+			// the renderer finished the track without advancing to a next one,
+			// either because there is none or SetNextAVTransport failed.
+			// (When polling, the track change timer handles this.)
+			return d.advanceTrack()
 		}
 	}
+	return nil
+}
 
-	return "", false
+// must be called with eventLock held; returns the actions to run once it is released
+func (d *DLNAPlayer) handleExternalStop() []func() {
+	d.stopwatch.Reset()
+	d.lastStartTime = 0
+	d.state = stopped
+	return []func(){func() { d.setTrackChangeTimer(0) }, d.InvokeOnStopped}
 }
 
-func (d *DLNAPlayer) _updateProxyURL(key, url string) {
-	// Check if the key already exists, and if so, move it to the most recently used position
-	for i := range len(d.proxyURLs) {
-		if d.proxyURLs[i].key == key {
-			if i < len(d.proxyURLs)-1 {
-				// Shift elements to the left from found position to the end
-				copy(d.proxyURLs[i:], d.proxyURLs[i+1:])
-			}
-			// Place updated entry at the last position
-			d.proxyURLs[len(d.proxyURLs)-1] = proxyMapEntry{key: key, url: url}
-			return
-		}
-	}
+// must be called with eventLock held
+func (d *DLNAPlayer) recentTransportCmd() bool {
+	return time.Since(d.lastTransportCmd) < transportCmdGracePeriod
+}
 
-	// Shift all elements left to make room for the new entry at the end
-	copy(d.proxyURLs[:], d.proxyURLs[1:])
-	// Insert new element at the most recent position
-	d.proxyURLs[len(d.proxyURLs)-1] = proxyMapEntry{key: key, url: url}
+// parses the H+:MM:SS[.F+] time format of AVTransport
+func parseClockTime(s string) (int, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	h, err1 := strconv.Atoi(parts[0])
+	m, err2 := strconv.Atoi(parts[1])
+	sec, err3 := strconv.ParseFloat(parts[2], 64)
+	if err := errors.Join(err1, err2, err3); err != nil {
+		return 0, err
+	}
+	return h*3600 + m*60 + int(sec), nil
 }
 
 // httpClientHandler wraps an http.Client to implement services.RequestHandler
@@ -695,20 +889,20 @@ func (h httpClientHandler) Do(req *http.Request) (*http.Response, error) {
 type retryLogger struct{}
 
 func (retryLogger) Error(msg string, keysAndValues ...any) {
-	log.Println(msg, keysAndValues)
+	logger.Error(msg, keysAndValues...)
 }
 
 func (retryLogger) Info(msg string, keysAndValues ...any) {
-	log.Println(msg, keysAndValues)
+	logger.Info(msg, keysAndValues...)
 }
 
 func (retryLogger) Warn(msg string, keysAndValues ...any) {
-	log.Println(msg, keysAndValues)
+	logger.Warn(msg, keysAndValues...)
 }
 
 func (retryLogger) Debug(msg string, keysAndValues ...any) {
 	// log only retries, not every request
 	if strings.Contains(msg, "retrying request") {
-		log.Println(msg, keysAndValues)
+		logger.Debug(msg, keysAndValues...)
 	}
 }
@@ -0,0 +1,375 @@
+package dlna
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dweymouth/supersonic/backend/util"
+)
+
+const (
+	avTransportService      = "AVTransport"
+	renderingControlService = "RenderingControl"
+
+	// requested subscription duration; renderers may grant a different one
+	subscriptionTimeout = 30 * time.Minute
+	// renew a subscription this long before it expires
+	renewMargin = time.Minute
+)
+
+// eventListener subscribes to UPnP (GENA) events of the renderer's services,
+// receives the event notifications on a local HTTP server,
+// and keeps the subscriptions alive until closed.
+type eventListener struct {
+	httpClient *http.Client
+	server     *http.Server
+	callback   string // base callback URL of the local server
+
+	// onEvent is invoked with the service name and the changed state variables
+	// of each notification. For LastChange events, the variables of instance 0
+	// are given, with only the Master channel of per-channel variables.
+	onEvent func(service string, vars map[string]string)
+	// onLost is invoked if a subscription expires and cannot be renewed.
+	onLost func()
+
+	lock   sync.Mutex
+	subs   map[string]*subscription // by service name
+	closed bool
+}
+
+type subscription struct {
+	eventURL string
+	sid      string
+	renew    *time.Timer
+}
+
+// eventSubURLs fetches the renderer's device description and returns
+// the event subscription URLs of its services, by service name.
+func eventSubURLs(ctx context.Context, httpClient *http.Client, descURL string) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, descURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET device description: %s", resp.Status)
+	}
+
+	var desc struct {
+		URLBase string            `xml:"URLBase"`
+		Device  deviceDescription `xml:"device"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&desc); err != nil {
+		return nil, err
+	}
+	base, _ := url.Parse(descURL)
+	if b, err := url.Parse(desc.URLBase); err == nil && desc.URLBase != "" {
+		base = b
+	}
+	urls := make(map[string]string)
+	desc.Device.collectEventSubURLs(base, urls)
+	return urls, nil
+}
+
+type deviceDescription struct {
+	ServiceList struct {
+		Services []struct {
+			Type        string `xml:"serviceType"`
+			EventSubURL string `xml:"eventSubURL"`
+		} `xml:"service"`
+	} `xml:"serviceList"`
+	DeviceList struct {
+		Devices []deviceDescription `xml:"device"`
+	} `xml:"deviceList"`
+}
+
+func (d *deviceDescription) collectEventSubURLs(base *url.URL, urls map[string]string) {
+	for _, s := range d.ServiceList.Services {
+		// e.g. urn:schemas-upnp-org:service:AVTransport:1
+		parts := strings.Split(s.Type, ":")
+		if len(parts) < 2 || s.EventSubURL == "" {
+			continue
+		}
+		name := parts[len(parts)-2]
+		if _, ok := urls[name]; ok {
+			continue
+		}
+		if u, err := url.Parse(s.EventSubURL); err == nil {
+			urls[name] = base.ResolveReference(u).String()
+		}
+	}
+	for i := range d.DeviceList.Devices {
+		d.DeviceList.Devices[i].collectEventSubURLs(base, urls)
+	}
+}
+
+// newEventListener starts the local callback server on the
+// interface from which the renderer at rendererHost is reachable.
+func newEventListener(rendererHost string, onEvent func(string, map[string]string), onLost func()) (*eventListener, error) {
+	ip, err := localIPFor(rendererHost)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(ip, "0"))
+	if err != nil {
+		return nil, err
+	}
+	e := &eventListener{
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		callback:   "http://" + listener.Addr().String() + "/",
+		onEvent:    onEvent,
+		onLost:     onLost,
+		subs:       make(map[string]*subscription),
+	}
+	e.server = &http.Server{Handler: http.HandlerFunc(e.handleNotify)}
+	go e.server.Serve(listener)
+	return e, nil
+}
+
+// localIPFor returns the local IP address used to reach the host,
+// falling back to the first non-loopback address.
+func localIPFor(host string) (string, error) {
+	if conn, err := net.Dial("udp", net.JoinHostPort(host, "1900")); err == nil {
+		defer conn.Close()
+		return conn.LocalAddr().(*net.UDPAddr).IP.String(), nil
+	}
+	return util.GetLocalIP()
+}
+
+// subscribe subscribes to the events of the service. The renderer sends
+// the current value of all evented variables right after subscribing.
+func (e *eventListener) subscribe(ctx context.Context, service, eventURL string) error {
+	sid, timeout, err := e.sendSubscribe(ctx, eventURL, "", service)
+	if err != nil {
+		return err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.closed {
+		return errors.New("event listener closed")
+	}
+	sub := &subscription{eventURL: eventURL, sid: sid}
+	sub.renew = time.AfterFunc(renewAfter(timeout), func() { e.renew(service) })
+	e.subs[service] = sub
+	return nil
+}
+
+// sendSubscribe sends a new subscription request if sid is empty,
+// or a renewal of the existing subscription otherwise.
+func (e *eventListener) sendSubscribe(ctx context.Context, eventURL, sid, service string) (string, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, "SUBSCRIBE", eventURL, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	if sid == "" {
+		req.Header.Set("CALLBACK", "<"+e.callback+service+">")
+		req.Header.Set("NT", "upnp:event")
+	} else {
+		req.Header.Set("SID", sid)
+	}
+	req.Header.Set("TIMEOUT", fmt.Sprintf("Second-%d", int(subscriptionTimeout.Seconds())))
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("SUBSCRIBE %s: %s", service, resp.Status)
+	}
+	if sid = resp.Header.Get("SID"); sid == "" {
+		return "", 0, fmt.Errorf("SUBSCRIBE %s: no SID in response", service)
+	}
+	return sid, parseTimeout(resp.Header.Get("TIMEOUT")), nil
+}
+
+func (e *eventListener) renew(service string) {
+	e.lock.Lock()
+	sub, ok := e.subs[service]
+	if !ok || e.closed {
+		e.lock.Unlock()
+		return
+	}
+	sid := sub.sid
+	e.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, timeout, err := e.sendSubscribe(ctx, sub.eventURL, sid, service)
+	if err != nil {
+		// the renderer may have restarted and forgotten the subscription
+		logger.Warn("failed to renew subscription, resubscribing", "service", service, "err", err)
+		sid, timeout, err = e.sendSubscribe(ctx, sub.eventURL, "", service)
+	}
+	if err != nil {
+		logger.Error("lost event subscription", "service", service, "err", err)
+		e.lock.Lock()
+		delete(e.subs, service)
+		e.lock.Unlock()
+		if e.onLost != nil {
+			e.onLost()
+		}
+		return
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if !e.closed {
+		sub.sid = sid
+		sub.renew = time.AfterFunc(renewAfter(timeout), func() { e.renew(service) })
+	}
+}
+
+// close unsubscribes from all events and stops the callback server.
+func (e *eventListener) close() {
+	e.lock.Lock()
+	if e.closed {
+		e.lock.Unlock()
+		return
+	}
+	e.closed = true
+	subs := e.subs
+	e.subs = nil
+	e.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for _, sub := range subs {
+		sub.renew.Stop()
+		req, err := http.NewRequestWithContext(ctx, "UNSUBSCRIBE", sub.eventURL, nil)
+		if err != nil {
+			continue
+		}
+		req.Header.Set("SID", sub.sid)
+		if resp, err := e.httpClient.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}
+	e.server.Shutdown(ctx)
+}
+
+func (e *eventListener) handleNotify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "NOTIFY" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if r.Header.Get("NT") != "upnp:event" || r.Header.Get("NTS") != "upnp:propchange" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	// (the initial event may arrive before the SUBSCRIBE response,
+	// so the subscription is not looked up by SID)
+	service := strings.TrimPrefix(r.URL.Path, "/")
+	e.lock.Lock()
+	closed := e.closed
+	e.lock.Unlock()
+	if closed || (service != avTransportService && service != renderingControlService) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	vars, err := parsePropertySet(body)
+	if err != nil {
+		logger.Warn("invalid event", "service", service, "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	if len(vars) > 0 && e.onEvent != nil {
+		e.onEvent(service, vars)
+	}
+}
+
+type propertySet struct {
+	Properties []struct {
+		Vars []struct {
+			XMLName xml.Name
+			Value   string `xml:",chardata"`
+			Inner   string `xml:",innerxml"`
+		} `xml:",any"`
+	} `xml:"property"`
+}
+
+type lastChangeEvent struct {
+	Instances []struct {
+		ID   string `xml:"val,attr"`
+		Vars []struct {
+			XMLName xml.Name
+			Val     string `xml:"val,attr"`
+			Channel string `xml:"channel,attr"`
+		} `xml:",any"`
+	} `xml:"InstanceID"`
+}
+
+// parsePropertySet returns the state variables of an event notification,
+// expanding the LastChange variable into the variables it contains.
+func parsePropertySet(body []byte) (map[string]string, error) {
+	var ps propertySet
+	if err := xml.Unmarshal(body, &ps); err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string)
+	for _, p := range ps.Properties {
+		for _, v := range p.Vars {
+			if v.XMLName.Local != "LastChange" {
+				vars[v.XMLName.Local] = strings.TrimSpace(v.Value)
+				continue
+			}
+			lastChange := strings.TrimSpace(v.Value)
+			if lastChange == "" {
+				// some renderers embed the event unescaped
+				lastChange = v.Inner
+			}
+			var ev lastChangeEvent
+			if err := xml.Unmarshal([]byte(lastChange), &ev); err != nil {
+				return nil, fmt.Errorf("invalid LastChange: %w", err)
+			}
+			for _, inst := range ev.Instances {
+				if inst.ID != "0" {
+					continue
+				}
+				for _, iv := range inst.Vars {
+					if iv.Channel != "" && iv.Channel != "Master" {
+						continue
+					}
+					vars[iv.XMLName.Local] = iv.Val
+				}
+			}
+		}
+	}
+	return vars, nil
+}
+
+// parses a TIMEOUT header of the form Second-N or Second-infinite
+func parseTimeout(s string) time.Duration {
+	secs, err := strconv.Atoi(strings.TrimPrefix(s, "Second-"))
+	if err != nil || secs <= 0 {
+		return subscriptionTimeout
+	}
+	return time.Duration(secs) * time.Second
+}
+
+func renewAfter(timeout time.Duration) time.Duration {
+	if timeout > 2*renewMargin {
+		return timeout - renewMargin
+	}
+	return timeout / 2
+}
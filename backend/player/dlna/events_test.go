@@ -0,0 +1,141 @@
+package dlna
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+	"github.com/dweymouth/supersonic/backend/player"
+)
+
+const testAVTransportEvent = `<?xml version="1.0"?>
+<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0"><e:property><LastChange>` +
+	`&lt;Event xmlns=&quot;urn:schemas-upnp-org:metadata-1-0/AVT/&quot;&gt;&lt;InstanceID val=&quot;0&quot;&gt;` +
+	`&lt;TransportState val=&quot;PLAYING&quot;/&gt;&lt;CurrentTrackURI val=&quot;http://host/next&quot;/&gt;` +
+	`&lt;/InstanceID&gt;&lt;/Event&gt;</LastChange></e:property></e:propertyset>`
+
+const testRenderingControlEvent = `<?xml version="1.0"?>
+<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0"><e:property><LastChange>` +
+	`&lt;Event xmlns=&quot;urn:schemas-upnp-org:metadata-1-0/RCS/&quot;&gt;&lt;InstanceID val=&quot;0&quot;&gt;` +
+	`&lt;Volume channel=&quot;LF&quot; val=&quot;10&quot;/&gt;&lt;Volume channel=&quot;Master&quot; val=&quot;42&quot;/&gt;` +
+	`&lt;/InstanceID&gt;&lt;/Event&gt;</LastChange></e:property></e:propertyset>`
+
+func TestParsePropertySet(t *testing.T) {
+	vars, err := parsePropertySet([]byte(testAVTransportEvent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars["TransportState"] != "PLAYING" || vars["CurrentTrackURI"] != "http://host/next" {
+		t.Errorf("unexpected AVTransport vars %v", vars)
+	}
+
+	vars, err = parsePropertySet([]byte(testRenderingControlEvent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars["Volume"] != "42" {
+		t.Errorf("expected Master volume 42, got %v", vars)
+	}
+}
+
+func TestSubscribeAndNotify(t *testing.T) {
+	events := make(chan map[string]string, 1)
+	e, err := newEventListener("127.0.0.1", func(service string, vars map[string]string) {
+		if service == avTransportService {
+			events <- vars
+		}
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.close()
+
+	// fake renderer sends the initial event to the callback after subscribing
+	var unsubscribed bool
+	renderer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "SUBSCRIBE":
+			callback := strings.Trim(r.Header.Get("CALLBACK"), "<>")
+			w.Header().Set("SID", "uuid:1")
+			w.Header().Set("TIMEOUT", "Second-1800")
+			w.WriteHeader(http.StatusOK)
+			go func() {
+				req, _ := http.NewRequest("NOTIFY", callback, strings.NewReader(testAVTransportEvent))
+				req.Header.Set("NT", "upnp:event")
+				req.Header.Set("NTS", "upnp:propchange")
+				req.Header.Set("SID", "uuid:1")
+				if resp, err := http.DefaultClient.Do(req); err == nil {
+					resp.Body.Close()
+				}
+			}()
+		case "UNSUBSCRIBE":
+			unsubscribed = r.Header.Get("SID") == "uuid:1"
+		}
+	}))
+	defer renderer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := e.subscribe(ctx, avTransportService, renderer.URL+"/evt"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case vars := <-events:
+		if vars["TransportState"] != "PLAYING" {
+			t.Errorf("unexpected event vars %v", vars)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for event")
+	}
+
+	e.close()
+	if !unsubscribed {
+		t.Error("expected subscription to be cancelled on close")
+	}
+}
+
+func TestHandleEvent(t *testing.T) {
+	d := &DLNAPlayer{resetChan: make(chan time.Duration)}
+	d.volume.Store(-1)
+	d.eventsActive.Store(true)
+	defer d.Destroy()
+
+	d.state = playing
+	d.curTrackMeta = mediaprovider.MediaItemMetadata{ID: "1", Duration: time.Minute}
+	d.curURL = "http://host/cur"
+	d.nextTrackMeta = mediaprovider.MediaItemMetadata{ID: "2", Duration: time.Minute}
+	d.nextURL = "http://host/next"
+
+	trackChanged := false
+	d.OnTrackChange(func() { trackChanged = true })
+	gotPaused := false
+	d.OnPaused(func() {
+		// callbacks run after the event lock is released, so may use the player
+		gotPaused = d.GetStatus().State == player.Paused
+	})
+	var volume int
+	d.OnVolumeChange(func(vol int) { volume = vol })
+
+	d.handleEvent(avTransportService, map[string]string{"CurrentTrackURI": "http://host/next"})
+	if !trackChanged || d.curTrackMeta.ID != "2" || d.curURL != "http://host/next" {
+		t.Error("expected renderer to drive track change")
+	}
+
+	d.handleEvent(avTransportService, map[string]string{"TransportState": "PAUSED_PLAYBACK"})
+	if !gotPaused || d.state != paused {
+		t.Error("expected pause on the renderer to be reflected")
+	}
+
+	d.handleEvent(renderingControlService, map[string]string{"Volume": "30"})
+	if volume != 0 {
+		t.Error("initial volume should not be reported as a change")
+	}
+	d.handleEvent(renderingControlService, map[string]string{"Volume": "55"})
+	if volume != 55 {
+		t.Errorf("expected volume change to 55, got %d", volume)
+	}
+}
@@ -1,154 +1,657 @@
 package jukebox
 
 import (
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/dweymouth/supersonic/backend/logging"
 	"github.com/dweymouth/supersonic/backend/mediaprovider"
 	"github.com/dweymouth/supersonic/backend/player"
+	"github.com/dweymouth/supersonic/sharedutil"
 )
 
+var logger = logging.For(logging.Player)
+
 const (
 	stopped = 0
 	playing = 1
 	paused  = 2
 )
 
+const (
+	// how often the jukebox is polled for changes made by other clients
+	pollInterval = 2 * time.Second
+	// a jukebox that stops this close to the end of the track has finished it
+	trackEndMargin = 2 * pollInterval
+	// position changes larger than this between polls are reported as seeks
+	seekTolerance = 3 * time.Second
+)
+
+var (
+	_ player.QueuePlayer       = (*JukeboxPlayer)(nil)
+	_ player.VolumeEventPlayer = (*JukeboxPlayer)(nil)
+)
+
+// JukeboxPlayer plays on the server's own audio output through the
+// Subsonic jukebox API. The whole play queue is mirrored to the jukebox's
+// playlist, and the jukebox is polled to pick up track changes, pauses,
+// seeks, volume and queue changes made by other clients.
 type JukeboxPlayer struct {
 	player.BasePlayerCallbackImpl
 
 	provider mediaprovider.JukeboxProvider
 
+	// held while making requests that change the jukebox, and while polling,
+	// so that a poll never sees our own changes half applied
+	reqLock sync.Mutex
+	// guards the fields below, and is never held during a request so that
+	// status reads don't wait on the network. The fields other than nextIdx
+	// and the callbacks are only changed holding both locks, so may be read
+	// holding either. Callbacks are invoked after releasing both.
+	lock sync.Mutex
+
 	state   int // stopped, playing, paused
 	volume  int
-	seeking bool
+	seeking atomic.Bool
+
+	queue   []*mediaprovider.Track // mirror of the jukebox playlist
+	curIdx  int                    // the jukebox's current index
+	nextIdx int                    // index to play once the current track ends, or -1
+
+	// set if the current track must be skipped to before resuming,
+	// since the jukebox playlist was replaced while paused
+	pendingSkip bool
+
+	// position in the current track as of posTime
+	position float64
+	posTime  time.Time
 
-	curTrack           int
-	queueLength        int
-	curTrackDuration   float64
-	startTrackTime     float64
-	startedAtUnixMilli int64
+	onVolumeChange     func(int)
+	onQueueIndexChange func(int)
+	onQueueChange      func([]*mediaprovider.Track, int)
+
+	stopPolling chan struct{}
+	destroyOnce sync.Once
+}
+
+// NewJukeboxPlayer connects to the server's jukebox and starts polling it.
+// The jukebox's playlist is replaced once the player is used to play.
+func NewJukeboxPlayer(provider mediaprovider.JukeboxProvider) (*JukeboxPlayer, error) {
+	q, err := provider.JukeboxGetQueue()
+	if err != nil {
+		return nil, fmt.Errorf("jukebox unavailable: %w", err)
+	}
+	j := &JukeboxPlayer{
+		provider:    provider,
+		volume:      q.Volume,
+		queue:       q.Tracks,
+		curIdx:      q.CurrentTrack,
+		nextIdx:     -1,
+		stopPolling: make(chan struct{}),
+	}
+	go j.pollLoop()
+	return j, nil
 }
 
 func (j *JukeboxPlayer) SetVolume(vol int) error {
+	j.reqLock.Lock()
+	defer j.reqLock.Unlock()
 	if err := j.provider.JukeboxSetVolume(vol); err != nil {
 		return err
 	}
+	j.lock.Lock()
 	j.volume = vol
+	j.lock.Unlock()
 	return nil
 }
 
 func (j *JukeboxPlayer) GetVolume() int {
+	j.lock.Lock()
+	defer j.lock.Unlock()
 	return j.volume
 }
 
+// OnVolumeChange sets a callback invoked when another client changes the jukebox volume.
+func (j *JukeboxPlayer) OnVolumeChange(cb func(int)) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.onVolumeChange = cb
+}
+
+func (j *JukeboxPlayer) OnQueueIndexChange(cb func(int)) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.onQueueIndexChange = cb
+}
+
+func (j *JukeboxPlayer) OnQueueChange(cb func([]*mediaprovider.Track, int)) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.onQueueChange = cb
+}
+
 func (j *JukeboxPlayer) Continue() error {
-	if j.state == playing {
+	j.reqLock.Lock()
+	if j.state == playing || j.curIdx < 0 {
+		j.reqLock.Unlock()
 		return nil
 	}
-	if err := j.startAndUpdateTime(); err != nil {
+	if j.pendingSkip {
+		if err := j.provider.JukeboxSeek(j.curIdx, int(j.position)); err != nil {
+			j.reqLock.Unlock()
+			return err
+		}
+	}
+	if err := j.provider.JukeboxStart(); err != nil {
+		j.reqLock.Unlock()
 		return err
 	}
-
+	j.lock.Lock()
+	j.pendingSkip = false
 	j.state = playing
+	j.posTime = time.Now()
+	j.lock.Unlock()
+	j.reqLock.Unlock()
+
 	j.InvokeOnPlaying()
 	return nil
 }
 
 func (j *JukeboxPlayer) Pause() error {
+	j.reqLock.Lock()
 	if j.state != playing {
+		j.reqLock.Unlock()
 		return nil
 	}
 	if err := j.provider.JukeboxStop(); err != nil {
+		j.reqLock.Unlock()
 		return err
 	}
-	// TODO: calculate paused at time
+	j.lock.Lock()
+	j.position = j.curPos()
 	j.state = paused
+	j.lock.Unlock()
+	j.reqLock.Unlock()
+
 	j.InvokeOnPaused()
 	return nil
 }
 
 func (j *JukeboxPlayer) Stop(_ bool) error {
+	j.reqLock.Lock()
 	if j.state == stopped {
+		j.reqLock.Unlock()
 		return nil
 	}
 	if err := j.provider.JukeboxStop(); err != nil {
+		j.reqLock.Unlock()
 		return err
 	}
+	j.lock.Lock()
 	j.state = stopped
+	j.position = 0
+	j.lock.Unlock()
+	j.reqLock.Unlock()
+
 	j.InvokeOnStopped()
 	return nil
 }
 
-func (j *JukeboxPlayer) PlayTrack(track *mediaprovider.Track, _ float64) error {
-	if err := j.provider.JukeboxSet(track.ID); err != nil {
-		return err
+// SetQueue edits the jukebox playlist into tracks, on a copy of the
+// queue which is stored once the requests are done (or have failed).
+func (j *JukeboxPlayer) SetQueue(tracks []*mediaprovider.Track, nowPlayingIdx int) error {
+	j.reqLock.Lock()
+	defer j.reqLock.Unlock()
+
+	oldIDs := trackIDs(j.queue)
+	newIDs := trackIDs(tracks)
+	oldCur := j.curIdx
+	if j.state == stopped {
+		oldCur = -1 // nothing to keep playing
 	}
-	j.startTrackTime = 0
-	if err := j.startAndUpdateTime(); err != nil {
-		return err
+	newCur := matchCurrent(oldIDs, oldCur, newIDs, nowPlayingIdx)
+	remove, addFrom, ok := planQueueEdit(oldIDs, oldCur, newIDs, newCur)
+	if !ok {
+		return j.resetQueue(tracks, newCur)
 	}
-
-	j.curTrack = 0
-	j.queueLength = 1
-	j.curTrackDuration = track.Duration.Seconds()
-
+	queue, curIdx := slices.Clone(j.queue), j.curIdx
+	for _, idx := range remove {
+		if err := j.provider.JukeboxRemove(idx); err != nil {
+			return j.resync(err)
+		}
+		queue = slices.Delete(queue, idx, idx+1)
+		if idx < curIdx {
+			curIdx--
+		}
+	}
+	for _, t := range tracks[addFrom:] {
+		if err := j.provider.JukeboxAdd(t.ID); err != nil {
+			return j.resync(err)
+		}
+		queue = append(queue, t)
+	}
+	if oldCur < 0 {
+		curIdx = newCur
+	}
+	j.lock.Lock()
+	j.setQueue(queue, curIdx)
+	j.lock.Unlock()
 	return nil
 }
 
-func (j *JukeboxPlayer) SetNextTrack(track *mediaprovider.Track) error {
-	// we need to replace the last track in the queue, remove it first
-	if j.curTrack < j.queueLength-1 {
-		if err := j.provider.JukeboxRemove(j.curTrack + 1); err != nil {
-			return err
+// resetQueue replaces the whole jukebox playlist, which interrupts
+// playback; the current track is resumed where it was, if it is kept.
+// Must be called with reqLock held.
+func (j *JukeboxPlayer) resetQueue(tracks []*mediaprovider.Track, newCur int) error {
+	j.lock.Lock()
+	pos := j.curPos()
+	j.lock.Unlock()
+	if err := j.provider.JukeboxClear(); err != nil {
+		return j.resync(err)
+	}
+	for i, t := range tracks {
+		if err := j.provider.JukeboxAdd(t.ID); err != nil {
+			j.lock.Lock()
+			j.setQueue(slices.Clone(tracks[:i]), -1)
+			j.lock.Unlock()
+			return j.resync(err)
+		}
+	}
+	var err error
+	if newCur >= 0 && j.state == playing {
+		err = j.provider.JukeboxSeek(newCur, int(pos))
+		if err == nil {
+			err = j.provider.JukeboxStart()
 		}
-		j.queueLength -= 1
 	}
-	// append the new track to the queue
-	if err := j.provider.JukeboxAdd(track.ID); err != nil {
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.setQueue(slices.Clone(tracks), newCur)
+	if newCur < 0 {
+		// the caller plays another track next
+		j.state = stopped
+		return nil
+	}
+	switch j.state {
+	case playing:
+		if err == nil {
+			j.position, j.posTime = pos, time.Now()
+		}
+	case paused:
+		j.pendingSkip = true
+		j.position = pos
+	}
+	return err
+}
+
+// resync adopts the jukebox's actual playlist after a failed change,
+// and returns err. Must be called with reqLock held.
+func (j *JukeboxPlayer) resync(err error) error {
+	if q, e := j.provider.JukeboxGetQueue(); e == nil {
+		j.lock.Lock()
+		j.setQueue(q.Tracks, q.CurrentTrack)
+		j.lock.Unlock()
+	}
+	return err
+}
+
+// must be called with both locks held
+func (j *JukeboxPlayer) setQueue(queue []*mediaprovider.Track, curIdx int) {
+	j.queue = queue
+	j.curIdx = curIdx
+	j.clampNextIdx()
+}
+
+// must be called with lock held
+func (j *JukeboxPlayer) clampNextIdx() {
+	if j.nextIdx >= len(j.queue) {
+		j.nextIdx = -1
+	}
+}
+
+func (j *JukeboxPlayer) PlayTrackAt(idx int, startTime float64) error {
+	j.reqLock.Lock()
+	if idx < 0 || idx >= len(j.queue) {
+		j.reqLock.Unlock()
+		return fmt.Errorf("jukebox: track index (%d) out of range (0-%d)", idx, len(j.queue))
+	}
+	if err := j.provider.JukeboxSeek(idx, int(startTime)); err != nil {
+		j.reqLock.Unlock()
 		return err
 	}
-	j.queueLength += 1
+	if err := j.provider.JukeboxStart(); err != nil {
+		j.reqLock.Unlock()
+		return err
+	}
+	j.lock.Lock()
+	j.curIdx = idx
+	j.nextIdx = j.following(idx)
+	j.pendingSkip = false
+	j.state = playing
+	j.position, j.posTime = startTime, time.Now()
+	j.lock.Unlock()
+	j.reqLock.Unlock()
+
+	j.InvokeOnPlaying()
+	j.InvokeOnTrackChange()
+	if startTime > 0 {
+		j.InvokeOnSeek()
+	}
+	return nil
+}
+
+func (j *JukeboxPlayer) SetNextTrackAt(idx int) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if idx >= len(j.queue) {
+		return fmt.Errorf("jukebox: track index (%d) out of range (0-%d)", idx, len(j.queue))
+	}
+	// the jukebox advances to the following track by itself;
+	// any other is skipped to by the poller when the track ends
+	j.nextIdx = idx
 	return nil
 }
 
 func (j *JukeboxPlayer) SeekSeconds(secs float64) error {
-	j.seeking = true
-	err := j.provider.JukeboxSeek(j.curTrack, int(secs))
-	j.seeking = false
+	j.seeking.Store(true)
+	j.reqLock.Lock()
+	err := j.provider.JukeboxSeek(j.curIdx, int(secs))
+	if err == nil {
+		j.lock.Lock()
+		j.position, j.posTime = secs, time.Now()
+		j.lock.Unlock()
+	}
+	j.reqLock.Unlock()
+	j.seeking.Store(false)
+	if err != nil {
+		return err
+	}
 	j.InvokeOnSeek()
-	return err
+	return nil
 }
 
 func (j *JukeboxPlayer) IsSeeking() bool {
-	return j.seeking
+	return j.seeking.Load()
 }
 
 func (j *JukeboxPlayer) GetStatus() player.Status {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
 	state := player.Stopped
 	if j.state == playing {
 		state = player.Playing
 	} else if j.state == paused {
 		state = player.Paused
 	}
+	var duration float64
+	if j.curIdx >= 0 && j.curIdx < len(j.queue) {
+		duration = j.queue[j.curIdx].Duration.Seconds()
+	}
+	return player.Status{
+		State:    state,
+		TimePos:  j.curPos(),
+		Duration: duration,
+	}
+}
 
-	// TODO - the rest
+func (j *JukeboxPlayer) Destroy() {
+	j.destroyOnce.Do(func() { close(j.stopPolling) })
+}
 
-	return player.Status{
-		State: state,
+// must be called with lock held
+func (j *JukeboxPlayer) curPos() float64 {
+	if j.state != playing {
+		return j.position
 	}
+	return j.position + time.Since(j.posTime).Seconds()
 }
 
-func (j *JukeboxPlayer) Destroy() {}
+// returns the index following idx in the queue, or -1 if none
+// must be called with lock held
+func (j *JukeboxPlayer) following(idx int) int {
+	if idx+1 < len(j.queue) {
+		return idx + 1
+	}
+	return -1
+}
 
-func (j *JukeboxPlayer) startAndUpdateTime() error {
-	beforeStart := time.Now()
-	if err := j.provider.JukeboxStart(); err != nil {
-		return err
+func (j *JukeboxPlayer) pollLoop() {
+	t := time.NewTicker(pollInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-j.stopPolling:
+			return
+		case <-t.C:
+			j.poll()
+		}
+	}
+}
+
+// poll compares the jukebox's state to what we last set, and applies
+// and reports changes made by other clients, as well as the jukebox
+// advancing through its playlist.
+func (j *JukeboxPlayer) poll() {
+	j.reqLock.Lock()
+	q, err := j.provider.JukeboxGetQueue()
+	if err != nil {
+		j.reqLock.Unlock()
+		logger.Error("failed to get jukebox status", "err", err)
+		return
+	}
+	j.lock.Lock()
+	var events []func()
+	if q.Volume != j.volume {
+		j.volume = q.Volume
+		if cb := j.onVolumeChange; cb != nil {
+			events = append(events, func() { cb(q.Volume) })
+		}
+	}
+	statusEvents, finished := j.handleStatus(q)
+	events = append(events, statusEvents...)
+	j.lock.Unlock()
+	if finished {
+		events = append(events, j.finishTrack()...)
+	}
+	j.reqLock.Unlock()
+
+	for _, ev := range events {
+		ev()
+	}
+}
+
+// handleStatus updates the player for the polled jukebox state and returns
+// the callbacks to invoke once the locks are released, and whether the
+// jukebox has finished the current track, to be handled by finishTrack.
+// Must be called with both locks held.
+func (j *JukeboxPlayer) handleStatus(q *mediaprovider.JukeboxQueue) ([]func(), bool) {
+	if !slices.Equal(trackIDs(q.Tracks), trackIDs(j.queue)) {
+		// another client changed the playlist; adopt it as our queue
+		j.queue = q.Tracks
+		j.curIdx = q.CurrentTrack
+		j.nextIdx = j.following(q.CurrentTrack)
+		j.pendingSkip = false
+		j.position, j.posTime = q.PositionSeconds, time.Now()
+		events := j.setStateFromJukebox(q.Playing)
+		if cb := j.onQueueChange; cb != nil {
+			tracks, idx := slices.Clone(q.Tracks), q.CurrentTrack
+			events = append([]func(){func() { cb(tracks, idx) }}, events...)
+		}
+		return events, false
+	}
+	if j.state == stopped || j.pendingSkip {
+		// not playing, or the jukebox doesn't yet reflect our current track
+		return nil, false
+	}
+
+	if q.CurrentTrack != j.curIdx {
+		switch {
+		case q.CurrentTrack < 0 || q.CurrentTrack >= len(j.queue):
+			return nil, true
+		case q.CurrentTrack == j.curIdx+1 && j.nextIdx == q.CurrentTrack:
+			// advanced through the playlist as expected
+			j.curIdx = q.CurrentTrack
+			j.nextIdx = j.following(q.CurrentTrack)
+			j.position, j.posTime = q.PositionSeconds, time.Now()
+			return append([]func(){j.InvokeOnTrackChange}, j.setStateFromJukebox(q.Playing)...), false
+		case q.CurrentTrack == j.curIdx+1:
+			// advanced, but another track should follow (e.g. looping)
+			return nil, true
+		default:
+			// another client played a different track
+			j.curIdx = q.CurrentTrack
+			j.nextIdx = j.following(q.CurrentTrack)
+			j.position, j.posTime = q.PositionSeconds, time.Now()
+			events := j.setStateFromJukebox(q.Playing)
+			if cb := j.onQueueIndexChange; cb != nil {
+				idx := q.CurrentTrack
+				events = append([]func(){func() { cb(idx) }}, events...)
+			}
+			return events, false
+		}
 	}
-	afterStart := time.Now()
 
-	// assume track started playing at (ie has been playing for)
-	// half the round-trip latency
-	j.startedAtUnixMilli = time.Now().Add(-afterStart.Sub(beforeStart)).UnixMilli()
+	if !q.Playing && j.state == playing {
+		if j.queue[j.curIdx].Duration.Seconds()-j.curPos() < trackEndMargin.Seconds() {
+			return nil, true
+		}
+		j.position = q.PositionSeconds
+		j.state = paused
+		return []func(){j.InvokeOnPaused}, false
+	}
+	var events []func()
+	if q.Playing && j.state == paused {
+		j.state = playing
+		events = append(events, j.InvokeOnPlaying)
+	}
+	if j.state == playing {
+		drift := math.Abs(j.curPos() - q.PositionSeconds)
+		j.position, j.posTime = q.PositionSeconds, time.Now()
+		if drift > seekTolerance.Seconds() {
+			events = append(events, j.InvokeOnSeek)
+		}
+	}
+	return events, false
+}
+
+// setStateFromJukebox adopts the playing state of the jukebox after
+// another client's change, returning the callbacks to invoke.
+// Must be called with both locks held.
+func (j *JukeboxPlayer) setStateFromJukebox(jukeboxPlaying bool) []func() {
+	switch {
+	case jukeboxPlaying && j.state != playing:
+		j.state = playing
+		return []func(){j.InvokeOnPlaying}
+	case !jukeboxPlaying && j.state == playing:
+		j.state = paused
+		j.position = j.curPos()
+		return []func(){j.InvokeOnPaused}
+	}
 	return nil
 }
+
+// finishTrack moves on from a track the jukebox has finished playing,
+// to the next track if any. Must be called with reqLock held.
+func (j *JukeboxPlayer) finishTrack() []func() {
+	j.lock.Lock()
+	next := j.nextIdx
+	j.lock.Unlock()
+	if next >= 0 {
+		err := j.provider.JukeboxSeek(next, 0)
+		if err == nil {
+			err = j.provider.JukeboxStart()
+		}
+		if err == nil {
+			j.lock.Lock()
+			j.curIdx = next
+			j.nextIdx = j.following(next)
+			j.state = playing
+			j.position, j.posTime = 0, time.Now()
+			j.lock.Unlock()
+			return []func(){j.InvokeOnTrackChange}
+		}
+		logger.Error("failed to play next jukebox track", "err", err)
+	}
+	j.provider.JukeboxStop()
+	j.lock.Lock()
+	j.state = stopped
+	j.position = 0
+	j.lock.Unlock()
+	return []func(){j.InvokeOnStopped}
+}
+
+func trackIDs(tracks []*mediaprovider.Track) []string {
+	return sharedutil.MapSlice(tracks, func(t *mediaprovider.Track) string { return t.ID })
+}
+
+// matchCurrent returns the index in the new queue of the jukebox's current
+// track, given the index the caller believes is playing. The jukebox may
+// have advanced to the following track before the caller was told.
+func matchCurrent(old []string, oldCur int, new []string, nowPlayingIdx int) int {
+	if oldCur < 0 {
+		return nowPlayingIdx
+	}
+	for _, idx := range []int{nowPlayingIdx, nowPlayingIdx + 1} {
+		if idx >= 0 && idx < len(new) && new[idx] == old[oldCur] {
+			return idx
+		}
+	}
+	return -1
+}
+
+// planQueueEdit plans how to turn the old jukebox playlist into the new one
+// using only the jukebox's remove and add (append) actions, without removing
+// the current track at oldCur (if >= 0), which must be at newCur in the new
+// playlist. It returns the indexes to remove, in the order to remove them,
+// and the index of the new playlist from which tracks are to be appended.
+// ok is false if the edit isn't possible, e.g. if tracks before the current
+// one have been reordered.
+func planQueueEdit(old []string, oldCur int, new []string, newCur int) (remove []int, addFrom int, ok bool) {
+	if oldCur < 0 {
+		k := commonPrefixLen(old, new)
+		for idx := len(old) - 1; idx >= k; idx-- {
+			remove = append(remove, idx)
+		}
+		return remove, k, true
+	}
+	if newCur < 0 || newCur >= len(new) || old[oldCur] != new[newCur] {
+		return nil, 0, false
+	}
+
+	// tracks before the current one can only be removed,
+	// so the new ones must be a subsequence of the old
+	var removeBefore []int
+	matched := 0
+	for idx := 0; idx < oldCur; idx++ {
+		if matched < newCur && old[idx] == new[matched] {
+			matched++
+		} else {
+			removeBefore = append(removeBefore, idx)
+		}
+	}
+	if matched < newCur {
+		return nil, 0, false
+	}
+
+	// keep what's unchanged after the current track, and replace the rest
+	k := commonPrefixLen(old[oldCur+1:], new[newCur+1:])
+	for idx := len(old) - 1; idx > oldCur+k; idx-- {
+		remove = append(remove, idx)
+	}
+	slices.Reverse(removeBefore)
+	return append(remove, removeBefore...), newCur + 1 + k, true
+}
+
+func commonPrefixLen(a, b []string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
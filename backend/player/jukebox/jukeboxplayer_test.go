@@ -0,0 +1,255 @@
+package jukebox
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+	"github.com/dweymouth/supersonic/backend/player"
+)
+
+func TestPlanQueueEdit(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		old    string
+		oldCur int
+		new    string
+		newCur int
+		ok     bool
+		ops    int // removes plus adds
+	}{
+		{name: "unchanged", old: "abcd", oldCur: 1, new: "abcd", newCur: 1, ok: true, ops: 0},
+		{name: "append", old: "abc", oldCur: 0, new: "abcde", newCur: 0, ok: true, ops: 2},
+		{name: "remove before current", old: "abcd", oldCur: 2, new: "bcd", newCur: 1, ok: true, ops: 1},
+		{name: "remove after current", old: "abcd", oldCur: 0, new: "abd", newCur: 0, ok: true, ops: 3},
+		{name: "reorder after current", old: "abcde", oldCur: 1, new: "abedc", newCur: 1, ok: true, ops: 6},
+		{name: "shuffle, current first", old: "abcde", oldCur: 2, new: "cadeb", newCur: 0, ok: true, ops: 8},
+		{name: "stopped", old: "abcd", oldCur: -1, new: "abxy", newCur: -1, ok: true, ops: 4},
+		{name: "reorder before current", old: "abcd", oldCur: 2, new: "bacd", newCur: 2, ok: false},
+		{name: "current missing", old: "abcd", oldCur: 2, new: "abd", newCur: -1, ok: false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			old, new := strings.Split(tt.old, ""), strings.Split(tt.new, "")
+			remove, addFrom, ok := planQueueEdit(old, tt.oldCur, new, tt.newCur)
+			if ok != tt.ok {
+				t.Fatalf("expected ok=%v, got %v", tt.ok, ok)
+			}
+			if !ok {
+				return
+			}
+			if ops := len(remove) + len(new) - addFrom; ops != tt.ops {
+				t.Errorf("expected %d operations, got %d", tt.ops, ops)
+			}
+			result := slices.Clone(old)
+			cur := tt.oldCur
+			for _, idx := range remove {
+				if idx == cur {
+					t.Fatalf("current track at %d removed", idx)
+				}
+				result = slices.Delete(result, idx, idx+1)
+				if idx < cur {
+					cur--
+				}
+			}
+			result = append(result, new[addFrom:]...)
+			if !slices.Equal(result, new) {
+				t.Errorf("expected %v, got %v", new, result)
+			}
+			if tt.oldCur >= 0 && cur != tt.newCur {
+				t.Errorf("expected current track at %d, got %d", tt.newCur, cur)
+			}
+		})
+	}
+}
+
+func TestSetQueue(t *testing.T) {
+	fj := &fakeJukebox{}
+	j := newTestPlayer(fj)
+
+	if err := j.SetQueue(testTracks("abcd"), -1); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.PlayTrackAt(2, 0); err != nil {
+		t.Fatal(err)
+	}
+	// shuffling moves the current track to the front of the queue
+	if err := j.SetQueue(testTracks("cadb"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := fj.ids(); got != "cadb" {
+		t.Errorf("expected jukebox queue cadb, got %s", got)
+	}
+	if fj.cleared != 0 || fj.cur != 0 || !fj.playing {
+		t.Errorf("expected current track to keep playing, got cleared=%d cur=%d playing=%v",
+			fj.cleared, fj.cur, fj.playing)
+	}
+}
+
+func TestPollExternalChanges(t *testing.T) {
+	fj := &fakeJukebox{}
+	j := newTestPlayer(fj)
+	j.SetQueue(testTracks("abc"), -1)
+	j.PlayTrackAt(0, 0)
+	j.SetNextTrackAt(1)
+
+	var trackChanges, pauses int
+	jumpedTo := -1
+	var newQueue string
+	j.OnTrackChange(func() { trackChanges++ })
+	j.OnPaused(func() { pauses++ })
+	j.OnQueueIndexChange(func(idx int) { jumpedTo = idx })
+	j.OnQueueChange(func(tracks []*mediaprovider.Track, _ int) {
+		newQueue = strings.Join(trackIDs(tracks), "")
+	})
+
+	// the jukebox advanced to the next track by itself
+	fj.cur = 1
+	j.poll()
+	if trackChanges != 1 || jumpedTo != -1 {
+		t.Errorf("expected a track change, got %d changes, jump to %d", trackChanges, jumpedTo)
+	}
+
+	// another client skipped ahead
+	fj.cur = 0
+	j.poll()
+	if trackChanges != 1 || jumpedTo != 0 {
+		t.Errorf("expected a jump to 0, got %d changes, jump to %d", trackChanges, jumpedTo)
+	}
+
+	// another client paused
+	fj.playing = false
+	j.poll()
+	if pauses != 1 || j.GetStatus().State != player.Paused {
+		t.Errorf("expected pause, got %d pauses, state %v", pauses, j.GetStatus().State)
+	}
+
+	// another client replaced the queue
+	fj.tracks = testTracks("xy")
+	j.poll()
+	if newQueue != "xy" {
+		t.Errorf("expected queue xy, got %q", newQueue)
+	}
+}
+
+func TestGetStatusDuringRequest(t *testing.T) {
+	bj := &blockingJukebox{fakeJukebox: &fakeJukebox{}, started: make(chan struct{}), release: make(chan struct{})}
+	j := newTestPlayer(bj.fakeJukebox)
+	j.SetQueue(testTracks("abc"), -1)
+	j.provider = bj
+
+	done := make(chan error)
+	go func() { done <- j.PlayTrackAt(1, 0) }()
+	<-bj.started
+	status := make(chan player.Status)
+	go func() { status <- j.GetStatus() }()
+	select {
+	case s := <-status:
+		if s.State != player.Stopped {
+			t.Errorf("expected the state before the request, got %v", s.State)
+		}
+	case <-time.After(time.Second):
+		t.Error("GetStatus blocked on the jukebox request")
+	}
+	close(bj.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if s := j.GetStatus(); s.State != player.Playing {
+		t.Errorf("expected playing, got %v", s.State)
+	}
+}
+
+func newTestPlayer(fj *fakeJukebox) *JukeboxPlayer {
+	return &JukeboxPlayer{
+		provider:    fj,
+		curIdx:      -1,
+		nextIdx:     -1,
+		stopPolling: make(chan struct{}),
+	}
+}
+
+func testTracks(ids string) []*mediaprovider.Track {
+	var tracks []*mediaprovider.Track
+	for _, id := range strings.Split(ids, "") {
+		tracks = append(tracks, &mediaprovider.Track{ID: id, Duration: 3 * time.Minute})
+	}
+	return tracks
+}
+
+type fakeJukebox struct {
+	tracks  []*mediaprovider.Track
+	cur     int
+	playing bool
+	pos     int
+	vol     int
+	cleared int
+}
+
+var _ mediaprovider.JukeboxProvider = (*fakeJukebox)(nil)
+
+func (f *fakeJukebox) ids() string {
+	return strings.Join(trackIDs(f.tracks), "")
+}
+
+func (f *fakeJukebox) JukeboxStart() error { f.playing = true; return nil }
+func (f *fakeJukebox) JukeboxStop() error  { f.playing = false; return nil }
+
+func (f *fakeJukebox) JukeboxSeek(idx, seconds int) error {
+	f.cur, f.pos = idx, seconds
+	return nil
+}
+
+func (f *fakeJukebox) JukeboxClear() error {
+	f.tracks, f.cur = nil, -1
+	f.cleared++
+	return nil
+}
+
+func (f *fakeJukebox) JukeboxAdd(trackID string) error {
+	f.tracks = append(f.tracks, &mediaprovider.Track{ID: trackID, Duration: 3 * time.Minute})
+	return nil
+}
+
+func (f *fakeJukebox) JukeboxRemove(idx int) error {
+	f.tracks = slices.Delete(f.tracks, idx, idx+1)
+	if idx < f.cur {
+		f.cur--
+	}
+	return nil
+}
+
+func (f *fakeJukebox) JukeboxGetStatus() (*mediaprovider.JukeboxStatus, error) {
+	return &mediaprovider.JukeboxStatus{
+		Volume:          f.vol,
+		CurrentTrack:    f.cur,
+		Playing:         f.playing,
+		PositionSeconds: float64(f.pos),
+	}, nil
+}
+
+func (f *fakeJukebox) JukeboxGetQueue() (*mediaprovider.JukeboxQueue, error) {
+	stat, _ := f.JukeboxGetStatus()
+	return &mediaprovider.JukeboxQueue{JukeboxStatus: *stat, Tracks: slices.Clone(f.tracks)}, nil
+}
+
+func (f *fakeJukebox) JukeboxSet(trackID string) error {
+	f.JukeboxClear()
+	return f.JukeboxAdd(trackID)
+}
+
+func (f *fakeJukebox) JukeboxSetVolume(vol int) error { f.vol = vol; return nil }
+
+// blockingJukebox blocks starting playback until released.
+type blockingJukebox struct {
+	*fakeJukebox
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingJukebox) JukeboxStart() error {
+	close(b.started)
+	<-b.release
+	return b.fakeJukebox.JukeboxStart()
+}
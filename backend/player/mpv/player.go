@@ -10,11 +10,14 @@ import (
 	"sync"
 	"time"
 
+	"github.com/dweymouth/supersonic/backend/logging"
 	"github.com/dweymouth/supersonic/backend/mediaprovider"
 	"github.com/dweymouth/supersonic/backend/player"
 	"github.com/supersonic-app/go-mpv"
 )
 
+var logger = logging.For(logging.Player)
+
 // Error returned by many Player functions if called before the player has not been initialized.
 var ErrUnitialized error = errors.New("mpv player uninitialized")
 
@@ -47,7 +50,42 @@ type MediaInfo struct {
 
 	// The average bit rate in bits per second.
 	Bitrate int
-}
+
+	// The sample rate of the source, before decoding.
+	SourceSamplerate int
+
+	// The sample format, sample rate and number of channels
+	// of the audio sent to the output device.
+	OutputFormat       string
+	OutputSamplerate   int
+	OutputChannelCount int
+
+	// The ways in which the audio is altered on its path
+	// from the source to the output device, if any.
+	SignalPathIssues []SignalPathIssue
+}
+
+// A way in which the audio is altered on its path to the output device,
+// so that playback is not bit-perfect.
+type SignalPathIssue int
+
+const (
+	// The audio is resampled to another sample rate.
+	SignalPathResampled SignalPathIssue = iota
+	// The audio is converted to another sample format.
+	SignalPathFormatConverted
+	// The audio is up- or downmixed to another number of channels.
+	SignalPathRemixed
+	// The volume is not at 100%.
+	SignalPathVolumeScaled
+	// ReplayGain is applied.
+	SignalPathReplayGain
+	// Audio filters (e.g. the equalizer) are applied.
+	SignalPathFiltered
+	// The output device is shared with other apps, and may
+	// mix and resample the audio.
+	SignalPathSharedOutput
+)
 
 var _ player.URLPlayer = (*Player)(nil)
 
@@ -62,6 +100,7 @@ type Player struct {
 	replayGainOpts player.ReplayGainOptions
 	haveRGainOpts  bool
 	audioExclusive bool
+	bitPerfect     bool
 	status         player.Status
 	seeking        bool
 	curPlaylistPos int64
@@ -70,7 +109,14 @@ type Player struct {
 	clientName     string
 	equalizer      Equalizer
 	peaksEnabled   bool
+	samplesEnabled bool
+	tap            *sampleTap
+	tapFailed      bool
+	tlsOpts        TLSOptions
+	httpHeaders    []string
 	pauseFade      bool
+	audioOutput    string
+	audioOutOpts   map[string]string
 
 	icyTitleCb func(string)
 
@@ -121,7 +167,7 @@ func (p *Player) Init(maxCacheMB int, httpProxy string) error {
 		if p.vol < 0 {
 			p.vol = 100
 		}
-		m.SetOption("volume", mpv.FORMAT_INT64, p.vol)
+		m.SetOption("volume", mpv.FORMAT_INT64, p.outputVolume())
 
 		p.SetAudioExclusive(p.audioExclusive)
 		if p.haveRGainOpts {
@@ -135,12 +181,23 @@ func (p *Player) Init(maxCacheMB int, httpProxy string) error {
 		if httpProxy != "" {
 			m.SetOptionString("http-proxy", httpProxy)
 		}
+		p.tlsOpts.apply(m)
+
+		if p.audioOutput != "" {
+			m.SetOptionString("ao", p.audioOutput)
+			for name, val := range p.audioOutOpts {
+				m.SetOptionString(name, val)
+			}
+		}
 
 		m.ObserveProperty(0, "metadata", mpv.FORMAT_NODE)
 
 		if err := m.Initialize(); err != nil {
 			return fmt.Errorf("error initializing mpv: %s", err.Error())
 		}
+		if err := setHTTPHeaders(m, p.httpHeaders); err != nil {
+			logger.Error("error setting mpv HTTP headers", "err", err)
+		}
 
 		p.mpv = m
 	}
@@ -237,16 +294,21 @@ func (p *Player) SetVolume(vol int) error {
 		vol = 0
 	}
 	if p.initialized {
-		err := p.mpv.SetProperty("volume", mpv.FORMAT_INT64, vol)
-		if err == nil {
-			p.vol = vol
-		}
-		return err
+		p.vol = vol
+		return p.mpv.SetProperty("volume", mpv.FORMAT_INT64, p.outputVolume())
 	}
 	p.vol = vol
 	return nil
 }
 
+// the volume to set on mpv; full volume in bit-perfect mode
+func (p *Player) outputVolume() int {
+	if p.bitPerfect {
+		return 100
+	}
+	return p.vol
+}
+
 // Sets the ReplayGain options of the player.
 // Unlike most Player functions, SetReplayGainOptions can be called
 // before Init, to set the initial replaygain options of the player on startup.
@@ -260,6 +322,9 @@ func (p *Player) SetReplayGainOptions(options player.ReplayGainOptions) error {
 	case player.ReplayGainTrack:
 		mode = "track"
 	}
+	if p.bitPerfect {
+		mode = "no"
+	}
 
 	if p.initialized {
 		if err := p.mpv.SetPropertyString("replaygain", mode); err != nil {
@@ -285,14 +350,95 @@ func (p *Player) SetReplayGainOptions(options player.ReplayGainOptions) error {
 func (p *Player) SetAudioExclusive(tf bool) {
 	p.audioExclusive = tf
 	if p.initialized {
-		val := "no"
-		if tf {
-			val = "yes"
+		p.mpv.SetOptionString("audio-exclusive", yesNo(p.exclusive()))
+	}
+}
+
+// Sets bit-perfect mode, in which the decoded audio is sent unaltered to
+// the output device, opened exclusively at the source's sample rate and
+// format. ReplayGain, the equalizer and volume are bypassed while enabled.
+// Unlike most Player functions, SetBitPerfect can be called before Init.
+func (p *Player) SetBitPerfect(tf bool) error {
+	p.bitPerfect = tf
+	if !p.initialized {
+		return nil
+	}
+	p.SetAudioExclusive(p.audioExclusive)
+	if p.haveRGainOpts {
+		if err := p.SetReplayGainOptions(p.replayGainOpts); err != nil {
+			return err
 		}
-		p.mpv.SetOptionString("audio-exclusive", val)
+	}
+	if err := p.SetVolume(p.vol); err != nil {
+		return err
+	}
+	return p.setAF()
+}
+
+// whether the audio device is to be opened exclusively
+func (p *Player) exclusive() bool {
+	return p.audioExclusive || p.bitPerfect
+}
+
+// Sets the mpv audio output driver to use instead of the system audio API,
+// along with any mpv options it needs (e.g. "pcm" with "ao-pcm-file").
+// Must be called before Init.
+func (p *Player) SetAudioOutput(ao string, options map[string]string) {
+	p.audioOutput = ao
+	p.audioOutOpts = options
+}
+
+// TLSOptions are the TLS settings for streams loaded over HTTPS.
+type TLSOptions struct {
+	// PEM CA bundle to verify the server with, in addition to the system CAs
+	CAFile string
+	// PEM client certificate and key, which may be the same file
+	CertFile string
+	KeyFile  string
+	// Verify is whether to verify the server certificate
+	Verify bool
+}
+
+func (o TLSOptions) apply(m *mpv.Mpv) {
+	m.SetOptionString("tls-verify", yesNo(o.Verify))
+	m.SetOptionString("tls-ca-file", o.CAFile)
+	m.SetOptionString("tls-cert-file", o.CertFile)
+	m.SetOptionString("tls-key-file", o.KeyFile)
+}
+
+// SetTLSOptions sets the TLS settings for the streams played after the call.
+func (p *Player) SetTLSOptions(opts TLSOptions) {
+	p.tlsOpts = opts
+	if p.initialized {
+		opts.apply(p.mpv)
 	}
 }
 
+// SetHTTPHeaders sets the extra "Name: value" headers sent with
+// the HTTP requests for the streams played after the call.
+func (p *Player) SetHTTPHeaders(headers []string) error {
+	p.httpHeaders = headers
+	if p.initialized {
+		return setHTTPHeaders(p.mpv, headers)
+	}
+	return nil
+}
+
+// setHTTPHeaders replaces the headers of an initialized mpv instance.
+// Each header is appended as a list item, rather than setting the
+// comma-separated list, since header values may contain commas.
+func setHTTPHeaders(m *mpv.Mpv, headers []string) error {
+	if err := m.SetOptionString("http-header-fields", ""); err != nil {
+		return err
+	}
+	for _, h := range headers {
+		if err := m.Command([]string{"change-list", "http-header-fields", "append", h}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (p *Player) SetPauseFade(pauseFade bool) {
 	p.pauseFade = pauseFade
 }
@@ -305,13 +451,13 @@ func (p *Player) GetVolume() int {
 // sets paused status and ensures that audio exlusive is false while paused
 // (releases audio device to other players)
 func (p *Player) setPaused(paused bool) error {
-	if !paused && p.audioExclusive {
+	if !paused && p.exclusive() {
 		if err := p.mpv.SetOptionString("audio-exclusive", "yes"); err != nil {
 			return err
 		}
 	}
 	err := p.mpv.SetProperty("pause", mpv.FORMAT_FLAG, paused)
-	if err == nil && paused && p.audioExclusive {
+	if err == nil && paused && p.exclusive() {
 		err = p.mpv.SetOptionString("audio-exclusive", "no")
 	}
 	return err
@@ -446,10 +592,62 @@ func (p *Player) GetMediaInfo() (MediaInfo, error) {
 	if err == nil {
 		info.Codec = codec.(string)
 	}
+	sr, err := p.mpv.GetProperty("track-list/0/demux-samplerate", mpv.FORMAT_INT64)
+	if err == nil {
+		info.SourceSamplerate = int(sr.(int64))
+	}
+	if n, err := p.mpv.GetProperty("audio-out-params", mpv.FORMAT_NODE); err == nil {
+		nodeMap := n.(*mpv.Node).Data.(map[string]*mpv.Node)
+		info.OutputFormat = nodeMap["format"].Data.(string)
+		info.OutputSamplerate = int(nodeMap["samplerate"].Data.(int64))
+		info.OutputChannelCount = int(nodeMap["channel-count"].Data.(int64))
+	}
+
+	vol, _ := p.mpv.GetProperty("volume", mpv.FORMAT_DOUBLE)
+	volume, _ := vol.(float64)
+	info.SignalPathIssues = signalPathIssues(info, volume,
+		p.mpv.GetPropertyString("replaygain"),
+		p.mpv.GetPropertyString("af"),
+		p.exclusive())
 
 	return info, nil
 }
 
+func signalPathIssues(info MediaInfo, volume float64, replayGain, af string, exclusive bool) []SignalPathIssue {
+	var issues []SignalPathIssue
+	if (info.SourceSamplerate > 0 && info.SourceSamplerate != info.Samplerate) ||
+		(info.OutputSamplerate > 0 && info.OutputSamplerate != info.Samplerate) {
+		issues = append(issues, SignalPathResampled)
+	}
+	// planar and packed formats (e.g. "s32p" and "s32") hold the same samples
+	if info.OutputFormat != "" && strings.TrimSuffix(info.OutputFormat, "p") != strings.TrimSuffix(info.Format, "p") {
+		issues = append(issues, SignalPathFormatConverted)
+	}
+	if info.OutputChannelCount > 0 && info.OutputChannelCount != info.ChannelCount {
+		issues = append(issues, SignalPathRemixed)
+	}
+	if math.Abs(volume-100) > 0.01 {
+		issues = append(issues, SignalPathVolumeScaled)
+	}
+	if replayGain != "" && replayGain != "no" {
+		issues = append(issues, SignalPathReplayGain)
+	}
+	if af != "" {
+		issues = append(issues, SignalPathFiltered)
+	}
+	if !exclusive {
+		issues = append(issues, SignalPathSharedOutput)
+	}
+	return issues
+}
+
+func yesNo(tf bool) string {
+	if tf {
+		return "yes"
+	}
+	return "no"
+}
+
 func (p *Player) ObserveIcyRadioTitle(cb func(string)) {
 	p.icyTitleCb = cb
 	p.mpv.ObserveProperty(1, "metadata/icy-title", mpv.FORMAT_STRING)
@@ -481,6 +679,10 @@ func (p *Player) Destroy() {
 	if p.bgCancel != nil {
 		p.bgCancel()
 	}
+	if p.tap != nil {
+		p.tap.destroy()
+		p.tap = nil
+	}
 	if p.initialized {
 		p.mpv.Command([]string{"stop"})
 		p.mpv.TerminateDestroy()
@@ -508,6 +710,51 @@ func (p *Player) GetPeaks() (float64, float64, float64, float64) {
 	return lPeak, rPeak, lRMS, rRMS
 }
 
+// SetSamplesEnabled sets whether the PCM samples being played
+// can be read with ReadSamples.
+func (p *Player) SetSamplesEnabled(enabled bool) {
+	if p.samplesEnabled == enabled {
+		return
+	}
+	p.samplesEnabled = enabled
+	p.tapFailed = false
+	if !enabled && p.tap != nil {
+		p.tap.destroy()
+		p.tap = nil
+	}
+}
+
+// ReadSamples fills left and right with the stereo samples, at
+// SampleTapSampleRate, that end at the current playback position.
+// It returns the number of samples read, which is 0 if samples are not
+// enabled, or not (yet) available. Must not be called concurrently.
+func (p *Player) ReadSamples(left, right []float64) int {
+	if !p.samplesEnabled || p.tapFailed || p.status.State != player.Playing {
+		return 0
+	}
+	source := p.mpv.GetPropertyString("path")
+	pos, err := p.mpv.GetProperty("playback-time", mpv.FORMAT_DOUBLE)
+	if source == "" || err != nil || pos == nil {
+		return 0
+	}
+	if p.tap == nil || !p.tap.covers(source, pos.(float64)) {
+		// start decoding the current media from the playback position
+		// (after a track change or seek)
+		if p.tap != nil {
+			p.tap.destroy()
+			p.tap = nil
+		}
+		if p.tap, err = newSampleTap(p.mpv, source, pos.(float64)); err != nil {
+			logger.Error("error starting sample tap", "err", err)
+			p.tapFailed = true // don't retry until re-enabled
+			return 0
+		}
+	} else {
+		p.tap.update(readSampleTapSettings(p.mpv))
+	}
+	return p.tap.read(pos.(float64), left, right)
+}
+
 // sets the state and invokes callbacks, if triggered
 func (p *Player) setState(s player.State) {
 	switch {
@@ -523,6 +770,11 @@ func (p *Player) setState(s player.State) {
 
 func (p *Player) setAF() error {
 	var filters []string
+	if p.bitPerfect {
+		// no filters, not even for analysis, since
+		// they may convert the sample format
+		return p.mpv.SetPropertyString("af", "")
+	}
 	if p.peaksEnabled {
 		filters = append(filters, "@astats:astats=metadata=1:reset=1:measure_overall=none")
 	}
@@ -0,0 +1,55 @@
+package mpv
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSignalPathIssues(t *testing.T) {
+	info := MediaInfo{
+		Format:             "s32p",
+		Samplerate:         96000,
+		ChannelCount:       2,
+		SourceSamplerate:   96000,
+		OutputFormat:       "s32",
+		OutputSamplerate:   96000,
+		OutputChannelCount: 2,
+	}
+	if issues := signalPathIssues(info, 100, "no", "", true); len(issues) != 0 {
+		t.Errorf("expected a bit-perfect path, got issues %v", issues)
+	}
+
+	info.OutputFormat = "s16"
+	info.OutputSamplerate = 48000
+	issues := signalPathIssues(info, 80, "track", "lavfi=[superequalizer]", false)
+	expected := []SignalPathIssue{
+		SignalPathResampled,
+		SignalPathFormatConverted,
+		SignalPathVolumeScaled,
+		SignalPathReplayGain,
+		SignalPathFiltered,
+		SignalPathSharedOutput,
+	}
+	if !slices.Equal(issues, expected) {
+		t.Errorf("expected issues %v, got %v", expected, issues)
+	}
+}
+
+func TestSampleTapSource(t *testing.T) {
+	for source, want := range map[string]bool{
+		"/home/user/.cache/track.flac":  false,
+		`C:\Users\user\track.flac`:      false,
+		"file:///home/user/track.flac":  false,
+		"https://music.example.com/x":   true,
+		"http://radio.example.com:8000": true,
+	} {
+		if got := isStream(source); got != want {
+			t.Errorf("isStream(%q) = %v, want %v", source, got, want)
+		}
+	}
+
+	s := sampleTapSettings{af: "@astats:lavfi-astats", gainDB: -6.5}
+	if got := s.tapAF(); got != "@astats:lavfi-astats,volume=volume=-6.50dB" {
+		t.Errorf("unexpected tap filters: %q", got)
+	}
+}
@@ -0,0 +1,269 @@
+package mpv
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/supersonic-app/go-mpv"
+)
+
+// The sample rate of the samples returned by Player.ReadSamples.
+const SampleTapSampleRate = 44100
+
+const (
+	// bytes per frame of the tap's interleaved stereo s16 PCM
+	sampleTapFrameSize = 4
+	// max number of seconds that playback may be ahead of
+	// the tap's decoding before the tap is restarted
+	sampleTapMaxLag = 2.0
+	// number of seconds of the media decoded by each tap, which bounds
+	// the size of its files; a new tap continues where it ends
+	sampleTapWindow = 30.0
+	// number of seconds before the playback position that a tap starts
+	// decoding at, so that it has samples to return right away
+	sampleTapHistory = 1.0
+)
+
+// sampleTap gives access to the PCM samples of the currently playing media.
+// libmpv has no API to read the samples it plays, and no audio filter can
+// pass them out of the playing instance, so the tap decodes a window of the
+// media a second time with a separate mpv instance, writing PCM to a
+// temporary file, from which the samples at the playback position are read.
+// Streamed media are not fetched from the server again: the window is
+// dumped from the demuxer cache of the playing instance. The tap applies
+// the same filters (e.g. the equalizer), ReplayGain and volume as playback.
+type sampleTap struct {
+	mpv      *mpv.Mpv
+	source   string  // the path or URL of the media being decoded
+	start    float64 // the playback position where decoding began
+	file     *os.File
+	dump     string // the path of the dumped cache being decoded, if any
+	settings sampleTapSettings
+	buf      []byte
+}
+
+// sampleTapSettings are the playback settings that alter the
+// samples, which the tap mirrors from the playing instance.
+type sampleTapSettings struct {
+	af     string
+	volume float64
+	gainDB float64 // ReplayGain
+}
+
+func readSampleTapSettings(m *mpv.Mpv) sampleTapSettings {
+	s := sampleTapSettings{af: m.GetPropertyString("af"), volume: 100}
+	if vol, err := m.GetProperty("volume", mpv.FORMAT_DOUBLE); err == nil {
+		s.volume = vol.(float64)
+	}
+	s.gainDB = replayGainDB(m)
+	return s
+}
+
+// replayGainDB returns the ReplayGain the playing instance applies to the
+// current track. mpv doesn't expose it, so it is computed as mpv does.
+func replayGainDB(m *mpv.Mpv) float64 {
+	mode := m.GetPropertyString("replaygain")
+	if mode != "track" && mode != "album" {
+		return 0
+	}
+	getDouble := func(name string) (float64, bool) {
+		v, err := m.GetProperty(name, mpv.FORMAT_DOUBLE)
+		if err != nil || v == nil {
+			return 0, false
+		}
+		return v.(float64), true
+	}
+	gain, ok := getDouble("current-tracks/audio/replaygain-" + mode + "-gain")
+	peak, _ := getDouble("current-tracks/audio/replaygain-" + mode + "-peak")
+	if !ok && mode == "album" {
+		gain, ok = getDouble("current-tracks/audio/replaygain-track-gain")
+		peak, _ = getDouble("current-tracks/audio/replaygain-track-peak")
+	}
+	if !ok {
+		fallback, _ := getDouble("replaygain-fallback")
+		return fallback
+	}
+	preamp, _ := getDouble("replaygain-preamp")
+	gain += preamp
+	// replaygain-clip=no lowers the gain to prevent clipping
+	if m.GetPropertyString("replaygain-clip") == "no" && peak > 0 {
+		gain = min(gain, -20*math.Log10(peak))
+	}
+	return gain
+}
+
+// tapAF returns the filters the tap applies for the settings.
+func (s sampleTapSettings) tapAF() string {
+	if math.Abs(s.gainDB) < 0.01 {
+		return s.af
+	}
+	gain := fmt.Sprintf("volume=volume=%0.2fdB", s.gainDB)
+	if s.af == "" {
+		return gain
+	}
+	return s.af + "," + gain
+}
+
+// newSampleTap starts decoding the media being played by the playing mpv
+// instance m from shortly before the playback position pos.
+func newSampleTap(m *mpv.Mpv, source string, pos float64) (*sampleTap, error) {
+	t := &sampleTap{source: source, start: max(pos-sampleTapHistory, 0)}
+	var err error
+	if t.file, err = os.CreateTemp("", "supersonic-samples-*.pcm"); err != nil {
+		return nil, err
+	}
+	input := source
+	if isStream(source) {
+		// the dump begins at the packet containing start
+		if err := t.dumpCache(m); err != nil {
+			t.cleanUp()
+			return nil, err
+		}
+		input = t.dump
+	}
+
+	t.settings = readSampleTapSettings(m)
+	t.mpv = mpv.Create()
+	t.mpv.SetOptionString("idle", "yes")
+	t.mpv.SetOptionString("video", "no")
+	t.mpv.SetOptionString("audio-display", "no")
+	t.mpv.SetOptionString("terminal", "no")
+	t.mpv.SetOptionString("ao", "pcm")
+	t.mpv.SetOptionString("ao-pcm-file", t.file.Name())
+	t.mpv.SetOptionString("ao-pcm-waveheader", "no")
+	t.mpv.SetOption("volume", mpv.FORMAT_DOUBLE, t.settings.volume)
+	t.mpv.SetOptionString("replaygain", "no") // applied as a filter
+	t.mpv.SetOptionString("af", t.settings.tapAF())
+	t.mpv.SetOption("audio-samplerate", mpv.FORMAT_INT64, SampleTapSampleRate)
+	t.mpv.SetOptionString("audio-channels", "stereo")
+	t.mpv.SetOptionString("audio-format", "s16")
+	if input == source {
+		t.mpv.SetOptionString("start", fmt.Sprintf("%f", t.start))
+		t.mpv.SetOptionString("end", fmt.Sprintf("%f", t.start+sampleTapWindow))
+	}
+	if err := t.mpv.Initialize(); err != nil {
+		t.mpv.TerminateDestroy()
+		t.cleanUp()
+		return nil, err
+	}
+	if err := t.mpv.Command([]string{"loadfile", input, "replace"}); err != nil {
+		t.mpv.TerminateDestroy()
+		t.cleanUp()
+		return nil, err
+	}
+	return t, nil
+}
+
+// dumpCache writes the window of the playing instance's
+// demuxer cache that the tap decodes to a temporary file.
+func (t *sampleTap) dumpCache(m *mpv.Mpv) error {
+	f, err := os.CreateTemp("", "supersonic-samples-*.mkv")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	t.dump = f.Name()
+	return m.Command([]string{"dump-cache",
+		fmt.Sprintf("%f", t.start), fmt.Sprintf("%f", t.start+sampleTapWindow), t.dump})
+}
+
+// isStream returns whether the source is streamed over the network,
+// rather than a local file.
+func isStream(source string) bool {
+	return strings.Contains(source, "://") && !strings.HasPrefix(source, "file://")
+}
+
+// covers returns true if the tap is decoding the given source and can
+// (or soon will be able to) return the samples at the playback position.
+func (t *sampleTap) covers(source string, pos float64) bool {
+	if t.source != source || pos < t.start {
+		return false
+	}
+	decoded := t.decodedSeconds()
+	end := t.start + decoded
+	if decoded > 0 && t.finished() {
+		// start the next tap while this one still has history to return
+		return pos <= end-sampleTapHistory/2
+	}
+	return pos <= end+sampleTapMaxLag
+}
+
+// finished returns true if the tap is idle, which once it has
+// decoded anything means it has decoded all of its window.
+func (t *sampleTap) finished() bool {
+	idle, err := t.mpv.GetProperty("idle-active", mpv.FORMAT_FLAG)
+	return err == nil && idle.(bool)
+}
+
+// update applies changed playback settings to the samples decoded from now on.
+func (t *sampleTap) update(s sampleTapSettings) {
+	if s == t.settings {
+		return
+	}
+	if s.volume != t.settings.volume {
+		t.mpv.SetProperty("volume", mpv.FORMAT_DOUBLE, s.volume)
+	}
+	if s.tapAF() != t.settings.tapAF() {
+		t.mpv.SetPropertyString("af", s.tapAF())
+	}
+	t.settings = s
+}
+
+func (t *sampleTap) decodedSeconds() float64 {
+	s, err := t.file.Stat()
+	if err != nil {
+		return 0
+	}
+	return float64(s.Size()/sampleTapFrameSize) / SampleTapSampleRate
+}
+
+// read fills left and right with the samples that end at the playback
+// position pos, and returns the number of samples read. Any samples from
+// before the start of the tap are zero.
+func (t *sampleTap) read(pos float64, left, right []float64) int {
+	n := min(len(left), len(right))
+	end := int64((pos - t.start) * SampleTapSampleRate)
+	begin := end - int64(n)
+	skip := 0
+	if begin < 0 {
+		skip = int(-begin)
+		begin = 0
+	}
+	if cap(t.buf) < n*sampleTapFrameSize {
+		t.buf = make([]byte, n*sampleTapFrameSize)
+	}
+	buf := t.buf[:(n-skip)*sampleTapFrameSize]
+	if _, err := t.file.ReadAt(buf, begin*sampleTapFrameSize); err != nil {
+		// not yet decoded
+		return 0
+	}
+	for i := range skip {
+		left[i], right[i] = 0, 0
+	}
+	for i := skip; i < n; i++ {
+		frame := buf[(i-skip)*sampleTapFrameSize:]
+		left[i] = float64(int16(binary.LittleEndian.Uint16(frame[0:]))) / 32768
+		right[i] = float64(int16(binary.LittleEndian.Uint16(frame[2:]))) / 32768
+	}
+	return n
+}
+
+func (t *sampleTap) destroy() {
+	// terminating mpv may block briefly
+	go func() {
+		t.mpv.TerminateDestroy()
+		t.cleanUp()
+	}()
+}
+
+// cleanUp removes the tap's temporary files.
+func (t *sampleTap) cleanUp() {
+	t.file.Close()
+	os.Remove(t.file.Name())
+	if t.dump != "" {
+		os.Remove(t.dump)
+	}
+}
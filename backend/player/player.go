@@ -16,6 +16,27 @@ type TrackPlayer interface {
 	SetNextTrack(track *mediaprovider.Track) error
 }
 
+// A player which keeps its own queue of tracks (e.g. a server-side jukebox),
+// to which the whole play queue is mirrored rather than only the current
+// and next track. The player advances through its queue on its own, so
+// track changes are gapless.
+type QueuePlayer interface {
+	BasePlayer
+	// SetQueue mirrors the play queue to the player. The track at
+	// nowPlayingIdx is kept playing if it is already.
+	SetQueue(tracks []*mediaprovider.Track, nowPlayingIdx int) error
+	// PlayTrackAt plays the track at the given index of the queue.
+	PlayTrackAt(idx int, startTime float64) error
+	// SetNextTrackAt sets the queue index to play after the current track
+	// finishes, if not the following one (e.g. when looping), or -1 for none.
+	SetNextTrackAt(idx int) error
+
+	// Invoked when another client plays a different track of the queue.
+	OnQueueIndexChange(func(idx int))
+	// Invoked when another client changes the player's queue.
+	OnQueueChange(func(tracks []*mediaprovider.Track, nowPlayingIdx int))
+}
+
 type BasePlayer interface {
 	Continue() error
 	Pause() error
@@ -43,6 +64,32 @@ type ReplayGainPlayer interface {
 	SetReplayGainOptions(ReplayGainOptions) error
 }
 
+// A player whose volume may also be changed other than through SetVolume,
+// e.g. on the remote device itself.
+type VolumeEventPlayer interface {
+	// Invoked when the volume is changed externally.
+	OnVolumeChange(func(vol int))
+}
+
+// A player which outputs to several rooms at once (e.g. multi-room audio),
+// each of which has its own volume in addition to the player volume.
+type MultiRoomPlayer interface {
+	Rooms() []Room
+	SetRoomVolume(roomID string, vol int) error
+	SetRoomMuted(roomID string, muted bool) error
+
+	// Invoked when rooms connect or disconnect or their volume changes.
+	OnRoomsChange(func())
+}
+
+// An output of a MultiRoomPlayer.
+type Room struct {
+	ID     string
+	Name   string
+	Volume int
+	Muted  bool
+}
+
 // The playback state (Stopped, Paused, or Playing).
 type State int
 
@@ -0,0 +1,12 @@
+//go:build !windows
+
+package snapcast
+
+import "syscall"
+
+// TCP stream sources are fed by having mpv write to a FIFO which we relay.
+const fifoSupported = true
+
+func makeFIFO(path string) error {
+	return syscall.Mkfifo(path, 0600)
+}
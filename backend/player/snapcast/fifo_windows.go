@@ -0,0 +1,9 @@
+package snapcast
+
+import "errors"
+
+const fifoSupported = false
+
+func makeFIFO(path string) error {
+	return errors.New("snapcast: FIFOs are not supported on Windows")
+}
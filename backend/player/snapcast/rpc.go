@@ -0,0 +1,152 @@
+package snapcast
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// default port of the snapserver JSON-RPC control API (raw TCP)
+	defaultControlPort = "1705"
+
+	writeTimeout = 5 * time.Second
+)
+
+var errConnClosed = errors.New("snapcast: connection closed")
+
+// rpcClient speaks JSON-RPC 2.0 to a snapserver's control port,
+// where requests, responses and notifications are newline-delimited JSON.
+type rpcClient struct {
+	conn      net.Conn
+	writeLock sync.Mutex
+
+	pendingLock sync.Mutex
+	nextID      int
+	pending     map[int]chan rpcResponse
+
+	// invoked on the read goroutine for server notifications
+	onNotification func(method string, params json.RawMessage)
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+type rpcRequest struct {
+	ID      int    `json:"id"`
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func dialRPC(ctx context.Context, addr string, onNotification func(string, json.RawMessage)) (*rpcClient, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &rpcClient{
+		conn:           conn,
+		pending:        make(map[int]chan rpcResponse),
+		onNotification: onNotification,
+		closed:         make(chan struct{}),
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+// call invokes the RPC method and unmarshals its result into result, if non-nil.
+func (c *rpcClient) call(ctx context.Context, method string, params, result any) error {
+	respChan := make(chan rpcResponse, 1)
+	c.pendingLock.Lock()
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = respChan
+	c.pendingLock.Unlock()
+	defer func() {
+		c.pendingLock.Lock()
+		delete(c.pending, id)
+		c.pendingLock.Unlock()
+	}()
+
+	b, err := json.Marshal(rpcRequest{ID: id, JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	c.writeLock.Lock()
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err = c.conn.Write(append(b, '\r', '\n'))
+	c.writeLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case resp := <-respChan:
+		if resp.Error != nil {
+			return fmt.Errorf("snapcast: %s failed: %s", method, resp.Error.Message)
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.closed:
+		return errConnClosed
+	}
+}
+
+func (c *rpcClient) readLoop() {
+	defer c.Close()
+	scanner := bufio.NewScanner(c.conn)
+	// status of a server with many clients can be large
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var resp rpcResponse
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			continue
+		}
+		if resp.ID == nil {
+			if resp.Method != "" && c.onNotification != nil {
+				c.onNotification(resp.Method, resp.Params)
+			}
+			continue
+		}
+		c.pendingLock.Lock()
+		if respChan, ok := c.pending[*resp.ID]; ok {
+			respChan <- resp
+		}
+		c.pendingLock.Unlock()
+	}
+	select {
+	case <-c.closed:
+	default:
+		if err := scanner.Err(); err != nil {
+			logger.Error("snapcast read error", "err", err)
+		}
+	}
+}
+
+func (c *rpcClient) Close() {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.conn.Close()
+	})
+}
@@ -0,0 +1,240 @@
+package snapcast
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dweymouth/supersonic/backend/util"
+)
+
+const controlService = "_snapcast-jsonrpc._tcp.local."
+
+// Server is a snapserver with a stream source we can send audio to.
+type Server struct {
+	// Friendly name of the server, from its host name
+	Name string
+	// host:port of the JSON-RPC control API
+	Addr string
+	// The stream which playback will be sent to
+	Stream Stream
+}
+
+type serverStatus struct {
+	Server struct {
+		Groups []group `json:"groups"`
+		Server struct {
+			Host struct {
+				Name string `json:"name"`
+			} `json:"host"`
+		} `json:"server"`
+		Streams []Stream `json:"streams"`
+	} `json:"server"`
+}
+
+type group struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	StreamID string   `json:"stream_id"`
+	Clients  []client `json:"clients"`
+}
+
+type client struct {
+	ID        string `json:"id"`
+	Connected bool   `json:"connected"`
+	Host      struct {
+		Name string `json:"name"`
+	} `json:"host"`
+	Config struct {
+		Name   string       `json:"name"`
+		Volume clientVolume `json:"volume"`
+	} `json:"config"`
+}
+
+type clientVolume struct {
+	Muted   bool `json:"muted"`
+	Percent int  `json:"percent"`
+}
+
+// Stream is an audio source configured on the snapserver.
+type Stream struct {
+	ID  string `json:"id"`
+	URI struct {
+		Raw    string            `json:"raw"`
+		Scheme string            `json:"scheme"`
+		Host   string            `json:"host"`
+		Path   string            `json:"path"`
+		Query  map[string]string `json:"query"`
+	} `json:"uri"`
+}
+
+// SampleFormat is the PCM format a stream source expects.
+type SampleFormat struct {
+	Rate     int
+	Bits     int
+	Channels int
+}
+
+// SampleFormat returns the stream's "rate:bits:channels" sample format,
+// defaulting to snapserver's 48000:16:2.
+func (s Stream) SampleFormat() SampleFormat {
+	f := SampleFormat{Rate: 48000, Bits: 16, Channels: 2}
+	parts := strings.Split(s.URI.Query["sampleformat"], ":")
+	if len(parts) != 3 {
+		return f
+	}
+	rate, err1 := strconv.Atoi(parts[0])
+	bits, err2 := strconv.Atoi(parts[1])
+	channels, err3 := strconv.Atoi(parts[2])
+	if err1 != nil || err2 != nil || err3 != nil || rate <= 0 || channels <= 0 {
+		return f
+	}
+	switch bits {
+	case 16, 24, 32:
+		return SampleFormat{Rate: rate, Bits: bits, Channels: channels}
+	}
+	return f
+}
+
+// Whether snapserver listens for a TCP connection to receive this stream's audio.
+func (s Stream) isTCPServer() bool {
+	mode := s.URI.Query["mode"]
+	return s.URI.Scheme == "tcp" && (mode == "" || mode == "server")
+}
+
+// tcpAddr returns the address to send audio to for a TCP server stream.
+func (s Stream) tcpAddr(serverHost string) string {
+	port := "4953"
+	if _, p, err := net.SplitHostPort(s.URI.Host); err == nil && p != "" {
+		port = p
+	}
+	return net.JoinHostPort(serverHost, port)
+}
+
+// pickStream chooses the stream to send playback to. A stream with the
+// preferred ID is used if given; otherwise streams named after the
+// app are preferred, then the first stream we are able to feed.
+// Pipe (FIFO) sources can only be fed when the server is on this computer.
+func pickStream(streams []Stream, serverIsLocal bool, preferredID string) (Stream, bool) {
+	usable := func(s Stream) bool {
+		return (s.isTCPServer() && fifoSupported) || (s.URI.Scheme == "pipe" && serverIsLocal)
+	}
+	var candidate *Stream
+	for i, s := range streams {
+		if !usable(s) {
+			continue
+		}
+		if preferredID != "" {
+			if s.ID == preferredID {
+				return s, true
+			}
+			continue
+		}
+		if strings.Contains(strings.ToLower(s.ID), "supersonic") {
+			return s, true
+		}
+		if candidate == nil {
+			candidate = &streams[i]
+		}
+	}
+	if candidate == nil {
+		return Stream{}, false
+	}
+	return *candidate, true
+}
+
+// Discover finds snapservers on the local network via mDNS,
+// plus any additional servers given by host[:port], that have
+// a stream source we can send audio to.
+func Discover(ctx context.Context, wait time.Duration, extraHosts []string, preferredStreamID string) ([]Server, error) {
+	services, err := util.BrowseMDNS(ctx, controlService, wait)
+	if err != nil && len(extraHosts) == 0 {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(services)+len(extraHosts))
+	for _, s := range services {
+		addrs = append(addrs, s.Addr)
+	}
+	for _, h := range extraHosts {
+		if _, _, err := net.SplitHostPort(h); err != nil {
+			h = net.JoinHostPort(h, defaultControlPort)
+		}
+		addrs = append(addrs, h)
+	}
+
+	var wg sync.WaitGroup
+	results := make([]*Server, len(addrs))
+	for i, addr := range addrs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s, err := queryServer(ctx, addr, preferredStreamID); err == nil {
+				results[i] = s
+			}
+		}()
+	}
+	wg.Wait()
+
+	var servers []Server
+	seen := make(map[string]bool)
+	for _, s := range results {
+		if s != nil && !seen[s.Addr] {
+			seen[s.Addr] = true
+			servers = append(servers, *s)
+		}
+	}
+	return servers, nil
+}
+
+func queryServer(ctx context.Context, addr, preferredStreamID string) (*Server, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	rpc, err := dialRPC(ctx, addr, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rpc.Close()
+
+	var status serverStatus
+	if err := rpc.call(ctx, "Server.GetStatus", nil, &status); err != nil {
+		return nil, err
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	stream, ok := pickStream(status.Server.Streams, isLocalHost(host), preferredStreamID)
+	if !ok {
+		return nil, fmt.Errorf("snapcast: no usable stream source on %s", addr)
+	}
+	name := status.Server.Server.Host.Name
+	if name == "" {
+		name = host
+	}
+	return &Server{Name: name, Addr: addr, Stream: stream}, nil
+}
+
+// Whether the host refers to this computer.
+func isLocalHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, a := range addrs {
+		if ipNet, ok := a.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
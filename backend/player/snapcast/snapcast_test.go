@@ -0,0 +1,172 @@
+package snapcast
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testStatus = `{"server":{
+	"groups":[{"id":"g1","name":"","stream_id":"default","clients":[
+		{"id":"kitchen","connected":true,"host":{"name":"kitchen-pi"},"config":{"name":"","volume":{"muted":false,"percent":40}}},
+		{"id":"garage","connected":false,"host":{"name":"garage-pi"},"config":{"name":"Garage","volume":{"muted":false,"percent":100}}}
+	]}],
+	"server":{"host":{"name":"snapserver"}},
+	"streams":[
+		{"id":"default","uri":{"scheme":"pipe","path":"/tmp/snapfifo","query":{"name":"default"}}},
+		{"id":"Supersonic","uri":{"scheme":"tcp","host":"0.0.0.0:4955","query":{"name":"Supersonic","mode":"server","sampleformat":"44100:16:2"}}}
+	]}}`
+
+// fakeSnapserver answers JSON-RPC requests on the control port
+// and records the method calls it receives.
+type fakeSnapserver struct {
+	listener net.Listener
+
+	lock  sync.Mutex
+	calls []string
+}
+
+func newFakeSnapserver(t *testing.T) *fakeSnapserver {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSnapserver{listener: l}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSnapserver) serve(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var req rpcRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			return
+		}
+		s.lock.Lock()
+		s.calls = append(s.calls, req.Method)
+		s.lock.Unlock()
+		result := json.RawMessage(`"ok"`)
+		if req.Method == "Server.GetStatus" {
+			result = json.RawMessage(testStatus)
+		}
+		b, _ := json.Marshal(map[string]any{"id": req.ID, "jsonrpc": "2.0", "result": result})
+		conn.Write(append(b, '\r', '\n'))
+	}
+}
+
+func TestStream_SampleFormat(t *testing.T) {
+	var s Stream
+	if f := s.SampleFormat(); f != (SampleFormat{48000, 16, 2}) {
+		t.Errorf("expected default sample format, got %v", f)
+	}
+	s.URI.Query = map[string]string{"sampleformat": "44100:24:2"}
+	if f := s.SampleFormat(); f != (SampleFormat{44100, 24, 2}) {
+		t.Errorf("expected 44100:24:2, got %v", f)
+	}
+}
+
+func TestConvertS32ToS24(t *testing.T) {
+	b := []byte{
+		0x00, 0x56, 0x34, 0x12, // 0x12345600
+		0x00, 0x00, 0x00, 0x80, // min int32
+		0xff, 0xff, 0xff, 0xff, // -1
+	}
+	convertS32ToS24(b)
+	want := []byte{
+		0x56, 0x34, 0x12, 0x00,
+		0x00, 0x00, 0x80, 0xff,
+		0xff, 0xff, 0xff, 0xff,
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("got % x, want % x", b, want)
+	}
+}
+
+func TestPickStream(t *testing.T) {
+	var status serverStatus
+	if err := json.Unmarshal([]byte(testStatus), &status); err != nil {
+		t.Fatal(err)
+	}
+	streams := status.Server.Streams
+	if s, ok := pickStream(streams, true, "default"); !ok || s.ID != "default" {
+		t.Errorf("expected preferred stream, got %q", s.ID)
+	}
+	if _, ok := pickStream(streams, false, "default"); ok {
+		t.Error("pipe stream should not be usable on a remote server")
+	}
+	if !fifoSupported {
+		return
+	}
+	if s, ok := pickStream(streams, true, ""); !ok || s.ID != "Supersonic" {
+		t.Errorf("expected stream named after the app, got %q", s.ID)
+	}
+	if addr := streams[1].tcpAddr("192.168.1.5"); addr != "192.168.1.5:4955" {
+		t.Errorf("unexpected stream source address %s", addr)
+	}
+}
+
+func TestRoomsAndVolume(t *testing.T) {
+	srv := newFakeSnapserver(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	p := &SnapcastPlayer{
+		server:      Server{Addr: srv.listener.Addr().String(), Stream: Stream{ID: "Supersonic"}},
+		prevStreams: make(map[string]string),
+	}
+	rpc, err := dialRPC(ctx, p.server.Addr, p.handleNotification)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.rpc = rpc
+	defer rpc.Close()
+
+	if err := p.switchGroupsToStream(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if prev := p.prevStreams["g1"]; prev != "default" {
+		t.Errorf("expected previous stream of g1 to be saved, got %q", prev)
+	}
+
+	rooms := p.Rooms()
+	if len(rooms) != 1 || rooms[0].ID != "kitchen" || rooms[0].Name != "kitchen-pi" || rooms[0].Volume != 40 {
+		t.Fatalf("unexpected rooms: %+v", rooms)
+	}
+
+	changed := false
+	p.OnRoomsChange(func() { changed = true })
+	if err := p.SetRoomVolume("kitchen", 75); err != nil {
+		t.Fatal(err)
+	}
+	if vol := p.Rooms()[0].Volume; vol != 75 || !changed {
+		t.Errorf("expected room volume 75 and change callback, got %d, %v", vol, changed)
+	}
+
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	want := []string{"Server.GetStatus", "Group.SetStream", "Client.SetVolume"}
+	if len(srv.calls) != len(want) {
+		t.Fatalf("expected calls %v, got %v", want, srv.calls)
+	}
+	for i := range want {
+		if srv.calls[i] != want[i] {
+			t.Errorf("expected calls %v, got %v", want, srv.calls)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package snapcast
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dweymouth/supersonic/backend/logging"
+	"github.com/dweymouth/supersonic/backend/player"
+	"github.com/dweymouth/supersonic/backend/player/mpv"
+)
+
+var logger = logging.For(logging.Player)
+
+const (
+	requestTimeout = 5 * time.Second
+
+	// bounds of the delay between attempts to reconnect to a TCP stream source
+	minRedialDelay = 1 * time.Second
+	maxRedialDelay = 30 * time.Second
+)
+
+var (
+	_ player.URLPlayer       = (*SnapcastPlayer)(nil)
+	_ player.MultiRoomPlayer = (*SnapcastPlayer)(nil)
+)
+
+// SnapcastPlayer plays to the rooms of a Snapcast multi-room audio server.
+// Audio is decoded by a dedicated mpv instance whose raw PCM output is
+// written to one of the server's stream sources, and the server's groups
+// are switched to that stream while the player is in use.
+type SnapcastPlayer struct {
+	*mpv.Player
+
+	server Server
+	rpc    *rpcClient
+
+	// for TCP stream sources, mpv writes to this FIFO and we relay it
+	fifoPath string
+	fifo     *os.File
+
+	lock          sync.Mutex
+	groups        []group
+	prevStreams   map[string]string // group ID -> stream ID before we switched it
+	onRoomsChange []func()
+}
+
+// NewSnapcastPlayer connects to the server and switches its groups to
+// the server's chosen stream. initMPV is called to initialize the
+// player's mpv instance once its audio output has been configured.
+func NewSnapcastPlayer(server Server, initMPV func(*mpv.Player) error) (*SnapcastPlayer, error) {
+	p := &SnapcastPlayer{server: server, prevStreams: make(map[string]string)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	rpc, err := dialRPC(ctx, server.Addr, p.handleNotification)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", server.Name, err)
+	}
+	p.rpc = rpc
+
+	f := server.Stream.SampleFormat()
+	pcmPath, err := p.setupPCMOutput(f)
+	if err != nil {
+		p.Destroy()
+		return nil, err
+	}
+	// mpv has no packed 24-bit output format, so 24-bit streams are
+	// fed from 32-bit output converted by relayPCM
+	mpvBits := f.Bits
+	if f.Bits == 24 {
+		mpvBits = 32
+	}
+	p.Player = mpv.New()
+	p.Player.SetAudioOutput("pcm", map[string]string{
+		"ao-pcm-file":       pcmPath,
+		"ao-pcm-waveheader": "no",
+		"audio-samplerate":  strconv.Itoa(f.Rate),
+		"audio-format":      fmt.Sprintf("s%d", mpvBits),
+		"audio-channels":    strconv.Itoa(f.Channels),
+	})
+	if err := initMPV(p.Player); err != nil {
+		p.Destroy()
+		return nil, err
+	}
+
+	if err := p.switchGroupsToStream(ctx); err != nil {
+		p.Destroy()
+		return nil, err
+	}
+	return p, nil
+}
+
+// setupPCMOutput returns the path mpv should write PCM audio to.
+func (p *SnapcastPlayer) setupPCMOutput(f SampleFormat) (string, error) {
+	stream := p.server.Stream
+	if stream.URI.Scheme == "pipe" {
+		if f.Bits == 24 {
+			return "", errors.New("snapcast: 24-bit pipe streams are not supported; use 16 or 32 bits")
+		}
+		// server is on this computer and reads from the FIFO itself
+		return stream.URI.Path, nil
+	}
+
+	p.fifoPath = filepath.Join(os.TempDir(), fmt.Sprintf("supersonic-snapcast-%d.pcm", os.Getpid()))
+	os.Remove(p.fifoPath)
+	if err := makeFIFO(p.fifoPath); err != nil {
+		return "", err
+	}
+	// opened read-write so that neither this open nor mpv's blocks,
+	// and reads don't see EOF each time mpv closes the audio output
+	fifo, err := os.OpenFile(p.fifoPath, os.O_RDWR, 0)
+	if err != nil {
+		return "", err
+	}
+	p.fifo = fifo
+	host, _, _ := net.SplitHostPort(p.server.Addr)
+	go p.relayPCM(stream.tcpAddr(host), f.Bits == 24)
+	return p.fifoPath, nil
+}
+
+// relayPCM copies mpv's PCM output from the FIFO to the server's TCP stream source.
+// Writes block while the server isn't reading, which paces mpv's decoding.
+// While the stream source can't be reached, audio is discarded and
+// reconnection is attempted with an increasing delay.
+// If to24Bit is set, mpv's 32-bit samples are converted to snapcast's
+// 24-bit format, which is stored in 4 bytes with the low 3 bytes used.
+func (p *SnapcastPlayer) relayPCM(addr string, to24Bit bool) {
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	var nextDial time.Time
+	redialDelay := minRedialDelay
+	buf := make([]byte, 16*1024)
+	var carry [4]byte // bytes of an incomplete sample from the last read
+	var nCarry int
+	for {
+		copy(buf, carry[:nCarry])
+		n, err := p.fifo.Read(buf[nCarry:])
+		if err != nil {
+			return // FIFO closed on Destroy
+		}
+		out := buf[:nCarry+n]
+		if to24Bit {
+			whole := len(out) - len(out)%4
+			nCarry = copy(carry[:], out[whole:])
+			out = out[:whole]
+			convertS32ToS24(out)
+		}
+		if conn == nil {
+			if time.Now().Before(nextDial) {
+				continue // drop audio rather than stall mpv
+			}
+			if conn, err = net.DialTimeout("tcp", addr, requestTimeout); err != nil {
+				logger.Error("failed to connect to snapcast stream source", "err", err)
+				conn = nil
+				nextDial = time.Now().Add(redialDelay)
+				redialDelay = min(redialDelay*2, maxRedialDelay)
+				continue
+			}
+			redialDelay = minRedialDelay
+		}
+		conn.SetWriteDeadline(time.Now().Add(requestTimeout))
+		if _, err := conn.Write(out); err != nil {
+			logger.Error("snapcast stream source write error", "err", err)
+			conn.Close()
+			conn = nil
+		}
+	}
+}
+
+// convertS32ToS24 converts little-endian 32-bit samples in place to
+// 24-bit samples in 4-byte little-endian containers.
+func convertS32ToS24(b []byte) {
+	for i := 0; i+4 <= len(b); i += 4 {
+		s := int32(binary.LittleEndian.Uint32(b[i:])) >> 8
+		binary.LittleEndian.PutUint32(b[i:], uint32(s))
+	}
+}
+
+func (p *SnapcastPlayer) switchGroupsToStream(ctx context.Context) error {
+	if err := p.refreshStatus(ctx); err != nil {
+		return err
+	}
+	p.lock.Lock()
+	groups := p.groups
+	p.lock.Unlock()
+
+	streamID := p.server.Stream.ID
+	for _, g := range groups {
+		if g.StreamID == streamID {
+			continue
+		}
+		if err := p.rpc.call(ctx, "Group.SetStream", map[string]any{
+			"id":        g.ID,
+			"stream_id": streamID,
+		}, nil); err != nil {
+			return err
+		}
+		p.lock.Lock()
+		p.prevStreams[g.ID] = g.StreamID
+		p.lock.Unlock()
+	}
+	return nil
+}
+
+// restores the groups that we switched to our stream to their previous stream,
+// unless they have been switched to another stream in the meantime
+func (p *SnapcastPlayer) restoreGroupStreams(ctx context.Context) {
+	p.lock.Lock()
+	var restore []group
+	for _, g := range p.groups {
+		if prev, ok := p.prevStreams[g.ID]; ok && g.StreamID == p.server.Stream.ID {
+			restore = append(restore, group{ID: g.ID, StreamID: prev})
+		}
+	}
+	p.lock.Unlock()
+
+	for _, g := range restore {
+		p.rpc.call(ctx, "Group.SetStream", map[string]any{
+			"id":        g.ID,
+			"stream_id": g.StreamID,
+		}, nil)
+	}
+}
+
+func (p *SnapcastPlayer) refreshStatus(ctx context.Context) error {
+	var status serverStatus
+	if err := p.rpc.call(ctx, "Server.GetStatus", nil, &status); err != nil {
+		return err
+	}
+	p.lock.Lock()
+	p.groups = status.Server.Groups
+	p.lock.Unlock()
+	return nil
+}
+
+// Rooms returns the connected clients of the server.
+func (p *SnapcastPlayer) Rooms() []player.Room {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	var rooms []player.Room
+	for _, g := range p.groups {
+		for _, c := range g.Clients {
+			if !c.Connected {
+				continue
+			}
+			name := c.Config.Name
+			if name == "" {
+				name = c.Host.Name
+			}
+			rooms = append(rooms, player.Room{
+				ID:     c.ID,
+				Name:   name,
+				Volume: c.Config.Volume.Percent,
+				Muted:  c.Config.Volume.Muted,
+			})
+		}
+	}
+	return rooms
+}
+
+func (p *SnapcastPlayer) SetRoomVolume(roomID string, vol int) error {
+	return p.setClientVolume(roomID, func(v *clientVolume) { v.Percent = vol })
+}
+
+func (p *SnapcastPlayer) SetRoomMuted(roomID string, muted bool) error {
+	return p.setClientVolume(roomID, func(v *clientVolume) { v.Muted = muted })
+}
+
+func (p *SnapcastPlayer) setClientVolume(clientID string, update func(*clientVolume)) error {
+	p.lock.Lock()
+	c := p.findClient(clientID)
+	if c == nil {
+		p.lock.Unlock()
+		return fmt.Errorf("snapcast: unknown client %s", clientID)
+	}
+	vol := c.Config.Volume
+	update(&vol)
+	p.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	if err := p.rpc.call(ctx, "Client.SetVolume", map[string]any{
+		"id":     clientID,
+		"volume": vol,
+	}, nil); err != nil {
+		return err
+	}
+	p.updateClientVolume(clientID, vol)
+	return nil
+}
+
+func (p *SnapcastPlayer) updateClientVolume(clientID string, vol clientVolume) {
+	p.lock.Lock()
+	if c := p.findClient(clientID); c != nil {
+		c.Config.Volume = vol
+	}
+	p.lock.Unlock()
+	p.invokeOnRoomsChange()
+}
+
+// must be called with lock held
+func (p *SnapcastPlayer) findClient(clientID string) *client {
+	for i := range p.groups {
+		for j := range p.groups[i].Clients {
+			if c := &p.groups[i].Clients[j]; c.ID == clientID {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+func (p *SnapcastPlayer) OnRoomsChange(cb func()) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.onRoomsChange = append(p.onRoomsChange, cb)
+}
+
+func (p *SnapcastPlayer) invokeOnRoomsChange() {
+	p.lock.Lock()
+	callbacks := p.onRoomsChange
+	p.lock.Unlock()
+	for _, cb := range callbacks {
+		cb()
+	}
+}
+
+// invoked on the RPC read goroutine
+func (p *SnapcastPlayer) handleNotification(method string, params json.RawMessage) {
+	switch method {
+	case "Client.OnVolumeChanged":
+		var n struct {
+			ID     string       `json:"id"`
+			Volume clientVolume `json:"volume"`
+		}
+		if json.Unmarshal(params, &n) == nil {
+			p.updateClientVolume(n.ID, n.Volume)
+		}
+	case "Client.OnConnect", "Client.OnDisconnect", "Client.OnNameChanged",
+		"Group.OnStreamChanged", "Server.OnUpdate":
+		// can't make a request from the read goroutine
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+			defer cancel()
+			if p.refreshStatus(ctx) == nil {
+				p.invokeOnRoomsChange()
+			}
+		}()
+	}
+}
+
+func (p *SnapcastPlayer) Destroy() {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	if p.Player != nil {
+		// stop mpv before closing the FIFO it writes to
+		p.Player.Destroy()
+	}
+	if p.rpc != nil {
+		p.restoreGroupStreams(ctx)
+		p.rpc.Close()
+	}
+	if p.fifo != nil {
+		p.fifo.Close()
+	}
+	if p.fifoPath != "" {
+		os.Remove(p.fifoPath)
+	}
+}
@@ -0,0 +1,210 @@
+package backend
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+)
+
+// RadioParams describes a generated radio station.
+// Candidate tracks are drawn from every seed and mixed together,
+// then filtered by the constraints in the embedded RadioConfig.
+type RadioParams struct {
+	// Seed tracks - draws from the song radio of each track.
+	TrackIDs []string
+	// Seed artists - draws from similar tracks of each artist.
+	ArtistIDs []string
+	// Seed genres - draws random tracks from each genre.
+	Genres []string
+
+	RadioConfig
+}
+
+func (r RadioParams) HasSeeds() bool {
+	return len(r.TrackIDs)+len(r.ArtistIDs)+len(r.Genres) > 0
+}
+
+// RadioEngine generates batches of tracks for a radio station,
+// remembering recently played artists between batches so that
+// the artist separation constraint holds across batch boundaries.
+type RadioEngine struct {
+	Params RadioParams
+
+	mp            mediaprovider.MediaProvider
+	recentArtists []string // most recent last
+
+	// if set, seeds are tried in order (tracks, artists, genres)
+	// and the first yielding any tracks is used, instead of mixing all
+	fallbackSeeds bool
+}
+
+func NewRadioEngine(mp mediaprovider.MediaProvider, params RadioParams) *RadioEngine {
+	return &RadioEngine{mp: mp, Params: params}
+}
+
+// NewAutoplayEngine returns a RadioEngine for autoplay, which draws from
+// similar tracks of the seed artists, falling back to random tracks from
+// the seed genres, and then to random tracks from the whole library.
+func NewAutoplayEngine(mp mediaprovider.MediaProvider, params RadioParams) *RadioEngine {
+	return &RadioEngine{mp: mp, Params: params, fallbackSeeds: true}
+}
+
+// SeedHistory records the artists of already queued tracks,
+// in play order, for the purposes of artist separation.
+func (r *RadioEngine) SeedHistory(items []mediaprovider.MediaItem) {
+	for _, item := range items {
+		if tr, ok := item.(*mediaprovider.Track); ok {
+			r.pushArtist(tr)
+		}
+	}
+}
+
+// NextBatch fetches up to count tracks for the radio station.
+// If include is non-nil, only tracks for which it returns true are considered.
+// Falls back to random tracks from the whole library if no seed yields
+// any tracks that satisfy the constraints.
+func (r *RadioEngine) NextBatch(count int, include func(*mediaprovider.Track) bool) []*mediaprovider.Track {
+	filter := func(tracks []*mediaprovider.Track) []*mediaprovider.Track {
+		return slices.DeleteFunc(tracks, func(t *mediaprovider.Track) bool {
+			return !r.Params.Matches(t) || (include != nil && !include(t))
+		})
+	}
+
+	// a radio mixes all sources, while autoplay takes the tracks of the
+	// first source that has any left after filtering and artist separation
+	var sources [][]*mediaprovider.Track
+	var fallbackTracks []*mediaprovider.Track
+	addSource := func(tracks []*mediaprovider.Track) bool {
+		if !r.fallbackSeeds {
+			sources = append(sources, filter(tracks))
+			return false
+		}
+		fallbackTracks = r.spreadArtists(filter(tracks))
+		return len(fallbackTracks) > 0
+	}
+	fetchSources := func() {
+		for _, id := range r.Params.TrackIDs {
+			tracks, err := r.mp.GetSongRadio(id, count)
+			if err != nil {
+				autoplayLog.Error("failed to get song radio", "err", err)
+			}
+			if addSource(tracks) {
+				return
+			}
+		}
+		for _, id := range r.Params.ArtistIDs {
+			tracks, err := r.mp.GetSimilarTracks(id, count)
+			if err != nil {
+				autoplayLog.Error("failed to get similar tracks", "err", err)
+			}
+			if addSource(tracks) {
+				return
+			}
+		}
+		for _, g := range r.Params.Genres {
+			tracks, err := r.mp.GetRandomTracks(g, count)
+			if err != nil {
+				autoplayLog.Error("failed to get tracks by genre", "err", err)
+			}
+			if addSource(tracks) {
+				return
+			}
+		}
+	}
+	fetchSources()
+
+	tracks := fallbackTracks
+	if !r.fallbackSeeds {
+		tracks = r.spreadArtists(interleaveTracks(sources))
+	}
+	if len(tracks) == 0 {
+		random, err := r.mp.GetRandomTracks("", count)
+		if err != nil {
+			autoplayLog.Error("failed to get random tracks", "err", err)
+		}
+		tracks = r.spreadArtists(filter(random))
+	}
+	if len(tracks) > count {
+		tracks = tracks[:count]
+	}
+	return tracks
+}
+
+// Matches returns whether the track satisfies the decade, BPM, and rating constraints.
+// Tracks missing the metadata for an active constraint do not match.
+func (c RadioConfig) Matches(t *mediaprovider.Track) bool {
+	if len(c.Decades) > 0 && (t.Year == 0 || !slices.Contains(c.Decades, t.Year/10*10)) {
+		return false
+	}
+	if c.MinBPM > 0 && t.BPM < c.MinBPM {
+		return false
+	}
+	if c.MaxBPM > 0 && (t.BPM == 0 || t.BPM > c.MaxBPM) {
+		return false
+	}
+	return t.Rating >= c.MinRating
+}
+
+// reorders tracks so that no artist repeats within ArtistSeparation tracks,
+// dropping tracks that cannot be placed without violating the constraint
+func (r *RadioEngine) spreadArtists(tracks []*mediaprovider.Track) []*mediaprovider.Track {
+	result := make([]*mediaprovider.Track, 0, len(tracks))
+	for len(tracks) > 0 {
+		idx := slices.IndexFunc(tracks, func(t *mediaprovider.Track) bool {
+			key := radioArtistKey(t)
+			return key == "" || !slices.Contains(r.recentArtists, key)
+		})
+		if idx < 0 {
+			break
+		}
+		result = append(result, tracks[idx])
+		r.pushArtist(tracks[idx])
+		tracks = slices.Delete(tracks, idx, idx+1)
+	}
+	return result
+}
+
+func (r *RadioEngine) pushArtist(t *mediaprovider.Track) {
+	n := r.Params.ArtistSeparation
+	key := radioArtistKey(t)
+	if n <= 0 || key == "" {
+		return
+	}
+	r.recentArtists = append(r.recentArtists, key)
+	if l := len(r.recentArtists); l > n {
+		r.recentArtists = r.recentArtists[l-n:]
+	}
+}
+
+func radioArtistKey(t *mediaprovider.Track) string {
+	if len(t.ArtistIDs) > 0 && t.ArtistIDs[0] != "" {
+		return t.ArtistIDs[0]
+	}
+	if len(t.ArtistNames) > 0 {
+		return strings.ToLower(t.ArtistNames[0])
+	}
+	return ""
+}
+
+// round-robins between the source lists, skipping duplicate tracks
+func interleaveTracks(sources [][]*mediaprovider.Track) []*mediaprovider.Track {
+	var result []*mediaprovider.Track
+	seen := make(map[string]bool)
+	for i := 0; ; i++ {
+		added := false
+		for _, src := range sources {
+			if i >= len(src) {
+				continue
+			}
+			added = true
+			if !seen[src[i].ID] {
+				seen[src[i].ID] = true
+				result = append(result, src[i])
+			}
+		}
+		if !added {
+			return result
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package backend
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+)
+
+func TestRadioConfigMatches(t *testing.T) {
+	cfg := RadioConfig{Decades: []int{1990}, MinBPM: 100, MaxBPM: 130, MinRating: 3}
+	tests := []struct {
+		name  string
+		track mediaprovider.Track
+		want  bool
+	}{
+		{"matching", mediaprovider.Track{Year: 1994, BPM: 120, Rating: 4}, true},
+		{"wrong decade", mediaprovider.Track{Year: 2001, BPM: 120, Rating: 4}, false},
+		{"unknown year", mediaprovider.Track{BPM: 120, Rating: 4}, false},
+		{"too fast", mediaprovider.Track{Year: 1994, BPM: 140, Rating: 4}, false},
+		{"unknown BPM", mediaprovider.Track{Year: 1994, Rating: 4}, false},
+		{"low rating", mediaprovider.Track{Year: 1994, BPM: 120, Rating: 2}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cfg.Matches(&tt.track); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRadioSpreadArtists(t *testing.T) {
+	tr := func(id, artistID string) *mediaprovider.Track {
+		return &mediaprovider.Track{ID: id, ArtistIDs: []string{artistID}}
+	}
+	r := NewRadioEngine(nil, RadioParams{RadioConfig: RadioConfig{ArtistSeparation: 2}})
+	r.SeedHistory([]mediaprovider.MediaItem{tr("0", "c")})
+
+	got := r.spreadArtists([]*mediaprovider.Track{
+		tr("1", "a"), tr("2", "a"), tr("3", "b"), tr("4", "c"), tr("5", "a"),
+	})
+	var ids string
+	for _, t := range got {
+		ids += t.ID
+	}
+	// "c" was recently played so must wait for "a" and "b";
+	// one of the three "a" tracks can't be placed without repeating too soon
+	if want := "1342"; ids != want {
+		t.Errorf("spreadArtists order = %q, want %q", ids, want)
+	}
+}
+
+type radioSourceProvider struct {
+	mediaprovider.MediaProvider
+	similar map[string][]*mediaprovider.Track // by artist ID
+	random  map[string][]*mediaprovider.Track // by genre
+}
+
+func (r *radioSourceProvider) GetSimilarTracks(artistID string, _ int) ([]*mediaprovider.Track, error) {
+	return slices.Clone(r.similar[artistID]), nil
+}
+
+func (r *radioSourceProvider) GetRandomTracks(genre string, _ int) ([]*mediaprovider.Track, error) {
+	return slices.Clone(r.random[genre]), nil
+}
+
+func TestAutoplayEngineFallback(t *testing.T) {
+	tr := func(id string) *mediaprovider.Track {
+		return &mediaprovider.Track{ID: id, ArtistIDs: []string{id}}
+	}
+	mp := &radioSourceProvider{
+		similar: map[string][]*mediaprovider.Track{"a": {tr("s1"), tr("s2")}},
+		random: map[string][]*mediaprovider.Track{
+			"rock": {tr("g1"), tr("g2")},
+			"":     {tr("r1"), tr("r2")},
+		},
+	}
+	ids := func(tracks []*mediaprovider.Track) string {
+		var s string
+		for _, t := range tracks {
+			s += t.ID
+		}
+		return s
+	}
+
+	params := RadioParams{ArtistIDs: []string{"a"}, Genres: []string{"rock"}}
+	if got := ids(NewAutoplayEngine(mp, params).NextBatch(10, nil)); got != "s1s2" {
+		t.Errorf("expected only similar tracks, got %q", got)
+	}
+	// a custom radio mixes all seeds
+	if got := ids(NewRadioEngine(mp, params).NextBatch(10, nil)); got != "s1g1s2g2" {
+		t.Errorf("expected mixed seeds, got %q", got)
+	}
+
+	noSimilar := func(t *mediaprovider.Track) bool { return t.ID[0] != 's' }
+	if got := ids(NewAutoplayEngine(mp, params).NextBatch(10, noSimilar)); got != "g1g2" {
+		t.Errorf("expected fallback to genre tracks, got %q", got)
+	}
+	// similar tracks whose artists were just played fall back to the genre
+	e := NewAutoplayEngine(mp, RadioParams{ArtistIDs: []string{"a"}, Genres: []string{"rock"}, RadioConfig: RadioConfig{ArtistSeparation: 5}})
+	e.SeedHistory([]mediaprovider.MediaItem{tr("s1"), tr("s2")})
+	if got := ids(e.NextBatch(10, nil)); got != "g1g2" {
+		t.Errorf("expected fallback to genre tracks after artist separation, got %q", got)
+	}
+	params.Genres = nil
+	if got := ids(NewAutoplayEngine(mp, params).NextBatch(10, noSimilar)); got != "r1r2" {
+		t.Errorf("expected fallback to random tracks, got %q", got)
+	}
+}
@@ -3,7 +3,6 @@ package backend
 import (
 	"encoding/json"
 	"errors"
-	"log"
 	"os"
 
 	"github.com/dweymouth/supersonic/backend/mediaprovider"
@@ -75,7 +74,7 @@ func LoadPlayQueue(filepath string, sm *ServerManager, loadFromServer bool) (*Sa
 				TimePos:    float64(queue.TimePos),
 			}, nil
 		} else {
-			log.Printf("error loading queue from server: %v", err.Error())
+			playbackLog.Error("error loading queue from server", "err", err)
 		}
 	}
 
@@ -0,0 +1,419 @@
+package backend
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/deluan/sanitize"
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+	"github.com/dweymouth/supersonic/backend/mediaprovider/helpers"
+)
+
+const (
+	searchIndexRefreshInterval = 10 * time.Minute
+	// max number of recently added albums to check for each refresh
+	searchIndexMaxRecentAlbums = 200
+	// longer words are only matched exactly or by prefix
+	maxFuzzyWordLen = 32
+)
+
+// Scores for how well a query term matches a word of an indexed item.
+// Matches of the item's name score higher than of its artist or album.
+const (
+	searchScoreExact  = 4
+	searchScorePrefix = 3
+	searchScoreFuzzy  = 2
+	searchScoreOther  = 1
+)
+
+// SearchIndex is an in-memory full-text index of the artists, albums,
+// tracks and playlists of the connected server, for instant search
+// with prefix and typo-tolerant (edit distance) matching.
+// It is built in the background when a server is connected,
+// and refreshed periodically from the recently added albums.
+type SearchIndex struct {
+	mu      sync.RWMutex
+	entries []*searchIndexEntry
+	indexed map[string]bool // IDs of the indexed albums and artists
+	ready   bool
+
+	cancel context.CancelFunc
+}
+
+type searchIndexEntry struct {
+	result *mediaprovider.SearchResult
+	// accent-folded, lowercase words of the item's name
+	name []string
+	// and of its artist and album names
+	other []string
+}
+
+type scoredSearchResult struct {
+	entry *searchIndexEntry
+	score int
+}
+
+// NewSearchIndex returns a new SearchIndex, which indexes each server
+// connected to by the ServerManager until ctx is canceled.
+func NewSearchIndex(ctx context.Context, s *ServerManager) *SearchIndex {
+	i := &SearchIndex{}
+	s.OnServerConnected(func(_ *ServerConfig) {
+		i.start(ctx, s.Server)
+	})
+	s.OnLogout(i.stop)
+	return i
+}
+
+// Ready returns true once the index of the current server has been built.
+func (i *SearchIndex) Ready() bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.ready
+}
+
+// Search returns the indexed items matching the query, best matches first.
+// The query can contain field filters (see helpers.SearchQuery).
+// Each term must match the start of, or be within a small edit distance of,
+// a word of the item's name or its artist or album name.
+func (i *SearchIndex) Search(query string, maxResults int) []*mediaprovider.SearchResult {
+	q := helpers.ParseSearchQuery(query)
+	terms := make([][]string, len(q.Terms))
+	for j, t := range q.Terms {
+		terms[j] = searchIndexWords(t)
+	}
+
+	i.mu.RLock()
+	var matches []scoredSearchResult
+	for _, e := range i.entries {
+		if !q.IncludesType(e.result.Type) {
+			continue
+		}
+		score, ok := e.match(terms)
+		if ok && q.Matches(e.result) {
+			matches = append(matches, scoredSearchResult{entry: e, score: score})
+		}
+	}
+	i.mu.RUnlock()
+
+	slices.SortStableFunc(matches, func(a, b scoredSearchResult) int {
+		if a.score != b.score {
+			return b.score - a.score
+		}
+		// prefer shorter names, which the query matches more fully
+		return len(a.entry.name) - len(b.entry.name)
+	})
+	results := make([]*mediaprovider.SearchResult, 0, min(len(matches), maxResults))
+	for _, m := range matches[:min(len(matches), maxResults)] {
+		results = append(results, m.entry.result)
+	}
+	return results
+}
+
+// match returns the score of the entry for the query terms, and false
+// if not all terms match. Phrases (multi-word terms) must match
+// consecutive words.
+func (e *searchIndexEntry) match(terms [][]string) (int, bool) {
+	total := 0
+	for _, phrase := range terms {
+		score := max(matchPhrase(phrase, e.name, searchScoreExact, searchScorePrefix, searchScoreFuzzy),
+			matchPhrase(phrase, e.other, searchScoreOther, searchScoreOther, searchScoreOther))
+		if score == 0 {
+			return 0, false
+		}
+		total += score
+	}
+	return total, true
+}
+
+func matchPhrase(phrase, words []string, exact, prefix, fuzzy int) int {
+	best := 0
+	for start := 0; start+len(phrase) <= len(words); start++ {
+		score := 0
+		for k, term := range phrase {
+			s := matchWord(term, words[start+k], exact, prefix, fuzzy)
+			if s == 0 {
+				score = 0
+				break
+			}
+			score += s
+		}
+		best = max(best, score)
+	}
+	return best
+}
+
+func matchWord(term, word string, exact, prefix, fuzzy int) int {
+	switch {
+	case term == word:
+		return exact
+	case strings.HasPrefix(word, term):
+		return prefix
+	}
+	maxDist := maxEditDistance(term)
+	if maxDist == 0 {
+		return 0
+	}
+	// compare to the whole word, and to its start for partially typed words
+	if editDistance(term, word, maxDist) <= maxDist {
+		return fuzzy
+	}
+	if len(word) > len(term) && editDistance(term, word[:len(term)], maxDist) <= maxDist {
+		return fuzzy
+	}
+	return 0
+}
+
+// maxEditDistance returns the number of typos tolerated in a term.
+func maxEditDistance(term string) int {
+	switch n := len(term); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance returns the optimal string alignment distance between a and b
+// (Levenshtein distance, also counting swapped adjacent letters as one edit),
+// or maxDist+1 if it exceeds maxDist.
+func editDistance(a, b string, maxDist int) int {
+	if d := len(a) - len(b); d > maxDist || -d > maxDist || len(b) > maxFuzzyWordLen {
+		return maxDist + 1
+	}
+	var rows [3][maxFuzzyWordLen + 1]int
+	prev2, prev, cur := rows[0][:len(b)+1], rows[1][:len(b)+1], rows[2][:len(b)+1]
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > maxDist {
+			return maxDist + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
+
+// searchIndexWords returns the accent-folded, lowercase words of s.
+func searchIndexWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(sanitize.Accents(s)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+func (i *SearchIndex) start(ctx context.Context, mp mediaprovider.MediaProvider) {
+	i.stop()
+	ctx, cancel := context.WithCancel(ctx)
+	i.mu.Lock()
+	i.cancel = cancel
+	i.mu.Unlock()
+	go func() {
+		started := time.Now()
+		entries, indexed := buildSearchIndex(ctx, mp)
+		i.mu.Lock()
+		// stop cancels ctx while holding the lock
+		if ctx.Err() != nil {
+			i.mu.Unlock()
+			return
+		}
+		i.entries, i.indexed, i.ready = entries, indexed, true
+		i.mu.Unlock()
+		searchLog.Info("indexed items for search", "count", len(entries), "elapsed", time.Since(started).Round(time.Millisecond))
+
+		t := time.NewTicker(searchIndexRefreshInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				i.refresh(ctx, mp)
+			}
+		}
+	}()
+}
+
+func (i *SearchIndex) stop() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.cancel != nil {
+		i.cancel()
+		i.cancel = nil
+	}
+	i.entries, i.indexed, i.ready = nil, nil, false
+}
+
+// refresh adds the recently added albums, with their tracks and artists,
+// and re-indexes the playlists, which are few and change often.
+func (i *SearchIndex) refresh(ctx context.Context, mp mediaprovider.MediaProvider) {
+	i.mu.RLock()
+	indexed := i.indexed
+	i.mu.RUnlock()
+
+	var added []*searchIndexEntry
+	var newIDs []string
+	iter := mp.IterateAlbums(mediaprovider.AlbumSortRecentlyAdded,
+		mediaprovider.NewAlbumFilter(mediaprovider.AlbumFilterOptions{}))
+	for n := 0; n < searchIndexMaxRecentAlbums; n++ {
+		al := iter.Next()
+		// albums are sorted by date added, so stop at the first one already indexed
+		if al == nil || indexed[al.ID] || ctx.Err() != nil {
+			break
+		}
+		awt, err := mp.GetAlbum(al.ID)
+		if err != nil {
+			searchLog.Error("failed to index album", "album", al.ID, "err", err)
+			continue
+		}
+		added = append(added, albumSearchIndexEntry(&awt.Album))
+		newIDs = append(newIDs, al.ID)
+		for _, tr := range awt.Tracks {
+			added = append(added, trackSearchIndexEntry(tr))
+		}
+		for k, id := range al.ArtistIDs {
+			if k < len(al.ArtistNames) && !indexed[id] && !slices.Contains(newIDs, id) {
+				ar := &mediaprovider.Artist{ID: id, CoverArtID: id, Name: al.ArtistNames[k]}
+				added = append(added, artistSearchIndexEntry(ar))
+				newIDs = append(newIDs, id)
+			}
+		}
+	}
+	playlists, err := mp.GetPlaylists()
+	if err != nil && len(added) == 0 {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if ctx.Err() != nil {
+		return
+	}
+	entries := slices.Clone(i.entries)
+	if err == nil {
+		entries = slices.DeleteFunc(entries, func(e *searchIndexEntry) bool {
+			return e.result.Type == mediaprovider.ContentTypePlaylist
+		})
+		for _, pl := range playlists {
+			entries = append(entries, playlistSearchIndexEntry(pl))
+		}
+	}
+	i.entries = append(entries, added...)
+	for _, id := range newIDs {
+		i.indexed[id] = true
+	}
+	if len(added) > 0 {
+		searchLog.Info("added items to the search index", "count", len(added))
+	}
+}
+
+func buildSearchIndex(ctx context.Context, mp mediaprovider.MediaProvider) ([]*searchIndexEntry, map[string]bool) {
+	var entries []*searchIndexEntry
+	indexed := make(map[string]bool)
+
+	artistSort := ""
+	if sorts := mp.ArtistSortOrders(); len(sorts) > 0 {
+		artistSort = sorts[0]
+	}
+	artists := mp.IterateArtists(artistSort, mediaprovider.NewArtistFilter(mediaprovider.ArtistFilterOptions{}))
+	for ar := artists.Next(); ar != nil && ctx.Err() == nil; ar = artists.Next() {
+		entries = append(entries, artistSearchIndexEntry(ar))
+		indexed[ar.ID] = true
+	}
+	albums := mp.IterateAlbums(mediaprovider.AlbumSortRecentlyAdded,
+		mediaprovider.NewAlbumFilter(mediaprovider.AlbumFilterOptions{}))
+	for al := albums.Next(); al != nil && ctx.Err() == nil; al = albums.Next() {
+		entries = append(entries, albumSearchIndexEntry(al))
+		indexed[al.ID] = true
+	}
+	tracks := mp.IterateTracks("")
+	for tr := tracks.Next(); tr != nil && ctx.Err() == nil; tr = tracks.Next() {
+		entries = append(entries, trackSearchIndexEntry(tr))
+	}
+	if playlists, err := mp.GetPlaylists(); err == nil {
+		for _, pl := range playlists {
+			entries = append(entries, playlistSearchIndexEntry(pl))
+		}
+	}
+	return entries, indexed
+}
+
+func artistSearchIndexEntry(ar *mediaprovider.Artist) *searchIndexEntry {
+	return &searchIndexEntry{
+		result: &mediaprovider.SearchResult{
+			Type:    mediaprovider.ContentTypeArtist,
+			ID:      ar.ID,
+			CoverID: ar.CoverArtID,
+			Name:    ar.Name,
+			Size:    ar.AlbumCount,
+			Item:    ar,
+		},
+		name: searchIndexWords(ar.Name),
+	}
+}
+
+func albumSearchIndexEntry(al *mediaprovider.Album) *searchIndexEntry {
+	artists := strings.Join(al.ArtistNames, ", ")
+	return &searchIndexEntry{
+		result: &mediaprovider.SearchResult{
+			Type:       mediaprovider.ContentTypeAlbum,
+			ID:         al.ID,
+			CoverID:    al.CoverArtID,
+			Name:       al.Name,
+			ArtistName: artists,
+			Size:       al.TrackCount,
+			Item:       al,
+		},
+		name:  searchIndexWords(al.Name),
+		other: searchIndexWords(artists),
+	}
+}
+
+func trackSearchIndexEntry(tr *mediaprovider.Track) *searchIndexEntry {
+	artists := strings.Join(tr.ArtistNames, ", ")
+	return &searchIndexEntry{
+		result: &mediaprovider.SearchResult{
+			Type:       mediaprovider.ContentTypeTrack,
+			ID:         tr.ID,
+			CoverID:    tr.CoverArtID,
+			Name:       tr.Title,
+			ArtistName: artists,
+			Size:       int(tr.Duration.Seconds()),
+			Item:       tr,
+		},
+		name:  searchIndexWords(tr.Title),
+		other: searchIndexWords(artists + " " + tr.Album),
+	}
+}
+
+func playlistSearchIndexEntry(pl *mediaprovider.Playlist) *searchIndexEntry {
+	return &searchIndexEntry{
+		result: &mediaprovider.SearchResult{
+			Type:    mediaprovider.ContentTypePlaylist,
+			ID:      pl.ID,
+			CoverID: pl.CoverArtID,
+			Name:    pl.Name,
+			Size:    pl.TrackCount,
+			Item:    pl,
+		},
+		name: searchIndexWords(pl.Name),
+	}
+}
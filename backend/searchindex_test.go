@@ -0,0 +1,74 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+)
+
+func TestEditDistance(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"beatles", "beatles", 0},
+		{"beatels", "beatles", 1}, // transposition
+		{"beatle", "beatles", 1},
+		{"beetles", "beatles", 1},
+		{"bxatxxs", "beatles", 3}, // exceeds maxDist
+	} {
+		if d := editDistance(tt.a, tt.b, 2); d != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, d, tt.want)
+		}
+	}
+}
+
+func TestSearchIndex_Search(t *testing.T) {
+	year := 1969
+	i := &SearchIndex{ready: true}
+	i.entries = []*searchIndexEntry{
+		artistSearchIndexEntry(&mediaprovider.Artist{ID: "ar1", Name: "The Beatles"}),
+		albumSearchIndexEntry(&mediaprovider.Album{ID: "al1", Name: "Abbey Road", ArtistNames: []string{"The Beatles"},
+			Date: mediaprovider.ItemDate{Year: &year}}),
+		trackSearchIndexEntry(&mediaprovider.Track{ID: "tr1", Title: "Come Together", ArtistNames: []string{"The Beatles"},
+			Album: "Abbey Road", Year: 1969}),
+		trackSearchIndexEntry(&mediaprovider.Track{ID: "tr2", Title: "Café Régalia", ArtistNames: []string{"Beatless"}}),
+		playlistSearchIndexEntry(&mediaprovider.Playlist{ID: "pl1", Name: "Road trip"}),
+	}
+
+	ids := func(query string) []string {
+		var ids []string
+		for _, r := range i.Search(query, 10) {
+			ids = append(ids, r.ID)
+		}
+		return ids
+	}
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		// name matches first, then artist name matches
+		{"beatles", []string{"ar1", "al1", "tr1", "tr2"}},
+		{"beatels", []string{"ar1", "al1", "tr1", "tr2"}},
+		{"abb", []string{"al1", "tr1"}},
+		{"come togehter", []string{"tr1"}},
+		{"regalia", []string{"tr2"}},
+		{"café", []string{"tr2"}},
+		{`"abbey road"`, []string{"al1", "tr1"}},
+		{"road type:playlist", []string{"pl1"}},
+		{"beatles year:1969", []string{"al1", "tr1"}},
+		{"zeppelin", nil},
+	} {
+		got := ids(tt.query)
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.query, got, tt.want)
+			continue
+		}
+		for k := range got {
+			if got[k] != tt.want[k] {
+				t.Errorf("%q: got %v, want %v", tt.query, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -5,45 +5,76 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dweymouth/go-jellyfin"
 	"github.com/dweymouth/supersonic/backend/mediaprovider"
+	dlnaMP "github.com/dweymouth/supersonic/backend/mediaprovider/dlna"
 	jellyfinMP "github.com/dweymouth/supersonic/backend/mediaprovider/jellyfin"
 	subsonicMP "github.com/dweymouth/supersonic/backend/mediaprovider/subsonic"
+	unifiedMP "github.com/dweymouth/supersonic/backend/mediaprovider/unified"
+	"github.com/dweymouth/supersonic/backend/player/mpv"
 	"github.com/dweymouth/supersonic/res"
 	"github.com/google/uuid"
 	"github.com/supersonic-app/go-subsonic/subsonic"
-	"github.com/zalando/go-keyring"
 )
 
 type ServerManager struct {
 	LoggedInUser string
 	ServerID     uuid.UUID
 	Server       mediaprovider.MediaProvider
-
-	useKeyring        bool
-	prefetchCoverCB   func(string)
-	appName           string
-	appVersion        string
-	config            *Config
-	onServerConnected []func(*ServerConfig)
-	onLogout          []func()
+	// records the requests sent to the server when enabled
+	NetworkLog *NetworkLog
+
+	credentials        CredentialStore
+	cookieJarsLock     sync.Mutex
+	cookieJars         map[uuid.UUID]*cookiejar.Jar
+	monitorCancel      context.CancelFunc
+	streamProxy        *streamProxy
+	onConnectionStatus []func(ConnectionStatus)
+	onHostChanged      []func(hostname string)
+	prefetchCoverCB    func(string)
+	appName            string
+	appVersion         string
+	config             *Config
+	onServerConnected  []func(*ServerConfig)
+	onLogout           []func()
+
+	// guards the connection state, which is read by the stream proxy and
+	// image fetches on their own goroutines, and changes when failing over
+	// between hostnames, which may happen on any goroutine
+	connLock sync.RWMutex
+	// the URL through which the server is connected,
+	// either its Hostname or AltHostname
+	hostname      string
+	httpClient    *http.Client
+	proxyStreams  bool // stream through the stream proxy
+	streamTLS     mpv.TLSOptions
+	streamHeaders []string
+	monitor       *hostMonitor
+	members       map[string]*unifiedMember // by member key
 }
 
 var ErrUnreachable = errors.New("server is unreachable")
 
-func NewServerManager(appName, appVersion string, config *Config, useKeyring bool) *ServerManager {
-	return &ServerManager{
-		appName:    appName,
-		appVersion: appVersion,
-		config:     config,
-		useKeyring: useKeyring,
+// NewServerManager creates the ServerManager. If credentials is nil,
+// no credentials are saved and the user must log in on each launch.
+func NewServerManager(appName, appVersion string, config *Config, credentials CredentialStore) *ServerManager {
+	s := &ServerManager{
+		appName:     appName,
+		appVersion:  appVersion,
+		config:      config,
+		credentials: credentials,
 	}
+	s.NetworkLog = NewNetworkLog()
+	s.streamProxy = newStreamProxy(s.streamProxyClient)
+	s.NetworkLog.SetEnabled(config.Application.RecordNetworkRequests)
+	return s
 }
 
 func (s *ServerManager) SetPrefetchAlbumCoverCallback(cb func(string)) {
@@ -54,10 +85,34 @@ func (s *ServerManager) SetPrefetchAlbumCoverCallback(cb func(string)) {
 }
 
 func (s *ServerManager) ConnectToServer(conf *ServerConfig, password string) error {
-	cli, err := s.connect(conf.ServerConnection, password)
+	var cli mediaprovider.Server
+	var monitor *hostMonitor
+	var members []*unifiedMember
+	var err error
+	if conf.ServerType == ServerTypeUnified {
+		cli, members, err = s.connectUnified(conf.ServerConnection)
+	} else {
+		cli, monitor, err = s.connect(conf.ID, conf.ServerConnection, password)
+	}
 	if err != nil {
 		return err
 	}
+	var hostname string
+	if monitor != nil {
+		hostname = monitor.Status().Hostname
+	}
+	// stop the previous server's monitor first, so that
+	// it can't change the new server's connection state
+	s.stopHostMonitor()
+	if err := s.setupServerHTTP(conf, hostname, monitor); err != nil {
+		return err
+	}
+	for _, m := range members {
+		if m.httpClient, _, _, err = s.newServerHTTPClient(m.conf, m.monitor); err != nil {
+			return err
+		}
+	}
+	s.startHostMonitor(conf, hostname, monitor, members)
 	s.Server = cli.MediaProvider()
 	s.Server.SetPrefetchCoverCallback(s.prefetchCoverCB)
 	s.LoggedInUser = conf.Username
@@ -69,13 +124,15 @@ func (s *ServerManager) ConnectToServer(conf *ServerConfig, password string) err
 	return nil
 }
 
+// TestConnectionAndAuth tests logging in to the server. The serverID is
+// the ID of a saved server, or the zero UUID for a server being added.
 func (s *ServerManager) TestConnectionAndAuth(
-	ctx context.Context, connection ServerConnection, password string,
+	ctx context.Context, serverID uuid.UUID, connection ServerConnection, password string,
 ) error {
 	err := ErrUnreachable
 	done := make(chan bool)
 	go func() {
-		_, err = s.connect(connection, password)
+		_, _, err = s.connect(serverID, connection, password)
 		close(done)
 	}()
 	select {
@@ -98,6 +155,16 @@ func (s *ServerManager) GetDefaultServer() *ServerConfig {
 	return nil
 }
 
+// CurrentServerConfig returns the config of the connected server, if any.
+func (s *ServerManager) CurrentServerConfig() *ServerConfig {
+	for _, c := range s.config.Servers {
+		if c.ID == s.ServerID {
+			return c
+		}
+	}
+	return nil
+}
+
 func (s *ServerManager) SetDefaultServer(serverID uuid.UUID) {
 	var found bool
 	for _, s := range s.config.Servers {
@@ -123,7 +190,7 @@ func (s *ServerManager) AddServer(nickname string, connection ServerConnection)
 }
 
 func (s *ServerManager) DeleteServer(serverID uuid.UUID) {
-	s.deleteServerPassword(serverID)
+	s.deleteServerCredentials(serverID)
 	newServers := make([]*ServerConfig, 0, len(s.config.Servers)-1)
 	for _, s := range s.config.Servers {
 		if s.ID != serverID {
@@ -136,20 +203,27 @@ func (s *ServerManager) DeleteServer(serverID uuid.UUID) {
 func (s *ServerManager) Logout(deletePassword bool) {
 	if s.Server != nil {
 		if deletePassword {
-			s.deleteServerPassword(s.ServerID)
+			s.deleteServerCredentials(s.ServerID)
 		}
 		for _, cb := range s.onLogout {
 			cb()
 		}
 		s.Server = nil
+		s.setServerHTTP(nil, false, mpv.TLSOptions{}, nil)
+		s.connLock.Lock()
+		s.hostname = ""
+		s.connLock.Unlock()
+		s.stopHostMonitor()
 		s.LoggedInUser = ""
 		s.ServerID = uuid.UUID{}
 	}
 }
 
-func (s *ServerManager) deleteServerPassword(serverID uuid.UUID) {
-	if s.useKeyring {
-		keyring.Delete(s.appName, s.ServerID.String())
+func (s *ServerManager) deleteServerCredentials(serverID uuid.UUID) {
+	if s.credentials != nil {
+		s.credentials.Delete(serverID.String())
+		s.credentials.Delete(tokenKey(serverID))
+		s.credentials.Delete(certPasswordKey(serverID))
 	}
 }
 
@@ -163,21 +237,116 @@ func (s *ServerManager) OnLogout(cb func()) {
 	s.onLogout = append(s.onLogout, cb)
 }
 
+var errNoCredentialStorage = errors.New("credential storage not enabled")
+
+// GetServerPassword returns the saved password, or API key
+// if the server uses API key authentication.
 func (s *ServerManager) GetServerPassword(serverID uuid.UUID) (string, error) {
-	if s.useKeyring {
-		return keyring.Get(s.appName, serverID.String())
+	if s.credentials == nil {
+		return "", errNoCredentialStorage
 	}
-	return "", errors.New("keyring not enabled")
+	return s.credentials.Get(serverID.String())
 }
 
 func (s *ServerManager) SetServerPassword(server *ServerConfig, password string) error {
-	if s.useKeyring {
-		return keyring.Set(s.appName, server.ID.String(), password)
+	if s.credentials == nil {
+		return errNoCredentialStorage
+	}
+	return s.credentials.Set(server.ID.String(), password)
+}
+
+// HasServerToken returns whether an access token is saved for the server,
+// with which it can be connected to without a password.
+func (s *ServerManager) HasServerToken(serverID uuid.UUID) bool {
+	_, err := s.getServerToken(serverID)
+	return err == nil
+}
+
+func (s *ServerManager) getServerToken(serverID uuid.UUID) (string, error) {
+	if s.credentials == nil {
+		return "", errNoCredentialStorage
+	}
+	return s.credentials.Get(tokenKey(serverID))
+}
+
+func (s *ServerManager) setServerToken(serverID uuid.UUID, token string) {
+	if s.credentials == nil || serverID == uuid.Nil {
+		return
+	}
+	if err := s.credentials.Set(tokenKey(serverID), token); err != nil {
+		serverLog.Error("error saving access token", "err", err)
+	}
+}
+
+func tokenKey(serverID uuid.UUID) string {
+	return serverID.String() + ".token"
+}
+
+// SetClientCertPassword saves the password of the server's PKCS#12 client certificate.
+func (s *ServerManager) SetClientCertPassword(server *ServerConfig, password string) error {
+	if s.credentials == nil {
+		return errNoCredentialStorage
+	}
+	if password == "" {
+		return s.credentials.Delete(certPasswordKey(server.ID))
+	}
+	return s.credentials.Set(certPasswordKey(server.ID), password)
+}
+
+func (s *ServerManager) getClientCertPassword(serverID uuid.UUID) (string, error) {
+	if s.credentials == nil {
+		return "", errNoCredentialStorage
+	}
+	return s.credentials.Get(certPasswordKey(serverID))
+}
+
+func certPasswordKey(serverID uuid.UUID) string {
+	return serverID.String() + ".certpass"
+}
+
+// SavedCredentials returns the kinds of credentials saved for the server.
+// It may block while the credential store is unlocked.
+func (s *ServerManager) SavedCredentials(server *ServerConfig) []CredentialKind {
+	var kinds []CredentialKind
+	if _, err := s.GetServerPassword(server.ID); err == nil {
+		if server.APIKeyAuth {
+			kinds = append(kinds, CredentialAPIKey)
+		} else {
+			kinds = append(kinds, CredentialPassword)
+		}
+	}
+	if s.HasServerToken(server.ID) {
+		kinds = append(kinds, CredentialAccessToken)
+	}
+	if _, err := s.getClientCertPassword(server.ID); err == nil {
+		kinds = append(kinds, CredentialCertPassword)
+	}
+	return kinds
+}
+
+// RevokeCredential deletes a saved credential of the server.
+func (s *ServerManager) RevokeCredential(server *ServerConfig, kind CredentialKind) error {
+	if s.credentials == nil {
+		return errNoCredentialStorage
+	}
+	switch kind {
+	case CredentialAccessToken:
+		return s.credentials.Delete(tokenKey(server.ID))
+	case CredentialCertPassword:
+		return s.credentials.Delete(certPasswordKey(server.ID))
 	}
-	return errors.New("keyring not available")
+	return s.credentials.Delete(server.ID.String())
 }
 
-func (s *ServerManager) connect(connection ServerConnection, password string) (mediaprovider.Server, error) {
+// connect logs in to the server through whichever of its hostnames
+// responds first, returning the server and the monitor of its hostnames,
+// which sends requests to the hostname in use.
+func (s *ServerManager) connect(serverID uuid.UUID, connection ServerConnection, password string) (mediaprovider.Server, *hostMonitor, error) {
+	if connection.ServerType == ServerTypeUnified {
+		cli, _, err := s.connectUnified(connection)
+		return cli, nil, err
+	}
+
 	var cli, altCli mediaprovider.Server
 	timeout := time.Second * time.Duration(s.config.Application.RequestTimeoutSeconds)
 
@@ -189,52 +358,100 @@ func (s *ServerManager) connect(connection ServerConnection, password string) (m
 		connection.AltHostname = NormalizeServerURL(connection.AltHostname)
 	}
 	httpProxy := resolveHTTPProxy(s.config.LocalPlayback)
+	tlsConfig, err := s.serverTLSConfig(serverID, connection)
+	if err != nil {
+		return nil, nil, err
+	}
+	jar, err := s.cookieJar(serverID, connection.CookieFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	pingClient := newHTTPClient(timeout, httpProxy, connection.SkipSSLVerify)
+	setTLSConfig(pingClient, tlsConfig)
+	setServerHTTPSettings(pingClient, connection.CustomHeaders, jar)
+	monitor := newHostMonitor(connection.Hostname, connection.AltHostname, pingClient.Transport)
+	newClient := func() *http.Client {
+		cli := newHTTPClient(timeout, httpProxy, connection.SkipSSLVerify)
+		setTLSConfig(cli, tlsConfig)
+		cli.Transport = s.NetworkLog.wrap(cli.Transport, connection.CustomHeaders)
+		setServerHTTPSettings(cli, connection.CustomHeaders, jar)
+		cli.Transport = monitor.wrap(cli.Transport)
+		return cli
+	}
 
 	if connection.ServerType == ServerTypeJellyfin {
+		// log in with the saved access token if there is one,
+		// and save the token of each new login
+		token, _ := s.getServerToken(serverID)
+		onToken := func(token string) { s.setServerToken(serverID, token) }
+
 		client, err := jellyfin.NewClient(connection.Hostname, res.AppName, res.AppVersion, jellyfin.WithTimeout(timeout))
 		if err != nil {
-			log.Printf("error creating Jellyfin client: %s", err.Error())
-			return nil, err
+			serverLog.Error("error creating Jellyfin client", "err", err)
+			return nil, nil, err
 		}
 		applyTransportSettings(client.HTTPClient, httpProxy, connection.SkipSSLVerify)
+		setTLSConfig(client.HTTPClient, tlsConfig)
+		client.HTTPClient.Transport = s.NetworkLog.wrap(client.HTTPClient.Transport, connection.CustomHeaders)
+		setServerHTTPSettings(client.HTTPClient, connection.CustomHeaders, jar)
+		client.HTTPClient.Transport = monitor.wrap(client.HTTPClient.Transport)
 		cli = &jellyfinMP.JellyfinServer{
-			Client: *client,
+			Client:         *client,
+			Token:          token,
+			OnTokenChanged: onToken,
 		}
 
 		if connection.AltHostname != "" {
 			altClient, err := jellyfin.NewClient(connection.AltHostname, res.AppName, res.AppVersion, jellyfin.WithTimeout(timeout))
 			if err != nil {
-				log.Printf("error creating Jellyfin alternative client: %s", err.Error())
-				return nil, err
+				serverLog.Error("error creating Jellyfin alternative client", "err", err)
+				return nil, nil, err
 			}
 			applyTransportSettings(altClient.HTTPClient, httpProxy, connection.SkipSSLVerify)
+			setTLSConfig(altClient.HTTPClient, tlsConfig)
+			altClient.HTTPClient.Transport = s.NetworkLog.wrap(altClient.HTTPClient.Transport, connection.CustomHeaders)
+			setServerHTTPSettings(altClient.HTTPClient, connection.CustomHeaders, jar)
+			altClient.HTTPClient.Transport = monitor.wrap(altClient.HTTPClient.Transport)
 			altCli = &jellyfinMP.JellyfinServer{
-				Client: *altClient,
+				Client:         *altClient,
+				Token:          token,
+				OnTokenChanged: onToken,
 			}
 		}
+	} else if connection.ServerType == ServerTypeDLNA {
+		cli = &dlnaMP.DLNAServer{
+			HTTPClient: newClient(),
+			URL:        connection.Hostname,
+		}
+		altCli = &dlnaMP.DLNAServer{
+			HTTPClient: newClient(),
+			URL:        connection.AltHostname,
+		}
 	} else {
 		ua := fmt.Sprintf("%s/%s", s.appName, s.appVersion)
 		cli = &subsonicMP.SubsonicServer{
 			Client: subsonic.Client{
 				UserAgent:    ua,
-				Client:       newHTTPClient(timeout, httpProxy, connection.SkipSSLVerify),
+				Client:       newClient(),
 				BaseUrl:      connection.Hostname,
 				User:         connection.Username,
 				PasswordAuth: connection.LegacyAuth,
 				ClientName:   res.AppName,
 				UseJSON:      true,
 			},
+			APIKeyAuth: connection.APIKeyAuth,
 		}
 		altCli = &subsonicMP.SubsonicServer{
 			Client: subsonic.Client{
 				UserAgent:    ua,
-				Client:       newHTTPClient(timeout, httpProxy, connection.SkipSSLVerify),
+				Client:       newClient(),
 				BaseUrl:      connection.AltHostname,
 				User:         connection.Username,
 				PasswordAuth: connection.LegacyAuth,
 				ClientName:   res.AppName,
 				UseJSON:      true,
 			},
+			APIKeyAuth: connection.APIKeyAuth,
 		}
 	}
 
@@ -273,13 +490,458 @@ func (s *ServerManager) connect(connection ServerConnection, password string) (m
 
 	select {
 	case <-ctx.Done():
-		return nil, ErrUnreachable
+		return nil, nil, ErrUnreachable
 	case res := <-pingChan:
 		if res.isAlt {
-			return altCli, res.err
+			monitor.activate(1)
+			return altCli, monitor, res.err
+		}
+		monitor.activate(0)
+		return cli, monitor, res.err
+	}
+}
+
+// unifiedMember is a connected member server of a unified library, whose
+// requests outside of its API and streams use its own HTTP settings
+// and the hostname in use by its monitor.
+type unifiedMember struct {
+	key        string
+	conf       *ServerConfig
+	monitor    *hostMonitor
+	httpClient *http.Client
+}
+
+// unifiedMemberKey returns the key that namespaces the item IDs of a
+// member server in a unified library.
+func unifiedMemberKey(serverID uuid.UUID) string {
+	return strings.ReplaceAll(serverID.String(), "-", "")[:8]
+}
+
+// connectUnified connects to the member servers of a Unified server in
+// parallel, using their saved passwords. Members that can't be connected
+// to are left out of the library, unless none can be connected to.
+func (s *ServerManager) connectUnified(connection ServerConnection) (mediaprovider.Server, []*unifiedMember, error) {
+	var configs []*ServerConfig
+	for _, id := range connection.UnifiedServerIDs {
+		for _, c := range s.config.Servers {
+			if c.ID == id && c.ServerType != ServerTypeUnified {
+				configs = append(configs, c)
+			}
+		}
+	}
+	if len(configs) == 0 {
+		return nil, nil, errors.New("unified server has no member servers")
+	}
+
+	members := make([]*unifiedMember, len(configs))
+	servers := make([]mediaprovider.Server, len(configs))
+	errs := make([]error, len(configs))
+	var wg sync.WaitGroup
+	for i, c := range configs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var password string
+			if c.ServerType != ServerTypeDLNA {
+				pass, err := s.GetServerPassword(c.ID)
+				if err != nil && !s.HasServerToken(c.ID) {
+					errs[i] = fmt.Errorf("%s: no saved credentials: %w", c.Nickname, err)
+					return
+				}
+				password = pass
+			}
+			cli, monitor, err := s.connect(c.ID, c.ServerConnection, password)
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", c.Nickname, err)
+				return
+			}
+			servers[i] = cli
+			members[i] = &unifiedMember{key: unifiedMemberKey(c.ID), conf: c, monitor: monitor}
+		}()
+	}
+	wg.Wait()
+
+	server := &unifiedMP.Server{}
+	var connected []*unifiedMember
+	for i, m := range members {
+		if m == nil {
+			serverLog.Error("error connecting to unified library member", "err", errs[i])
+			continue
+		}
+		server.Members = append(server.Members, unifiedMP.Member{
+			Key:      m.key,
+			Name:     m.conf.Nickname,
+			Username: m.conf.Username,
+			Server:   servers[i],
+		})
+		connected = append(connected, m)
+	}
+	if len(server.Members) == 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+	return server, connected, nil
+}
+
+// serverTLSConfig returns the TLS config of the server,
+// using its saved client certificate password if none is given.
+func (s *ServerManager) serverTLSConfig(serverID uuid.UUID, connection ServerConnection) (*tls.Config, error) {
+	if connection.ClientCertPassword == "" && serverID != uuid.Nil {
+		connection.ClientCertPassword, _ = s.getClientCertPassword(serverID)
+	}
+	return serverTLSConfig(connection)
+}
+
+// setupServerHTTP creates the HTTP client for requests to the connected
+// server outside of its API (downloads, images), and the TLS options and
+// headers for streaming it with mpv, which can only read PEM files.
+// The members of a unified library have their own clients, and are
+// streamed through the stream proxy.
+func (s *ServerManager) setupServerHTTP(conf *ServerConfig, hostname string, monitor *hostMonitor) error {
+	// mpv can't pin certificates, so pinned servers are streamed through
+	// the stream proxy, whose requests are verified by the server's client.
+	// PKCS#12 client certificates are also only used by the server's client,
+	// rather than writing their private key to a file mpv could read.
+	proxyStreams := conf.PinnedCertSHA256 != "" ||
+		(conf.ClientCertFile != "" && !isPEMFile(conf.ClientCertFile))
+	if conf.ServerType == ServerTypeUnified {
+		cli := newHTTPClient(0, resolveHTTPProxy(s.config.LocalPlayback), conf.SkipSSLVerify)
+		s.setServerHTTP(cli, proxyStreams, mpv.TLSOptions{}, nil)
+		return nil
+	}
+
+	cli, tlsConfig, jar, err := s.newServerHTTPClient(conf, monitor)
+	if err != nil {
+		return err
+	}
+	headers := streamHTTPHeaders(hostname, conf.CustomHeaders, jar)
+	if tlsConfig == nil || proxyStreams {
+		s.setServerHTTP(cli, proxyStreams, mpv.TLSOptions{}, headers)
+		return nil
+	}
+	// mpv doesn't verify certificates by default,
+	// so verification is only enabled when a CA bundle is given
+	streamTLS := mpv.TLSOptions{
+		CAFile: conf.CACertFile,
+		Verify: conf.CACertFile != "" && !conf.SkipSSLVerify,
+	}
+	if len(tlsConfig.Certificates) > 0 {
+		streamTLS.CertFile = conf.ClientCertFile
+		streamTLS.KeyFile = conf.ClientKeyFile
+		if streamTLS.KeyFile == "" {
+			streamTLS.KeyFile = conf.ClientCertFile
+		}
+	}
+	s.setServerHTTP(cli, proxyStreams, streamTLS, headers)
+	return nil
+}
+
+// setServerHTTP sets the HTTP settings of the connected server.
+func (s *ServerManager) setServerHTTP(cli *http.Client, proxyStreams bool, streamTLS mpv.TLSOptions, streamHeaders []string) {
+	s.connLock.Lock()
+	defer s.connLock.Unlock()
+	s.httpClient = cli
+	s.proxyStreams = proxyStreams
+	s.streamTLS = streamTLS
+	s.streamHeaders = streamHeaders
+}
+
+// newServerHTTPClient creates a client for requests to the server outside
+// of its API, with the server's TLS, header, cookie and proxy settings,
+// sent to the hostname in use by the monitor, if any.
+func (s *ServerManager) newServerHTTPClient(conf *ServerConfig, monitor *hostMonitor) (*http.Client, *tls.Config, http.CookieJar, error) {
+	tlsConfig, err := s.serverTLSConfig(conf.ID, conf.ServerConnection)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	jar, err := s.cookieJar(conf.ID, conf.CookieFile)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cli := newHTTPClient(0, resolveHTTPProxy(s.config.LocalPlayback), conf.SkipSSLVerify)
+	setTLSConfig(cli, tlsConfig)
+	cli.Transport = s.NetworkLog.wrap(cli.Transport, conf.CustomHeaders)
+	setServerHTTPSettings(cli, conf.CustomHeaders, jar)
+	if monitor != nil {
+		cli.Transport = monitor.wrap(cli.Transport)
+	}
+	return cli, tlsConfig, jar, nil
+}
+
+// HTTPClient returns the client for requests to the connected server
+// outside of its API, which uses the server's TLS and proxy settings.
+func (s *ServerManager) HTTPClient() *http.Client {
+	s.connLock.RLock()
+	defer s.connLock.RUnlock()
+	return s.httpClientLocked()
+}
+
+func (s *ServerManager) httpClientLocked() *http.Client {
+	if s.httpClient == nil {
+		return http.DefaultClient
+	}
+	return s.httpClient
+}
+
+// HTTPClientForURL returns the client for a URL on the host of the
+// connected server, or of a member of the connected unified library.
+// Stream URLs of the local stream proxy are fetched directly.
+func (s *ServerManager) HTTPClientForURL(rawURL string) *http.Client {
+	if s.streamProxy.Serves(rawURL) {
+		return http.DefaultClient
+	}
+	s.connLock.RLock()
+	defer s.connLock.RUnlock()
+	if u, err := url.Parse(rawURL); err == nil {
+		for _, m := range s.members {
+			if m.monitor.IsServerURL(u) {
+				return m.httpClient
+			}
 		}
-		return cli, res.err
 	}
+	return s.httpClientLocked()
+}
+
+// IsServerURL returns whether the URL is on the host of the connected
+// server, or of a member of the connected unified library.
+func (s *ServerManager) IsServerURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	s.connLock.RLock()
+	defer s.connLock.RUnlock()
+	if s.monitor != nil && s.monitor.IsServerURL(u) {
+		return true
+	}
+	for _, m := range s.members {
+		if m.monitor.IsServerURL(u) {
+			return true
+		}
+	}
+	return false
+}
+
+// StreamURL returns the URL for the player to stream the item from, given
+// its stream URL from the server. Stream URLs are requested by the player
+// rather than the server's client, so they are rewritten to the hostname
+// in use. The streams of unified library members, whose HTTP settings the
+// player doesn't have, and of servers with a pinned certificate, which the
+// player can't verify, are sent through the local stream proxy.
+func (s *ServerManager) StreamURL(itemID, rawURL string) string {
+	unified, isUnified := s.Server.(*unifiedMP.MediaProvider)
+	s.connLock.RLock()
+	monitor, proxyStreams := s.monitor, s.proxyStreams
+	var member *unifiedMember
+	if isUnified {
+		member = s.members[unified.MemberKey(itemID)]
+	}
+	s.connLock.RUnlock()
+
+	if isUnified {
+		if member == nil {
+			return rawURL
+		}
+		proxyURL, err := s.streamProxy.URL(member.key, member.monitor.RewriteURL(rawURL))
+		if err != nil {
+			playbackLog.Error("error starting stream proxy", "err", err)
+			return ""
+		}
+		return proxyURL
+	}
+	if monitor != nil {
+		rawURL = monitor.RewriteURL(rawURL)
+	}
+	if !proxyStreams {
+		return rawURL
+	}
+	proxyURL, err := s.streamProxy.URL(serverStreamRoute, rawURL)
+	if err != nil {
+		playbackLog.Error("error starting stream proxy", "err", err)
+		return ""
+	}
+	return proxyURL
+}
+
+// the stream proxy route of the connected server, which can't
+// be a unified member key since those are hexadecimal
+const serverStreamRoute = "server"
+
+// streamProxyClient returns the HTTP client of the server
+// that the stream proxy sends the route's requests to.
+func (s *ServerManager) streamProxyClient(route string) *http.Client {
+	s.connLock.RLock()
+	defer s.connLock.RUnlock()
+	if route == serverStreamRoute && s.proxyStreams {
+		return s.httpClient
+	}
+	if m := s.members[route]; m != nil {
+		return m.httpClient
+	}
+	return nil
+}
+
+// ConnectionStatus returns the status of the connection to the server.
+func (s *ServerManager) ConnectionStatus() ConnectionStatus {
+	s.connLock.RLock()
+	monitor := s.monitor
+	s.connLock.RUnlock()
+	if monitor == nil {
+		return ConnectionStatus{}
+	}
+	return monitor.Status()
+}
+
+// CheckConnection checks the connection to the server without waiting
+// for the next periodic health check.
+func (s *ServerManager) CheckConnection() {
+	s.connLock.RLock()
+	monitor := s.monitor
+	s.connLock.RUnlock()
+	if monitor != nil {
+		monitor.CheckSoon()
+	}
+}
+
+// Sets a callback that is invoked when the connection status changes.
+// It may be called from any goroutine.
+func (s *ServerManager) OnConnectionStatusChanged(cb func(ConnectionStatus)) {
+	s.onConnectionStatus = append(s.onConnectionStatus, cb)
+}
+
+// Sets a callback that is invoked after failing over to the other hostname
+// of the server, or of a member of the unified library.
+// It may be called from any goroutine.
+func (s *ServerManager) OnHostChanged(cb func(hostname string)) {
+	s.onHostChanged = append(s.onHostChanged, cb)
+}
+
+// handleConnectionStatus handles status changes of the connection to the
+// server through the monitor, which are ignored once it is stopped.
+// It may be called from any goroutine.
+func (s *ServerManager) handleConnectionStatus(monitor *hostMonitor, conf *ServerConfig, status ConnectionStatus, hostChanged bool) {
+	var headers []string
+	if hostChanged {
+		jar, _ := s.cookieJar(conf.ID, "")
+		headers = streamHTTPHeaders(status.Hostname, conf.CustomHeaders, jar)
+	}
+	s.connLock.Lock()
+	current := s.monitor == monitor
+	if current && hostChanged {
+		s.hostname = status.Hostname
+		s.streamHeaders = headers
+	}
+	s.connLock.Unlock()
+	if !current {
+		return
+	}
+
+	if hostChanged {
+		serverLog.Info("switched server hostname", "hostname", status.Hostname)
+		for _, cb := range s.onHostChanged {
+			cb(status.Hostname)
+		}
+	}
+	for _, cb := range s.onConnectionStatus {
+		cb(status)
+	}
+}
+
+// handleMemberConnectionStatus handles status changes of the connection
+// to a member of the unified library, whose stream URLs must be reissued
+// after failing over to its other hostname.
+func (s *ServerManager) handleMemberConnectionStatus(status ConnectionStatus, hostChanged bool) {
+	if hostChanged {
+		serverLog.Info("switched unified library member hostname", "hostname", status.Hostname)
+		for _, cb := range s.onHostChanged {
+			cb(s.Hostname())
+		}
+	}
+}
+
+// startHostMonitor starts monitoring the hostnames of the connected
+// server, or of the members of the connected unified library.
+func (s *ServerManager) startHostMonitor(conf *ServerConfig, hostname string, monitor *hostMonitor, members []*unifiedMember) {
+	var memberMap map[string]*unifiedMember
+	if len(members) > 0 {
+		memberMap = make(map[string]*unifiedMember, len(members))
+		for _, m := range members {
+			memberMap[m.key] = m
+		}
+	}
+	s.connLock.Lock()
+	s.monitor = monitor
+	s.members = memberMap
+	s.hostname = hostname
+	s.connLock.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.monitorCancel = cancel
+	if monitor != nil {
+		monitor.setOnChange(func(status ConnectionStatus, hostChanged bool) {
+			s.handleConnectionStatus(monitor, conf, status, hostChanged)
+		})
+		go monitor.Run(ctx)
+	}
+	for _, m := range members {
+		m.monitor.setOnChange(s.handleMemberConnectionStatus)
+		go m.monitor.Run(ctx)
+	}
+}
+
+func (s *ServerManager) stopHostMonitor() {
+	if s.monitorCancel != nil {
+		s.monitorCancel()
+	}
+	s.monitorCancel = nil
+	s.connLock.Lock()
+	s.monitor = nil
+	s.members = nil
+	s.connLock.Unlock()
+}
+
+// cookieJar returns the cookie jar of the server, which keeps the cookies
+// set by the server across reconnects, with the cookie file's cookies added.
+func (s *ServerManager) cookieJar(serverID uuid.UUID, cookieFile string) (*cookiejar.Jar, error) {
+	s.cookieJarsLock.Lock()
+	defer s.cookieJarsLock.Unlock()
+	jar, ok := s.cookieJars[serverID]
+	if !ok || serverID == uuid.Nil {
+		jar, _ = cookiejar.New(nil)
+	}
+	if cookieFile != "" {
+		if err := loadCookieFile(jar, cookieFile); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidCookieFile, err)
+		}
+	}
+	if serverID != uuid.Nil {
+		if s.cookieJars == nil {
+			s.cookieJars = make(map[uuid.UUID]*cookiejar.Jar)
+		}
+		s.cookieJars[serverID] = jar
+	}
+	return jar, nil
+}
+
+// Hostname returns the URL through which the server is connected,
+// either its Hostname or AltHostname.
+func (s *ServerManager) Hostname() string {
+	s.connLock.RLock()
+	defer s.connLock.RUnlock()
+	return s.hostname
+}
+
+// StreamHTTPHeaders returns the headers for streaming the connected server.
+func (s *ServerManager) StreamHTTPHeaders() []string {
+	s.connLock.RLock()
+	defer s.connLock.RUnlock()
+	return s.streamHeaders
+}
+
+// StreamTLSOptions returns the TLS options for streaming the connected server.
+func (s *ServerManager) StreamTLSOptions() mpv.TLSOptions {
+	s.connLock.RLock()
+	defer s.connLock.RUnlock()
+	return s.streamTLS
 }
 
 func newHTTPClient(timeout time.Duration, proxyURL string, skipSSLVerify bool) *http.Client {
@@ -303,10 +965,10 @@ func applyTransportSettings(cli *http.Client, proxyURL string, skipSSLVerify boo
 	if proxyURL != "" {
 		proxy, err := url.Parse(proxyURL)
 		if err != nil {
-			log.Printf("Warning: invalid proxy URL %q, ignoring proxy: %s", redactProxyURL(proxyURL), err.Error())
+			serverLog.Warn("invalid proxy URL, ignoring proxy", "proxy", redactProxyURL(proxyURL), "err", err)
 		} else {
 			transport.Proxy = http.ProxyURL(proxy)
-			log.Printf("Setting API client proxy: %s", redactProxyURL(proxyURL))
+			serverLog.Info("setting API client proxy", "proxy", redactProxyURL(proxyURL))
 		}
 	}
 	if skipSSLVerify {
@@ -1,9 +1,17 @@
 package backend
 
 import (
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -238,3 +246,162 @@ func TestApplyTransportSettingsClonesExistingTransport(t *testing.T) {
 		t.Fatal("transport TLSClientConfig.InsecureSkipVerify = false, want true")
 	}
 }
+
+func TestStreamURL_Pinned(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("stream"))
+	}))
+	defer srv.Close()
+	sum := sha256.Sum256(srv.Certificate().Raw)
+
+	for _, tc := range []struct {
+		name     string
+		pin      string
+		wantCode int
+	}{
+		{"matching pin", hex.EncodeToString(sum[:]), http.StatusOK},
+		{"other pin", clientCertSHA256, http.StatusBadGateway},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewServerManager("test", "1.0", &Config{}, nil)
+			conf := &ServerConfig{ServerConnection: ServerConnection{PinnedCertSHA256: tc.pin}}
+			if err := s.setupServerHTTP(conf, srv.URL, nil); err != nil {
+				t.Fatal(err)
+			}
+			streamURL := s.StreamURL("1", srv.URL+"/rest/stream?id=1")
+			if !strings.HasPrefix(streamURL, "http://127.0.0.1:") {
+				t.Fatalf("got stream URL %q, want the stream proxy", streamURL)
+			}
+			resp, err := http.Get(streamURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.wantCode {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tc.wantCode)
+			}
+		})
+	}
+}
+
+func TestSetupServerHTTP_PKCS12(t *testing.T) {
+	data, err := base64.StdEncoding.DecodeString(modernP12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(t.TempDir(), "client.p12")
+	if err := os.WriteFile(certFile, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServerManager("test", "1.0", &Config{}, nil)
+	conf := &ServerConfig{ServerConnection: ServerConnection{ClientCertFile: certFile, ClientCertPassword: "secret"}}
+	if err := s.setupServerHTTP(conf, "https://music.example.com", nil); err != nil {
+		t.Fatal(err)
+	}
+	// the key stays with the server's client rather than being written out for mpv
+	if opts := s.StreamTLSOptions(); opts.CertFile != "" || opts.KeyFile != "" {
+		t.Errorf("got stream TLS options %+v, want no client certificate files", opts)
+	}
+	if streamURL := s.StreamURL("1", "https://music.example.com/rest/stream?id=1"); !strings.HasPrefix(streamURL, "http://127.0.0.1:") {
+		t.Errorf("got stream URL %q, want the stream proxy", streamURL)
+	}
+}
+
+func TestHandleConnectionStatus_Failover(t *testing.T) {
+	lan := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	vpn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer vpn.Close()
+
+	s := NewServerManager("test", "1.0", &Config{}, nil)
+	conf := &ServerConfig{ServerConnection: ServerConnection{
+		Hostname:      lan.URL,
+		AltHostname:   vpn.URL,
+		CustomHeaders: map[string]string{"X-Test": "1"},
+	}}
+	base := newHTTPClient(0, "", false).Transport
+	monitor := newHostMonitor(lan.URL, vpn.URL, base)
+	monitor.activate(0)
+	if err := s.setupServerHTTP(conf, lan.URL, monitor); err != nil {
+		t.Fatal(err)
+	}
+	var hostChanges atomic.Int32
+	s.OnHostChanged(func(string) { hostChanges.Add(1) })
+	s.startHostMonitor(conf, lan.URL, monitor, nil)
+	defer s.stopHostMonitor()
+
+	// a request fails over to the other host on its own goroutine
+	// while the connection state is read
+	lan.Close()
+	done := make(chan error)
+	go func() {
+		resp, err := s.HTTPClient().Get(lan.URL + "/rest/ping")
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+	for len(done) == 0 && s.Hostname() != vpn.URL {
+		s.StreamHTTPHeaders()
+		s.StreamURL("1", lan.URL+"/rest/stream?id=1")
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if s.Hostname() != vpn.URL || hostChanges.Load() != 1 {
+		t.Errorf("got hostname %s after %d changes, want %s", s.Hostname(), hostChanges.Load(), vpn.URL)
+	}
+
+	// a stopped monitor no longer changes the connection state
+	s.stopHostMonitor()
+	monitor.setStatus(ConnectionStatus{State: ConnectionOK, Hostname: lan.URL}, 0)
+	if s.Hostname() != vpn.URL || hostChanges.Load() != 1 {
+		t.Errorf("stopped monitor changed the hostname to %s", s.Hostname())
+	}
+}
+
+func TestStreamProxy_UnifiedMemberDisconnect(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("stream"))
+	}))
+	defer srv.Close()
+
+	s := NewServerManager("test", "1.0", &Config{}, nil)
+	member := &unifiedMember{
+		key:        "0123abcd",
+		conf:       &ServerConfig{},
+		monitor:    newHostMonitor(srv.URL, "", nil),
+		httpClient: srv.Client(),
+	}
+	member.monitor.activate(0)
+	s.startHostMonitor(&ServerConfig{}, "", nil, []*unifiedMember{member})
+	proxyURL, err := s.streamProxy.URL(member.key, srv.URL+"/rest/stream?id=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the player streams through the proxy while the library disconnects
+	done := make(chan int)
+	go func() {
+		code := http.StatusOK
+		for code == http.StatusOK {
+			resp, err := http.Get(proxyURL)
+			if err != nil {
+				t.Error(err)
+				break
+			}
+			resp.Body.Close()
+			code = resp.StatusCode
+			s.HTTPClientForURL(srv.URL + "/cover.jpg")
+			s.IsServerURL(srv.URL + "/cover.jpg")
+		}
+		done <- code
+	}()
+	time.Sleep(10 * time.Millisecond)
+	s.stopHostMonitor()
+	if code := <-done; code != http.StatusBadGateway {
+		t.Errorf("got status %d after disconnecting, want %d", code, http.StatusBadGateway)
+	}
+	if s.IsServerURL(srv.URL + "/cover.jpg") {
+		t.Error("disconnected member's URL is still a server URL")
+	}
+}
@@ -0,0 +1,118 @@
+package backend
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+)
+
+// Shuffle modes, as stored in PlaybackConfig.ShuffleMode
+const (
+	// Uniform random shuffle
+	ShuffleModeRandom = "Random"
+	// Favors favorite and highly rated tracks
+	ShuffleModeFavorites = "Favorites"
+	// Favors rarely played tracks and tracks not played in a long time
+	ShuffleModeRediscover = "Rediscover"
+)
+
+var ShuffleModes = []string{ShuffleModeRandom, ShuffleModeFavorites, ShuffleModeRediscover}
+
+// shuffleItems shuffles items in place according to the shuffle mode.
+// Weighted modes use weighted random sampling without replacement, so
+// that heavily weighted items tend to come earlier in the shuffled order.
+// If artistSpread is true, the order is then adjusted where possible
+// so that the same artist is not played twice in a row.
+func shuffleItems(items []mediaprovider.MediaItem, mode string, artistSpread bool, randFloat func() float64) {
+	weightFn := shuffleWeightFunc(mode, time.Now())
+	if weightFn == nil {
+		// Fisher-Yates
+		for i := len(items) - 1; i > 0; i-- {
+			j := min(int(randFloat()*float64(i+1)), i)
+			items[i], items[j] = items[j], items[i]
+		}
+	} else {
+		// Efraimidis-Spirakis: sort by descending u^(1/w)
+		type keyedItem struct {
+			item mediaprovider.MediaItem
+			key  float64
+		}
+		keyed := make([]keyedItem, len(items))
+		for i, item := range items {
+			keyed[i] = keyedItem{item: item, key: math.Pow(randFloat(), 1/weightFn(item))}
+		}
+		slices.SortStableFunc(keyed, func(a, b keyedItem) int {
+			return cmp.Compare(b.key, a.key)
+		})
+		for i, k := range keyed {
+			items[i] = k.item
+		}
+	}
+	if artistSpread {
+		spreadArtistsInPlace(items)
+	}
+}
+
+// returns nil for uniform random shuffling
+func shuffleWeightFunc(mode string, now time.Time) func(mediaprovider.MediaItem) float64 {
+	switch mode {
+	case ShuffleModeFavorites:
+		return func(item mediaprovider.MediaItem) float64 {
+			tr, ok := item.(*mediaprovider.Track)
+			if !ok {
+				return 1
+			}
+			w := 1.0
+			if tr.Rating > 0 {
+				w = float64(tr.Rating) / 3
+			}
+			if tr.Favorite {
+				w += 3
+			}
+			return w
+		}
+	case ShuffleModeRediscover:
+		return func(item mediaprovider.MediaItem) float64 {
+			tr, ok := item.(*mediaprovider.Track)
+			if !ok {
+				return 1
+			}
+			// never played tracks get the full age bonus
+			age := 4.0
+			if !tr.LastPlayed.IsZero() {
+				age = 1 + min(now.Sub(tr.LastPlayed).Hours()/24/365, 3)
+			}
+			return age / (1 + math.Log1p(float64(tr.PlayCount)))
+		}
+	}
+	return nil
+}
+
+// moves items so that no two adjacent items share an artist,
+// where possible, by pulling forward the next item with a different artist
+func spreadArtistsInPlace(items []mediaprovider.MediaItem) {
+	key := func(item mediaprovider.MediaItem) string {
+		if tr, ok := item.(*mediaprovider.Track); ok {
+			return radioArtistKey(tr)
+		}
+		return ""
+	}
+	for i := 1; i < len(items); i++ {
+		prev := key(items[i-1])
+		if prev == "" || key(items[i]) != prev {
+			continue
+		}
+		for j := i + 1; j < len(items); j++ {
+			if key(items[j]) != prev {
+				// rotate items[i:j+1] right by one to bring j to i
+				item := items[j]
+				copy(items[i+1:j+1], items[i:j])
+				items[i] = item
+				break
+			}
+		}
+	}
+}
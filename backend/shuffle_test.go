@@ -0,0 +1,69 @@
+package backend
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+)
+
+func TestShuffleFavoritesWeighting(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	favInFirstHalf := 0
+	for range 200 {
+		items := make([]mediaprovider.MediaItem, 10)
+		for i := range items {
+			items[i] = &mediaprovider.Track{ID: string(rune('a' + i)), Favorite: i == 9}
+		}
+		shuffleItems(items, ShuffleModeFavorites, false, rnd.Float64)
+		for i := range 5 {
+			if items[i].Metadata().ID == "j" {
+				favInFirstHalf++
+			}
+		}
+	}
+	// the favorite has 4x the weight of the others, so should land
+	// in the first half much more often than the uniform 50%
+	if favInFirstHalf < 150 {
+		t.Errorf("favorite track in first half %d/200 times, expected >= 150", favInFirstHalf)
+	}
+}
+
+func TestShuffleRandomUsesRandFloat(t *testing.T) {
+	shuffled := func(seed int64) string {
+		items := make([]mediaprovider.MediaItem, 10)
+		for i := range items {
+			items[i] = &mediaprovider.Track{ID: string(rune('a' + i))}
+		}
+		shuffleItems(items, ShuffleModeRandom, false, rand.New(rand.NewSource(seed)).Float64)
+		var ids string
+		for _, it := range items {
+			ids += it.Metadata().ID
+		}
+		return ids
+	}
+	order := shuffled(1)
+	if order != shuffled(1) {
+		t.Error("expected the same order from the same random source")
+	}
+	if order == "abcdefghij" {
+		t.Error("expected items to be shuffled")
+	}
+}
+
+func TestSpreadArtistsInPlace(t *testing.T) {
+	tr := func(id, artistID string) mediaprovider.MediaItem {
+		return &mediaprovider.Track{ID: id, ArtistIDs: []string{artistID}}
+	}
+	items := []mediaprovider.MediaItem{
+		tr("1", "a"), tr("2", "a"), tr("3", "a"), tr("4", "b"), tr("5", "c"),
+	}
+	spreadArtistsInPlace(items)
+	var ids string
+	for _, item := range items {
+		ids += item.Metadata().ID
+	}
+	if want := "14253"; ids != want {
+		t.Errorf("spreadArtistsInPlace order = %q, want %q", ids, want)
+	}
+}
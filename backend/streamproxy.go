@@ -0,0 +1,130 @@
+package backend
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// request headers of the player that are passed on to the server
+var streamProxyRequestHeaders = []string{"Range", "If-Range", "Accept", "User-Agent", "Icy-MetaData"}
+
+// streamProxy is a local HTTP server through which the player streams
+// from servers whose HTTP settings it can't apply itself: the member
+// servers of a unified library, each with its own TLS settings, headers,
+// cookies and hostnames, and servers with a pinned certificate, which mpv
+// can't verify. Requests are sent on with the server's HTTP client.
+//
+// The stream URL is encoded in the proxy URL, signed so that the proxy
+// only fetches URLs it handed out, along with the route that selects the
+// server's client, so proxy URLs stay valid for as long as the player
+// may request them (e.g. to seek).
+type streamProxy struct {
+	// returns the HTTP client of the route's server,
+	// or nil if the server is no longer connected
+	client func(route string) *http.Client
+
+	key []byte
+
+	lock     sync.Mutex
+	listener net.Listener
+}
+
+func newStreamProxy(client func(route string) *http.Client) *streamProxy {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return &streamProxy{client: client, key: key}
+}
+
+// URL returns the proxy URL through which the player streams rawURL
+// with the HTTP client of the route's server, starting the proxy if
+// it isn't running.
+func (p *streamProxy) URL(route, rawURL string) (string, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.listener == nil {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return "", err
+		}
+		p.listener = l
+		go http.Serve(l, p)
+	}
+	enc := base64.RawURLEncoding
+	return fmt.Sprintf("http://%s/%s/%s/%s", p.listener.Addr(), route,
+		enc.EncodeToString([]byte(rawURL)), enc.EncodeToString(p.sign(route, rawURL))), nil
+}
+
+// Serves returns whether the URL is one returned by URL.
+func (p *streamProxy) Serves(rawURL string) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.listener != nil && strings.HasPrefix(rawURL, fmt.Sprintf("http://%s/", p.listener.Addr()))
+}
+
+func (p *streamProxy) sign(route, rawURL string) []byte {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(route + "\x00" + rawURL))
+	return mac.Sum(nil)
+}
+
+// parse returns the route and stream URL of a proxy URL path.
+func (p *streamProxy) parse(path string) (route, rawURL string, ok bool) {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(parts) != 3 {
+		return "", "", false
+	}
+	enc := base64.RawURLEncoding
+	u, err1 := enc.DecodeString(parts[1])
+	sig, err2 := enc.DecodeString(parts[2])
+	if err1 != nil || err2 != nil || !hmac.Equal(sig, p.sign(parts[0], string(u))) {
+		return "", "", false
+	}
+	return parts[0], string(u), true
+}
+
+func (p *streamProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	route, rawURL, ok := p.parse(r.URL.Path)
+	if !ok || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		http.NotFound(w, r)
+		return
+	}
+	cli := p.client(route)
+	if cli == nil {
+		http.Error(w, "server not connected", http.StatusBadGateway)
+		return
+	}
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, rawURL, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, h := range streamProxyRequestHeaders {
+		if v := r.Header.Values(h); len(v) > 0 {
+			req.Header[h] = v
+		}
+	}
+	resp, err := cli.Do(req)
+	if err != nil {
+		playbackLog.Warn("error proxying stream", "err", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for name, values := range resp.Header {
+		switch name {
+		case "Connection", "Keep-Alive", "Transfer-Encoding":
+			continue
+		}
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
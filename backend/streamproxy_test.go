@@ -0,0 +1,73 @@
+package backend
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamProxy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Auth") != "member" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.ServeContent(w, r, "stream", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	defer srv.Close()
+
+	member := newHTTPClient(0, "", false)
+	setServerHTTPSettings(member, map[string]string{"X-Auth": "member"}, nil)
+	p := newStreamProxy(func(route string) *http.Client {
+		if route == "aaaa" {
+			return member
+		}
+		return nil
+	})
+
+	get := func(url, rng string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		if rng != "" {
+			req.Header.Set("Range", rng)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+
+	proxyURL, err := p.URL("aaaa", srv.URL+"/rest/stream?id=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Serves(proxyURL) || p.Serves(srv.URL) {
+		t.Error("Serves should only report proxy URLs")
+	}
+	if code, body := get(proxyURL, ""); code != http.StatusOK || body != "0123456789" {
+		t.Errorf("got %d %q, want the stream fetched with the member's headers", code, body)
+	}
+	if code, body := get(proxyURL, "bytes=4-"); code != http.StatusPartialContent || body != "456789" {
+		t.Errorf("got %d %q for a range request", code, body)
+	}
+
+	// URLs not handed out by the proxy are not fetched
+	other, _ := p.URL("aaaa", srv.URL+"/rest/stream?id=2")
+	// the other stream URL with the signature of the first
+	forged := strings.Split(proxyURL, "/")
+	forged[len(forged)-2] = strings.Split(other, "/")[len(forged)-2]
+	if code, _ := get(strings.Join(forged, "/"), ""); code != http.StatusNotFound {
+		t.Errorf("got %d for a forged URL, want %d", code, http.StatusNotFound)
+	}
+
+	gone, _ := p.URL("bbbb", srv.URL+"/rest/stream?id=1")
+	if code, _ := get(gone, ""); code != http.StatusBadGateway {
+		t.Errorf("got %d for a disconnected server, want %d", code, http.StatusBadGateway)
+	}
+}
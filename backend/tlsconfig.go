@@ -0,0 +1,184 @@
+package backend
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidTLSConfig is returned when connecting to a server whose CA
+// bundle, client certificate or pinned fingerprint can't be used.
+var ErrInvalidTLSConfig = errors.New("invalid TLS settings")
+
+// hasCustomTLS returns whether the server has TLS settings beyond the defaults.
+func (c ServerConnection) hasCustomTLS() bool {
+	return c.CACertFile != "" || c.ClientCertFile != "" || c.PinnedCertSHA256 != ""
+}
+
+// serverTLSConfig returns the TLS config for connections to the server,
+// or nil if it has no custom TLS settings.
+func serverTLSConfig(conn ServerConnection) (*tls.Config, error) {
+	if !conn.hasCustomTLS() {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{InsecureSkipVerify: conn.SkipSSLVerify}
+	if conn.CACertFile != "" {
+		pool, err := loadCACertPool(conn.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidTLSConfig, err)
+		}
+		cfg.RootCAs = pool
+	}
+	if conn.ClientCertFile != "" {
+		cert, err := loadClientCertificate(conn.ClientCertFile, conn.ClientKeyFile, conn.ClientCertPassword)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidTLSConfig, err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if conn.PinnedCertSHA256 != "" {
+		pin, err := parseCertFingerprint(conn.PinnedCertSHA256)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidTLSConfig, err)
+		}
+		if cfg.RootCAs != nil {
+			// the chain is verified against the CA bundle as usual,
+			// and the pinned certificate must be part of it
+			cfg.InsecureSkipVerify = false
+			cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+				for _, chain := range cs.VerifiedChains {
+					if slices.ContainsFunc(chain, pinMatches(pin)) {
+						return nil
+					}
+				}
+				return errPinMismatch
+			}
+		} else {
+			// The pinned certificate (the server's own or one of its CAs) is
+			// trusted instead of the system CAs, so that self-signed servers
+			// can be pinned.
+			cfg.InsecureSkipVerify = true
+			cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+				return verifyPinnedChain(cs, pin)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+var errPinMismatch = errors.New("server certificate does not match the pinned fingerprint")
+
+func pinMatches(pin []byte) func(*x509.Certificate) bool {
+	return func(c *x509.Certificate) bool {
+		sum := sha256.Sum256(c.Raw)
+		return bytes.Equal(sum[:], pin)
+	}
+}
+
+// verifyPinnedChain accepts the server's certificate if it is the pinned
+// certificate, or if it chains up to the pinned certificate as the only
+// trusted root. The other certificates sent by the server are only used
+// as intermediates.
+func verifyPinnedChain(cs tls.ConnectionState, pin []byte) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errPinMismatch
+	}
+	leaf := cs.PeerCertificates[0]
+	if pinMatches(pin)(leaf) {
+		return nil
+	}
+	i := slices.IndexFunc(cs.PeerCertificates[1:], pinMatches(pin))
+	if i < 0 {
+		return errPinMismatch
+	}
+	opts := x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         x509.NewCertPool(),
+		Intermediates: x509.NewCertPool(),
+	}
+	for j, c := range cs.PeerCertificates[1:] {
+		if j == i {
+			opts.Roots.AddCert(c)
+		} else {
+			opts.Intermediates.AddCert(c)
+		}
+	}
+	if _, err := leaf.Verify(opts); err != nil {
+		return fmt.Errorf("%w: %w", errPinMismatch, err)
+	}
+	return nil
+}
+
+// loadCACertPool returns the system CAs plus the CAs of the PEM bundle.
+func loadCACertPool(path string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", path)
+	}
+	return pool, nil
+}
+
+// loadClientCertificate loads a client certificate and key from PEM files,
+// or from a PKCS#12 (.p12/.pfx) bundle if the certificate file is not PEM.
+// The key may be in the certificate file, in which case keyPath is empty.
+func loadClientCertificate(certPath, keyPath, password string) (tls.Certificate, error) {
+	certData, err := os.ReadFile(certPath)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if block, _ := pem.Decode(certData); block == nil {
+		return decodePKCS12(certData, password)
+	}
+	keyData := certData
+	if keyPath != "" {
+		if keyData, err = os.ReadFile(keyPath); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+	return tls.X509KeyPair(certData, keyData)
+}
+
+// isPEMFile returns whether the file starts with a PEM block.
+func isPEMFile(path string) bool {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	block, _ := pem.Decode(b)
+	return block != nil
+}
+
+// parseCertFingerprint parses a hex SHA-256 fingerprint,
+// with or without colons, as shown by browsers and openssl.
+func parseCertFingerprint(fp string) ([]byte, error) {
+	fp = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(fp)), "sha256:")
+	b, err := hex.DecodeString(strings.NewReplacer(":", "", " ", "").Replace(fp))
+	if err != nil || len(b) != sha256.Size {
+		return nil, errors.New("pinned fingerprint must be a hex SHA-256 hash")
+	}
+	return b, nil
+}
+
+// setTLSConfig sets the TLS config of a client created by newHTTPClient.
+func setTLSConfig(cli *http.Client, cfg *tls.Config) {
+	if t, ok := cli.Transport.(*http.Transport); ok && cfg != nil {
+		t.TLSClientConfig = cfg
+	}
+}
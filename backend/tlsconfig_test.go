@@ -0,0 +1,261 @@
+package backend
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// client certificate (CN=client) and P-256 key with the password "secret",
+// encrypted with AES-256 and PBKDF2 as by current openssl versions
+const modernP12 = "" +
+	"MIIEDAIBAzCCA8IGCSqGSIb3DQEHAaCCA7MEggOvMIIDqzCCAmIGCSqGSIb3DQEHBqCCAlMw" +
+	"ggJPAgEAMIICSAYJKoZIhvcNAQcBMFcGCSqGSIb3DQEFDTBKMCkGCSqGSIb3DQEFDDAcBAj5" +
+	"44u0sGNrGAICCAAwDAYIKoZIhvcNAgkFADAdBglghkgBZQMEASoEEDbV0+pe80l/GYccyvGa" +
+	"BQaAggHgPULAJHPNa+gMYrzp6RYLsgJh8lXJC4r87QNQIwA0TQmW0OZ9ph+o7aqVpR3POkDx" +
+	"Mi4rhZvHO3dXo13QX+c3/493PRhrK3EcFr6dzsMd0HXMWdGfP8QcvVRwJ15am42mwJ0k0pw3" +
+	"A0NnOe2rKx/7B8NTPN84vNTzTNm/SiJW9Ju7dy3dUrehrXJCow3CxpnnuBm05P3cfyyZAC1B" +
+	"mfHBbIL9D4luZ3WvmfrOFcA2avi1eZDBpBiuFCQnBLat2toNYP6DZvIvEjSDcNmTZCkf53di" +
+	"zuvyG7KaZNi3lWUN7uTXm30gCfBCsODlrTUhkC049EVl6hstuNaOTF6LI14e6Ume9ER58L2y" +
+	"TGLAR9aePw2b4UO6+9W8C8jpFZ+I3nLHhVhY3wBudTw+q7KaedtbZcUwvOBnWG6pd9c35g0h" +
+	"AgSsEHk866afzfY5xXsrgPBNeqsWgGRwjDzjnlolANPL8fJDjyOvGAPoiq3EcT12SdS7Fmip" +
+	"Yg1nHdVG1wcWXStegGFhaAR4mVx4M6teGQIz+FG30dLLWv7/hQCU8Mr9xeYKzSR+qxcIISV9" +
+	"dUTe/DU5KwPdFhUTyaSZrw7lWXZwg1a2GUgh+pfhs1+/w04XCoO8rJ/7jQQ+V8NObuudQ7tA" +
+	"MIIBQQYJKoZIhvcNAQcBoIIBMgSCAS4wggEqMIIBJgYLKoZIhvcNAQwKAQKgge8wgewwVwYJ" +
+	"KoZIhvcNAQUNMEowKQYJKoZIhvcNAQUMMBwECOFcftb+dEuZAgIIADAMBggqhkiG9w0CCQUA" +
+	"MB0GCWCGSAFlAwQBKgQQwGIkZyV4qRzC5uMCuGwfGASBkJ1VRE1IcTCfwNgRmpcPJsdnyPIS" +
+	"49TuIql/EXo29PG0NtfHLVCIsNCeIwoAKL74dI2DA99GUgwZkED/XJdVYjieFlvp90NiJa/J" +
+	"HKESLCxZFoDhOCs9w92+LjZ9Qkw1rUei06VorM1yoNnslTgP7Kmz1yYj/0oiX06umjVtDaRM" +
+	"uljMQpofRjoonbHAyIZPjDElMCMGCSqGSIb3DQEJFTEWBBQDZmTeXtobgJH6GIOP+KnJF+bQ" +
+	"yzBBMDEwDQYJYIZIAWUDBAIBBQAEIOckUdkSM2x536FcNPF+opmiRt+UIq0+2b+JS+tw2x+z" +
+	"BAiPi2ZeZcOfUQICCAA="
+
+// the same, encrypted with 3DES as by older tools
+const legacyP12 = "" +
+	"MIIDegIBAzCCA0AGCSqGSIb3DQEHAaCCAzEEggMtMIIDKTCCAh8GCSqGSIb3DQEHBqCCAhAw" +
+	"ggIMAgEAMIICBQYJKoZIhvcNAQcBMBwGCiqGSIb3DQEMAQMwDgQIb2+qVr4P27ACAggAgIIB" +
+	"2NktldY6I8zaerFfR+tGjM9khVCpxjwULX1763zQ0b80E6vQPTZGb/gQTTANTLJEhyKGmnG8" +
+	"rf4+hpLMawX6m6Gi5y4SdvTyrbLYPsjmzdMXxMElejWBSouXpmQakaEq6j3WKuIstSZaGhMi" +
+	"qSZ3t7HhU2FCP4QFVWOzZVg+8lctphSDTV/ZTLTuaj9GuzLBExoiovTF9gSBfMYgPycvV8Hz" +
+	"I+HjpIIPiNs+NKlfKJik3STCryyovZTeJMzJBzvGlalxql3WL5aHvAs62H77C5MNw8YLmASR" +
+	"CHzUbUqZeeKLRVsqT+EwGZR7QnxamQ10apfEyj4Goy4zexe6AbmvcGYwbM7gaEym2r6YUM5R" +
+	"Wsqs5Zk2y3L/5FCRffAJqpJfNsByfz5EiHyevTSB7gl9NYo2ciOuch+EyJdPktaPuHzOIuII" +
+	"kxTon/DBXXg2XP1UXnlvVlvTdSgK+U3LRXZvz3MxZJrWloRXYfJbBdiz7IXOu8+VOnjy2OPr" +
+	"1ykV9P1aG/NftNrtL6sZC+0TCgvYm6S5rKasGEBDg8vQ3mKIH0ye8plUHQURKROQEfHPY/JS" +
+	"pbE0PuW5iZ/u3GnC+Hwq75u2kOrjtmBNSI1g6tAW1cwznd7JyewEyL4wggECBgkqhkiG9w0B" +
+	"BwGggfQEgfEwge4wgesGCyqGSIb3DQEMCgECoIG0MIGxMBwGCiqGSIb3DQEMAQMwDgQI4Qtq" +
+	"eE7Q5ksCAggABIGQuc5JPOT37wQVziqEwy9+Adr+ZsfjqVJJD+TlzaDhrt91lBcBcmlA9gyM" +
+	"XooKVaoo4BSw25hXFEP6OwhvXDuMAeNsubwtVX5m1H/dajxvknHufCw4OhR1KbCjw0qxsmf2" +
+	"qUj/ALNHv0Vzoxp8AnUnwueUicfQN6aH8fVSo4GWC9Ey9eLxIL1XdN2pwFOHX65/MSUwIwYJ" +
+	"KoZIhvcNAQkVMRYEFANmZN5e2huAkfoYg4/4qckX5tDLMDEwITAJBgUrDgMCGgUABBQKGpWJ" +
+	"3IWek9Yuoa/omVSsX1EbMAQIWpb3RCvwLdICAggA"
+
+const clientCertSHA256 = "58:36:78:ED:E6:B2:82:9C:75:E0:AA:75:15:A1:E8:C3:D9:04:D5:AC:50:AF:C9:18:D1:27:6F:8C:93:F7:6A:F0"
+
+func TestDecodePKCS12(t *testing.T) {
+	for name, p12 := range map[string]string{"modern": modernP12, "legacy": legacyP12} {
+		data, err := base64.StdEncoding.DecodeString(p12)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := decodePKCS12(data, "secret")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if cn := cert.Leaf.Subject.CommonName; cn != "client" {
+			t.Errorf("%s: got subject %q, want %q", name, cn, "client")
+		}
+		pin, _ := parseCertFingerprint(clientCertSHA256)
+		if sum := sha256.Sum256(cert.Certificate[0]); hex.EncodeToString(sum[:]) != hex.EncodeToString(pin) {
+			t.Errorf("%s: certificate fingerprint mismatch", name)
+		}
+		if cert.PrivateKey == nil {
+			t.Errorf("%s: missing private key", name)
+		}
+		if _, err := decodePKCS12(data, "wrong"); !errors.Is(err, errPKCS12Password) {
+			t.Errorf("%s: got error %v with wrong password, want %v", name, err, errPKCS12Password)
+		}
+		tampered := append([]byte(nil), data...)
+		tampered[len(tampered)/2] ^= 1
+		if _, err := decodePKCS12(tampered, "secret"); err == nil {
+			t.Errorf("%s: expected error for a tampered bundle", name)
+		}
+	}
+}
+
+func TestParseCertFingerprint(t *testing.T) {
+	for _, fp := range []string{
+		clientCertSHA256,
+		"583678ede6b2829c75e0aa7515a1e8c3d904d5ac50afc918d1276f8c93f76af0",
+		"sha256:58:36:78:ed:e6:b2:82:9c:75:e0:aa:75:15:a1:e8:c3:d9:04:d5:ac:50:af:c9:18:d1:27:6f:8c:93:f7:6a:f0 ",
+	} {
+		if _, err := parseCertFingerprint(fp); err != nil {
+			t.Errorf("parseCertFingerprint(%q): %v", fp, err)
+		}
+	}
+	for _, fp := range []string{"", "58:36:78", "zz" + clientCertSHA256[2:]} {
+		if _, err := parseCertFingerprint(fp); err == nil {
+			t.Errorf("parseCertFingerprint(%q): expected error", fp)
+		}
+	}
+}
+
+func TestServerTLSConfig_Pinned(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	sum := sha256.Sum256(srv.Certificate().Raw)
+
+	for _, tc := range []struct {
+		name    string
+		pin     string
+		wantErr bool
+	}{
+		{"matching pin", hex.EncodeToString(sum[:]), false},
+		{"other pin", clientCertSHA256, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := serverTLSConfig(ServerConnection{PinnedCertSHA256: tc.pin})
+			if err != nil {
+				t.Fatal(err)
+			}
+			cli := newHTTPClient(5*time.Second, "", false)
+			setTLSConfig(cli, cfg)
+			resp, err := cli.Get(srv.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if (err != nil) != tc.wantErr {
+				t.Errorf("got error %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+// newTestCert creates a certificate for 127.0.0.1, signed by parent
+// (self-signed if nil), and returns it with its key.
+func newTestCert(t *testing.T, cn string, isCA bool, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func TestServerTLSConfig_PinnedChain(t *testing.T) {
+	ca, caKey := newTestCert(t, "pinned CA", true, nil, nil)
+	issued, issuedKey := newTestCert(t, "server", false, ca, caKey)
+	untrusted, untrustedKey := newTestCert(t, "attacker", false, nil, nil)
+	other, _ := newTestCert(t, "other CA", true, nil, nil)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0600)
+	fingerprint := func(c *x509.Certificate) string {
+		sum := sha256.Sum256(c.Raw)
+		return hex.EncodeToString(sum[:])
+	}
+
+	for _, tc := range []struct {
+		name    string
+		chain   []*x509.Certificate
+		key     crypto.Signer
+		conn    ServerConnection
+		wantErr bool
+	}{
+		{"pinned leaf", []*x509.Certificate{untrusted}, untrustedKey,
+			ServerConnection{PinnedCertSHA256: fingerprint(untrusted)}, false},
+		{"leaf issued by pinned CA", []*x509.Certificate{issued, ca}, issuedKey,
+			ServerConnection{PinnedCertSHA256: fingerprint(ca)}, false},
+		{"untrusted leaf followed by pinned CA", []*x509.Certificate{untrusted, ca}, untrustedKey,
+			ServerConnection{PinnedCertSHA256: fingerprint(ca)}, true},
+		{"CA bundle and pinned CA", []*x509.Certificate{issued}, issuedKey,
+			ServerConnection{CACertFile: caFile, PinnedCertSHA256: fingerprint(ca)}, false},
+		{"CA bundle and other pin", []*x509.Certificate{issued}, issuedKey,
+			ServerConnection{CACertFile: caFile, PinnedCertSHA256: fingerprint(other)}, true},
+		{"CA bundle and untrusted pinned leaf", []*x509.Certificate{untrusted}, untrustedKey,
+			ServerConnection{CACertFile: caFile, PinnedCertSHA256: fingerprint(untrusted)}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			cert := tls.Certificate{PrivateKey: tc.key}
+			for _, c := range tc.chain {
+				cert.Certificate = append(cert.Certificate, c.Raw)
+			}
+			srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+			srv.StartTLS()
+			defer srv.Close()
+
+			cfg, err := serverTLSConfig(tc.conn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cli := newHTTPClient(5*time.Second, "", false)
+			setTLSConfig(cli, cfg)
+			resp, err := cli.Get(srv.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if (err != nil) != tc.wantErr {
+				t.Errorf("got error %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestServerTLSConfig_Invalid(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+	notCA := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(notCA, []byte("not a certificate"), 0600)
+
+	for _, conn := range []ServerConnection{
+		{CACertFile: missing},
+		{CACertFile: notCA},
+		{ClientCertFile: missing},
+		{PinnedCertSHA256: "not a fingerprint"},
+	} {
+		if _, err := serverTLSConfig(conn); !errors.Is(err, ErrInvalidTLSConfig) {
+			t.Errorf("serverTLSConfig(%+v): got error %v, want %v", conn, err, ErrInvalidTLSConfig)
+		}
+	}
+	if cfg, err := serverTLSConfig(ServerConnection{SkipSSLVerify: true}); cfg != nil || err != nil {
+		t.Errorf("expected no TLS config without custom settings, got %v, %v", cfg, err)
+	}
+}
@@ -2,7 +2,6 @@ package backend
 
 import (
 	"context"
-	"log"
 	"net/http"
 	"net/url"
 	"strings"
@@ -68,7 +67,7 @@ func (u *UpdateChecker) checkForUpdate() {
 func (u *UpdateChecker) CheckLatestVersionTag() string {
 	resp, err := http.Head(u.latestReleaseURL)
 	if err != nil {
-		log.Printf("failed to check for newest version: %s", err.Error())
+		appLog.Warn("failed to check for newest version", "err", err)
 		return ""
 	}
 	url := resp.Request.URL.String()
@@ -3,13 +3,17 @@ package util
 import (
 	"context"
 	"io"
-	"log"
 	"net"
 	"net/http"
 	"os"
 	"time"
+
+	"github.com/dweymouth/supersonic/backend/logging"
 )
 
+// the file streamer feeds partly downloaded tracks to the waveform generator
+var logger = logging.For(logging.Waveform)
+
 type FileStreamerServer struct {
 	Path       string
 	IsComplete func() bool
@@ -65,7 +69,7 @@ func (fs *FileStreamerServer) streamHandler(w http.ResponseWriter, _ *http.Reque
 
 	file, err := os.Open(fs.Path)
 	if err != nil {
-		log.Println("File streamer failed to open source file")
+		logger.Error("file streamer failed to open source file", "err", err)
 		http.Error(w, "could not open file", http.StatusInternalServerError)
 		return
 	}
@@ -92,7 +96,7 @@ func (fs *FileStreamerServer) streamHandler(w http.ResponseWriter, _ *http.Reque
 		}
 		n, err := file.Read(buf)
 		if err != nil && err != io.EOF {
-			log.Printf("read error: %v", err)
+			logger.Error("file streamer read error", "err", err)
 			break
 		}
 		bytesRead += int64(n)
@@ -100,7 +104,7 @@ func (fs *FileStreamerServer) streamHandler(w http.ResponseWriter, _ *http.Reque
 		if n > 0 {
 			_, err := w.Write(buf[:n])
 			if err != nil {
-				log.Printf("client write error: %v", err)
+				logger.Warn("file streamer client write error", "err", err)
 				break
 			}
 			if canFlush {
@@ -0,0 +1,182 @@
+package util
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var mdnsGroupAddr = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// MDNSService is a service instance found on the local network by BrowseMDNS.
+type MDNSService struct {
+	// Instance name, e.g. "living-room._googlecast._tcp.local."
+	Instance string
+	// host:port of the service
+	Addr string
+	// key=value pairs from the TXT record
+	TXT map[string]string
+}
+
+// BrowseMDNS sends a one-shot mDNS query for the given service type
+// (e.g. "_googlecast._tcp.local.") and returns the instances
+// that responded within the wait time.
+func BrowseMDNS(ctx context.Context, service string, wait time.Duration) ([]MDNSService, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	query, err := buildMDNSQuery(service)
+	if err != nil {
+		return nil, err
+	}
+	// Sent from an ephemeral port, so responders will
+	// reply directly to us rather than to the multicast group
+	if _, err := conn.WriteToUDP(query, mdnsGroupAddr); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(wait)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetReadDeadline(time.Now()) // unblock read
+		case <-done:
+		}
+	}()
+
+	records := newMDNSRecords(service)
+	buf := make([]byte, 9000)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			break // deadline reached or canceled
+		}
+		records.parse(buf[:n], src.IP)
+	}
+	return records.services(), nil
+}
+
+func buildMDNSQuery(service string) ([]byte, error) {
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{})
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{
+		Name:  dnsmessage.MustNewName(service),
+		Type:  dnsmessage.TypePTR,
+		Class: dnsmessage.ClassINET,
+	}); err != nil {
+		return nil, err
+	}
+	return b.Finish()
+}
+
+type mdnsInstance struct {
+	target string
+	port   uint16
+	txt    map[string]string
+	srcIP  net.IP
+}
+
+// accumulates records from mDNS responses, since a device's
+// PTR, SRV, TXT and A records may arrive in separate packets
+type mdnsRecords struct {
+	service   string
+	instances map[string]*mdnsInstance
+	hosts     map[string]net.IP
+	order     []string // instance names in order of discovery
+}
+
+func newMDNSRecords(service string) *mdnsRecords {
+	return &mdnsRecords{
+		service:   strings.ToLower(service),
+		instances: make(map[string]*mdnsInstance),
+		hosts:     make(map[string]net.IP),
+	}
+}
+
+func (r *mdnsRecords) instance(name string, srcIP net.IP) *mdnsInstance {
+	inst, ok := r.instances[name]
+	if !ok {
+		inst = &mdnsInstance{txt: make(map[string]string), srcIP: srcIP}
+		r.instances[name] = inst
+		r.order = append(r.order, name)
+	}
+	return inst
+}
+
+func (r *mdnsRecords) parse(msg []byte, srcIP net.IP) {
+	var p dnsmessage.Parser
+	if _, err := p.Start(msg); err != nil {
+		return
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return
+	}
+	answers, err := p.AllAnswers()
+	if err != nil {
+		return
+	}
+	p.SkipAllAuthorities()
+	additionals, _ := p.AllAdditionals()
+
+	for _, res := range append(answers, additionals...) {
+		name := strings.ToLower(res.Header.Name.String())
+		switch body := res.Body.(type) {
+		case *dnsmessage.PTRResource:
+			if name == r.service {
+				r.instance(strings.ToLower(body.PTR.String()), srcIP)
+			}
+		case *dnsmessage.SRVResource:
+			if strings.HasSuffix(name, "."+r.service) {
+				inst := r.instance(name, srcIP)
+				inst.target = strings.ToLower(body.Target.String())
+				inst.port = body.Port
+			}
+		case *dnsmessage.TXTResource:
+			if strings.HasSuffix(name, "."+r.service) {
+				inst := r.instance(name, srcIP)
+				for _, kv := range body.TXT {
+					if k, v, ok := strings.Cut(kv, "="); ok {
+						inst.txt[k] = v
+					}
+				}
+			}
+		case *dnsmessage.AResource:
+			r.hosts[name] = net.IP(body.A[:])
+		}
+	}
+}
+
+func (r *mdnsRecords) services() []MDNSService {
+	var services []MDNSService
+	for _, name := range r.order {
+		inst := r.instances[name]
+		if inst.port == 0 {
+			continue // never received SRV record
+		}
+		ip, ok := r.hosts[inst.target]
+		if !ok {
+			ip = inst.srcIP
+		}
+		services = append(services, MDNSService{
+			Instance: name,
+			Addr:     net.JoinHostPort(ip.String(), strconv.Itoa(int(inst.port))),
+			TXT:      inst.txt,
+		})
+	}
+	return services
+}
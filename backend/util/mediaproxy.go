@@ -0,0 +1,193 @@
+package util
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type proxyMapEntry struct {
+	key string
+	url string
+}
+
+// MediaProxy is a local HTTP server for remote playback devices,
+// which serves locally cached files and proxies stream URLs that the
+// device may not be able to reach directly (e.g. a server on localhost).
+type MediaProxy struct {
+	server *http.Server
+	active atomic.Bool
+
+	// guarded by urlLock
+	localIP string
+	port    int
+
+	// keep in order of most recently accessed at the end
+	// that way the item in urls[0] can be kicked out
+	// when adding a new URL to the proxy, since only the
+	// stream and cover art for the current and next track
+	// will need to be active at any given time
+	urls    [4]proxyMapEntry
+	urlLock sync.Mutex
+}
+
+// EnsureStarted starts the proxy server if it is not already running.
+func (m *MediaProxy) EnsureStarted() error {
+	if m.active.Swap(true) {
+		return nil // already active
+	}
+
+	localIP, err := GetLocalIP()
+	if err != nil {
+		m.active.Store(false)
+		return err
+	}
+
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		m.active.Store(false)
+		return err
+	}
+	m.urlLock.Lock()
+	m.localIP = localIP
+	m.port = listener.Addr().(*net.TCPAddr).Port
+	m.urlLock.Unlock()
+
+	m.server = &http.Server{
+		Handler: http.HandlerFunc(m.handleRequest),
+	}
+
+	go m.server.Serve(listener)
+	return nil
+}
+
+// Add registers a URL or local file path with the proxy
+// and returns the proxy URL at which it is served.
+func (m *MediaProxy) Add(url string) string {
+	hash := md5.Sum([]byte(url))
+	key := base64.URLEncoding.EncodeToString(hash[:])
+	m.urlLock.Lock()
+	defer m.urlLock.Unlock()
+	m._updateProxyURL(key, url)
+	return fmt.Sprintf("http://%s:%d/%s", m.localIP, m.port, key)
+}
+
+// Serves returns true if the URL is one returned by Add.
+func (m *MediaProxy) Serves(url string) bool {
+	if !m.active.Load() {
+		return false
+	}
+	m.urlLock.Lock()
+	defer m.urlLock.Unlock()
+	return strings.HasPrefix(url, fmt.Sprintf("http://%s:%d/", m.localIP, m.port))
+}
+
+func (m *MediaProxy) Shutdown() {
+	if m.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		go func() {
+			defer cancel()
+			m.server.Shutdown(ctx)
+		}()
+		m.server = nil
+	}
+	m.active.Store(false)
+}
+
+func (m *MediaProxy) handleRequest(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	url, _ := m.lookupProxyURL(key)
+
+	if url == "" {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("404"))
+		return
+	}
+
+	// if the url is a filepath for a local cached file, serve it
+	if info, err := os.Stat(url); err == nil && info.Size() > 0 {
+		http.ServeFile(w, r, url)
+		return
+	}
+
+	// Otherwise, proxy request to the music server
+	proxyReq, err := http.NewRequest(r.Method, url, r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Copy headers from the original request to the new request
+	proxyReq.Header = r.Header
+
+	// Create an HTTP client and send the request
+	client := &http.Client{}
+	resp, err := client.Do(proxyReq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	// Copy headers from the response to the writer
+	for name, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+
+	// Set the status code
+	w.WriteHeader(resp.StatusCode)
+
+	// Copy the response body to the writer
+	_, err = io.Copy(w, resp.Body)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error copying response body:", err)
+	}
+}
+
+// lookupProxyURL finds a URL by key and updates its position to most recently used
+func (m *MediaProxy) lookupProxyURL(key string) (string, bool) {
+	m.urlLock.Lock()
+	defer m.urlLock.Unlock()
+
+	for i := range len(m.urls) {
+		if m.urls[i].key == key {
+			url := m.urls[i].url
+			// Move accessed entry to the most recent position
+			m._updateProxyURL(key, url)
+			return url, true
+		}
+	}
+
+	return "", false
+}
+
+func (m *MediaProxy) _updateProxyURL(key, url string) {
+	// Check if the key already exists, and if so, move it to the most recently used position
+	for i := range len(m.urls) {
+		if m.urls[i].key == key {
+			if i < len(m.urls)-1 {
+				// Shift elements to the left from found position to the end
+				copy(m.urls[i:], m.urls[i+1:])
+			}
+			// Place updated entry at the last position
+			m.urls[len(m.urls)-1] = proxyMapEntry{key: key, url: url}
+			return
+		}
+	}
+
+	// Shift all elements left to make room for the new entry at the end
+	copy(m.urls[:], m.urls[1:])
+	// Insert new element at the most recent position
+	m.urls[len(m.urls)-1] = proxyMapEntry{key: key, url: url}
+}
@@ -7,7 +7,6 @@ import (
 	"image"
 	"image/color"
 	"io"
-	"log"
 	"math"
 	"os"
 	"path/filepath"
@@ -469,14 +468,14 @@ func (w *WaveformImageGenerator) convertToWav(ctx context.Context, id, inPath, o
 			e := m.WaitEvent(0.05 /*timeout seconds*/)
 			if e.Event_Id == mpv.EVENT_IDLE {
 				if _, err := os.Stat(outPath); os.IsNotExist(err) {
-					log.Printf("WARNING! file %s does not exist after MPV convert", outPath)
+					waveformLog.Warn("file does not exist after MPV convert", "path", outPath)
 				}
 				return nil
 			}
 			ia := m.GetPropertyString("idle-active")
 			if ia == "yes" || ia == "true" {
 				if _, err := os.Stat(outPath); os.IsNotExist(err) {
-					log.Printf("WARNING! file %s does not exist after MPV convert", outPath)
+					waveformLog.Warn("file does not exist after MPV convert", "path", outPath)
 				}
 				return nil
 			}
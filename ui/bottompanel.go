@@ -110,12 +110,17 @@ func NewBottomPanel(pm *backend.PlaybackManager, im *backend.ImageManager, contr
 	bp.Controls.OnChangeShuffle = func(shuffle bool) {
 		pm.SetShuffle(shuffle)
 	}
+	bp.Controls.SetShuffleMode(pm.ShuffleMode())
+	bp.Controls.OnChangeShuffleMode = pm.SetShuffleMode
 	pm.OnLoopModeChange(func(lm backend.LoopMode) {
 		fyne.Do(func() { bp.Controls.SetLoopMode(lm) })
 	})
 	pm.OnShuffleChange(func(sh bool) {
 		fyne.Do(func() { bp.Controls.SetShuffle(sh) })
 	})
+	pm.OnShuffleModeChange(func(mode string, artistSpread bool) {
+		fyne.Do(func() { bp.Controls.SetShuffleMode(mode, artistSpread) })
+	})
 
 	bp.AuxControls = widgets.NewAuxControls(pm.Volume(), pm.IsAutoplay())
 	pm.OnVolumeChange(func(vol int) {
@@ -16,19 +16,21 @@ import (
 )
 
 type albumsPageAdapter struct {
-	cfg       *backend.AlbumsPageConfig
-	contr     *controller.Controller
-	mp        mediaprovider.MediaProvider
-	pm        *backend.PlaybackManager
-	filter    mediaprovider.AlbumFilter
-	filterBtn *widgets.AlbumFilterButton
+	cfg *backend.AlbumsPageConfig
+	// the filter remembered for the current server
+	savedFilter *mediaprovider.AlbumFilterOptions
+	contr       *controller.Controller
+	mp          mediaprovider.MediaProvider
+	pm          *backend.PlaybackManager
+	filter      mediaprovider.AlbumFilter
+	filterBtn   *widgets.AlbumFilterButton
 
 	// dependency injected from the GridViewPage
 	itemsFn func() []widgets.GridViewItemModel
 }
 
-func NewAlbumsPage(cfg *backend.AlbumsPageConfig, pool *util.WidgetPool, contr *controller.Controller, pm *backend.PlaybackManager, mp mediaprovider.MediaProvider, im *backend.ImageManager) Page {
-	adapter := &albumsPageAdapter{cfg: cfg, contr: contr, mp: mp, pm: pm}
+func NewAlbumsPage(cfg *backend.AlbumsPageConfig, filter *mediaprovider.AlbumFilterOptions, pool *util.WidgetPool, contr *controller.Controller, pm *backend.PlaybackManager, mp mediaprovider.MediaProvider, im *backend.ImageManager) Page {
+	adapter := &albumsPageAdapter{cfg: cfg, savedFilter: filter, contr: contr, mp: mp, pm: pm}
 	return NewGridViewPage(adapter, pool, mp, im)
 }
 
@@ -42,9 +44,7 @@ func (a *albumsPageAdapter) Title() string { return lang.L("Albums") }
 
 func (a *albumsPageAdapter) Filter() mediaprovider.AlbumFilter {
 	if a.filter == nil {
-		a.filter = mediaprovider.NewAlbumFilter(
-			mediaprovider.AlbumFilterOptions{},
-		)
+		a.filter = mediaprovider.NewAlbumFilter(a.savedFilter.Clone())
 	}
 	return a.filter
 }
@@ -147,6 +147,8 @@ func (a *albumsPageAdapter) ActionButton() fyne.CanvasObject {
 
 func (a *albumsPageAdapter) Iter(sortOrderIdx int, filter mediaprovider.AlbumFilter) widgets.GridViewIterator {
 	sortOrder := a.mp.AlbumSortOrders()[sortOrderIdx]
+	// remember the filter choice (the iterator is recreated on every change)
+	*a.savedFilter = filter.Options().Clone()
 	return widgets.NewGridViewAlbumIterator(a.mp.IterateAlbums(sortOrder, filter))
 }
 
@@ -642,7 +642,12 @@ func NewArtistPageHeader(page *ArtistPage) *ArtistPageHeader {
 				go a.artistPage.pm.ShuffleArtistAlbums(a.artistID)
 			})
 			shuffleAlbums.Icon = myTheme.AlbumIcon
-			menu := fyne.NewMenu("", shuffleTracks, shuffleAlbums)
+			customRadio := fyne.NewMenuItem(lang.L("Start custom radio")+"...", func() {
+				name := a.titleDisp.Segments[0].(*widget.TextSegment).Text
+				a.artistPage.contr.ShowArtistCustomRadioDialog(a.artistID, name)
+			})
+			customRadio.Icon = myTheme.RadioIcon
+			menu := fyne.NewMenu("", shuffleTracks, shuffleAlbums, customRadio)
 			pop = widget.NewPopUpMenu(menu, fyne.CurrentApp().Driver().CanvasForObject(a))
 		}
 		pos := fyne.CurrentApp().Driver().AbsolutePositionForObject(a.menuBtn)
@@ -40,6 +40,13 @@ type Scrollable interface {
 	Scroll(amount float32)
 }
 
+// Pages that can report and restore their scroll position should implement
+// this interface so it can be remembered across app sessions.
+type ScrollPositioner interface {
+	ScrollPosition() float32
+	SetScrollPosition(pos float32)
+}
+
 type CanShowNowPlaying interface {
 	OnSongChange(playing mediaprovider.MediaItem, lastScrobbledIfAny *mediaprovider.Track)
 }
@@ -60,7 +67,7 @@ type BrowsingPane struct {
 	playbackManager *backend.PlaybackManager
 
 	curPage    Page
-	history    []SavedPage
+	history    []historyEntry
 	historyIdx int
 
 	pageContainer *fyne.Container
@@ -93,6 +100,48 @@ func (b *BrowsingPane) SetPage(p Page) {
 	b.onHistoryChanged()
 }
 
+// HistoryEntry is a serializable record of a page in the browsing history.
+type HistoryEntry struct {
+	Route     controller.Route
+	ScrollPos float32
+}
+
+type historyEntry struct {
+	HistoryEntry
+	saved SavedPage
+}
+
+// HistoryState returns the browsing history, oldest first,
+// including the current page, and the index of the current page.
+func (b *BrowsingPane) HistoryState() ([]HistoryEntry, int) {
+	if b.curPage == nil {
+		return nil, 0
+	}
+	entries := make([]HistoryEntry, max(len(b.history), b.historyIdx+1))
+	for i, h := range b.history {
+		entries[i] = h.HistoryEntry
+	}
+	entries[b.historyIdx] = newHistoryEntry(b.curPage).HistoryEntry
+	return entries, b.historyIdx
+}
+
+// RestoreHistoryState replaces the browsing history with the given entries
+// and navigates to the current one. Pages are created with createPage
+// only when they are navigated to.
+func (b *BrowsingPane) RestoreHistoryState(entries []HistoryEntry, curIdx int, createPage func(controller.Route) Page) {
+	if curIdx < 0 || curIdx >= len(entries) {
+		return
+	}
+	b.history = make([]historyEntry, len(entries))
+	for i, e := range entries {
+		b.history[i] = historyEntry{HistoryEntry: e, saved: &routeSavedPage{entry: e, createPage: createPage}}
+	}
+	b.historyIdx = curIdx
+	b.curPage = nil
+	b.doSetPage(b.history[curIdx].saved.Restore())
+	b.onHistoryChanged()
+}
+
 func (b *BrowsingPane) ClearHistory() {
 	b.history = nil
 	b.historyIdx = 0
@@ -208,14 +257,16 @@ func (b *BrowsingPane) addPageToHistory(p Page, truncate bool) {
 	if truncate {
 		// allow garbage collection of pages that will be removed from the history
 		for i := b.historyIdx; i < len(b.history); i++ {
-			b.history[i] = nil
+			b.history[i] = historyEntry{}
 		}
 		b.history = b.history[:b.historyIdx]
 	}
+	entry := newHistoryEntry(p)
+	entry.saved = p.Save()
 	if b.historyIdx < len(b.history) {
-		b.history[b.historyIdx] = p.Save()
+		b.history[b.historyIdx] = entry
 	} else {
-		b.history = append(b.history, p.Save())
+		b.history = append(b.history, entry)
 	}
 	b.historyIdx++
 }
@@ -232,7 +283,7 @@ func (b *BrowsingPane) GoBack() {
 	if b.historyIdx > 0 {
 		// due to widget reuse between pages,
 		// we must create the new page before calling addPageToHistory
-		p := b.history[b.historyIdx-1].Restore()
+		p := b.history[b.historyIdx-1].saved.Restore()
 		b.addPageToHistory(b.curPage, false)
 		b.historyIdx -= 2
 		b.doSetPage(p)
@@ -242,7 +293,7 @@ func (b *BrowsingPane) GoBack() {
 
 func (b *BrowsingPane) GoForward() {
 	if b.historyIdx < len(b.history)-1 {
-		p := b.history[b.historyIdx+1].Restore()
+		p := b.history[b.historyIdx+1].saved.Restore()
 		b.addPageToHistory(b.curPage, false)
 		b.doSetPage(p)
 		b.onHistoryChanged()
@@ -265,3 +316,27 @@ func (b *BrowsingPane) CurrentPage() controller.Route {
 func (b *BrowsingPane) CreateRenderer() fyne.WidgetRenderer {
 	return widget.NewSimpleRenderer(b.pageContainer)
 }
+
+// must be called before p.Save(), which may release p's widgets
+func newHistoryEntry(p Page) historyEntry {
+	e := historyEntry{HistoryEntry: HistoryEntry{Route: p.Route()}}
+	if s, ok := p.(ScrollPositioner); ok {
+		e.ScrollPos = s.ScrollPosition()
+	}
+	return e
+}
+
+// SavedPage for a history entry restored from a previous session,
+// which creates the page anew when restored.
+type routeSavedPage struct {
+	entry      HistoryEntry
+	createPage func(controller.Route) Page
+}
+
+func (r *routeSavedPage) Restore() Page {
+	p := r.createPage(r.entry.Route)
+	if s, ok := p.(ScrollPositioner); ok && r.entry.ScrollPos > 0 {
+		s.SetScrollPosition(r.entry.ScrollPos)
+	}
+	return p
+}
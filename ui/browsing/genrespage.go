@@ -33,6 +33,9 @@ type GenresPage struct {
 	titleDisp *widget.RichText
 	container *fyne.Container
 	searcher  *widgets.SearchEntry
+
+	// applied once the page has loaded
+	initialScrollPos float32
 }
 
 func NewGenresPage(contr *controller.Controller, mp mediaprovider.MediaProvider) *GenresPage {
@@ -41,9 +44,10 @@ func NewGenresPage(contr *controller.Controller, mp mediaprovider.MediaProvider)
 
 func newGenresPage(contr *controller.Controller, mp mediaprovider.MediaProvider, searchText string, sorting widgets.ListHeaderSort, scrollPos float32) *GenresPage {
 	a := &GenresPage{
-		contr:     contr,
-		mp:        mp,
-		titleDisp: widget.NewRichTextWithText(lang.L("Genres")),
+		contr:            contr,
+		mp:               mp,
+		initialScrollPos: scrollPos,
+		titleDisp:        widget.NewRichTextWithText(lang.L("Genres")),
 	}
 	a.ExtendBaseWidget(a)
 	a.titleDisp.Segments[0].(*widget.TextSegment).Style.SizeName = theme.SizeNameHeadingText
@@ -54,18 +58,20 @@ func newGenresPage(contr *controller.Controller, mp mediaprovider.MediaProvider,
 	a.searcher.OnSearched = a.onSearched
 	a.searcher.Entry.Text = searchText
 	a.buildContainer()
-	go a.load(searchText != "", scrollPos)
+	go a.load(searchText != "")
 	return a
 }
 
 // should be called asynchronously
-func (a *GenresPage) load(searchOnLoad bool, scrollPos float32) {
+func (a *GenresPage) load(searchOnLoad bool) {
 	genres, err := a.mp.GetGenres()
 	if err != nil {
 		log.Printf("error loading genres: %v", err.Error())
 	}
 	fyne.Do(func() {
 		a.genres = genres
+		scrollPos := a.initialScrollPos
+		a.initialScrollPos = 0
 		if searchOnLoad {
 			a.onSearched(a.searcher.Entry.Text)
 			if scrollPos != 0 {
@@ -104,17 +110,26 @@ func (a *GenresPage) SearchWidget() fyne.Focusable {
 }
 
 var _ Scrollable = (*GenresPage)(nil)
+var _ ScrollPositioner = (*GenresPage)(nil)
 
 func (a *GenresPage) Scroll(amount float32) {
 	a.list.list.ScrollToOffset(a.list.list.GetScrollOffset() + amount)
 }
 
+func (a *GenresPage) ScrollPosition() float32 {
+	return a.list.list.GetScrollOffset()
+}
+
+func (a *GenresPage) SetScrollPosition(pos float32) {
+	a.initialScrollPos = pos
+}
+
 func (a *GenresPage) Route() controller.Route {
 	return controller.GenresRoute()
 }
 
 func (a *GenresPage) Reload() {
-	go a.load(false, 0)
+	go a.load(false)
 }
 
 func (a *GenresPage) Save() SavedPage {
@@ -191,6 +191,14 @@ func (g *GridViewPage[M, F]) Scroll(scrollAmt float32) {
 	g.grid.ScrollToOffset(g.grid.GetScrollOffset() + scrollAmt)
 }
 
+func (g *GridViewPage[M, F]) ScrollPosition() float32 {
+	return g.grid.GetScrollOffset()
+}
+
+func (g *GridViewPage[M, F]) SetScrollPosition(pos float32) {
+	g.grid.ScrollToOffsetWhenLoaded(pos)
+}
+
 func (g *GridViewPage[M, F]) OnSearched(query string) {
 	if query == "" {
 		if g.sortOrder != nil {
@@ -21,6 +21,7 @@ import (
 	"github.com/dweymouth/supersonic/ui/layouts"
 	myTheme "github.com/dweymouth/supersonic/ui/theme"
 	"github.com/dweymouth/supersonic/ui/util"
+	"github.com/dweymouth/supersonic/ui/visualizations"
 	"github.com/dweymouth/supersonic/ui/widgets"
 
 	"fyne.io/fyne/v2"
@@ -57,8 +58,10 @@ type NowPlayingPage struct {
 	queueList          *widgets.PlayQueueList
 	relatedList        *widgets.PlayQueueList
 	lyricsViewer       *widgets.LyricsViewer
+	visualizer         *visualizations.VisualizerView
 	card               *widgets.LargeNowPlayingCard
 	statusLabel        *widget.Label
+	signalPath         *widgets.SignalPathPanel
 	tabs               *container.AppTabs
 	lyricsLoading      *widgets.LoadingDots
 	relatedLoading     *widgets.LoadingDots
@@ -172,6 +175,7 @@ func NewNowPlayingPage(
 	a.relatedList.OnPlaySelectionNext = func(items []mediaprovider.MediaItem) {
 		a.pm.LoadItems(items, backend.InsertNext, false)
 	}
+	a.relatedList.OnCustomRadio = a.contr.ShowCustomRadioDialog
 	a.relatedList.OnPlaySongRadio = func(track *mediaprovider.Track) {
 		go func() {
 			if err := a.pm.PlaySimilarSongs(track.ID); err != nil {
@@ -185,7 +189,13 @@ func NewNowPlayingPage(
 		a.contr.ShowTrackInfoDialog(track)
 	}
 	a.lyricsViewer = widgets.NewLyricsViewer(a.onSeekToLyricLine)
+	a.visualizer = visualizations.NewVisualizerView(conf.Visualization)
+	a.visualizer.OnVisualizationChanged = func(name string) {
+		a.conf.Visualization = name
+	}
 	a.statusLabel = widget.NewLabel(lang.L("Stopped"))
+	a.signalPath = widgets.NewSignalPathPanel()
+	a.signalPath.Hide()
 
 	a.Reload()
 	return a
@@ -205,6 +215,8 @@ func (a *NowPlayingPage) CreateRenderer() fyne.WidgetRenderer {
 			initialTab = 1
 		} else if a.conf.InitialView == "Related" {
 			initialTab = 2
+		} else if a.conf.InitialView == "Visualizer" {
+			initialTab = 3
 		}
 		_ = initialTab
 		paddedLayout := &layouts.PercentPadLayout{
@@ -222,6 +234,8 @@ func (a *NowPlayingPage) CreateRenderer() fyne.WidgetRenderer {
 			container.NewTabItem(lang.L("Related"), container.NewStack(
 				a.relatedList,
 				container.NewCenter(a.relatedLoading))),
+			container.NewTabItem(lang.L("Visualizer"),
+				container.NewPadded(a.visualizer)),
 		)
 		a.tabs.SelectIndex(initialTab)
 		a.tabs.OnSelected = func(*container.TabItem) {
@@ -232,12 +246,14 @@ func (a *NowPlayingPage) CreateRenderer() fyne.WidgetRenderer {
 			} else if idx == 2 /*related*/ {
 				a.updateRelatedList()
 			}
+			a.updateVisualizer()
 		}
 		if initialTab == 1 /*lyrics*/ {
 			a.updateLyrics()
 		} else if initialTab == 2 /*related*/ {
 			a.updateRelatedList()
 		}
+		a.updateVisualizer()
 		c := theme.Color(myTheme.ColorNamePageBackground)
 		a.backgroundGradient = canvas.NewLinearGradient(c, c, 0)
 		a.backgroundImgA = canvas.NewImageFromImage(nil)
@@ -257,6 +273,7 @@ func (a *NowPlayingPage) CreateRenderer() fyne.WidgetRenderer {
 				layout.NewSpacer(),
 				container.NewBorder(nil, nil, util.NewHSpace(1), util.NewHSpace(1),
 					myTheme.NewThemedRectangle(theme.ColorNameInputBorder)),
+				container.New(&layout.CustomPaddedLayout{LeftPadding: theme.Padding()}, a.signalPath),
 				a.statusLabel,
 			),
 		)
@@ -269,6 +286,7 @@ func (a *NowPlayingPage) Save() SavedPage {
 		a.imageLoadCancel()
 	}
 	a.alreadyLoaded = false
+	a.contr.SetNowPlayingVisualization(nil)
 	nps := a.nowPlayingPageState
 	a.pool.Release(util.WidgetTypeNowPlayingPage, a)
 	return &nps
@@ -469,6 +487,15 @@ func (a *NowPlayingPage) updateRelatedList() {
 	}(ctx)
 }
 
+// updateVisualizer drives the visualizer while its tab is selected.
+func (a *NowPlayingPage) updateVisualizer() {
+	if a.tabs != nil && a.tabs.SelectedIndex() == 3 /*visualizer*/ {
+		a.contr.SetNowPlayingVisualization(a.visualizer)
+	} else {
+		a.contr.SetNowPlayingVisualization(nil)
+	}
+}
+
 func (a *NowPlayingPage) OnPlayQueueChange() {
 	a.Reload()
 }
@@ -482,6 +509,7 @@ func (a *NowPlayingPage) Reload() {
 
 	a.queue = a.pm.GetActivePlayQueue()
 	a.queueList.SetItems(a.queue)
+	a.queueList.SetUpNextItems(a.pm.GetUpNext())
 	a.totalTime = 0.0
 	for _, tr := range a.queue {
 		a.totalTime += tr.Metadata().Duration.Seconds()
@@ -497,6 +525,8 @@ func (a *NowPlayingPage) Reload() {
 		a.updateLyrics()
 	case 2: /*related*/
 		a.updateRelatedList()
+	case 3: /*visualizer*/
+		a.updateVisualizer()
 	}
 }
 
@@ -568,6 +598,8 @@ func (a *NowPlayingPage) saveSelectedTab(tabNum int) {
 		tabName = "Lyrics"
 	case 2:
 		tabName = "Related"
+	case 3:
+		tabName = "Visualizer"
 	}
 	a.conf.InitialView = tabName
 }
@@ -601,11 +633,22 @@ func (a *NowPlayingPage) formatStatusLine() {
 		len(a.queue), statusSuffix, lang.L("Total time"), util.SecondsToTimeString(a.totalTime))
 
 	mediaInfo := ""
-	if state != stopped {
-		mediaInfo = a.formatMediaInfoStr(curPlayer)
+	showSignalPath := false
+	if mpvP, ok := curPlayer.(*mpv.Player); ok && state != stopped {
+		if audioInfo, err := mpvP.GetMediaInfo(); err != nil {
+			log.Printf("error getting playback status: %s", err.Error())
+		} else {
+			mediaInfo = " | " + formatMediaInfoStr(audioInfo)
+			if a.cfg.LocalPlayback.BitPerfect {
+				a.signalPath.Update(audioInfo)
+				showSignalPath = true
+			}
+		}
 	}
-	if mediaInfo != "" {
-		mediaInfo = " | " + mediaInfo
+	if showSignalPath {
+		a.signalPath.Show()
+	} else {
+		a.signalPath.Hide()
 	}
 
 	a.statusLabel.Text = fmt.Sprintf("%s%s", status, mediaInfo)
@@ -614,16 +657,7 @@ func (a *NowPlayingPage) formatStatusLine() {
 	}
 }
 
-func (a *NowPlayingPage) formatMediaInfoStr(player player.BasePlayer) string {
-	mpv, ok := player.(*mpv.Player)
-	if !ok {
-		return ""
-	}
-	audioInfo, err := mpv.GetMediaInfo()
-	if err != nil {
-		log.Printf("error getting playback status: %s", err.Error())
-		return ""
-	}
+func formatMediaInfoStr(audioInfo mpv.MediaInfo) string {
 	codec := audioInfo.Codec
 	if len(codec) <= 4 && !strings.EqualFold(codec, "opus") {
 		codec = strings.ToUpper(codec) // FLAC, MP3, AAC, etc
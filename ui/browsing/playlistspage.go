@@ -118,6 +118,7 @@ func (a *PlaylistsPage) buildContainer(initialView fyne.CanvasObject) {
 }
 
 var _ Scrollable = (*PlaylistsPage)(nil)
+var _ ScrollPositioner = (*PlaylistsPage)(nil)
 
 func (p *PlaylistsPage) Scroll(scrollAmt float32) {
 	if p.viewToggle.ActivatedButtonIndex() == 1 && p.gridView != nil {
@@ -127,6 +128,23 @@ func (p *PlaylistsPage) Scroll(scrollAmt float32) {
 	}
 }
 
+func (p *PlaylistsPage) ScrollPosition() float32 {
+	if p.viewToggle.ActivatedButtonIndex() == 1 && p.gridView != nil {
+		return p.gridView.GetScrollOffset()
+	} else if p.viewToggle.ActivatedButtonIndex() == 0 && p.listView != nil {
+		return p.listView.list.GetScrollOffset()
+	}
+	return 0
+}
+
+func (p *PlaylistsPage) SetScrollPosition(pos float32) {
+	if p.viewToggle.ActivatedButtonIndex() == 1 {
+		p.initialGridScrollPos = pos
+	} else {
+		p.initialListScrollPos = pos
+	}
+}
+
 // should be called asynchronously
 func (a *PlaylistsPage) load(searchOnLoad bool) {
 	playlists, err := a.mp.GetPlaylists()
@@ -38,6 +38,9 @@ type RadiosPage struct {
 	noRadiosMsg fyne.CanvasObject
 	container   *fyne.Container
 	searcher    *widgets.SearchEntry
+
+	// applied once the page has loaded
+	initialScrollPos float32
 }
 
 func NewRadiosPage(contr *controller.Controller, rp mediaprovider.RadioProvider, pm *backend.PlaybackManager) *RadiosPage {
@@ -46,10 +49,11 @@ func NewRadiosPage(contr *controller.Controller, rp mediaprovider.RadioProvider,
 
 func newRadiosPage(contr *controller.Controller, rp mediaprovider.RadioProvider, pm *backend.PlaybackManager, searchText string, scrollPos float32) *RadiosPage {
 	a := &RadiosPage{
-		contr:     contr,
-		rp:        rp,
-		pm:        pm,
-		titleDisp: widget.NewRichTextWithText(lang.L("Internet Radio Stations")),
+		contr:            contr,
+		rp:               rp,
+		pm:               pm,
+		initialScrollPos: scrollPos,
+		titleDisp:        widget.NewRichTextWithText(lang.L("Internet Radio Stations")),
 	}
 	a.ExtendBaseWidget(a)
 	a.titleDisp.Segments[0].(*widget.TextSegment).Style.SizeName = theme.SizeNameHeadingText
@@ -68,12 +72,12 @@ func newRadiosPage(contr *controller.Controller, rp mediaprovider.RadioProvider,
 	a.noRadiosMsg.Hide()
 
 	a.buildContainer()
-	go a.load(searchText != "", scrollPos)
+	go a.load(searchText != "")
 	return a
 }
 
 // should be called asynchronously
-func (a *RadiosPage) load(searchOnLoad bool, scrollPos float32) {
+func (a *RadiosPage) load(searchOnLoad bool) {
 	radios, err := a.rp.GetRadioStations()
 	if err != nil {
 		log.Printf("error loading radios: %v", err.Error())
@@ -86,6 +90,8 @@ func (a *RadiosPage) load(searchOnLoad bool, scrollPos float32) {
 			a.noRadiosMsg.Hide()
 		}
 		a.radios = radios
+		scrollPos := a.initialScrollPos
+		a.initialScrollPos = 0
 		if searchOnLoad {
 			a.onSearched(a.searcher.Entry.Text)
 			if scrollPos != 0 {
@@ -136,11 +142,20 @@ func (a *RadiosPage) SearchWidget() fyne.Focusable {
 }
 
 var _ Scrollable = (*RadiosPage)(nil)
+var _ ScrollPositioner = (*RadiosPage)(nil)
 
 func (a *RadiosPage) Scroll(amount float32) {
 	a.list.list.ScrollToOffset(a.list.list.GetScrollOffset() + amount)
 }
 
+func (a *RadiosPage) ScrollPosition() float32 {
+	return a.list.list.GetScrollOffset()
+}
+
+func (a *RadiosPage) SetScrollPosition(pos float32) {
+	a.initialScrollPos = pos
+}
+
 var _ CanShowNowPlaying = (*RadiosPage)(nil)
 
 func (a *RadiosPage) OnSongChange(playing mediaprovider.MediaItem, _ *mediaprovider.Track) {
@@ -157,7 +172,7 @@ func (a *RadiosPage) Route() controller.Route {
 }
 
 func (a *RadiosPage) Reload() {
-	go a.load(false, 0)
+	go a.load(false)
 }
 
 func (a *RadiosPage) Save() SavedPage {
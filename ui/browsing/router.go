@@ -38,7 +38,11 @@ func (r Router) CreatePage(rte controller.Route) Page {
 	case controller.Album:
 		return NewAlbumPage(rte.Arg, &r.App.Config.AlbumPage, r.widgetPool, r.App.PlaybackManager, r.App.ServerManager.Server, r.App.ImageManager, r.Controller)
 	case controller.Albums:
-		return NewAlbumsPage(&r.App.Config.AlbumsPage, r.widgetPool, r.Controller, r.App.PlaybackManager, r.App.ServerManager.Server, r.App.ImageManager)
+		filter := &mediaprovider.AlbumFilterOptions{}
+		if conf := r.App.ServerManager.CurrentServerConfig(); conf != nil {
+			filter = &conf.UIState.AlbumsFilter
+		}
+		return NewAlbumsPage(&r.App.Config.AlbumsPage, filter, r.widgetPool, r.Controller, r.App.PlaybackManager, r.App.ServerManager.Server, r.App.ImageManager)
 	case controller.Artist:
 		return NewArtistPage(rte.Arg, &r.App.Config.ArtistPage, r.widgetPool, r.App.PlaybackManager, r.App.ServerManager.Server, r.App.ImageManager, r.Controller)
 	case controller.Artists:
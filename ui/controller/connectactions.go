@@ -62,6 +62,7 @@ func (m *Controller) connectTracklistActionsWithReplayGainMode(tracklist *widget
 		m.ShowShareDialog(trackID)
 	}
 	tracklist.OnShowTrackInfo = m.ShowTrackInfoDialog
+	tracklist.OnCustomRadio = m.ShowCustomRadioDialog
 	tracklist.OnPlaySongRadio = func(track *mediaprovider.Track) {
 		go func() {
 			tracks, err := m.GetSongRadioTracks(track)
@@ -219,8 +220,13 @@ func (c *Controller) ConnectPlayQueuelistActions(list *widgets.PlayQueueList) {
 		list.UnselectAll()
 		c.App.PlaybackManager.RemoveTracksFromQueue(idxs)
 	}
+	list.OnRemoveFromUpNext = func(itemID string) {
+		c.App.PlaybackManager.RemoveFromUpNext([]string{itemID})
+	}
+	list.OnClearUpNext = c.App.PlaybackManager.ClearUpNext
 	list.OnSetRating = c.SetTrackRatings
 	list.OnSetFavorite = c.SetTrackFavorites
+	list.OnCustomRadio = c.ShowCustomRadioDialog
 	list.OnPlaySongRadio = func(track *mediaprovider.Track) {
 		go func() {
 			if err := c.App.PlaybackManager.PlaySimilarSongs(track.ID); err != nil {
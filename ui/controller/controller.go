@@ -8,9 +8,12 @@ import (
 	"image/color"
 	"io"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
 	"time"
 
 	fynetooltip "github.com/dweymouth/fyne-tooltip"
@@ -18,6 +21,7 @@ import (
 	"github.com/dweymouth/supersonic/backend/mediaprovider"
 	"github.com/dweymouth/supersonic/backend/player"
 	"github.com/dweymouth/supersonic/backend/player/mpv"
+	"github.com/dweymouth/supersonic/res"
 	"github.com/dweymouth/supersonic/sharedutil"
 	"github.com/dweymouth/supersonic/ui/dialogs"
 	myTheme "github.com/dweymouth/supersonic/ui/theme"
@@ -30,6 +34,7 @@ import (
 	"fyne.io/fyne/v2/driver"
 	"fyne.io/fyne/v2/lang"
 	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 )
@@ -65,6 +70,11 @@ type Controller struct {
 	escapablePopUp     fyne.CanvasObject
 	haveModal          bool
 	runOnModalClosed   func()
+
+	// the open room volume dialog, if any, and the multi-room
+	// player whose rooms change callback updates it
+	roomVolumeDlg         *dialogs.RoomVolumeDialog
+	roomsChangeRegistered player.MultiRoomPlayer
 }
 
 func New(app *backend.App, appVersion string, mainWindow fyne.Window) *Controller {
@@ -77,6 +87,7 @@ func New(app *backend.App, appVersion string, mainWindow fyne.Window) *Controlle
 	c.App.PlaybackManager.OnQueueChange(util.FyneDoFunc(func() {
 		if c.popUpQueue != nil {
 			c.popUpQueueList.SetItems(c.App.PlaybackManager.GetActivePlayQueue())
+			c.popUpQueueList.SetUpNextItems(c.App.PlaybackManager.GetUpNext())
 		}
 	}))
 	c.App.PlaybackManager.OnSongChange(func(track mediaprovider.MediaItem, _ *mediaprovider.Track) {
@@ -181,6 +192,11 @@ func (m *Controller) ShowCastMenu(onPendingPlayerChange func()) {
 		item.Checked = isCurrent
 		menu.Items = append(menu.Items, item)
 	}
+	if mr, ok := m.App.PlaybackManager.CurrentPlayer().(player.MultiRoomPlayer); ok && rp != nil {
+		roomVolumes := fyne.NewMenuItem(lang.L("Room volumes"), func() { m.ShowRoomVolumeDialog(mr) })
+		roomVolumes.Icon = theme.VolumeUpIcon()
+		menu.Items = append(menu.Items, fyne.NewMenuItemSeparator(), roomVolumes)
+	}
 	pop := widget.NewPopUpMenu(menu, m.MainWindow.Canvas())
 	canvasSize := m.MainWindow.Canvas().Size()
 	pop.ShowAtPosition(fyne.NewPos(
@@ -189,11 +205,52 @@ func (m *Controller) ShowCastMenu(onPendingPlayerChange func()) {
 	))
 }
 
+// ShowRoomVolumeDialog shows the per-room volume controls of a multi-room remote player.
+func (m *Controller) ShowRoomVolumeDialog(mr player.MultiRoomPlayer) {
+	dlg := dialogs.NewRoomVolumeDialog(mr.Rooms())
+	pop := widget.NewModalPopUp(dlg, m.MainWindow.Canvas())
+	m.roomVolumeDlg = dlg
+	dlg.OnDismiss = func() {
+		m.roomVolumeDlg = nil
+		pop.Hide()
+		m.doModalClosed()
+	}
+	dlg.OnSetRoomVolume = func(room player.Room, vol int) {
+		go func() {
+			err := mr.SetRoomVolume(room.ID, vol)
+			if err == nil && room.Muted && vol > 0 {
+				err = mr.SetRoomMuted(room.ID, false)
+			}
+			if err != nil {
+				log.Printf("error setting room volume: %v", err)
+				fyne.Do(func() { m.ToastProvider.ShowErrorToast(lang.L("Failed to set room volume")) })
+			}
+		}()
+	}
+	// players have no way to remove a callback, so register
+	// only once per player and update whichever dialog is open
+	if m.roomsChangeRegistered != mr {
+		m.roomsChangeRegistered = mr
+		mr.OnRoomsChange(func() {
+			rooms := mr.Rooms()
+			fyne.Do(func() {
+				if m.roomVolumeDlg != nil && m.roomsChangeRegistered == mr {
+					m.roomVolumeDlg.SetRooms(rooms)
+				}
+			})
+		})
+	}
+	m.ClosePopUpOnEscape(pop)
+	m.haveModal = true
+	pop.Show()
+}
+
 func (m *Controller) ShowPopUpPlayQueue() {
 	if m.popUpQueue == nil {
 		m.popUpQueueList = widgets.NewPlayQueueList(m.App.ImageManager, false)
 		m.popUpQueueList.Reorderable = true
 		m.popUpQueueList.SetItems(m.App.PlaybackManager.GetActivePlayQueue())
+		m.popUpQueueList.SetUpNextItems(m.App.PlaybackManager.GetUpNext())
 		m.ConnectPlayQueuelistActions(m.popUpQueueList)
 
 		title := widget.NewRichTextWithText(lang.L("Play Queue"))
@@ -301,6 +358,7 @@ func (m *Controller) GetArtistTracks(artistID string) []*mediaprovider.Track {
 func (c *Controller) ShowAboutDialog() {
 	dlg := dialogs.NewAboutDialog(c.AppVersion)
 	pop := widget.NewModalPopUp(dlg, c.MainWindow.Canvas())
+	dlg.OnShowLogs = c.showLogFile
 	dlg.OnDismiss = func() {
 		pop.Hide()
 		c.doModalClosed()
@@ -310,6 +368,77 @@ func (c *Controller) ShowAboutDialog() {
 	pop.Show()
 }
 
+// showLogFile opens the log file in the system's default app.
+func (c *Controller) showLogFile() {
+	path := c.App.LogFilePath()
+	if path == "" {
+		c.ToastProvider.ShowErrorToast(lang.L("The log file couldn't be opened"))
+		return
+	}
+	u, err := url.Parse(storage.NewFileURI(path).String())
+	if err == nil {
+		err = fyne.CurrentApp().OpenURL(u)
+	}
+	if err != nil {
+		log.Printf("error opening log file: %v", err)
+		c.ToastProvider.ShowErrorToast(lang.L("The log file couldn't be opened"))
+	}
+}
+
+// ShowNetworkInspector shows the requests recorded in the network log,
+// refreshing the list while it is open.
+func (c *Controller) ShowNetworkInspector() {
+	netLog := c.App.ServerManager.NetworkLog
+	dlg := dialogs.NewNetworkInspectorDialog(netLog.Enabled())
+	dlg.SetEntries(netLog.Entries())
+	pop := widget.NewModalPopUp(dlg, c.MainWindow.Canvas())
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(time.Second)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				entries := netLog.Entries()
+				fyne.Do(func() { dlg.SetEntries(entries) })
+			}
+		}
+	}()
+	dlg.OnRecordChanged = func(record bool) {
+		c.App.Config.Application.RecordNetworkRequests = record
+		netLog.SetEnabled(record)
+	}
+	dlg.OnClear = func() {
+		netLog.Clear()
+		dlg.SetEntries(nil)
+	}
+	dlg.OnExportHAR = func() {
+		dg := dialog.NewFileSave(func(file fyne.URIWriteCloser, err error) {
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			if file == nil {
+				return
+			}
+			defer file.Close()
+			if err := netLog.WriteHAR(file, res.AppName, c.AppVersion); err != nil {
+				log.Printf("error exporting network log: %v", err)
+				c.ToastProvider.ShowErrorToast(lang.L("Failed to export network log"))
+			}
+		}, c.MainWindow)
+		dg.SetFileName(res.AppName + "-network.har")
+		dg.Show()
+	}
+	dlg.OnDismiss = func() {
+		close(done)
+		pop.Hide()
+	}
+	pop.Show()
+}
+
 func (c *Controller) ShowSettingsDialog(themeUpdateCallbk func(), themeFiles map[string]string) {
 	devs, err := c.App.LocalPlayer.ListAudioDevices()
 	if err != nil {
@@ -339,43 +468,36 @@ func (c *Controller) ShowSettingsDialog(themeUpdateCallbk func(), themeFiles map
 	dlg.OnAudioExclusiveSettingChanged = func() {
 		c.App.LocalPlayer.SetAudioExclusive(c.App.Config.LocalPlayback.AudioExclusive)
 	}
+	dlg.OnBitPerfectSettingChanged = func() {
+		if err := c.App.SetBitPerfect(c.App.Config.LocalPlayback.BitPerfect); err != nil {
+			log.Printf("error setting bit-perfect mode: %v", err)
+		}
+	}
 	dlg.OnPauseFadeSettingsChanged = func() {
 		c.App.LocalPlayer.SetPauseFade(c.App.Config.LocalPlayback.PauseFade)
 	}
+	dlg.OnShuffleModeSettingChanged = func() {
+		c.App.PlaybackManager.SetShuffleMode(c.App.Config.Playback.ShuffleMode, c.App.Config.Playback.ShuffleArtistSpread)
+	}
+	dlg.OnDLNARendererSettingChanged = func() {
+		if err := c.App.SetDLNARendererEnabled(c.App.Config.LocalPlayback.DLNARendererEnabled); err != nil {
+			log.Printf("error starting DLNA renderer: %s", err.Error())
+			c.ToastProvider.ShowErrorToast(lang.L("Failed to start DLNA renderer"))
+		}
+	}
 	dlg.OnAudioDeviceSettingChanged = func() {
-		c.App.LocalPlayer.SetAudioDevice(c.App.Config.LocalPlayback.AudioDeviceName)
+		if err := c.App.AudioDeviceManager.SetDevice(c.App.Config.LocalPlayback.AudioDeviceName); err != nil {
+			log.Printf("error setting audio device: %v", err)
+		}
 	}
 	dlg.OnThemeSettingChanged = themeUpdateCallbk
 	dlg.OnEqualizerSettingsChanged = func() {
-		// Create the appropriate equalizer type based on config
-		var eq mpv.Equalizer
-		if c.App.Config.LocalPlayback.EqualizerType == "ISO10Band" {
-			eq10 := &mpv.ISO10BandEqualizer{
-				Disabled: !c.App.Config.LocalPlayback.EqualizerEnabled,
-				EQPreamp: c.App.Config.LocalPlayback.EqualizerPreamp,
-			}
-			// Copy up to 10 bands
-			numBands := min(len(c.App.Config.LocalPlayback.GraphicEqualizerBands), 10)
-			for i := 0; i < numBands; i++ {
-				eq10.BandGains[i] = c.App.Config.LocalPlayback.GraphicEqualizerBands[i]
-			}
-			eq = eq10
-		} else {
-			eq15 := &mpv.ISO15BandEqualizer{
-				Disabled: !c.App.Config.LocalPlayback.EqualizerEnabled,
-				EQPreamp: c.App.Config.LocalPlayback.EqualizerPreamp,
-			}
-			// Copy up to 15 bands
-			numBands := min(len(c.App.Config.LocalPlayback.GraphicEqualizerBands), 15)
-			for i := 0; i < numBands; i++ {
-				eq15.BandGains[i] = c.App.Config.LocalPlayback.GraphicEqualizerBands[i]
-			}
-			eq = eq15
-		}
-		c.App.LocalPlayer.SetEqualizer(eq)
+		c.App.LocalPlayer.SetEqualizer(backend.NewEqualizerFromConfig(&c.App.Config.LocalPlayback))
 	}
 	dlg.OnPageNeedsRefresh = c.RefreshPageFunc
 	dlg.OnClearCaches = func() { go c.App.ClearCaches() }
+	dlg.OnManageCredentials = c.ShowCredentialsDialog
+	dlg.OnShowNetworkInspector = c.ShowNetworkInspector
 	pop := widget.NewModalPopUp(dlg, c.MainWindow.Canvas())
 	fynetooltip.AddPopUpToolTipLayer(pop)
 	dlg.OnDismiss = func() {
@@ -643,6 +765,57 @@ func (c *Controller) ShowTrackInfoDialog(track *mediaprovider.Track) {
 	pop.Show()
 }
 
+// ShowCustomRadioDialog shows the dialog to configure and start a custom radio
+// seeded from the given tracks (if any) and user-selected genres.
+func (c *Controller) ShowCustomRadioDialog(seedTracks []*mediaprovider.Track) {
+	seedNames := sharedutil.MapSlice(seedTracks, func(t *mediaprovider.Track) string { return t.Title })
+	c.showCustomRadioDialog(backend.RadioParams{TrackIDs: sharedutil.TracksToIDs(seedTracks)}, seedNames)
+}
+
+// ShowArtistCustomRadioDialog shows the dialog to configure and start a custom radio
+// seeded from the given artist and user-selected genres.
+func (c *Controller) ShowArtistCustomRadioDialog(artistID, artistName string) {
+	c.showCustomRadioDialog(backend.RadioParams{ArtistIDs: []string{artistID}}, []string{artistName})
+}
+
+func (c *Controller) showCustomRadioDialog(params backend.RadioParams, seedNames []string) {
+	mp := c.App.ServerManager.Server
+	if mp == nil {
+		return
+	}
+	params.RadioConfig = c.App.Config.Playback.AutoplayRadio
+	params.Decades = slices.Clone(params.Decades)
+	dlg := dialogs.NewCustomRadioDialog(params, seedNames)
+	pop := widget.NewModalPopUp(dlg, c.MainWindow.Canvas())
+	dlg.OnDismiss = func() {
+		pop.Hide()
+		c.doModalClosed()
+	}
+	dlg.OnStartRadio = func(params backend.RadioParams) {
+		dlg.OnDismiss()
+		go func() {
+			if err := c.App.PlaybackManager.StartRadio(params); err != nil {
+				log.Printf("error starting custom radio: %v", err)
+				fyne.Do(func() { c.ToastProvider.ShowErrorToast(lang.L("Unable to start radio")) })
+			}
+		}()
+	}
+	c.ClosePopUpOnEscape(pop)
+	c.haveModal = true
+	pop.Show()
+
+	go func() {
+		genres, err := mp.GetGenres()
+		if err != nil {
+			log.Printf("error getting genres: %v", err)
+			return
+		}
+		names := sharedutil.MapSlice(genres, func(g *mediaprovider.Genre) string { return g.Name })
+		slices.SortFunc(names, strings.Compare)
+		fyne.Do(func() { dlg.SetGenres(names) })
+	}()
+}
+
 func (c *Controller) GetSongRadioTracks(sourceTrack *mediaprovider.Track) ([]*mediaprovider.Track, error) {
 	radioTracks, err := c.App.ServerManager.Server.GetSongRadio(sourceTrack.ID, 100)
 	if err != nil {
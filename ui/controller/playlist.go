@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"context"
 	"log"
 	"strconv"
 
@@ -9,6 +10,7 @@ import (
 	"fyne.io/fyne/v2/lang"
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/widget"
+	"github.com/dweymouth/supersonic/backend"
 	"github.com/dweymouth/supersonic/backend/mediaprovider"
 	"github.com/dweymouth/supersonic/sharedutil"
 	"github.com/dweymouth/supersonic/ui/dialogs"
@@ -183,3 +185,72 @@ func (m *Controller) DoCreatePlaylistWorkflow() {
 	m.haveModal = true
 	pop.Show()
 }
+
+// Show the duplicate tracks dialog and scan the library for duplicates.
+// If the user chooses, replace the inferior copies of each duplicate
+// with the preferred copy in all playlists they own.
+func (m *Controller) DoFindDuplicateTracksWorkflow() {
+	mp := m.App.ServerManager.Server
+	if mp == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	dlg := dialogs.NewDuplicateTracksDialog()
+	pop := widget.NewModalPopUp(dlg, m.MainWindow.Canvas())
+	dlg.OnDismiss = func() {
+		cancel()
+		pop.Hide()
+		m.doModalClosed()
+	}
+	dlg.OnReplaceInPlaylists = func(groups []*backend.DuplicateTrackGroup) {
+		dlg.OnDismiss()
+		go func() {
+			playlists, err := mp.GetPlaylists()
+			if err == nil {
+				playlists = sharedutil.FilterSlice(playlists, func(p *mediaprovider.Playlist) bool {
+					return util.IsOwnPlaylist(mp, p, m.App.ServerManager.LoggedInUser)
+				})
+				var n int
+				n, err = backend.ReplaceDuplicatesInPlaylists(mp, playlists, groups)
+				if err == nil {
+					fyne.Do(func() {
+						msg := lang.LocalizePluralKey("playlist.replacedduplicates",
+							"Updated playlists", n, map[string]string{"playlistCount": strconv.Itoa(n)})
+						m.ToastProvider.ShowSuccessToast(msg)
+						if rte := m.CurPageFunc(); rte.Page == Playlist {
+							m.ReloadFunc()
+						}
+					})
+					return
+				}
+			}
+			log.Printf("error replacing duplicate tracks in playlists: %s", err.Error())
+			fyne.Do(func() { m.ToastProvider.ShowErrorToast(lang.L("Error updating playlist")) })
+		}()
+	}
+	// cancel the scan also when the dialog is closed with Escape
+	m.ClosePopUpOnEscape(cancelOnHidePopUp{PopUp: pop, cancel: cancel})
+	m.haveModal = true
+	pop.Show()
+
+	go func() {
+		groups := backend.FindDuplicateTracks(ctx, mp.IterateTracks(""), func(n int) {
+			fyne.Do(func() { dlg.SetScanProgress(n) })
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		fyne.Do(func() { dlg.SetGroups(groups) })
+	}()
+}
+
+// cancelOnHidePopUp is a PopUp that calls cancel when it is hidden.
+type cancelOnHidePopUp struct {
+	*widget.PopUp
+	cancel context.CancelFunc
+}
+
+func (p cancelOnHidePopUp) Hide() {
+	p.cancel()
+	p.PopUp.Hide()
+}
@@ -49,6 +49,16 @@ func (p PageName) String() string {
 	}
 }
 
+// ParsePageName returns the PageName whose String() is s, or None.
+func ParsePageName(s string) PageName {
+	for p := Album; p <= Radios; p++ {
+		if p.String() == s {
+			return p
+		}
+	}
+	return None
+}
+
 type Route struct {
 	Page PageName
 	Arg  string
@@ -0,0 +1,40 @@
+package controller
+
+import "testing"
+
+func TestParsePageName(t *testing.T) {
+	for p := Album; p <= Radios; p++ {
+		if got := ParsePageName(p.String()); got != p {
+			t.Errorf("ParsePageName(%q) = %v, want %v", p.String(), got, p)
+		}
+	}
+	for _, s := range []string{"", "album", "Unknown Page"} {
+		if got := ParsePageName(s); got != None {
+			t.Errorf("ParsePageName(%q) = %v, want None", s, got)
+		}
+	}
+}
+
+func TestRouteRoundTrip(t *testing.T) {
+	routes := []Route{
+		AlbumsRoute(),
+		ArtistRoute("ar-1"),
+		AlbumRoute("al-1"),
+		AllTracksRoute(),
+		FavoritesRoute(),
+		GenreRoute("Jazz & Blues"),
+		GenresRoute(),
+		PlaylistRoute("pl-1"),
+		PlaylistsRoute(),
+		ArtistsRoute(),
+		RadiosRoute(),
+		NowPlayingRoute(),
+	}
+	for _, r := range routes {
+		// routes are saved by page name and argument
+		restored := Route{Page: ParsePageName(r.Page.String()), Arg: r.Arg}
+		if restored != r {
+			t.Errorf("route %v restored as %v", r, restored)
+		}
+	}
+}
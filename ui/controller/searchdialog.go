@@ -12,7 +12,7 @@ import (
 )
 
 func (c *Controller) ShowQuickSearch() {
-	qs := dialogs.NewQuickSearch(c.App.ServerManager.Server, c.App.ImageManager)
+	qs := dialogs.NewQuickSearch(c.App.ServerManager.Server, c.App.SearchIndex, c.App.ImageManager)
 	pop := widget.NewModalPopUp(qs.SearchDialog, c.MainWindow.Canvas())
 	qs.SetOnDismiss(func() {
 		pop.Hide()
@@ -74,6 +74,9 @@ func (c *Controller) ShowQuickSearch() {
 			}
 		}()
 	}
+	qs.OnCustomRadio = func(track *mediaprovider.Track) {
+		c.ShowCustomRadioDialog([]*mediaprovider.Track{track})
+	}
 	qs.OnSetFavorite = func(trackID string, fav bool) {
 		go c.SetTrackFavorites([]string{trackID}, fav)
 	}
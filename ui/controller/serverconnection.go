@@ -2,8 +2,11 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"strings"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -12,15 +15,20 @@ import (
 	"fyne.io/fyne/v2/widget"
 	"github.com/dweymouth/supersonic/backend"
 	"github.com/dweymouth/supersonic/ui/dialogs"
+	"github.com/google/uuid"
 )
 
 func (m *Controller) PromptForFirstServer() {
-	d := dialogs.NewAddEditServerDialog(lang.L("Connect to Server"), false, nil, m.MainWindow.Canvas().Focus)
+	if f := m.App.CredentialFile; f != nil && f.Locked() {
+		m.promptForCredentialPassphrase(f, m.PromptForFirstServer)
+		return
+	}
+	d := dialogs.NewAddEditServerDialog(lang.L("Connect to Server"), false, nil, nil, m.MainWindow.Canvas().Focus)
 	pop := widget.NewModalPopUp(d, m.MainWindow.Canvas())
 	d.OnSubmit = func() {
 		d.DisableSubmit()
 		go func() {
-			if m.testConnectionAndUpdateDialogText(d) {
+			if m.testConnectionAndUpdateDialogText(d, uuid.Nil) {
 				// connection is good
 				fyne.Do(func() {
 					pop.Hide()
@@ -33,8 +41,19 @@ func (m *Controller) PromptForFirstServer() {
 					Username:      d.Username,
 					LegacyAuth:    d.LegacyAuth,
 					SkipSSLVerify: d.SkipSSLVerify,
+					APIKeyAuth:    d.APIKeyAuth,
+
+					UnifiedServerIDs: d.MemberServerIDs,
+
+					CACertFile:       d.CACertFile,
+					ClientCertFile:   d.ClientCertFile,
+					ClientKeyFile:    d.ClientKeyFile,
+					PinnedCertSHA256: d.PinnedCertSHA256,
+					CustomHeaders:    d.CustomHeaders,
+					CookieFile:       d.CookieFile,
 				}
 				server := m.App.ServerManager.AddServer(d.Nickname, conn)
+				m.saveClientCertPassword(server, d.ClientCertPassword)
 				if err := m.trySetPasswordAndConnectToServer(server, d.Password); err != nil {
 					log.Printf("error connecting to server: %s", err.Error())
 				}
@@ -49,12 +68,18 @@ func (m *Controller) PromptForFirstServer() {
 
 // DoConnectToServerWorkflow does the workflow for connecting to the last active server on startup
 func (c *Controller) DoConnectToServerWorkflow(server *backend.ServerConfig) {
+	if f := c.App.CredentialFile; f != nil && f.Locked() {
+		c.promptForCredentialPassphrase(f, func() { c.DoConnectToServerWorkflow(server) })
+		return
+	}
+
 	// GetServerPassword may block on keyring unlock (showing a system dialog),
 	// so run it in a goroutine to avoid freezing the Fyne event loop.
 	go func() {
 		pass, err := c.App.ServerManager.GetServerPassword(server.ID)
-		if err != nil {
-			log.Printf("error getting password from keyring: %v", err)
+		// a server with a saved access token can connect without the password
+		if err != nil && !c.App.ServerManager.HasServerToken(server.ID) {
+			log.Printf("error getting saved password: %v", err)
 			fyne.Do(c.PromptForLoginAndConnect)
 			return
 		}
@@ -117,9 +142,13 @@ func (m *Controller) PromptForLoginAndConnect() {
 			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 			defer cancel()
 
-			err := m.App.ServerManager.TestConnectionAndAuth(ctx, server.ServerConnection, password)
+			err := m.App.ServerManager.TestConnectionAndAuth(ctx, server.ID, server.ServerConnection, password)
 			fyne.Do(func() {
-				if err == backend.ErrUnreachable {
+				if errors.Is(err, backend.ErrInvalidTLSConfig) {
+					d.SetErrorText(lang.L("Invalid TLS settings") + fmt.Sprintf(" (%s)", errorDetail(err, backend.ErrInvalidTLSConfig)))
+				} else if errors.Is(err, backend.ErrInvalidCookieFile) {
+					d.SetErrorText(lang.L("Invalid cookie file") + fmt.Sprintf(" (%s)", errorDetail(err, backend.ErrInvalidCookieFile)))
+				} else if err == backend.ErrUnreachable {
 					d.SetErrorText(lang.L("Server unreachable"))
 				} else if err != nil {
 					d.SetErrorText(lang.L("Authentication failed"))
@@ -134,12 +163,12 @@ func (m *Controller) PromptForLoginAndConnect() {
 	}
 	d.OnEditServer = func(server *backend.ServerConfig) {
 		pop.Hide()
-		editD := dialogs.NewAddEditServerDialog(lang.L("Edit server"), true, server, m.MainWindow.Canvas().Focus)
+		editD := dialogs.NewAddEditServerDialog(lang.L("Edit server"), true, server, m.App.Config.Servers, m.MainWindow.Canvas().Focus)
 		editPop := widget.NewModalPopUp(editD, m.MainWindow.Canvas())
 		editD.OnSubmit = func() {
 			d.DisableSubmit()
 			go func() {
-				success := m.testConnectionAndUpdateDialogText(editD)
+				success := m.testConnectionAndUpdateDialogText(editD, server.ID)
 				fyne.Do(func() {
 					if success {
 						// connection is good
@@ -150,6 +179,15 @@ func (m *Controller) PromptForLoginAndConnect() {
 						server.Username = editD.Username
 						server.LegacyAuth = editD.LegacyAuth
 						server.SkipSSLVerify = editD.SkipSSLVerify
+						server.APIKeyAuth = editD.APIKeyAuth
+						server.UnifiedServerIDs = editD.MemberServerIDs
+						server.CACertFile = editD.CACertFile
+						server.ClientCertFile = editD.ClientCertFile
+						server.ClientKeyFile = editD.ClientKeyFile
+						server.PinnedCertSHA256 = editD.PinnedCertSHA256
+						server.CustomHeaders = editD.CustomHeaders
+						server.CookieFile = editD.CookieFile
+						m.saveClientCertPassword(server, editD.ClientCertPassword)
 						m.trySetPasswordAndConnectToServer(server, editD.Password)
 						m.doModalClosed()
 					}
@@ -165,12 +203,12 @@ func (m *Controller) PromptForLoginAndConnect() {
 	}
 	d.OnNewServer = func() {
 		pop.Hide()
-		newD := dialogs.NewAddEditServerDialog(lang.L("Add Server"), true, nil, m.MainWindow.Canvas().Focus)
+		newD := dialogs.NewAddEditServerDialog(lang.L("Add Server"), true, nil, m.App.Config.Servers, m.MainWindow.Canvas().Focus)
 		newPop := widget.NewModalPopUp(newD, m.MainWindow.Canvas())
 		newD.OnSubmit = func() {
 			d.DisableSubmit()
 			go func() {
-				success := m.testConnectionAndUpdateDialogText(newD)
+				success := m.testConnectionAndUpdateDialogText(newD, uuid.Nil)
 				fyne.Do(func() {
 					if success {
 						// connection is good
@@ -182,8 +220,19 @@ func (m *Controller) PromptForLoginAndConnect() {
 							Username:      newD.Username,
 							LegacyAuth:    newD.LegacyAuth,
 							SkipSSLVerify: newD.SkipSSLVerify,
+							APIKeyAuth:    newD.APIKeyAuth,
+
+							UnifiedServerIDs: newD.MemberServerIDs,
+
+							CACertFile:       newD.CACertFile,
+							ClientCertFile:   newD.ClientCertFile,
+							ClientKeyFile:    newD.ClientKeyFile,
+							PinnedCertSHA256: newD.PinnedCertSHA256,
+							CustomHeaders:    newD.CustomHeaders,
+							CookieFile:       newD.CookieFile,
 						}
 						server := m.App.ServerManager.AddServer(newD.Nickname, conn)
+						m.saveClientCertPassword(server, newD.ClientCertPassword)
 						m.trySetPasswordAndConnectToServer(server, newD.Password)
 						m.doModalClosed()
 					}
@@ -232,7 +281,7 @@ func (c *Controller) tryConnectToServer(ctx context.Context, server *backend.Ser
 	timeout := time.Duration(c.App.Config.Application.RequestTimeoutSeconds) * time.Second
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
-	if err := c.App.ServerManager.TestConnectionAndAuth(ctx, server.ServerConnection, password); err != nil {
+	if err := c.App.ServerManager.TestConnectionAndAuth(ctx, server.ID, server.ServerConnection, password); err != nil {
 		return err
 	}
 	if err := c.App.ServerManager.ConnectToServer(server, password); err != nil {
@@ -242,8 +291,26 @@ func (c *Controller) tryConnectToServer(ctx context.Context, server *backend.Ser
 	return nil
 }
 
+// saveClientCertPassword saves the password of the server's PKCS#12 client
+// certificate, keeping the saved one if none was entered.
+func (c *Controller) saveClientCertPassword(server *backend.ServerConfig, password string) {
+	if password == "" && server.ClientCertFile != "" {
+		return
+	}
+	// also keep it for this session, in case credentials can't be saved
+	server.ClientCertPassword = password
+	if err := c.App.ServerManager.SetClientCertPassword(server, password); err != nil {
+		log.Printf("error saving client certificate password: %v", err)
+	}
+}
+
+// errorDetail returns the cause of an error wrapping the sentinel error.
+func errorDetail(err, sentinel error) string {
+	return strings.TrimPrefix(err.Error(), sentinel.Error()+": ")
+}
+
 // should be called from goroutine
-func (c *Controller) testConnectionAndUpdateDialogText(dlg *dialogs.AddEditServerDialog) bool {
+func (c *Controller) testConnectionAndUpdateDialogText(dlg *dialogs.AddEditServerDialog, serverID uuid.UUID) bool {
 	fyne.Do(func() { dlg.SetInfoText(lang.L("Testing connection") + "...") })
 	conn := backend.ServerConnection{
 		ServerType:    dlg.ServerType,
@@ -252,11 +319,37 @@ func (c *Controller) testConnectionAndUpdateDialogText(dlg *dialogs.AddEditServe
 		Username:      dlg.Username,
 		LegacyAuth:    dlg.LegacyAuth,
 		SkipSSLVerify: dlg.SkipSSLVerify,
+		APIKeyAuth:    dlg.APIKeyAuth,
+
+		UnifiedServerIDs: dlg.MemberServerIDs,
+
+		CACertFile:         dlg.CACertFile,
+		ClientCertFile:     dlg.ClientCertFile,
+		ClientKeyFile:      dlg.ClientKeyFile,
+		ClientCertPassword: dlg.ClientCertPassword,
+		PinnedCertSHA256:   dlg.PinnedCertSHA256,
+		CustomHeaders:      dlg.CustomHeaders,
+		CookieFile:         dlg.CookieFile,
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	err := c.App.ServerManager.TestConnectionAndAuth(ctx, conn, dlg.Password)
-	if err == backend.ErrUnreachable {
+	err := c.App.ServerManager.TestConnectionAndAuth(ctx, serverID, conn, dlg.Password)
+	if errors.Is(err, backend.ErrInvalidTLSConfig) {
+		fyne.Do(func() {
+			dlg.SetErrorText(lang.L("Invalid TLS settings") + fmt.Sprintf(" (%s)", errorDetail(err, backend.ErrInvalidTLSConfig)))
+		})
+		return false
+	} else if errors.Is(err, backend.ErrInvalidCookieFile) {
+		fyne.Do(func() {
+			dlg.SetErrorText(lang.L("Invalid cookie file") + fmt.Sprintf(" (%s)", errorDetail(err, backend.ErrInvalidCookieFile)))
+		})
+		return false
+	} else if err != nil && conn.ServerType == backend.ServerTypeUnified {
+		fyne.Do(func() {
+			dlg.SetErrorText(lang.L("Could not connect to any of the servers"))
+		})
+		return false
+	} else if err == backend.ErrUnreachable {
 		fyne.Do(func() {
 			dlg.SetErrorText(lang.L("Could not reach server") + fmt.Sprintf(" (%s?)", lang.L("wrong URL")))
 		})
@@ -269,3 +362,74 @@ func (c *Controller) testConnectionAndUpdateDialogText(dlg *dialogs.AddEditServe
 	}
 	return true
 }
+
+// promptForCredentialPassphrase asks for the master passphrase of the
+// encrypted credential file, then calls onDone whether it was unlocked or not.
+func (c *Controller) promptForCredentialPassphrase(f *backend.CredentialFile, onDone func()) {
+	title, info := lang.L("Unlock saved credentials"), lang.L("Enter the passphrase of your saved credentials")
+	if !f.Exists() {
+		title, info = lang.L("Protect saved credentials"), lang.L("Choose a passphrase to encrypt your saved credentials with")
+	}
+	passEntry := widget.NewPasswordEntry()
+	dlg := dialog.NewForm(title, lang.L("OK"), lang.L("Cancel"),
+		[]*widget.FormItem{
+			widget.NewFormItem("", widget.NewLabel(info)),
+			widget.NewFormItem(lang.L("Passphrase"), passEntry),
+		},
+		func(ok bool) {
+			c.haveModal = false
+			if !ok || passEntry.Text == "" {
+				onDone()
+				return
+			}
+			pass := passEntry.Text
+			// deriving the key takes a moment, so don't block the UI
+			go func() {
+				err := f.Unlock(pass)
+				fyne.Do(func() {
+					if errors.Is(err, backend.ErrWrongPassphrase) {
+						c.ToastProvider.ShowErrorToast(lang.L("Wrong passphrase"))
+						c.promptForCredentialPassphrase(f, onDone)
+						return
+					} else if err != nil {
+						log.Printf("error unlocking credential file: %v", err)
+					}
+					onDone()
+				})
+			}()
+		}, c.MainWindow)
+	c.haveModal = true
+	dlg.Show()
+	c.MainWindow.Canvas().Focus(passEntry)
+}
+
+// ShowCredentialsDialog shows the credentials saved for each server.
+func (c *Controller) ShowCredentialsDialog() {
+	storage := lang.L("Credentials are saved in the system keyring")
+	if c.App.CredentialFile != nil {
+		storage = lang.L("Credentials are saved in an encrypted file")
+	}
+	dlg := dialogs.NewCredentialsDialog(storage)
+	pop := widget.NewModalPopUp(dlg, c.MainWindow.Canvas())
+	servers := slices.Clone(c.App.Config.Servers)
+	// the keyring may block while it is unlocked, so load in the background
+	load := func() {
+		saved := make(map[uuid.UUID][]backend.CredentialKind)
+		for _, s := range servers {
+			saved[s.ID] = c.App.ServerManager.SavedCredentials(s)
+		}
+		fyne.Do(func() { dlg.SetCredentials(servers, saved) })
+	}
+	dlg.OnRevoke = func(server *backend.ServerConfig, kind backend.CredentialKind) {
+		go func() {
+			if err := c.App.ServerManager.RevokeCredential(server, kind); err != nil {
+				log.Printf("error revoking credential: %v", err)
+				fyne.Do(func() { c.ToastProvider.ShowErrorToast(lang.L("Failed to revoke credential")) })
+			}
+			load()
+		}()
+	}
+	dlg.OnDismiss = pop.Hide
+	go load()
+	pop.Show()
+}
@@ -2,9 +2,11 @@ package controller
 
 import (
 	"math"
+	"slices"
 	"time"
 
 	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/lang"
 	"github.com/dweymouth/supersonic/backend/player"
 	"github.com/dweymouth/supersonic/backend/player/mpv"
@@ -14,10 +16,21 @@ import (
 	"github.com/dweymouth/supersonic/ui/visualizations"
 )
 
+// number of samples read for the sample-driven visualizations per frame
+const visualizationSamples = 4096
+
 // embedded in parent controller struct
 type visualizationData struct {
-	peakMeter    *visualizations.PeakMeter
-	peakMeterWin fyne.Window
+	peakMeter         *visualizations.PeakMeter
+	peakMeterWin      fyne.Window
+	visualizationTabs *container.AppTabs
+
+	// the sample-driven visualization shown in the peak meter window,
+	// if one is selected instead of the peak meter
+	windowVisualization visualizations.SampleVisualization
+	// the visualization shown in the Now Playing page, if any
+	nowPlayingVisualization visualizations.SampleVisualization
+	samplesL, samplesR      []float64
 
 	visualizationAnim *fyne.Animation
 }
@@ -27,23 +40,40 @@ func (c *Controller) initVisualizations() {
 	c.App.PlaybackManager.OnPaused(c.stopVisualizationAnim)
 	c.App.PlaybackManager.OnPlaying(func() {
 		if _, ok := c.App.PlaybackManager.CurrentPlayer().(*mpv.Player); ok {
-			if c.peakMeter != nil {
+			if c.visualizationsShown() {
 				c.startVisualizationAnim()
 			}
 		}
 	})
 }
 
+// SetNowPlayingVisualization sets the visualization shown in the
+// Now Playing page, to be driven while playing, or nil if none is shown.
+func (c *Controller) SetNowPlayingVisualization(v visualizations.SampleVisualization) {
+	c.nowPlayingVisualization = v
+	c.updateVisualizationAnim()
+}
+
 func (c *Controller) ShowPeakMeter() {
+	c.ShowVisualization("")
+}
+
+// ShowVisualization shows the visualizations window with the
+// sample-driven visualization of the given name, or "" for the peak meter.
+func (c *Controller) ShowVisualization(name string) {
+	c.App.Config.PeakMeter.Visualization = name
 	if c.peakMeterWin != nil {
+		c.visualizationTabs.SelectIndex(slices.Index(visualizations.SampleVisualizationNames, name) + 1)
 		c.peakMeterWin.Show()
 		return
 	}
-	c.peakMeterWin = fyne.CurrentApp().NewWindow(lang.L("Peak Meter"))
+	c.peakMeterWin = fyne.CurrentApp().NewWindow(lang.L("Visualizations"))
 
 	onClose := func() {
-		c.stopVisualizationAnim()
 		c.peakMeter = nil
+		c.windowVisualization = nil
+		c.visualizationTabs = nil
+		c.updateVisualizationAnim()
 		util.SaveWindowSize(c.peakMeterWin,
 			&c.App.Config.PeakMeter.WindowWidth,
 			&c.App.Config.PeakMeter.WindowHeight)
@@ -61,7 +91,27 @@ func (c *Controller) ShowPeakMeter() {
 			float32(c.App.Config.PeakMeter.WindowHeight)))
 	}
 	c.peakMeter = visualizations.NewPeakMeter()
-	c.peakMeterWin.SetContent(c.peakMeter)
+	tabs := container.NewAppTabs(container.NewTabItem(lang.L("Peak Meter"), c.peakMeter))
+	views := make([]visualizations.SampleVisualization, len(visualizations.SampleVisualizationNames))
+	for i, name := range visualizations.SampleVisualizationNames {
+		views[i] = visualizations.NewSampleVisualization(name)
+		tabs.Append(container.NewTabItem(lang.L(name), views[i]))
+		if name == c.App.Config.PeakMeter.Visualization {
+			tabs.SelectIndex(i + 1)
+			c.windowVisualization = views[i]
+		}
+	}
+	tabs.OnSelected = func(*container.TabItem) {
+		c.windowVisualization = nil
+		c.App.Config.PeakMeter.Visualization = ""
+		if idx := tabs.SelectedIndex(); idx > 0 {
+			c.windowVisualization = views[idx-1]
+			c.App.Config.PeakMeter.Visualization = visualizations.SampleVisualizationNames[idx-1]
+		}
+		c.updateVisualizationAnim()
+	}
+	c.visualizationTabs = tabs
+	c.peakMeterWin.SetContent(tabs)
 	if c.App.LocalPlayer.GetStatus().State == player.Playing {
 		c.startVisualizationAnim()
 	} else {
@@ -72,17 +122,49 @@ func (c *Controller) ShowPeakMeter() {
 	SetWindowThemeMode(c.peakMeterWin, fyne.CurrentApp().Settings().Theme().(*myTheme.MyTheme).AppearanceMode())
 }
 
+func (c *Controller) visualizationsShown() bool {
+	return c.peakMeter != nil || c.nowPlayingVisualization != nil
+}
+
+// peakMeterShown returns true if the peak meter is the selected
+// visualization in the peak meter window.
+func (c *Controller) peakMeterShown() bool {
+	return c.peakMeter != nil && c.windowVisualization == nil
+}
+
+func (c *Controller) sampleVisualizationsShown() bool {
+	return c.windowVisualization != nil || c.nowPlayingVisualization != nil
+}
+
+// updateVisualizationAnim starts or stops the animation, and the analysis
+// of the audio in the player, for the visualizations currently shown.
+func (c *Controller) updateVisualizationAnim() {
+	if !c.visualizationsShown() {
+		c.stopVisualizationAnim()
+		return
+	}
+	if c.visualizationAnim != nil {
+		c.App.LocalPlayer.SetPeaksEnabled(c.peakMeterShown())
+		c.App.LocalPlayer.SetSamplesEnabled(c.sampleVisualizationsShown())
+	} else if _, ok := c.App.PlaybackManager.CurrentPlayer().(*mpv.Player); ok &&
+		c.App.LocalPlayer.GetStatus().State == player.Playing {
+		c.startVisualizationAnim()
+	}
+}
+
 func (c *Controller) stopVisualizationAnim() {
 	if c.visualizationAnim != nil {
 		c.visualizationAnim.Stop()
 		c.visualizationAnim = nil
 		c.App.LocalPlayer.SetPeaksEnabled(false)
+		c.App.LocalPlayer.SetSamplesEnabled(false)
 	}
 }
 
 func (c *Controller) startVisualizationAnim() {
 	if c.visualizationAnim == nil {
-		c.App.LocalPlayer.SetPeaksEnabled(true)
+		c.App.LocalPlayer.SetPeaksEnabled(c.peakMeterShown())
+		c.App.LocalPlayer.SetSamplesEnabled(c.sampleVisualizationsShown())
 		c.visualizationAnim = fyne.NewAnimation(
 			time.Duration(math.MaxInt64), /*until stopped*/
 			c.tickVisualizations)
@@ -91,8 +173,24 @@ func (c *Controller) startVisualizationAnim() {
 }
 
 func (c *Controller) tickVisualizations(_ float32) {
-	lP, rP, lRMS, rRMS := c.App.LocalPlayer.GetPeaks()
-	if c.visualizationData.peakMeter != nil {
-		c.visualizationData.peakMeter.UpdatePeaks(lP, rP, lRMS, rRMS)
+	if c.peakMeterShown() {
+		lP, rP, lRMS, rRMS := c.App.LocalPlayer.GetPeaks()
+		c.peakMeter.UpdatePeaks(lP, rP, lRMS, rRMS)
+	}
+	if !c.sampleVisualizationsShown() {
+		return
+	}
+	if c.samplesL == nil {
+		c.samplesL = make([]float64, visualizationSamples)
+		c.samplesR = make([]float64, visualizationSamples)
+	}
+	n := c.App.LocalPlayer.ReadSamples(c.samplesL, c.samplesR)
+	if n == 0 {
+		return
+	}
+	for _, v := range []visualizations.SampleVisualization{c.windowVisualization, c.nowPlayingVisualization} {
+		if v != nil {
+			v.UpdateSamples(c.samplesL[:n], c.samplesR[:n], mpv.SampleTapSampleRate)
+		}
 	}
 }
@@ -18,7 +18,8 @@ import (
 type AboutDialog struct {
 	widget.BaseWidget
 
-	OnDismiss func()
+	OnDismiss  func()
+	OnShowLogs func()
 
 	content fyne.CanvasObject
 }
@@ -37,6 +38,11 @@ func NewAboutDialog(version string) *AboutDialog {
 		),
 		widget.NewSeparator(),
 		container.NewHBox(
+			widget.NewButtonWithIcon(lang.L("Show logs"), theme.DocumentIcon(), func() {
+				if a.OnShowLogs != nil {
+					a.OnShowLogs()
+				}
+			}),
 			layout.NewSpacer(),
 			widget.NewButton(lang.L("Close"), func() {
 				if a.OnDismiss != nil {
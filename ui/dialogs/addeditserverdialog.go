@@ -2,8 +2,11 @@ package dialogs
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/dweymouth/supersonic/backend"
+	"github.com/dweymouth/supersonic/sharedutil"
+	"github.com/google/uuid"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -24,19 +27,34 @@ type AddEditServerDialog struct {
 	Username      string
 	Password      string
 	LegacyAuth    bool
+	APIKeyAuth    bool
 	SkipSSLVerify bool
+	// IDs of the member servers, if ServerType is Unified
+	MemberServerIDs []uuid.UUID
+	// TLS settings
+	CACertFile         string
+	ClientCertFile     string
+	ClientKeyFile      string
+	ClientCertPassword string
+	PinnedCertSHA256   string
+	// HTTP settings
+	CustomHeaders map[string]string
+	CookieFile    string
 	OnSubmit      func()
 	OnCancel      func()
 
-	passField  *widget.Entry
-	submitBtn  *widget.Button
-	promptText *widget.RichText
-	container  *fyne.Container
+	headersField *widget.Entry
+	passField    *widget.Entry
+	submitBtn    *widget.Button
+	promptText   *widget.RichText
+	container    *fyne.Container
 }
 
 var _ fyne.Widget = (*AddEditServerDialog)(nil)
 
-func NewAddEditServerDialog(title string, cancelable bool, prefillServer *backend.ServerConfig, focusHandler func(fyne.Focusable)) *AddEditServerDialog {
+// NewAddEditServerDialog creates the dialog to add or edit a server. The
+// configured servers are offered as members if a Unified server is added.
+func NewAddEditServerDialog(title string, cancelable bool, prefillServer *backend.ServerConfig, servers []*backend.ServerConfig, focusHandler func(fyne.Focusable)) *AddEditServerDialog {
 	a := &AddEditServerDialog{}
 	a.ExtendBaseWidget(a)
 	if prefillServer != nil {
@@ -46,38 +64,114 @@ func NewAddEditServerDialog(title string, cancelable bool, prefillServer *backen
 		a.AltHost = prefillServer.AltHostname
 		a.Username = prefillServer.Username
 		a.LegacyAuth = prefillServer.LegacyAuth
+		a.APIKeyAuth = prefillServer.APIKeyAuth
 		a.SkipSSLVerify = prefillServer.SkipSSLVerify
+		a.MemberServerIDs = slices.Clone(prefillServer.UnifiedServerIDs)
+		a.CACertFile = prefillServer.CACertFile
+		a.ClientCertFile = prefillServer.ClientCertFile
+		a.ClientKeyFile = prefillServer.ClientKeyFile
+		a.PinnedCertSHA256 = prefillServer.PinnedCertSHA256
+		a.CustomHeaders = prefillServer.CustomHeaders
+		a.CookieFile = prefillServer.CookieFile
 	}
 
+	// servers that can be members of a Unified server
+	memberServers := sharedutil.FilterSlice(slices.Clone(servers), func(s *backend.ServerConfig) bool {
+		return s.ServerType != backend.ServerTypeUnified && (prefillServer == nil || s.ID != prefillServer.ID)
+	})
+	memberNames := sharedutil.MapSlice(memberServers, func(s *backend.ServerConfig) string { return s.Nickname })
+	membersCheck := widget.NewCheckGroup(memberNames, func(selected []string) {
+		a.MemberServerIDs = nil
+		for _, s := range memberServers {
+			if slices.Contains(selected, s.Nickname) {
+				a.MemberServerIDs = append(a.MemberServerIDs, s.ID)
+			}
+		}
+	})
+	for _, s := range memberServers {
+		if slices.Contains(a.MemberServerIDs, s.ID) {
+			membersCheck.Selected = append(membersCheck.Selected, s.Nickname)
+		}
+	}
+	membersLabel := widget.NewLabel(lang.L("Servers"))
+
 	titleLabel := widget.NewLabel(title)
 	titleLabel.TextStyle.Bold = true
 	legacyAuthCheck := widget.NewCheckWithData(lang.L("Use legacy authentication"), binding.BindBool(&a.LegacyAuth))
-	serverTypeChoice := widget.NewRadioGroup([]string{"Subsonic", "Jellyfin"}, func(s string) {
-		a.ServerType = backend.ServerType(s)
-		if s == string(backend.ServerTypeSubsonic) {
-			legacyAuthCheck.Show()
+	userLabel := widget.NewLabel(lang.L("Username"))
+	passLabel := widget.NewLabel(lang.L("Password"))
+	a.passField = widget.NewPasswordEntry()
+	userField := widget.NewEntryWithData(binding.BindString(&a.Username))
+	hostField := widget.NewEntryWithData(binding.BindString(&a.Host))
+	hostLabel := widget.NewLabel(lang.L("URL"))
+	altHostLabel := widget.NewLabel(lang.L("Alt. URL"))
+	var altHostField *widget.Entry
+	var skipSSLCheck, apiKeyCheck *widget.Check
+	var advanced *widget.Accordion
+	updateFieldsForServerType := func() {
+		isSubsonic := a.ServerType == backend.ServerTypeSubsonic
+		showOrHide(apiKeyCheck, isSubsonic)
+		// an OpenSubsonic API key replaces the password
+		showOrHide(legacyAuthCheck, isSubsonic && !a.APIKeyAuth)
+		if isSubsonic && a.APIKeyAuth {
+			passLabel.SetText(lang.L("API key"))
 		} else {
-			legacyAuthCheck.Hide()
+			passLabel.SetText(lang.L("Password"))
 		}
+		// DLNA media servers have no authentication, and Unified servers
+		// connect to their members with the members' saved credentials
+		hasAuth := a.ServerType != backend.ServerTypeDLNA && a.ServerType != backend.ServerTypeUnified
+		for _, w := range []fyne.CanvasObject{userLabel, userField, passLabel, a.passField} {
+			showOrHide(w, hasAuth)
+		}
+		isUnified := a.ServerType == backend.ServerTypeUnified
+		for _, w := range []fyne.CanvasObject{hostLabel, hostField, altHostLabel, altHostField, skipSSLCheck, advanced} {
+			showOrHide(w, !isUnified)
+		}
+		showOrHide(membersLabel, isUnified)
+		showOrHide(membersCheck, isUnified)
+		if a.ServerType == backend.ServerTypeDLNA {
+			hostField.SetPlaceHolder("http://192.168.1.10:8200")
+		} else {
+			hostField.SetPlaceHolder("http://localhost:4533")
+		}
+	}
+	serverTypes := []string{"Subsonic", "Jellyfin", "DLNA"}
+	// a Unified server combines the libraries of at least two other servers
+	if len(memberServers) >= 2 {
+		serverTypes = append(serverTypes, string(backend.ServerTypeUnified))
+	}
+	serverTypeChoice := widget.NewRadioGroup(serverTypes, func(s string) {
+		a.ServerType = backend.ServerType(s)
+		updateFieldsForServerType()
 	})
-	skipSSLCheck := widget.NewCheckWithData(lang.L("Skip SSL certificate verification"), binding.BindBool(&a.SkipSSLVerify))
+	apiKeyCheck = widget.NewCheck(lang.L("Use API key"), func(b bool) {
+		a.APIKeyAuth = b
+		updateFieldsForServerType()
+	})
+	apiKeyCheck.Checked = a.APIKeyAuth
+	skipSSLCheck = widget.NewCheckWithData(lang.L("Skip SSL certificate verification"), binding.BindBool(&a.SkipSSLVerify))
 	serverTypeChoice.Required = true
 	serverTypeChoice.Horizontal = true
-	selected := backend.ServerTypeSubsonic
-	if a.ServerType == backend.ServerTypeJellyfin {
-		selected = backend.ServerTypeJellyfin
+	if !slices.Contains(serverTypes, string(a.ServerType)) {
+		a.ServerType = backend.ServerTypeSubsonic
 	}
-	serverTypeChoice.Selected = string(selected)
-	a.passField = widget.NewPasswordEntry()
+	serverTypeChoice.Selected = string(a.ServerType)
 	a.passField.OnSubmitted = func(_ string) { a.doSubmit() }
-	userField := widget.NewEntryWithData(binding.BindString(&a.Username))
 	userField.OnSubmitted = func(_ string) { focusHandler(a.passField) }
-	altHostField := widget.NewEntryWithData(binding.BindString(&a.AltHost))
+	altHostField = widget.NewEntryWithData(binding.BindString(&a.AltHost))
 	altHostField.SetPlaceHolder(fmt.Sprintf("(%s)", lang.L("optional")) + " https://my-external-domain.net/music")
-	altHostField.OnSubmitted = func(_ string) { focusHandler(userField) }
-	hostField := widget.NewEntryWithData(binding.BindString(&a.Host))
-	hostField.SetPlaceHolder("http://localhost:4533")
+	altHostField.OnSubmitted = func(_ string) {
+		if a.ServerType == backend.ServerTypeDLNA {
+			a.doSubmit()
+		} else {
+			focusHandler(userField)
+		}
+	}
 	hostField.OnSubmitted = func(_ string) { focusHandler(altHostField) }
+	advanced = widget.NewAccordion(widget.NewAccordionItem(lang.L("Advanced"),
+		container.NewVBox(a.newTLSForm(), widget.NewSeparator(), a.newHTTPForm())))
+	updateFieldsForServerType()
 	nickField := widget.NewEntryWithData(binding.BindString(&a.Nickname))
 	nickField.SetPlaceHolder(lang.L("My Server"))
 	nickField.OnSubmitted = func(_ string) { focusHandler(hostField) }
@@ -107,22 +201,73 @@ func NewAddEditServerDialog(title string, cancelable bool, prefillServer *backen
 			serverTypeChoice,
 			widget.NewLabel(lang.L("Nickname")),
 			nickField,
-			widget.NewLabel(lang.L("URL")),
+			hostLabel,
 			hostField,
-			widget.NewLabel(lang.L("Alt. URL")),
+			altHostLabel,
 			altHostField,
-			widget.NewLabel(lang.L("Username")),
+			membersLabel,
+			membersCheck,
+			userLabel,
 			userField,
-			widget.NewLabel(lang.L("Password")),
+			passLabel,
 			a.passField,
 		),
-		container.NewHBox(layout.NewSpacer(), legacyAuthCheck, skipSSLCheck),
+		container.NewHBox(layout.NewSpacer(), legacyAuthCheck, apiKeyCheck, skipSSLCheck),
+		advanced,
 		widget.NewSeparator(),
 		bottomRow,
 	)
 	return a
 }
 
+// newTLSForm creates the form for the CA bundle, client certificate
+// and pinned certificate fingerprint of the server.
+func (a *AddEditServerDialog) newTLSForm() fyne.CanvasObject {
+	optional := fmt.Sprintf("(%s) ", lang.L("optional"))
+	caField := widget.NewEntryWithData(binding.BindString(&a.CACertFile))
+	caField.SetPlaceHolder(optional + "/path/to/ca.pem")
+	certField := widget.NewEntryWithData(binding.BindString(&a.ClientCertFile))
+	certField.SetPlaceHolder(optional + "/path/to/client.pem, client.p12")
+	keyField := widget.NewEntryWithData(binding.BindString(&a.ClientKeyFile))
+	keyField.SetPlaceHolder(optional + "/path/to/client.key")
+	certPassField := widget.NewPasswordEntry()
+	certPassField.Bind(binding.BindString(&a.ClientCertPassword))
+	certPassField.SetPlaceHolder(lang.L("For PKCS#12 certificates"))
+	pinField := widget.NewEntryWithData(binding.BindString(&a.PinnedCertSHA256))
+	pinField.SetPlaceHolder(optional + "AB:CD:EF:...")
+
+	return container.New(layout.NewFormLayout(),
+		widget.NewLabel(lang.L("CA certificates")),
+		caField,
+		widget.NewLabel(lang.L("Client certificate")),
+		certField,
+		widget.NewLabel(lang.L("Client key")),
+		keyField,
+		widget.NewLabel(lang.L("Certificate password")),
+		certPassField,
+		widget.NewLabel(lang.L("Pinned SHA-256")),
+		pinField,
+	)
+}
+
+// newHTTPForm creates the form for the custom headers and cookie file
+// of the server, e.g. for authentication by a reverse proxy.
+func (a *AddEditServerDialog) newHTTPForm() fyne.CanvasObject {
+	a.headersField = widget.NewMultiLineEntry()
+	a.headersField.SetText(backend.FormatHeaderLines(a.CustomHeaders))
+	a.headersField.SetPlaceHolder(fmt.Sprintf("(%s) ", lang.L("optional")) + "Remote-User: alice")
+	a.headersField.SetMinRowsVisible(2)
+	cookieField := widget.NewEntryWithData(binding.BindString(&a.CookieFile))
+	cookieField.SetPlaceHolder(fmt.Sprintf("(%s) ", lang.L("optional")) + "/path/to/cookies.txt")
+
+	return container.New(layout.NewFormLayout(),
+		widget.NewLabel(lang.L("HTTP headers")),
+		a.headersField,
+		widget.NewLabel(lang.L("Cookie file")),
+		cookieField,
+	)
+}
+
 func (a *AddEditServerDialog) SetInfoText(text string) {
 	a.doSetPromptText(text, theme.ColorNameForeground)
 }
@@ -143,6 +288,16 @@ func (a *AddEditServerDialog) DisableSubmit() {
 
 func (a *AddEditServerDialog) doSubmit() {
 	a.Password = a.passField.Text
+	headers, err := backend.ParseHeaderLines(a.headersField.Text)
+	if err != nil {
+		a.SetErrorText(lang.L("Invalid HTTP headers"))
+		return
+	}
+	a.CustomHeaders = headers
+	if a.ServerType == backend.ServerTypeUnified && len(a.MemberServerIDs) < 2 {
+		a.SetErrorText(lang.L("Select at least two servers"))
+		return
+	}
 	if a.OnSubmit != nil {
 		a.OnSubmit()
 	}
@@ -166,6 +321,14 @@ func (a *AddEditServerDialog) doSetPromptText(text string, color fyne.ThemeColor
 	a.promptText.Refresh()
 }
 
+func showOrHide(w fyne.CanvasObject, show bool) {
+	if show {
+		w.Show()
+	} else {
+		w.Hide()
+	}
+}
+
 func (a *AddEditServerDialog) MinSize() fyne.Size {
 	a.ExtendBaseWidget(a)
 	return fyne.NewSize(475, a.container.MinSize().Height)
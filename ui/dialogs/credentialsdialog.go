@@ -0,0 +1,87 @@
+package dialogs
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/lang"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/dweymouth/supersonic/backend"
+	"github.com/google/uuid"
+)
+
+// CredentialsDialog lists the credentials saved for each server
+// and lets the user revoke them.
+type CredentialsDialog struct {
+	widget.BaseWidget
+
+	OnDismiss func()
+	OnRevoke  func(server *backend.ServerConfig, kind backend.CredentialKind)
+
+	credentialsForm *fyne.Container
+	emptyLabel      *widget.Label
+	container       *fyne.Container
+}
+
+func NewCredentialsDialog(storageDescription string) *CredentialsDialog {
+	d := &CredentialsDialog{}
+	d.ExtendBaseWidget(d)
+
+	title := widget.NewRichTextWithText(lang.L("Saved credentials"))
+	title.Segments[0].(*widget.TextSegment).Style.TextStyle.Bold = true
+	storage := widget.NewLabel(storageDescription)
+	storage.Importance = widget.LowImportance
+	closeBtn := widget.NewButton(lang.L("Close"), func() {
+		if d.OnDismiss != nil {
+			d.OnDismiss()
+		}
+	})
+
+	d.credentialsForm = container.New(layout.NewFormLayout())
+	d.emptyLabel = widget.NewLabel(lang.L("Loading") + "...")
+	d.container = container.NewBorder(
+		/*top*/ container.NewVBox(
+			container.NewHBox(layout.NewSpacer(), title, layout.NewSpacer()),
+			storage,
+		),
+		/*bottom*/ container.NewVBox(
+			widget.NewSeparator(),
+			container.NewHBox(layout.NewSpacer(), closeBtn),
+		),
+		/*left/right*/ nil, nil,
+		/*center*/ container.NewVScroll(container.NewVBox(d.emptyLabel, d.credentialsForm)),
+	)
+	return d
+}
+
+// SetCredentials shows the kinds of credentials saved for each server.
+func (d *CredentialsDialog) SetCredentials(servers []*backend.ServerConfig, saved map[uuid.UUID][]backend.CredentialKind) {
+	d.credentialsForm.RemoveAll()
+	for _, s := range servers {
+		for _, kind := range saved[s.ID] {
+			revoke := widget.NewButtonWithIcon(lang.L("Revoke"), theme.DeleteIcon(), func() {
+				if d.OnRevoke != nil {
+					d.OnRevoke(s, kind)
+				}
+			})
+			d.credentialsForm.Add(widget.NewLabel(s.Nickname))
+			d.credentialsForm.Add(container.NewBorder(nil, nil, nil, revoke, widget.NewLabel(lang.L(string(kind)))))
+		}
+	}
+	if len(d.credentialsForm.Objects) == 0 {
+		d.emptyLabel.SetText(lang.L("No credentials are saved"))
+		d.emptyLabel.Show()
+	} else {
+		d.emptyLabel.Hide()
+	}
+	d.credentialsForm.Refresh()
+}
+
+func (d *CredentialsDialog) MinSize() fyne.Size {
+	return fyne.NewSize(450, 300)
+}
+
+func (d *CredentialsDialog) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(d.container)
+}
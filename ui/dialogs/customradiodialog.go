@@ -0,0 +1,145 @@
+package dialogs
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/lang"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+	"github.com/dweymouth/supersonic/backend"
+	"github.com/dweymouth/supersonic/ui/widgets"
+)
+
+var radioDecades = []int{1950, 1960, 1970, 1980, 1990, 2000, 2010, 2020}
+
+type CustomRadioDialog struct {
+	widget.BaseWidget
+
+	OnDismiss    func()
+	OnStartRadio func(params backend.RadioParams)
+
+	Params backend.RadioParams
+
+	genres    *widget.CheckGroup
+	container *fyne.Container
+}
+
+// NewCustomRadioDialog creates a dialog for configuring a custom radio.
+// seedDescriptions are the display names of the seed tracks or artist, if any.
+func NewCustomRadioDialog(params backend.RadioParams, seedDescriptions []string) *CustomRadioDialog {
+	d := &CustomRadioDialog{Params: params}
+	d.ExtendBaseWidget(d)
+
+	d.genres = widget.NewCheckGroup(nil, func(selected []string) {
+		d.Params.Genres = selected
+	})
+	d.genres.Selected = params.Genres
+	genreScroll := container.NewVScroll(d.genres)
+	genreScroll.SetMinSize(fyne.NewSize(0, 150))
+
+	startBtn := widget.NewButton(lang.L("Start radio"), func() {
+		if d.OnStartRadio != nil {
+			d.OnStartRadio(d.Params)
+		}
+	})
+	startBtn.Importance = widget.HighImportance
+	cancelBtn := widget.NewButton(lang.L("Cancel"), func() {
+		if d.OnDismiss != nil {
+			d.OnDismiss()
+		}
+	})
+
+	title := widget.NewRichTextWithText(lang.L("Start custom radio"))
+	title.Segments[0].(*widget.TextSegment).Style.TextStyle.Bold = true
+
+	content := container.NewVBox()
+	if len(seedDescriptions) > 0 {
+		seeds := widget.NewLabel(strings.Join(seedDescriptions, ", "))
+		seeds.Wrapping = fyne.TextWrapWord
+		content.Add(container.New(layout.NewFormLayout(), newFormText(lang.L("Seeds"), true), seeds))
+	}
+	content.Add(widget.NewLabelWithStyle(lang.L("Genres"), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+	content.Add(genreScroll)
+	content.Add(widget.NewSeparator())
+	content.Add(newRadioConstraintsForm(&d.Params.RadioConfig))
+
+	d.container = container.NewBorder(
+		/*top*/ container.NewHBox(layout.NewSpacer(), title, layout.NewSpacer()),
+		/*bottom*/ container.NewVBox(
+			widget.NewSeparator(),
+			container.NewHBox(layout.NewSpacer(), cancelBtn, startBtn),
+		),
+		/*left/right*/ nil, nil,
+		/*center*/ container.NewVScroll(content),
+	)
+	return d
+}
+
+// SetGenres sets the genres available for mixing into the radio.
+func (d *CustomRadioDialog) SetGenres(genres []string) {
+	d.genres.Options = genres
+	d.genres.Refresh()
+}
+
+func (d *CustomRadioDialog) MinSize() fyne.Size {
+	return fyne.NewSize(550, 450)
+}
+
+func (d *CustomRadioDialog) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(d.container)
+}
+
+// newRadioConstraintsForm creates form rows that edit cfg in place.
+func newRadioConstraintsForm(cfg *backend.RadioConfig) *fyne.Container {
+	decades := container.NewGridWithColumns(4)
+	for _, decade := range radioDecades {
+		check := widget.NewCheck(fmt.Sprintf("%ds", decade), func(b bool) {
+			cfg.Decades = slices.DeleteFunc(cfg.Decades, func(d int) bool { return d == decade })
+			if b {
+				cfg.Decades = append(cfg.Decades, decade)
+				slices.Sort(cfg.Decades)
+			}
+		})
+		check.Checked = slices.Contains(cfg.Decades, decade)
+		decades.Add(check)
+	}
+
+	threeDigitValidator := func(text, selText string, r rune) bool {
+		return unicode.IsDigit(r) && len(text)-len(selText) < 3
+	}
+	newIntEntry := func(val *int, validator widgets.CharAllowedFunc, numChars int) *widgets.TextRestrictedEntry {
+		e := widgets.NewTextRestrictedEntry(validator)
+		e.SetMinCharWidth(numChars)
+		if *val > 0 {
+			e.Text = strconv.Itoa(*val)
+		}
+		e.OnChanged = func(s string) {
+			*val, _ = strconv.Atoi(s) // empty string -> 0 (unconstrained)
+		}
+		return e
+	}
+	minBPM := newIntEntry(&cfg.MinBPM, threeDigitValidator, 3)
+	maxBPM := newIntEntry(&cfg.MaxBPM, threeDigitValidator, 3)
+	separation := newIntEntry(&cfg.ArtistSeparation, func(text, selText string, r rune) bool {
+		return unicode.IsDigit(r) && len(text)-len(selText) < 2
+	}, 2)
+
+	ratingOpts := []string{lang.L("Any"), "1", "2", "3", "4", "5"}
+	minRating := widget.NewSelect(ratingOpts, func(s string) {
+		cfg.MinRating, _ = strconv.Atoi(s) // "Any" -> 0
+	})
+	minRating.SetSelectedIndex(min(max(cfg.MinRating, 0), 5))
+
+	return container.New(layout.NewFormLayout(),
+		widget.NewLabel(lang.L("Decades")), decades,
+		widget.NewLabel(lang.L("BPM range")), container.NewHBox(minBPM, widget.NewLabel("–"), maxBPM),
+		widget.NewLabel(lang.L("Minimum rating")), container.NewHBox(minRating),
+		widget.NewLabel(lang.L("Don't repeat artist within")), container.NewHBox(separation, widget.NewLabel(lang.L("tracks"))),
+	)
+}
@@ -0,0 +1,166 @@
+package dialogs
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/lang"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+	"github.com/dweymouth/supersonic/backend"
+	"github.com/dweymouth/supersonic/backend/mediaprovider"
+	"github.com/dweymouth/supersonic/ui/util"
+)
+
+type DuplicateTracksDialog struct {
+	widget.BaseWidget
+
+	OnDismiss            func()
+	OnReplaceInPlaylists func(groups []*backend.DuplicateTrackGroup)
+
+	groups     []*backend.DuplicateTrackGroup
+	status     *widget.Label
+	list       *widget.List
+	replaceBtn *widget.Button
+	container  *fyne.Container
+}
+
+func NewDuplicateTracksDialog() *DuplicateTracksDialog {
+	d := &DuplicateTracksDialog{}
+	d.ExtendBaseWidget(d)
+
+	d.status = widget.NewLabel(lang.L("Scanning library") + "...")
+	d.status.Alignment = fyne.TextAlignCenter
+	d.list = widget.NewList(
+		func() int { return len(d.groups) },
+		func() fyne.CanvasObject { return newDuplicateGroupRow() },
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			obj.(*duplicateGroupRow).Update(d.groups[id])
+			d.list.SetItemHeight(id, obj.MinSize().Height)
+		},
+	)
+	d.list.HideSeparators = false
+	d.replaceBtn = widget.NewButton(lang.L("Replace in Playlists"), func() {
+		if d.OnReplaceInPlaylists != nil {
+			d.OnReplaceInPlaylists(d.groups)
+		}
+	})
+	d.replaceBtn.Importance = widget.HighImportance
+	d.replaceBtn.Disable()
+
+	title := widget.NewRichTextWithText(lang.L("Duplicate Tracks"))
+	title.Segments[0].(*widget.TextSegment).Style.TextStyle.Bold = true
+	dismissBtn := widget.NewButton(lang.L("Close"), func() {
+		if d.OnDismiss != nil {
+			d.OnDismiss()
+		}
+	})
+
+	d.container = container.NewBorder(
+		/*top*/ container.NewVBox(
+			container.NewHBox(layout.NewSpacer(), title, layout.NewSpacer()),
+			d.status,
+		),
+		/*bottom*/ container.NewVBox(
+			widget.NewSeparator(),
+			container.NewHBox(layout.NewSpacer(), d.replaceBtn, dismissBtn),
+		),
+		/*left/right*/ nil, nil,
+		/*center*/ d.list,
+	)
+	return d
+}
+
+// SetScanProgress updates the status text with the number of tracks scanned.
+func (d *DuplicateTracksDialog) SetScanProgress(scanned int) {
+	d.status.SetText(fmt.Sprintf("%s... (%d)", lang.L("Scanning library"), scanned))
+}
+
+// SetGroups shows the duplicate groups found by the library scan.
+func (d *DuplicateTracksDialog) SetGroups(groups []*backend.DuplicateTrackGroup) {
+	d.groups = groups
+	if len(groups) == 0 {
+		d.status.SetText(lang.L("No duplicate tracks found"))
+		d.replaceBtn.Disable()
+	} else {
+		d.status.SetText(lang.L("Choose the copy of each track to keep"))
+		d.replaceBtn.Enable()
+	}
+	d.list.Refresh()
+}
+
+func (d *DuplicateTracksDialog) MinSize() fyne.Size {
+	return fyne.NewSize(600, 450)
+}
+
+func (d *DuplicateTracksDialog) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(d.container)
+}
+
+type duplicateGroupRow struct {
+	widget.BaseWidget
+
+	group   *backend.DuplicateTrackGroup
+	title   *widget.Label
+	options *widget.RadioGroup
+
+	container *fyne.Container
+}
+
+func newDuplicateGroupRow() *duplicateGroupRow {
+	r := &duplicateGroupRow{
+		title:   widget.NewLabel(""),
+		options: widget.NewRadioGroup(nil, nil),
+	}
+	r.ExtendBaseWidget(r)
+	r.title.TextStyle.Bold = true
+	r.title.Truncation = fyne.TextTruncateEllipsis
+	r.options.Required = true
+	r.options.OnChanged = func(selected string) {
+		if r.group == nil {
+			return
+		}
+		for i, opt := range r.options.Options {
+			if opt == selected {
+				r.group.Preferred = i
+				return
+			}
+		}
+	}
+	r.container = container.NewVBox(r.title, r.options)
+	return r
+}
+
+func (r *duplicateGroupRow) Update(group *backend.DuplicateTrackGroup) {
+	r.group = nil // don't update preferred index while rebuilding options
+	tr := group.Tracks[0]
+	r.title.SetText(fmt.Sprintf("%s – %s", tr.Title, strings.Join(tr.ArtistNames, ", ")))
+	opts := make([]string, len(group.Tracks))
+	for i, t := range group.Tracks {
+		opts[i] = duplicateTrackDescription(i, t)
+	}
+	r.options.Options = opts
+	r.options.Selected = opts[group.Preferred]
+	r.options.Refresh()
+	r.group = group
+}
+
+func (r *duplicateGroupRow) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(r.container)
+}
+
+func duplicateTrackDescription(idx int, tr *mediaprovider.Track) string {
+	format := strings.ToUpper(tr.Extension)
+	if format == "" {
+		format = tr.ContentType
+	}
+	details := []string{format, fmt.Sprintf("%d kbps", tr.BitRate)}
+	if tr.BitDepth > 0 && tr.SampleRate > 0 {
+		details = append(details, fmt.Sprintf("%d-bit/%g kHz", tr.BitDepth, float64(tr.SampleRate)/1000))
+	}
+	details = append(details, util.SecondsToTimeString(tr.Duration.Seconds()))
+	// prefix with index so that options are unique even if metadata matches
+	return fmt.Sprintf("%d. %s (%s)", idx+1, tr.Album, strings.Join(details, ", "))
+}
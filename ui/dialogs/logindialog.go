@@ -27,6 +27,7 @@ type LoginDialog struct {
 
 	serverSelect *widget.Select
 	passField    *widget.Entry
+	passLabel    *widget.Label
 	promptText   *widget.RichText
 	submitBtn    *widget.Button
 
@@ -42,11 +43,27 @@ func NewLoginDialog(servers []*backend.ServerConfig, pwFetch PasswordFetchFunc)
 	titleLabel.TextStyle.Bold = true
 	l.passField = widget.NewPasswordEntry()
 	l.passField.OnSubmitted = func(_ string) { l.onSubmit() }
+	l.passLabel = widget.NewLabel(lang.L("Password"))
 
 	serverNames := sharedutil.MapSlice(servers, func(s *backend.ServerConfig) string { return s.Nickname })
 	l.serverSelect = widget.NewSelect(serverNames, func(_ string) {
+		// DLNA servers don't require a password, and Unified servers
+		// use the saved passwords of their member servers
+		server := l.servers[l.serverSelect.SelectedIndex()]
+		if t := server.ServerType; t == backend.ServerTypeDLNA || t == backend.ServerTypeUnified {
+			l.passLabel.Hide()
+			l.passField.Hide()
+		} else {
+			l.passLabel.Show()
+			l.passField.Show()
+		}
+		if server.APIKeyAuth {
+			l.passLabel.SetText(lang.L("API key"))
+		} else {
+			l.passLabel.SetText(lang.L("Password"))
+		}
 		if pwFetch != nil {
-			if pw, err := pwFetch(servers[l.serverSelect.SelectedIndex()].ID); err == nil {
+			if pw, err := pwFetch(l.servers[l.serverSelect.SelectedIndex()].ID); err == nil {
 				l.passField.SetText(pw)
 				return
 			}
@@ -69,7 +86,7 @@ func NewLoginDialog(servers []*backend.ServerConfig, pwFetch PasswordFetchFunc)
 		container.New(layout.NewFormLayout(),
 			widget.NewLabel(lang.L("Server")),
 			container.NewBorder(nil, nil, nil, container.NewHBox(editBtn, newBtn, deleteBtn), l.serverSelect),
-			widget.NewLabel(lang.L("Password")),
+			l.passLabel,
 			l.passField),
 		widget.NewSeparator(),
 		container.NewHBox(l.promptText, layout.NewSpacer(), l.submitBtn),
@@ -0,0 +1,164 @@
+package dialogs
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/lang"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/dweymouth/supersonic/backend"
+	"github.com/dweymouth/supersonic/sharedutil"
+	"github.com/dweymouth/supersonic/ui/util"
+)
+
+var networkInspectorColumns = []struct {
+	name  string
+	width float32
+}{
+	{"Time", 90},
+	{"Method", 70},
+	{"Status", 70},
+	{"Latency", 80},
+	{"Size", 80},
+	{"URL", 600},
+}
+
+// NetworkInspectorDialog shows the requests recorded in the network log.
+type NetworkInspectorDialog struct {
+	widget.BaseWidget
+
+	OnDismiss       func()
+	OnClear         func()
+	OnExportHAR     func()
+	OnRecordChanged func(record bool)
+
+	entries  []backend.NetworkLogEntry
+	filtered []backend.NetworkLogEntry
+
+	filter    *widget.Entry
+	table     *widget.Table
+	container *fyne.Container
+}
+
+func NewNetworkInspectorDialog(recording bool) *NetworkInspectorDialog {
+	d := &NetworkInspectorDialog{}
+	d.ExtendBaseWidget(d)
+
+	title := widget.NewRichTextWithText(lang.L("Network inspector"))
+	title.Segments[0].(*widget.TextSegment).Style.TextStyle.Bold = true
+	record := widget.NewCheck(lang.L("Record requests"), func(b bool) {
+		if d.OnRecordChanged != nil {
+			d.OnRecordChanged(b)
+		}
+	})
+	record.Checked = recording
+	d.filter = widget.NewEntry()
+	d.filter.SetPlaceHolder(lang.L("Filter"))
+	d.filter.OnChanged = func(string) { d.applyFilter() }
+
+	d.table = widget.NewTableWithHeaders(
+		func() (int, int) { return len(d.filtered), len(networkInspectorColumns) },
+		func() fyne.CanvasObject {
+			l := widget.NewLabel("")
+			l.Truncation = fyne.TextTruncateEllipsis
+			return l
+		},
+		func(id widget.TableCellID, obj fyne.CanvasObject) {
+			obj.(*widget.Label).SetText(d.cellText(d.filtered[id.Row], id.Col))
+		},
+	)
+	d.table.ShowHeaderColumn = false
+	d.table.UpdateHeader = func(id widget.TableCellID, obj fyne.CanvasObject) {
+		if id.Col >= 0 {
+			obj.(*widget.Label).SetText(lang.L(networkInspectorColumns[id.Col].name))
+		}
+	}
+	for i, col := range networkInspectorColumns {
+		d.table.SetColumnWidth(i, col.width)
+	}
+
+	clearBtn := widget.NewButtonWithIcon(lang.L("Clear"), theme.DeleteIcon(), func() {
+		if d.OnClear != nil {
+			d.OnClear()
+		}
+	})
+	exportBtn := widget.NewButtonWithIcon(lang.L("Export HAR")+"...", theme.DocumentSaveIcon(), func() {
+		if d.OnExportHAR != nil {
+			d.OnExportHAR()
+		}
+	})
+	closeBtn := widget.NewButton(lang.L("Close"), func() {
+		if d.OnDismiss != nil {
+			d.OnDismiss()
+		}
+	})
+
+	d.container = container.NewBorder(
+		/*top*/ container.NewVBox(
+			container.NewHBox(layout.NewSpacer(), title, layout.NewSpacer()),
+			container.NewBorder(nil, nil, record, nil, d.filter),
+		),
+		/*bottom*/ container.NewVBox(
+			widget.NewSeparator(),
+			container.NewHBox(clearBtn, exportBtn, layout.NewSpacer(), closeBtn),
+		),
+		/*left/right*/ nil, nil,
+		/*center*/ d.table,
+	)
+	return d
+}
+
+// SetEntries shows the recorded requests, newest first.
+func (d *NetworkInspectorDialog) SetEntries(entries []backend.NetworkLogEntry) {
+	d.entries = entries
+	d.applyFilter()
+}
+
+func (d *NetworkInspectorDialog) applyFilter() {
+	filter := strings.ToLower(d.filter.Text)
+	d.filtered = sharedutil.FilterSlice(d.entries, func(e backend.NetworkLogEntry) bool {
+		return filter == "" ||
+			strings.Contains(strings.ToLower(e.URL), filter) ||
+			strings.Contains(strings.ToLower(e.Method), filter) ||
+			strings.Contains(strconv.Itoa(e.Status), filter)
+	})
+	slices.Reverse(d.filtered) // newest first
+	d.table.Refresh()
+}
+
+func (d *NetworkInspectorDialog) cellText(e backend.NetworkLogEntry, col int) string {
+	switch col {
+	case 0:
+		return e.Time.Format("15:04:05")
+	case 1:
+		return e.Method
+	case 2:
+		if e.Status == 0 {
+			return lang.L("Error")
+		}
+		return strconv.Itoa(e.Status)
+	case 3:
+		return fmt.Sprintf("%d ms", e.Latency.Milliseconds())
+	case 4:
+		return util.BytesToSizeString(e.ResponseSize)
+	default:
+		if e.Error != "" {
+			return e.URL + " (" + e.Error + ")"
+		}
+		return e.URL
+	}
+}
+
+func (d *NetworkInspectorDialog) MinSize() fyne.Size {
+	return fyne.NewSize(900, 550)
+}
+
+func (d *NetworkInspectorDialog) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(d.container)
+}
@@ -7,6 +7,7 @@ import (
 	"fyne.io/fyne/v2/lang"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+	"github.com/dweymouth/supersonic/backend"
 	"github.com/dweymouth/supersonic/backend/mediaprovider"
 	myTheme "github.com/dweymouth/supersonic/ui/theme"
 	"github.com/dweymouth/supersonic/ui/util"
@@ -16,6 +17,7 @@ type QuickSearch struct {
 	SearchDialog *SearchDialog
 	results      []*mediaprovider.SearchResult
 	mp           mediaprovider.MediaProvider
+	index        *backend.SearchIndex
 
 	OnPlay          func(t mediaprovider.ContentType, id string, item any, shuffle bool)
 	OnAddToQueue    func(t mediaprovider.ContentType, id string, item any, next bool)
@@ -25,18 +27,24 @@ type QuickSearch struct {
 	OnDownload      func(track *mediaprovider.Track)
 	OnShare         func(trackID string)
 	OnPlaySongRadio func(track *mediaprovider.Track)
+	OnCustomRadio   func(track *mediaprovider.Track)
 	OnShowTrackInfo func(track *mediaprovider.Track)
 }
 
-func NewQuickSearch(mp mediaprovider.MediaProvider, im util.ImageFetcher) *QuickSearch {
-	q := &QuickSearch{mp: mp}
+// NewQuickSearch returns a new QuickSearch dialog, which searches the local
+// search index if it is non-nil and ready, and the server otherwise.
+func NewQuickSearch(mp mediaprovider.MediaProvider, index *backend.SearchIndex, im util.ImageFetcher) *QuickSearch {
+	q := &QuickSearch{mp: mp, index: index}
 	q.SearchDialog = NewSearchDialog(im, lang.L("Search Everywhere"), lang.L("Close"), q.onSearched)
 	q.SearchDialog.OnShowContextMenu = q.showMenu
+	q.SearchDialog.ShowQueryHints = true
 	return q
 }
 
 func (q *QuickSearch) onSearched(query string) []*mediaprovider.SearchResult {
-	if query != "" {
+	if query != "" && q.index != nil && q.index.Ready() {
+		q.results = q.index.Search(query, 50)
+	} else if query != "" {
 		if res, err := q.mp.SearchAll(query, 50); err != nil {
 			q.results = nil
 			log.Printf("Error searching: %s", err.Error())
@@ -78,6 +86,9 @@ func (q *QuickSearch) showMenu(idx int, pos fyne.Position) {
 		menu.OnPlaySongRadio = func() {
 			q.OnPlaySongRadio(item.(*mediaprovider.Track))
 		}
+		menu.OnCustomRadio = func() {
+			q.OnCustomRadio(item.(*mediaprovider.Track))
+		}
 		menu.OnShowInfo = func() {
 			q.OnShowTrackInfo(item.(*mediaprovider.Track))
 		}
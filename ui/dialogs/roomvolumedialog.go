@@ -0,0 +1,103 @@
+package dialogs
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/lang"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+	"github.com/dweymouth/supersonic/backend/player"
+	"github.com/dweymouth/supersonic/ui/widgets"
+)
+
+// RoomVolumeDialog shows a volume control for each room of a multi-room player.
+type RoomVolumeDialog struct {
+	widget.BaseWidget
+
+	OnDismiss       func()
+	OnSetRoomVolume func(room player.Room, vol int)
+
+	rooms       []player.Room
+	volControls map[string]*widgets.VolumeControl
+	roomsForm   *fyne.Container
+	emptyLabel  *widget.Label
+	container   *fyne.Container
+}
+
+func NewRoomVolumeDialog(rooms []player.Room) *RoomVolumeDialog {
+	d := &RoomVolumeDialog{volControls: make(map[string]*widgets.VolumeControl)}
+	d.ExtendBaseWidget(d)
+
+	title := widget.NewRichTextWithText(lang.L("Room volumes"))
+	title.Segments[0].(*widget.TextSegment).Style.TextStyle.Bold = true
+	closeBtn := widget.NewButton(lang.L("Close"), func() {
+		if d.OnDismiss != nil {
+			d.OnDismiss()
+		}
+	})
+
+	d.roomsForm = container.New(layout.NewFormLayout())
+	d.emptyLabel = widget.NewLabel(lang.L("No rooms are connected"))
+	d.container = container.NewBorder(
+		/*top*/ container.NewHBox(layout.NewSpacer(), title, layout.NewSpacer()),
+		/*bottom*/ container.NewVBox(
+			widget.NewSeparator(),
+			container.NewHBox(layout.NewSpacer(), closeBtn),
+		),
+		/*left/right*/ nil, nil,
+		/*center*/ container.NewVScroll(container.NewVBox(d.emptyLabel, d.roomsForm)),
+	)
+	d.SetRooms(rooms)
+	return d
+}
+
+// SetRooms updates the dialog for rooms that have connected,
+// disconnected or changed volume.
+func (d *RoomVolumeDialog) SetRooms(rooms []player.Room) {
+	sameRooms := len(rooms) == len(d.rooms)
+	for i := 0; sameRooms && i < len(rooms); i++ {
+		sameRooms = rooms[i].ID == d.rooms[i].ID && rooms[i].Name == d.rooms[i].Name
+	}
+	d.rooms = rooms
+	if sameRooms && len(d.volControls) > 0 {
+		for _, r := range rooms {
+			d.volControls[r.ID].SetVolume(displayedRoomVolume(r))
+		}
+		return
+	}
+
+	d.roomsForm.RemoveAll()
+	clear(d.volControls)
+	for i, r := range rooms {
+		vc := widgets.NewVolumeControl(displayedRoomVolume(r))
+		vc.OnSetVolume = func(vol int) {
+			if d.OnSetRoomVolume != nil {
+				d.OnSetRoomVolume(d.rooms[i], vol)
+			}
+		}
+		d.volControls[r.ID] = vc
+		d.roomsForm.Add(widget.NewLabel(r.Name))
+		d.roomsForm.Add(vc)
+	}
+	if len(rooms) == 0 {
+		d.emptyLabel.Show()
+	} else {
+		d.emptyLabel.Hide()
+	}
+	d.roomsForm.Refresh()
+}
+
+func displayedRoomVolume(r player.Room) int {
+	if r.Muted {
+		return 0
+	}
+	return r.Volume
+}
+
+func (d *RoomVolumeDialog) MinSize() fyne.Size {
+	return fyne.NewSize(400, 250)
+}
+
+func (d *RoomVolumeDialog) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(d.container)
+}
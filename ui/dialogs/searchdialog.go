@@ -26,6 +26,10 @@ type SearchDialog struct {
 
 	PlaceholderText string
 
+	// If true, hints on the search query syntax (see helpers.SearchQuery)
+	// are shown below the search entry, and Tab completes filter fields and values
+	ShowQueryHints bool
+
 	// Additional item that can be placed to the left
 	// of the dismiss buttons
 	ActionItem fyne.CanvasObject
@@ -41,6 +45,7 @@ type SearchDialog struct {
 	selectedIndex int
 
 	searchEntry *searchEntry
+	queryHint   *widget.Label
 	loadingDots *widgets.LoadingDots
 	list        *widget.List
 	dialogTitle string
@@ -66,7 +71,13 @@ func NewSearchDialog(im util.ImageFetcher, title, dismissBtn string, onSearched
 	se.OnTypedDown = sd.moveSelectionDown
 	se.OnTypedUp = sd.moveSelectionUp
 	se.OnTypedEscape = sd.onDismiss
+	se.OnTextChanged = sd.updateQueryHint
 	sd.searchEntry = se
+	sd.queryHint = widget.NewLabel("")
+	sd.queryHint.SizeName = theme.SizeNameCaptionText
+	sd.queryHint.Importance = widget.LowImportance
+	sd.queryHint.Wrapping = fyne.TextWrapWord
+	sd.queryHint.Hide()
 	sd.list = widget.NewList(
 		func() int {
 			sd.resultsMutex.RLock()
@@ -102,6 +113,7 @@ func (sd *SearchDialog) SearchQuery() string {
 
 func (sd *SearchDialog) Show() {
 	sd.BaseWidget.Show()
+	sd.updateQueryHint(sd.searchEntry.Text)
 	sd.onSearched("")
 }
 
@@ -112,6 +124,20 @@ func (sd *SearchDialog) Refresh() {
 	sd.BaseWidget.Refresh()
 }
 
+func (sd *SearchDialog) updateQueryHint(query string) {
+	if !sd.ShowQueryHints {
+		return
+	}
+	hint, completion := searchQueryHint(query)
+	sd.searchEntry.completion = completion
+	if completion != "" {
+		hint += "  " + lang.L("(Tab to complete)")
+	}
+	sd.queryHint.SetText(hint)
+	sd.queryHint.Hidden = hint == ""
+	sd.queryHint.Refresh()
+}
+
 func (sd *SearchDialog) onDismiss() {
 	if sd.OnDismiss != nil {
 		sd.OnDismiss()
@@ -192,7 +218,9 @@ func (sd *SearchDialog) CreateRenderer() fyne.WidgetRenderer {
 		container.NewBorder(
 			container.NewVBox(title,
 				container.New(layout.NewCustomPaddedLayout(0, 0, 2, 2),
-					sd.searchEntry)),
+					sd.searchEntry),
+				container.New(layout.NewCustomPaddedLayout(-theme.Padding(), -theme.Padding(), 2, 2),
+					sd.queryHint)),
 			container.NewVBox(widget.NewSeparator(), bottomRow),
 			nil, nil,
 			container.New(layout.NewCustomPaddedLayout(0, 0, 4, 4), sd.list)),
@@ -368,17 +396,36 @@ type searchEntry struct {
 	OnTypedUp     func()
 	OnTypedDown   func()
 	OnTypedEscape func()
+	OnTextChanged func(string)
+
+	// text appended to complete the query when Tab is typed
+	completion string
 }
 
 func newSearchEntry() *searchEntry {
 	q := &searchEntry{}
 	q.ExtendBaseWidget(q)
 	q.SearchEntry.Init()
+	onChanged := q.Entry.OnChanged
+	q.Entry.OnChanged = func(s string) {
+		onChanged(s)
+		if q.OnTextChanged != nil {
+			q.OnTextChanged(s)
+		}
+	}
 	return q
 }
 
+func (q *searchEntry) AcceptsTab() bool {
+	return q.completion != ""
+}
+
 func (q *searchEntry) TypedKey(e *fyne.KeyEvent) {
 	switch {
+	case e.Name == fyne.KeyTab && q.completion != "":
+		q.SetText(q.Text + q.completion)
+		q.CursorColumn = len([]rune(q.Text))
+		q.Refresh()
 	case e.Name == fyne.KeyUp && q.OnTypedUp != nil:
 		q.OnTypedUp()
 	case e.Name == fyne.KeyDown && q.OnTypedDown != nil:
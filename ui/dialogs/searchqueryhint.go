@@ -0,0 +1,67 @@
+package dialogs
+
+import (
+	"strings"
+
+	"fyne.io/fyne/v2/lang"
+	"github.com/dweymouth/supersonic/backend/mediaprovider/helpers"
+)
+
+// searchQueryHint returns a hint on the search query syntax for the query
+// being typed, and the text to append to the query to complete the field
+// or value being typed, if there is a single possible completion.
+func searchQueryHint(query string) (hint, completion string) {
+	if query == "" || strings.HasSuffix(query, " ") || strings.Count(query, `"`)%2 == 1 {
+		return lang.L("Filter with artist:, album:, genre:, year:, rating:, is: and type:. Use \"quotes\" for phrases and - to exclude"), ""
+	}
+	tok := query[strings.LastIndex(query, " ")+1:]
+	tok = strings.TrimPrefix(tok, "-")
+
+	field, value, hasColon := strings.Cut(strings.ToLower(tok), ":")
+	if !hasColon {
+		var matches []string
+		for _, f := range helpers.SearchFields {
+			if strings.HasPrefix(f, field) {
+				matches = append(matches, f+":")
+			}
+		}
+		if len(matches) == 0 || field == "" {
+			return "", ""
+		}
+		if len(matches) == 1 {
+			completion = matches[0][len(field):]
+		}
+		return strings.Join(matches, "  "), completion
+	}
+
+	completeValue := func(values []string) (string, string) {
+		var matches []string
+		for _, v := range values {
+			if strings.HasPrefix(v, value) {
+				matches = append(matches, field+":"+v)
+			}
+		}
+		if len(matches) == 1 && len(value) < len(matches[0])-len(field)-1 {
+			return matches[0], matches[0][len(field)+1+len(value):]
+		}
+		return strings.Join(matches, "  "), ""
+	}
+
+	switch field {
+	case helpers.SearchFieldArtist:
+		return lang.L("Artist name, e.g. artist:\"pink floyd\""), ""
+	case helpers.SearchFieldAlbum:
+		return lang.L("Album name, e.g. album:\"dark side\""), ""
+	case helpers.SearchFieldGenre:
+		return lang.L("Genre name, e.g. genre:jazz"), ""
+	case helpers.SearchFieldYear:
+		return lang.L("Year or range, e.g. year:1994, year:1990..1999, year:>=2000"), ""
+	case helpers.SearchFieldRating:
+		return lang.L("Track rating, e.g. rating:5, rating:>=3, rating:1..2"), ""
+	case helpers.SearchFieldIs:
+		return completeValue(helpers.SearchIsValues)
+	case helpers.SearchFieldType:
+		return completeValue(helpers.SearchTypeValues)
+	}
+	return "", ""
+}
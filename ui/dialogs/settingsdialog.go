@@ -34,13 +34,18 @@ type SettingsDialog struct {
 
 	OnReplayGainSettingsChanged    func()
 	OnAudioExclusiveSettingChanged func()
+	OnBitPerfectSettingChanged     func()
 	OnPauseFadeSettingsChanged     func()
+	OnShuffleModeSettingChanged    func()
+	OnDLNARendererSettingChanged   func()
 	OnAudioDeviceSettingChanged    func()
 	OnThemeSettingChanged          func()
 	OnDismiss                      func()
 	OnEqualizerSettingsChanged     func()
 	OnPageNeedsRefresh             func()
 	OnClearCaches                  func()
+	OnManageCredentials            func()
+	OnShowNetworkInspector         func()
 
 	config          *backend.Config
 	audioDevices    []mpv.AudioDevice
@@ -54,6 +59,11 @@ type SettingsDialog struct {
 
 	clientDecidesScrobble bool
 
+	// widgets for the settings remembered per audio device
+	audioExclusive   *widget.Check
+	equalizerEnabled *widget.Check
+	graphicEQ        *GraphicEqualizer
+
 	content fyne.CanvasObject
 }
 
@@ -329,9 +339,15 @@ func (s *SettingsDialog) createPlaybackTab(isLocalPlayer, isReplayGainPlayer boo
 	transcodeCodec := widget.NewSelectWithData([]string{"opus", "mp3"}, binding.BindString(&s.config.Transcoding.Codec))
 	transcodeBitRate := widget.NewSelectWithData([]string{"96", "128", "160", "192", "256", "320"},
 		binding.IntToString(binding.BindInt(&s.config.Transcoding.MaxBitRateKBPS)))
+	adaptiveTranscode := widget.NewCheck(lang.L("Adjust bit rate to network speed"), func(b bool) {
+		s.config.Transcoding.Adaptive = b
+		s.setRestartRequired()
+	})
+	adaptiveTranscode.Checked = s.config.Transcoding.Adaptive
 	if !s.config.Transcoding.RequestTranscode {
 		transcodeCodec.Disable()
 		transcodeBitRate.Disable()
+		adaptiveTranscode.Disable()
 	}
 
 	var transcode *widget.Check
@@ -348,9 +364,11 @@ func (s *SettingsDialog) createPlaybackTab(isLocalPlayer, isReplayGainPlayer boo
 			disableTranscode.SetChecked(false)
 			transcodeCodec.Enable()
 			transcodeBitRate.Enable()
+			adaptiveTranscode.Enable()
 		} else {
 			transcodeCodec.Disable()
 			transcodeBitRate.Disable()
+			adaptiveTranscode.Disable()
 		}
 	})
 	transcode.Checked = s.config.Transcoding.RequestTranscode
@@ -371,6 +389,7 @@ func (s *SettingsDialog) createPlaybackTab(isLocalPlayer, isReplayGainPlayer boo
 		if s.OnAudioDeviceSettingChanged != nil {
 			s.OnAudioDeviceSettingChanged()
 		}
+		s.reloadDeviceSettings()
 	}
 
 	rGainOpts := []string{lang.L("None"), lang.L("Album"), lang.L("Track"), lang.L("Auto")}
@@ -432,6 +451,15 @@ func (s *SettingsDialog) createPlaybackTab(isLocalPlayer, isReplayGainPlayer boo
 		s.onAudioExclusiveSettingsChanged()
 	})
 	audioExclusive.Checked = s.config.LocalPlayback.AudioExclusive
+	s.audioExclusive = audioExclusive
+
+	bitPerfect := widget.NewCheck(lang.L("Bit-perfect mode"), func(checked bool) {
+		s.config.LocalPlayback.BitPerfect = checked
+		if s.OnBitPerfectSettingChanged != nil {
+			s.OnBitPerfectSettingChanged()
+		}
+	})
+	bitPerfect.Checked = s.config.LocalPlayback.BitPerfect
 
 	pauseFade := widget.NewCheck(lang.L("Fade out on pause"), func(checked bool) {
 		s.config.LocalPlayback.PauseFade = checked
@@ -441,9 +469,36 @@ func (s *SettingsDialog) createPlaybackTab(isLocalPlayer, isReplayGainPlayer boo
 	})
 	pauseFade.Checked = s.config.LocalPlayback.PauseFade
 
+	shuffleModeNames := []string{lang.L("Random"), lang.L("Favor favorites"), lang.L("Rediscover forgotten tracks")}
+	onShuffleModeChanged := func() {
+		if s.OnShuffleModeSettingChanged != nil {
+			s.OnShuffleModeSettingChanged()
+		}
+	}
+	shuffleMode := widget.NewSelect(shuffleModeNames, func(_ string) {})
+	shuffleMode.SetSelectedIndex(max(slices.Index(backend.ShuffleModes, s.config.Playback.ShuffleMode), 0))
+	shuffleMode.OnChanged = func(_ string) {
+		s.config.Playback.ShuffleMode = backend.ShuffleModes[shuffleMode.SelectedIndex()]
+		onShuffleModeChanged()
+	}
+	shuffleArtistSpread := widget.NewCheck(lang.L("Avoid same artist twice in a row"), func(checked bool) {
+		s.config.Playback.ShuffleArtistSpread = checked
+		onShuffleModeChanged()
+	})
+	shuffleArtistSpread.Checked = s.config.Playback.ShuffleArtistSpread
+
+	dlnaRenderer := widget.NewCheck(lang.L("Allow other apps to cast to Supersonic (DLNA)"), func(checked bool) {
+		s.config.LocalPlayback.DLNARendererEnabled = checked
+		if s.OnDLNARendererSettingChanged != nil {
+			s.OnDLNARendererSettingChanged()
+		}
+	})
+	dlnaRenderer.Checked = s.config.LocalPlayback.DLNARendererEnabled
+
 	if !isLocalPlayer {
 		deviceSelect.Disable()
 		audioExclusive.Disable()
+		bitPerfect.Disable()
 		pauseFade.Disable()
 	}
 	if !isReplayGainPlayer {
@@ -458,11 +513,13 @@ func (s *SettingsDialog) createPlaybackTab(isLocalPlayer, isReplayGainPlayer boo
 			container.New(layout.NewFormLayout(),
 				widget.NewLabel(lang.L("Audio device")), container.NewBorder(nil, nil, nil, util.NewHSpace(70), deviceSelect),
 				layout.NewSpacer(), audioExclusive,
+				layout.NewSpacer(), bitPerfect,
 			)),
 		pauseFade,
+		dlnaRenderer,
 		s.newSectionSeparator(),
 		disableTranscode,
-		container.NewHBox(transcode, transcodeCodec, transcodeBitRate),
+		container.NewHBox(transcode, transcodeCodec, transcodeBitRate, adaptiveTranscode),
 		s.newSectionSeparator(),
 		widget.NewLabelWithStyle("ReplayGain", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
 		container.New(layout.NewFormLayout(),
@@ -471,12 +528,21 @@ func (s *SettingsDialog) createPlaybackTab(isLocalPlayer, isReplayGainPlayer boo
 			widget.NewLabel(lang.L("Prevent clipping")), preventClipping,
 		),
 		s.newSectionSeparator(),
+		widget.NewLabelWithStyle(lang.L("Shuffle"), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		container.New(layout.NewFormLayout(),
+			widget.NewLabel(lang.L("Shuffle mode")), container.NewGridWithColumns(2, shuffleMode),
+			layout.NewSpacer(), shuffleArtistSpread,
+		),
+		s.newSectionSeparator(),
 		widget.NewLabelWithStyle(lang.L("When enqueuing random"), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
 		widget.NewCheckWithData(lang.L("Skip one-star tracks"), binding.BindBool(&s.config.Playback.SkipOneStarWhenShuffling)),
 		container.NewBorder(nil, nil,
 			widget.NewLabel(lang.L("Skip tracks with keyword")), nil,
 			widget.NewEntryWithData(binding.BindString(&s.config.Playback.SkipKeywordWhenShuffling)),
 		),
+		s.newSectionSeparator(),
+		widget.NewLabelWithStyle(lang.L("Autoplay"), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		newRadioConstraintsForm(&s.config.Playback.AutoplayRadio),
 	))
 }
 
@@ -495,6 +561,7 @@ func (s *SettingsDialog) createEqualizerTab(eqBands []string) *container.TabItem
 		}
 	})
 	enabled.Checked = s.config.LocalPlayback.EqualizerEnabled
+	s.equalizerEnabled = enabled
 	geq := NewGraphicEqualizer(s.config.LocalPlayback.EqualizerPreamp,
 		eqBands,
 		s.config.LocalPlayback.GraphicEqualizerBands,
@@ -588,10 +655,34 @@ func (s *SettingsDialog) createEqualizerTab(eqBands []string) *container.TabItem
 		geq.SetProfileLabel(s.config.LocalPlayback.AutoEQProfileName)
 	}
 
+	s.graphicEQ = geq
+
 	cont := container.NewBorder(enabled, nil, nil, nil, geq)
 	return container.NewTabItem(lang.L("Equalizer"), cont)
 }
 
+// reloadDeviceSettings updates the widgets of the settings that are
+// remembered per audio device, after switching to another device.
+func (s *SettingsDialog) reloadDeviceSettings() {
+	lp := &s.config.LocalPlayback
+	if s.audioExclusive != nil {
+		s.audioExclusive.SetChecked(lp.AudioExclusive)
+	}
+	if s.graphicEQ == nil {
+		return // not using an equalizer player
+	}
+	s.equalizerEnabled.SetChecked(lp.EqualizerEnabled)
+	s.graphicEQ.applyPreset(backend.EQPreset{
+		Type:   lp.EqualizerType,
+		Preamp: lp.EqualizerPreamp,
+		Bands:  slices.Clone(lp.GraphicEqualizerBands),
+	})
+	s.graphicEQ.ClearLoadedPresetState()
+	s.graphicEQ.ClearPresetSelection()
+	s.graphicEQ.setActivePreset(lp.ActiveEQPresetName)
+	s.graphicEQ.SetProfileLabel(lp.AutoEQProfileName)
+}
+
 func (s *SettingsDialog) openAutoEQBrowser(geq *GraphicEqualizer, debouncer func()) {
 	if s.autoEQManager == nil {
 		log.Printf("ERROR: AutoEQ manager not available (nil)")
@@ -838,13 +929,48 @@ func (s *SettingsDialog) createAdvancedTab() *container.TabItem {
 	preventScreensaver := widget.NewCheckWithData(lang.L("Prevent screensaver on Now Playing page"),
 		binding.BindBool(&s.config.Application.PreventScreensaverOnNowPlayingPage))
 
+	searchIndex := widget.NewCheck(lang.L("Keep a local search index for instant search"), func(b bool) {
+		s.config.Application.EnableLocalSearchIndex = b
+		s.setRestartRequired()
+	})
+	searchIndex.Checked = s.config.Application.EnableLocalSearchIndex
+
+	credentialFile := widget.NewCheck(lang.L("Save credentials in an encrypted file instead of the system keyring"), func(b bool) {
+		s.config.Application.UseCredentialFile = b
+		s.setRestartRequired()
+	})
+	credentialFile.Checked = s.config.Application.UseCredentialFile
+	manageCredentials := widget.NewButton(lang.L("Manage saved credentials"), func() {
+		if s.OnManageCredentials != nil {
+			s.OnManageCredentials()
+		}
+	})
+	credentialsCfg := container.NewHBox(credentialFile, layout.NewSpacer(), manageCredentials)
+	if !s.config.Application.EnablePasswordStorage {
+		credentialFile.Disable()
+		manageCredentials.Disable()
+	}
+	networkInspector := widget.NewButton(lang.L("Network inspector")+"...", func() {
+		if s.OnShowNetworkInspector != nil {
+			s.OnShowNetworkInspector()
+		}
+	})
+	networkCfg := container.NewHBox(
+		widget.NewLabel(lang.L("Record the requests sent to the server for bug reports")),
+		layout.NewSpacer(),
+		networkInspector,
+	)
+
 	return container.NewTabItem(lang.L("Advanced"), container.NewVBox(
 		multi,
 		update,
 		lrclib,
 		osMediaAPIs,
 		preventScreensaver,
+		searchIndex,
+		credentialsCfg,
 		imgCacheCfg,
+		networkCfg,
 	))
 }
 
@@ -11,8 +11,10 @@ import (
 	fynetooltip "github.com/dweymouth/fyne-tooltip"
 	"github.com/dweymouth/supersonic/backend"
 	"github.com/dweymouth/supersonic/backend/mediaprovider"
+	"github.com/dweymouth/supersonic/backend/player/mpv"
 	"github.com/dweymouth/supersonic/backend/windows"
 	"github.com/dweymouth/supersonic/res"
+	"github.com/dweymouth/supersonic/sharedutil"
 	"github.com/dweymouth/supersonic/ui/browsing"
 	uicontainer "github.com/dweymouth/supersonic/ui/container"
 	"github.com/dweymouth/supersonic/ui/controller"
@@ -20,6 +22,7 @@ import (
 	"github.com/dweymouth/supersonic/ui/shortcuts"
 	myTheme "github.com/dweymouth/supersonic/ui/theme"
 	"github.com/dweymouth/supersonic/ui/util"
+	"github.com/dweymouth/supersonic/ui/visualizations"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -120,12 +123,31 @@ func NewMainWindow(fyneApp fyne.App, appName, displayAppName, appVersion string,
 		})
 	})
 	app.PlaybackManager.OnQueueChange(func() {
-		fyne.Do(func() { m.Sidebar.SetQueueTracks(app.PlaybackManager.GetActivePlayQueue()) })
+		fyne.Do(func() {
+			m.Sidebar.SetQueueTracks(app.PlaybackManager.GetActivePlayQueue(), app.PlaybackManager.GetUpNext())
+		})
+	})
+	app.AudioDeviceManager.OnDeviceChange(func(dev mpv.AudioDevice) {
+		fyne.Do(func() {
+			m.ToastOverlay.ShowSuccessToast(fmt.Sprintf(lang.L("Audio output switched to %s"), dev.Description))
+		})
 	})
 	app.ServerManager.OnServerConnected(func(conf *backend.ServerConfig) {
+		status := app.ServerManager.ConnectionStatus()
+		fyne.Do(func() { m.Toolbar.ConnectionStatus.SetStatus(status) })
 		go m.RunOnServerConnectedTasks(conf, app, displayAppName)
 	})
+	app.ServerManager.OnConnectionStatusChanged(func(status backend.ConnectionStatus) {
+		fyne.Do(func() {
+			m.Toolbar.ConnectionStatus.SetStatus(status)
+			m.ToastOverlay.SetSuppressErrors(status.State == backend.ConnectionReconnecting)
+		})
+	})
+	m.Toolbar.ConnectionStatus.OnTapped = app.ServerManager.CheckConnection
 	app.ServerManager.OnLogout(func() {
+		m.Toolbar.ConnectionStatus.SetStatus(backend.ConnectionStatus{})
+		m.ToastOverlay.SetSuppressErrors(false)
+		m.saveServerUIState()
 		m.Toolbar.DisableNavigationButtons()
 		m.BrowsingPane.SetPage(nil)
 		m.BrowsingPane.ClearHistory()
@@ -136,10 +158,15 @@ func NewMainWindow(fyneApp fyne.App, appName, displayAppName, appVersion string,
 	m.Toolbar.AddSettingsSubmenu(lang.L("Select Library"), myTheme.LibraryIcon, fyne.NewMenu("",
 		fyne.NewMenuItem(lang.L("All Libraries"), func() { /* dummy - will get replaced on server login */ })))
 	m.Toolbar.AddSettingsMenuItem(lang.L("Rescan Library"), theme.ViewRefreshIcon(), func() { app.ServerManager.Server.RescanLibrary() })
+	m.Toolbar.AddSettingsMenuItem(lang.L("Find Duplicate Tracks"), theme.SearchIcon(), m.Controller.DoFindDuplicateTracksWorkflow)
 	m.Toolbar.AddSettingsMenuSeparator()
+	m.Toolbar.AddSettingsMenuItem(lang.L("Start custom radio")+"...", myTheme.RadioIcon, func() { m.Controller.ShowCustomRadioDialog(nil) })
 	m.Toolbar.AddSettingsSubmenu(lang.L("Visualizations"), myTheme.VisualizationIcon,
 		fyne.NewMenu("", []*fyne.MenuItem{
 			fyne.NewMenuItem(lang.L("Peak Meter"), m.Controller.ShowPeakMeter),
+			fyne.NewMenuItem(lang.L("Spectrum"), func() { m.Controller.ShowVisualization(visualizations.VisualizationSpectrum) }),
+			fyne.NewMenuItem(lang.L("Goniometer"), func() { m.Controller.ShowVisualization(visualizations.VisualizationGoniometer) }),
+			fyne.NewMenuItem(lang.L("Spectrogram"), func() { m.Controller.ShowVisualization(visualizations.VisualizationSpectrogram) }),
 		}...))
 	m.Toolbar.AddSettingsMenuSeparator()
 	m.Toolbar.AddSettingsMenuItem(lang.L("Check for Updates"), theme.DownloadIcon(), func() {
@@ -362,7 +389,9 @@ func (m *MainWindow) RunOnServerConnectedTasks(serverConf *backend.ServerConfig,
 
 	fyne.Do(func() {
 		m.Toolbar.EnableNavigationButtons()
-		m.Router.NavigateTo(m.StartupPage())
+		if !m.restoreServerUIState(serverConf) {
+			m.Router.NavigateTo(m.StartupPage())
+		}
 		_, canRate := m.App.ServerManager.Server.(mediaprovider.SupportsRating)
 		m.BottomPanel.NowPlaying.DisableRating = !canRate
 
@@ -403,6 +432,56 @@ func (m *MainWindow) RunOnServerConnectedTasks(serverConf *backend.ServerConfig,
 	m.alreadyConnected = true
 }
 
+// saves the browsing history and page settings of the connected server
+func (m *MainWindow) saveServerUIState() {
+	conf := m.App.ServerManager.CurrentServerConfig()
+	if conf == nil {
+		return
+	}
+	entries, curIdx := m.BrowsingPane.HistoryState()
+	conf.UIState.History = sharedutil.MapSlice(entries, func(e browsing.HistoryEntry) backend.SavedRoute {
+		return backend.SavedRoute{Page: e.Route.Page.String(), Arg: e.Route.Arg, ScrollPos: e.ScrollPos}
+	})
+	conf.UIState.HistoryIdx = curIdx
+	albumsPage := m.App.Config.AlbumsPage
+	conf.UIState.AlbumsPage = &albumsPage
+	artistsPage := m.App.Config.ArtistsPage
+	conf.UIState.ArtistsPage = &artistsPage
+}
+
+// restores the page settings of a newly connected server, and its
+// browsing history if any. Returns false if there was no history to restore.
+func (m *MainWindow) restoreServerUIState(conf *backend.ServerConfig) bool {
+	state := conf.UIState
+	if state.AlbumsPage != nil {
+		m.App.Config.AlbumsPage = *state.AlbumsPage
+	}
+	if state.ArtistsPage != nil {
+		m.App.Config.ArtistsPage = *state.ArtistsPage
+	}
+
+	var entries []browsing.HistoryEntry
+	curIdx := -1
+	for i, r := range state.History {
+		page := controller.ParsePageName(r.Page)
+		if page == controller.None {
+			continue // unknown page, e.g. from a different app version
+		}
+		if i == state.HistoryIdx {
+			curIdx = len(entries)
+		}
+		entries = append(entries, browsing.HistoryEntry{
+			Route:     controller.Route{Page: page, Arg: r.Arg},
+			ScrollPos: r.ScrollPos,
+		})
+	}
+	if curIdx < 0 {
+		return false
+	}
+	m.BrowsingPane.RestoreHistoryState(entries, curIdx, m.Router.CreatePage)
+	return true
+}
+
 func (m *MainWindow) SetupSystemTrayMenu(appName string, fyneApp fyne.App) {
 	if desk, ok := fyneApp.(desktop.App); ok {
 		menu := fyne.NewMenu(appName,
@@ -589,6 +668,7 @@ func (m *MainWindow) Quit() {
 }
 
 func (m *MainWindow) SaveWindowSettings() {
+	m.saveServerUIState()
 	util.SaveWindowSize(m.Window,
 		&m.App.Config.Application.WindowWidth,
 		&m.App.Config.Application.WindowHeight)
@@ -79,8 +79,9 @@ func (s *Sidebar) SetSelectedIndex(idx int) {
 	s.tabs.SelectIndex(idx)
 }
 
-func (s *Sidebar) SetQueueTracks(items []mediaprovider.MediaItem) {
+func (s *Sidebar) SetQueueTracks(items, upNext []mediaprovider.MediaItem) {
 	s.queueList.SetItems(items)
+	s.queueList.SetUpNextItems(upNext)
 }
 
 func (s *Sidebar) SetNowPlaying(item mediaprovider.MediaItem) {
@@ -28,6 +28,7 @@ type ToastOverlay struct {
 	currentToast     *toast
 	currentToastAnim *fyne.Animation
 	dismissCancel    context.CancelFunc
+	suppressErrors   bool
 
 	container *fyne.Container
 }
@@ -44,9 +45,18 @@ func (t *ToastOverlay) ShowSuccessToast(message string) {
 }
 
 func (t *ToastOverlay) ShowErrorToast(message string) {
+	if t.suppressErrors {
+		return
+	}
 	t.showToast(true, message)
 }
 
+// SetSuppressErrors sets whether error toasts are suppressed, e.g. while
+// reconnecting to the server, when most requests are expected to fail.
+func (t *ToastOverlay) SetSuppressErrors(suppress bool) {
+	t.suppressErrors = suppress
+}
+
 func (t *ToastOverlay) showToast(isErr bool, message string) {
 	t.cancelPreviousToast()
 
@@ -11,6 +11,7 @@ import (
 	"github.com/dweymouth/supersonic/ui/controller"
 	"github.com/dweymouth/supersonic/ui/layouts"
 	myTheme "github.com/dweymouth/supersonic/ui/theme"
+	"github.com/dweymouth/supersonic/ui/widgets"
 
 	ttwidget "github.com/dweymouth/fyne-tooltip/widget"
 )
@@ -29,6 +30,8 @@ type Toolbar struct {
 	navBtnsPageMap   map[controller.PageName]fyne.Resource
 	radioBtn         fyne.CanvasObject
 
+	ConnectionStatus *widgets.ConnectionStatus
+
 	quickSearchBtn *ttwidget.Button
 	sidebarBtn     *ttwidget.Button
 	settingsBtn    *ttwidget.Button
@@ -57,6 +60,7 @@ func NewToolbar(browsingPane *browsing.BrowsingPane, navigateFn func(controller.
 
 	t.setupNavigationButtons(navigateFn)
 
+	t.ConnectionStatus = widgets.NewConnectionStatus()
 	t.quickSearchBtn = ttwidget.NewButtonWithIcon("", theme.SearchIcon(), showSearchFn)
 	t.quickSearchBtn.SetToolTip(lang.L("Search Everywhere"))
 	t.sidebarBtn = ttwidget.NewButtonWithIcon("", myTheme.SidebarIcon, toggleSidebarFn)
@@ -141,7 +145,7 @@ func (t *Toolbar) CreateRenderer() fyne.WidgetRenderer {
 	content := container.New(layouts.NewLeftMiddleRightLayout(0, 0),
 		container.NewHBox(t.home, t.back, t.forward, t.reload),
 		t.navBtnsContainer,
-		container.NewHBox(layout.NewSpacer(), t.quickSearchBtn, t.sidebarBtn, t.settingsBtn))
+		container.NewHBox(layout.NewSpacer(), t.ConnectionStatus, t.quickSearchBtn, t.sidebarBtn, t.settingsBtn))
 	return widget.NewSimpleRenderer(content)
 }
 
@@ -12,11 +12,13 @@ type TrackContextMenu struct {
 	ratingSubmenu     *fyne.MenuItem
 	shareMenuItem     *fyne.MenuItem
 	songRadioMenuItem *fyne.MenuItem
+	customRadioItem   *fyne.MenuItem
 	infoMenuItem      *fyne.MenuItem
 
 	OnPlay          func(shuffle bool)
 	OnAddToQueue    func(next bool)
 	OnPlaySongRadio func()
+	OnCustomRadio   func()
 	OnDownload      func()
 	OnAddToPlaylist func()
 	OnShowInfo      func()
@@ -37,6 +39,12 @@ func NewTrackContextMenu(disablePlaybackMenu bool, auxItems []*fyne.MenuItem) *T
 		}
 	})
 	tcm.songRadioMenuItem.Icon = myTheme.RadioIcon
+	tcm.customRadioItem = fyne.NewMenuItem(lang.L("Start custom radio")+"...", func() {
+		if tcm.OnCustomRadio != nil {
+			tcm.OnCustomRadio()
+		}
+	})
+	tcm.customRadioItem.Icon = myTheme.RadioIcon
 	if !disablePlaybackMenu {
 		play := fyne.NewMenuItem(lang.L("Play"), func() {
 			if tcm.OnPlay != nil {
@@ -63,7 +71,7 @@ func NewTrackContextMenu(disablePlaybackMenu bool, auxItems []*fyne.MenuItem) *T
 		})
 		add.Icon = theme.ContentAddIcon()
 		tcm.menu.Items = append(tcm.menu.Items,
-			play, shuffle, playNext, add, tcm.songRadioMenuItem)
+			play, shuffle, playNext, add, tcm.songRadioMenuItem, tcm.customRadioItem)
 	}
 	playlist := fyne.NewMenuItem(lang.L("Add to playlist")+"...", func() {
 		if tcm.OnAddToPlaylist != nil {
@@ -105,7 +113,7 @@ func NewTrackContextMenu(disablePlaybackMenu bool, auxItems []*fyne.MenuItem) *T
 	tcm.shareMenuItem.Icon = myTheme.ShareIcon
 	tcm.menu.Items = append(tcm.menu.Items, tcm.shareMenuItem)
 	if disablePlaybackMenu {
-		tcm.menu.Items = append(tcm.menu.Items, tcm.songRadioMenuItem)
+		tcm.menu.Items = append(tcm.menu.Items, tcm.songRadioMenuItem, tcm.customRadioItem)
 	}
 	tcm.menu.Items = append(tcm.menu.Items, fyne.NewMenuItemSeparator())
 	tcm.menu.Items = append(tcm.menu.Items, favorite, unfavorite)
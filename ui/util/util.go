@@ -19,6 +19,8 @@ import (
 	"fyne.io/fyne/v2/widget"
 	ttwidget "github.com/dweymouth/fyne-tooltip/widget"
 	"github.com/dweymouth/supersonic/backend/mediaprovider"
+	"github.com/dweymouth/supersonic/backend/mediaprovider/jellyfin"
+	"github.com/dweymouth/supersonic/backend/mediaprovider/unified"
 	"github.com/dweymouth/supersonic/res"
 	"github.com/dweymouth/supersonic/sharedutil"
 	myTheme "github.com/dweymouth/supersonic/ui/theme"
@@ -336,6 +338,20 @@ func DisplayReleaseType(releaseTypes mediaprovider.ReleaseTypes) string {
 	return strings.Join(modifiers, " ")
 }
 
+// IsOwnPlaylist returns whether the playlist is owned by the logged in user.
+func IsOwnPlaylist(mp mediaprovider.MediaProvider, playlist *mediaprovider.Playlist, loggedInUser string) bool {
+	if u, isUnified := mp.(*unified.MediaProvider); isUnified {
+		// owned by the user logged in to the playlist's server
+		return u.IsOwnPlaylist(playlist)
+	}
+	if _, isJellyfin := mp.(*jellyfin.JellyfinMediaProvider); isJellyfin {
+		// Jellyfin usernames are case-insensitive
+		return strings.EqualFold(playlist.Owner, loggedInUser)
+	}
+	// Subsonic usernames are case-sensitive
+	return playlist.Owner == loggedInUser
+}
+
 func NewRatingSubmenu(onSetRating func(int)) *fyne.MenuItem {
 	newRatingMenuItem := func(rating int) *fyne.MenuItem {
 		label := fmt.Sprintf("(%s)", lang.L("none"))
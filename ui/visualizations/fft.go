@@ -0,0 +1,106 @@
+package visualizations
+
+import (
+	"math"
+	"math/bits"
+)
+
+const (
+	// number of samples analyzed per frame (~93 ms at 44.1 kHz)
+	fftSize = 4096
+
+	minFrequency = 30
+	maxFrequency = 16000
+
+	// dB range shown by the spectrum visualizations
+	spectrumRangeDB = 70
+)
+
+// spectrumAnalysis computes the magnitude spectrum of the mono mix of
+// stereo samples, reusing its buffers between frames.
+type spectrumAnalysis struct {
+	window []float64
+	re, im []float64
+	mags   []float64 // dB relative to full scale, for bins 0 to fftSize/2
+}
+
+func newSpectrumAnalysis() *spectrumAnalysis {
+	s := &spectrumAnalysis{
+		window: make([]float64, fftSize),
+		re:     make([]float64, fftSize),
+		im:     make([]float64, fftSize),
+		mags:   make([]float64, fftSize/2+1),
+	}
+	// Hann window
+	for i := range s.window {
+		s.window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(fftSize-1))
+	}
+	return s
+}
+
+// analyze computes the magnitude spectrum of the last fftSize samples
+// (or fewer, zero padded) of left and right.
+func (s *spectrumAnalysis) analyze(left, right []float64) []float64 {
+	n := min(len(left), len(right), fftSize)
+	left, right = left[len(left)-n:], right[len(right)-n:]
+	clear(s.re)
+	clear(s.im)
+	for i := range n {
+		s.re[i] = 0.5 * (left[i] + right[i]) * s.window[i]
+	}
+	fft(s.re, s.im)
+	// a full scale sine has magnitude fftSize/4 with the Hann window
+	norm := 4.0 / fftSize
+	for i := range s.mags {
+		mag := math.Hypot(s.re[i], s.im[i]) * norm
+		s.mags[i] = 20 * math.Log10(max(mag, 1e-9))
+	}
+	return s.mags
+}
+
+// bandLevels sets levels to the peak magnitude (dB) of each of len(levels)
+// bands spaced logarithmically from minFrequency to maxFrequency.
+func bandLevels(mags []float64, sampleRate int, levels []float64) {
+	binHz := float64(sampleRate) / fftSize
+	ratio := math.Pow(maxFrequency/minFrequency, 1/float64(len(levels)))
+	lo := float64(minFrequency)
+	for i := range levels {
+		hi := lo * ratio
+		first := int(math.Round(lo / binHz))
+		last := min(int(math.Round(hi/binHz)), len(mags)-1)
+		level := mags[min(first, len(mags)-1)]
+		for b := first + 1; b <= last; b++ {
+			level = max(level, mags[b])
+		}
+		levels[i] = level
+		lo = hi
+	}
+}
+
+// fft computes the discrete Fourier transform of re + i*im in place.
+// The length must be a power of two.
+func fft(re, im []float64) {
+	n := len(re)
+	shift := 64 - bits.Len(uint(n-1))
+	for i := range n {
+		j := int(bits.Reverse64(uint64(i)) >> shift)
+		if j > i {
+			re[i], re[j] = re[j], re[i]
+			im[i], im[j] = im[j], im[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		half := size / 2
+		step := -2 * math.Pi / float64(size)
+		for start := 0; start < n; start += size {
+			for k := range half {
+				wRe, wIm := math.Cos(step*float64(k)), math.Sin(step*float64(k))
+				a, b := start+k, start+k+half
+				tRe := wRe*re[b] - wIm*im[b]
+				tIm := wRe*im[b] + wIm*re[b]
+				re[b], im[b] = re[a]-tRe, im[a]-tIm
+				re[a], im[a] = re[a]+tRe, im[a]+tIm
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package visualizations
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSpectrumAnalysis(t *testing.T) {
+	const sampleRate = 44100
+	const freq = 1000.0
+	left := make([]float64, fftSize)
+	right := make([]float64, fftSize)
+	for i := range left {
+		left[i] = math.Sin(2 * math.Pi * freq * float64(i) / sampleRate)
+		right[i] = left[i]
+	}
+
+	mags := newSpectrumAnalysis().analyze(left, right)
+	peak := 0
+	for i := range mags {
+		if mags[i] > mags[peak] {
+			peak = i
+		}
+	}
+	if f := float64(peak) * sampleRate / fftSize; math.Abs(f-freq) > sampleRate/fftSize {
+		t.Errorf("expected peak at %g Hz, got %g Hz", freq, f)
+	}
+	if math.Abs(mags[peak]) > 1.5 {
+		t.Errorf("expected full scale sine near 0 dB, got %g dB", mags[peak])
+	}
+
+	levels := make([]float64, 32)
+	bandLevels(mags, sampleRate, levels)
+	loudest := 0
+	for i := range levels {
+		if levels[i] > levels[loudest] {
+			loudest = i
+		}
+	}
+	// the band containing 1 kHz
+	ratio := math.Pow(maxFrequency/minFrequency, 1/float64(len(levels)))
+	want := int(math.Log(freq/minFrequency) / math.Log(ratio))
+	if loudest != want {
+		t.Errorf("expected band %d to be loudest, got %d", want, loudest)
+	}
+}
@@ -0,0 +1,206 @@
+package visualizations
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	myTheme "github.com/dweymouth/supersonic/ui/theme"
+)
+
+const (
+	goniometerImageSize = 256
+	// number of most recent samples plotted per frame
+	goniometerSamples = 1024
+	// fraction of the previous frames' brightness kept each frame
+	goniometerPersistence = 0.8
+)
+
+// Goniometer is a stereo oscilloscope plotting the left channel against the
+// right, rotated 45° so that mono (mid) signals are vertical and out of phase
+// (side) signals horizontal. A correlation meter below shows the phase
+// correlation from -1 (out of phase) to +1 (mono).
+type Goniometer struct {
+	widget.BaseWidget
+
+	// plotted brightness of each pixel, 0-1
+	intensity   [goniometerImageSize * goniometerImageSize]float32
+	img         *image.NRGBA
+	correlation float64
+
+	// true iff only a layout is needed, rathern than a full refresh.
+	// cleared by the renderer
+	refreshLayoutOnly bool
+}
+
+var _ SampleVisualization = (*Goniometer)(nil)
+
+func NewGoniometer() *Goniometer {
+	g := &Goniometer{img: image.NewNRGBA(image.Rect(0, 0, goniometerImageSize, goniometerImageSize))}
+	g.ExtendBaseWidget(g)
+	return g
+}
+
+func (g *Goniometer) UpdateSamples(left, right []float64, _ int) {
+	for i := range g.intensity {
+		g.intensity[i] *= goniometerPersistence
+	}
+	n := min(len(left), len(right), goniometerSamples)
+	left, right = left[len(left)-n:], right[len(right)-n:]
+	var lr, ll, rr float64
+	half := float64(goniometerImageSize) / 2
+	for i := range n {
+		l, r := left[i], right[i]
+		lr += l * r
+		ll += l * l
+		rr += r * r
+		// scale so that full scale mono reaches the top edge,
+		// with the left channel alone on the upper left diagonal
+		side := (l - r) * math.Sqrt2 / 2
+		mid := (l + r) * math.Sqrt2 / 2
+		x := int(half - side*half/math.Sqrt2)
+		y := int(half - mid*half/math.Sqrt2)
+		if x >= 0 && x < goniometerImageSize && y >= 0 && y < goniometerImageSize {
+			g.intensity[y*goniometerImageSize+x] = 1
+		}
+	}
+	if ll > 0 && rr > 0 {
+		g.correlation = lr / math.Sqrt(ll*rr)
+	} else {
+		g.correlation = 0
+	}
+	g.refreshLayoutOnly = true
+	g.Refresh()
+}
+
+func (g *Goniometer) Refresh() {
+	g.refreshLayoutOnly = false
+	g.BaseWidget.Refresh()
+}
+
+func (g *Goniometer) CreateRenderer() fyne.WidgetRenderer {
+	r := &goniometerRenderer{g: g}
+	r.image = canvas.NewImageFromImage(g.img)
+	r.image.FillMode = canvas.ImageFillContain
+	r.image.ScaleMode = canvas.ImageScaleFastest
+	for i, text := range []string{"L", "R", "M", "S"} {
+		r.labels[i].Text = text
+		r.labels[i].TextSize = 11
+		r.labels[i].TextStyle.Bold = true
+	}
+	r.Refresh()
+	return r
+}
+
+type goniometerRenderer struct {
+	g *Goniometer
+
+	image        *canvas.Image
+	axes         [4]canvas.Line // L, R, M, S
+	labels       [4]canvas.Text
+	corrTrack    canvas.Rectangle
+	corrCenter   canvas.Rectangle
+	corrPosition canvas.Rectangle
+
+	fgColor color.NRGBA
+
+	objects []fyne.CanvasObject
+}
+
+func (r *goniometerRenderer) MinSize() fyne.Size {
+	return fyne.NewSize(150, 170)
+}
+
+func (r *goniometerRenderer) Layout(size fyne.Size) {
+	corrHeight := float32(8)
+	corrSpacing := float32(6)
+	plotSize := min(size.Width, size.Height-corrHeight-corrSpacing)
+	origin := fyne.NewPos((size.Width-plotSize)/2, 0)
+	r.image.Move(origin)
+	r.image.Resize(fyne.NewSquareSize(plotSize))
+
+	c := origin.AddXY(plotSize/2, plotSize/2)
+	d := plotSize / 2
+	ends := [4][2]fyne.Position{
+		{c.AddXY(-d/math.Sqrt2, -d/math.Sqrt2), c.AddXY(d/math.Sqrt2, d/math.Sqrt2)}, // L
+		{c.AddXY(d/math.Sqrt2, -d/math.Sqrt2), c.AddXY(-d/math.Sqrt2, d/math.Sqrt2)}, // R
+		{c.AddXY(0, -d), c.AddXY(0, d)},                                              // M
+		{c.AddXY(-d, 0), c.AddXY(d, 0)},                                              // S
+	}
+	for i := range r.axes {
+		r.axes[i].Position1, r.axes[i].Position2 = ends[i][0], ends[i][1]
+		r.axes[i].Refresh()
+	}
+	labelPos := []fyne.Position{ends[0][0], ends[1][0], ends[2][0], ends[3][1]}
+	for i := range r.labels {
+		r.labels[i].Move(labelPos[i].AddXY(2, 0))
+	}
+
+	y := plotSize + corrSpacing
+	r.corrTrack.Move(fyne.NewPos(origin.X, y))
+	r.corrTrack.Resize(fyne.NewSize(plotSize, corrHeight))
+	r.corrCenter.Move(fyne.NewPos(origin.X+plotSize/2, y))
+	r.corrCenter.Resize(fyne.NewSize(1, corrHeight))
+	markerWidth := theme.SeparatorThicknessSize() * 3
+	x := origin.X + float32((r.g.correlation+1)/2)*(plotSize-markerWidth)
+	r.corrPosition.Move(fyne.NewPos(x, y))
+	r.corrPosition.Resize(fyne.NewSize(markerWidth, corrHeight))
+}
+
+func (r *goniometerRenderer) Refresh() {
+	if !r.g.refreshLayoutOnly {
+		r.refreshColors()
+	}
+	r.g.refreshLayoutOnly = false
+
+	// render the plot with the trace fading into the background
+	c := color.NRGBAModel.Convert(theme.PrimaryColor()).(color.NRGBA)
+	for i, v := range r.g.intensity {
+		off := i * 4
+		r.g.img.Pix[off+0] = c.R
+		r.g.img.Pix[off+1] = c.G
+		r.g.img.Pix[off+2] = c.B
+		r.g.img.Pix[off+3] = uint8(v * 255)
+	}
+	r.image.Refresh()
+
+	if r.g.correlation < 0 {
+		r.corrPosition.FillColor = theme.ErrorColor()
+	} else {
+		r.corrPosition.FillColor = r.fgColor
+	}
+	r.Layout(r.g.Size())
+}
+
+func (r *goniometerRenderer) refreshColors() {
+	r.fgColor = color.NRGBAModel.Convert(theme.ForegroundColor()).(color.NRGBA)
+	ruleColor := myTheme.BlendColors(r.fgColor, theme.BackgroundColor(), 0.25)
+	for i := range r.axes {
+		r.axes[i].StrokeColor = ruleColor
+		r.axes[i].StrokeWidth = 1
+	}
+	for i := range r.labels {
+		r.labels[i].Color = r.fgColor
+	}
+	r.corrTrack.FillColor = ruleColor
+	r.corrCenter.FillColor = r.fgColor
+}
+
+func (r *goniometerRenderer) Objects() []fyne.CanvasObject {
+	if r.objects == nil {
+		r.objects = make([]fyne.CanvasObject, 0, 12)
+		for i := range r.axes {
+			r.objects = append(r.objects, &r.axes[i], &r.labels[i])
+		}
+		r.objects = append(r.objects, r.image, &r.corrTrack, &r.corrCenter, &r.corrPosition)
+	}
+	return r.objects
+}
+
+func (r *goniometerRenderer) Destroy() {
+}
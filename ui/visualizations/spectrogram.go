@@ -0,0 +1,118 @@
+package visualizations
+
+import (
+	"image"
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	myTheme "github.com/dweymouth/supersonic/ui/theme"
+)
+
+const (
+	// number of frames (~8.5 seconds at 60 Hz) shown
+	spectrogramWidth = 512
+	// number of frequency bands, spaced logarithmically
+	spectrogramHeight = 192
+)
+
+// Spectrogram shows the spectrum over time, scrolling from right to left,
+// with the level of each frequency band shown by its color, from the
+// background color for silence to the primary color to the foreground
+// color for full scale.
+type Spectrogram struct {
+	widget.BaseWidget
+
+	analysis *spectrumAnalysis
+	levels   [spectrogramHeight]float64
+	img      *image.NRGBA
+
+	// colors for each level from -spectrumRangeDB to 0 dB
+	palette  [256]color.NRGBA
+	themeKey [3]color.Color
+}
+
+var _ SampleVisualization = (*Spectrogram)(nil)
+
+func NewSpectrogram() *Spectrogram {
+	s := &Spectrogram{
+		analysis: newSpectrumAnalysis(),
+		img:      image.NewNRGBA(image.Rect(0, 0, spectrogramWidth, spectrogramHeight)),
+	}
+	s.ExtendBaseWidget(s)
+	return s
+}
+
+func (s *Spectrogram) UpdateSamples(left, right []float64, sampleRate int) {
+	s.updatePalette()
+	bandLevels(s.analysis.analyze(left, right), sampleRate, s.levels[:])
+
+	stride := s.img.Stride
+	for y := range spectrogramHeight {
+		row := s.img.Pix[y*stride : (y+1)*stride]
+		// scroll left by one column
+		copy(row, row[4:])
+		// lowest frequencies at the bottom
+		level := s.levels[spectrogramHeight-1-y]
+		idx := int((level + spectrumRangeDB) / spectrumRangeDB * 255)
+		c := s.palette[max(0, min(idx, 255))]
+		px := row[stride-4:]
+		px[0], px[1], px[2], px[3] = c.R, c.G, c.B, c.A
+	}
+	s.Refresh()
+}
+
+// updatePalette recomputes the level colors if the theme changed.
+func (s *Spectrogram) updatePalette() {
+	bg, primary, fg := theme.BackgroundColor(), theme.PrimaryColor(), theme.ForegroundColor()
+	if s.themeKey == [3]color.Color{bg, primary, fg} {
+		return
+	}
+	s.themeKey = [3]color.Color{bg, primary, fg}
+	for i := range s.palette {
+		f := float64(i) / 255
+		var c color.Color
+		if f < 0.6 {
+			c = myTheme.BlendColors(primary, bg, f/0.6)
+		} else {
+			c = myTheme.BlendColors(fg, primary, (f-0.6)/0.4)
+		}
+		s.palette[i] = color.NRGBAModel.Convert(c).(color.NRGBA)
+	}
+	// clear the history, which was drawn with the old colors
+	clear(s.img.Pix)
+}
+
+func (s *Spectrogram) CreateRenderer() fyne.WidgetRenderer {
+	img := canvas.NewImageFromImage(s.img)
+	img.FillMode = canvas.ImageFillStretch
+	img.ScaleMode = canvas.ImageScaleFastest
+	return &spectrogramRenderer{s: s, image: img}
+}
+
+type spectrogramRenderer struct {
+	s     *Spectrogram
+	image *canvas.Image
+}
+
+func (r *spectrogramRenderer) MinSize() fyne.Size {
+	return fyne.NewSize(275, 100)
+}
+
+func (r *spectrogramRenderer) Layout(size fyne.Size) {
+	r.image.Resize(size)
+}
+
+func (r *spectrogramRenderer) Refresh() {
+	r.image.Refresh()
+}
+
+func (r *spectrogramRenderer) Objects() []fyne.CanvasObject {
+	return []fyne.CanvasObject{r.image}
+}
+
+func (r *spectrogramRenderer) Destroy() {
+}
@@ -0,0 +1,188 @@
+package visualizations
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	myTheme "github.com/dweymouth/supersonic/ui/theme"
+)
+
+// SampleVisualization is a visualization driven by the PCM samples being played.
+type SampleVisualization interface {
+	fyne.CanvasObject
+
+	// UpdateSamples updates the visualization with the latest stereo samples.
+	// This function is expected to be called from a fyne.Animation callback,
+	// running at 60 Hz
+	UpdateSamples(left, right []float64, sampleRate int)
+}
+
+const (
+	spectrumBars         = 40
+	spectrumFalloffDB    = 1.0 // per frame
+	spectrumPeakFalloff  = 0.3 // dB per frame
+	spectrumPeakHoldTime = 30  // frames
+)
+
+var spectrumRulerFreqs = []int{50, 100, 200, 500, 1000, 2000, 5000, 10000}
+
+// SpectrumAnalyzer shows the frequency spectrum as bars
+// spaced logarithmically in frequency.
+type SpectrumAnalyzer struct {
+	widget.BaseWidget
+
+	analysis  *spectrumAnalysis
+	bands     [spectrumBars]float64
+	levels    [spectrumBars]float64
+	peaks     [spectrumBars]float64
+	peakHolds [spectrumBars]int
+
+	// true iff only a layout is needed, rathern than a full refresh.
+	// cleared by the renderer
+	refreshLayoutOnly bool
+}
+
+var _ SampleVisualization = (*SpectrumAnalyzer)(nil)
+
+func NewSpectrumAnalyzer() *SpectrumAnalyzer {
+	s := &SpectrumAnalyzer{analysis: newSpectrumAnalysis()}
+	for i := range s.levels {
+		s.levels[i] = -spectrumRangeDB
+		s.peaks[i] = -spectrumRangeDB
+	}
+	s.ExtendBaseWidget(s)
+	return s
+}
+
+func (s *SpectrumAnalyzer) UpdateSamples(left, right []float64, sampleRate int) {
+	bandLevels(s.analysis.analyze(left, right), sampleRate, s.bands[:])
+	for i, level := range s.bands {
+		level = max(level, -spectrumRangeDB)
+		// bars rise immediately and fall gradually
+		s.levels[i] = max(level, s.levels[i]-spectrumFalloffDB)
+		if s.levels[i] >= s.peaks[i] {
+			s.peaks[i] = s.levels[i]
+			s.peakHolds[i] = spectrumPeakHoldTime
+		} else if s.peakHolds[i] > 0 {
+			s.peakHolds[i]--
+		} else {
+			s.peaks[i] = max(s.peaks[i]-spectrumPeakFalloff, -spectrumRangeDB)
+		}
+	}
+	s.refreshLayoutOnly = true
+	s.Refresh()
+}
+
+func (s *SpectrumAnalyzer) Refresh() {
+	s.refreshLayoutOnly = false
+	s.BaseWidget.Refresh()
+}
+
+func (s *SpectrumAnalyzer) CreateRenderer() fyne.WidgetRenderer {
+	return newSpectrumRenderer(s)
+}
+
+type spectrumRenderer struct {
+	s *SpectrumAnalyzer
+
+	bars        [spectrumBars]canvas.Rectangle
+	peaks       [spectrumBars]canvas.Rectangle
+	rulerLines  []canvas.Rectangle
+	rulerLabels []canvas.Text
+
+	objects []fyne.CanvasObject
+}
+
+func newSpectrumRenderer(s *SpectrumAnalyzer) *spectrumRenderer {
+	r := &spectrumRenderer{s: s}
+	r.rulerLines = make([]canvas.Rectangle, len(spectrumRulerFreqs))
+	r.rulerLabels = make([]canvas.Text, len(spectrumRulerFreqs))
+	for i, f := range spectrumRulerFreqs {
+		if f >= 1000 {
+			r.rulerLabels[i].Text = fmt.Sprintf("%dk", f/1000)
+		} else {
+			r.rulerLabels[i].Text = fmt.Sprintf("%d", f)
+		}
+		r.rulerLabels[i].TextSize = 11
+		r.rulerLabels[i].Resize(r.rulerLabels[i].MinSize())
+	}
+	r.Refresh()
+	return r
+}
+
+func (r *spectrumRenderer) MinSize() fyne.Size {
+	return fyne.NewSize(275, 100)
+}
+
+func (r *spectrumRenderer) Layout(size fyne.Size) {
+	labelHeight := float32(14)
+	height := size.Height - labelHeight
+	barSpacing := float32(2)
+	barWidth := size.Width/spectrumBars - barSpacing
+	peakHeight := theme.SeparatorThicknessSize() * 2
+
+	heightFor := func(db float64) float32 {
+		return float32((db+spectrumRangeDB)/spectrumRangeDB) * height
+	}
+	for i := range r.bars {
+		x := float32(i)*(barWidth+barSpacing) + barSpacing/2
+		h := heightFor(r.s.levels[i])
+		r.bars[i].Move(fyne.NewPos(x, height-h))
+		r.bars[i].Resize(fyne.NewSize(barWidth, h))
+		p := heightFor(r.s.peaks[i])
+		r.peaks[i].Move(fyne.NewPos(x, height-p-peakHeight))
+		r.peaks[i].Resize(fyne.NewSize(barWidth, peakHeight))
+	}
+
+	logRange := math.Log(maxFrequency / minFrequency)
+	for i, f := range spectrumRulerFreqs {
+		x := float32(math.Log(float64(f)/minFrequency)/logRange) * size.Width
+		r.rulerLines[i].Move(fyne.NewPos(x, 0))
+		r.rulerLines[i].Resize(fyne.NewSize(1, height))
+		r.rulerLabels[i].Move(fyne.NewPos(x-r.rulerLabels[i].MinSize().Width/2, height))
+	}
+}
+
+func (r *spectrumRenderer) Refresh() {
+	if r.s.refreshLayoutOnly {
+		r.s.refreshLayoutOnly = false
+		r.Layout(r.s.Size())
+		return
+	}
+
+	foreground := theme.ForegroundColor()
+	c := color.NRGBAModel.Convert(theme.PrimaryColor()).(color.NRGBA)
+	c.A = 160
+	ruleColor := myTheme.BlendColors(foreground, theme.BackgroundColor(), 0.25)
+	for i := range r.bars {
+		r.bars[i].FillColor = c
+		r.peaks[i].FillColor = foreground
+	}
+	for i := range r.rulerLines {
+		r.rulerLines[i].FillColor = ruleColor
+		r.rulerLabels[i].Color = foreground
+	}
+	r.Layout(r.s.Size())
+}
+
+func (r *spectrumRenderer) Objects() []fyne.CanvasObject {
+	if r.objects == nil {
+		r.objects = make([]fyne.CanvasObject, 0, 2*len(r.rulerLines)+2*spectrumBars)
+		for i := range r.rulerLines {
+			r.objects = append(r.objects, &r.rulerLines[i], &r.rulerLabels[i])
+		}
+		for i := range r.bars {
+			r.objects = append(r.objects, &r.bars[i], &r.peaks[i])
+		}
+	}
+	return r.objects
+}
+
+func (r *spectrumRenderer) Destroy() {
+}
@@ -0,0 +1,103 @@
+package visualizations
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/lang"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Names of the sample-driven visualizations, as saved in the config.
+const (
+	VisualizationSpectrum    = "Spectrum"
+	VisualizationGoniometer  = "Goniometer"
+	VisualizationSpectrogram = "Spectrogram"
+)
+
+var SampleVisualizationNames = []string{
+	VisualizationSpectrum,
+	VisualizationGoniometer,
+	VisualizationSpectrogram,
+}
+
+// NewSampleVisualization creates the sample-driven visualization with the given
+// name, or the spectrum analyzer if the name is not recognized.
+func NewSampleVisualization(name string) SampleVisualization {
+	switch name {
+	case VisualizationGoniometer:
+		return NewGoniometer()
+	case VisualizationSpectrogram:
+		return NewSpectrogram()
+	default:
+		return NewSpectrumAnalyzer()
+	}
+}
+
+// VisualizerView shows one of the sample-driven visualizations,
+// with a selector to switch between them.
+type VisualizerView struct {
+	widget.BaseWidget
+
+	OnVisualizationChanged func(name string)
+
+	active    SampleVisualization
+	activeKey string
+	selector  *widget.Select
+	content   *fyne.Container
+	container *fyne.Container
+}
+
+var _ SampleVisualization = (*VisualizerView)(nil)
+
+func NewVisualizerView(initial string) *VisualizerView {
+	v := &VisualizerView{content: container.NewStack()}
+	labels := make([]string, len(SampleVisualizationNames))
+	for i, name := range SampleVisualizationNames {
+		labels[i] = lang.L(name)
+	}
+	v.selector = widget.NewSelect(labels, func(label string) {
+		for i, l := range labels {
+			if l == label && SampleVisualizationNames[i] != v.activeKey {
+				v.setVisualization(SampleVisualizationNames[i])
+				if v.OnVisualizationChanged != nil {
+					v.OnVisualizationChanged(v.activeKey)
+				}
+			}
+		}
+	})
+	v.SetVisualization(initial)
+	v.container = container.NewBorder(
+		container.NewHBox(layout.NewSpacer(), v.selector),
+		nil, nil, nil, v.content)
+	v.ExtendBaseWidget(v)
+	return v
+}
+
+// SetVisualization shows the visualization with the given name.
+func (v *VisualizerView) SetVisualization(name string) {
+	v.setVisualization(name)
+	for i, n := range SampleVisualizationNames {
+		if n == v.activeKey {
+			v.selector.SetSelectedIndex(i)
+		}
+	}
+}
+
+func (v *VisualizerView) setVisualization(name string) {
+	v.active = NewSampleVisualization(name)
+	v.activeKey = VisualizationSpectrum
+	if name == VisualizationGoniometer || name == VisualizationSpectrogram {
+		v.activeKey = name
+	}
+	v.content.Objects = []fyne.CanvasObject{v.active}
+	v.content.Refresh()
+}
+
+func (v *VisualizerView) UpdateSamples(left, right []float64, sampleRate int) {
+	v.active.UpdateSamples(left, right, sampleRate)
+}
+
+func (v *VisualizerView) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(v.container)
+}
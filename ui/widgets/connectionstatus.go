@@ -0,0 +1,72 @@
+package widgets
+
+import (
+	"fmt"
+	"net/url"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/lang"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/dweymouth/supersonic/backend"
+
+	ttwidget "github.com/dweymouth/fyne-tooltip/widget"
+)
+
+// ConnectionStatus shows which hostname of the server is in use and its
+// latency in a tooltip, and that the app is reconnecting while the server
+// is unreachable. Tapping it checks the connection again.
+type ConnectionStatus struct {
+	widget.BaseWidget
+
+	OnTapped func()
+
+	btn *ttwidget.Button
+}
+
+func NewConnectionStatus() *ConnectionStatus {
+	c := &ConnectionStatus{}
+	c.ExtendBaseWidget(c)
+	c.btn = ttwidget.NewButtonWithIcon("", theme.ConfirmIcon(), func() {
+		if c.OnTapped != nil {
+			c.OnTapped()
+		}
+	})
+	c.btn.Importance = widget.LowImportance
+	c.Hide()
+	return c
+}
+
+// SetStatus shows the connection status, or hides the widget if
+// the server has no hostname of its own (e.g. a Unified library).
+func (c *ConnectionStatus) SetStatus(status backend.ConnectionStatus) {
+	if status.Hostname == "" {
+		c.Hide()
+		return
+	}
+	host := status.Hostname
+	if u, err := url.Parse(status.Hostname); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	if status.State == backend.ConnectionReconnecting {
+		c.btn.SetText(lang.L("Reconnecting") + "...")
+		c.btn.SetIcon(theme.WarningIcon())
+		c.btn.Importance = widget.WarningImportance
+		c.btn.SetToolTip(fmt.Sprintf(lang.L("Can't reach %s"), host))
+	} else {
+		c.btn.SetText("")
+		c.btn.SetIcon(theme.ConfirmIcon())
+		c.btn.Importance = widget.LowImportance
+		tip := fmt.Sprintf(lang.L("Connected to %s"), host)
+		if status.Latency > 0 {
+			tip += fmt.Sprintf(" (%d ms)", status.Latency.Milliseconds())
+		}
+		c.btn.SetToolTip(tip)
+	}
+	c.Show()
+	c.btn.Refresh()
+}
+
+func (c *ConnectionStatus) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(c.btn)
+}
@@ -133,6 +133,9 @@ type GridView struct {
 	itemWidth          float32
 	numColsCached      int
 	shareMenuItem      *fyne.MenuItem
+
+	// scroll offset to apply once enough items have loaded
+	pendingScrollPos float32
 }
 
 type GridViewState struct {
@@ -232,6 +235,7 @@ func (g *GridView) Clear() {
 	g.cancelFetch()
 	g.items = nil
 	g.done = true
+	g.pendingScrollPos = 0
 }
 
 func (g *GridView) Reset(iter GridViewIterator) {
@@ -242,6 +246,7 @@ func (g *GridView) Reset(iter GridViewIterator) {
 	g.done = false
 	g.highestShown = 0
 	g.iter = iter
+	g.pendingScrollPos = 0
 	g.stateMutex.Unlock()
 	g.checkFetchMoreItems(36)
 	g.loadingDots.Start()
@@ -278,9 +283,27 @@ func (g *GridView) ScrollToOffset(offs float32) {
 	g.grid.ScrollToOffset(offs)
 }
 
+// ScrollToOffsetWhenLoaded scrolls to the given offset, waiting
+// for more items to be fetched if the view is not yet long enough.
+func (g *GridView) ScrollToOffsetWhenLoaded(offs float32) {
+	g.pendingScrollPos = offs
+	g.applyPendingScroll()
+}
+
+func (g *GridView) applyPendingScroll() {
+	if g.pendingScrollPos <= 0 || g.Size().Height == 0 {
+		return
+	}
+	g.grid.ScrollToOffset(g.pendingScrollPos)
+	if g.grid.GetScrollOffset() >= g.pendingScrollPos-1 || g.done {
+		g.pendingScrollPos = 0
+	}
+}
+
 func (g *GridView) Resize(size fyne.Size) {
 	g.numColsCached = -1
 	g.BaseWidget.Resize(size)
+	g.applyPendingScroll()
 }
 
 var _ fyne.Tappable = (*GridView)(nil)
@@ -456,6 +479,7 @@ func (g *GridView) checkFetchMoreItems(count int) {
 						g.loadingDots.Stop()
 						if len(items) > 0 {
 							g.grid.Refresh()
+							g.applyPendingScroll()
 						}
 					})
 				}
@@ -29,6 +29,9 @@ type IconButton struct {
 	IconSize    IconButtonSize
 	OnTapped    func()
 
+	// If set, invoked on right click
+	OnTappedSecondary func(*fyne.PointEvent)
+
 	icon     fyne.Resource
 	focused  bool
 	hovered  bool
@@ -39,10 +42,11 @@ type IconButton struct {
 }
 
 var (
-	_ fyne.Tappable     = (*IconButton)(nil)
-	_ fyne.Focusable    = (*IconButton)(nil)
-	_ fyne.Disableable  = (*IconButton)(nil)
-	_ desktop.Hoverable = (*IconButton)(nil)
+	_ fyne.Tappable          = (*IconButton)(nil)
+	_ fyne.SecondaryTappable = (*IconButton)(nil)
+	_ fyne.Focusable         = (*IconButton)(nil)
+	_ fyne.Disableable       = (*IconButton)(nil)
+	_ desktop.Hoverable      = (*IconButton)(nil)
 )
 
 func NewIconButton(icon fyne.Resource, onTapped func()) *IconButton {
@@ -84,6 +88,12 @@ func (i *IconButton) Tapped(*fyne.PointEvent) {
 	}
 }
 
+func (i *IconButton) TappedSecondary(e *fyne.PointEvent) {
+	if !i.disabled && i.OnTappedSecondary != nil {
+		i.OnTappedSecondary(e)
+	}
+}
+
 func (i *IconButton) FocusGained() {
 	if !i.focused {
 		defer i.Refresh()
@@ -1,6 +1,8 @@
 package widgets
 
 import (
+	"fmt"
+
 	"github.com/dweymouth/supersonic/backend"
 	myTheme "github.com/dweymouth/supersonic/ui/theme"
 	"github.com/dweymouth/supersonic/ui/util"
@@ -87,13 +89,16 @@ type PlayerControls struct {
 
 	UseWaveformSeekbar bool
 
-	OnChangeShuffle func(shuffle bool)
+	OnChangeShuffle     func(shuffle bool)
+	OnChangeShuffleMode func(mode string, artistSpread bool)
 
 	slider         *TrackPosSlider
 	waveform       *WaveformSeekbar
 	curTimeLabel   *labelMinSize
 	totalTimeLabel *labelMinSize
 	shuffle        *IconButton
+	shuffleMode    string
+	artistSpread   bool
 	prev           *IconButton
 	playpause      *IconButton
 	next           *IconButton
@@ -155,6 +160,7 @@ func NewPlayerControls(useWaveformSeekbar bool, initialLoopMode backend.LoopMode
 			pc.OnChangeShuffle(pc.shuffle.Highlighted)
 		}
 	}
+	pc.shuffle.OnTappedSecondary = pc.showShuffleModeMenu
 
 	pc.loop = NewIconButton(myTheme.RepeatIcon, nil)
 	pc.loop.IconSize = IconButtonSizeSmallest
@@ -226,6 +232,52 @@ func (pc *PlayerControls) SetShuffle(isShuffle bool) {
 	pc.shuffle.Refresh()
 }
 
+// SetShuffleMode sets the shuffle mode shown in the shuffle button's menu.
+func (pc *PlayerControls) SetShuffleMode(mode string, artistSpread bool) {
+	pc.shuffleMode = mode
+	pc.artistSpread = artistSpread
+	tooltip := lang.L("Shuffle")
+	if mode != "" && mode != backend.ShuffleModeRandom {
+		tooltip = fmt.Sprintf("%s (%s)", tooltip, shuffleModeName(mode))
+	}
+	pc.shuffle.SetToolTip(tooltip)
+}
+
+func (pc *PlayerControls) showShuffleModeMenu(e *fyne.PointEvent) {
+	onChanged := func(mode string, artistSpread bool) {
+		pc.SetShuffleMode(mode, artistSpread)
+		if pc.OnChangeShuffleMode != nil {
+			pc.OnChangeShuffleMode(mode, artistSpread)
+		}
+	}
+	var items []*fyne.MenuItem
+	for _, mode := range backend.ShuffleModes {
+		item := fyne.NewMenuItem(shuffleModeName(mode), func() {
+			onChanged(mode, pc.artistSpread)
+		})
+		item.Checked = mode == pc.shuffleMode || (mode == backend.ShuffleModeRandom && pc.shuffleMode == "")
+		items = append(items, item)
+	}
+	spread := fyne.NewMenuItem(lang.L("Avoid same artist twice in a row"), func() {
+		onChanged(pc.shuffleMode, !pc.artistSpread)
+	})
+	spread.Checked = pc.artistSpread
+	items = append(items, fyne.NewMenuItemSeparator(), spread)
+	widget.ShowPopUpMenuAtPosition(fyne.NewMenu("", items...),
+		fyne.CurrentApp().Driver().CanvasForObject(pc), e.AbsolutePosition)
+}
+
+func shuffleModeName(mode string) string {
+	switch mode {
+	case backend.ShuffleModeFavorites:
+		return lang.L("Favor favorites")
+	case backend.ShuffleModeRediscover:
+		return lang.L("Rediscover forgotten tracks")
+	default:
+		return lang.L("Random")
+	}
+}
+
 func (pc *PlayerControls) OnSeekNext(f func()) {
 	pc.next.OnTapped = f
 }
@@ -1,6 +1,7 @@
 package widgets
 
 import (
+	"fmt"
 	"image"
 	"slices"
 	"strconv"
@@ -43,6 +44,7 @@ type PlayQueueList struct {
 	OnPlaySelection     func(items []mediaprovider.MediaItem, shuffle bool)
 	OnPlaySelectionNext func(items []mediaprovider.MediaItem)
 	OnPlaySongRadio     func(track *mediaprovider.Track)
+	OnCustomRadio       func(tracks []*mediaprovider.Track)
 	OnAddToQueue        func(items []mediaprovider.MediaItem)
 	OnAddToPlaylist     func(trackIDs []string)
 	OnSetFavorite       func(trackIDs []string, fav bool)
@@ -53,6 +55,8 @@ type PlayQueueList struct {
 	OnShare             func(tracks []*mediaprovider.Track)
 	OnShowArtistPage    func(artistID string)
 	OnReorderItems      func(idxs []int, reorderTo int)
+	OnRemoveFromUpNext  func(itemID string)
+	OnClearUpNext       func()
 
 	useNonQueueMenu bool
 	menu            *util.TrackContextMenu // ctx menu for when only tracks are selected
@@ -60,10 +64,12 @@ type PlayQueueList struct {
 
 	nowPlayingID string
 
-	list        *FocusList
-	colLayout   *layouts.ColumnsLayout
-	tracksMutex sync.RWMutex
-	items       []*util.TrackListModel
+	list          *FocusList
+	upNextSection *fyne.Container
+	upNextRows    *fyne.Container
+	colLayout     *layouts.ColumnsLayout
+	tracksMutex   sync.RWMutex
+	items         []*util.TrackListModel
 }
 
 func NewPlayQueueList(im *backend.ImageManager, useNonQueueMenu bool) *PlayQueueList {
@@ -118,9 +124,66 @@ func NewPlayQueueList(im *backend.ImageManager, useNonQueueMenu bool) *PlayQueue
 		}
 	}
 
+	clearUpNext := widget.NewButton(lang.L("Clear"), func() {
+		if p.OnClearUpNext != nil {
+			p.OnClearUpNext()
+		}
+	})
+	clearUpNext.Importance = widget.LowImportance
+	p.upNextRows = container.NewVBox()
+	p.upNextSection = container.NewVBox(
+		container.NewHBox(
+			widget.NewLabelWithStyle(lang.L("Up Next"), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			layout.NewSpacer(),
+			clearUpNext,
+		),
+		p.upNextRows,
+		widget.NewSeparator(),
+	)
+	p.upNextSection.Hide()
+
 	return p
 }
 
+// max number of up next items to show individually
+const maxUpNextRows = 5
+
+// SetUpNextItems sets the items added with "play next" that will
+// play before the rest of the queue. The up next section is hidden if empty.
+func (p *PlayQueueList) SetUpNextItems(items []mediaprovider.MediaItem) {
+	p.upNextRows.RemoveAll()
+	for i, item := range items {
+		if i == maxUpNextRows {
+			n := len(items) - i
+			more := widget.NewLabel(lang.LocalizePluralKey("playqueue.upnextmore",
+				fmt.Sprintf("and %d more", n), n, map[string]string{"count": strconv.Itoa(n)}))
+			more.Importance = widget.LowImportance
+			p.upNextRows.Add(more)
+			break
+		}
+		meta := item.Metadata()
+		text := meta.Name
+		if len(meta.Artists) > 0 {
+			text += " – " + meta.Artists[0]
+		}
+		title := widget.NewLabel(text)
+		title.Truncation = fyne.TextTruncateEllipsis
+		remove := NewIconButton(theme.ContentClearIcon(), func() {
+			if p.OnRemoveFromUpNext != nil {
+				p.OnRemoveFromUpNext(meta.ID)
+			}
+		})
+		remove.SetToolTip(lang.L("Remove from queue"))
+		p.upNextRows.Add(container.NewBorder(nil, nil, nil, remove, title))
+	}
+	if len(items) == 0 {
+		p.upNextSection.Hide()
+	} else {
+		p.upNextSection.Show()
+	}
+	p.upNextSection.Refresh()
+}
+
 func (p *PlayQueueList) SetTracks(trs []*mediaprovider.Track) {
 	p.tracksMutex.Lock()
 	p.items = util.ToTrackListModels(trs)
@@ -294,6 +357,11 @@ func (p *PlayQueueList) ensureTracksMenu() {
 	p.menu.OnPlaySongRadio = func() {
 		p.OnPlaySongRadio(p.selectedTracks()[0])
 	}
+	p.menu.OnCustomRadio = func() {
+		if p.OnCustomRadio != nil {
+			p.OnCustomRadio(p.selectedTracks())
+		}
+	}
 	p.menu.OnDownload = func() {
 		p.OnDownload(p.selectedTracks(), "Selected tracks")
 	}
@@ -355,7 +423,7 @@ func (t *PlayQueueList) selectedIdxs() []int {
 }
 
 func (p *PlayQueueList) CreateRenderer() fyne.WidgetRenderer {
-	return widget.NewSimpleRenderer(p.list)
+	return widget.NewSimpleRenderer(container.NewBorder(p.upNextSection, nil, nil, nil, p.list))
 }
 
 type PlayQueueListRow struct {
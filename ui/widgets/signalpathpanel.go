@@ -0,0 +1,109 @@
+package widgets
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dweymouth/supersonic/backend/player/mpv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/lang"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// SignalPathPanel shows the audio format at each stage of local playback
+// (source, decoder and output device), and warns of anything on the path
+// that keeps playback from being bit-perfect.
+type SignalPathPanel struct {
+	widget.BaseWidget
+
+	path     *widget.TextSegment
+	warnings *widget.TextSegment
+	text     *widget.RichText
+}
+
+func NewSignalPathPanel() *SignalPathPanel {
+	s := &SignalPathPanel{
+		path: &widget.TextSegment{Style: widget.RichTextStyleInline},
+		warnings: &widget.TextSegment{Style: widget.RichTextStyle{
+			Inline:    true,
+			SizeName:  theme.SizeNameCaptionText,
+			ColorName: theme.ColorNameWarning,
+		}},
+	}
+	s.text = widget.NewRichText(s.path, &widget.TextSegment{Style: widget.RichTextStyleParagraph}, s.warnings)
+	s.ExtendBaseWidget(s)
+	return s
+}
+
+// Update shows the signal path of the currently playing media.
+func (s *SignalPathPanel) Update(info mpv.MediaInfo) {
+	codec := info.Codec
+	if len(codec) <= 4 {
+		codec = strings.ToUpper(codec)
+	}
+	source := codec
+	if info.SourceSamplerate > 0 {
+		source = fmt.Sprintf("%s %s", codec, formatSamplerate(info.SourceSamplerate))
+	}
+	path := fmt.Sprintf("%s: %s  →  %s: %s  →  %s: %s",
+		lang.L("Source"), source,
+		lang.L("Decoded"), formatAudioParams(info.Format, info.Samplerate, info.ChannelCount),
+		lang.L("Output"), formatAudioParams(info.OutputFormat, info.OutputSamplerate, info.OutputChannelCount))
+
+	warnings := lang.L("Bit-perfect")
+	if len(info.SignalPathIssues) > 0 {
+		msgs := make([]string, len(info.SignalPathIssues))
+		for i, issue := range info.SignalPathIssues {
+			msgs[i] = signalPathIssueText(issue)
+		}
+		warnings = lang.L("Not bit-perfect") + ": " + strings.Join(msgs, ", ")
+	}
+	warningColor := theme.ColorNameWarning
+	if len(info.SignalPathIssues) == 0 {
+		warningColor = theme.ColorNameSuccess
+	}
+
+	if path != s.path.Text || warnings != s.warnings.Text || warningColor != s.warnings.Style.ColorName {
+		s.path.Text = path
+		s.warnings.Text = warnings
+		s.warnings.Style.ColorName = warningColor
+		s.text.Refresh()
+	}
+}
+
+func (s *SignalPathPanel) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(s.text)
+}
+
+func formatSamplerate(rate int) string {
+	return fmt.Sprintf("%g kHz", float64(rate)/1000)
+}
+
+func formatAudioParams(format string, samplerate, channels int) string {
+	if format == "" {
+		return "-"
+	}
+	return fmt.Sprintf("%s %s %d ch", format, formatSamplerate(samplerate), channels)
+}
+
+func signalPathIssueText(issue mpv.SignalPathIssue) string {
+	switch issue {
+	case mpv.SignalPathResampled:
+		return lang.L("resampled")
+	case mpv.SignalPathFormatConverted:
+		return lang.L("sample format converted")
+	case mpv.SignalPathRemixed:
+		return lang.L("channels remixed")
+	case mpv.SignalPathVolumeScaled:
+		return lang.L("volume not at 100%")
+	case mpv.SignalPathReplayGain:
+		return lang.L("ReplayGain applied")
+	case mpv.SignalPathFiltered:
+		return lang.L("audio filters applied")
+	case mpv.SignalPathSharedOutput:
+		return lang.L("output device not exclusive")
+	}
+	return ""
+}
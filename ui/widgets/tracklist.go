@@ -70,6 +70,7 @@ type Tracklist struct {
 	OnDownload          func(tracks []*mediaprovider.Track, downloadName string)
 	OnShare             func(trackID string)
 	OnPlaySongRadio     func(track *mediaprovider.Track)
+	OnCustomRadio       func(tracks []*mediaprovider.Track)
 	OnReorderTracks     func(trackIDs []string, insertPos int)
 	OnShowTrackInfo     func(track *mediaprovider.Track)
 
@@ -528,6 +529,11 @@ func (t *Tracklist) onShowContextMenu(e *fyne.PointEvent, trackIdx int) {
 		t.ctxMenu.OnPlaySongRadio = func() {
 			t.onPlaySongRadio(t.SelectedTracks())
 		}
+		t.ctxMenu.OnCustomRadio = func() {
+			if t.OnCustomRadio != nil {
+				t.OnCustomRadio(t.SelectedTracks())
+			}
+		}
 		t.ctxMenu.OnAddToPlaylist = func() {
 			t.OnAddToPlaylist(t.SelectedTrackIDs())
 		}